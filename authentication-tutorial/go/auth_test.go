@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockOAuthServer is a local OAuth2 token endpoint for exercising the token
+// flow without real credentials. It rejects credentials other than
+// test-client and test-secret, and otherwise respond writes the
+// response to the nth token request, counting from 1.
+type mockOAuthServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests int
+	respond  func(n int, w http.ResponseWriter)
+}
+
+func newMockOAuthServer(respond func(n int, w http.ResponseWriter)) *mockOAuthServer {
+	m := &mockOAuthServer{respond: respond}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		m.requests++
+		n := m.requests
+		m.mu.Unlock()
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "test-client" || secret != "test-secret" || r.PostFormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		m.respond(n, w)
+	}))
+	return m
+}
+
+// Requests returns how many token requests were made
+func (m *mockOAuthServer) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+// issue responds with a token numbered after the request that expires in
+// expiresIn seconds
+func issue(expiresIn int) func(int, http.ResponseWriter) {
+	return func(n int, w http.ResponseWriter) {
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}
+}
+
+// failFirst responds with status to the first failures requests, then
+// issues tokens
+func failFirst(failures, status int) func(int, http.ResponseWriter) {
+	return func(n int, w http.ResponseWriter) {
+		if n <= failures {
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"error":"mock failure %d"}`, n)
+			return
+		}
+		issue(3600)(n, w)
+	}
+}
+
+// respondWith always responds with status and body
+func respondWith(status int, body string) func(int, http.ResponseWriter) {
+	return func(_ int, w http.ResponseWriter) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}
+}
+
+// expectToken fetches a token through the token manager and checks it
+func expectToken(want string) error {
+	token, err := tokenManager.EnsureValidToken()
+	if err != nil {
+		return err
+	}
+	if token != want {
+		return fmt.Errorf("got %s, want %s", token, want)
+	}
+	return nil
+}
+
+// expectError checks that fetching a token fails with an error containing
+// want
+func expectError(want string) error {
+	_, err := tokenManager.EnsureValidToken()
+	if err == nil {
+		return fmt.Errorf("got a token, want an error containing %q", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		return fmt.Errorf("got error %q, want one containing %q", err, want)
+	}
+	return nil
+}
+
+// expireIn moves the current token's expiry to d from now, keeping its
+// hour-long lifetime so the refresh margin isn't capped
+func expireIn(d time.Duration) {
+	token := tokenManager.token
+	token.ExpiresAt = time.Now().Add(d).Unix()
+	token.IssuedAt = token.ExpiresAt - int64(time.Hour/time.Second)
+}
+
+// tokenFlowTests are the scenarios TestTokenFlow runs. Each gets a fresh
+// mock server and token manager, and checks how many token requests it made.
+var tokenFlowTests = []struct {
+	name         string
+	respond      func(n int, w http.ResponseWriter)
+	check        func() error
+	wantRequests int
+}{
+	{"a valid token is reused", issue(3600), func() error {
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		return expectToken("token-1")
+	}, 1},
+	{"a token outside the refresh margin is reused", issue(3600), func() error {
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		expireIn(2 * tokenRefreshMargin)
+		return expectToken("token-1")
+	}, 1},
+	{"a token inside the refresh margin is replaced", issue(3600), func() error {
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		expireIn(tokenRefreshMargin / 2)
+		return expectToken("token-2")
+	}, 2},
+	{"an expired token is replaced", issue(3600), func() error {
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		expireIn(-time.Minute)
+		return expectToken("token-2")
+	}, 2},
+	{"a short-lived token isn't refreshed on every call", issue(30), func() error {
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		return expectToken("token-1")
+	}, 1},
+	{"a token without expires_in is assumed to last an hour", respondWith(http.StatusOK, `{"access_token":"token-1"}`), func() error {
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		if left := time.Until(time.Unix(tokenManager.token.ExpiresAt, 0)); left < 59*time.Minute {
+			return fmt.Errorf("token expires in %s, want an hour", left.Round(time.Second))
+		}
+		return nil
+	}, 1},
+	{"a malformed token body is an error", respondWith(http.StatusOK, `<html>Bad Gateway</html>`), func() error {
+		return expectError("failed to parse response")
+	}, 1},
+	{"a body without access_token is an error", respondWith(http.StatusOK, `{"token_type":"Bearer","expires_in":3600}`), func() error {
+		return expectError("no access_token")
+	}, 1},
+	{"5xx responses are retried", failFirst(2, http.StatusServiceUnavailable), func() error {
+		return expectToken("token-3")
+	}, 3},
+	{"429 responses are retried", failFirst(1, http.StatusTooManyRequests), func() error {
+		return expectToken("token-2")
+	}, 2},
+	{"retries give up after tokenRetries", respondWith(http.StatusBadGateway, `{"error":"down"}`), func() error {
+		return expectError("502 Bad Gateway")
+	}, 3},
+	{"4xx responses aren't retried", respondWith(http.StatusBadRequest, `{"error":"invalid_scope"}`), func() error {
+		return expectError("invalid_scope")
+	}, 1},
+	{"wrong credentials aren't retried", issue(3600), func() error {
+		clientSecret = "wrong"
+		return expectError("invalid_client")
+	}, 1},
+	{"a cached token is only reused for the same audience", issue(3600), func() error {
+		dir, err := os.MkdirTemp("", "gloo-token-cache")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		cache := filepath.Join(dir, "token.json")
+
+		tokenManager = &TokenManager{CacheFile: cache}
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		tokenManager = &TokenManager{CacheFile: cache}
+		if err := expectToken("token-1"); err != nil {
+			return err
+		}
+		audience = "https://other.example.com"
+		tokenManager = &TokenManager{CacheFile: cache}
+		return expectToken("token-2")
+	}, 2},
+	{"concurrent callers share one token request", func(n int, w http.ResponseWriter) {
+		time.Sleep(50 * time.Millisecond)
+		issue(3600)(n, w)
+	}, func() error {
+		errs := make(chan error, 20)
+		var wg sync.WaitGroup
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- expectToken("token-1")
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, 1},
+}
+
+// TestTokenFlow runs the token flow scenarios against a mock OAuth2 server,
+// without touching the real token endpoint or token cache
+func TestTokenFlow(t *testing.T) {
+	defer func(url, id, secret, sc, aud string, tm *TokenManager, delay time.Duration) {
+		tokenURL, clientID, clientSecret, scope, audience = url, id, secret, sc, aud
+		tokenManager, tokenRetryDelay = tm, delay
+	}(tokenURL, clientID, clientSecret, scope, audience, tokenManager, tokenRetryDelay)
+
+	for _, tc := range tokenFlowTests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newMockOAuthServer(tc.respond)
+			defer server.Close()
+			tokenURL, clientID, clientSecret, scope, audience = server.URL, "test-client", "test-secret", "api/access", ""
+			tokenManager, tokenRetryDelay = &TokenManager{}, time.Millisecond
+
+			if err := tc.check(); err != nil {
+				t.Fatal(err)
+			}
+			if got := server.Requests(); got != tc.wantRequests {
+				t.Errorf("made %d token requests, want %d", got, tc.wantRequests)
+			}
+		})
+	}
+}
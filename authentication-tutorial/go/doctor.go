@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// diagnosis is a failed check, with what to do about it
+type diagnosis struct {
+	problem string
+	fix     string
+}
+
+func (d *diagnosis) Error() string {
+	return d.problem
+}
+
+// doctorCheck is one stage of auth doctor. Each stage relies on the ones
+// before it, so the first failure ends the run.
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+// runDoctor checks each stage of authenticating and calling the API, and
+// explains how to fix the first one that fails
+func runDoctor() bool {
+	var token string
+	checks := []doctorCheck{
+		{"Configuration", checkConfig},
+		{"Network", checkNetwork},
+		{"Token", func() (string, error) {
+			var detail string
+			var err error
+			token, detail, err = checkToken()
+			return detail, err
+		}},
+		{"Scopes", func() (string, error) { return checkScopes(token) }},
+		{"API call", func() (string, error) { return checkAPICall(token) }},
+	}
+
+	fmt.Println("=== Gloo AI Auth Doctor ===")
+	fmt.Println()
+	for i, check := range checks {
+		detail, err := check.run()
+		if err == nil {
+			fmt.Printf("✓ %s: %s\n", check.name, detail)
+			continue
+		}
+
+		fmt.Printf("✗ %s: %v\n", check.name, err)
+		if d, ok := err.(*diagnosis); ok && d.fix != "" {
+			fmt.Printf("  → %s\n", d.fix)
+		}
+		for _, skipped := range checks[i+1:] {
+			fmt.Printf("- %s: skipped\n", skipped.name)
+		}
+		return false
+	}
+	fmt.Println()
+	fmt.Println("Everything looks good.")
+	return true
+}
+
+// checkConfig checks the credentials are set and look plausible
+func checkConfig() (string, error) {
+	setFix := "Set GLOO_CLIENT_ID and GLOO_CLIENT_SECRET in a .env file in this directory, or export them. Get them from API Credentials in Gloo AI Studio (https://studio.ai.gloo.com/)."
+	for _, v := range []struct{ name, value, placeholder string }{
+		{"GLOO_CLIENT_ID", clientID, "YOUR_CLIENT_ID"},
+		{"GLOO_CLIENT_SECRET", clientSecret, "YOUR_CLIENT_SECRET"},
+	} {
+		switch {
+		case v.value == "" || v.value == v.placeholder:
+			return "", &diagnosis{v.name + " is not set", setFix}
+		case strings.TrimSpace(v.value) != v.value:
+			return "", &diagnosis{v.name + " has leading or trailing whitespace", "Remove the whitespace; it usually comes from copying the value."}
+		case strings.Trim(v.value, `"'`) != v.value:
+			return "", &diagnosis{v.name + " is wrapped in quotes", "Remove the quotes around the value."}
+		}
+	}
+
+	source := "environment"
+	if _, err := os.Stat(".env"); err == nil {
+		source = ".env file and environment"
+	}
+	detail := fmt.Sprintf("client ID %s from %s, requesting scope %s", clientID, source, scope)
+	if proxy := getEnv("HTTPS_PROXY", getEnv("https_proxy", "")); proxy != "" {
+		detail += ", via proxy " + proxy
+	}
+	return detail, nil
+}
+
+// checkNetwork resolves the token endpoint's host and opens a TCP
+// connection to it. Behind a proxy only the proxy is reachable directly,
+// so the check is left to the token request.
+func checkNetwork() (string, error) {
+	u, err := url.Parse(tokenURL)
+	if err != nil {
+		return "", &diagnosis{"token URL is invalid: " + err.Error(), ""}
+	}
+	if proxy, _ := http.ProxyFromEnvironment(&http.Request{URL: u}); proxy != nil {
+		return "requests go through a proxy; skipping direct checks", nil
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		return "", &diagnosis{"can't resolve " + host + ": " + err.Error(),
+			"Check your internet connection and DNS settings. On a corporate network you may need to set HTTPS_PROXY."}
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), 10*time.Second)
+	if err != nil {
+		return "", &diagnosis{fmt.Sprintf("can't connect to %s port %s: %v", host, port, err),
+			"A firewall may be blocking outbound HTTPS. Allow connections to " + host + " on port " + port + ", or set HTTPS_PROXY."}
+	}
+	conn.Close()
+	return fmt.Sprintf("%s resolves to %s, connected in %s", host, strings.Join(addrs, ", "), time.Since(start).Round(time.Millisecond)), nil
+}
+
+// checkToken requests a new token, bypassing the token cache
+func checkToken() (string, string, error) {
+	token, err := getAccessToken()
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "invalid_client") || strings.Contains(msg, "401"):
+			return "", "", &diagnosis{msg, "The client ID or secret is wrong, or the secret was rotated. Copy both again from API Credentials in Gloo AI Studio."}
+		case strings.Contains(msg, "invalid_scope"):
+			return "", "", &diagnosis{msg, "This client can't request the scope " + scope + ". Set GLOO_SCOPE to the scopes it was granted, or check its settings in Gloo AI Studio."}
+		case strings.Contains(msg, "failed to make request"):
+			return "", "", &diagnosis{msg, "The token endpoint couldn't be reached. Check your network or proxy settings."}
+		}
+		return "", "", &diagnosis{msg, "The token endpoint returned an unexpected error. Try again shortly."}
+	}
+	detail := fmt.Sprintf("%s token, expires at %s", token.TokenType, time.Unix(token.ExpiresAt, 0).Format(time.RFC3339))
+	if token.ClockSkew != 0 {
+		detail += fmt.Sprintf(" (server clock is %s ahead of this machine)", token.ClockSkew)
+	}
+	return token.AccessToken, detail, nil
+}
+
+// checkScopes checks the token was granted the required scopes
+func checkScopes(token string) (string, error) {
+	jwt, err := parseJWT(token)
+	if err != nil {
+		return "token is opaque, so its scopes can't be checked", nil
+	}
+	if err := requireScopes(jwt.Claims, requiredScopes()); err != nil {
+		return "", &diagnosis{err.Error(), "Enable the missing scopes for this client in Gloo AI Studio, or set GLOO_REQUIRED_SCOPES if they aren't needed."}
+	}
+	return "granted " + jwt.Claims.Scope, nil
+}
+
+// checkAPICall makes a minimal authenticated API call with the new token
+func checkAPICall(token string) (string, error) {
+	reqBody, err := json.Marshal(ChatCompletionRequest{
+		AutoRouting: true,
+		Messages:    []ChatMessage{{Role: "user", Content: "Reply with OK."}},
+	})
+	if err != nil {
+		return "", &diagnosis{"failed to marshal payload: " + err.Error(), ""}
+	}
+
+	start := time.Now()
+	resp, body, err := postJSON(apiURL, token, reqBody)
+	if err != nil {
+		return "", &diagnosis{err.Error(), "The API couldn't be reached even though the token endpoint could. Check that your network allows " + apiURL + "."}
+	}
+	problem := fmt.Sprintf("%s - %s", resp.Status, strings.TrimSpace(string(body)))
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return fmt.Sprintf("%s answered in %s", apiURL, time.Since(start).Round(time.Millisecond)), nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return "", &diagnosis{problem, "The API rejected a token that was just issued. Check that tokenURL and apiURL point at the same platform."}
+	case resp.StatusCode == http.StatusForbidden:
+		return "", &diagnosis{problem, "The client isn't allowed to use this API. Check its access in Gloo AI Studio."}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "", &diagnosis{problem, "Authentication works, but the client is rate limited. Wait and try again."}
+	case resp.StatusCode >= 500:
+		return "", &diagnosis{problem, "Authentication works, but the API had an error. Try again shortly."}
+	}
+	return "", &diagnosis{problem, ""}
+}
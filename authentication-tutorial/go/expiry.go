@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry a token is refreshed.
+// TOKEN_REFRESH_MARGIN overrides it, as a duration ("90s", "2m") or a
+// number of seconds.
+var tokenRefreshMargin = 60 * time.Second
+
+func init() {
+	v := os.Getenv("TOKEN_REFRESH_MARGIN")
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		tokenRefreshMargin = d
+	} else if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		tokenRefreshMargin = time.Duration(secs) * time.Second
+	}
+}
+
+// clockSkew estimates how far the server's clock is ahead of ours from a
+// response's Date header, measured against the middle of the request. Date
+// only has one-second resolution, so smaller differences count as none.
+func clockSkew(resp *http.Response, sent, received time.Time) time.Duration {
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := serverTime.Sub(local.Truncate(time.Second))
+	if skew > -time.Second && skew < time.Second {
+		return 0
+	}
+	return skew
+}
+
+// jwtExpiry reads the exp claim of a JWT access token. The signature isn't
+// checked: the value is only used to schedule a refresh.
+func jwtExpiry(token string) (time.Time, bool) {
+	jwt, err := parseJWT(token)
+	if err != nil || jwt.Claims.ExpiresAt == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(jwt.Claims.ExpiresAt, 0), true
+}
+
+// defaultTokenLifetime is assumed when a token response has neither
+// expires_in nor a JWT exp claim
+const defaultTokenLifetime = time.Hour
+
+// setExpiry works out when token expires by our clock. expires_in is
+// counted from when the request was sent, which errs on the early side.
+// A JWT's exp claim is in server time, so it is shifted by the clock skew
+// first. When both are available the earlier wins.
+func setExpiry(token *TokenInfo, sent time.Time, skew time.Duration) {
+	token.IssuedAt = sent.Unix()
+	token.ClockSkew = skew
+
+	expiresAt := sent.Add(time.Duration(token.ExpiresIn) * time.Second)
+	exp, hasExp := jwtExpiry(token.AccessToken)
+	switch {
+	case hasExp && (token.ExpiresIn <= 0 || exp.Add(-skew).Before(expiresAt)):
+		expiresAt = exp.Add(-skew)
+	case !hasExp && token.ExpiresIn <= 0:
+		expiresAt = sent.Add(defaultTokenLifetime)
+	}
+	token.ExpiresAt = expiresAt.Unix()
+}
+
+// refreshMargin is the safety margin for a token: tokenRefreshMargin, but
+// no more than half the token's lifetime, so short-lived tokens aren't
+// refreshed on every call
+func refreshMargin(token *TokenInfo) time.Duration {
+	margin := tokenRefreshMargin
+	if token.IssuedAt > 0 {
+		if half := time.Duration(token.ExpiresAt-token.IssuedAt) * time.Second / 2; margin > half {
+			margin = half
+		}
+	}
+	return margin
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TokenClaims are the access token claims this tutorial looks at
+type TokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope"`
+	TokenUse  string `json:"token_use"`
+	ExpiresAt int64  `json:"exp"`
+	IssuedAt  int64  `json:"iat"`
+}
+
+// Scopes returns the granted scopes, which the scope claim lists
+// space-separated
+func (c TokenClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// parsedJWT is a JWT split into its parts
+type parsedJWT struct {
+	Header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	Claims       TokenClaims
+	signingInput string
+	signature    []byte
+}
+
+// parseJWT decodes a JWT without checking its signature
+func parseJWT(token string) (*parsedJWT, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("access token is not a JWT")
+	}
+	var jwt parsedJWT
+	for i, target := range []interface{}{&jwt.Header, &jwt.Claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode JWT: %w", err)
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			return nil, fmt.Errorf("failed to parse JWT: %w", err)
+		}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT signature: %w", err)
+	}
+	jwt.signingInput = parts[0] + "." + parts[1]
+	jwt.signature = signature
+	return &jwt, nil
+}
+
+// expectedIssuer returns GLOO_ISSUER, or the origin of tokenURL, the
+// platform that issues our tokens
+func expectedIssuer() (string, error) {
+	if issuer := getEnv("GLOO_ISSUER", ""); issuer != "" {
+		return strings.TrimSuffix(issuer, "/"), nil
+	}
+	u, err := url.Parse(tokenURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("can't find the issuer from token URL %q; set GLOO_ISSUER", tokenURL)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// checkIssuer fails unless the token was issued by the expected issuer.
+// The claims aren't verified yet, so this must pass before they're used
+// to find anything, or a forged token could name its own signing keys.
+func checkIssuer(claims TokenClaims, issuer string) error {
+	if strings.TrimSuffix(claims.Issuer, "/") != issuer {
+		return fmt.Errorf("token was issued by %q, not %q; set GLOO_ISSUER if the platform uses another issuer", claims.Issuer, issuer)
+	}
+	return nil
+}
+
+// jwksURL returns GLOO_JWKS_URL, or the expected issuer's standard JWKS
+// location. It never comes from the token itself.
+func jwksURL(issuer string) string {
+	return getEnv("GLOO_JWKS_URL", issuer+"/.well-known/jwks.json")
+}
+
+// fetchJWKS downloads the RSA signing keys published at url, by key ID
+func fetchJWKS(url string) (map[string]*rsa.PublicKey, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing keys: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signing keys from %s: %s", url, resp.Status)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse signing keys: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			return nil, fmt.Errorf("signing key %q is malformed", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// verifySignature checks the token's RS256, RS384, or RS512 signature
+// against the published signing keys
+func (jwt *parsedJWT) verifySignature(keys map[string]*rsa.PublicKey) error {
+	key, ok := keys[jwt.Header.Kid]
+	if !ok {
+		return fmt.Errorf("token was signed with unknown key %q", jwt.Header.Kid)
+	}
+
+	var hash crypto.Hash
+	var digest []byte
+	switch jwt.Header.Alg {
+	case "RS256":
+		sum := sha256.Sum256([]byte(jwt.signingInput))
+		hash, digest = crypto.SHA256, sum[:]
+	case "RS384":
+		sum := sha512.Sum384([]byte(jwt.signingInput))
+		hash, digest = crypto.SHA384, sum[:]
+	case "RS512":
+		sum := sha512.Sum512([]byte(jwt.signingInput))
+		hash, digest = crypto.SHA512, sum[:]
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", jwt.Header.Alg)
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, jwt.signature); err != nil {
+		return fmt.Errorf("token signature is invalid")
+	}
+	return nil
+}
+
+// requireScopes fails with the missing scopes if the token wasn't granted
+// all of required
+func requireScopes(claims TokenClaims, required []string) error {
+	granted := map[string]bool{}
+	for _, s := range claims.Scopes() {
+		granted[s] = true
+	}
+	var missing []string
+	for _, s := range required {
+		if !granted[s] {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("access token is missing required scope(s) %s (granted: %q); check GLOO_SCOPE and the scopes enabled for this client in Gloo AI Studio",
+		strings.Join(missing, ", "), claims.Scope)
+}
+
+// requiredScopes returns GLOO_REQUIRED_SCOPES (space- or comma-separated),
+// by default the scopes that were requested
+func requiredScopes() []string {
+	return strings.FieldsFunc(getEnv("GLOO_REQUIRED_SCOPES", scope), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+// inspectToken shows the token's claims, verifies its signature, and
+// checks its scopes
+func inspectToken(token string) error {
+	jwt, err := parseJWT(token)
+	if err != nil {
+		return err
+	}
+
+	c := jwt.Claims
+	fmt.Printf("   Client ID:  %s\n", c.ClientID)
+	fmt.Printf("   Scopes:     %s\n", c.Scope)
+	if c.ExpiresAt != 0 {
+		exp := time.Unix(c.ExpiresAt, 0)
+		fmt.Printf("   Expires:    %s (in %s, by this machine's clock)\n", exp.Format(time.RFC3339), time.Until(exp).Round(time.Second))
+	}
+	fmt.Printf("   Issuer:     %s\n", c.Issuer)
+	fmt.Printf("   Algorithm:  %s (key %s)\n", jwt.Header.Alg, jwt.Header.Kid)
+
+	issuer, err := expectedIssuer()
+	if err != nil {
+		return err
+	}
+	if err := checkIssuer(c, issuer); err != nil {
+		return err
+	}
+	url := jwksURL(issuer)
+	keys, err := fetchJWKS(url)
+	if err != nil {
+		return err
+	}
+	if err := jwt.verifySignature(keys); err != nil {
+		return err
+	}
+	fmt.Println("   ✓ Signature verified against", url)
+
+	required := requiredScopes()
+	if err := requireScopes(c, required); err != nil {
+		return err
+	}
+	fmt.Println("   ✓ Required scopes granted:", strings.Join(required, ", "))
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -19,6 +20,8 @@ var (
 	clientSecret string
 	tokenURL     = "https://platform.ai.gloo.com/oauth2/token"
 	apiURL       = "https://platform.ai.gloo.com/ai/v2/chat/completions"
+	scope        = "api/access" // space-separated; GLOO_SCOPE overrides it
+	audience     string         // sent only if set, from GLOO_AUDIENCE
 )
 
 // TokenInfo represents the OAuth2 token response
@@ -27,6 +30,9 @@ type TokenInfo struct {
 	ExpiresIn   int    `json:"expires_in"`
 	ExpiresAt   int64  `json:"expires_at"`
 	TokenType   string `json:"token_type"`
+
+	IssuedAt  int64         `json:"-"` // when the token was requested, by our clock
+	ClockSkew time.Duration `json:"-"` // server clock minus ours, from the Date header
 }
 
 // ChatMessage represents a chat message
@@ -51,9 +57,6 @@ type ChatCompletionResponse struct {
 	} `json:"choices"`
 }
 
-// Global token storage
-var tokenInfo *TokenInfo
-
 // getEnv returns environment variable or default value
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -62,64 +65,92 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// Token requests that fail with a network error, a 429, or a 5xx response
+// are retried, waiting tokenRetryDelay and then twice as long each time
+var (
+	tokenRetries    = 2
+	tokenRetryDelay = 500 * time.Millisecond
+)
+
 // getAccessToken retrieves a new access token from the Gloo AI API
 func getAccessToken() (*TokenInfo, error) {
-	data := strings.NewReader("grant_type=client_credentials&scope=api/access")
-	req, err := http.NewRequest("POST", tokenURL, data)
+	var lastErr error
+	for attempt := 0; attempt <= tokenRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(tokenRetryDelay << (attempt - 1))
+		}
+		token, retryable, err := requestAccessToken()
+		if err == nil || !retryable {
+			return token, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// requestAccessToken makes one token request, and reports whether a failure
+// is worth retrying
+func requestAccessToken() (*TokenInfo, bool, error) {
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {scope}}
+	if audience != "" {
+		form.Set("audience", audience)
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.SetBasicAuth(clientID, clientSecret)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	client := &http.Client{Timeout: 30 * time.Second}
+	sent := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, true, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
+	skew := clockSkew(resp, sent, time.Now())
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, true, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get token: %s - %s", resp.Status, string(body))
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retryable, fmt.Errorf("failed to get token: %s - %s", resp.Status, string(body))
 	}
 
 	var token TokenInfo
 	if err := json.Unmarshal(body, &token); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, false, fmt.Errorf("failed to parse response: no access_token in %s", string(body))
 	}
 
-	token.ExpiresAt = time.Now().Unix() + int64(token.ExpiresIn)
-	return &token, nil
+	setExpiry(&token, sent, skew)
+	return &token, false, nil
 }
 
-// isTokenExpired checks if the token is expired or close to expiring
+// isTokenExpired checks if the token is expired or within the refresh
+// margin of expiring
 func isTokenExpired(token *TokenInfo) bool {
 	if token == nil || token.ExpiresAt == 0 {
 		return true
 	}
-	return time.Now().Unix() > (token.ExpiresAt - 60)
+	return !time.Now().Add(refreshMargin(token)).Before(time.Unix(token.ExpiresAt, 0))
 }
 
 // ensureValidToken ensures we have a valid access token
 func ensureValidToken() (string, error) {
-	if isTokenExpired(tokenInfo) {
-		fmt.Println("Getting new access token...")
-		var err error
-		tokenInfo, err = getAccessToken()
-		if err != nil {
-			return "", fmt.Errorf("failed to get access token: %w", err)
-		}
-	}
-	return tokenInfo.AccessToken, nil
+	return tokenManager.EnsureValidToken()
 }
 
-// makeAuthenticatedRequest makes an authenticated API request
+// makeAuthenticatedRequest makes an authenticated API request. If the
+// token is rejected as expired or invalid, it is refreshed and the request
+// sent once more.
 func makeAuthenticatedRequest(endpoint string, payload interface{}) (*ChatCompletionResponse, error) {
 	token, err := ensureValidToken()
 	if err != nil {
@@ -134,9 +165,38 @@ func makeAuthenticatedRequest(endpoint string, payload interface{}) (*ChatComple
 		}
 	}
 
+	resp, body, err := postJSON(endpoint, token, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if tokenRejected(resp, body) {
+		fmt.Println("Access token was rejected, refreshing it and retrying...")
+		refreshed, err := tokenManager.ForceRefresh()
+		if err != nil {
+			return nil, err
+		}
+		if resp, body, err = postJSON(endpoint, refreshed.AccessToken, reqBody); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API call failed: %s - %s", resp.Status, string(body))
+	}
+
+	var response ChatCompletionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// postJSON sends an authenticated JSON POST and reads the whole response
+func postJSON(endpoint, token string, reqBody []byte) (*http.Response, []byte, error) {
 	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Add("Authorization", "Bearer "+token)
@@ -145,30 +205,36 @@ func makeAuthenticatedRequest(endpoint string, payload interface{}) (*ChatComple
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API call failed: %s - %s", resp.Status, string(body))
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	return resp, body, nil
+}
 
-	var response ChatCompletionResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// tokenRejected reports whether a response rejects the access token itself,
+// which a new token fixes: any 401, or a 403 that says the token is expired
+// or invalid. Other 403s mean the client lacks access, and are returned as
+// they are.
+func tokenRejected(resp *http.Response, body []byte) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return true
+	case http.StatusForbidden:
+		reason := strings.ToLower(resp.Header.Get("WWW-Authenticate") + " " + string(body))
+		return strings.Contains(reason, "invalid_token") || strings.Contains(reason, "expired")
 	}
-
-	return &response, nil
+	return false
 }
 
 // testAuthentication tests the authentication implementation
 func testAuthentication() bool {
-	fmt.Println("=== Gloo AI Authentication Test ===\n")
+	fmt.Println("=== Gloo AI Authentication Test ===")
+	fmt.Println()
 
 	// Test 1: Token retrieval
 	fmt.Println("1. Testing token retrieval...")
@@ -180,20 +246,34 @@ func testAuthentication() bool {
 
 	fmt.Println("   ✓ Token retrieved successfully")
 	fmt.Printf("   Token type: %s\n", tokenInfo.TokenType)
-	fmt.Printf("   Expires in: %d seconds\n\n", tokenInfo.ExpiresIn)
+	fmt.Printf("   Expires in: %d seconds\n", tokenInfo.ExpiresIn)
+	fmt.Printf("   Expires at: %s (refreshed %s before)\n", time.Unix(tokenInfo.ExpiresAt, 0).Format(time.RFC3339), refreshMargin(tokenInfo))
+	if tokenInfo.ClockSkew != 0 {
+		fmt.Printf("   Clock skew: server is %s ahead of this machine\n", tokenInfo.ClockSkew)
+	}
+	fmt.Println()
 
-	// Test 2: Token validation
-	fmt.Println("2. Testing token validation...")
+	// Test 2: Token inspection
+	fmt.Println("2. Inspecting the access token...")
+	if err := inspectToken(tokenInfo.AccessToken); err != nil {
+		fmt.Printf("   ✗ Token inspection failed: %v\n", err)
+		return false
+	}
+	fmt.Println()
+
+	// Test 3: Token validation
+	fmt.Println("3. Testing token validation...")
 	token, err := ensureValidToken()
 	if err != nil {
 		fmt.Printf("   ✗ Token validation failed: %v\n", err)
 		return false
 	}
 	_ = token // Use the token variable
-	fmt.Println("   ✓ Token validation successful\n")
+	fmt.Println("   ✓ Token validation successful")
+	fmt.Println()
 
-	// Test 3: API call with authentication
-	fmt.Println("3. Testing authenticated API call...")
+	// Test 4: API call with authentication
+	fmt.Println("4. Testing authenticated API call...")
 	request := ChatCompletionRequest{
 		AutoRouting: true,
 		Messages: []ChatMessage{
@@ -229,8 +309,13 @@ func main() {
 	// Set configuration
 	clientID = getEnv("GLOO_CLIENT_ID", "YOUR_CLIENT_ID")
 	clientSecret = getEnv("GLOO_CLIENT_SECRET", "YOUR_CLIENT_SECRET")
+	scope = getEnv("GLOO_SCOPE", scope)
+	audience = getEnv("GLOO_AUDIENCE", "")
+
+	// auth doctor explains missing credentials itself
+	noCredentials := len(os.Args) > 2 && os.Args[1] == "auth" && os.Args[2] == "doctor"
 
-	if clientID == "YOUR_CLIENT_ID" || clientSecret == "YOUR_CLIENT_SECRET" {
+	if !noCredentials && (clientID == "YOUR_CLIENT_ID" || clientSecret == "YOUR_CLIENT_SECRET") {
 		fmt.Println("Please set your GLOO_CLIENT_ID and GLOO_CLIENT_SECRET environment variables")
 		fmt.Println("You can create a .env file with:")
 		fmt.Println("GLOO_CLIENT_ID=your_client_id")
@@ -238,5 +323,28 @@ func main() {
 		return
 	}
 
+	tokenManager.CacheFile = defaultTokenCache()
+
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		if err := runAuth(os.Args[2:]); err != nil {
+			fmt.Printf("✗ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		token, err := ensureValidToken()
+		if err != nil {
+			fmt.Printf("✗ %v\n", err)
+			os.Exit(1)
+		}
+		if err := inspectToken(token); err != nil {
+			fmt.Printf("   ✗ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	testAuthentication()
-}
\ No newline at end of file
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenManager holds the current access token. With a CacheFile the token is
+// also kept on disk, so separate runs (and the auth subcommands) share it
+// instead of each requesting their own. It is safe for concurrent use.
+type TokenManager struct {
+	CacheFile string
+
+	mu     sync.Mutex
+	token  *TokenInfo
+	loaded bool
+}
+
+// tokenManager is the token source used for every request
+var tokenManager = &TokenManager{}
+
+// cachedToken is the on-disk form of a token. It records the client, scope,
+// and audience the token was issued for, so a cache isn't reused after
+// switching any of them.
+type cachedToken struct {
+	ClientID    string `json:"client_id"`
+	Scope       string `json:"scope"`
+	Audience    string `json:"audience,omitempty"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	ExpiresAt   int64  `json:"expires_at"`
+	IssuedAt    int64  `json:"issued_at"`
+	ClockSkewMs int64  `json:"clock_skew_ms"`
+}
+
+// defaultTokenCache returns GLOO_TOKEN_CACHE, or a file in the user's cache
+// directory. "off" disables the cache.
+func defaultTokenCache() string {
+	if path := getEnv("GLOO_TOKEN_CACHE", ""); path != "" {
+		if path == "off" {
+			return ""
+		}
+		return path
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gloo-ai", "token.json")
+}
+
+// EnsureValidToken returns the current access token, fetching a new one
+// when there is none or it is about to expire
+func (tm *TokenManager) EnsureValidToken() (string, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.load()
+	if isTokenExpired(tm.token) {
+		fmt.Println("Getting new access token...")
+		if err := tm.refresh(); err != nil {
+			return "", fmt.Errorf("failed to get access token: %w", err)
+		}
+	}
+	return tm.token.AccessToken, nil
+}
+
+// ForceRefresh fetches a new access token even if the current one is still
+// valid, and replaces it everywhere it is cached
+func (tm *TokenManager) ForceRefresh() (*TokenInfo, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.loaded = true
+	if err := tm.refresh(); err != nil {
+		return nil, fmt.Errorf("failed to refresh access token: %w", err)
+	}
+	token := *tm.token
+	return &token, nil
+}
+
+// Revoke asks the server to revoke token, or the cached token when token is
+// empty. A revoked cached token is dropped so the next request fetches a new
+// one.
+func (tm *TokenManager) Revoke(token string) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.load()
+	if token == "" {
+		if tm.token == nil {
+			return fmt.Errorf("no cached access token to revoke")
+		}
+		token = tm.token.AccessToken
+	}
+	if err := revokeToken(token); err != nil {
+		return err
+	}
+	if tm.token != nil && tm.token.AccessToken == token {
+		tm.token = nil
+		tm.save()
+	}
+	return nil
+}
+
+// refresh replaces the current token with a new one; tm.mu must be held
+func (tm *TokenManager) refresh() error {
+	token, err := getAccessToken()
+	if err != nil {
+		return err
+	}
+	tm.token = token
+	tm.save()
+	return nil
+}
+
+// load reads the cache file the first time the token is needed; tm.mu must
+// be held. An unreadable cache is ignored and a new token fetched.
+func (tm *TokenManager) load() {
+	if tm.loaded {
+		return
+	}
+	tm.loaded = true
+	if tm.CacheFile == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(tm.CacheFile)
+	if err != nil {
+		return
+	}
+	var cached cachedToken
+	if err := json.Unmarshal(data, &cached); err != nil || cached.ClientID != clientID || cached.Scope != scope || cached.Audience != audience || cached.AccessToken == "" {
+		return
+	}
+	tm.token = &TokenInfo{
+		AccessToken: cached.AccessToken,
+		TokenType:   cached.TokenType,
+		ExpiresIn:   cached.ExpiresIn,
+		ExpiresAt:   cached.ExpiresAt,
+		IssuedAt:    cached.IssuedAt,
+		ClockSkew:   time.Duration(cached.ClockSkewMs) * time.Millisecond,
+	}
+}
+
+// save writes the current token to the cache file, readable only by this
+// user, or removes the file when there is no token; tm.mu must be held.
+// Failing to cache isn't fatal, so errors are only reported.
+func (tm *TokenManager) save() {
+	if tm.CacheFile == "" {
+		return
+	}
+	if tm.token == nil {
+		if err := os.Remove(tm.CacheFile); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: failed to clear token cache: %v\n", err)
+		}
+		return
+	}
+	data, err := json.MarshalIndent(cachedToken{
+		ClientID:    clientID,
+		Scope:       scope,
+		Audience:    audience,
+		AccessToken: tm.token.AccessToken,
+		TokenType:   tm.token.TokenType,
+		ExpiresIn:   tm.token.ExpiresIn,
+		ExpiresAt:   tm.token.ExpiresAt,
+		IssuedAt:    tm.token.IssuedAt,
+		ClockSkewMs: tm.token.ClockSkew.Milliseconds(),
+	}, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(tm.CacheFile), 0700)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(tm.CacheFile, data, 0600)
+	}
+	if err == nil {
+		// WriteFile only sets the mode of a new file, so tighten a cache
+		// left readable by an earlier version
+		err = os.Chmod(tm.CacheFile, 0600)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to cache access token: %v\n", err)
+	}
+}
+
+// revokeURL returns GLOO_REVOKE_URL, or the revocation endpoint next to the
+// token endpoint
+func revokeURL() string {
+	return getEnv("GLOO_REVOKE_URL", strings.TrimSuffix(tokenURL, "/token")+"/revoke")
+}
+
+// revokeToken revokes an access token with an RFC 7009 revocation request
+func revokeToken(token string) error {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest("POST", revokeURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(clientID, clientSecret)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if strings.Contains(string(body), "unsupported_token_type") {
+			return fmt.Errorf("the server doesn't revoke access tokens, so this one stays valid until it expires; rotate the client secret in Gloo AI Studio to lock out a leaked token sooner")
+		}
+		return fmt.Errorf("failed to revoke token: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// runAuth runs the auth subcommands: refresh fetches a new token even if the
+// cached one is still valid, revoke revokes the cached token or the one
+// given, and doctor diagnoses setup problems
+func runAuth(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: auth refresh | auth revoke [token] | auth doctor")
+	}
+	switch args[0] {
+	case "doctor":
+		if !runDoctor() {
+			return fmt.Errorf("auth doctor found a problem")
+		}
+	case "refresh":
+		token, err := tokenManager.ForceRefresh()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ New access token expires at %s\n", time.Unix(token.ExpiresAt, 0).Format(time.RFC3339))
+		if tokenManager.CacheFile != "" {
+			fmt.Println("  Cached in", tokenManager.CacheFile)
+		}
+	case "revoke":
+		var token string
+		if len(args) > 1 {
+			token = args[1]
+		}
+		if err := tokenManager.Revoke(token); err != nil {
+			return err
+		}
+		fmt.Println("✓ Access token revoked")
+	default:
+		return fmt.Errorf("unknown auth command %q (expected refresh, revoke, or doctor)", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	// modernc.org/sqlite is pure Go, so no C compiler is needed; it
+	// registers itself as the "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// UserChat maps an application's user to their Gloo AI conversation
+type UserChat struct {
+	UserID    string    `json:"user_id"`
+	ChatID    string    `json:"chat_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  int       `json:"messages"`
+}
+
+// ChatDirectory stores which chat belongs to each user of the proxy
+type ChatDirectory interface {
+	// Lookup returns the user's chat, or nil if they don't have one yet
+	Lookup(userID string) (*UserChat, error)
+	// Record notes a message in the user's chat, creating the mapping on
+	// the first one
+	Record(userID, chatID string) error
+	// Forget removes the user's mapping so their next message starts a new
+	// chat
+	Forget(userID string) error
+	Close() error
+}
+
+// sqliteDriver is the database/sql driver modernc.org/sqlite registers
+const sqliteDriver = "sqlite"
+
+// sqlDirectory keeps the mapping in a SQLite database
+type sqlDirectory struct {
+	db *sql.DB
+}
+
+func openSQLDirectory(path string) (*sqlDirectory, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS user_chats (
+		user_id    TEXT PRIMARY KEY,
+		chat_id    TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		messages   INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create user_chats table: %w", err)
+	}
+	return &sqlDirectory{db: db}, nil
+}
+
+func (d *sqlDirectory) Lookup(userID string) (*UserChat, error) {
+	var c UserChat
+	err := d.db.QueryRow(
+		`SELECT user_id, chat_id, created_at, updated_at, messages FROM user_chats WHERE user_id = ?`,
+		userID,
+	).Scan(&c.UserID, &c.ChatID, &c.CreatedAt, &c.UpdatedAt, &c.Messages)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (d *sqlDirectory) Record(userID, chatID string) error {
+	now := time.Now().UTC()
+	_, err := d.db.Exec(`INSERT INTO user_chats (user_id, chat_id, created_at, updated_at, messages)
+		VALUES (?, ?, ?, ?, 2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			chat_id = excluded.chat_id,
+			updated_at = excluded.updated_at,
+			messages = user_chats.messages + 2`,
+		userID, chatID, now, now)
+	return err
+}
+
+func (d *sqlDirectory) Forget(userID string) error {
+	_, err := d.db.Exec(`DELETE FROM user_chats WHERE user_id = ?`, userID)
+	return err
+}
+
+func (d *sqlDirectory) Close() error {
+	return d.db.Close()
+}
+
+// jsonDirectory keeps the mapping in a JSON file, for deployments that
+// would rather not keep a database. It suits a single proxy process only.
+type jsonDirectory struct {
+	path  string
+	mu    sync.Mutex
+	chats map[string]UserChat
+}
+
+func openJSONDirectory(path string) (*jsonDirectory, error) {
+	d := &jsonDirectory{path: path, chats: map[string]UserChat{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.chats); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return d, nil
+}
+
+func (d *jsonDirectory) Lookup(userID string) (*UserChat, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.chats[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (d *jsonDirectory) Record(userID, chatID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now().UTC()
+	c, ok := d.chats[userID]
+	if !ok {
+		c = UserChat{UserID: userID, CreatedAt: now}
+	}
+	c.ChatID = chatID
+	c.UpdatedAt = now
+	c.Messages += 2 // the message and its reply
+	d.chats[userID] = c
+	return d.save()
+}
+
+func (d *jsonDirectory) Forget(userID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.chats, userID)
+	return d.save()
+}
+
+func (d *jsonDirectory) Close() error {
+	return nil
+}
+
+// save writes the file; the caller holds d.mu
+func (d *jsonDirectory) save() error {
+	data, err := json.MarshalIndent(d.chats, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(d.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	// Write to a temporary file first so a crash never leaves a partial file
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Transcript is a chat's history prepared for export
+type Transcript struct {
+	Name      string        `json:"name,omitempty"`
+	ChatID    string        `json:"chat_id"`
+	CreatedAt string        `json:"created_at"`
+	Messages  []ChatMessage `json:"messages"`
+}
+
+// exportFormats maps each export format to its renderer
+var exportFormats = map[string]func(io.Writer, []Transcript) error{
+	"md":   writeMarkdown,
+	"json": writeJSON,
+	"html": writeHTML,
+}
+
+// fetchTranscript loads a chat's history, or only the messages sent since
+// a time if since isn't zero
+func fetchTranscript(name, chatID string, since time.Time) (Transcript, error) {
+	history, err := getChatHistoryPage(chatID, HistoryQuery{Since: since})
+	if err != nil {
+		return Transcript{}, err
+	}
+	return Transcript{
+		Name:      name,
+		ChatID:    chatID,
+		CreatedAt: history.CreatedAt,
+		Messages:  history.Messages,
+	}, nil
+}
+
+// sourceLabel describes one source for export, with its URL if it has one
+func sourceLabel(source Source) string {
+	if source.URL != "" {
+		return fmt.Sprintf("%s (%s)", source.Label(), source.URL)
+	}
+	return source.Label()
+}
+
+// capitalize upper-cases the first letter of a role name
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func transcriptTitle(t Transcript) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return "Chat " + t.ChatID
+}
+
+func writeMarkdown(w io.Writer, transcripts []Transcript) error {
+	for i, t := range transcripts {
+		if i > 0 {
+			fmt.Fprint(w, "\n---\n\n")
+		}
+		fmt.Fprintf(w, "# %s\n\n", transcriptTitle(t))
+		fmt.Fprintf(w, "- Chat ID: `%s`\n", t.ChatID)
+		fmt.Fprintf(w, "- Created: %s\n", formatTimestamp(t.CreatedAt))
+		fmt.Fprintf(w, "- Messages: %d\n", len(t.Messages))
+
+		for _, m := range t.Messages {
+			fmt.Fprintf(w, "\n## %s", capitalize(m.Role))
+			if m.Timestamp != "" {
+				fmt.Fprintf(w, " · %s", formatTimestamp(m.Timestamp))
+			}
+			fmt.Fprintf(w, "\n\n%s\n", strings.TrimSpace(m.Message))
+			if len(m.Sources) > 0 {
+				fmt.Fprint(w, "\n**Sources:**\n\n")
+				for _, s := range m.Sources {
+					fmt.Fprintf(w, "- %s\n", sourceLabel(s))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, transcripts []Transcript) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if len(transcripts) == 1 {
+		return enc.Encode(transcripts[0])
+	}
+	return enc.Encode(transcripts)
+}
+
+var htmlTranscript = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"title":  transcriptTitle,
+	"when":   formatTimestamp,
+	"source": sourceLabel,
+	"role":   capitalize,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Gloo AI Chat Transcript</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.meta { color: #666; font-size: 0.9rem; }
+.message { border-radius: 0.5rem; padding: 0.75rem 1rem; margin: 1rem 0; white-space: pre-wrap; }
+.user { background: #eef4ff; }
+.assistant { background: #f4f4f4; }
+.sources { font-size: 0.9rem; white-space: normal; }
+</style>
+</head>
+<body>
+{{range .}}
+<section>
+<h1>{{title .}}</h1>
+<p class="meta">Chat ID {{.ChatID}} · Created {{when .CreatedAt}} · {{len .Messages}} messages</p>
+{{range .Messages}}
+<div class="message {{.Role}}">
+<p class="meta"><strong>{{role .Role}}</strong>{{if .Timestamp}} · {{when .Timestamp}}{{end}}</p>
+{{.Message}}
+{{if .Sources}}<div class="sources"><strong>Sources:</strong><ul>{{range .Sources}}<li>{{source .}}</li>{{end}}</ul></div>{{end}}
+</div>
+{{end}}
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, transcripts []Transcript) error {
+	return htmlTranscript.Execute(w, transcripts)
+}
+
+// runExport handles the export command:
+//
+//	export <name-or-chat-id> [-format md|json|html] [-o file] [-since when]
+//	export -all-sessions [-format md|json|html] [-o file] [-since when]
+func runExport(store *SessionStore, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "md", "output format: md, json, or html")
+	out := flags.String("o", "", "file to write (default stdout)")
+	all := flags.Bool("all-sessions", false, "export every saved chat")
+	sinceFlag := flags.String("since", "", "only messages since a date or age (2024-06-01, 24h, 7d)")
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+
+	render, ok := exportFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q (expected md, json, or html)", *format)
+	}
+
+	var transcripts []Transcript
+	switch {
+	case *all && len(positional) == 0:
+		sessions, err := store.Load()
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return fmt.Errorf("no saved chats to export")
+		}
+		for _, s := range sessions {
+			t, err := fetchTranscript(s.Name, s.ChatID, since)
+			if err != nil {
+				return fmt.Errorf("failed to export %q: %w", s.Name, err)
+			}
+			transcripts = append(transcripts, t)
+		}
+	case !*all && len(positional) == 1:
+		name, chatID := resolveChat(store, positional[0])
+		t, err := fetchTranscript(name, chatID, since)
+		if err != nil {
+			return err
+		}
+		transcripts = append(transcripts, t)
+	default:
+		return fmt.Errorf("usage: go run . export <name-or-chat-id> | -all-sessions [-format md|json|html] [-o file] [-since when]")
+	}
+
+	if *out == "" {
+		return render(os.Stdout, transcripts)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := render(f, transcripts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Exported %d chat(s) to %s\n", len(transcripts), *out)
+	return nil
+}
+
+// parseInterspersed parses args with flags, allowing flags before and after
+// the positional arguments, which it returns
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// resolveChat accepts a saved chat's name, or any chat ID
+func resolveChat(store *SessionStore, nameOrID string) (name, chatID string) {
+	if saved, err := store.Get(nameOrID); err == nil {
+		return saved.Name, saved.ChatID
+	}
+	return "", nameOrID
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyPageSize is how many messages /history and the history command
+// show at a time
+const historyPageSize = 10
+
+// HistoryQuery selects part of a chat's history
+type HistoryQuery struct {
+	Limit  int       // newest messages to return; 0 returns all
+	Before string    // only messages before this message ID
+	Since  time.Time // only messages sent at or after this time
+}
+
+// HistoryPage is one page of a chat's history, oldest message first
+type HistoryPage struct {
+	ChatHistory
+	HasMore bool   `json:"has_more"` // earlier messages remain
+	Cursor  string `json:"-"`        // pass as Before to fetch the previous page
+}
+
+// getChatHistoryPage fetches the messages selected by q. The limit, before,
+// and since parameters are sent to the API, and also applied to what comes
+// back, so paging works the same whether or not the server honours them.
+func getChatHistoryPage(chatID string, q HistoryQuery) (*HistoryPage, error) {
+	token, err := ensureValidToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get valid token: %w", err)
+	}
+
+	params := url.Values{}
+	params.Add("chat_id", chatID)
+	if q.Limit > 0 {
+		params.Add("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Before != "" {
+		params.Add("before", q.Before)
+	}
+	if !q.Since.IsZero() {
+		params.Add("since", q.Since.UTC().Format(time.RFC3339))
+	}
+	requestURL := fmt.Sprintf("%s?%s", chatURL, params.Encode())
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("chat history request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newApiError("chat history retrieval", resp.StatusCode, body)
+	}
+
+	var page HistoryPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse chat history: %w", err)
+	}
+
+	for i := range page.Messages {
+		if page.Messages[i].Role == "user" {
+			page.Messages[i].Message = stripPersona(page.Messages[i].Message)
+		}
+	}
+	q.apply(&page)
+	return &page, nil
+}
+
+// apply trims a page to the query: messages from Before onwards and older
+// than Since are dropped, then all but the newest Limit
+func (q HistoryQuery) apply(page *HistoryPage) {
+	messages := page.Messages
+	if q.Before != "" {
+		for i, m := range messages {
+			if m.MessageID == q.Before {
+				messages = messages[:i]
+				break
+			}
+		}
+	}
+	if !q.Since.IsZero() {
+		kept := messages[:0:0]
+		for _, m := range messages {
+			// Keep messages whose time can't be read rather than hide them
+			if t, err := time.Parse(time.RFC3339, m.Timestamp); err != nil || !t.Before(q.Since) {
+				kept = append(kept, m)
+			}
+		}
+		if len(kept) < len(messages) {
+			// The rest of the history is older still
+			page.HasMore = false
+		}
+		messages = kept
+	}
+	if q.Limit > 0 && len(messages) > q.Limit {
+		messages = messages[len(messages)-q.Limit:]
+		page.HasMore = true
+	}
+
+	page.Messages = messages
+	page.Cursor = ""
+	if page.HasMore && len(messages) > 0 {
+		page.Cursor = messages[0].MessageID
+	}
+	if page.Cursor == "" {
+		// Without a message ID there's no way to ask for the page before
+		page.HasMore = false
+	}
+}
+
+// parseSince reads a -since value: an RFC 3339 time, a date (2006-01-02),
+// or an age such as 90m, 24h, or 7d
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q (use a date like 2024-06-01, an RFC 3339 time, or an age like 24h or 7d)", value)
+}
+
+// historyViewer shows a chat's history a page at a time, newest first,
+// loading earlier messages only when asked for them
+type historyViewer struct {
+	chatID string
+	query  HistoryQuery
+	shown  int // messages displayed so far
+}
+
+// next fetches and displays the page before the last one shown. It
+// reports whether earlier messages remain.
+func (v *historyViewer) next() (bool, error) {
+	page, err := getChatHistoryPage(v.chatID, v.query)
+	if err != nil {
+		return false, err
+	}
+	if len(page.Messages) == 0 {
+		if v.shown == 0 {
+			fmt.Println("No messages")
+		}
+		return false, nil
+	}
+	if v.shown > 0 {
+		fmt.Println("── Earlier messages ──")
+	}
+	fmt.Println()
+	for i, message := range page.Messages {
+		displayMessage(message, i)
+	}
+	v.shown += len(page.Messages)
+	v.query.Before = page.Cursor
+	return page.HasMore, nil
+}
+
+// runHistory handles the history command, which shows a chat's latest
+// messages and offers to load earlier ones a page at a time:
+//
+//	history <name-or-chat-id> [-limit n] [-since when]
+func runHistory(store *SessionStore, in io.Reader, args []string) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	limit := flags.Int("limit", historyPageSize, "messages per page (0 shows all)")
+	sinceFlag := flags.String("since", "", "only messages since a date or age (2024-06-01, 24h, 7d)")
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: go run . history <name-or-chat-id> [-limit n] [-since when]")
+	}
+	if *limit < 0 {
+		return fmt.Errorf("-limit can't be negative, got %d", *limit)
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+
+	name, chatID := resolveChat(store, positional[0])
+	if name != "" {
+		fmt.Printf("=== %s ===\n", name)
+	}
+	viewer := &historyViewer{chatID: chatID, query: HistoryQuery{Limit: *limit, Since: since}}
+	scanner := bufio.NewScanner(in)
+	for {
+		more, err := viewer.next()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		fmt.Print("Press Enter to load earlier messages, or q to stop: ")
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+		if strings.TrimSpace(scanner.Text()) != "" {
+			break
+		}
+	}
+	fmt.Printf("🔗 Chat ID: %s\n", chatID)
+	return nil
+}
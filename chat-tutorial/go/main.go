@@ -3,12 +3,14 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -16,10 +18,11 @@ import (
 
 // Configuration constants
 const (
-	tokenURL    = "https://platform.ai.gloo.com/oauth2/token"
-	messageURL  = "https://platform.ai.gloo.com/ai/v1/message"
-	chatURL     = "https://platform.ai.gloo.com/ai/v1/chat"
-	httpTimeout = 30 * time.Second
+	tokenURL       = "https://platform.ai.gloo.com/oauth2/token"
+	messageURL     = "https://platform.ai.gloo.com/ai/v1/message"
+	chatURL        = "https://platform.ai.gloo.com/ai/v1/chat"
+	completionsURL = "https://platform.ai.gloo.com/ai/v2/chat/completions" // topic tagging for stats
+	httpTimeout    = 30 * time.Second
 )
 
 // Data structures
@@ -48,16 +51,17 @@ type MessageResponse struct {
 	Timestamp   string   `json:"timestamp"`
 	Success     bool     `json:"success"`
 	Suggestions []string `json:"suggestions"`
-	Sources     []any    `json:"sources"`
+	Sources     []Source `json:"sources"`
 }
 
 type ChatMessage struct {
-	QueryID        string `json:"query_id"`
-	MessageID      string `json:"message_id"`
-	Timestamp      string `json:"timestamp"`
-	Role           string `json:"role"`
-	Message        string `json:"message"`
-	CharacterLimit *int   `json:"character_limit,omitempty"`
+	QueryID        string   `json:"query_id"`
+	MessageID      string   `json:"message_id"`
+	Timestamp      string   `json:"timestamp"`
+	Role           string   `json:"role"`
+	Message        string   `json:"message"`
+	CharacterLimit *int     `json:"character_limit,omitempty"`
+	Sources        []Source `json:"sources,omitempty"`
 }
 
 type ChatHistory struct {
@@ -93,13 +97,13 @@ func init() {
 	// Load environment variables from .env file
 	if err := godotenv.Load(); err != nil {
 		// .env file is optional, so don't fail if it doesn't exist
-		fmt.Println("Warning: .env file not found, using environment variables")
+		fmt.Fprintln(os.Stderr, "Warning: .env file not found, using environment variables")
 	}
-	
+
 	// Initialize configuration
 	clientID = getEnvOrDefault("GLOO_CLIENT_ID", "YOUR_CLIENT_ID")
 	clientSecret = getEnvOrDefault("GLOO_CLIENT_SECRET", "YOUR_CLIENT_SECRET")
-	
+
 	// Initialize HTTP client with timeout
 	httpClient = &http.Client{
 		Timeout: httpTimeout,
@@ -114,7 +118,8 @@ func getEnvOrDefault(key, defaultValue string) string {
 }
 
 func getAccessToken() (*TokenInfo, error) {
-	data := strings.NewReader("grant_type=client_credentials&scope=api/access")
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {getEnvOrDefault("GLOO_SCOPE", "api/access")}}
+	data := strings.NewReader(form.Encode())
 	req, err := http.NewRequest("POST", tokenURL, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -135,17 +140,7 @@ func getAccessToken() (*TokenInfo, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		var apiErr ApiError
-		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Detail != "" {
-			return nil, &GlooApiError{
-				Message:    fmt.Sprintf("authentication failed: %s", apiErr.Detail),
-				StatusCode: resp.StatusCode,
-			}
-		}
-		return nil, &GlooApiError{
-			Message:    fmt.Sprintf("authentication failed: HTTP %d - %s", resp.StatusCode, string(body)),
-			StatusCode: resp.StatusCode,
-		}
+		return nil, newApiError("authentication", resp.StatusCode, body)
 	}
 
 	var token TokenInfo
@@ -164,9 +159,15 @@ func isTokenExpired(token *TokenInfo) bool {
 	return time.Now().Unix() > (token.ExpiresAt - 60)
 }
 
+// tokenMu guards tokenInfo, which the proxy's handlers share
+var tokenMu sync.Mutex
+
 func ensureValidToken() (string, error) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
 	if isTokenExpired(tokenInfo) {
-		fmt.Println("Getting new access token...")
+		// Progress goes to stderr so exports written to stdout stay clean
+		fmt.Fprintln(os.Stderr, "Getting new access token...")
 		var err error
 		tokenInfo, err = getAccessToken()
 		if err != nil {
@@ -176,24 +177,72 @@ func ensureValidToken() (string, error) {
 	return tokenInfo.AccessToken, nil
 }
 
-func sendMessage(messageText string, chatID string) (*MessageResponse, error) {
-	token, err := ensureValidToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get valid token: %w", err)
-	}
-
+func newMessageRequest(messageText string, chatID string) MessageRequest {
 	payload := MessageRequest{
-		Query:             messageText,
-		CharacterLimit:    1000,
-		SourcesLimit:      5,
-		Stream:            false,
-		Publishers:        []string{},
-		EnableSuggestions: 1, // Enable suggested follow-up questions
+		Query:          withPersona(messageText, chatID),
+		CharacterLimit: messageOptions.CharacterLimit,
+		SourcesLimit:   messageOptions.SourcesLimit,
+		Stream:         false,
+		Publishers:     messageOptions.Publishers,
+	}
+	if messageOptions.EnableSuggestions {
+		payload.EnableSuggestions = 1 // Enable suggested follow-up questions
 	}
 
 	if chatID != "" {
 		payload.ChatID = chatID
 	}
+	return payload
+}
+
+// newApiError builds a GlooApiError from a failed response, preferring the
+// API's own error detail when the body has one
+func newApiError(action string, statusCode int, body []byte) error {
+	if statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity {
+		if issues := describeValidationErrors(body); issues != "" {
+			return &GlooApiError{
+				Message:    fmt.Sprintf("%s failed: the API rejected the request: %s", action, issues),
+				StatusCode: statusCode,
+			}
+		}
+	}
+
+	var apiErr ApiError
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Detail != "" {
+		hint := ""
+		if statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity {
+			hint = flagHint(apiErr.Detail)
+		}
+		return &GlooApiError{
+			Message:    fmt.Sprintf("%s failed: %s%s", action, apiErr.Detail, hint),
+			StatusCode: statusCode,
+		}
+	}
+	return &GlooApiError{
+		Message:    fmt.Sprintf("%s failed: HTTP %d - %s", action, statusCode, string(body)),
+		StatusCode: statusCode,
+	}
+}
+
+// sendMessage sends a message, retrying transient failures
+func sendMessage(messageText string, chatID string) (*MessageResponse, error) {
+	return postMessage(&outgoingMessage{text: messageText, chatID: chatID})
+}
+
+// postMessage sends msg, retrying transient failures. A message that may
+// already have been posted is looked up in its chat's history first, and
+// if it's there its reply is returned rather than posting it twice.
+func postMessage(msg *outgoingMessage) (*MessageResponse, error) {
+	if reply, err := msg.alreadyPosted(); reply != nil || err != nil {
+		return reply, err
+	}
+
+	token, err := ensureValidToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get valid token: %w", err)
+	}
+
+	payload := newMessageRequest(msg.text, msg.chatID)
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -208,8 +257,11 @@ func sendMessage(messageText string, chatID string) (*MessageResponse, error) {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := doWithRetry(httpClient, req, msg)
 	if err != nil {
+		if reply := postedReply(err); reply != nil {
+			return reply, nil
+		}
 		return nil, fmt.Errorf("message request failed: %w", err)
 	}
 	defer resp.Body.Close()
@@ -220,17 +272,7 @@ func sendMessage(messageText string, chatID string) (*MessageResponse, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		var apiErr ApiError
-		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Detail != "" {
-			return nil, &GlooApiError{
-				Message:    fmt.Sprintf("message sending failed: %s", apiErr.Detail),
-				StatusCode: resp.StatusCode,
-			}
-		}
-		return nil, &GlooApiError{
-			Message:    fmt.Sprintf("message sending failed: HTTP %d - %s", resp.StatusCode, string(body)),
-			StatusCode: resp.StatusCode,
-		}
+		return nil, newApiError("message sending", resp.StatusCode, body)
 	}
 
 	var response MessageResponse
@@ -241,55 +283,14 @@ func sendMessage(messageText string, chatID string) (*MessageResponse, error) {
 	return &response, nil
 }
 
+// getChatHistory fetches a chat's whole history; see getChatHistoryPage to
+// fetch part of it
 func getChatHistory(chatID string) (*ChatHistory, error) {
-	token, err := ensureValidToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get valid token: %w", err)
-	}
-
-	params := url.Values{}
-	params.Add("chat_id", chatID)
-	requestURL := fmt.Sprintf("%s?%s", chatURL, params.Encode())
-
-	req, err := http.NewRequest("GET", requestURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := httpClient.Do(req)
+	page, err := getChatHistoryPage(chatID, HistoryQuery{})
 	if err != nil {
-		return nil, fmt.Errorf("chat history request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		var apiErr ApiError
-		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Detail != "" {
-			return nil, &GlooApiError{
-				Message:    fmt.Sprintf("chat history retrieval failed: %s", apiErr.Detail),
-				StatusCode: resp.StatusCode,
-			}
-		}
-		return nil, &GlooApiError{
-			Message:    fmt.Sprintf("chat history retrieval failed: HTTP %d - %s", resp.StatusCode, string(body)),
-			StatusCode: resp.StatusCode,
-		}
-	}
-
-	var history ChatHistory
-	if err := json.Unmarshal(body, &history); err != nil {
-		return nil, fmt.Errorf("failed to parse chat history: %w", err)
-	}
-
-	return &history, nil
+	return &page.ChatHistory, nil
 }
 
 func formatTimestamp(timestamp string) string {
@@ -310,11 +311,13 @@ func displayMessage(message ChatMessage, index int) {
 	role := strings.ToUpper(message.Role)
 	timestamp := formatTimestamp(message.Timestamp)
 	fmt.Printf("%d. %s [%s]:\n", index+1, role, timestamp)
-	fmt.Printf("%s\n\n", message.Message)
+	fmt.Printf("%s\n", message.Message)
+	displaySources(message.Sources)
+	fmt.Println()
 }
 
-func main() {
-	// Validate environment
+// requireEnvironment exits with setup instructions if credentials are missing
+func requireEnvironment() {
 	if err := validateEnvironment(); err != nil {
 		fmt.Printf("❌ Environment Error: %v\n", err)
 		fmt.Println("Create a .env file with:")
@@ -322,7 +325,126 @@ func main() {
 		fmt.Println("GLOO_CLIENT_SECRET=your_client_secret")
 		os.Exit(1)
 	}
+}
+
+func main() {
+	messageOptions.RegisterFlags(flag.CommandLine)
+	personaName := flag.String("persona", os.Getenv("CHAT_PERSONA"), "persona from the personas file to use for new chats")
+	personasFile := flag.String("personas", getEnvOrDefault("CHAT_PERSONAS_FILE", defaultPersonasFile), "personas file")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: go run . [flags] [new [name] | list | resume <name> | delete <name> | history <name> | export <name> | stats | voice [name] | tui [name] | serve | demo]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if err := messageOptions.Validate(); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(2)
+	}
+	if *personaName != "" {
+		persona, err := loadPersona(*personasFile, *personaName)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(2)
+		}
+		activePersona = persona
+	}
+
+	store := defaultSessionStore()
+	command := flag.Arg(0)
+	args := flag.Args()[min(flag.NArg(), 1):]
+
+	switch command {
+	case "demo":
+		requireEnvironment()
+		runDemo()
+	case "", "new":
+		// Chat interactively by default
+		requireEnvironment()
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		runChat(os.Stdin, store, nil, name, nil)
+	case "list":
+		if err := listSessions(store); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "resume":
+		if len(args) != 1 {
+			fmt.Println("Usage: go run . resume <name>")
+			os.Exit(2)
+		}
+		saved, err := store.Get(args[0])
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		requireEnvironment()
+		runChat(os.Stdin, store, saved, "", nil)
+	case "serve":
+		requireEnvironment()
+		if err := runProxy(args); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "voice":
+		requireEnvironment()
+		if err := runVoice(store, args); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "tui":
+		var saved *SavedSession
+		if len(args) > 0 {
+			var err error
+			if saved, err = store.Get(args[0]); err != nil {
+				fmt.Printf("❌ Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		requireEnvironment()
+		if err := runTUI(store, saved); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "history":
+		requireEnvironment()
+		if err := runHistory(store, os.Stdin, args); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "stats":
+		requireEnvironment()
+		if err := runStats(store, args); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "export":
+		requireEnvironment()
+		if err := runExport(store, args); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "delete":
+		if len(args) != 1 {
+			fmt.Println("Usage: go run . delete <name>")
+			os.Exit(2)
+		}
+		if err := store.Delete(args[0]); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted %q from saved chats\n", args[0])
+	default:
+		fmt.Printf("Unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
 
+// runDemo runs the scripted two-message conversation
+func runDemo() {
 	// Start with a deep, meaningful question about human flourishing
 	initialQuestion := "How can I find meaning and purpose when facing life's greatest challenges?"
 
@@ -339,7 +461,9 @@ func main() {
 	chatID := chatResponse.ChatID
 
 	fmt.Println("AI Response:")
-	fmt.Printf("%s\n\n", chatResponse.Message)
+	fmt.Printf("%s\n", chatResponse.Message)
+	displaySources(chatResponse.Sources)
+	fmt.Println()
 
 	// Show suggested follow-up questions
 	if len(chatResponse.Suggestions) > 0 {
@@ -360,7 +484,7 @@ func main() {
 
 	fmt.Println("=== Continuing the Conversation ===")
 	fmt.Printf("Using suggested question: %s\n\n", followUpQuestion)
-	
+
 	// Send follow-up message
 	followUpResponse, err := sendMessage(followUpQuestion, chatID)
 	if err != nil {
@@ -369,6 +493,7 @@ func main() {
 	}
 	fmt.Println("AI Response:")
 	fmt.Println(followUpResponse.Message)
+	displaySources(followUpResponse.Sources)
 	fmt.Println()
 
 	// Display final chat history
@@ -387,4 +512,4 @@ func main() {
 	fmt.Printf("📊 Total messages: %d\n", len(chatHistory.Messages))
 	fmt.Printf("🔗 Chat ID: %s\n", chatID)
 	fmt.Printf("📅 Session created: %s\n", formatTimestamp(chatHistory.CreatedAt))
-}
\ No newline at end of file
+}
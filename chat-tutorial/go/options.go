@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MessageOptions are the per-message settings sent with every query
+type MessageOptions struct {
+	CharacterLimit    int
+	SourcesLimit      int
+	EnableSuggestions bool
+	Publishers        []string // restricts answers to these publishers' content
+}
+
+// messageOptions holds the settings from flags and the environment
+var messageOptions = MessageOptions{
+	CharacterLimit:    1000,
+	SourcesLimit:      5,
+	EnableSuggestions: true,
+}
+
+// optionFlags names the flag that sets each request field, for error hints
+var optionFlags = map[string]string{
+	"character_limit":    "-character-limit",
+	"sources_limit":      "-sources-limit",
+	"enable_suggestions": "-suggestions",
+	"publishers":         "-publishers",
+}
+
+// RegisterFlags adds a flag for each option to fs. Defaults come from
+// CHAT_CHARACTER_LIMIT, CHAT_SOURCES_LIMIT, and CHAT_SUGGESTIONS when set.
+func (o *MessageOptions) RegisterFlags(fs *flag.FlagSet) {
+	if v, err := strconv.Atoi(os.Getenv("CHAT_CHARACTER_LIMIT")); err == nil {
+		o.CharacterLimit = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CHAT_SOURCES_LIMIT")); err == nil {
+		o.SourcesLimit = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CHAT_SUGGESTIONS")); err == nil {
+		o.EnableSuggestions = v
+	}
+
+	fs.IntVar(&o.CharacterLimit, "character-limit", o.CharacterLimit, "maximum length of each reply in characters")
+	fs.IntVar(&o.SourcesLimit, "sources-limit", o.SourcesLimit, "maximum number of sources to cite per reply")
+	fs.BoolVar(&o.EnableSuggestions, "suggestions", o.EnableSuggestions, "ask for suggested follow-up questions")
+	fs.Func("publishers", "only draw on these publishers' content (comma-separated, repeatable)", func(s string) error {
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				o.Publishers = append(o.Publishers, p)
+			}
+		}
+		return nil
+	})
+}
+
+// Validate catches values that can never be valid before a request is
+// sent. Upper bounds are left to the API, whose errors are reported by
+// newApiError with the flag to change.
+func (o *MessageOptions) Validate() error {
+	if o.CharacterLimit < 1 {
+		return fmt.Errorf("-character-limit must be at least 1, got %d", o.CharacterLimit)
+	}
+	// 0 would be dropped from the request by omitempty, silently leaving
+	// the API's default in place
+	if o.SourcesLimit < 1 {
+		return fmt.Errorf("-sources-limit must be at least 1, got %d", o.SourcesLimit)
+	}
+	return nil
+}
+
+// validationIssue is one entry of a request validation error, as returned
+// with HTTP 422: {"detail": [{"loc": ["body", "character_limit"], "msg": "..."}]}
+type validationIssue struct {
+	Loc []any  `json:"loc"`
+	Msg string `json:"msg"`
+}
+
+// describeValidationErrors turns a validation error body into a readable
+// message that names the flag behind each rejected field. It returns ""
+// if body isn't a validation error.
+func describeValidationErrors(body []byte) string {
+	var parsed struct {
+		Detail []validationIssue `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil || len(parsed.Detail) == 0 {
+		return ""
+	}
+
+	var issues []string
+	for _, issue := range parsed.Detail {
+		field := ""
+		if len(issue.Loc) > 0 {
+			field = fmt.Sprint(issue.Loc[len(issue.Loc)-1])
+		}
+		text := issue.Msg
+		if field != "" {
+			text = field + ": " + issue.Msg
+		}
+		if name, ok := optionFlags[field]; ok {
+			text += fmt.Sprintf(" (change it with %s)", name)
+		}
+		issues = append(issues, text)
+	}
+	return strings.Join(issues, "; ")
+}
+
+// flagHint points at the flag to change when an error message mentions one
+// of the request fields it sets
+func flagHint(message string) string {
+	for field, name := range optionFlags {
+		if strings.Contains(message, field) {
+			return fmt.Sprintf(" (change it with %s)", name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Persona is a system instruction that sets how the assistant answers
+// throughout a chat
+type Persona struct {
+	Name        string
+	Description string
+	Instruction string
+}
+
+// defaultPersonasFile is read when -personas isn't given
+const defaultPersonasFile = "personas.yaml"
+
+// activePersona is the persona chosen with -persona, or nil
+var activePersona *Persona
+
+// The message API has no system instruction field, so a persona is pinned
+// as a preamble to the first message of each new chat. The chat keeps it in
+// context from then on, and getChatHistoryPage strips it from the history.
+const (
+	personaStart = "[Conversation instructions: follow these for the whole conversation]\n"
+	personaEnd   = "\n[End of instructions]\n\n"
+)
+
+// withPersona prepends the active persona's preamble to the first message
+// of a chat
+func withPersona(messageText string, chatID string) string {
+	if activePersona == nil || chatID != "" {
+		return messageText
+	}
+	return personaStart + activePersona.Instruction + personaEnd + messageText
+}
+
+// stripPersona removes a persona preamble from a message
+func stripPersona(message string) string {
+	if !strings.HasPrefix(message, personaStart) {
+		return message
+	}
+	if i := strings.Index(message, personaEnd); i >= 0 {
+		return message[i+len(personaEnd):]
+	}
+	return message
+}
+
+// loadPersona reads the named persona from a personas file
+func loadPersona(path, name string) (*Persona, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read personas: %w (copy personas.example.yaml to %s to get started)", err, defaultPersonasFile)
+	}
+	personas, err := parsePersonas(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	persona, ok := personas[name]
+	if !ok {
+		var names []string
+		for n := range personas {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("no persona named %q in %s (available: %s)", name, path, strings.Join(names, ", "))
+	}
+	return persona, nil
+}
+
+// parsePersonas reads the YAML subset personas files use, which keeps the
+// example free of a YAML dependency: a mapping of persona names to
+// description and instruction fields, where values are plain or quoted
+// scalars or | and > block scalars.
+//
+//	counselor:
+//	  description: A gentle, pastoral listener
+//	  instruction: |
+//	    You are a compassionate counselor...
+func parsePersonas(data string) (map[string]*Persona, error) {
+	personas := map[string]*Persona{}
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+
+	var current *Persona
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || strings.ContainsAny(line[:indent], "\t") {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", i+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if indent == 0 {
+			if value != "" {
+				return nil, fmt.Errorf("line %d: persona %q should be followed by indented fields", i+1, key)
+			}
+			if _, dup := personas[key]; dup {
+				return nil, fmt.Errorf("line %d: persona %q is defined twice", i+1, key)
+			}
+			current = &Persona{Name: key}
+			personas[key] = current
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: field %q is outside a persona", i+1, key)
+		}
+
+		if value == "|" || value == ">" {
+			var block []string
+			block, i = blockScalar(lines, i+1, indent)
+			if value == "|" {
+				value = strings.Join(block, "\n")
+			} else {
+				value = foldLines(block)
+			}
+		} else {
+			var err error
+			if value, err = plainScalar(value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+		}
+
+		switch key {
+		case "description":
+			current.Description = value
+		case "instruction":
+			current.Instruction = value
+		default:
+			return nil, fmt.Errorf("line %d: unknown field %q (expected description or instruction)", i+1, key)
+		}
+	}
+
+	for name, p := range personas {
+		if strings.TrimSpace(p.Instruction) == "" {
+			return nil, fmt.Errorf("persona %q has no instruction", name)
+		}
+	}
+	return personas, nil
+}
+
+// blockScalar collects the lines of a block scalar starting at lines[start]
+// that are indented more than parent. It returns them with the block's
+// indentation removed, and the index of the last line consumed.
+func blockScalar(lines []string, start, parent int) ([]string, int) {
+	var block []string
+	indent := -1
+	last := start - 1
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			block = append(block, "")
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		if n <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = n
+		}
+		block = append(block, line[min(n, indent):])
+		last = i
+	}
+	// Trailing blank lines belong to whatever follows
+	return block[:max(0, last-start+1)], last
+}
+
+// foldLines joins a > block scalar: lines are joined with spaces, and blank
+// lines become line breaks
+func foldLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		switch {
+		case line == "":
+			b.WriteString("\n")
+		case i > 0 && lines[i-1] != "":
+			b.WriteString(" " + line)
+		default:
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// plainScalar unquotes a single-line value and drops a trailing comment
+func plainScalar(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chatProxy serves the chat API to many users of an application. Each user
+// ID maps to one Gloo AI chat, so users never see each other's
+// conversations and the application doesn't need to track chat IDs.
+type chatProxy struct {
+	directory ChatDirectory
+	limiter   *rateLimiter
+	apiKey    string // required as a bearer token when set
+
+	// One message at a time per user, so a user's first two messages
+	// can't start two chats. A user's entry is removed once nobody holds
+	// or waits for it.
+	locksMu sync.Mutex
+	locks   map[string]*userLock
+}
+
+// userLock is a user's mutex and how many requests hold or wait for it
+type userLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// recordAttempts is how many times the proxy tries to save a new user's
+// chat before giving up on the request
+const recordAttempts = 3
+
+// proxyMessageRequest is the body of POST /users/{id}/messages
+type proxyMessageRequest struct {
+	Message string `json:"message"`
+}
+
+// ServeHTTP routes:
+//
+//	POST   /users/{id}/messages  send a message in the user's chat
+//	GET    /users/{id}/history   the user's chat history (limit, before, since)
+//	DELETE /users/{id}/chat      forget the user's chat; the next message starts a new one
+//	GET    /healthz              liveness check
+func (p *chatProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" {
+		writeProxyJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+	if !p.authorized(r) {
+		writeProxyError(w, http.StatusUnauthorized, "missing or invalid API key")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "users" || parts[1] == "" {
+		writeProxyError(w, http.StatusNotFound, "not found")
+		return
+	}
+	userID, resource := parts[1], parts[2]
+
+	switch {
+	case resource == "messages" && r.Method == http.MethodPost:
+		p.sendMessage(w, r, userID)
+	case resource == "history" && r.Method == http.MethodGet:
+		p.history(w, r, userID)
+	case resource == "chat" && r.Method == http.MethodDelete:
+		p.forget(w, userID)
+	case resource == "messages" || resource == "history" || resource == "chat":
+		writeProxyError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeProxyError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (p *chatProxy) authorized(r *http.Request) bool {
+	if p.apiKey == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(p.apiKey)) == 1
+}
+
+func (p *chatProxy) lock(userID string) func() {
+	p.locksMu.Lock()
+	if p.locks == nil {
+		p.locks = map[string]*userLock{}
+	}
+	l, ok := p.locks[userID]
+	if !ok {
+		l = &userLock{}
+		p.locks[userID] = l
+	}
+	l.refs++
+	p.locksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		p.locksMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(p.locks, userID)
+		}
+		p.locksMu.Unlock()
+	}
+}
+
+func (p *chatProxy) sendMessage(w http.ResponseWriter, r *http.Request, userID string) {
+	if ok, wait := p.limiter.Allow(userID); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeProxyError(w, http.StatusTooManyRequests, "rate limit exceeded; try again later")
+		return
+	}
+
+	var req proxyMessageRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeProxyError(w, http.StatusBadRequest, "body must be JSON like {\"message\": \"...\"}")
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeProxyError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	unlock := p.lock(userID)
+	defer unlock()
+
+	chatID := ""
+	chat, err := p.directory.Lookup(userID)
+	if err != nil {
+		writeProxyError(w, http.StatusInternalServerError, "failed to look up chat: "+err.Error())
+		return
+	}
+	if chat != nil {
+		chatID = chat.ChatID
+	}
+
+	// A client's own Idempotency-Key is passed on, so its retries carry
+	// the same key upstream
+	msg := &outgoingMessage{text: req.Message, chatID: chatID}
+	if key := r.Header.Get("Idempotency-Key"); len(key) <= 255 {
+		msg.key = key
+	}
+	response, err := postMessage(msg)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	if chatID != "" {
+		if err := p.directory.Record(userID, chatID); err != nil {
+			// The mapping already exists, so only the message count and
+			// last update time are lost
+			log.Printf("failed to record message for user %q: %v", userID, err)
+		}
+		writeProxyJSON(w, http.StatusOK, response)
+		return
+	}
+
+	// The new chat's ID is only in this response, so if it can't be saved
+	// the user's next message would start another chat
+	for attempt := 1; ; attempt++ {
+		err = p.directory.Record(userID, response.ChatID)
+		if err == nil {
+			break
+		}
+		if attempt == recordAttempts {
+			log.Printf("failed to record chat %s for user %q: %v", response.ChatID, userID, err)
+			writeProxyError(w, http.StatusInternalServerError, "the message was answered, but its chat couldn't be saved: "+err.Error())
+			return
+		}
+		time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+	}
+	writeProxyJSON(w, http.StatusOK, response)
+}
+
+func (p *chatProxy) history(w http.ResponseWriter, r *http.Request, userID string) {
+	chat, err := p.directory.Lookup(userID)
+	if err != nil {
+		writeProxyError(w, http.StatusInternalServerError, "failed to look up chat: "+err.Error())
+		return
+	}
+	if chat == nil {
+		writeProxyError(w, http.StatusNotFound, "user has no chat yet")
+		return
+	}
+
+	query := HistoryQuery{Before: r.URL.Query().Get("before")}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 0 {
+			writeProxyError(w, http.StatusBadRequest, "limit must be a non-negative number")
+			return
+		}
+	}
+	if query.Since, err = parseSince(r.URL.Query().Get("since")); err != nil {
+		writeProxyError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := getChatHistoryPage(chat.ChatID, query)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	writeProxyJSON(w, http.StatusOK, struct {
+		*HistoryPage
+		NextBefore string `json:"next_before,omitempty"`
+	}{page, page.Cursor})
+}
+
+func (p *chatProxy) forget(w http.ResponseWriter, userID string) {
+	unlock := p.lock(userID)
+	defer unlock()
+	if err := p.directory.Forget(userID); err != nil {
+		writeProxyError(w, http.StatusInternalServerError, "failed to forget chat: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeProxyJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeProxyError(w http.ResponseWriter, status int, message string) {
+	writeProxyJSON(w, status, map[string]string{"error": message})
+}
+
+// writeUpstreamError reports a failed Gloo AI call. Requests the API
+// rejected are the caller's to fix (400); anything else is a 502.
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	var apiErr *GlooApiError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity) {
+		writeProxyError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("upstream error: %v", err)
+	writeProxyError(w, http.StatusBadGateway, err.Error())
+}
+
+// rateLimiter is a token bucket per user: each user can send burst
+// messages at once, refilled at perMinute. Buckets that have refilled are
+// dropped, since a new bucket starts full.
+type rateLimiter struct {
+	perMinute float64
+	burst     float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	pruned  time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	return &rateLimiter{perMinute: float64(perMinute), burst: float64(burst), buckets: map[string]*tokenBucket{}}
+}
+
+// Allow takes a token from the user's bucket, or reports how long until
+// one is available
+func (l *rateLimiter) Allow(userID string) (bool, time.Duration) {
+	if l.perMinute <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+	b, ok := l.buckets[userID]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[userID] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Minutes()*l.perMinute)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.perMinute * float64(time.Minute))
+}
+
+// prune drops buckets that would be full by now, at most once per refill
+// period; the caller holds l.mu
+func (l *rateLimiter) prune(now time.Time) {
+	refill := time.Duration(l.burst / l.perMinute * float64(time.Minute))
+	if now.Sub(l.pruned) < refill {
+		return
+	}
+	l.pruned = now
+	for userID, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+// runProxy handles the serve command:
+//
+//	serve [-addr :8080] [-store sqlite|json] [-db path] [-rate-limit n] [-burst n]
+func runProxy(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", getEnvOrDefault("PROXY_ADDR", ":8080"), "address to listen on")
+	store := flags.String("store", "sqlite", "where to keep the user to chat mapping: sqlite or json")
+	dbPath := flags.String("db", "", "database file (default chat-proxy.db, or chat-proxy.json for -store json)")
+	perMinute := flags.Int("rate-limit", 20, "messages each user may send per minute (0 disables the limit)")
+	burst := flags.Int("burst", 5, "messages each user may send at once before the rate limit applies")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *burst < 1 {
+		return fmt.Errorf("-burst must be at least 1, got %d", *burst)
+	}
+
+	var directory ChatDirectory
+	var err error
+	switch *store {
+	case "sqlite":
+		if *dbPath == "" {
+			*dbPath = "chat-proxy.db"
+		}
+		directory, err = openSQLDirectory(*dbPath)
+	case "json":
+		if *dbPath == "" {
+			*dbPath = "chat-proxy.json"
+		}
+		directory, err = openJSONDirectory(*dbPath)
+	default:
+		return fmt.Errorf("unknown store %q (expected sqlite or json)", *store)
+	}
+	if err != nil {
+		return err
+	}
+	defer directory.Close()
+
+	// Fail fast on bad credentials rather than on the first message
+	if _, err := ensureValidToken(); err != nil {
+		return err
+	}
+
+	proxy := &chatProxy{
+		directory: directory,
+		limiter:   newRateLimiter(*perMinute, *burst),
+		apiKey:    os.Getenv("PROXY_API_KEY"),
+	}
+	if proxy.apiKey == "" {
+		log.Printf("PROXY_API_KEY is not set: any client can send messages as any user")
+	}
+	log.Printf("Chat proxy listening on %s (%s store at %s)", *addr, *store, *dbPath)
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           proxy,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
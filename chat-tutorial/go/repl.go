@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const chatHelp = `Type a message and press Enter to send it.
+  1, 2, ...   send a suggested follow-up by its number
+  /history    show the latest messages; add a date or age such as
+              2024-06-01 or 7d to show only messages since then
+  /more       show earlier messages after /history
+  /retry      resend the last message if it failed
+  /new        start a new conversation
+  /name NAME  rename this conversation in the saved list
+  /help       show this help
+  /quit       exit`
+
+// chatSession is the state of an interactive conversation
+type chatSession struct {
+	chatID      string
+	suggestions []string
+
+	store *SessionStore
+	saved *SavedSession // nil until the first reply creates the chat
+	name  string        // name to save a new chat under; empty picks one
+	voice *voiceIO      // reads replies aloud in voice mode
+
+	history *historyViewer // the /history listing, while /more can extend it
+
+	// The last message that failed, kept so resending it checks whether
+	// it was posted anyway
+	failed *outgoingMessage
+}
+
+// outgoing returns the message to send text as: the failed message when
+// text resends it, otherwise a new one
+func (s *chatSession) outgoing(text string) *outgoingMessage {
+	if s.failed != nil && s.failed.text == text && s.failed.chatID == s.chatID {
+		return s.failed
+	}
+	return &outgoingMessage{text: text, chatID: s.chatID}
+}
+
+// settle remembers a failed message for resending, or forgets the last
+// failure once a message goes through
+func (s *chatSession) settle(msg *outgoingMessage, err error) {
+	if err != nil {
+		s.failed = msg
+	} else {
+		s.failed = nil
+	}
+}
+
+// runChat reads messages from in and streams each reply, until /quit or
+// end of input. The conversation is saved to store so it can be resumed;
+// pass a saved session as resume to continue it, or a name for a new chat.
+// With voice set, an empty line records a spoken question and replies are
+// read aloud.
+func runChat(in io.Reader, store *SessionStore, resume *SavedSession, name string, voice *voiceIO) {
+	fmt.Println("=== Gloo AI Chat ===")
+	fmt.Println(chatHelp)
+	if voice != nil {
+		fmt.Println("Voice mode: press Enter on an empty line to ask out loud.")
+	}
+
+	session := &chatSession{store: store, saved: resume, name: name, voice: voice}
+	if len(messageOptions.Publishers) > 0 {
+		fmt.Printf("\nAnswering from: %s\n", strings.Join(messageOptions.Publishers, ", "))
+	}
+	if activePersona != nil {
+		fmt.Printf("\nPersona for new chats: %s", activePersona.Name)
+		if activePersona.Description != "" {
+			fmt.Printf(" (%s)", activePersona.Description)
+		}
+		fmt.Println()
+	}
+	if resume != nil {
+		session.chatID = resume.ChatID
+		fmt.Printf("\nResuming %q (%d messages)\n", resume.Name, resume.Messages)
+		if resume.Persona != "" {
+			fmt.Printf("This chat uses the %q persona\n", resume.Persona)
+		}
+	}
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Print("\nYou: ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" && voice != nil {
+			spoken, err := voice.listen(scanner)
+			if err != nil {
+				fmt.Printf("\n❌ %v\n", err)
+				continue
+			}
+			if spoken == "" {
+				fmt.Println("\nDidn't catch that, try again")
+				continue
+			}
+			fmt.Printf("\nYou said: %s\n", spoken)
+			line = spoken
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			if !session.command(line) {
+				return
+			}
+			continue
+		}
+
+		if text, ok := session.pick(line); ok {
+			session.send(text)
+		}
+	}
+}
+
+// pick returns the message to send for a line of input: the line itself,
+// or the suggestion it picks when it's a bare number
+func (s *chatSession) pick(line string) (string, bool) {
+	n, err := strconv.Atoi(line)
+	if err != nil {
+		return line, true
+	}
+	if n < 1 || n > len(s.suggestions) {
+		fmt.Printf("No suggestion %d\n", n)
+		return "", false
+	}
+	fmt.Printf("→ %s\n", s.suggestions[n-1])
+	return s.suggestions[n-1], true
+}
+
+// send streams the reply to a message and remembers its suggestions
+func (s *chatSession) send(text string) {
+	msg := s.outgoing(text)
+	fmt.Print("\nAI: ")
+	response, err := sendMessageStream(msg, func(delta string) {
+		fmt.Print(delta)
+	})
+	fmt.Println()
+	s.settle(msg, err)
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		fmt.Println("Type /retry to resend it")
+		return
+	}
+
+	if s.chatID == "" {
+		s.chatID = response.ChatID
+	}
+	// Saving is best effort: a failure shouldn't interrupt the chat
+	if note, err := s.record(text, response); err != nil {
+		fmt.Printf("⚠️  Could not save chat: %v\n", err)
+	} else if note != "" {
+		fmt.Println(note)
+	}
+	displaySources(response.Sources)
+	s.suggestions = response.Suggestions
+	if len(s.suggestions) > 0 {
+		fmt.Println("\nSuggested follow-up questions:")
+		for i, suggestion := range s.suggestions {
+			fmt.Printf("%d. %s\n", i+1, suggestion)
+		}
+	}
+	if s.voice != nil {
+		s.voice.speak(response.Message)
+	}
+}
+
+// command runs a slash command and reports whether to keep chatting
+func (s *chatSession) command(line string) bool {
+	switch strings.Fields(line)[0] {
+	case "/quit", "/exit":
+		return false
+	case "/help":
+		fmt.Println(chatHelp)
+	case "/retry":
+		if s.failed == nil {
+			fmt.Println("Nothing to retry")
+			break
+		}
+		fmt.Printf("→ %s\n", s.failed.text)
+		s.send(s.failed.text)
+	case "/new":
+		s.chatID = ""
+		s.failed = nil
+		s.suggestions = nil
+		s.saved = nil
+		s.name = ""
+		s.history = nil
+		fmt.Println("Started a new conversation")
+	case "/name":
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			fmt.Println("Usage: /name NAME")
+			break
+		}
+		if s.saved == nil {
+			// Not saved yet: use the name once the chat exists
+			s.name = fields[1]
+			fmt.Printf("This conversation will be saved as %q\n", s.name)
+			break
+		}
+		renamed := *s.saved
+		renamed.Name = fields[1]
+		if err := s.store.Put(renamed); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			break
+		}
+		s.saved = &renamed
+		fmt.Printf("Renamed to %q\n", renamed.Name)
+	case "/history":
+		if s.chatID == "" {
+			fmt.Println("No messages yet")
+			break
+		}
+		fields := strings.Fields(line)
+		since, err := parseSince(strings.Join(fields[1:], " "))
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			break
+		}
+		s.history = &historyViewer{chatID: s.chatID, query: HistoryQuery{Limit: historyPageSize, Since: since}}
+		s.showHistory()
+	case "/more":
+		if s.history == nil {
+			fmt.Println("Use /history first")
+			break
+		}
+		s.showHistory()
+	default:
+		fmt.Printf("Unknown command %s\n%s\n", line, chatHelp)
+	}
+	return true
+}
+
+// showHistory displays the next page of the /history listing
+func (s *chatSession) showHistory() {
+	more, err := s.history.next()
+	if err != nil {
+		fmt.Printf("❌ Error getting chat history: %v\n", err)
+		return
+	}
+	if more {
+		fmt.Println("Type /more to see earlier messages")
+	} else {
+		s.history = nil
+	}
+	fmt.Printf("🔗 Chat ID: %s\n", s.chatID)
+}
+
+// record saves the conversation after the reply to text. It returns a note
+// to show when the chat had to be saved under a different name than
+// requested. Call it before replacing s.suggestions with the reply's, so a
+// message that repeats a suggestion counts as using it.
+func (s *chatSession) record(text string, response *MessageResponse) (string, error) {
+	if s.store == nil || s.chatID == "" {
+		return "", nil
+	}
+
+	now := time.Now()
+	note := ""
+	if s.saved == nil {
+		existing, err := s.store.Load()
+		if err != nil {
+			return "", err
+		}
+		name := s.name
+		if name == "" {
+			name = "chat-" + now.Format("20060102-150405")
+		}
+		s.saved = &SavedSession{
+			Name:      uniqueSessionName(existing, name),
+			ChatID:    s.chatID,
+			CreatedAt: now,
+		}
+		if activePersona != nil {
+			s.saved.Persona = activePersona.Name
+		}
+		if s.saved.Name != name {
+			note = fmt.Sprintf("A saved chat named %q already exists; saving as %q", name, s.saved.Name)
+		}
+	}
+	s.saved.UpdatedAt = now
+	s.saved.Messages += 2 // the message and its reply
+	s.saved.SuggestionsOffered += len(response.Suggestions)
+	for _, suggestion := range s.suggestions {
+		if suggestion == text {
+			s.saved.SuggestionsUsed++
+			break
+		}
+	}
+
+	return note, s.store.Put(*s.saved)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Retry settings. CHAT_MAX_RETRIES overrides maxRetries; 0 disables
+// retries.
+var (
+	maxRetries     = 3
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+	// retryAfterMax caps how long a Retry-After header can make a retry wait
+	retryAfterMax = 30 * time.Second
+)
+
+func init() {
+	if v, err := strconv.Atoi(os.Getenv("CHAT_MAX_RETRIES")); err == nil && v >= 0 {
+		maxRetries = v
+	}
+}
+
+// outgoingMessage is a message being sent, with what's needed to tell
+// whether an attempt that failed was posted anyway
+type outgoingMessage struct {
+	text   string
+	chatID string // empty when the message starts a new chat
+	key    string // sent as Idempotency-Key with every attempt; generated if empty
+	sent   bool   // an attempt has been made
+
+	// The newest message in the chat before the first attempt, so only
+	// messages after it can be this one. known is false if it couldn't be
+	// looked up.
+	after string
+	known bool
+}
+
+// begin prepares the first attempt at sending m: it picks the idempotency
+// key and records the chat's newest message
+func (m *outgoingMessage) begin() {
+	if m.sent {
+		return
+	}
+	m.sent = true
+	if m.key == "" {
+		m.key = newIdempotencyKey()
+	}
+	if m.chatID == "" {
+		return
+	}
+	page, err := getChatHistoryPage(m.chatID, HistoryQuery{Limit: 1})
+	if err != nil {
+		return
+	}
+	if n := len(page.Messages); n > 0 {
+		m.after = page.Messages[n-1].MessageID
+		m.known = m.after != ""
+	} else {
+		m.known = true
+	}
+}
+
+// newIdempotencyKey returns a random key identifying one message across
+// its attempts
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// postedError reports that a message was found in its chat's history, so
+// it mustn't be sent again. reply is its answer, or nil if there isn't one
+// yet.
+type postedError struct {
+	reply *MessageResponse
+}
+
+func (e *postedError) Error() string {
+	return "the message was posted, but its reply isn't ready yet; check the chat's history instead of sending it again"
+}
+
+// checkNotPosted returns nil if the message is safe to resend: no user
+// message with its text reached the chat after the newest message recorded
+// by begin. A message that did is reported with a *postedError. A message
+// that would start a new chat, or whose chat couldn't be read before it was
+// sent, can't be looked up, so it is never resent.
+func (m *outgoingMessage) checkNotPosted() error {
+	if m.chatID == "" {
+		return fmt.Errorf("the message may have started a new chat before the request failed, so it wasn't resent; check your chats before sending it again")
+	}
+	if !m.known {
+		return fmt.Errorf("the chat's history couldn't be read before the message was sent, so it wasn't resent; check the chat's history before sending it again")
+	}
+	page, err := getChatHistoryPage(m.chatID, HistoryQuery{})
+	if err != nil {
+		return fmt.Errorf("couldn't check whether the message was posted, so it wasn't resent: %w", err)
+	}
+	messages := page.Messages
+	if m.after != "" {
+		found := false
+		for i, message := range messages {
+			if message.MessageID == m.after {
+				messages, found = messages[i+1:], true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("couldn't find where the message would be in the chat's history, so it wasn't resent; check the chat's history before sending it again")
+		}
+	}
+	for i, message := range messages {
+		if message.Role != "user" || message.Message != m.text {
+			continue
+		}
+		posted := &postedError{}
+		if i+1 < len(messages) && messages[i+1].Role != "user" {
+			reply := messages[i+1]
+			posted.reply = &MessageResponse{
+				ChatID:    m.chatID,
+				QueryID:   reply.QueryID,
+				MessageID: reply.MessageID,
+				Message:   reply.Message,
+				Timestamp: reply.Timestamp,
+				Success:   true,
+				Sources:   reply.Sources,
+			}
+		}
+		return posted
+	}
+	return nil
+}
+
+// alreadyPosted looks for a message that an earlier call failed to send in
+// its chat's history, and returns its reply if it was posted. A message
+// that would start a new chat, or whose chat couldn't be read before it was
+// sent, can't be looked up, so it is sent again as the caller asked.
+func (m *outgoingMessage) alreadyPosted() (*MessageResponse, error) {
+	if !m.sent || m.chatID == "" || !m.known {
+		return nil, nil
+	}
+	err := m.checkNotPosted()
+	if reply := postedReply(err); reply != nil {
+		return reply, nil
+	}
+	return nil, err
+}
+
+// postedReply returns the reply to a message that err says was posted
+// already, or nil
+func postedReply(err error) *MessageResponse {
+	var posted *postedError
+	if errors.As(err, &posted) {
+		return posted.reply
+	}
+	return nil
+}
+
+// retryable reports whether a response status is worth retrying: rate
+// limits and server errors are usually transient, other client errors are
+// not
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryDelay returns how long to wait before retry number attempt (from 1),
+// honouring a Retry-After header in seconds, up to retryAfterMax, if the
+// server sent one
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			if secs < int(retryAfterMax/time.Second) {
+				return time.Duration(secs) * time.Second
+			}
+			return retryAfterMax
+		}
+	}
+	delay := retryBaseDelay << (attempt - 1)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	// Full jitter keeps clients that failed together from retrying together
+	return time.Duration(mathrand.Int63n(int64(delay)) + 1)
+}
+
+// doWithRetry sends a message request with client, retrying network errors,
+// 429s, and 5xx responses with exponential backoff. The body is replayed
+// with GetBody, as set by http.NewRequest for bytes and strings readers.
+// A 429 means the message wasn't processed, so it is simply resent, but
+// after a network error or 5xx it may have been posted, so msg's chat is
+// checked first and the retries stop if it was. Every attempt carries msg's
+// Idempotency-Key.
+func doWithRetry(client *http.Client, req *http.Request, msg *outgoingMessage) (*http.Response, error) {
+	msg.begin()
+	req.Header.Set("Idempotency-Key", msg.key)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !retryable(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= maxRetries {
+			return resp, err
+		}
+
+		wait := retryDelay(attempt+1, resp)
+		rateLimited := resp != nil && resp.StatusCode == http.StatusTooManyRequests
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		time.Sleep(wait)
+		if !rateLimited {
+			if err := msg.checkNotPosted(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
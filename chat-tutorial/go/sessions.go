@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SavedSession is a named chat kept on this machine so it can be resumed
+// in a later run
+type SavedSession struct {
+	Name      string    `json:"name"`
+	ChatID    string    `json:"chat_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  int       `json:"messages"`
+	Persona   string    `json:"persona,omitempty"` // persona the chat started with
+
+	// Follow-up suggestions shown, and how many of them were sent
+	SuggestionsOffered int `json:"suggestions_offered,omitempty"`
+	SuggestionsUsed    int `json:"suggestions_used,omitempty"`
+}
+
+// SessionStore is the JSON file holding saved sessions
+type SessionStore struct {
+	Path string
+}
+
+// defaultSessionStore returns CHAT_SESSIONS_FILE if set, otherwise
+// gloo-cookbook/chat-sessions.json in the user's config directory
+func defaultSessionStore() *SessionStore {
+	if path := os.Getenv("CHAT_SESSIONS_FILE"); path != "" {
+		return &SessionStore{Path: path}
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return &SessionStore{Path: filepath.Join(dir, "gloo-cookbook", "chat-sessions.json")}
+}
+
+// Load returns every saved session, most recently used first
+func (s *SessionStore) Load() ([]SavedSession, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []SavedSession
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.Path, err)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *SessionStore) save(sessions []SavedSession) error {
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial file
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+// Get returns the session with the given name
+func (s *SessionStore) Get(name string) (*SavedSession, error) {
+	sessions, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		if sessions[i].Name == name {
+			return &sessions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no saved chat named %q (see the list command)", name)
+}
+
+// Put adds a session or replaces the one with the same chat ID. Names
+// must be unique.
+func (s *SessionStore) Put(session SavedSession) error {
+	sessions, err := s.Load()
+	if err != nil {
+		return err
+	}
+	existing := -1
+	for i := range sessions {
+		if sessions[i].ChatID == session.ChatID {
+			existing = i
+		} else if sessions[i].Name == session.Name {
+			return fmt.Errorf("a saved chat named %q already exists", session.Name)
+		}
+	}
+	if existing >= 0 {
+		sessions[existing] = session
+	} else {
+		sessions = append(sessions, session)
+	}
+	return s.save(sessions)
+}
+
+// Delete removes the session with the given name. Only the local record is
+// removed; the conversation itself stays on the Gloo AI platform.
+func (s *SessionStore) Delete(name string) error {
+	sessions, err := s.Load()
+	if err != nil {
+		return err
+	}
+	for i := range sessions {
+		if sessions[i].Name == name {
+			return s.save(append(sessions[:i], sessions[i+1:]...))
+		}
+	}
+	return fmt.Errorf("no saved chat named %q (see the list command)", name)
+}
+
+// uniqueSessionName returns base, or base with a numeric suffix if a
+// session already uses that name
+func uniqueSessionName(sessions []SavedSession, base string) string {
+	taken := map[string]bool{}
+	for _, s := range sessions {
+		taken[s.Name] = true
+	}
+	name := base
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	return name
+}
+
+// listSessions prints the saved sessions
+func listSessions(store *SessionStore) error {
+	sessions, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No saved chats yet. Start one with: go run . new <name>")
+		return nil
+	}
+
+	fmt.Printf("%-24s %-9s %-20s %s\n", "NAME", "MESSAGES", "LAST USED", "CHAT ID")
+	for _, s := range sessions {
+		fmt.Printf("%-24s %-9d %-20s %s\n", s.Name, s.Messages, s.UpdatedAt.Local().Format("2006-01-02 15:04"), s.ChatID)
+	}
+	return nil
+}
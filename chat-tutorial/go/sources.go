@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Source is one entry in the sources list of a message reply or of an
+// assistant message in the chat history. Replies cite publisher content by
+// title, with the author, publisher, and link when the content has them.
+type Source struct {
+	ID        string `json:"id,omitempty"`
+	Title     string `json:"title"`
+	Publisher string `json:"publisher,omitempty"`
+	Author    string `json:"author,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Snippet   string `json:"snippet,omitempty"`
+}
+
+// Label describes the source in one line: its title, author, and publisher
+func (s Source) Label() string {
+	label := s.Title
+	if label == "" {
+		label = "(untitled)"
+	}
+	var by []string
+	if s.Author != "" {
+		by = append(by, s.Author)
+	}
+	if s.Publisher != "" {
+		by = append(by, s.Publisher)
+	}
+	if len(by) > 0 {
+		label += " — " + strings.Join(by, ", ")
+	}
+	return label
+}
+
+// displaySources prints the sources cited in a reply
+func displaySources(sources []Source) {
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Println("\n📚 Sources:")
+	for i, src := range sources {
+		fmt.Printf("   [%d] %s\n", i+1, src.Label())
+		if src.URL != "" {
+			fmt.Printf("       %s\n", src.URL)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChatStats summarises the saved chats for people evaluating the assistant
+type ChatStats struct {
+	GeneratedAt string `json:"generated_at"`
+	Since       string `json:"since,omitempty"`
+
+	Chats             int     `json:"chats"`
+	Messages          int     `json:"messages"`
+	UserMessages      int     `json:"user_messages"`
+	AssistantMessages int     `json:"assistant_messages"`
+	MessagesPerChat   float64 `json:"avg_messages_per_chat"`
+
+	AvgQuestionChars   float64 `json:"avg_question_chars"`
+	AvgResponseChars   float64 `json:"avg_response_chars"`
+	AvgResponseWords   float64 `json:"avg_response_words"`
+	AvgSourcesPerReply float64 `json:"avg_sources_per_reply"`
+
+	SuggestionsOffered int     `json:"suggestions_offered"`
+	SuggestionsUsed    int     `json:"suggestions_used"`
+	SuggestionUseRate  float64 `json:"suggestion_use_rate"`
+
+	Topics []TopicCount `json:"topics,omitempty"`
+}
+
+// TopicCount is how many chats were tagged with a topic
+type TopicCount struct {
+	Topic string `json:"topic"`
+	Chats int    `json:"chats"`
+}
+
+const topicPrompt = `You label conversations for an analytics report. Read the user's messages and reply with a JSON array of one to three short, lowercase topic tags (for example ["grief", "prayer"]). Reply with the array only.`
+
+// tagTopics asks the completions API for topic tags describing a chat's
+// questions
+func tagTopics(questions []string) ([]string, error) {
+	token, err := ensureValidToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get valid token: %w", err)
+	}
+
+	payload := map[string]interface{}{
+		"messages": []map[string]string{
+			{"role": "system", "content": topicPrompt},
+			{"role": "user", "content": strings.Join(questions, "\n")},
+		},
+		"auto_routing": true,
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", completionsURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("topic tagging request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newApiError("topic tagging", resp.StatusCode, body)
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("topic tagging returned no choices")
+	}
+	return parseTopics(completion.Choices[0].Message.Content)
+}
+
+// parseTopics reads the JSON array in a tagging reply, ignoring any text
+// the model put around it
+func parseTopics(content string) ([]string, error) {
+	start, end := strings.Index(content, "["), strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no topic list in reply %q", content)
+	}
+	var raw []string
+	if err := json.Unmarshal([]byte(content[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse topics: %w", err)
+	}
+	var topics []string
+	for _, t := range raw {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics, nil
+}
+
+// collectStats fetches each saved chat's history and totals it. Tagging
+// failures are reported on stderr and leave the chat out of Topics.
+func collectStats(sessions []SavedSession, since time.Time, withTopics bool) (*ChatStats, error) {
+	stats := &ChatStats{GeneratedAt: time.Now().UTC().Format(time.RFC3339)}
+	if !since.IsZero() {
+		stats.Since = since.UTC().Format(time.RFC3339)
+	}
+
+	var questionChars, responseChars, responseWords, sources int
+	topicChats := map[string]int{}
+	for _, s := range sessions {
+		fmt.Fprintf(os.Stderr, "Analysing %q...\n", s.Name)
+		history, err := getChatHistoryPage(s.ChatID, HistoryQuery{Since: since})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %q: %w", s.Name, err)
+		}
+		if len(history.Messages) == 0 {
+			continue
+		}
+
+		stats.Chats++
+		// Suggestion counts are only kept per chat, not per message
+		stats.SuggestionsOffered += s.SuggestionsOffered
+		stats.SuggestionsUsed += s.SuggestionsUsed
+
+		var questions []string
+		for _, m := range history.Messages {
+			stats.Messages++
+			switch m.Role {
+			case "user":
+				stats.UserMessages++
+				questionChars += len([]rune(m.Message))
+				questions = append(questions, m.Message)
+			case "assistant":
+				stats.AssistantMessages++
+				responseChars += len([]rune(m.Message))
+				responseWords += len(strings.Fields(m.Message))
+				sources += len(m.Sources)
+			}
+		}
+
+		if withTopics && len(questions) > 0 {
+			topics, err := tagTopics(questions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Could not tag %q: %v\n", s.Name, err)
+				continue
+			}
+			seen := map[string]bool{}
+			for _, t := range topics {
+				if !seen[t] {
+					seen[t] = true
+					topicChats[t]++
+				}
+			}
+		}
+	}
+
+	stats.MessagesPerChat = average(stats.Messages, stats.Chats)
+	stats.AvgQuestionChars = average(questionChars, stats.UserMessages)
+	stats.AvgResponseChars = average(responseChars, stats.AssistantMessages)
+	stats.AvgResponseWords = average(responseWords, stats.AssistantMessages)
+	stats.AvgSourcesPerReply = average(sources, stats.AssistantMessages)
+	stats.SuggestionUseRate = average(stats.SuggestionsUsed, stats.UserMessages)
+
+	for topic, n := range topicChats {
+		stats.Topics = append(stats.Topics, TopicCount{Topic: topic, Chats: n})
+	}
+	sort.Slice(stats.Topics, func(i, j int) bool {
+		if stats.Topics[i].Chats != stats.Topics[j].Chats {
+			return stats.Topics[i].Chats > stats.Topics[j].Chats
+		}
+		return stats.Topics[i].Topic < stats.Topics[j].Topic
+	})
+	return stats, nil
+}
+
+// average returns total/n rounded to two places, or 0 when n is 0
+func average(total, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(int(float64(total)/float64(n)*100+0.5)) / 100
+}
+
+// statsFormats maps each stats format to its renderer
+var statsFormats = map[string]func(io.Writer, *ChatStats) error{
+	"md":   writeStatsMarkdown,
+	"json": writeStatsJSON,
+}
+
+func writeStatsJSON(w io.Writer, stats *ChatStats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
+
+func writeStatsMarkdown(w io.Writer, stats *ChatStats) error {
+	fmt.Fprint(w, "# Chat Statistics\n\n")
+	fmt.Fprintf(w, "Generated %s", formatTimestamp(stats.GeneratedAt))
+	if stats.Since != "" {
+		fmt.Fprintf(w, " from messages since %s", formatTimestamp(stats.Since))
+	}
+	fmt.Fprint(w, ".\n\n")
+
+	fmt.Fprint(w, "| Metric | Value |\n|--------|-------|\n")
+	fmt.Fprintf(w, "| Chats | %d |\n", stats.Chats)
+	fmt.Fprintf(w, "| Messages | %d (%d user, %d assistant) |\n", stats.Messages, stats.UserMessages, stats.AssistantMessages)
+	fmt.Fprintf(w, "| Messages per chat | %.2f |\n", stats.MessagesPerChat)
+	fmt.Fprintf(w, "| Average question length | %.0f characters |\n", stats.AvgQuestionChars)
+	fmt.Fprintf(w, "| Average response length | %.0f characters, %.0f words |\n", stats.AvgResponseChars, stats.AvgResponseWords)
+	fmt.Fprintf(w, "| Sources per reply | %.2f |\n", stats.AvgSourcesPerReply)
+	fmt.Fprintf(w, "| Suggestions offered | %d |\n", stats.SuggestionsOffered)
+	fmt.Fprintf(w, "| Suggestions used | %d (%.0f%% of questions) |\n", stats.SuggestionsUsed, stats.SuggestionUseRate*100)
+
+	if len(stats.Topics) > 0 {
+		fmt.Fprint(w, "\n## Topics\n\n| Topic | Chats |\n|-------|-------|\n")
+		for _, t := range stats.Topics {
+			fmt.Fprintf(w, "| %s | %d |\n", t.Topic, t.Chats)
+		}
+	}
+	return nil
+}
+
+// runStats handles the stats command:
+//
+//	stats [-format md|json] [-o file] [-since when] [-topics=false]
+func runStats(store *SessionStore, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	format := flags.String("format", "md", "output format: md or json")
+	out := flags.String("o", "", "file to write (default stdout)")
+	sinceFlag := flags.String("since", "", "only messages since a date or age (2024-06-01, 24h, 7d)")
+	withTopics := flags.Bool("topics", true, "tag each chat's topics with the completions API")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: go run . stats [-format md|json] [-o file] [-since when] [-topics=false]")
+	}
+
+	render, ok := statsFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q (expected md or json)", *format)
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("no saved chats to analyse")
+	}
+	stats, err := collectStats(sessions, since, *withTopics)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return render(os.Stdout, stats)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := render(f, stats); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Wrote statistics for %d chat(s) to %s\n", stats.Chats, *out)
+	return nil
+}
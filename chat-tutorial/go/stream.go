@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// streamClient has no overall timeout: a long reply can stream for minutes
+var streamClient = &http.Client{}
+
+// messageStreamChunk is one SSE "data:" payload from the message API. A
+// chunk carries either a "delta" or the reply so far in "message", and the
+// final chunk carries the chat ID, suggestions, and sources.
+type messageStreamChunk struct {
+	MessageResponse
+	Delta string `json:"delta"`
+}
+
+// sendMessageStream sends a message with streaming enabled and calls onDelta
+// as each piece of the reply arrives. If the API answers with a regular JSON
+// body instead of an event stream, the whole reply is passed to onDelta at
+// once, so callers don't need to care which they got. Failures before the
+// reply starts are retried as in postMessage, and a reply found in the
+// chat's history is passed to onDelta whole; a stream cut off part way is
+// returned as an error for the caller to resend.
+func sendMessageStream(msg *outgoingMessage, onDelta func(string)) (*MessageResponse, error) {
+	reply, err := msg.alreadyPosted()
+	if reply == nil && err == nil {
+		reply, err = streamMessage(msg, onDelta)
+	} else if reply != nil && onDelta != nil {
+		onDelta(reply.Message)
+	}
+	return reply, err
+}
+
+// streamMessage sends msg with streaming enabled
+func streamMessage(msg *outgoingMessage, onDelta func(string)) (*MessageResponse, error) {
+	token, err := ensureValidToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get valid token: %w", err)
+	}
+
+	payload := newMessageRequest(msg.text, msg.chatID)
+	payload.Stream = true
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", messageURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := doWithRetry(streamClient, req, msg)
+	if err != nil {
+		if reply := postedReply(err); reply != nil {
+			if onDelta != nil {
+				onDelta(reply.Message)
+			}
+			return reply, nil
+		}
+		return nil, fmt.Errorf("message request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newApiError("message sending", resp.StatusCode, body)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var response MessageResponse
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		if onDelta != nil {
+			onDelta(response.Message)
+		}
+		return &response, nil
+	}
+
+	return readMessageStream(resp.Body, onDelta)
+}
+
+// readMessageStream reads an SSE body into a MessageResponse
+func readMessageStream(body io.Reader, onDelta func(string)) (*MessageResponse, error) {
+	response := &MessageResponse{}
+	var reply strings.Builder
+
+	emit := func(text string) {
+		if text == "" {
+			return
+		}
+		reply.WriteString(text)
+		if onDelta != nil {
+			onDelta(text)
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk messageStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		if chunk.Delta != "" {
+			emit(chunk.Delta)
+		} else if chunk.Message != "" {
+			// "message" may hold the reply so far or just the new piece
+			if so := reply.String(); strings.HasPrefix(chunk.Message, so) {
+				emit(chunk.Message[len(so):])
+			} else {
+				emit(chunk.Message)
+			}
+		}
+
+		if chunk.ChatID != "" {
+			response.ChatID = chunk.ChatID
+		}
+		if chunk.QueryID != "" {
+			response.QueryID = chunk.QueryID
+		}
+		if chunk.MessageID != "" {
+			response.MessageID = chunk.MessageID
+		}
+		if chunk.Timestamp != "" {
+			response.Timestamp = chunk.Timestamp
+		}
+		if len(chunk.Suggestions) > 0 {
+			response.Suggestions = chunk.Suggestions
+		}
+		if len(chunk.Sources) > 0 {
+			response.Sources = chunk.Sources
+		}
+		if chunk.Success {
+			response.Success = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	response.Message = reply.String()
+	return response, nil
+}
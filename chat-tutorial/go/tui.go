@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	userStyle     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("39"))
+	aiStyle       = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("42"))
+	dimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	paneStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("238")).Padding(0, 1)
+	chipStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("252")).Background(lipgloss.Color("237")).Padding(0, 1)
+	activeChip    = chipStyle.Copy().Foreground(lipgloss.Color("16")).Background(lipgloss.Color("212"))
+	selectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+)
+
+const tuiHelp = "enter send · tab suggestion · pgup/pgdn scroll · ctrl+n new · ctrl+s sessions · esc quit"
+
+// tuiEntry is one message in the conversation pane
+type tuiEntry struct {
+	role string
+	text string
+}
+
+// replyMsg delivers the result of sendMessage
+type replyMsg struct {
+	msg      *outgoingMessage // the message that was sent
+	response *MessageResponse
+	err      error
+}
+
+// historyMsg delivers a resumed chat's history
+type historyMsg struct {
+	chatID  string // the chat it was loaded for
+	history *ChatHistory
+	err     error
+}
+
+// tuiModel is the Bubble Tea model for the chat screen. It reuses
+// chatSession, so chats started here are saved and can be resumed from the
+// line-based client and vice versa.
+type tuiModel struct {
+	session *chatSession
+	entries []tuiEntry
+	sources []Source // sources of the latest reply, shown in the side panel
+	chip    int      // highlighted suggestion, or -1
+
+	viewport viewport.Model
+	input    textinput.Model
+	waiting  bool
+	status   string
+
+	// Session switcher
+	switching bool
+	saved     []SavedSession
+	cursor    int
+
+	width, height int
+}
+
+// runTUI runs the full-screen chat, resuming a saved chat if one is given
+func runTUI(store *SessionStore, resume *SavedSession) error {
+	// Fetch the token up front: its progress message would otherwise be
+	// written over the interface
+	if _, err := ensureValidToken(); err != nil {
+		return err
+	}
+
+	input := textinput.New()
+	input.Placeholder = "Type a message…"
+	input.CharLimit = 2000
+	input.Focus()
+
+	m := &tuiModel{
+		session:  &chatSession{store: store},
+		chip:     -1,
+		viewport: viewport.New(0, 0),
+		input:    input,
+	}
+	m.resume(resume)
+
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+// resume switches to a saved chat; its history loads in Init or Update
+func (m *tuiModel) resume(saved *SavedSession) {
+	m.session.chatID = ""
+	m.session.failed = nil
+	m.session.saved = nil
+	m.session.suggestions = nil
+	m.entries = nil
+	m.sources = nil
+	m.chip = -1
+	if saved != nil {
+		m.session.chatID = saved.ChatID
+		m.session.saved = saved
+		m.status = fmt.Sprintf("Loading %q…", saved.Name)
+	}
+}
+
+func (m *tuiModel) loadHistory() tea.Cmd {
+	if m.session.chatID == "" {
+		return nil
+	}
+	chatID := m.session.chatID
+	return func() tea.Msg {
+		history, err := getChatHistory(chatID)
+		return historyMsg{chatID: chatID, history: history, err: err}
+	}
+}
+
+func sendCmd(msg *outgoingMessage) tea.Cmd {
+	return func() tea.Msg {
+		response, err := postMessage(msg)
+		return replyMsg{msg: msg, response: response, err: err}
+	}
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, m.loadHistory())
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		m.refresh()
+		return m, nil
+
+	case replyMsg:
+		m.waiting = false
+		m.status = ""
+		m.session.settle(msg.msg, msg.err)
+		if msg.err != nil {
+			// Restore the message so enter resends it, checking first
+			// whether it was posted anyway
+			m.status = "❌ " + msg.err.Error() + " (press enter to retry)"
+			if n := len(m.entries); n > 0 && m.entries[n-1].role == "user" && m.entries[n-1].text == msg.msg.text {
+				m.entries = m.entries[:n-1]
+			}
+			m.input.SetValue(msg.msg.text)
+			m.input.CursorEnd()
+			m.refresh()
+			return m, nil
+		}
+		if m.session.chatID == "" {
+			m.session.chatID = msg.response.ChatID
+		}
+		if note, err := m.session.record(msg.msg.text, msg.response); err != nil {
+			m.status = "⚠️  Could not save chat: " + err.Error()
+		} else if note != "" {
+			m.status = note
+		}
+		m.entries = append(m.entries, tuiEntry{role: "assistant", text: msg.response.Message})
+		m.sources = msg.response.Sources
+		m.session.suggestions = msg.response.Suggestions
+		m.chip = -1
+		m.refresh()
+		return m, nil
+
+	case historyMsg:
+		if msg.chatID != m.session.chatID {
+			// Loaded for a chat that has since been switched away from
+			return m, nil
+		}
+		m.status = ""
+		if msg.err != nil {
+			m.status = "❌ " + msg.err.Error()
+			return m, nil
+		}
+		m.entries = nil
+		for _, message := range msg.history.Messages {
+			m.entries = append(m.entries, tuiEntry{role: message.Role, text: message.Message})
+			if message.Role == "assistant" {
+				m.sources = message.Sources
+			}
+		}
+		m.refresh()
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.switching {
+			return m.updateSwitcher(msg)
+		}
+		if key := msg.String(); m.waiting && (key == "ctrl+n" || key == "ctrl+s") {
+			// The pending reply belongs to the current chat
+			m.status = "Wait for the reply before switching chats"
+			return m, nil
+		}
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "ctrl+n":
+			m.resume(nil)
+			m.session.name = ""
+			m.status = "Started a new conversation"
+			m.refresh()
+			return m, nil
+		case "ctrl+s":
+			saved, err := m.session.store.Load()
+			if err != nil {
+				m.status = "❌ " + err.Error()
+				return m, nil
+			}
+			m.saved, m.cursor, m.switching = saved, 0, true
+			return m, nil
+		case "tab":
+			if n := len(m.session.suggestions); n > 0 {
+				m.chip = (m.chip + 1) % n
+				m.input.SetValue(m.session.suggestions[m.chip])
+				m.input.CursorEnd()
+			}
+			return m, nil
+		case "enter":
+			text := strings.TrimSpace(m.input.Value())
+			if text == "" || m.waiting {
+				return m, nil
+			}
+			m.entries = append(m.entries, tuiEntry{role: "user", text: text})
+			m.input.Reset()
+			m.waiting = true
+			m.status = "Thinking…"
+			m.refresh()
+			return m, sendCmd(m.session.outgoing(text))
+		case "pgup", "pgdown", "up", "down":
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// updateSwitcher handles keys while the session switcher is open
+func (m *tuiModel) updateSwitcher(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "ctrl+s":
+		m.switching = false
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.saved)-1 {
+			m.cursor++
+		}
+	case "enter":
+		m.switching = false
+		if len(m.saved) == 0 {
+			return m, nil
+		}
+		selected := m.saved[m.cursor]
+		m.resume(&selected)
+		m.refresh()
+		return m, m.loadHistory()
+	}
+	return m, nil
+}
+
+// layout sizes the panes to the window
+func (m *tuiModel) layout() {
+	convWidth := m.width * 2 / 3
+	// Header, suggestions, input (3 lines with its border), status, help
+	bodyHeight := m.height - 7
+	if bodyHeight < 3 {
+		bodyHeight = 3
+	}
+	m.viewport.Width = max(convWidth-4, 10)
+	m.viewport.Height = max(bodyHeight-2, 1)
+	m.input.Width = max(m.width-6, 10)
+}
+
+// refresh re-renders the conversation into the viewport
+func (m *tuiModel) refresh() {
+	wrap := lipgloss.NewStyle().Width(m.viewport.Width)
+	var b strings.Builder
+	if len(m.entries) == 0 {
+		b.WriteString(dimStyle.Render("Ask anything to start a conversation."))
+	}
+	for i, e := range m.entries {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		if e.role == "user" {
+			b.WriteString(userStyle.Render("You"))
+		} else {
+			b.WriteString(aiStyle.Render("AI"))
+		}
+		b.WriteString("\n")
+		b.WriteString(wrap.Render(e.text))
+	}
+	m.viewport.SetContent(b.String())
+	m.viewport.GotoBottom()
+}
+
+func (m *tuiModel) sourcesView(width int) string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Sources"))
+	if len(m.sources) == 0 {
+		b.WriteString("\n" + dimStyle.Render("None cited yet"))
+	}
+	wrap := lipgloss.NewStyle().Width(width)
+	for i, src := range m.sources {
+		b.WriteString(fmt.Sprintf("\n\n%d. %s", i+1, wrap.Render(src.Label())))
+		if src.URL != "" {
+			b.WriteString("\n" + dimStyle.Render(wrap.Render(src.URL)))
+		}
+	}
+	return b.String()
+}
+
+func (m *tuiModel) chipsView() string {
+	if len(m.session.suggestions) == 0 {
+		return dimStyle.Render("No suggestions")
+	}
+	var chips []string
+	for i, s := range m.session.suggestions {
+		style := chipStyle
+		if i == m.chip {
+			style = activeChip
+		}
+		chips = append(chips, style.Render(s))
+	}
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(strings.Join(chips, " "))
+}
+
+func (m *tuiModel) switcherView() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Saved chats") + dimStyle.Render("  ↑/↓ choose · enter open · esc cancel") + "\n\n")
+	if len(m.saved) == 0 {
+		b.WriteString(dimStyle.Render("No saved chats yet"))
+	}
+	for i, s := range m.saved {
+		line := fmt.Sprintf("%-24s %3d messages   %s", s.Name, s.Messages, s.UpdatedAt.Local().Format("2006-01-02 15:04"))
+		if i == m.cursor {
+			b.WriteString(selectedStyle.Render("› "+line) + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return paneStyle.Width(max(m.width-2, 10)).Height(max(m.height-4, 3)).Render(b.String())
+}
+
+func (m *tuiModel) View() string {
+	if m.width == 0 {
+		return "Loading…"
+	}
+
+	name := "new chat"
+	if m.session.saved != nil {
+		name = m.session.saved.Name
+	}
+	header := titleStyle.Render("Gloo AI Chat") + dimStyle.Render(" · "+name)
+	if m.switching {
+		return lipgloss.JoinVertical(lipgloss.Left, header, m.switcherView(), dimStyle.Render(tuiHelp))
+	}
+
+	convWidth := m.width * 2 / 3
+	sideWidth := m.width - convWidth
+	bodyHeight := m.viewport.Height
+	conversation := paneStyle.Width(convWidth - 2).Height(bodyHeight).Render(m.viewport.View())
+	side := paneStyle.Width(max(sideWidth-2, 10)).Height(bodyHeight).Render(m.sourcesView(max(sideWidth-4, 8)))
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		header,
+		lipgloss.JoinHorizontal(lipgloss.Top, conversation, side),
+		m.chipsView(),
+		paneStyle.Width(max(m.width-2, 10)).Render(m.input.View()),
+		m.status,
+		dimStyle.Render(tuiHelp),
+	)
+}
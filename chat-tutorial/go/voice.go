@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// SpeechToText turns a WAV recording into text
+type SpeechToText interface {
+	Transcribe(wavPath string) (string, error)
+}
+
+// TextToSpeech reads a reply aloud
+type TextToSpeech interface {
+	Speak(text string) error
+}
+
+// sttBackends are the speech-to-text backends selectable with -stt
+var sttBackends = map[string]func() (SpeechToText, error){
+	"whisper": newWhisperSTT,
+	"command": newCommandSTT,
+}
+
+// ttsBackends are the text-to-speech backends selectable with -tts
+var ttsBackends = map[string]func() (TextToSpeech, error){
+	"say":     func() (TextToSpeech, error) { return newProgramTTS("say") },
+	"espeak":  func() (TextToSpeech, error) { return newProgramTTS("espeak-ng", "espeak") },
+	"command": newCommandTTS,
+	"none":    func() (TextToSpeech, error) { return silentTTS{}, nil },
+}
+
+// voiceIO records questions and speaks replies in the chat
+type voiceIO struct {
+	record []string // recorder command; {file} is replaced with the WAV path
+	stt    SpeechToText
+	tts    TextToSpeech
+}
+
+// newVoiceIO sets up voice mode with the named backends. An empty name
+// picks a backend from what's configured and installed.
+func newVoiceIO(sttName, ttsName string) (*voiceIO, error) {
+	if sttName == "" {
+		sttName = defaultSTT()
+	}
+	if ttsName == "" {
+		ttsName = defaultTTS()
+	}
+	newSTT, ok := sttBackends[sttName]
+	if !ok {
+		return nil, fmt.Errorf("unknown speech-to-text backend %q (expected %s)", sttName, backendNames(sttBackends))
+	}
+	newTTS, ok := ttsBackends[ttsName]
+	if !ok {
+		return nil, fmt.Errorf("unknown text-to-speech backend %q (expected %s)", ttsName, backendNames(ttsBackends))
+	}
+
+	record, err := recorderCommand()
+	if err != nil {
+		return nil, err
+	}
+	stt, err := newSTT()
+	if err != nil {
+		return nil, err
+	}
+	tts, err := newTTS()
+	if err != nil {
+		return nil, err
+	}
+	return &voiceIO{record: record, stt: stt, tts: tts}, nil
+}
+
+func backendNames[T any](backends map[string]T) string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// defaultSTT uses VOICE_STT if set, then a transcription command if one is
+// configured, then the Whisper API
+func defaultSTT() string {
+	if name := os.Getenv("VOICE_STT"); name != "" {
+		return name
+	}
+	if os.Getenv("VOICE_STT_COMMAND") != "" {
+		return "command"
+	}
+	return "whisper"
+}
+
+// defaultTTS uses VOICE_TTS if set, then a configured command, then the
+// system's speech program, and stays silent if there is none
+func defaultTTS() string {
+	if name := os.Getenv("VOICE_TTS"); name != "" {
+		return name
+	}
+	if os.Getenv("VOICE_TTS_COMMAND") != "" {
+		return "command"
+	}
+	if _, err := exec.LookPath("say"); err == nil && runtime.GOOS == "darwin" {
+		return "say"
+	}
+	for _, program := range []string{"espeak-ng", "espeak"} {
+		if _, err := exec.LookPath(program); err == nil {
+			return "espeak"
+		}
+	}
+	return "none"
+}
+
+// recorderCommand returns VOICE_RECORD_COMMAND, or a 16 kHz mono recording
+// with SoX or ALSA's arecord. Recording stops when the command is
+// interrupted.
+func recorderCommand() ([]string, error) {
+	if command := os.Getenv("VOICE_RECORD_COMMAND"); command != "" {
+		return strings.Fields(command), nil
+	}
+	if _, err := exec.LookPath("rec"); err == nil {
+		return []string{"rec", "-q", "-c", "1", "-r", "16000", "-b", "16", "{file}"}, nil
+	}
+	if _, err := exec.LookPath("arecord"); err == nil {
+		return []string{"arecord", "-q", "-f", "S16_LE", "-c", "1", "-r", "16000", "{file}"}, nil
+	}
+	return nil, fmt.Errorf("no audio recorder found: install SoX (rec) or arecord, or set VOICE_RECORD_COMMAND")
+}
+
+// withFile replaces {file} in a command's arguments
+func withFile(command []string, path string) []string {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = strings.ReplaceAll(arg, "{file}", path)
+	}
+	return args
+}
+
+// listen records until the user presses Enter, then transcribes the
+// recording
+func (v *voiceIO) listen(scanner *bufio.Scanner) (string, error) {
+	dir, err := os.MkdirTemp("", "gloo-voice")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "question.wav")
+
+	args := withFile(v.record, path)
+	cmd := exec.Command(args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start recording: %w", err)
+	}
+	fmt.Print("🎙️  Listening... press Enter when you're done ")
+	scanner.Scan()
+
+	// Recorders finish the WAV file when interrupted; Windows can't send
+	// an interrupt, so fall back to stopping the process
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		cmd.Process.Kill()
+	}
+	cmd.Wait()
+
+	// A WAV header alone is 44 bytes
+	if info, err := os.Stat(path); err != nil || info.Size() <= 44 {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return "", fmt.Errorf("nothing was recorded: %s", detail)
+		}
+		return "", fmt.Errorf("nothing was recorded")
+	}
+	return v.stt.Transcribe(path)
+}
+
+// speak reads a reply aloud. Failures are reported but don't end the chat.
+func (v *voiceIO) speak(text string) {
+	if err := v.tts.Speak(speakable(text)); err != nil {
+		fmt.Printf("⚠️  Could not speak the reply: %v\n", err)
+	}
+}
+
+var markdownMarks = regexp.MustCompile("[*_`#>]+")
+
+// speakable strips Markdown so it isn't read out
+func speakable(text string) string {
+	return strings.TrimSpace(markdownMarks.ReplaceAllString(text, ""))
+}
+
+// whisperSTT transcribes with an OpenAI-compatible transcription API
+type whisperSTT struct {
+	url, model, apiKey string
+}
+
+// newWhisperSTT reads OPENAI_API_KEY, and optionally VOICE_STT_URL for a
+// compatible server and VOICE_STT_MODEL
+func newWhisperSTT() (SpeechToText, error) {
+	stt := &whisperSTT{
+		url:    "https://api.openai.com/v1/audio/transcriptions",
+		model:  "whisper-1",
+		apiKey: os.Getenv("OPENAI_API_KEY"),
+	}
+	if url := os.Getenv("VOICE_STT_URL"); url != "" {
+		stt.url = url
+	} else if stt.apiKey == "" {
+		return nil, fmt.Errorf("set OPENAI_API_KEY (or VOICE_STT_URL for a local server) to transcribe with Whisper, or use -stt command")
+	}
+	if model := os.Getenv("VOICE_STT_MODEL"); model != "" {
+		stt.model = model
+	}
+	return stt, nil
+}
+
+func (w *whisperSTT) Transcribe(wavPath string) (string, error) {
+	audio, err := os.ReadFile(wavPath)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", w.model)
+	part, err := form.CreateFormFile("file", filepath.Base(wavPath))
+	if err != nil {
+		return "", err
+	}
+	part.Write(audio)
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", w.url, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if w.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.apiKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse transcription: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// commandSTT runs VOICE_STT_COMMAND, such as a local whisper.cpp build,
+// and reads the transcript from its output
+type commandSTT struct {
+	command []string
+}
+
+func newCommandSTT() (SpeechToText, error) {
+	command := strings.Fields(os.Getenv("VOICE_STT_COMMAND"))
+	if len(command) == 0 {
+		return nil, fmt.Errorf("set VOICE_STT_COMMAND to a transcription command, with {file} for the recording")
+	}
+	return &commandSTT{command: command}, nil
+}
+
+func (c *commandSTT) Transcribe(wavPath string) (string, error) {
+	args := withFile(c.command, wavPath)
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("transcription command failed: %w", err)
+	}
+	return strings.Join(strings.Fields(string(out)), " "), nil
+}
+
+// programTTS speaks with a system program that takes the text as an
+// argument, such as say on macOS or espeak on Linux
+type programTTS struct {
+	program string
+}
+
+// newProgramTTS uses the first of programs that is installed
+func newProgramTTS(programs ...string) (TextToSpeech, error) {
+	for _, program := range programs {
+		if path, err := exec.LookPath(program); err == nil {
+			return &programTTS{program: path}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not installed", strings.Join(programs, " or "))
+}
+
+func (p *programTTS) Speak(text string) error {
+	return exec.Command(p.program, text).Run()
+}
+
+// commandTTS pipes the text to VOICE_TTS_COMMAND on stdin
+type commandTTS struct {
+	command []string
+}
+
+func newCommandTTS() (TextToSpeech, error) {
+	command := strings.Fields(os.Getenv("VOICE_TTS_COMMAND"))
+	if len(command) == 0 {
+		return nil, fmt.Errorf("set VOICE_TTS_COMMAND to a command that speaks text read from stdin")
+	}
+	return &commandTTS{command: command}, nil
+}
+
+func (c *commandTTS) Speak(text string) error {
+	cmd := exec.Command(c.command[0], c.command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// silentTTS leaves replies on screen only
+type silentTTS struct{}
+
+func (silentTTS) Speak(string) error { return nil }
+
+// runVoice handles the voice command, which chats hands-free. A name
+// resumes that saved chat, or names a new one.
+//
+//	voice [name] [-stt whisper|command] [-tts say|espeak|command|none]
+func runVoice(store *SessionStore, args []string) error {
+	flags := flag.NewFlagSet("voice", flag.ContinueOnError)
+	sttName := flags.String("stt", "", "speech-to-text backend: "+backendNames(sttBackends)+" (default from VOICE_STT or what's configured)")
+	ttsName := flags.String("tts", "", "text-to-speech backend: "+backendNames(ttsBackends)+" (default from VOICE_TTS or what's installed)")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("usage: go run . voice [name] [-stt backend] [-tts backend]")
+	}
+
+	voice, err := newVoiceIO(*sttName, *ttsName)
+	if err != nil {
+		return err
+	}
+
+	var resume *SavedSession
+	name := ""
+	if len(positional) == 1 {
+		name = positional[0]
+		if saved, err := store.Get(name); err == nil {
+			resume, name = saved, ""
+		}
+	}
+	runChat(os.Stdin, store, resume, name, voice)
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Claim verdicts from checkClaims
+const (
+	verdictSupported    = "supported"
+	verdictContradicted = "contradicted"
+	verdictUnverified   = "unverified"
+)
+
+// claimsPrompt asks the model to list the factual claims in an answer
+const claimsPrompt = `You extract factual claims from text. List each specific, checkable factual claim in the user's text as a short standalone sentence. Skip opinions, advice, and hedges.
+Reply with only a JSON object of this form:
+{"claims": ["claim one", "claim two"]}`
+
+// verifyPrompt asks the model to check claims against grounded evidence
+const verifyPrompt = `You fact-check claims against reference material. For each numbered claim, decide whether the reference supports it, contradicts it, or doesn't mention it. Use only the reference, not your own knowledge.
+Reply with only a JSON object of this form:
+{"verdicts": [{"claim": 1, "verdict": "supported|contradicted|unverified", "correction": "what the reference says instead, if contradicted", "source": "title of the source used, if any"}]}`
+
+// ClaimCheck is one claim from the non-grounded answer and how it compares
+// with the grounded answer and its sources
+type ClaimCheck struct {
+	Claim      string
+	Verdict    string
+	Correction string
+	Source     string
+}
+
+// HallucinationReport compares a non-grounded answer with a grounded one
+type HallucinationReport struct {
+	Checks []ClaimCheck
+}
+
+// Count returns the number of claims with the given verdict
+func (r *HallucinationReport) Count(verdict string) int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Verdict == verdict {
+			n++
+		}
+	}
+	return n
+}
+
+// completeJSON sends a system prompt and user message at temperature 0 and
+// decodes the JSON reply into out
+func completeJSON(system, user string, maxTokens int, out interface{}) error {
+	payload := CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		AutoRouting: true,
+		GenerationParams: GenerationParams{
+			Temperature: floatPtr(0),
+			MaxTokens:   intPtr(maxTokens),
+		},
+	}
+	response, err := postCompletion(context.Background(), completionsURL, payload)
+	if err != nil {
+		return err
+	}
+	if len(response.Choices) == 0 {
+		return fmt.Errorf("no choices in response")
+	}
+
+	content := strings.TrimSpace(response.Choices[0].Message.Content)
+	content = strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```")
+	content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+	if err := json.Unmarshal([]byte(content), out); err != nil {
+		return fmt.Errorf("failed to parse reply: %w", err)
+	}
+	return nil
+}
+
+// extractClaims lists the factual claims in answer
+func extractClaims(answer string) ([]string, error) {
+	var reply struct {
+		Claims []string `json:"claims"`
+	}
+	if err := completeJSON(claimsPrompt, answer, 800, &reply); err != nil {
+		return nil, fmt.Errorf("claim extraction failed: %w", err)
+	}
+
+	var claims []string
+	for _, c := range reply.Claims {
+		if c = strings.TrimSpace(c); c != "" {
+			claims = append(claims, c)
+		}
+	}
+	return claims, nil
+}
+
+// referenceText formats a grounded answer and its sources as the evidence
+// claims are checked against
+func referenceText(grounded *CompletionResponse) string {
+	var b strings.Builder
+	b.WriteString("Grounded answer:\n")
+	b.WriteString(grounded.Choices[0].Message.Content)
+	for i, src := range grounded.Sources {
+		fmt.Fprintf(&b, "\n\nSource %d: %s", i+1, src.Title)
+		if src.Snippet != "" {
+			fmt.Fprintf(&b, "\n%s", src.Snippet)
+		}
+	}
+	return b.String()
+}
+
+// checkClaims checks each claim against the grounded answer and sources.
+// Claims the model leaves out of its reply are reported as unverified.
+func checkClaims(claims []string, grounded *CompletionResponse) ([]ClaimCheck, error) {
+	var numbered strings.Builder
+	for i, c := range claims {
+		fmt.Fprintf(&numbered, "%d. %s\n", i+1, c)
+	}
+	user := fmt.Sprintf("Reference:\n%s\n\nClaims:\n%s", referenceText(grounded), numbered.String())
+
+	var reply struct {
+		Verdicts []struct {
+			Claim      int    `json:"claim"`
+			Verdict    string `json:"verdict"`
+			Correction string `json:"correction"`
+			Source     string `json:"source"`
+		} `json:"verdicts"`
+	}
+	if err := completeJSON(verifyPrompt, user, 1500, &reply); err != nil {
+		return nil, fmt.Errorf("claim check failed: %w", err)
+	}
+
+	checks := make([]ClaimCheck, len(claims))
+	for i, c := range claims {
+		checks[i] = ClaimCheck{Claim: c, Verdict: verdictUnverified}
+	}
+	for _, v := range reply.Verdicts {
+		if v.Claim < 1 || v.Claim > len(claims) {
+			continue
+		}
+		check := &checks[v.Claim-1]
+		switch strings.ToLower(strings.TrimSpace(v.Verdict)) {
+		case verdictSupported:
+			check.Verdict = verdictSupported
+		case verdictContradicted:
+			check.Verdict = verdictContradicted
+			check.Correction = v.Correction
+		}
+		check.Source = v.Source
+	}
+	return checks, nil
+}
+
+// analyzeHallucinations extracts the factual claims from the non-grounded
+// answer and checks them against the grounded answer and its sources
+func analyzeHallucinations(nonGrounded, grounded *CompletionResponse) (*HallucinationReport, error) {
+	if len(nonGrounded.Choices) == 0 || len(grounded.Choices) == 0 {
+		return nil, fmt.Errorf("both answers are needed for the comparison")
+	}
+
+	claims, err := extractClaims(nonGrounded.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	if len(claims) == 0 {
+		return &HallucinationReport{}, nil
+	}
+
+	checks, err := checkClaims(claims, grounded)
+	if err != nil {
+		return nil, err
+	}
+	return &HallucinationReport{Checks: checks}, nil
+}
+
+// displayHallucinationReport prints each claim marked with its verdict,
+// followed by the correction for any the grounded answer contradicts
+func displayHallucinationReport(r *HallucinationReport) {
+	fmt.Println("\n🔎 What Grounding Changed:")
+	if len(r.Checks) == 0 {
+		fmt.Println("   No factual claims found in the non-grounded answer")
+		return
+	}
+
+	for _, c := range r.Checks {
+		switch c.Verdict {
+		case verdictSupported:
+			fmt.Printf("   ✓ %s\n", c.Claim)
+		case verdictContradicted:
+			fmt.Printf("   ✗ %s\n", c.Claim)
+			if c.Correction != "" {
+				fmt.Printf("     → %s\n", c.Correction)
+			}
+		default:
+			fmt.Printf("   ? %s\n", c.Claim)
+		}
+		if c.Source != "" && c.Verdict != verdictUnverified {
+			fmt.Printf("     Source: %s\n", c.Source)
+		}
+	}
+
+	fmt.Printf("\n   %d supported, %d corrected, %d not covered by your content\n",
+		r.Count(verdictSupported), r.Count(verdictContradicted), r.Count(verdictUnverified))
+	fmt.Println("   (✓ supported  ✗ corrected by grounding  ? unverified)")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DemoConfig is the content the comparison demo runs against. It is read
+// from a JSON file so you can try your own publisher without editing code.
+type DemoConfig struct {
+	Publisher    string   `json:"publisher,omitempty"`
+	Queries      []string `json:"queries,omitempty"`
+	SourcesLimit int      `json:"sources_limit,omitempty"`
+	MaxTokens    int      `json:"max_tokens,omitempty"`
+}
+
+// defaultConfigPath is read if present when -config isn't given
+const defaultConfigPath = "grounded.json"
+
+// defaultDemoConfig returns the settings used when no config file is found
+func defaultDemoConfig() DemoConfig {
+	return DemoConfig{
+		Queries: []string{
+			"What is Bezalel Ministries' hiring process?",
+			"What educational resources does Bezalel Ministries provide?",
+			"Describe Bezalel's research methodology for creating artwork.",
+		},
+		SourcesLimit: 3,
+		MaxTokens:    500,
+	}
+}
+
+// loadDemoConfig reads a config file over the defaults; fields left out of
+// the file keep their default values. A missing file is an error only if
+// required is set, so the default path can be absent.
+func loadDemoConfig(path string, required bool) (DemoConfig, error) {
+	config := defaultDemoConfig()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	var file DemoConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return config, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.Publisher != "" {
+		config.Publisher = file.Publisher
+	}
+	if len(file.Queries) > 0 {
+		config.Queries = file.Queries
+	}
+	if file.SourcesLimit != 0 {
+		config.SourcesLimit = file.SourcesLimit
+	}
+	if file.MaxTokens != 0 {
+		config.MaxTokens = file.MaxTokens
+	}
+	return config, config.Validate()
+}
+
+// Validate reports settings the API would reject
+func (c DemoConfig) Validate() error {
+	if c.SourcesLimit < 1 {
+		return fmt.Errorf("sources_limit must be at least 1, got %d", c.SourcesLimit)
+	}
+	if c.MaxTokens < 1 {
+		return fmt.Errorf("max_tokens must be at least 1, got %d", c.MaxTokens)
+	}
+	if len(c.Queries) == 0 {
+		return fmt.Errorf("no queries to run")
+	}
+	return nil
+}
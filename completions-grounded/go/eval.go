@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// evalModes are the grounding strategies compared by an evaluation run
+var evalModes = []string{modeNone, modeDefault, modePublisher}
+
+// EvalQuestion is one row of the evaluation CSV
+type EvalQuestion struct {
+	Question string
+	Expected string
+}
+
+// EvalResult is the answer and score for one question and grounding mode
+type EvalResult struct {
+	Mode       string
+	Answer     string
+	Sources    int
+	Latency    time.Duration
+	Exact      bool
+	JudgeScore int // 1-5; 0 when not judged
+	JudgeNote  string
+	Err        error
+}
+
+// readEvalQuestions reads a CSV with a "question" column and an optional
+// "expected" column. Other columns are ignored.
+func readEvalQuestions(path string) ([]EvalQuestion, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header from %s: %w", path, err)
+	}
+
+	questionCol, expectedCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "question":
+			questionCol = i
+		case "expected":
+			expectedCol = i
+		}
+	}
+	if questionCol < 0 {
+		return nil, fmt.Errorf("%s has no \"question\" column", path)
+	}
+
+	var questions []EvalQuestion
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		q := EvalQuestion{Question: strings.TrimSpace(field(record, questionCol))}
+		if q.Question == "" {
+			continue
+		}
+		if expectedCol >= 0 {
+			q.Expected = strings.TrimSpace(field(record, expectedCol))
+		}
+		questions = append(questions, q)
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("%s contains no questions", path)
+	}
+	return questions, nil
+}
+
+func field(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+// normalizeAnswer lowercases text and collapses punctuation and whitespace,
+// so exact matching ignores formatting
+func normalizeAnswer(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// exactMatch reports whether the expected answer appears in the answer,
+// ignoring case, punctuation, and whitespace
+func exactMatch(answer, expected string) bool {
+	expected = normalizeAnswer(expected)
+	return expected != "" && strings.Contains(" "+normalizeAnswer(answer)+" ", " "+expected+" ")
+}
+
+// judgePrompt asks the model to grade an answer against the expected one
+const judgePrompt = `You are grading answers to questions. Compare the candidate answer with the expected answer.
+Reply with only a JSON object of this form:
+{"score": 3, "reason": "one short sentence"}
+Score 1 (wrong or unrelated) to 5 (fully correct and consistent with the expected answer).`
+
+// JudgeResult is the judge model's grade for one answer
+type JudgeResult struct {
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+// judgeAnswer grades answer against expected with the judge prompt.
+// Temperature 0 keeps grades stable between runs.
+func judgeAnswer(question, expected, answer string) (*JudgeResult, error) {
+	user := fmt.Sprintf("Question: %s\n\nExpected answer: %s\n\nCandidate answer: %s", question, expected, answer)
+	var result JudgeResult
+	if err := completeJSON(judgePrompt, user, 200, &result); err != nil {
+		return nil, fmt.Errorf("judge request failed: %w", err)
+	}
+	if result.Score < 1 || result.Score > 5 {
+		return nil, fmt.Errorf("judge score %d out of range", result.Score)
+	}
+	return &result, nil
+}
+
+// EvalOptions control an evaluation run
+type EvalOptions struct {
+	Publisher    string
+	SourcesLimit int
+	Concurrency  int
+	Judge        bool
+}
+
+// evaluateOne answers q with one grounding mode and scores the answer
+func evaluateOne(q EvalQuestion, mode string, opts EvalOptions) EvalResult {
+	result := EvalResult{Mode: mode}
+
+	start := time.Now()
+	response, err := askGrounded(context.Background(), mode, q.Question, opts.Publisher, opts.SourcesLimit)
+	result.Latency = time.Since(start)
+	if err == nil && len(response.Choices) == 0 {
+		err = fmt.Errorf("no choices in response")
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Answer = response.Choices[0].Message.Content
+	result.Sources = len(response.Sources)
+	if q.Expected == "" {
+		return result
+	}
+
+	result.Exact = exactMatch(result.Answer, q.Expected)
+	if opts.Judge {
+		judged, err := judgeAnswer(q.Question, q.Expected, result.Answer)
+		if err != nil {
+			result.JudgeNote = err.Error()
+		} else {
+			result.JudgeScore = judged.Score
+			result.JudgeNote = judged.Reason
+		}
+	}
+	return result
+}
+
+// runEvaluation answers every question with every grounding mode, running
+// up to opts.Concurrency requests at once. results[i][j] is question i
+// answered with evalModes[j].
+func runEvaluation(questions []EvalQuestion, opts EvalOptions) [][]EvalResult {
+	results := make([][]EvalResult, len(questions))
+	for i := range results {
+		results[i] = make([]EvalResult, len(evalModes))
+	}
+
+	type job struct{ question, mode int }
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	var progress sync.Mutex
+	done := 0
+
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				r := evaluateOne(questions[j.question], evalModes[j.mode], opts)
+				results[j.question][j.mode] = r
+
+				progress.Lock()
+				done++
+				status := "✓"
+				if r.Err != nil {
+					status = "✗"
+				}
+				fmt.Printf("   %s [%d/%d] Q%d %s\n", status, done, len(questions)*len(evalModes), j.question+1, r.Mode)
+				progress.Unlock()
+			}
+		}()
+	}
+
+	for i, q := range questions {
+		// Screen once per question rather than once per mode
+		if err := screenInput(q.Question); err != nil {
+			for m, mode := range evalModes {
+				results[i][m] = EvalResult{Mode: mode, Err: err}
+			}
+			fmt.Printf("   ✗ Q%d %v\n", i+1, err)
+			continue
+		}
+		for m := range evalModes {
+			jobs <- job{question: i, mode: m}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// writeEvalReport writes a Markdown comparison report: a summary per
+// grounding mode followed by every answer
+func writeEvalReport(w io.Writer, questions []EvalQuestion, results [][]EvalResult, opts EvalOptions) {
+	fmt.Fprintln(w, "# Grounded Completions Evaluation")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Publisher: %s | Sources limit: %d | Questions: %d | LLM judge: %v\n\n",
+		opts.Publisher, opts.SourcesLimit, len(questions), opts.Judge)
+
+	fmt.Fprintln(w, "## Summary")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Mode | Answered | Errors | Exact match | Avg judge score | Avg sources | Avg latency |")
+	fmt.Fprintln(w, "|------|----------|--------|-------------|-----------------|-------------|-------------|")
+	for m, mode := range evalModes {
+		var answered, errs, scored, exact, judged, judgeTotal, sources int
+		var latency time.Duration
+		for i, q := range questions {
+			r := results[i][m]
+			if r.Err != nil {
+				errs++
+				continue
+			}
+			answered++
+			sources += r.Sources
+			latency += r.Latency
+			if q.Expected != "" {
+				scored++
+				if r.Exact {
+					exact++
+				}
+			}
+			if r.JudgeScore > 0 {
+				judged++
+				judgeTotal += r.JudgeScore
+			}
+		}
+		fmt.Fprintf(w, "| %s | %d | %d | %s | %s | %s | %s |\n", mode, answered, errs,
+			ratio(exact, scored), average(judgeTotal, judged), average(sources, answered), averageLatency(latency, answered))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "## Answers")
+	fmt.Fprintln(w)
+	for i, q := range questions {
+		fmt.Fprintf(w, "### Q%d. %s\n\n", i+1, q.Question)
+		if q.Expected != "" {
+			fmt.Fprintf(w, "**Expected:** %s\n\n", q.Expected)
+		}
+		for _, r := range results[i] {
+			fmt.Fprintf(w, "#### %s\n\n", r.Mode)
+			if r.Err != nil {
+				fmt.Fprintf(w, "Error: %v\n\n", r.Err)
+				continue
+			}
+			fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(r.Answer))
+			fmt.Fprintf(w, "_Sources: %d | Latency: %s", r.Sources, r.Latency.Round(time.Millisecond))
+			if q.Expected != "" {
+				fmt.Fprintf(w, " | Exact match: %v", r.Exact)
+			}
+			if r.JudgeScore > 0 {
+				fmt.Fprintf(w, " | Judge: %d/5 (%s)", r.JudgeScore, r.JudgeNote)
+			} else if r.JudgeNote != "" {
+				fmt.Fprintf(w, " | Judge failed: %s", r.JudgeNote)
+			}
+			fmt.Fprint(w, "_\n\n")
+		}
+	}
+}
+
+func ratio(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
+func average(sum, n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", float64(sum)/float64(n))
+}
+
+func averageLatency(total time.Duration, n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return (total / time.Duration(n)).Round(time.Millisecond).String()
+}
+
+// runEvalCommand evaluates the questions in csvPath and writes the report
+// to outPath, or to stdout if outPath is empty
+func runEvalCommand(csvPath, outPath string, opts EvalOptions) error {
+	questions, err := readEvalQuestions(csvPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Println("  GROUNDED COMPLETIONS EVALUATION")
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Printf("\n%d questions x %d modes (%s), %d at a time\n\n",
+		len(questions), len(evalModes), strings.Join(evalModes, ", "), opts.Concurrency)
+
+	results := runEvaluation(questions, opts)
+
+	if outPath == "" {
+		fmt.Println()
+		writeEvalReport(os.Stdout, questions, results, opts)
+		return nil
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	writeEvalReport(f, questions, results, opts)
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	fmt.Printf("\n✓ Report written to %s\n", outPath)
+	return nil
+}
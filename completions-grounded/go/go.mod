@@ -2,4 +2,7 @@ module github.com/gloo-ai/grounded-completions-recipe
 
 go 1.21
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/sync v0.9.0
+)
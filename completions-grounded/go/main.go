@@ -2,17 +2,19 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/sync/errgroup"
 )
 
 // Configuration
@@ -20,6 +22,7 @@ var (
 	glooClientID     string
 	glooClientSecret string
 	publisherName    string
+	showDiff         bool
 )
 
 // API Endpoints
@@ -29,19 +32,6 @@ const (
 	groundedURL    = "https://platform.ai.gloo.com/ai/v2/chat/completions/grounded"
 )
 
-// Token management
-var (
-	accessToken string
-	tokenExpiry time.Time
-)
-
-// TokenResponse represents the OAuth2 token response
-type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int    `json:"expires_in"`
-	TokenType   string `json:"token_type"`
-}
-
 // Message represents a chat message
 type Message struct {
 	Role    string `json:"role"`
@@ -52,7 +42,7 @@ type Message struct {
 type CompletionRequest struct {
 	Messages    []Message `json:"messages"`
 	AutoRouting bool      `json:"auto_routing"`
-	MaxTokens   int       `json:"max_tokens"`
+	GenerationParams
 }
 
 // PublisherGroundedRequest represents a grounded completion request
@@ -61,198 +51,270 @@ type PublisherGroundedRequest struct {
 	AutoRouting  bool      `json:"auto_routing"`
 	RagPublisher string    `json:"rag_publisher"`
 	SourcesLimit int       `json:"sources_limit"`
-	MaxTokens    int       `json:"max_tokens"`
+	GenerationParams
 }
 
-// CompletionResponse represents the API response
-type CompletionResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-			Role    string `json:"role"`
-		} `json:"message"`
-		FinishReason string `json:"finish_reason"`
-		Index        int    `json:"index"`
-	} `json:"choices"`
-	SourcesReturned bool   `json:"sources_returned,omitempty"`
-	Model           string `json:"model,omitempty"`
+// DefaultGroundedRequest represents a grounded completion request on Gloo's
+// default content rather than a specific publisher
+type DefaultGroundedRequest struct {
+	Messages     []Message `json:"messages"`
+	AutoRouting  bool      `json:"auto_routing"`
+	SourcesLimit int       `json:"sources_limit"`
+	GenerationParams
 }
 
-// getAccessToken retrieves an OAuth2 access token from Gloo AI
-func getAccessToken() (*TokenResponse, error) {
-	if glooClientID == "" || glooClientSecret == "" {
-		return nil, fmt.Errorf("missing credentials: set GLOO_CLIENT_ID and GLOO_CLIENT_SECRET environment variables")
-	}
-
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-	data.Set("client_id", glooClientID)
-	data.Set("client_secret", glooClientSecret)
-
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create token request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+// Choice is one generated answer in a completion response
+type Choice struct {
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+	Index        int     `json:"index"`
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("token request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// CompletionResponse represents the API response
+type CompletionResponse struct {
+	Choices         []Choice `json:"choices"`
+	SourcesReturned bool     `json:"sources_returned,omitempty"`
+	Sources         []Source `json:"sources,omitempty"`
+	Model           string   `json:"model,omitempty"`
+	Usage           *Usage   `json:"usage,omitempty"`
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+// nonGroundedPayload builds a standard V2 completion request WITHOUT grounding
+func nonGroundedPayload(query string) CompletionRequest {
+	return CompletionRequest{
+		Messages: []Message{
+			{Role: "user", Content: query},
+		},
+		AutoRouting:      true,
+		GenerationParams: generationParams.WithDefaults(defaultGenerationParams),
 	}
+}
 
-	var tokenResp TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to decode token response: %w", err)
+// publisherGroundedPayload builds a grounded completion request WITH RAG
+func publisherGroundedPayload(query, publisher string, sourcesLimit int) PublisherGroundedRequest {
+	return PublisherGroundedRequest{
+		Messages: []Message{
+			{Role: "user", Content: query},
+		},
+		AutoRouting:      true,
+		RagPublisher:     publisher,
+		SourcesLimit:     sourcesLimit,
+		GenerationParams: generationParams.WithDefaults(defaultGenerationParams),
 	}
-
-	return &tokenResp, nil
 }
 
-// ensureValidToken ensures we have a valid access token, refreshing if necessary
-func ensureValidToken() (string, error) {
-	if accessToken == "" || time.Now().After(tokenExpiry) {
-		tokenData, err := getAccessToken()
-		if err != nil {
-			return "", err
-		}
-
-		accessToken = tokenData.AccessToken
-		expiresIn := tokenData.ExpiresIn
-		if expiresIn == 0 {
-			expiresIn = 3600
-		}
-		tokenExpiry = time.Now().Add(time.Duration(expiresIn-300) * time.Second)
+// defaultGroundedPayload builds a grounded completion request on Gloo's
+// default content, without choosing a publisher
+func defaultGroundedPayload(query string, sourcesLimit int) DefaultGroundedRequest {
+	return DefaultGroundedRequest{
+		Messages: []Message{
+			{Role: "user", Content: query},
+		},
+		AutoRouting:      true,
+		SourcesLimit:     sourcesLimit,
+		GenerationParams: generationParams.WithDefaults(defaultGenerationParams),
 	}
-
-	return accessToken, nil
 }
 
 // makeNonGroundedRequest makes a standard V2 completion request WITHOUT grounding
 func makeNonGroundedRequest(query string) (*CompletionResponse, error) {
-	token, err := ensureValidToken()
-	if err != nil {
-		return nil, err
-	}
+	return postCompletion(context.Background(), completionsURL, nonGroundedPayload(query))
+}
 
-	payload := CompletionRequest{
-		Messages: []Message{
-			{Role: "user", Content: query},
-		},
-		AutoRouting: true,
-		MaxTokens:   500,
-	}
+// makePublisherGroundedRequest makes a grounded completion request WITH RAG
+func makePublisherGroundedRequest(query, publisher string, sourcesLimit int) (*CompletionResponse, error) {
+	return postCompletion(context.Background(), groundedURL, publisherGroundedPayload(query, publisher, sourcesLimit))
+}
 
-	jsonData, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", completionsURL, bytes.NewBuffer(jsonData))
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
+// makeDefaultGroundedRequest makes a grounded completion request on Gloo's
+// default content, without choosing a publisher
+func makeDefaultGroundedRequest(query string, sourcesLimit int) (*CompletionResponse, error) {
+	return postCompletion(context.Background(), groundedURL, defaultGroundedPayload(query, sourcesLimit))
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// postCompletion sends a completion request payload to endpoint, giving up
+// when ctx ends. Transient failures are retried with backoff, and a 401
+// fetches a fresh token once.
+func postCompletion(ctx context.Context, endpoint string, payload interface{}) (*CompletionResponse, error) {
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("failed to encode request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+	resp, err := sendAuthorized(ctx, httpClient, endpoint, jsonData, "application/json")
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	var result CompletionResponse
-	json.NewDecoder(resp.Body).Decode(&result)
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	recordUsage(result.Model, result.Usage)
 	return &result, nil
 }
 
-// makePublisherGroundedRequest makes a grounded completion request WITH RAG
-func makePublisherGroundedRequest(query, publisher string, sourcesLimit int) (*CompletionResponse, error) {
-	token, err := ensureValidToken()
-	if err != nil {
-		return nil, err
-	}
-
-	payload := PublisherGroundedRequest{
-		Messages: []Message{
-			{Role: "user", Content: query},
-		},
-		AutoRouting:  true,
-		RagPublisher: publisher,
-		SourcesLimit: sourcesLimit,
-		MaxTokens:    500,
-	}
+// sendAuthorized POSTs jsonData to endpoint with a bearer token and returns
+// the response if it succeeded. Transient failures are retried with backoff
+// until ctx ends, and a 401 fetches a fresh token once.
+func sendAuthorized(ctx context.Context, client *http.Client, endpoint string, jsonData []byte, accept string) (*http.Response, error) {
+	for refreshed := false; ; refreshed = true {
+		token, err := tokenManager.EnsureValidToken()
+		if err != nil {
+			return nil, err
+		}
 
-	jsonData, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", groundedURL, bytes.NewBuffer(jsonData))
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		resp, err := doWithRetry(client, req, jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !refreshed {
+			resp.Body.Close()
+			tokenManager.Invalidate()
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		}
+		return resp, nil
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
-	}
+// comparisonSteps are the answers compareResponses shows, in step order
+var comparisonSteps = []struct{ mode, title string }{
+	{modeNone, "NON-GROUNDED Response (Generic Model Knowledge)"},
+	{modeDefault, "GROUNDED on Gloo's Default Content"},
+	{modePublisher, "GROUNDED on Your Publisher (Your Specific Content)"},
+}
 
-	var result CompletionResponse
-	json.NewDecoder(resp.Body).Decode(&result)
-	return &result, nil
+// stepResult is one finished step of a comparison
+type stepResult struct {
+	step     int
+	response *CompletionResponse
+	printed  bool // the answer was already streamed to the terminal
+	elapsed  time.Duration
+	err      error
 }
 
-// compareResponses compares both approaches side-by-side
-func compareResponses(query, publisher string) {
+// compareResponses compares the non-grounded, default-grounded, and
+// publisher-grounded answers to a query. The three requests run at once and
+// each answer is shown as soon as it arrives; with -stream they run one
+// after another instead, since streamed answers would interleave.
+func compareResponses(query, publisher string, sourcesLimit int) {
 	fmt.Println("\n" + strings.Repeat("=", 80))
 	fmt.Printf("Query: %s\n", query)
 	fmt.Println(strings.Repeat("=", 80))
 
-	// Step 1: Non-grounded
-	fmt.Println("\n🔹 STEP 1: NON-GROUNDED Response (Generic Model Knowledge):")
-	fmt.Println(strings.Repeat("-", 80))
-	nonGrounded, err := makeNonGroundedRequest(query)
-	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
+	if err := screenInput(query); err != nil {
+		fmt.Printf("\n❌ %v\n", err)
+		return
+	}
+
+	responses := make([]*CompletionResponse, len(comparisonSteps))
+	if streamOutput {
+		for i, step := range comparisonSteps {
+			displayStepHeader(i, i == 0)
+			start := time.Now()
+			response, printed, err := answerQuery(step.mode, query, publisher, sourcesLimit)
+			displayStep(stepResult{step: i, response: response, printed: printed, elapsed: time.Since(start), err: err})
+			responses[i] = response
+		}
 	} else {
-		fmt.Println(nonGrounded.Choices[0].Message.Content)
-		fmt.Println("\n📊 Metadata:")
-		fmt.Printf("   Sources used: %v\n", nonGrounded.SourcesReturned)
-		model := nonGrounded.Model
-		if model == "" {
-			model = "N/A"
+		// The first failure cancels the other requests: the comparison
+		// needs all three answers
+		g, ctx := errgroup.WithContext(context.Background())
+		results := make(chan stepResult, len(comparisonSteps))
+		for i, step := range comparisonSteps {
+			i, mode := i, step.mode
+			g.Go(func() error {
+				start := time.Now()
+				response, err := askGrounded(ctx, mode, query, publisher, sourcesLimit)
+				results <- stepResult{step: i, response: response, elapsed: time.Since(start), err: err}
+				return err
+			})
+		}
+		go func() {
+			g.Wait()
+			close(results)
+		}()
+
+		shown := 0
+		for r := range results {
+			if r.err != nil && errors.Is(r.err, context.Canceled) {
+				r.err = errors.New("cancelled because another step failed")
+			}
+			displayStepHeader(r.step, shown == 0)
+			displayStep(r)
+			responses[r.step] = r.response
+			shown++
+		}
+	}
+
+	// What grounding corrected
+	nonGrounded, publisherGrounded := responses[0], responses[2]
+	if showDiff && nonGrounded != nil && publisherGrounded != nil {
+		report, err := analyzeHallucinations(nonGrounded, publisherGrounded)
+		if err != nil {
+			fmt.Printf("\n❌ Comparison failed: %v\n", err)
+		} else {
+			displayHallucinationReport(report)
 		}
-		fmt.Printf("   Model: %s\n", model)
 	}
 
 	fmt.Println("\n" + strings.Repeat("=", 80) + "\n")
+}
 
-	// Step 2: Publisher grounded
-	fmt.Println("🔹 STEP 2: GROUNDED on Your Publisher (Your Specific Content):")
-	fmt.Println(strings.Repeat("-", 80))
-	publisherGrounded, err := makePublisherGroundedRequest(query, publisher, 3)
-	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
+// displayStepHeader prints the heading for a comparison step, after a
+// separator unless it is the first step shown
+func displayStepHeader(step int, first bool) {
+	if first {
+		fmt.Println()
 	} else {
-		fmt.Println(publisherGrounded.Choices[0].Message.Content)
-		fmt.Println("\n📊 Metadata:")
-		fmt.Printf("   Sources used: %v\n", publisherGrounded.SourcesReturned)
-		model := publisherGrounded.Model
-		if model == "" {
-			model = "N/A"
-		}
-		fmt.Printf("   Model: %s\n", model)
+		fmt.Println("\n" + strings.Repeat("=", 80) + "\n")
 	}
+	fmt.Printf("🔹 STEP %d: %s:\n", step+1, comparisonSteps[step].title)
+	fmt.Println(strings.Repeat("-", 80))
+}
 
-	fmt.Println("\n" + strings.Repeat("=", 80) + "\n")
+// displayStep prints a step's answer, metadata, and sources
+func displayStep(r stepResult) {
+	if r.err != nil {
+		fmt.Printf("❌ Error: %v\n", r.err)
+		return
+	}
+	if !r.printed {
+		if len(r.response.Choices) == 0 {
+			fmt.Println("(no answer returned)")
+		} else {
+			fmt.Println(r.response.Choices[0].Message.Content)
+		}
+	}
+	fmt.Println("\n📊 Metadata:")
+	if comparisonSteps[r.step].mode == modeNone {
+		fmt.Printf("   Sources used: %v\n", r.response.SourcesReturned)
+	} else {
+		fmt.Printf("   Sources used: %v (%d returned)\n", r.response.SourcesReturned, len(r.response.Sources))
+	}
+	model := r.response.Model
+	if model == "" {
+		model = "N/A"
+	}
+	fmt.Printf("   Model: %s\n", model)
+	fmt.Printf("   Answered in: %s\n", r.elapsed.Round(10*time.Millisecond))
+	if !r.printed {
+		displaySources(r.response.Sources)
+	}
 }
 
 func promptToContinue() {
@@ -262,40 +324,136 @@ func promptToContinue() {
 }
 
 func main() {
+	generationParams.RegisterFlags(flag.CommandLine)
+	configPath := flag.String("config", "", "JSON file with publisher, queries, sources_limit, and max_tokens (default "+defaultConfigPath+" if present)")
+	publisherFlag := flag.String("publisher", "", "publisher to ground on (overrides the config file and PUBLISHER_NAME)")
+	sourcesLimit := flag.Int("sources-limit", 0, "maximum number of sources per grounded answer (overrides the config file)")
+	var queryFlags []string
+	flag.Func("query", "query to run instead of the configured ones (repeatable)", func(s string) error {
+		queryFlags = append(queryFlags, s)
+		return nil
+	})
+	flag.BoolVar(&showDiff, "diff", false, "check the non-grounded answer's claims against the grounded answer and its sources")
+	flag.BoolVar(&streamOutput, "stream", false, "stream answers token by token, showing sources as soon as they arrive")
+	interactive := flag.Bool("interactive", false, "ask your own questions instead of running the comparison demo")
+	evalCSV := flag.String("eval", "", "evaluate every grounding mode on the questions in this CSV file")
+	evalOut := flag.String("eval-out", "", "write the evaluation report to this file instead of stdout")
+	judge := flag.Bool("judge", false, "grade evaluation answers against the expected column with an LLM judge")
+	concurrency := flag.Int("concurrency", 4, "number of evaluation requests to run at once")
+	moderate := flag.String("moderate", "", "screen queries first and block these categories (comma-separated, or \"all\")")
+	scope := flag.String("scope", "", "space-separated token scopes to request (default GLOO_SCOPE, or api/access)")
+	audience := flag.String("audience", "", "audience for token requests (default GLOO_AUDIENCE)")
+	flag.Parse()
+	if err := generationParams.Validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
 	if err := godotenv.Load(); err != nil {
 		fmt.Println("Warning: .env file not found, using system environment variables")
 	}
 
+	// Flags override the config file, which overrides the defaults
+	path, required := *configPath, true
+	if path == "" {
+		path, required = defaultConfigPath, false
+	}
+	config, err := loadDemoConfig(path, required)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if *sourcesLimit != 0 {
+		config.SourcesLimit = *sourcesLimit
+	}
+	if len(queryFlags) > 0 {
+		config.Queries = queryFlags
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	defaultGenerationParams.MaxTokens = intPtr(config.MaxTokens)
+
 	glooClientID = os.Getenv("GLOO_CLIENT_ID")
 	glooClientSecret = os.Getenv("GLOO_CLIENT_SECRET")
-	publisherName = os.Getenv("PUBLISHER_NAME")
+	tokenManager = NewTokenManager(glooClientID, glooClientSecret, tokenURL)
+	// Flags override the environment
+	if *scope != "" {
+		tokenManager.Scope = *scope
+	}
+	if *audience == "" {
+		*audience = os.Getenv("GLOO_AUDIENCE")
+	}
+	tokenManager.Audience = *audience
+	// publisherHint names the setting that chose the publisher, so the demo
+	// tells users where to change it
+	var publisherHint string
+	publisherName, publisherHint = *publisherFlag, "Pass -publisher with your publisher's name"
+	if publisherName == "" {
+		publisherName, publisherHint = config.Publisher, fmt.Sprintf("Update \"publisher\" in %s", path)
+	}
+	if publisherName == "" {
+		publisherName, publisherHint = os.Getenv("PUBLISHER_NAME"), "Update PUBLISHER_NAME in .env"
+	}
 	if publisherName == "" {
 		publisherName = "Bezalel"
 	}
 
+	// Screen queries before they are sent, if a moderation policy is set
+	moderationSpec := *moderate
+	if moderationSpec == "" {
+		moderationSpec = os.Getenv("MODERATION_BLOCK")
+	}
+	if moderationSpec != "" {
+		policy, err := parseModerationPolicy(moderationSpec)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		moderationPolicy = policy
+	}
+
+	if *evalCSV != "" {
+		opts := EvalOptions{
+			Publisher:    publisherName,
+			SourcesLimit: config.SourcesLimit,
+			Concurrency:  *concurrency,
+			Judge:        *judge,
+		}
+		if err := runEvalCommand(*evalCSV, *evalOut, opts); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *interactive {
+		runInteractive(os.Stdin, publisherName, config.SourcesLimit)
+		return
+	}
+
 	fmt.Println("\n" + strings.Repeat("=", 80))
 	fmt.Println("  GROUNDED COMPLETIONS DEMO - Comparing RAG vs Non-RAG Responses")
 	fmt.Println(strings.Repeat("=", 80))
 	fmt.Printf("\nPublisher: %s\n", publisherName)
-	fmt.Println("This demo shows a 2-step progression:")
+	fmt.Println("This demo shows a 3-step progression:")
 	fmt.Println("  1. Non-grounded (generic model knowledge)")
-	fmt.Println("  2. Grounded on your publisher (your specific content)")
+	fmt.Println("  2. Grounded on Gloo's default content")
+	fmt.Println("  3. Grounded on your publisher (your specific content)")
+	fmt.Println("The three requests run at once; each answer is shown as it arrives.")
 	fmt.Println("\nNote: For org-specific queries like Bezalel's hiring process,")
-	fmt.Println("step 1 may lack specific details, while step 2")
-	fmt.Println("provides accurate, source-backed answers from your content.\n")
-
-	queries := []string{
-		"What is Bezalel Ministries' hiring process?",
-		"What educational resources does Bezalel Ministries provide?",
-		"Describe Bezalel's research methodology for creating artwork.",
-	}
+	fmt.Println("steps 1 and 2 may lack specific details, while step 3")
+	fmt.Println("provides accurate, source-backed answers from your content.")
+	fmt.Println()
 
+	queries := config.Queries
 	for i, query := range queries {
 		fmt.Println("\n" + strings.Repeat("#", 80))
 		fmt.Printf("# COMPARISON %d of %d\n", i+1, len(queries))
 		fmt.Println(strings.Repeat("#", 80))
 
-		compareResponses(query, publisherName)
+		compareResponses(query, publisherName, config.SourcesLimit)
 
 		if i < len(queries)-1 {
 			promptToContinue()
@@ -307,13 +465,14 @@ func main() {
 	fmt.Println(strings.Repeat("=", 80))
 	fmt.Println("\nKey Takeaways:")
 	fmt.Println("✓ Step 1 (Non-grounded): Generic model knowledge, may hallucinate")
-	fmt.Println("✓ Step 2 (Publisher grounded): Your specific content, accurate and")
+	fmt.Println("✓ Step 2 (Default grounded): Gloo's content, source-backed but general")
+	fmt.Println("✓ Step 3 (Publisher grounded): Your specific content, accurate and")
 	fmt.Println("  source-backed (sources_returned: true)")
 	fmt.Println("✓ Grounding on your publisher content ensures accurate, relevant answers")
 	fmt.Println("  for organization-specific queries")
 	fmt.Println("\nNext Steps:")
 	fmt.Println("• Upload your own content to a Publisher in Gloo Studio")
-	fmt.Println("• Update PUBLISHER_NAME in .env to use your content")
+	fmt.Printf("• %s to use your content\n", publisherHint)
 	fmt.Println("• Try both general and specific queries to see the differences!")
 	fmt.Println()
 }
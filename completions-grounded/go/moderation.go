@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// moderationCategories are the categories the moderation prompt scores
+var moderationCategories = []string{
+	"self_harm",
+	"violence",
+	"sexual",
+	"hate",
+	"harassment",
+	"illicit",
+}
+
+// moderationPrompt asks the model to classify input rather than answer it
+const moderationPrompt = `You are a content safety classifier. Classify the user's message; do not answer it.
+Reply with only a JSON object of this form:
+{"categories": {"self_harm": false, "violence": false, "sexual": false, "hate": false, "harassment": false, "illicit": false}, "reason": "one short sentence"}
+Set a category to true only if the message clearly falls into it.`
+
+// ModerationResult is the classification of one piece of user input
+type ModerationResult struct {
+	Categories map[string]bool `json:"categories"`
+	Reason     string          `json:"reason"`
+}
+
+// Flagged returns the categories set to true, sorted
+func (r *ModerationResult) Flagged() []string {
+	var flagged []string
+	for category, set := range r.Categories {
+		if set {
+			flagged = append(flagged, category)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
+// ModerationPolicy lists the categories that block a request
+type ModerationPolicy struct {
+	Block []string
+}
+
+// parseModerationPolicy parses a comma-separated category list; "all"
+// blocks every category
+func parseModerationPolicy(spec string) (*ModerationPolicy, error) {
+	policy := &ModerationPolicy{}
+	for _, c := range strings.Split(spec, ",") {
+		c = strings.TrimSpace(c)
+		switch {
+		case c == "":
+			continue
+		case c == "all":
+			policy.Block = append(policy.Block, moderationCategories...)
+		case contains(moderationCategories, c):
+			policy.Block = append(policy.Block, c)
+		default:
+			return nil, fmt.Errorf("unknown moderation category %q (expected one of: %s, or all)",
+				c, strings.Join(moderationCategories, ", "))
+		}
+	}
+	return policy, nil
+}
+
+// BlockedError reports input that was flagged in a blocked category
+type BlockedError struct {
+	Categories []string
+	Reason     string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("input blocked by moderation (%s): %s", strings.Join(e.Categories, ", "), e.Reason)
+}
+
+// moderationPolicy is the active policy; nil means input is not screened
+var moderationPolicy *ModerationPolicy
+
+// moderateInput classifies text with the moderation prompt. Temperature 0
+// keeps the classification stable.
+func moderateInput(text string) (*ModerationResult, error) {
+	payload := CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: moderationPrompt},
+			{Role: "user", Content: text},
+		},
+		AutoRouting: true,
+		GenerationParams: GenerationParams{
+			Temperature: floatPtr(0),
+			MaxTokens:   intPtr(200),
+		},
+	}
+	response, err := postCompletion(context.Background(), completionsURL, payload)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("moderation request returned no choices")
+	}
+
+	content := strings.TrimSpace(response.Choices[0].Message.Content)
+	content = strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```")
+	content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+
+	var result ModerationResult
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse moderation result: %w", err)
+	}
+	if result.Categories == nil {
+		result.Categories = map[string]bool{}
+	}
+	return &result, nil
+}
+
+// CheckInput screens text against a policy and returns a *BlockedError if
+// any blocked category is flagged. If the classifier itself fails the input
+// is blocked too, so a moderation outage can't silently let content through.
+func CheckInput(text string, policy *ModerationPolicy) (*ModerationResult, error) {
+	result, err := moderateInput(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked []string
+	for _, category := range result.Flagged() {
+		if contains(policy.Block, category) {
+			blocked = append(blocked, category)
+		}
+	}
+	if len(blocked) > 0 {
+		return result, &BlockedError{Categories: blocked, Reason: result.Reason}
+	}
+	return result, nil
+}
+
+// screenInput applies the active moderation policy, if any
+func screenInput(text string) error {
+	if moderationPolicy == nil {
+		return nil
+	}
+	_, err := CheckInput(text, moderationPolicy)
+	return err
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Grounding modes for askGrounded
+const (
+	modeNone      = "none"      // plain completion, no retrieval
+	modeDefault   = "default"   // grounded on Gloo's default content
+	modePublisher = "publisher" // grounded on a specific publisher's content
+)
+
+// requestFor returns the endpoint and payload for a query with the given
+// grounding mode
+func requestFor(mode, query, publisher string, sourcesLimit int) (string, interface{}, error) {
+	switch mode {
+	case modeNone:
+		return completionsURL, nonGroundedPayload(query), nil
+	case modeDefault:
+		return groundedURL, defaultGroundedPayload(query, sourcesLimit), nil
+	case modePublisher:
+		return groundedURL, publisherGroundedPayload(query, publisher, sourcesLimit), nil
+	default:
+		return "", nil, fmt.Errorf("unknown grounding mode %q", mode)
+	}
+}
+
+// askGrounded answers a query with the given grounding mode, giving up when
+// ctx ends
+func askGrounded(ctx context.Context, mode, query, publisher string, sourcesLimit int) (*CompletionResponse, error) {
+	endpoint, payload, err := requestFor(mode, query, publisher, sourcesLimit)
+	if err != nil {
+		return nil, err
+	}
+	return postCompletion(ctx, endpoint, payload)
+}
+
+// replState holds the settings changed with REPL commands
+type replState struct {
+	mode         string
+	publisher    string
+	sourcesLimit int
+}
+
+const replHelp = `Commands:
+  /mode none|default|publisher   choose how answers are grounded
+  /publisher <name>              set the publisher for publisher mode
+  /sources <n>                   set the maximum number of sources
+  /settings                      show the current settings
+  /help                          show this help
+  /exit                          quit
+Anything else is sent as a question.`
+
+// runInteractive reads questions from in and answers them with the current
+// grounding mode, printing the answer and its sources
+func runInteractive(in io.Reader, publisher string, sourcesLimit int) {
+	state := &replState{mode: modePublisher, publisher: publisher, sourcesLimit: sourcesLimit}
+
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Println("  GROUNDED COMPLETIONS - Interactive Mode")
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Println(replHelp)
+	state.print()
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Printf("\n[%s] ❓ ", state.label())
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			if !state.command(line) {
+				return
+			}
+			continue
+		}
+
+		if err := screenInput(line); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			continue
+		}
+
+		fmt.Println()
+		response, printed, err := answerQuery(state.mode, line, state.publisher, state.sourcesLimit)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			continue
+		}
+		if len(response.Choices) == 0 {
+			fmt.Println("❌ Error: no choices in response")
+			continue
+		}
+
+		if !printed {
+			fmt.Println(response.Choices[0].Message.Content)
+		}
+		model := response.Model
+		if model == "" {
+			model = "N/A"
+		}
+		fmt.Printf("\n📊 Model: %s | Sources used: %v (%d returned)\n", model, response.SourcesReturned, len(response.Sources))
+		if !printed {
+			displaySources(response.Sources)
+		}
+	}
+}
+
+// label describes the current mode for the prompt
+func (s *replState) label() string {
+	if s.mode == modePublisher {
+		return modePublisher + ":" + s.publisher
+	}
+	return s.mode
+}
+
+func (s *replState) print() {
+	fmt.Printf("\nMode: %s | Publisher: %s | Sources limit: %d\n", s.mode, s.publisher, s.sourcesLimit)
+}
+
+// command applies a REPL command and reports whether to keep going
+func (s *replState) command(line string) bool {
+	fields := strings.Fields(line)
+	arg := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+
+	switch fields[0] {
+	case "/exit", "/quit":
+		return false
+	case "/help":
+		fmt.Println(replHelp)
+	case "/settings":
+		s.print()
+	case "/mode":
+		switch arg {
+		case modeNone, modeDefault, modePublisher:
+			s.mode = arg
+			fmt.Printf("Grounding mode: %s\n", s.label())
+		default:
+			fmt.Println("Usage: /mode none|default|publisher")
+		}
+	case "/publisher":
+		if arg == "" {
+			fmt.Println("Usage: /publisher <name>")
+			break
+		}
+		s.publisher = arg
+		fmt.Printf("Publisher: %s\n", s.publisher)
+	case "/sources":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			fmt.Println("Usage: /sources <n> (n >= 1)")
+			break
+		}
+		s.sourcesLimit = n
+		fmt.Printf("Sources limit: %d\n", s.sourcesLimit)
+	default:
+		fmt.Printf("Unknown command %s\n%s\n", fields[0], replHelp)
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Retry settings. GROUNDED_MAX_RETRIES overrides maxRetries; 0 disables
+// retries.
+var (
+	maxRetries     = 3
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+)
+
+func init() {
+	if v, err := strconv.Atoi(os.Getenv("GROUNDED_MAX_RETRIES")); err == nil && v >= 0 {
+		maxRetries = v
+	}
+}
+
+// httpClient is shared by every non-streaming request
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// retryable reports whether a response status is worth retrying: rate
+// limits and server errors are usually transient, other client errors are
+// not
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryDelay returns how long to wait before retry number attempt (from 1),
+// honouring a Retry-After header in seconds if the server sent one
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	delay := retryBaseDelay << (attempt - 1)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	// Full jitter keeps concurrent requests from retrying in lockstep
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// doWithRetry sends req with client, retrying network errors, 429s, and 5xx
+// responses with exponential backoff. body is resent on each attempt; pass
+// nil when req.Body can be replayed with GetBody (as for strings.Reader
+// bodies).
+func doWithRetry(client *http.Client, req *http.Request, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		} else if attempt > 0 && req.GetBody != nil {
+			b, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = b
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !retryable(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= maxRetries {
+			return resp, err
+		}
+
+		wait := retryDelay(attempt+1, resp)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Source is one piece of publisher content the grounded endpoint used to
+// answer a query
+type Source struct {
+	ItemID    string  `json:"item_id"`
+	Title     string  `json:"title"`
+	Publisher string  `json:"publisher"`
+	Author    string  `json:"author,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	Snippet   string  `json:"snippet"`
+	Score     float64 `json:"score,omitempty"`
+}
+
+// UnmarshalJSON accepts the field names used across Gloo's content APIs
+// (for example item_title as well as title), so the struct keeps working if
+// the grounded response mirrors the Search API's naming
+func (s *Source) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*s = Source{
+		ItemID:    firstString(raw, "item_id", "id", "uuid"),
+		Title:     firstString(raw, "title", "item_title", "name"),
+		Publisher: firstString(raw, "publisher", "publisher_name", "tenant"),
+		Author:    firstString(raw, "author", "authors"),
+		URL:       firstString(raw, "url", "item_url", "link"),
+		Snippet:   firstString(raw, "snippet", "text", "content"),
+	}
+	for _, key := range []string{"score", "certainty", "relevance"} {
+		if v, ok := raw[key]; ok && json.Unmarshal(v, &s.Score) == nil {
+			break
+		}
+	}
+	return nil
+}
+
+// firstString returns the first of keys present in raw as a string. Arrays
+// of strings (such as a list of authors) are joined with commas.
+func firstString(raw map[string]json.RawMessage, keys ...string) string {
+	for _, key := range keys {
+		v, ok := raw[key]
+		if !ok {
+			continue
+		}
+		var s string
+		if json.Unmarshal(v, &s) == nil && s != "" {
+			return s
+		}
+		var list []string
+		if json.Unmarshal(v, &list) == nil && len(list) > 0 {
+			return strings.Join(list, ", ")
+		}
+	}
+	return ""
+}
+
+// displaySources prints the sources behind a grounded answer
+func displaySources(sources []Source) {
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Println("\n📚 Sources:")
+	for i, src := range sources {
+		title := src.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Printf("   [%d] %s\n", i+1, title)
+		if src.Publisher != "" {
+			fmt.Printf("       Publisher: %s\n", src.Publisher)
+		}
+		if src.Author != "" {
+			fmt.Printf("       Author: %s\n", src.Author)
+		}
+		if src.ItemID != "" {
+			fmt.Printf("       Item ID: %s\n", src.ItemID)
+		}
+		if src.URL != "" {
+			fmt.Printf("       URL: %s\n", src.URL)
+		}
+		if src.Snippet != "" {
+			snippet := strings.Join(strings.Fields(src.Snippet), " ")
+			if len(snippet) > 160 {
+				snippet = snippet[:160] + "..."
+			}
+			fmt.Printf("       \"%s\"\n", snippet)
+		}
+	}
+}
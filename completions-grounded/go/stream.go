@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// streamOutput makes the demo stream answers token by token
+var streamOutput bool
+
+// streamClient has no overall timeout: a long answer can stream for minutes
+var streamClient = &http.Client{}
+
+// StreamHandlers receive a grounded stream as it arrives. Either may be nil.
+type StreamHandlers struct {
+	OnContent func(text string)
+	OnSources func(sources []Source)
+}
+
+// streamChunk is one SSE "data:" payload. Grounded streams carry the
+// sources either on a chunk of their own or alongside the content deltas.
+type streamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	SourcesReturned *bool    `json:"sources_returned"`
+	Sources         []Source `json:"sources"`
+	Usage           *Usage   `json:"usage"`
+}
+
+// readGroundedStream reads an SSE body into a CompletionResponse, calling
+// the handlers as content and sources arrive. Sources may come in a frame
+// with "event: sources" (whose data is either the list or an object holding
+// it) or in a "sources" field on a regular chunk. Reading continues past
+// the finish reason, since the sources frame can follow the last token.
+func readGroundedStream(body io.Reader, h StreamHandlers) (*CompletionResponse, error) {
+	result := &CompletionResponse{}
+	var content strings.Builder
+	var finishReason string
+	event := ""
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			event = ""
+			continue
+		}
+		if strings.HasPrefix(line, "event:") {
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if event == "sources" && strings.HasPrefix(data, "[") {
+			if err := json.Unmarshal([]byte(data), &chunk.Sources); err != nil {
+				return nil, fmt.Errorf("failed to parse sources frame: %w", err)
+			}
+		} else if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage
+		}
+		if chunk.SourcesReturned != nil {
+			result.SourcesReturned = *chunk.SourcesReturned
+		}
+		if len(chunk.Sources) > 0 {
+			result.Sources = append(result.Sources, chunk.Sources...)
+			result.SourcesReturned = true
+			if h.OnSources != nil {
+				h.OnSources(chunk.Sources)
+			}
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				if h.OnContent != nil {
+					h.OnContent(choice.Delta.Content)
+				}
+			}
+			if choice.FinishReason != nil {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	result.Choices = []Choice{{
+		Message:      Message{Role: "assistant", Content: content.String()},
+		FinishReason: finishReason,
+	}}
+	return result, nil
+}
+
+// streamCompletion sends payload to endpoint with "stream": true and reads
+// the answer as it arrives
+func streamCompletion(endpoint string, payload interface{}, h StreamHandlers) (*CompletionResponse, error) {
+	// Round-trip through a map to add the stream flag to any request type
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	fields["stream"] = true
+	jsonData, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	resp, err := sendAuthorized(context.Background(), streamClient, endpoint, jsonData, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result, err := readGroundedStream(resp.Body, h)
+	if err != nil {
+		return nil, err
+	}
+	recordUsage(result.Model, result.Usage)
+	return result, nil
+}
+
+// streamGrounded answers a query with the given grounding mode, printing
+// the answer token by token and the sources as soon as they arrive
+func streamGrounded(mode, query, publisher string, sourcesLimit int) (*CompletionResponse, error) {
+	endpoint, payload, err := requestFor(mode, query, publisher, sourcesLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	midLine := false
+	response, err := streamCompletion(endpoint, payload, StreamHandlers{
+		OnContent: func(text string) {
+			fmt.Print(text)
+			midLine = !strings.HasSuffix(text, "\n")
+		},
+		OnSources: func(sources []Source) {
+			if midLine {
+				fmt.Println()
+				midLine = false
+			}
+			displaySources(sources)
+			fmt.Println()
+		},
+	})
+	if midLine {
+		fmt.Println()
+	}
+	return response, err
+}
+
+// answerQuery answers a query with the given grounding mode, streaming it
+// to the terminal when -stream is set. It reports whether the answer and
+// sources were already printed.
+func answerQuery(mode, query, publisher string, sourcesLimit int) (*CompletionResponse, bool, error) {
+	if streamOutput {
+		response, err := streamGrounded(mode, query, publisher, sourcesLimit)
+		return response, true, err
+	}
+	response, err := askGrounded(context.Background(), mode, query, publisher, sourcesLimit)
+	return response, false, err
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// maxToolRounds caps how many times the model may call tools before it must
+// give its final answer.
+const maxToolRounds = 5
+
+// toolRegistry holds the tools offered to the model.
+var toolRegistry = newToolRegistry()
+
+func newToolRegistry() *ToolRegistry {
+	r := NewToolRegistry()
+	r.MustRegister("create_growth_plan",
+		"Creates a structured personal growth plan with a title and a series of actionable steps.",
+		executeCreateGrowthPlan)
+	r.MustRegister("lookup_scripture",
+		"Looks up the text of a Bible passage by reference.",
+		executeLookupScripture)
+	r.MustRegister("schedule_event",
+		"Adds an event or recurring reminder to the user's calendar.",
+		executeScheduleEvent)
+	return r
+}
+
+// savedPlans stands in for the application's storage in this example. Tool
+// calls run concurrently, so access is guarded by savedPlansMu.
+var (
+	savedPlansMu sync.Mutex
+	savedPlans   []GrowthPlan
+)
+
+// GrowthPlanResult is what the create_growth_plan tool reports back.
+type GrowthPlanResult struct {
+	Status    string `json:"status"`
+	PlanID    string `json:"plan_id"`
+	GoalTitle string `json:"goal_title"`
+	StepCount int    `json:"step_count"`
+	FirstStep string `json:"first_step"`
+}
+
+// executeCreateGrowthPlan implements the create_growth_plan tool: it stores
+// the plan and reports what was saved.
+func executeCreateGrowthPlan(plan GrowthPlan) (GrowthPlanResult, error) {
+	if plan.GoalTitle == "" || len(plan.Steps) == 0 {
+		return GrowthPlanResult{}, fmt.Errorf("growth plan needs a goal_title and at least one step")
+	}
+
+	savedPlansMu.Lock()
+	savedPlans = append(savedPlans, plan)
+	planID := fmt.Sprintf("plan-%d", len(savedPlans))
+	savedPlansMu.Unlock()
+
+	return GrowthPlanResult{
+		Status:    "created",
+		PlanID:    planID,
+		GoalTitle: plan.GoalTitle,
+		StepCount: len(plan.Steps),
+		FirstStep: plan.Steps[0].Action,
+	}, nil
+}
+
+// executeToolCall dispatches a tool call through the registry. Failures,
+// including arguments that don't match the tool's schema, are returned to
+// the model as an error result rather than aborting the loop, so the model
+// can explain or recover.
+func executeToolCall(call ToolCall) string {
+	result, err := toolRegistry.Dispatch(call)
+	if err != nil {
+		return fmt.Sprintf(`{"error": %q}`, err.Error())
+	}
+	return result
+}
+
+// executeToolCalls runs all tool calls from one assistant message
+// concurrently and returns their "tool" messages in the order the calls were
+// made, each tied to its call by tool_call_id.
+func executeToolCalls(calls []ToolCall) []Message {
+	results := make([]Message, len(calls))
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		fmt.Printf("🔧 Executing tool: %s\n", call.Function.Name)
+		wg.Add(1)
+		go func(i int, call ToolCall) {
+			defer wg.Done()
+			results[i] = Message{
+				Role:       "tool",
+				Content:    executeToolCall(call),
+				ToolCallID: call.ID,
+			}
+		}(i, call)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// completeToolRoundTrip continues a conversation whose latest response asked
+// for tool calls: it executes the calls (concurrently when the model made
+// several), appends the results as "tool" messages, and sends the
+// conversation back, repeating until the model answers in natural language
+// or maxToolRounds is reached.
+func completeToolRoundTrip(messages []Message, response *ApiResponse) (string, error) {
+	for round := 0; round < maxToolRounds; round++ {
+		if len(response.Choices) == 0 {
+			return "", fmt.Errorf("no choices in response")
+		}
+
+		assistant := response.Choices[0].Message
+		if len(assistant.ToolCalls) == 0 {
+			return assistant.Content, nil
+		}
+		messages = append(messages, assistant)
+
+		messages = append(messages, executeToolCalls(assistant.ToolCalls)...)
+
+		var err error
+		response, err = sendCompletionRequest(messages, toolRegistry.Definitions(), "auto")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("model was still calling tools after %d rounds", maxToolRounds)
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -34,13 +36,13 @@ type TokenInfo struct {
 
 type GrowthStep struct {
 	StepNumber int    `json:"step_number"`
-	Action     string `json:"action"`
-	Timeline   string `json:"timeline"`
+	Action     string `json:"action" description:"The specific, actionable task for this step."`
+	Timeline   string `json:"timeline" description:"A suggested timeframe for this step (e.g., 'Week 1-2')."`
 }
 
 type GrowthPlan struct {
-	GoalTitle string       `json:"goal_title"`
-	Steps     []GrowthStep `json:"steps"`
+	GoalTitle string       `json:"goal_title" description:"A concise, encouraging title for the user's goal."`
+	Steps     []GrowthStep `json:"steps" description:"A list of concrete steps the user should take."`
 }
 
 type ToolCall struct {
@@ -52,17 +54,28 @@ type ToolCall struct {
 	} `json:"function"`
 }
 
+// Message is a chat message in the conversation sent to the API. Assistant
+// messages may carry tool calls; tool messages carry a tool call's result.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
 type ApiResponse struct {
+	Model   string `json:"model"`
 	Choices []struct {
-		Message struct {
-			ToolCalls []ToolCall `json:"tool_calls"`
-		} `json:"message"`
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 }
 
 // --- Function Definitions ---
 func getAccessToken() (*TokenInfo, error) {
-	data := strings.NewReader("grant_type=client_credentials&scope=api/access")
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {getEnv("GLOO_SCOPE", "api/access")}}
+	data := strings.NewReader(form.Encode())
 	req, err := http.NewRequest("POST", tokenURL, data)
 	if err != nil {
 		return nil, err
@@ -105,6 +118,27 @@ func isTokenExpired(token *TokenInfo) bool {
 }
 
 func createGoalSettingRequest(userGoal string) (*ApiResponse, error) {
+	messages := []Message{{Role: "user", Content: userGoal}}
+	return sendCompletionRequest(messages, toolRegistry.Definitions(), "required")
+}
+
+// sendCompletionRequest sends the conversation so far, offering tools to the
+// model with the given tool_choice: "required", "auto", "none", or a
+// forcedToolChoice value naming a specific tool.
+func sendCompletionRequest(messages []Message, tools []map[string]interface{}, toolChoice interface{}) (*ApiResponse, error) {
+	payload := map[string]interface{}{
+		"auto_routing": true,
+		"messages":     messages,
+	}
+	if len(tools) > 0 {
+		payload["tools"] = tools
+		payload["tool_choice"] = toolChoice
+	}
+	return postCompletion(payload)
+}
+
+// postCompletion sends a completions payload and decodes the response.
+func postCompletion(payload map[string]interface{}) (*ApiResponse, error) {
 	var err error
 	if isTokenExpired(tokenInfo) {
 		fmt.Println("Token is expired or missing. Fetching a new one...")
@@ -114,51 +148,6 @@ func createGoalSettingRequest(userGoal string) (*ApiResponse, error) {
 		}
 	}
 
-	tools := []map[string]interface{}{
-		{
-			"type": "function",
-			"function": map[string]interface{}{
-				"name":        "create_growth_plan",
-				"description": "Creates a structured personal growth plan with a title and a series of actionable steps.",
-				"parameters": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"goal_title": map[string]interface{}{
-							"type":        "string",
-							"description": "A concise, encouraging title for the user's goal.",
-						},
-						"steps": map[string]interface{}{
-							"type":        "array",
-							"description": "A list of concrete steps the user should take.",
-							"items": map[string]interface{}{
-								"type": "object",
-								"properties": map[string]interface{}{
-									"step_number": map[string]string{"type": "integer"},
-									"action": map[string]string{
-										"type":        "string",
-										"description": "The specific, actionable task for this step.",
-									},
-									"timeline": map[string]string{
-										"type":        "string",
-										"description": "A suggested timeframe for this step (e.g., 'Week 1-2').",
-									},
-								},
-								"required": []string{"step_number", "action", "timeline"},
-							},
-						},
-					},
-					"required": []string{"goal_title", "steps"},
-				},
-			},
-		},
-	}
-
-	payload := map[string]interface{}{
-		"auto_routing": true,
-		"messages":     []map[string]string{{"role": "user", "content": userGoal}},
-		"tools":        tools,
-		"tool_choice":  "required",
-	}
 	jsonPayload, _ := json.Marshal(payload)
 
 	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonPayload))
@@ -184,6 +173,7 @@ func createGoalSettingRequest(userGoal string) (*ApiResponse, error) {
 
 	var result ApiResponse
 	json.Unmarshal(body, &result)
+	recordUsage(result.Model, result.Usage)
 
 	return &result, nil
 }
@@ -193,10 +183,24 @@ func parseGrowthPlan(apiResponse *ApiResponse) (*GrowthPlan, error) {
 		return nil, fmt.Errorf("no tool calls found in response")
 	}
 
-	toolCall := apiResponse.Choices[0].Message.ToolCalls[0]
+	var toolCall *ToolCall
+	for i, call := range apiResponse.Choices[0].Message.ToolCalls {
+		if call.Function.Name == "create_growth_plan" {
+			toolCall = &apiResponse.Choices[0].Message.ToolCalls[i]
+			break
+		}
+	}
+	if toolCall == nil {
+		return nil, fmt.Errorf("no create_growth_plan call found in response")
+	}
+
+	if err := toolRegistry.ValidateArguments(*toolCall); err != nil {
+		return nil, &ToolArgumentError{Call: *toolCall, Err: err}
+	}
+
 	var growthPlan GrowthPlan
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &growthPlan); err != nil {
-		return nil, fmt.Errorf("failed to parse growth plan: %v", err)
+		return nil, &ToolArgumentError{Call: *toolCall, Err: err}
 	}
 
 	return &growthPlan, nil
@@ -245,7 +249,19 @@ func init() {
 
 // --- Main Execution ---
 func main() {
-	userGoal := "I want to grow in my faith."
+	userGoal := "I want to grow in my faith. Make me a growth plan, give me the text of " +
+		"Joshua 1:9 to memorize, and schedule a weekly Sunday-evening reflection starting 2025-06-01 at 19:00."
+	if len(os.Args) > 1 && os.Args[1] == "structured" {
+		goal := "I want to build a daily prayer habit."
+		if len(os.Args) > 2 {
+			goal = strings.Join(os.Args[2:], " ")
+		}
+		runStructuredExample(goal)
+		return
+	}
+	if len(os.Args) > 1 {
+		userGoal = strings.Join(os.Args[1:], " ")
+	}
 	fmt.Printf("Creating growth plan for: '%s'\n", userGoal)
 
 	// Make API call with tool use
@@ -255,8 +271,22 @@ func main() {
 		return
 	}
 
-	// Parse the structured response
+	if len(response.Choices) > 0 {
+		fmt.Printf("\n🧰 The model requested %d tool call(s)\n", len(response.Choices[0].Message.ToolCalls))
+	}
+
+	// Parse the structured response, asking the model to fix its arguments
+	// if they don't match the tool's schema
+	messages := []Message{{Role: "user", Content: userGoal}}
 	growthPlan, err := parseGrowthPlan(response)
+	var argErr *ToolArgumentError
+	if errors.As(err, &argErr) {
+		fmt.Printf("\n🩹 Tool arguments were invalid (%v); asking the model to repair them...\n", argErr.Err)
+		response, err = repairToolArguments(messages, response, argErr, maxRepairAttempts)
+		if err == nil {
+			growthPlan, err = parseGrowthPlan(response)
+		}
+	}
 	if err != nil {
 		fmt.Printf("Error parsing growth plan: %v\n", err)
 		return
@@ -269,4 +299,14 @@ func main() {
 	fmt.Printf("\n📊 Raw JSON output:\n")
 	jsonBytes, _ := json.MarshalIndent(growthPlan, "", "  ")
 	fmt.Printf("%s\n", string(jsonBytes))
-}
\ No newline at end of file
+
+	// Execute the tools and send their results back for a final answer
+	fmt.Printf("\n🔁 Completing the tool round trip...\n")
+	finalAnswer, err := completeToolRoundTrip(messages, response)
+	if err != nil {
+		fmt.Printf("Error completing tool round trip: %v\n", err)
+		return
+	}
+
+	fmt.Printf("\n💬 Final answer:\n%s\n", finalAnswer)
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ToolRegistry holds Go functions exposed to the model as tools. Each
+// function takes a parameter struct; its JSON Schema is derived from the
+// struct's fields and tags:
+//
+//	json:"name"               property name (",omitempty" makes it optional)
+//	description:"..."         property description
+//	enum:"a,b,c"              allowed values for string properties
+//
+// Fields without omitempty are required.
+type ToolRegistry struct {
+	tools map[string]*registeredTool
+	order []string
+}
+
+type registeredTool struct {
+	name        string
+	description string
+	paramType   reflect.Type
+	fn          reflect.Value
+	schema      map[string]interface{}
+}
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]*registeredTool)}
+}
+
+// Register adds a tool. fn must have the signature func(P) (R, error), where
+// P is a struct type describing the tool's arguments and R is any value that
+// can be marshaled to JSON.
+func (r *ToolRegistry) Register(name, description string, fn interface{}) error {
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %q is already registered", name)
+	}
+
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 1 || fnType.NumOut() != 2 ||
+		fnType.In(0).Kind() != reflect.Struct || fnType.Out(1) != errorType {
+		return fmt.Errorf("tool %q: function must have signature func(ParamsStruct) (Result, error)", name)
+	}
+
+	schema, err := schemaForType(fnType.In(0))
+	if err != nil {
+		return fmt.Errorf("tool %q: %v", name, err)
+	}
+
+	r.tools[name] = &registeredTool{
+		name:        name,
+		description: description,
+		paramType:   fnType.In(0),
+		fn:          fnValue,
+		schema:      schema,
+	}
+	r.order = append(r.order, name)
+	return nil
+}
+
+// MustRegister is like Register but panics on error. It is intended for
+// registering tools at program start.
+func (r *ToolRegistry) MustRegister(name, description string, fn interface{}) {
+	if err := r.Register(name, description, fn); err != nil {
+		panic(err)
+	}
+}
+
+// Definitions returns the tool definitions in the format expected by the
+// completions API's "tools" field, in registration order.
+func (r *ToolRegistry) Definitions() []map[string]interface{} {
+	defs := make([]map[string]interface{}, 0, len(r.order))
+	for _, name := range r.order {
+		t := r.tools[name]
+		defs = append(defs, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        t.name,
+				"description": t.description,
+				"parameters":  t.schema,
+			},
+		})
+	}
+	return defs
+}
+
+// Schema returns the JSON Schema for a registered tool's parameters.
+func (r *ToolRegistry) Schema(name string) (map[string]interface{}, bool) {
+	t, ok := r.tools[name]
+	if !ok {
+		return nil, false
+	}
+	return t.schema, true
+}
+
+// ValidateArguments checks that a tool call names a registered tool and that
+// its arguments are valid JSON matching the tool's schema.
+func (r *ToolRegistry) ValidateArguments(call ToolCall) error {
+	t, ok := r.tools[call.Function.Name]
+	if !ok {
+		return fmt.Errorf("unknown tool: %s", call.Function.Name)
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &raw); err != nil {
+		return fmt.Errorf("arguments are not valid JSON: %v", err)
+	}
+	return validateAgainstSchema(raw, t.schema, "arguments")
+}
+
+// Dispatch validates a tool call's arguments against the tool's schema,
+// invokes the registered function, and returns its result as JSON.
+func (r *ToolRegistry) Dispatch(call ToolCall) (string, error) {
+	if err := r.ValidateArguments(call); err != nil {
+		return "", err
+	}
+	t := r.tools[call.Function.Name]
+
+	params := reflect.New(t.paramType)
+	if err := json.Unmarshal([]byte(call.Function.Arguments), params.Interface()); err != nil {
+		return "", fmt.Errorf("failed to decode arguments: %v", err)
+	}
+
+	out := t.fn.Call([]reflect.Value{params.Elem()})
+	if errValue := out[1].Interface(); errValue != nil {
+		return "", errValue.(error)
+	}
+
+	resultJSON, err := json.Marshal(out[0].Interface())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode result: %v", err)
+	}
+	return string(resultJSON), nil
+}
+
+// schemaForType derives a JSON Schema from a Go type.
+func schemaForType(t reflect.Type) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaForType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map keys must be strings, got %s", t.Key())
+		}
+		values, err := schemaForType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return schemaForStruct(t)
+	default:
+		return nil, fmt.Errorf("unsupported parameter type %s", t)
+	}
+}
+
+func schemaForStruct(t reflect.Type) (map[string]interface{}, error) {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitempty := parseJSONTag(field)
+		if name == "-" {
+			continue
+		}
+
+		prop, err := schemaForType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.Name, err)
+		}
+		if desc := field.Tag.Get("description"); desc != "" {
+			prop["description"] = desc
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+
+		properties[name] = prop
+		if !omitempty {
+			required = append(required, name)
+		}
+	}
+
+	sort.Strings(required)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}, nil
+}
+
+// parseJSONTag returns a field's JSON name and whether it is omitempty.
+func parseJSONTag(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// validateAgainstSchema checks a decoded JSON value against the subset of
+// JSON Schema produced by schemaForType: type, properties, required, items,
+// additionalProperties, enum, and the date-time format. path names the value
+// in error messages. checkSchemaSupported rejects schemas that need more.
+func validateAgainstSchema(value interface{}, schema map[string]interface{}, path string) error {
+	if enum, ok := schema["enum"]; ok {
+		if err := checkEnum(value, enum, path); err != nil {
+			return err
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %s", path, jsonTypeName(value))
+		}
+		for _, name := range stringList(schema["required"]) {
+			if _, present := obj[name]; !present {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for key, v := range obj {
+			propSchema, known := properties[key].(map[string]interface{})
+			if !known {
+				propSchema = additional
+			}
+			if propSchema == nil {
+				if properties != nil {
+					return fmt.Errorf("%s: unknown field %q", path, key)
+				}
+				continue
+			}
+			if err := validateAgainstSchema(v, propSchema, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %s", path, jsonTypeName(value))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, v := range arr {
+				if err := validateAgainstSchema(v, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %s", path, jsonTypeName(value))
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("%s: %q is not an RFC 3339 date-time", path, s)
+			}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %s", path, jsonTypeName(value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %s", path, jsonTypeName(value))
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer, got %s", path, jsonTypeName(value))
+		}
+	}
+	return nil
+}
+
+// schemaTypes are the type keyword values validateAgainstSchema checks.
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "boolean": true, "number": true, "integer": true,
+}
+
+// checkSchemaSupported reports the first keyword in schema that
+// validateAgainstSchema would ignore, so a caller-supplied schema can't pass
+// invalid output as validated. Annotations (description, title, default,
+// examples) are allowed since they don't constrain values.
+func checkSchemaSupported(schema map[string]interface{}, path string) error {
+	keys := make([]string, 0, len(schema))
+	for key := range schema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := schema[key]
+		switch key {
+		case "description", "title", "default", "examples":
+		case "type":
+			if t, ok := v.(string); !ok || !schemaTypes[t] {
+				return fmt.Errorf("%s: type %v is not supported; use one of object, array, string, boolean, number, or integer", path, v)
+			}
+		case "format":
+			if v != "date-time" {
+				return fmt.Errorf("%s: format %v is not supported; only date-time is checked", path, v)
+			}
+		case "enum":
+			if !isStringList(v) {
+				return fmt.Errorf("%s: enum must list strings", path)
+			}
+		case "required":
+			if !isStringList(v) {
+				return fmt.Errorf("%s: required must list property names", path)
+			}
+		case "properties":
+			properties, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: properties must be an object", path)
+			}
+			for name, p := range properties {
+				propSchema, ok := p.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("%s.%s: schema must be an object", path, name)
+				}
+				if err := checkSchemaSupported(propSchema, path+"."+name); err != nil {
+					return err
+				}
+			}
+		case "items":
+			items, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: items must be a single schema", path)
+			}
+			if err := checkSchemaSupported(items, path+"[]"); err != nil {
+				return err
+			}
+		case "additionalProperties":
+			switch additional := v.(type) {
+			case bool:
+				if additional {
+					return fmt.Errorf("%s: additionalProperties: true is not supported; give a schema for the extra values", path)
+				}
+			case map[string]interface{}:
+				if err := checkSchemaSupported(additional, path+".*"); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%s: additionalProperties must be false or a schema", path)
+			}
+		default:
+			return fmt.Errorf("%s: keyword %q is not supported by the validator", path, key)
+		}
+	}
+	return nil
+}
+
+// isStringList reports whether v is a list containing only strings.
+func isStringList(v interface{}) bool {
+	switch list := v.(type) {
+	case []string:
+		return true
+	case []interface{}:
+		return len(stringList(list)) == len(list)
+	}
+	return false
+}
+
+func checkEnum(value interface{}, enum interface{}, path string) error {
+	allowed := stringList(enum)
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s: expected one of %s", path, strings.Join(allowed, ", "))
+	}
+	for _, a := range allowed {
+		if s == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %q is not one of %s", path, s, strings.Join(allowed, ", "))
+}
+
+// stringList converts a []string or []interface{} of strings to []string.
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// maxRepairAttempts is how many times the model is asked to fix tool
+// arguments that don't match the tool's schema before giving up. Override it
+// with TOOL_REPAIR_ATTEMPTS.
+var maxRepairAttempts = 2
+
+func init() {
+	if value, ok := os.LookupEnv("TOOL_REPAIR_ATTEMPTS"); ok {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			maxRepairAttempts = n
+		}
+	}
+}
+
+// ToolArgumentError reports a tool call whose arguments could not be parsed
+// or did not match the tool's schema.
+type ToolArgumentError struct {
+	Call ToolCall
+	Err  error
+}
+
+func (e *ToolArgumentError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s: %v", e.Call.Function.Name, e.Err)
+}
+
+func (e *ToolArgumentError) Unwrap() error {
+	return e.Err
+}
+
+// forcedToolChoice is the tool_choice value that makes the model call the
+// named tool.
+func forcedToolChoice(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "function",
+		"function": map[string]string{"name": name},
+	}
+}
+
+// repairMessage tells the model what was wrong with its arguments.
+func repairMessage(err error) string {
+	return fmt.Sprintf(`{"error": %q, "instructions": "The arguments did not match the tool's schema. Call the tool again with corrected arguments."}`,
+		err.Error())
+}
+
+// repairToolArguments sends the invalid tool call back to the model along with
+// the validation error and asks it to call the tool again, up to maxAttempts
+// times. On success the corrected arguments replace the original ones in
+// response, so the rest of the tool round trip sees a valid call.
+func repairToolArguments(messages []Message, response *ApiResponse, argErr *ToolArgumentError, maxAttempts int) (*ApiResponse, error) {
+	name := argErr.Call.Function.Name
+	lastErr := argErr.Err
+
+	// The repair conversation only carries the failing call, so the other
+	// calls in the original response aren't executed or answered twice.
+	conversation := append([]Message{}, messages...)
+	conversation = append(conversation,
+		Message{Role: "assistant", ToolCalls: []ToolCall{argErr.Call}},
+		Message{Role: "tool", Content: repairMessage(lastErr), ToolCallID: argErr.Call.ID},
+	)
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		repaired, err := sendCompletionRequest(conversation, toolRegistry.Definitions(), forcedToolChoice(name))
+		if err != nil {
+			return nil, err
+		}
+
+		var call *ToolCall
+		if len(repaired.Choices) > 0 {
+			for i, c := range repaired.Choices[0].Message.ToolCalls {
+				if c.Function.Name == name {
+					call = &repaired.Choices[0].Message.ToolCalls[i]
+					break
+				}
+			}
+		}
+		if call == nil {
+			lastErr = fmt.Errorf("expected a %s call in the repair response", name)
+			fmt.Printf("   Repair attempt %d/%d: %v\n", attempt, maxAttempts, lastErr)
+			continue
+		}
+
+		if err := toolRegistry.ValidateArguments(*call); err != nil {
+			lastErr = err
+			fmt.Printf("   Repair attempt %d/%d: %v\n", attempt, maxAttempts, err)
+			conversation = append(conversation,
+				Message{Role: "assistant", ToolCalls: []ToolCall{*call}},
+				Message{Role: "tool", Content: repairMessage(err), ToolCallID: call.ID},
+			)
+			continue
+		}
+
+		fmt.Printf("   Repair attempt %d/%d: arguments are valid\n", attempt, maxAttempts)
+		for i, c := range response.Choices[0].Message.ToolCalls {
+			if c.ID == argErr.Call.ID {
+				response.Choices[0].Message.ToolCalls[i].Function.Arguments = call.Function.Arguments
+			}
+		}
+		return response, nil
+	}
+
+	return nil, &ToolArgumentError{Call: argErr.Call, Err: fmt.Errorf("still invalid after %d repair attempt(s): %v", maxAttempts, lastErr)}
+}
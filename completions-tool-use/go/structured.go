@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// StructuredRequest asks the model for an answer that is a JSON document
+// matching Schema, rather than a tool call.
+type StructuredRequest struct {
+	Messages []Message
+	// Name identifies the schema to the API, e.g. "growth_plan".
+	Name string
+	// Schema is the JSON Schema the answer must match. SchemaFor derives one
+	// from a Go type. Only the keywords validateAgainstSchema checks are
+	// accepted.
+	Schema map[string]interface{}
+	// Strict asks the API to enforce the schema while generating. Strict
+	// schemas must list every property as required.
+	Strict bool
+	// MaxRepairs is how many times an invalid answer is sent back to the
+	// model with the validation error. Zero uses maxRepairAttempts.
+	MaxRepairs int
+}
+
+// SchemaFor derives a JSON Schema from a Go value's type using the same rules
+// as ToolRegistry parameter structs.
+func SchemaFor(v interface{}) (map[string]interface{}, error) {
+	return schemaForType(reflect.TypeOf(v))
+}
+
+// CompleteStructured requests JSON output matching req.Schema, validates the
+// answer against the schema, and decodes it into out. Answers that aren't
+// valid JSON or don't match the schema are sent back to the model with the
+// validation error, up to req.MaxRepairs times. Schemas using keywords the
+// validator can't check are rejected before anything is sent.
+func CompleteStructured(req StructuredRequest, out interface{}) error {
+	if err := checkSchemaSupported(req.Schema, req.Name); err != nil {
+		return fmt.Errorf("unsupported schema: %w", err)
+	}
+	maxRepairs := req.MaxRepairs
+	if maxRepairs == 0 {
+		maxRepairs = maxRepairAttempts
+	}
+
+	schema := req.Schema
+	if req.Strict {
+		schema = strictSchema(schema)
+	}
+	responseFormat := map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   req.Name,
+			"schema": schema,
+			"strict": req.Strict,
+		},
+	}
+
+	conversation := append([]Message{}, req.Messages...)
+	var lastErr error
+	for attempt := 0; attempt <= maxRepairs; attempt++ {
+		response, err := postCompletion(map[string]interface{}{
+			"auto_routing":    true,
+			"messages":        conversation,
+			"response_format": responseFormat,
+		})
+		if err != nil {
+			return err
+		}
+		if len(response.Choices) == 0 {
+			return fmt.Errorf("no choices in response")
+		}
+
+		content := response.Choices[0].Message.Content
+		lastErr = validateStructuredAnswer(content, schema)
+		if lastErr == nil {
+			return json.Unmarshal([]byte(stripCodeFence(content)), out)
+		}
+
+		if attempt < maxRepairs {
+			fmt.Printf("   Invalid response (%v); repair attempt %d/%d\n", lastErr, attempt+1, maxRepairs)
+		}
+		conversation = append(conversation,
+			Message{Role: "assistant", Content: content},
+			Message{Role: "user", Content: fmt.Sprintf(
+				"That response was invalid: %v. Reply again with only a JSON document that matches the %s schema.",
+				lastErr, req.Name)},
+		)
+	}
+
+	return fmt.Errorf("structured output still invalid after %d repair attempt(s): %v", maxRepairs, lastErr)
+}
+
+// validateStructuredAnswer checks that content is JSON matching schema.
+func validateStructuredAnswer(content string, schema map[string]interface{}) error {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(stripCodeFence(content)), &raw); err != nil {
+		return fmt.Errorf("response is not valid JSON: %v", err)
+	}
+	return validateAgainstSchema(raw, schema, "response")
+}
+
+// stripCodeFence removes a Markdown ```json fence some models wrap JSON in.
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	if i := strings.IndexByte(content, '\n'); i >= 0 {
+		content = content[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
+
+// strictSchema returns a copy of schema with "additionalProperties": false on
+// every object that lists its properties, as strict mode requires.
+func strictSchema(schema map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(schema)+1)
+	for k, v := range schema {
+		out[k] = v
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		strictProps := make(map[string]interface{}, len(props))
+		for name, p := range props {
+			if ps, ok := p.(map[string]interface{}); ok {
+				strictProps[name] = strictSchema(ps)
+			} else {
+				strictProps[name] = p
+			}
+		}
+		out["properties"] = strictProps
+		out["additionalProperties"] = false
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		out["items"] = strictSchema(items)
+	}
+	return out
+}
+
+// runStructuredExample builds a growth plan with structured output instead of
+// a tool call.
+func runStructuredExample(userGoal string) {
+	schema, err := SchemaFor(GrowthPlan{})
+	if err != nil {
+		fmt.Printf("Error building schema: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Creating growth plan with structured output for: '%s'\n", userGoal)
+	var plan GrowthPlan
+	err = CompleteStructured(StructuredRequest{
+		Messages: []Message{
+			{Role: "system", Content: "Create a personal growth plan for the user's goal."},
+			{Role: "user", Content: userGoal},
+		},
+		Name:   "growth_plan",
+		Schema: schema,
+		Strict: true,
+	}, &plan)
+	if err != nil {
+		fmt.Printf("Error creating growth plan: %v\n", err)
+		return
+	}
+
+	displayGrowthPlan(&plan)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- lookup_scripture ---
+
+// ScriptureLookup is the argument struct for the lookup_scripture tool.
+type ScriptureLookup struct {
+	Reference   string `json:"reference" description:"A Bible reference, e.g. 'Philippians 4:6-7'."`
+	Translation string `json:"translation,omitempty" description:"Preferred translation. Defaults to NIV." enum:"NIV,ESV,KJV"`
+}
+
+// ScripturePassage is the result of the lookup_scripture tool.
+type ScripturePassage struct {
+	Reference   string `json:"reference"`
+	Translation string `json:"translation"`
+	Text        string `json:"text"`
+}
+
+// scriptureLibrary is a small offline passage store for the example. A real
+// application would call a Bible API here.
+var scriptureLibrary = map[string]string{
+	"philippians 4:6-7": "Do not be anxious about anything, but in every situation, by prayer and petition, with thanksgiving, present your requests to God. And the peace of God, which transcends all understanding, will guard your hearts and your minds in Christ Jesus.",
+	"joshua 1:9":        "Have I not commanded you? Be strong and courageous. Do not be afraid; do not be discouraged, for the LORD your God will be with you wherever you go.",
+	"psalm 119:105":     "Your word is a lamp for my feet, a light on my path.",
+	"romans 12:2":       "Do not conform to the pattern of this world, but be transformed by the renewing of your mind. Then you will be able to test and approve what God's will is—his good, pleasing and perfect will.",
+	"james 1:5":         "If any of you lacks wisdom, you should ask God, who gives generously to all without finding fault, and it will be given to you.",
+}
+
+// executeLookupScripture implements the lookup_scripture tool.
+func executeLookupScripture(args ScriptureLookup) (ScripturePassage, error) {
+	key := strings.ToLower(strings.Join(strings.Fields(args.Reference), " "))
+	text, ok := scriptureLibrary[key]
+	if !ok {
+		known := make([]string, 0, len(scriptureLibrary))
+		for ref := range scriptureLibrary {
+			known = append(known, ref)
+		}
+		sort.Strings(known)
+		return ScripturePassage{}, fmt.Errorf("passage %q is not available; available passages: %s",
+			args.Reference, strings.Join(known, ", "))
+	}
+
+	// The offline library only has NIV text.
+	return ScripturePassage{Reference: args.Reference, Translation: "NIV", Text: text}, nil
+}
+
+// --- schedule_event ---
+
+// CalendarEvent is the argument struct for the schedule_event tool.
+type CalendarEvent struct {
+	Title      string `json:"title" description:"Short title for the calendar event."`
+	Date       string `json:"date" description:"Start date in YYYY-MM-DD format."`
+	Time       string `json:"time,omitempty" description:"Start time in 24-hour HH:MM format. Omit for an all-day event."`
+	Recurrence string `json:"recurrence,omitempty" description:"How often the event repeats." enum:"none,daily,weekly,monthly"`
+}
+
+// ScheduledEvent is the result of the schedule_event tool.
+type ScheduledEvent struct {
+	EventID    string `json:"event_id"`
+	Title      string `json:"title"`
+	Start      string `json:"start"`
+	Recurrence string `json:"recurrence"`
+}
+
+// calendar stands in for a calendar service in this example. Tool calls run
+// concurrently, so access is guarded by calendarMu.
+var (
+	calendarMu sync.Mutex
+	calendar   []ScheduledEvent
+)
+
+// executeScheduleEvent implements the schedule_event tool.
+func executeScheduleEvent(event CalendarEvent) (ScheduledEvent, error) {
+	layout, value := "2006-01-02", event.Date
+	if event.Time != "" {
+		layout, value = "2006-01-02 15:04", event.Date+" "+event.Time
+	}
+	start, err := time.Parse(layout, value)
+	if err != nil {
+		return ScheduledEvent{}, fmt.Errorf("invalid date/time %q: expected YYYY-MM-DD and optional HH:MM", value)
+	}
+
+	recurrence := event.Recurrence
+	if recurrence == "" {
+		recurrence = "none"
+	}
+
+	calendarMu.Lock()
+	defer calendarMu.Unlock()
+
+	scheduled := ScheduledEvent{
+		EventID:    fmt.Sprintf("event-%d", len(calendar)+1),
+		Title:      event.Title,
+		Start:      start.Format(layout),
+		Recurrence: recurrence,
+	}
+	calendar = append(calendar, scheduled)
+	return scheduled, nil
+}
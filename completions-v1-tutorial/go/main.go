@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -39,16 +41,19 @@ type ChatMessage struct {
 type ChatCompletionRequest struct {
 	Model    string        `json:"model"`
 	Messages []ChatMessage `json:"messages"`
+	GenerationParams
 }
 
 // ChatCompletionResponse represents the API response
 type ChatCompletionResponse struct {
+	Model   string `json:"model"`
 	Choices []struct {
 		Message struct {
 			Role    string `json:"role"`
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 }
 
 // Global token storage
@@ -64,7 +69,8 @@ func getEnv(key, fallback string) string {
 
 // getAccessToken retrieves a new access token from the Gloo AI API
 func getAccessToken() (*TokenInfo, error) {
-	data := strings.NewReader("grant_type=client_credentials&scope=api/access")
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {getEnv("GLOO_SCOPE", "api/access")}}
+	data := strings.NewReader(form.Encode())
 	req, err := http.NewRequest("POST", tokenURL, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -131,6 +137,7 @@ func makeChatCompletionRequest(message string) (*ChatCompletionResponse, error)
 		Messages: []ChatMessage{
 			{Role: "user", Content: message},
 		},
+		GenerationParams: generationParams,
 	}
 
 	reqBody, err := json.Marshal(request)
@@ -166,13 +173,15 @@ func makeChatCompletionRequest(message string) (*ChatCompletionResponse, error)
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	recordUsage(response.Model, response.Usage)
 
 	return &response, nil
 }
 
 // testCompletionsAPI tests the completions API with multiple examples
 func testCompletionsAPI() bool {
-	fmt.Println("=== Gloo AI Completions API Test ===\n")
+	fmt.Println("=== Gloo AI Completions API Test ===")
+	fmt.Println()
 
 	testMessages := []string{
 		"How can I be joyful in hard times?",
@@ -203,6 +212,13 @@ func testCompletionsAPI() bool {
 
 // main is the entry point
 func main() {
+	generationParams.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	if err := generationParams.Validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
 	// Load environment variables
 	err := godotenv.Load()
 	if err != nil {
@@ -222,4 +238,4 @@ func main() {
 	}
 
 	testCompletionsAPI()
-}
\ No newline at end of file
+}
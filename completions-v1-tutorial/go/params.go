@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GenerationParams are the optional sampling controls accepted by the
+// completions API. Nil fields are left out of the request so the API default
+// applies.
+type GenerationParams struct {
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	N                *int     `json:"n,omitempty"`
+}
+
+// generationParams holds the values set on the command line
+var generationParams GenerationParams
+
+// RegisterFlags adds a flag for each parameter to fs
+func (p *GenerationParams) RegisterFlags(fs *flag.FlagSet) {
+	fs.Func("max-tokens", "maximum number of tokens to generate", intFlag(&p.MaxTokens))
+	fs.Func("temperature", "sampling temperature, 0 to 2", floatFlag(&p.Temperature))
+	fs.Func("top-p", "nucleus sampling probability mass, 0 to 1", floatFlag(&p.TopP))
+	fs.Func("stop", "stop sequence (repeat for up to 4)", func(s string) error {
+		p.Stop = append(p.Stop, s)
+		return nil
+	})
+	fs.Func("presence-penalty", "penalty for tokens already present, -2 to 2", floatFlag(&p.PresencePenalty))
+	fs.Func("frequency-penalty", "penalty proportional to token frequency, -2 to 2", floatFlag(&p.FrequencyPenalty))
+	fs.Func("seed", "seed for best-effort deterministic sampling", intFlag(&p.Seed))
+	fs.Func("n", "number of choices to generate", intFlag(&p.N))
+}
+
+func intFlag(dst **int) func(string) error {
+	return func(s string) error {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+		*dst = &v
+		return nil
+	}
+}
+
+func floatFlag(dst **float64) func(string) error {
+	return func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number")
+		}
+		*dst = &v
+		return nil
+	}
+}
+
+// Validate checks that each set parameter is within the range the API accepts
+func (p GenerationParams) Validate() error {
+	var problems []string
+	if p.MaxTokens != nil && *p.MaxTokens < 1 {
+		problems = append(problems, "max_tokens must be at least 1")
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		problems = append(problems, "temperature must be between 0 and 2")
+	}
+	if p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1) {
+		problems = append(problems, "top_p must be between 0 and 1")
+	}
+	if len(p.Stop) > 4 {
+		problems = append(problems, "at most 4 stop sequences are allowed")
+	}
+	if p.PresencePenalty != nil && (*p.PresencePenalty < -2 || *p.PresencePenalty > 2) {
+		problems = append(problems, "presence_penalty must be between -2 and 2")
+	}
+	if p.FrequencyPenalty != nil && (*p.FrequencyPenalty < -2 || *p.FrequencyPenalty > 2) {
+		problems = append(problems, "frequency_penalty must be between -2 and 2")
+	}
+	if p.N != nil && *p.N < 1 {
+		problems = append(problems, "n must be at least 1")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid generation parameters: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
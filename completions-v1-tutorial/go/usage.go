@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Usage is the token usage block of a completion response
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// UsageRecord is one line of the usage log
+type UsageRecord struct {
+	Time             time.Time `json:"time"`
+	Session          string    `json:"session"`
+	Example          string    `json:"example"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+}
+
+// usageExample names this example in the usage log
+const usageExample = "completions-v1"
+
+// usageSession identifies this run of the program in the usage log
+var usageSession = time.Now().Format("20060102-150405") + "-" + usageExample
+
+// usageLogPath returns the usage log shared by the completions examples:
+// GLOO_USAGE_LOG if set, otherwise gloo-cookbook/usage.jsonl in the user's
+// cache directory
+func usageLogPath() string {
+	if path := os.Getenv("GLOO_USAGE_LOG"); path != "" {
+		return path
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gloo-cookbook", "usage.jsonl")
+}
+
+// recordUsage appends a response's token usage to the usage log read by
+// the completions-v2 "usage" report. Failures are ignored: usage tracking
+// must never break a completion.
+func recordUsage(model string, usage *Usage) {
+	path := usageLogPath()
+	if usage == nil || path == "" {
+		return
+	}
+	if model == "" {
+		model = "unknown"
+	}
+
+	line, err := json.Marshal(UsageRecord{
+		Time:             time.Now().UTC(),
+		Session:          usageSession,
+		Example:          usageExample,
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	})
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
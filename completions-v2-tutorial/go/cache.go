@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// ResponseCache stores completion responses on disk, keyed by a hash of the
+// full request payload (model or routing choice, messages, and generation
+// parameters). Only deterministic requests are cached, so repeated runs and
+// evaluation sweeps don't pay for identical completions twice.
+type ResponseCache struct {
+	Dir string
+}
+
+// completionCache is the cache used by makeRequest; nil disables caching
+var completionCache *ResponseCache
+
+// defaultCacheDir returns COMPLETIONS_CACHE_DIR if set, otherwise
+// gloo-cookbook/completions in the user's cache directory
+func defaultCacheDir() string {
+	if dir := os.Getenv("COMPLETIONS_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gloo-cookbook", "completions")
+}
+
+// cacheKey returns the cache key for a payload and whether the payload is
+// cacheable. Only requests with temperature 0 are cached: anything else is
+// expected to vary between calls. Streaming requests are never cached.
+func cacheKey(payload map[string]interface{}) (string, bool) {
+	if stream, _ := payload["stream"].(bool); stream {
+		return "", false
+	}
+	if !isZeroTemperature(payload["temperature"]) {
+		return "", false
+	}
+
+	// encoding/json sorts map keys, so equal payloads encode identically
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), true
+}
+
+func isZeroTemperature(v interface{}) bool {
+	switch t := v.(type) {
+	case float64:
+		return t == 0
+	case int:
+		return t == 0
+	}
+	return false
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// Get returns the cached response body for key
+func (c *ResponseCache) Get(key string) ([]byte, bool) {
+	if c == nil || c.Dir == "" {
+		return nil, false
+	}
+	body, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// Put stores a response body under key. Failures are ignored: a cache that
+// can't be written just means the next run makes the request again.
+func (c *ResponseCache) Put(key string, body []byte) {
+	if c == nil || c.Dir == "" {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return
+	}
+	// Write to a temporary file first so a concurrent reader never sees a
+	// partial response
+	tmp, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(body)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// describeModel returns the response's model, marked when it was served
+// from the cache
+func describeModel(r *V2CompletionResponse) string {
+	if r.Cached {
+		return r.Model + " (cached)"
+	}
+	return r.Model
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Conversation keeps the message history of a multi-turn chat and sends it
+// with each new turn. When the history grows past TokenBudget, the oldest
+// turns are dropped, or folded into a running summary if Summarize is set.
+type Conversation struct {
+	System      string
+	Messages    []ChatMessage
+	Summary     string
+	TokenBudget int
+	// KeepRecent is how many of the latest messages are never trimmed
+	KeepRecent int
+	Summarize  bool
+}
+
+// NewConversation creates a conversation with the given system prompt
+func NewConversation(system string, tokenBudget int, summarize bool) *Conversation {
+	return &Conversation{
+		System:      system,
+		TokenBudget: tokenBudget,
+		KeepRecent:  4,
+		Summarize:   summarize,
+	}
+}
+
+// estimateTokens approximates a token count at about four characters per
+// token plus a small per-message overhead. It only needs to be close enough
+// to keep requests under the budget.
+func estimateTokens(messages []ChatMessage) int {
+	total := 0
+	for _, m := range messages {
+		total += len(m.Content)/4 + 4
+	}
+	return total
+}
+
+// requestMessages returns the messages sent to the API: the system prompt
+// (with any summary of trimmed turns) followed by the history
+func (c *Conversation) requestMessages() []ChatMessage {
+	var messages []ChatMessage
+	system := c.System
+	if c.Summary != "" {
+		system = strings.TrimSpace(system + "\n\nSummary of the earlier conversation:\n" + c.Summary)
+	}
+	if system != "" {
+		messages = append(messages, ChatMessage{Role: "system", Content: system})
+	}
+	return append(messages, c.Messages...)
+}
+
+// Tokens returns the estimated size of the next request
+func (c *Conversation) Tokens() int {
+	return estimateTokens(c.requestMessages())
+}
+
+// Send adds a user message, trims the history to the token budget, and
+// returns the assistant's reply, which is also added to the history
+func (c *Conversation) Send(message string) (string, error) {
+	if err := screenInput(message); err != nil {
+		return "", err
+	}
+	c.Messages = append(c.Messages, ChatMessage{Role: "user", Content: message})
+	if err := c.fitBudget(); err != nil {
+		return "", err
+	}
+
+	payload := map[string]interface{}{
+		"messages":     c.requestMessages(),
+		"auto_routing": true,
+	}
+	response, err := makeRequest(generationParams.Apply(payload))
+	if err != nil {
+		// Drop the unanswered message so the user can retry it
+		c.Messages = c.Messages[:len(c.Messages)-1]
+		return "", err
+	}
+	if len(response.Choices) == 0 {
+		c.Messages = c.Messages[:len(c.Messages)-1]
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	reply := response.Choices[0].Message.Content
+	c.Messages = append(c.Messages, ChatMessage{Role: "assistant", Content: reply})
+	return reply, nil
+}
+
+// fitBudget trims the oldest messages until the request fits the token
+// budget, always keeping the most recent KeepRecent messages
+func (c *Conversation) fitBudget() error {
+	if c.TokenBudget <= 0 || c.Tokens() <= c.TokenBudget {
+		return nil
+	}
+
+	// Trim whole turns so the history never starts with an assistant reply
+	cut := 0
+	for cut < len(c.Messages)-c.KeepRecent {
+		cut++
+		if cut < len(c.Messages) && c.Messages[cut].Role == "assistant" {
+			continue
+		}
+		remaining := Conversation{System: c.System, Summary: c.Summary, Messages: c.Messages[cut:]}
+		if remaining.Tokens() <= c.TokenBudget {
+			break
+		}
+	}
+	for cut > 0 && cut < len(c.Messages) && c.Messages[cut].Role == "assistant" {
+		cut--
+	}
+	if cut == 0 {
+		return nil
+	}
+
+	trimmed := c.Messages[:cut]
+	c.Messages = append([]ChatMessage{}, c.Messages[cut:]...)
+
+	if !c.Summarize {
+		fmt.Printf("   (trimmed %d old message(s) to stay under %d tokens)\n", len(trimmed), c.TokenBudget)
+		return nil
+	}
+
+	summary, err := summarizeMessages(c.Summary, trimmed)
+	if err != nil {
+		return fmt.Errorf("failed to summarize earlier turns: %w", err)
+	}
+	c.Summary = summary
+	fmt.Printf("   (summarized %d old message(s) to stay under %d tokens)\n", len(trimmed), c.TokenBudget)
+	return nil
+}
+
+// summarizeMessages asks the model to fold trimmed messages into the
+// existing summary
+func summarizeMessages(previous string, messages []ChatMessage) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Existing summary:\n" + previous + "\n\n")
+	}
+	transcript.WriteString("New messages:\n")
+	for _, m := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+	}
+
+	payload := map[string]interface{}{
+		"messages": []ChatMessage{
+			{Role: "system", Content: "Summarize this conversation in a few sentences. Keep names, facts, and decisions the assistant will need later."},
+			{Role: "user", Content: transcript.String()},
+		},
+		"auto_routing": true,
+		"max_tokens":   300,
+	}
+	response, err := makeRequest(payload)
+	if err != nil {
+		return "", err
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
+
+// Reset clears the history and summary
+func (c *Conversation) Reset() {
+	c.Messages = nil
+	c.Summary = ""
+}
+
+// runChatREPL runs an interactive multi-turn chat on in and out
+func runChatREPL(conv *Conversation, in io.Reader, out io.Writer) {
+	fmt.Fprintln(out, "=== Gloo AI Completions V2 Chat ===")
+	fmt.Fprintln(out, "Type a message, or /history, /reset, /exit.")
+	fmt.Fprintln(out)
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "You: ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return
+		case "/reset":
+			conv.Reset()
+			fmt.Fprintln(out, "   (conversation cleared)")
+			continue
+		case "/history":
+			if conv.Summary != "" {
+				fmt.Fprintf(out, "   [summary] %s\n", conv.Summary)
+			}
+			for _, m := range conv.Messages {
+				fmt.Fprintf(out, "   [%s] %s\n", m.Role, truncate(m.Content, 100))
+			}
+			fmt.Fprintf(out, "   ~%d tokens\n", conv.Tokens())
+			continue
+		}
+
+		reply, err := conv.Send(line)
+		if err != nil {
+			fmt.Fprintf(out, "   ✗ %v\n", err)
+			continue
+		}
+		fmt.Fprintf(out, "Assistant: %s\n\n", reply)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FallbackOption is one routing choice in a fallback chain. Set exactly one
+// of Model, ModelFamily, or AutoRouting.
+type FallbackOption struct {
+	Model       string
+	ModelFamily string
+	AutoRouting bool
+}
+
+// String describes the option the way it is written in COMPLETIONS_FALLBACK
+func (o FallbackOption) String() string {
+	switch {
+	case o.Model != "":
+		return "model:" + o.Model
+	case o.ModelFamily != "":
+		return "family:" + o.ModelFamily
+	default:
+		return "auto"
+	}
+}
+
+// apply sets the option's routing parameter on a copy of payload, replacing
+// any routing parameter already there
+func (o FallbackOption) apply(payload map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		out[k] = v
+	}
+	delete(out, "model")
+	delete(out, "model_family")
+	delete(out, "auto_routing")
+
+	switch {
+	case o.Model != "":
+		out["model"] = o.Model
+	case o.ModelFamily != "":
+		out["model_family"] = o.ModelFamily
+	default:
+		out["auto_routing"] = true
+	}
+	return out
+}
+
+// FallbackPolicy is an ordered list of routing choices. If a request on one
+// option errors, times out, or is rate-limited, it is retried on the next.
+type FallbackPolicy struct {
+	Options []FallbackOption
+	// AttemptTimeout bounds each attempt, so a slow model doesn't use up the
+	// whole request budget before the next option is tried
+	AttemptTimeout time.Duration
+}
+
+// defaultFallbackSpec is used when COMPLETIONS_FALLBACK is not set
+const defaultFallbackSpec = "model:gloo-anthropic-claude-sonnet-4.5,family:openai,auto"
+
+// parseFallbackPolicy parses a comma-separated chain such as
+// "model:gloo-anthropic-claude-sonnet-4.5,family:openai,auto"
+func parseFallbackPolicy(spec string, attemptTimeout time.Duration) (FallbackPolicy, error) {
+	policy := FallbackPolicy{AttemptTimeout: attemptTimeout}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, value, _ := strings.Cut(part, ":")
+		switch {
+		case kind == "model" && value != "":
+			policy.Options = append(policy.Options, FallbackOption{Model: value})
+		case kind == "family" && value != "":
+			policy.Options = append(policy.Options, FallbackOption{ModelFamily: value})
+		case part == "auto":
+			policy.Options = append(policy.Options, FallbackOption{AutoRouting: true})
+		default:
+			return FallbackPolicy{}, fmt.Errorf("invalid fallback option %q: expected model:<name>, family:<name>, or auto", part)
+		}
+	}
+	if len(policy.Options) == 0 {
+		return FallbackPolicy{}, fmt.Errorf("fallback chain is empty")
+	}
+	return policy, nil
+}
+
+// FallbackAttempt records an option that failed
+type FallbackAttempt struct {
+	Option FallbackOption
+	Err    error
+}
+
+// FallbackResult is a successful response along with the option that
+// produced it and the options that failed before it
+type FallbackResult struct {
+	Response *V2CompletionResponse
+	Option   FallbackOption
+	Failed   []FallbackAttempt
+}
+
+// shouldFallback reports whether a failed attempt should move on to the next
+// option. Authentication errors would fail on every option, so they stop the
+// chain; anything else (rate limits, server errors, timeouts, an unavailable
+// model) falls through.
+func shouldFallback(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode != http.StatusUnauthorized && apiErr.StatusCode != http.StatusForbidden
+	}
+	return true
+}
+
+// makeRequestWithFallback sends payload on each option of the policy in turn
+// until one succeeds
+func makeRequestWithFallback(payload map[string]interface{}, policy FallbackPolicy) (*FallbackResult, error) {
+	timeout := policy.AttemptTimeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	result := &FallbackResult{}
+	for _, option := range policy.Options {
+		response, err := makeRequestWithTimeout(option.apply(payload), timeout)
+		if err == nil {
+			result.Response = response
+			result.Option = option
+			return result, nil
+		}
+
+		result.Failed = append(result.Failed, FallbackAttempt{Option: option, Err: err})
+		if !shouldFallback(err) {
+			return result, err
+		}
+	}
+
+	return result, fmt.Errorf("all %d fallback options failed; last error: %w",
+		len(result.Failed), result.Failed[len(result.Failed)-1].Err)
+}
+
+// makeV2WithFallback - Example 5: Fallback chain
+func makeV2WithFallback(message string, policy FallbackPolicy) (*FallbackResult, error) {
+	payload := map[string]interface{}{
+		"messages": []map[string]string{{"role": "user", "content": message}},
+	}
+	return makeRequestWithFallback(generationParams.Apply(payload), policy)
+}
+
+// runFallbackExample sends a message through the configured fallback chain
+// and reports which option answered
+func runFallbackExample(message string) bool {
+	fmt.Println("=== Gloo AI Completions V2 Fallback Chain ===")
+	fmt.Println()
+
+	spec := getEnv("COMPLETIONS_FALLBACK", defaultFallbackSpec)
+	policy, err := parseFallbackPolicy(spec, 30*time.Second)
+	if err != nil {
+		fmt.Printf("   ✗ %v\n", err)
+		return false
+	}
+	fmt.Printf("Chain: %s\n", spec)
+	fmt.Printf("Prompt: %s\n\n", message)
+
+	if err := screenInput(message); err != nil {
+		fmt.Printf("   ✗ %v\n", err)
+		return false
+	}
+
+	result, err := makeV2WithFallback(message, policy)
+	for _, failed := range result.Failed {
+		fmt.Printf("   ✗ %s failed: %v\n", failed.Option, failed.Err)
+	}
+	if err != nil {
+		fmt.Printf("   ✗ Fallback chain failed: %v\n", err)
+		return false
+	}
+
+	fmt.Printf("   Answered by: %s\n", result.Option)
+	fmt.Printf("   Model used: %s\n", describeModel(result.Response))
+	if len(result.Response.Choices) > 0 {
+		fmt.Printf("   Response: %s\n", truncate(result.Response.Choices[0].Message.Content, 100))
+	}
+	fmt.Println("   ✓ Fallback test passed")
+	return true
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -45,6 +47,9 @@ type V2CompletionResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+	// Cached is set when the response came from the local response cache
+	Cached bool `json:"-"`
 }
 
 // Global token storage
@@ -60,7 +65,8 @@ func getEnv(key, fallback string) string {
 
 // getAccessToken retrieves a new access token from the Gloo AI API
 func getAccessToken() (*TokenInfo, error) {
-	data := strings.NewReader("grant_type=client_credentials&scope=api/access")
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {getEnv("GLOO_SCOPE", "api/access")}}
+	data := strings.NewReader(form.Encode())
 	req, err := http.NewRequest("POST", tokenURL, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -115,8 +121,36 @@ func ensureValidToken() (string, error) {
 	return tokenInfo.AccessToken, nil
 }
 
+// APIError is returned when the completions API responds with a non-200 status
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API call failed: %s - %s", e.Status, e.Body)
+}
+
 // makeRequest makes an API request
 func makeRequest(payload map[string]interface{}) (*V2CompletionResponse, error) {
+	return makeRequestWithTimeout(payload, 60*time.Second)
+}
+
+// makeRequestWithTimeout makes an API request that fails if no response
+// arrives within timeout
+func makeRequestWithTimeout(payload map[string]interface{}, timeout time.Duration) (*V2CompletionResponse, error) {
+	key, cacheable := cacheKey(payload)
+	if cacheable {
+		if body, ok := completionCache.Get(key); ok {
+			var response V2CompletionResponse
+			if err := json.Unmarshal(body, &response); err == nil {
+				response.Cached = true
+				return &response, nil
+			}
+		}
+	}
+
 	token, err := ensureValidToken()
 	if err != nil {
 		return nil, err
@@ -135,7 +169,7 @@ func makeRequest(payload map[string]interface{}) (*V2CompletionResponse, error)
 	req.Header.Add("Authorization", "Bearer "+token)
 	req.Header.Add("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 60 * time.Second}
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
@@ -148,13 +182,17 @@ func makeRequest(payload map[string]interface{}) (*V2CompletionResponse, error)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API call failed: %s - %s", resp.Status, string(body))
+		return nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
 	}
 
 	var response V2CompletionResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	recordUsage(response.Model, response.Usage)
+	if cacheable {
+		completionCache.Put(key, body)
+	}
 
 	return &response, nil
 }
@@ -166,7 +204,7 @@ func makeV2AutoRouting(message, tradition string) (*V2CompletionResponse, error)
 		"auto_routing": true,
 		"tradition":    tradition,
 	}
-	return makeRequest(payload)
+	return makeRequest(generationParams.Apply(payload))
 }
 
 // makeV2ModelFamily - Example 2: Model family selection
@@ -175,18 +213,20 @@ func makeV2ModelFamily(message, modelFamily string) (*V2CompletionResponse, erro
 		"messages":     []map[string]string{{"role": "user", "content": message}},
 		"model_family": modelFamily,
 	}
-	return makeRequest(payload)
+	return makeRequest(generationParams.Apply(payload))
 }
 
 // makeV2DirectModel - Example 3: Direct model selection
 func makeV2DirectModel(message, model string) (*V2CompletionResponse, error) {
 	payload := map[string]interface{}{
-		"messages":    []map[string]string{{"role": "user", "content": message}},
-		"model":       model,
-		"temperature": 0.7,
-		"max_tokens":  500,
+		"messages": []map[string]string{{"role": "user", "content": message}},
+		"model":    model,
 	}
-	return makeRequest(payload)
+	params := generationParams.WithDefaults(GenerationParams{
+		Temperature: floatPtr(0.7),
+		MaxTokens:   intPtr(500),
+	})
+	return makeRequest(params.Apply(payload))
 }
 
 // truncate truncates a string to a maximum length
@@ -199,7 +239,8 @@ func truncate(s string, maxLen int) string {
 
 // testCompletionsV2API tests the Completions V2 API with all three routing strategies
 func testCompletionsV2API() bool {
-	fmt.Println("=== Gloo AI Completions V2 API Test ===\n")
+	fmt.Println("=== Gloo AI Completions V2 API Test ===")
+	fmt.Println()
 
 	// Example 1: Auto-routing
 	fmt.Println("Example 1: Auto-Routing")
@@ -209,10 +250,11 @@ func testCompletionsV2API() bool {
 		fmt.Printf("   ✗ Auto-routing failed: %v\n", err)
 		return false
 	}
-	fmt.Printf("   Model used: %s\n", result1.Model)
+	fmt.Printf("   Model used: %s\n", describeModel(result1))
 	fmt.Printf("   Routing: %s\n", result1.RoutingMechanism)
 	fmt.Printf("   Response: %s\n", truncate(result1.Choices[0].Message.Content, 100))
-	fmt.Println("   ✓ Auto-routing test passed\n")
+	fmt.Println("   ✓ Auto-routing test passed")
+	fmt.Println()
 
 	// Example 2: Model family selection
 	fmt.Println("Example 2: Model Family Selection")
@@ -222,9 +264,10 @@ func testCompletionsV2API() bool {
 		fmt.Printf("   ✗ Model family failed: %v\n", err)
 		return false
 	}
-	fmt.Printf("   Model used: %s\n", result2.Model)
+	fmt.Printf("   Model used: %s\n", describeModel(result2))
 	fmt.Printf("   Response: %s\n", truncate(result2.Choices[0].Message.Content, 100))
-	fmt.Println("   ✓ Model family test passed\n")
+	fmt.Println("   ✓ Model family test passed")
+	fmt.Println()
 
 	// Example 3: Direct model selection
 	fmt.Println("Example 3: Direct Model Selection")
@@ -234,9 +277,10 @@ func testCompletionsV2API() bool {
 		fmt.Printf("   ✗ Direct model failed: %v\n", err)
 		return false
 	}
-	fmt.Printf("   Model used: %s\n", result3.Model)
+	fmt.Printf("   Model used: %s\n", describeModel(result3))
 	fmt.Printf("   Response: %s\n", truncate(result3.Choices[0].Message.Content, 100))
-	fmt.Println("   ✓ Direct model test passed\n")
+	fmt.Println("   ✓ Direct model test passed")
+	fmt.Println()
 
 	fmt.Println("=== All Completions V2 tests passed! ===")
 	return true
@@ -244,12 +288,52 @@ func testCompletionsV2API() bool {
 
 // main is the entry point
 func main() {
+	generationParams.RegisterFlags(flag.CommandLine)
+	tokenBudget := flag.Int("token-budget", 2000, "chat: trim history when a request would exceed this many tokens (0 disables)")
+	summarize := flag.Bool("summarize", false, "chat: summarize trimmed turns instead of dropping them")
+	noCache := flag.Bool("no-cache", false, "always call the API, even for temperature 0 requests")
+	moderate := flag.String("moderate", "", "screen input first and block these categories (comma-separated, or \"all\")")
+	flag.Parse()
+	if err := generationParams.Validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	args := flag.Args()
+
 	// Load environment variables
 	err := godotenv.Load()
 	if err != nil {
 		fmt.Println("No .env file found, using environment variables")
 	}
 
+	if !*noCache {
+		completionCache = &ResponseCache{Dir: defaultCacheDir()}
+	}
+
+	// Screen user input before it is sent, if a moderation policy is set
+	moderationSpec := *moderate
+	if moderationSpec == "" {
+		moderationSpec = os.Getenv("MODERATION_BLOCK")
+	}
+	if moderationSpec != "" {
+		policy, err := parseModerationPolicy(moderationSpec)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		moderationPolicy = policy
+	}
+
+	// The usage report only reads the local log and needs no credentials
+	if len(args) > 0 && args[0] == "usage" {
+		session := ""
+		if len(args) > 1 {
+			session = args[1]
+		}
+		runUsageReport(session)
+		return
+	}
+
 	// Set configuration
 	clientID = getEnv("GLOO_CLIENT_ID", "YOUR_CLIENT_ID")
 	clientSecret = getEnv("GLOO_CLIENT_SECRET", "YOUR_CLIENT_SECRET")
@@ -262,5 +346,43 @@ func main() {
 		return
 	}
 
+	if len(args) > 0 && args[0] == "stream" {
+		message := "Write a short prayer of gratitude for a new morning."
+		if len(args) > 1 {
+			message = strings.Join(args[1:], " ")
+		}
+		runStreamingExample(message)
+		return
+	}
+
+	if len(args) > 0 && args[0] == "moderate" {
+		text := "How can I support a friend who is grieving?"
+		if len(args) > 1 {
+			text = strings.Join(args[1:], " ")
+		}
+		runModerationExample(text)
+		return
+	}
+
+	if len(args) > 0 && args[0] == "template" {
+		runTemplateCommand(args[1:])
+		return
+	}
+
+	if len(args) > 0 && args[0] == "chat" {
+		conv := NewConversation("You are a warm, thoughtful assistant for a church community.", *tokenBudget, *summarize)
+		runChatREPL(conv, os.Stdin, os.Stdout)
+		return
+	}
+
+	if len(args) > 0 && args[0] == "fallback" {
+		message := "Suggest three questions for a small group discussion on Psalm 23."
+		if len(args) > 1 {
+			message = strings.Join(args[1:], " ")
+		}
+		runFallbackExample(message)
+		return
+	}
+
 	testCompletionsV2API()
 }
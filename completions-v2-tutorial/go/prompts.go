@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// builtinPrompts holds the templates shipped with the example
+//
+//go:embed prompts/*.txt
+var builtinPrompts embed.FS
+
+// PromptTemplate is a named, reusable prompt. Template files start with a
+// header between "---" lines:
+//
+//	---
+//	description: Short daily devotion on a theme
+//	system: You write warm, concise daily devotions.
+//	vars: theme, verse=, words=300
+//	---
+//	Write a devotion of about {{.words}} words on {{.theme}}.
+//
+// Variables listed without "=" are required; the others have defaults.
+type PromptTemplate struct {
+	Name        string
+	Description string
+	System      string
+	Required    []string
+	Defaults    map[string]string
+	body        *template.Template
+}
+
+// parsePromptTemplate parses a template file's header and body
+func parsePromptTemplate(name, content string) (*PromptTemplate, error) {
+	t := &PromptTemplate{Name: name, Defaults: map[string]string{}}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return nil, fmt.Errorf("prompt %s: missing --- header", name)
+	}
+	closed := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "---" {
+			closed = true
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("prompt %s: invalid header line %q", name, line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "description":
+			t.Description = value
+		case "system":
+			t.System = value
+		case "vars":
+			for _, v := range strings.Split(value, ",") {
+				v = strings.TrimSpace(v)
+				if v == "" {
+					continue
+				}
+				if k, def, hasDefault := strings.Cut(v, "="); hasDefault {
+					t.Defaults[strings.TrimSpace(k)] = strings.TrimSpace(def)
+				} else {
+					t.Required = append(t.Required, v)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("prompt %s: unknown header field %q", name, key)
+		}
+	}
+	if !closed {
+		return nil, fmt.Errorf("prompt %s: header is not closed with ---", name)
+	}
+
+	var body strings.Builder
+	for scanner.Scan() {
+		body.WriteString(scanner.Text() + "\n")
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(strings.TrimSpace(body.String()))
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", name, err)
+	}
+	t.body = tmpl
+	return t, nil
+}
+
+// Render fills in the template's variables. Every required variable must be
+// set; unknown variables are rejected so typos don't go unnoticed.
+func (t *PromptTemplate) Render(vars map[string]string) (string, error) {
+	data := make(map[string]string, len(t.Defaults)+len(vars))
+	for k, v := range t.Defaults {
+		data[k] = v
+	}
+	for k, v := range vars {
+		if _, known := t.Defaults[k]; !known && !contains(t.Required, k) {
+			return "", fmt.Errorf("prompt %s has no variable %q", t.Name, k)
+		}
+		data[k] = v
+	}
+
+	var missing []string
+	for _, k := range t.Required {
+		if data[k] == "" {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("prompt %s needs --var for: %s", t.Name, strings.Join(missing, ", "))
+	}
+
+	var out strings.Builder
+	if err := t.body.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", t.Name, err)
+	}
+	return out.String(), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPromptTemplates loads the built-in templates plus any *.txt files in
+// dir, which take precedence so curated prompts can be added or edited
+// without rebuilding
+func loadPromptTemplates(dir string) (map[string]*PromptTemplate, error) {
+	templates := map[string]*PromptTemplate{}
+
+	load := func(fsys fs.FS, pattern string) error {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			content, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSuffix(path.Base(file), ".txt")
+			t, err := parsePromptTemplate(name, string(content))
+			if err != nil {
+				return err
+			}
+			templates[name] = t
+		}
+		return nil
+	}
+
+	if err := load(builtinPrompts, "prompts/*.txt"); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := load(os.DirFS(dir), "*.txt"); err != nil {
+			return nil, fmt.Errorf("failed to load prompts from %s: %w", dir, err)
+		}
+	}
+	return templates, nil
+}
+
+// varFlags collects repeated --var key=value flags
+type varFlags map[string]string
+
+func (v varFlags) String() string { return fmt.Sprint(map[string]string(v)) }
+
+func (v varFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected key=value")
+	}
+	v[strings.TrimSpace(key)] = value
+	return nil
+}
+
+// runTemplateCommand implements "template list" and
+// "template <name> --var key=value ..."
+func runTemplateCommand(args []string) bool {
+	templates, err := loadPromptTemplates(getEnv("PROMPTS_DIR", ""))
+	if err != nil {
+		fmt.Printf("   ✗ %v\n", err)
+		return false
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		names := make([]string, 0, len(templates))
+		for name := range templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println("Available prompt templates:")
+		for _, name := range names {
+			t := templates[name]
+			vars := append([]string{}, t.Required...)
+			for k, v := range t.Defaults {
+				vars = append(vars, fmt.Sprintf("%s=%q", k, v))
+			}
+			sort.Strings(vars)
+			fmt.Printf("  %-16s %s\n", name, t.Description)
+			fmt.Printf("  %-16s vars: %s\n", "", strings.Join(vars, ", "))
+		}
+		return true
+	}
+
+	name := args[0]
+	t, ok := templates[name]
+	if !ok {
+		fmt.Printf("   ✗ Unknown prompt template %q (run \"template list\")\n", name)
+		return false
+	}
+
+	vars := varFlags{}
+	flags := flag.NewFlagSet("template "+name, flag.ContinueOnError)
+	flags.Var(vars, "var", "template variable as key=value (repeatable)")
+	showPrompt := flags.Bool("show", false, "print the rendered prompt without sending it")
+	if err := flags.Parse(args[1:]); err != nil {
+		return false
+	}
+
+	prompt, err := t.Render(vars)
+	if err != nil {
+		fmt.Printf("   ✗ %v\n", err)
+		return false
+	}
+
+	fmt.Printf("=== Prompt template: %s ===\n\n%s\n\n", name, prompt)
+	if *showPrompt {
+		return true
+	}
+
+	if err := screenInput(prompt); err != nil {
+		fmt.Printf("   ✗ %v\n", err)
+		return false
+	}
+
+	var messages []ChatMessage
+	if t.System != "" {
+		messages = append(messages, ChatMessage{Role: "system", Content: t.System})
+	}
+	messages = append(messages, ChatMessage{Role: "user", Content: prompt})
+	payload := map[string]interface{}{
+		"messages":     messages,
+		"auto_routing": true,
+	}
+
+	result, err := makeRequest(generationParams.Apply(payload))
+	if err != nil {
+		fmt.Printf("   ✗ Completion failed: %v\n", err)
+		return false
+	}
+	if len(result.Choices) == 0 {
+		fmt.Println("   ✗ No choices in response")
+		return false
+	}
+
+	fmt.Println(result.Choices[0].Message.Content)
+	fmt.Println()
+	fmt.Printf("   Model used: %s\n", describeModel(result))
+	return true
+}
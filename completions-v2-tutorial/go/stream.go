@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// StreamDelta is one incremental piece of a streamed completion
+type StreamDelta struct {
+	Content      string
+	FinishReason string
+	Model        string
+}
+
+// streamChunk represents one SSE "data:" payload from the V2 API
+type streamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// StreamResult is the accumulated result of a streamed completion
+type StreamResult struct {
+	Content      string
+	Model        string
+	FinishReason string
+}
+
+// parseSSEData extracts the payload of an SSE "data:" line.
+// It returns ok=false for blank lines, comments, and other SSE fields.
+func parseSSEData(line string) (data string, ok bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "data:")), true
+}
+
+// readSSEStream reads an SSE body and calls onDelta for every chunk that
+// carries content or a finish reason. It stops at a finish reason, a [DONE]
+// sentinel, or the end of the body.
+func readSSEStream(body io.Reader, onDelta func(StreamDelta)) (*StreamResult, error) {
+	result := &StreamResult{}
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, ok := parseSSEData(scanner.Text())
+		if !ok || data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := StreamDelta{Content: chunk.Choices[0].Delta.Content, Model: result.Model}
+		if chunk.Choices[0].FinishReason != nil {
+			delta.FinishReason = *chunk.Choices[0].FinishReason
+		}
+		if delta.Content == "" && delta.FinishReason == "" {
+			continue
+		}
+
+		content.WriteString(delta.Content)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+		if delta.FinishReason != "" {
+			result.FinishReason = delta.FinishReason
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// makeStreamingRequest sends a payload with "stream": true and calls onDelta
+// as each token arrives. The full response is returned once the stream ends.
+func makeStreamingRequest(payload map[string]interface{}, onDelta func(StreamDelta)) (*StreamResult, error) {
+	token, err := ensureValidToken()
+	if err != nil {
+		return nil, err
+	}
+
+	streamPayload := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		streamPayload[k] = v
+	}
+	streamPayload["stream"] = true
+
+	jsonPayload, err := json.Marshal(streamPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "text/event-stream")
+
+	// No overall client timeout: a long answer can stream for minutes.
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API call failed: %s - %s", resp.Status, string(body))
+	}
+
+	return readSSEStream(resp.Body, onDelta)
+}
+
+// streamDeltas is the channel form of makeStreamingRequest. The deltas
+// channel is closed when the stream ends; the final result or error is then
+// sent on the done channel.
+func streamDeltas(payload map[string]interface{}) (<-chan StreamDelta, <-chan error) {
+	deltas := make(chan StreamDelta)
+	done := make(chan error, 1)
+
+	go func() {
+		defer close(deltas)
+		_, err := makeStreamingRequest(payload, func(d StreamDelta) {
+			deltas <- d
+		})
+		done <- err
+	}()
+
+	return deltas, done
+}
+
+// makeV2Streaming - Example 4: Streaming with auto-routing
+func makeV2Streaming(message string, onDelta func(StreamDelta)) (*StreamResult, error) {
+	payload := map[string]interface{}{
+		"messages":     []map[string]string{{"role": "user", "content": message}},
+		"auto_routing": true,
+	}
+	return makeStreamingRequest(generationParams.Apply(payload), onDelta)
+}
+
+// runStreamingExample prints a streamed completion token by token
+func runStreamingExample(message string) bool {
+	fmt.Println("=== Gloo AI Completions V2 Streaming ===")
+	fmt.Println()
+	fmt.Printf("Prompt: %s\n\n", message)
+
+	if err := screenInput(message); err != nil {
+		fmt.Printf("   ✗ %v\n", err)
+		return false
+	}
+
+	result, err := makeV2Streaming(message, func(d StreamDelta) {
+		fmt.Print(d.Content)
+	})
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("\n   ✗ Streaming failed: %v\n", err)
+		return false
+	}
+
+	fmt.Println()
+	if result.Model != "" {
+		fmt.Printf("   Model used: %s\n", result.Model)
+	}
+	fmt.Printf("   Finish reason: %s\n", result.FinishReason)
+	fmt.Printf("   Characters received: %d\n", len(result.Content))
+	fmt.Println("   ✓ Streaming test passed")
+	return true
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Usage is the token usage block of a completion response
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// UsageRecord is one line of the usage log
+type UsageRecord struct {
+	Time             time.Time `json:"time"`
+	Session          string    `json:"session"`
+	Example          string    `json:"example"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+}
+
+// usageExample names this example in the usage log
+const usageExample = "completions-v2"
+
+// usageSession identifies this run of the program in the usage log
+var usageSession = time.Now().Format("20060102-150405") + "-" + usageExample
+
+// usageLogPath returns the usage log shared by the completions examples:
+// GLOO_USAGE_LOG if set, otherwise gloo-cookbook/usage.jsonl in the user's
+// cache directory
+func usageLogPath() string {
+	if path := os.Getenv("GLOO_USAGE_LOG"); path != "" {
+		return path
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gloo-cookbook", "usage.jsonl")
+}
+
+// recordUsage appends a response's token usage to the usage log. Failures
+// are ignored: usage tracking must never break a completion.
+func recordUsage(model string, usage *Usage) {
+	path := usageLogPath()
+	if usage == nil || path == "" {
+		return
+	}
+	if model == "" {
+		model = "unknown"
+	}
+
+	line, err := json.Marshal(UsageRecord{
+		Time:             time.Now().UTC(),
+		Session:          usageSession,
+		Example:          usageExample,
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	})
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// readUsageLog reads every record in the usage log
+func readUsageLog(path string) ([]UsageRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// ModelPrice is the price per million tokens for one model
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// loadPrices reads a JSON object mapping model names to ModelPrice. A
+// missing file means no prices, so the report shows tokens only.
+func loadPrices(path string) (map[string]ModelPrice, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var prices map[string]ModelPrice
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+type usageTotals struct {
+	requests         int
+	promptTokens     int
+	completionTokens int
+}
+
+// runUsageReport prints per-session, per-model token totals and estimated
+// spend. If session is empty, every session in the log is included.
+func runUsageReport(session string) bool {
+	path := usageLogPath()
+	records, err := readUsageLog(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("No usage recorded yet (%s)\n", path)
+		return true
+	}
+	if err != nil {
+		fmt.Printf("   ✗ Failed to read usage log: %v\n", err)
+		return false
+	}
+
+	prices, err := loadPrices(getEnv("GLOO_USAGE_PRICES", "usage-prices.json"))
+	if err != nil {
+		fmt.Printf("   ✗ %v\n", err)
+		return false
+	}
+
+	totals := map[string]map[string]*usageTotals{}
+	for _, r := range records {
+		if session != "" && r.Session != session {
+			continue
+		}
+		if totals[r.Session] == nil {
+			totals[r.Session] = map[string]*usageTotals{}
+		}
+		t := totals[r.Session][r.Model]
+		if t == nil {
+			t = &usageTotals{}
+			totals[r.Session][r.Model] = t
+		}
+		t.requests++
+		t.promptTokens += r.PromptTokens
+		t.completionTokens += r.CompletionTokens
+	}
+
+	fmt.Println("=== Gloo AI Completions Usage ===")
+	fmt.Printf("Log: %s\n", path)
+	if len(totals) == 0 {
+		fmt.Println("No matching usage records")
+		return true
+	}
+
+	sessions := make([]string, 0, len(totals))
+	for s := range totals {
+		sessions = append(sessions, s)
+	}
+	sort.Strings(sessions)
+
+	var grandTotal float64
+	unpriced := false
+	for _, s := range sessions {
+		fmt.Printf("\nSession %s\n", s)
+		fmt.Printf("  %-40s %8s %10s %10s %10s\n", "Model", "Requests", "Prompt", "Completion", "Est. cost")
+
+		models := make([]string, 0, len(totals[s]))
+		for m := range totals[s] {
+			models = append(models, m)
+		}
+		sort.Strings(models)
+
+		var sessionTotal float64
+		for _, m := range models {
+			t := totals[s][m]
+			cost := "n/a"
+			if price, ok := prices[m]; ok {
+				c := (float64(t.promptTokens)*price.Input + float64(t.completionTokens)*price.Output) / 1e6
+				sessionTotal += c
+				cost = fmt.Sprintf("$%.4f", c)
+			} else {
+				unpriced = true
+			}
+			fmt.Printf("  %-40s %8d %10d %10d %10s\n", m, t.requests, t.promptTokens, t.completionTokens, cost)
+		}
+		fmt.Printf("  Session estimate: $%.4f\n", sessionTotal)
+		grandTotal += sessionTotal
+	}
+
+	fmt.Printf("\nTotal estimate: $%.4f\n", grandTotal)
+	if unpriced {
+		fmt.Println("Models marked n/a have no price in GLOO_USAGE_PRICES and are not included in the estimates.")
+	}
+	return true
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API Endpoints
+var (
+	tokenURL    = "https://platform.ai.gloo.com/oauth2/token"
+	searchURL   = "https://platform.ai.gloo.com/ai/data/v1/search"
+	groundedURL = "https://platform.ai.gloo.com/ai/v2/chat/completions/grounded"
+	uploadURL   = "https://platform.ai.gloo.com/ingestion/v2/files"
+)
+
+// supportedExtensions are the file types the Data Engine ingests
+var supportedExtensions = map[string]bool{
+	".txt":  true,
+	".md":   true,
+	".pdf":  true,
+	".doc":  true,
+	".docx": true,
+}
+
+// apiTimeout bounds each Gloo AI call, so a stuck request doesn't leave the
+// MCP client waiting forever
+const apiTimeout = 120 * time.Second
+
+// TokenManager holds the access token shared by every tool call
+type TokenManager struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+var tokenManager = &TokenManager{}
+
+// Token returns a valid access token, fetching a new one if the current one
+// is within a minute of expiring or force is set
+func (tm *TokenManager) Token(force bool) (string, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if !force && tm.token != "" && time.Until(tm.expiresAt) > time.Minute {
+		return tm.token, nil
+	}
+
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {scope},
+	}.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(clientID, clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get access token: %s - %s", resp.Status, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("failed to parse token response: %s", string(body))
+	}
+	if token.ExpiresIn <= 0 {
+		token.ExpiresIn = 3600
+	}
+	tm.token = token.AccessToken
+	tm.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return tm.token, nil
+}
+
+// callAPI sends a request built by newRequest with a bearer token and
+// decodes a 2xx JSON response into out. A 401 is retried once with a fresh
+// token. newRequest is called for each attempt, so bodies can be resent.
+func callAPI(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error), out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		token, err := tokenManager.Token(attempt > 0)
+		if err != nil {
+			return err
+		}
+		req, err := newRequest(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("Gloo AI returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+		return nil
+	}
+}
+
+// postJSON returns a request builder for a JSON POST
+func postJSON(endpoint string, payload interface{}) (func(context.Context) (*http.Request, error), error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	}, nil
+}
+
+// SearchResult is a single Search API result
+type SearchResult struct {
+	Metadata struct {
+		Certainty float64 `json:"certainty"`
+	} `json:"metadata"`
+	Properties struct {
+		ItemTitle string   `json:"item_title"`
+		Type      string   `json:"type"`
+		Author    []string `json:"author"`
+		Snippet   string   `json:"snippet"`
+	} `json:"properties"`
+}
+
+// search runs a semantic search over the tenant's content
+func search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	newRequest, err := postJSON(searchURL, map[string]interface{}{
+		"query":      query,
+		"collection": "GlooProd",
+		"tenant":     tenant,
+		"limit":      limit,
+		"certainty":  0.5,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []SearchResult `json:"data"`
+	}
+	if err := callAPI(ctx, newRequest, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Source is a piece of content a grounded answer drew on. Gloo's APIs name
+// these fields differently, so each is read from whichever name is present.
+type Source struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+func (s *Source) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	s.Title = firstString(raw, "title", "item_title", "name")
+	s.URL = firstString(raw, "url", "item_url", "link")
+	s.Snippet = firstString(raw, "snippet", "text", "content")
+	return nil
+}
+
+// firstString returns the first of keys present in raw as a string
+func firstString(raw map[string]json.RawMessage, keys ...string) string {
+	for _, key := range keys {
+		var s string
+		if v, ok := raw[key]; ok && json.Unmarshal(v, &s) == nil && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// GroundedAnswer is an answer from the grounded completions endpoint
+type GroundedAnswer struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Sources []Source `json:"sources"`
+}
+
+// groundedAnswer answers a question from the publisher's content
+func groundedAnswer(ctx context.Context, question string, sourcesLimit int) (*GroundedAnswer, error) {
+	payload := map[string]interface{}{
+		"messages":      []map[string]string{{"role": "user", "content": question}},
+		"auto_routing":  true,
+		"sources_limit": sourcesLimit,
+	}
+	if publisher != "" {
+		payload["rag_publisher"] = publisher
+	}
+	newRequest, err := postJSON(groundedURL, payload)
+	if err != nil {
+		return nil, err
+	}
+	var answer GroundedAnswer
+	if err := callAPI(ctx, newRequest, &answer); err != nil {
+		return nil, err
+	}
+	if len(answer.Choices) == 0 {
+		return nil, fmt.Errorf("Gloo AI returned no answer")
+	}
+	return &answer, nil
+}
+
+// UploadResponse is the Data Engine's response to a file upload
+type UploadResponse struct {
+	Message    string   `json:"message"`
+	Ingesting  []string `json:"ingesting"`
+	Duplicates []string `json:"duplicates"`
+}
+
+// resolveUploadPath returns the absolute path of a file to upload, which
+// must be inside uploadDir. Without that check a model, or a prompt
+// injected into its context, could upload any file the server can read.
+func resolveUploadPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(uploadDir, path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", path)
+	}
+	root, err := filepath.EvalSymlinks(uploadDir)
+	if err != nil {
+		return "", fmt.Errorf("upload directory not found: %s", uploadDir)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the upload directory %s", path, uploadDir)
+	}
+	if !supportedExtensions[strings.ToLower(filepath.Ext(resolved))] {
+		return "", fmt.Errorf("unsupported file type %q; supported types are .txt, .md, .pdf, .doc, and .docx", filepath.Ext(resolved))
+	}
+	return resolved, nil
+}
+
+// uploadFile uploads a file to the publisher's content for ingestion
+func uploadFile(ctx context.Context, path, producerID string) (*UploadResponse, error) {
+	path, err := resolveUploadPath(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("files", filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	part.Write(content)
+	if err := writer.WriteField("publisher_id", publisherID); err != nil {
+		return nil, fmt.Errorf("failed to add publisher_id: %w", err)
+	}
+	writer.Close()
+
+	target := uploadURL
+	if producerID != "" {
+		target += "?" + url.Values{"producer_id": {producerID}}.Encode()
+	}
+
+	var result UploadResponse
+	err = callAPI(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(body.Bytes()))
+		if err == nil {
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+		}
+		return req, err
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,5 @@
+module gloo-mcp-server
+
+go 1.20
+
+require github.com/joho/godotenv v1.5.1
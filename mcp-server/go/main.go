@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Configuration
+var (
+	clientID     string
+	clientSecret string
+	scope        = "api/access"
+	// tenant is searched by gloo_search
+	tenant string
+	// publisher grounds gloo_grounded_answer; empty uses Gloo's default
+	// content
+	publisher string
+	// publisherID receives files from gloo_upload
+	publisherID string
+	// uploadDir is the only directory gloo_upload may read from; empty
+	// disables the tool
+	uploadDir string
+)
+
+// getEnv returns environment variable or default value
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// publisherLabel names the content the tools work on, for the model
+func publisherLabel() string {
+	switch {
+	case publisher != "":
+		return publisher
+	case tenant != "":
+		return tenant
+	default:
+		return "the publisher"
+	}
+}
+
+// isLoopback reports whether addr only accepts local connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// serveHTTP runs the Streamable HTTP transport on addr at /mcp
+func serveHTTP(addr string) error {
+	apiKey := getEnv("MCP_API_KEY", "")
+	if apiKey == "" && !isLoopback(addr) {
+		logf("Error: set MCP_API_KEY before listening on %s", addr)
+		logf("Without it, anyone who can reach the server can use your Gloo AI credentials.")
+		os.Exit(1)
+	}
+	var origins []string
+	for _, o := range strings.Split(getEnv("MCP_ALLOWED_ORIGINS", ""), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", mcpHandler(apiKey, origins))
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logf("Gloo AI MCP server listening on http://%s/mcp", addr)
+	return server.ListenAndServe()
+}
+
+// main is the entry point. Nothing may be printed to stdout: in stdio mode
+// it carries the protocol.
+func main() {
+	godotenv.Load()
+
+	clientID = getEnv("GLOO_CLIENT_ID", "")
+	clientSecret = getEnv("GLOO_CLIENT_SECRET", "")
+	scope = getEnv("GLOO_SCOPE", scope)
+	tenant = getEnv("GLOO_TENANT", "")
+	publisher = getEnv("PUBLISHER_NAME", "")
+	publisherID = getEnv("GLOO_PUBLISHER_ID", "")
+	if dir := getEnv("MCP_UPLOAD_DIR", ""); dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			logf("Error: MCP_UPLOAD_DIR: %v", err)
+			os.Exit(1)
+		}
+		uploadDir = abs
+	}
+
+	if clientID == "" || clientSecret == "" {
+		logf("Error: GLOO_CLIENT_ID and GLOO_CLIENT_SECRET must be set, in a .env file or in the")
+		logf("\"env\" block of your MCP client's server configuration")
+		os.Exit(1)
+	}
+	if tenant == "" {
+		logf("Warning: GLOO_TENANT is not set, so gloo_search will fail")
+	}
+	if uploadDir == "" || publisherID == "" {
+		logf("gloo_upload is disabled; set MCP_UPLOAD_DIR and GLOO_PUBLISHER_ID to enable it")
+	}
+
+	var err error
+	switch {
+	case len(os.Args) < 2 || os.Args[1] == "stdio":
+		err = serveStdio(os.Stdin, os.Stdout)
+	case os.Args[1] == "http":
+		addr := "127.0.0.1:8090"
+		if len(os.Args) > 2 {
+			addr = os.Args[2]
+		}
+		err = serveHTTP(addr)
+	default:
+		logf("Usage:")
+		logf("  go run .                # stdio transport, for MCP clients that launch the server")
+		logf("  go run . http [addr]    # Streamable HTTP transport at http://addr/mcp (default 127.0.0.1:8090)")
+		os.Exit(1)
+	}
+	if err != nil {
+		logf("Error: %v", err)
+		os.Exit(1)
+	}
+}
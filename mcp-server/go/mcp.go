@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// protocolVersions are the MCP revisions this server speaks, newest first
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// rpcRequest is a JSON-RPC 2.0 request or notification. Notifications have
+// no ID and get no response.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC 2.0 error
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ToolResult is the result of a tools/call. A tool that fails reports the
+// failure here with IsError set, so the model can see it and react, rather
+// than as a protocol error.
+type ToolResult struct {
+	Content []TextContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+// TextContent is a text content block
+type TextContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// textResult returns a successful tool result holding text
+func textResult(text string) *ToolResult {
+	return &ToolResult{Content: []TextContent{{Type: "text", Text: text}}}
+}
+
+// errorResult returns a failed tool result describing err
+func errorResult(err error) *ToolResult {
+	return &ToolResult{Content: []TextContent{{Type: "text", Text: err.Error()}}, IsError: true}
+}
+
+// handleMessage processes one JSON-RPC message and returns the response to
+// send, or nil for notifications
+func handleMessage(ctx context.Context, data []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "parse error: " + err.Error()}}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return &rpcResponse{JSONRPC: "2.0", ID: idOrNull(req.ID), Error: &rpcError{codeInvalidRequest, "invalid JSON-RPC 2.0 request"}}
+	}
+	if len(req.ID) == 0 {
+		// notifications/initialized and notifications/cancelled need no
+		// action here
+		return nil
+	}
+
+	result, rpcErr := dispatch(ctx, req.Method, req.Params)
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	if rpcErr == nil {
+		resp.Result = result
+	}
+	return resp
+}
+
+// idOrNull returns id, or null when the request had none
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// dispatch runs a request method
+func dispatch(ctx context.Context, method string, params json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(params, &p)
+		version := protocolVersions[0]
+		for _, v := range protocolVersions {
+			if v == p.ProtocolVersion {
+				version = v
+			}
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "gloo-ai", "version": "1.0.0"},
+			"instructions": "Tools for searching and answering questions from " + publisherLabel() +
+				"'s content on Gloo AI. Prefer gloo_grounded_answer for questions, and gloo_search to find specific items.",
+		}, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		return map[string]interface{}{"tools": availableTools()}, nil
+
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{codeInvalidParams, "invalid tools/call params: " + err.Error()}
+		}
+		tool := findTool(p.Name)
+		if tool == nil {
+			return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", p.Name)}
+		}
+		if len(p.Arguments) == 0 {
+			p.Arguments = json.RawMessage("{}")
+		}
+		return tool.Call(ctx, p.Arguments), nil
+
+	default:
+		return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method %q not found", method)}
+	}
+}
+
+// serveStdio runs the stdio transport: one JSON-RPC message per line on
+// stdin, responses one per line on stdout. Anything else written to stdout
+// would corrupt the protocol, so logs go to stderr.
+func serveStdio(in io.Reader, out io.Writer) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		// Each message is handled on its own goroutine, so a slow tool
+		// call doesn't hold up pings or other calls
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := handleMessage(context.Background(), line)
+			if resp == nil {
+				return
+			}
+			data, _ := json.Marshal(resp)
+			mu.Lock()
+			defer mu.Unlock()
+			out.Write(append(data, '\n'))
+		}()
+	}
+	wg.Wait()
+	return scanner.Err()
+}
+
+// mcpHandler serves the Streamable HTTP transport at one endpoint. Each POST
+// carries one JSON-RPC message and gets its response as JSON; this server
+// never sends requests of its own, so GET streams aren't offered.
+func mcpHandler(apiKey string, allowedOrigins []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Browsers send Origin; checking it stops web pages from reaching a
+		// local server through DNS rebinding
+		if origin := r.Header.Get("Origin"); origin != "" && !contains(allowedOrigins, origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if apiKey != "" {
+			key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 16<<20))
+		if err != nil {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp := handleMessage(r.Context(), data)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// logf writes a log line to stderr, which MCP clients show in their logs
+func logf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Tool is an MCP tool. Its input schema is a JSON Schema object, which MCP
+// clients show to the model.
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+
+	// enabled reports whether the tool is configured; nil means always
+	enabled func() bool
+	run     func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Call runs the tool, reporting a failure as an error result
+func (t *Tool) Call(ctx context.Context, args json.RawMessage) *ToolResult {
+	text, err := t.run(ctx, args)
+	if err != nil {
+		return errorResult(err)
+	}
+	return textResult(text)
+}
+
+// tools are the tools this server offers, in the order they are listed
+var tools = []*Tool{
+	{
+		Name: "gloo_search",
+		Description: "Semantic search over the publisher's content on Gloo AI. Returns the most relevant items " +
+			"with their title, type, authors, relevance, and a snippet.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"query": map[string]interface{}{"type": "string", "description": "What to search for, in natural language"},
+			"limit": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50, "default": 5, "description": "Maximum number of results"},
+		}, "query"),
+		Annotations: map[string]interface{}{"title": "Search Gloo AI content", "readOnlyHint": true},
+		run:         runSearch,
+	},
+	{
+		Name: "gloo_grounded_answer",
+		Description: "Answer a question from the publisher's content on Gloo AI, with the sources the answer " +
+			"is based on. Use this for questions the publisher's content should answer.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"question":      map[string]interface{}{"type": "string", "description": "The question to answer"},
+			"sources_limit": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10, "default": 3, "description": "Maximum number of sources to ground the answer on"},
+		}, "question"),
+		Annotations: map[string]interface{}{"title": "Answer from Gloo AI content", "readOnlyHint": true},
+		run:         runGroundedAnswer,
+	},
+	{
+		Name: "gloo_upload",
+		Description: "Upload a file (.txt, .md, .pdf, .doc, or .docx) from the upload directory to the " +
+			"publisher's content on Gloo AI, where it is ingested and becomes searchable.",
+		InputSchema: objectSchema(map[string]interface{}{
+			"path":        map[string]interface{}{"type": "string", "description": "Path of the file, relative to the upload directory"},
+			"producer_id": map[string]interface{}{"type": "string", "description": "Optional ID of the item in your own system, for updating its metadata later"},
+		}, "path"),
+		Annotations: map[string]interface{}{"title": "Upload to Gloo AI", "readOnlyHint": false, "destructiveHint": false},
+		enabled:     func() bool { return uploadDir != "" && publisherID != "" },
+		run:         runUpload,
+	},
+}
+
+// objectSchema returns the JSON Schema of an object with the given
+// properties
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// availableTools returns the tools that are configured
+func availableTools() []*Tool {
+	var available []*Tool
+	for _, t := range tools {
+		if t.enabled == nil || t.enabled() {
+			available = append(available, t)
+		}
+	}
+	return available
+}
+
+// findTool returns the available tool called name, or nil
+func findTool(name string) *Tool {
+	for _, t := range availableTools() {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// decodeArgs decodes tool arguments, rejecting unknown ones so that a
+// misspelled argument isn't silently ignored
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(string(args)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// clamp returns v limited to [min, max], or fallback if v is unset
+func clamp(v, fallback, min, max int) int {
+	if v == 0 {
+		return fallback
+	}
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func runSearch(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(in.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+
+	results, err := search(ctx, in.Query, clamp(in.Limit, 5, 1, 50))
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No results found for %q.", in.Query), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d results for %q:\n", len(results), in.Query)
+	for i, r := range results {
+		p := r.Properties
+		fmt.Fprintf(&b, "\n%d. %s (%s)\n", i+1, p.ItemTitle, p.Type)
+		if len(p.Author) > 0 {
+			fmt.Fprintf(&b, "   Author: %s\n", strings.Join(p.Author, ", "))
+		}
+		fmt.Fprintf(&b, "   Relevance: %.2f\n", r.Metadata.Certainty)
+		if p.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", strings.TrimSpace(p.Snippet))
+		}
+	}
+	return b.String(), nil
+}
+
+func runGroundedAnswer(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct {
+		Question     string `json:"question"`
+		SourcesLimit int    `json:"sources_limit"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(in.Question) == "" {
+		return "", fmt.Errorf("question is required")
+	}
+
+	answer, err := groundedAnswer(ctx, in.Question, clamp(in.SourcesLimit, 3, 1, 10))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(answer.Choices[0].Message.Content)
+	if len(answer.Sources) > 0 {
+		b.WriteString("\n\nSources:\n")
+		for i, s := range answer.Sources {
+			fmt.Fprintf(&b, "%d. %s", i+1, s.Title)
+			if s.URL != "" {
+				fmt.Fprintf(&b, " <%s>", s.URL)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
+
+func runUpload(ctx context.Context, args json.RawMessage) (string, error) {
+	var in struct {
+		Path       string `json:"path"`
+		ProducerID string `json:"producer_id"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	result, err := uploadFile(ctx, in.Path, in.ProducerID)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(result.Duplicates) > 0:
+		return fmt.Sprintf("%s was already uploaded (duplicate of %s).", in.Path, strings.Join(result.Duplicates, ", ")), nil
+	case len(result.Ingesting) > 0:
+		return fmt.Sprintf("Uploaded %s; it is being ingested as item %s and will be searchable once processing finishes.", in.Path, strings.Join(result.Ingesting, ", ")), nil
+	default:
+		return fmt.Sprintf("Uploaded %s: %s", in.Path, result.Message), nil
+	}
+}
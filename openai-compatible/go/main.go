@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Configuration
+var (
+	clientID     string
+	clientSecret string
+	tokenURL     = "https://platform.ai.gloo.com/oauth2/token"
+	apiURL       = "https://platform.ai.gloo.com/ai/v2/chat/completions"
+	scope        = "api/access"
+
+	// listenAddr is where the shim listens; loopback only by default
+	listenAddr = "127.0.0.1:8080"
+	// apiKey, if set, is the key clients must send as their OpenAI API key
+	apiKey string
+	// modelMap translates OpenAI model names to Gloo routes
+	modelMap map[string]string
+)
+
+// completionTimeout bounds a non-streamed completion. Streamed completions
+// run until the stream ends or the client disconnects.
+const completionTimeout = 120 * time.Second
+
+// maxRequestBytes bounds the size of a request body
+const maxRequestBytes = 4 << 20
+
+// upstreamClient sends every request to Gloo AI. It has no overall timeout,
+// since a long answer can stream for minutes; requests carry a context
+// instead.
+var upstreamClient = &http.Client{}
+
+// getEnv returns environment variable or default value
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// TokenManager holds the Gloo AI access token shared by every request to
+// the shim
+type TokenManager struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+var tokenManager = &TokenManager{}
+
+// Token returns a valid access token, fetching a new one if the current one
+// is within a minute of expiring or force is set. Concurrent callers wait
+// for a single token request.
+func (tm *TokenManager) Token(force bool) (string, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if !force && tm.token != "" && time.Until(tm.expiresAt) > time.Minute {
+		return tm.token, nil
+	}
+
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {scope},
+	}.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(clientID, clientSecret)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get access token: %s - %s", resp.Status, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("failed to parse token response: %s", string(body))
+	}
+	if token.ExpiresIn <= 0 {
+		token.ExpiresIn = 3600
+	}
+	tm.token = token.AccessToken
+	tm.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return tm.token, nil
+}
+
+// errCredentialsRejected means Gloo AI rejected the shim's own credentials,
+// which is a server problem rather than the client's
+var errCredentialsRejected = errors.New("Gloo AI rejected the shim's credentials; check GLOO_CLIENT_ID and GLOO_CLIENT_SECRET")
+
+// sendToGloo posts a Completions V2 request. A 401 is retried once with a
+// fresh token, in case the cached one was revoked early.
+func sendToGloo(ctx context.Context, req *GlooRequest) (*http.Response, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, err := tokenManager.Token(attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Add("Authorization", "Bearer "+token)
+		httpReq.Header.Add("Content-Type", "application/json")
+		if req.Stream {
+			httpReq.Header.Add("Accept", "text/event-stream")
+		}
+
+		resp, err := upstreamClient.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach Gloo AI: %w", err)
+		}
+		if resp.StatusCode != http.StatusUnauthorized {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if attempt > 0 {
+			return nil, errCredentialsRejected
+		}
+	}
+}
+
+// handleChatCompletions serves POST /v1/chat/completions
+func handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "", "use POST for /v1/chat/completions")
+		return
+	}
+
+	var in OpenAIRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "", fmt.Sprintf("could not parse the JSON body of your request: %v", err))
+		return
+	}
+	glooReq, err := toGlooRequest(in)
+	if err != nil {
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			writeError(w, http.StatusBadRequest, reqErr.Param, reqErr.Message)
+		} else {
+			writeError(w, http.StatusBadRequest, "", err.Error())
+		}
+		return
+	}
+
+	// The request context ends when the client disconnects, which also
+	// stops the upstream request
+	ctx := r.Context()
+	if !in.Stream {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, completionTimeout)
+		defer cancel()
+	}
+
+	resp, err := sendToGloo(ctx, glooReq)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		status := resp.StatusCode
+		if status == http.StatusForbidden {
+			// Also the shim's credentials, not the client's
+			status = http.StatusBadGateway
+		}
+		writeError(w, status, "", fmt.Sprintf("Gloo AI returned %s: %s", resp.Status, strings.TrimSpace(string(body))))
+		return
+	}
+
+	if in.Stream {
+		relayStream(w, resp.Body, in.Model, in.StreamOptions != nil && in.StreamOptions.IncludeUsage)
+		return
+	}
+
+	var out GlooResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		writeError(w, http.StatusBadGateway, "", fmt.Sprintf("failed to parse the Gloo AI response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toChatCompletion(out, in.Model))
+}
+
+// handleModels serves GET /v1/models: the routes any model name can take,
+// plus the aliases in SHIM_MODEL_MAP
+func handleModels(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	}
+	var aliases []string
+	for alias := range modelMap {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	ids := append([]string{"auto", "family:anthropic", "family:openai", "family:google"}, aliases...)
+	models := make([]model, len(ids))
+	for i, id := range ids {
+		models[i] = model{ID: id, Object: "model", OwnedBy: "gloo"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": models})
+}
+
+// requireAPIKey rejects requests without the configured API key, sent the
+// way OpenAI SDKs send theirs. With no key configured, every request is
+// allowed.
+func requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey != "" {
+			key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+				writeError(w, http.StatusUnauthorized, "", "Incorrect API key provided. Use the key in the shim's SHIM_API_KEY.")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopback reports whether addr only accepts local connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// main is the entry point
+func main() {
+	if err := godotenv.Load(); err != nil {
+		fmt.Println("No .env file found, using environment variables")
+	}
+
+	clientID = getEnv("GLOO_CLIENT_ID", "YOUR_CLIENT_ID")
+	clientSecret = getEnv("GLOO_CLIENT_SECRET", "YOUR_CLIENT_SECRET")
+	scope = getEnv("GLOO_SCOPE", scope)
+	listenAddr = getEnv("SHIM_ADDR", listenAddr)
+	apiKey = getEnv("SHIM_API_KEY", "")
+
+	if clientID == "YOUR_CLIENT_ID" || clientSecret == "YOUR_CLIENT_SECRET" ||
+		clientID == "" || clientSecret == "" {
+		fmt.Println("Error: GLOO_CLIENT_ID and GLOO_CLIENT_SECRET must be set")
+		fmt.Println("Create a .env file with your credentials:")
+		fmt.Println("GLOO_CLIENT_ID=your_client_id_here")
+		fmt.Println("GLOO_CLIENT_SECRET=your_client_secret_here")
+		os.Exit(1)
+	}
+
+	var err error
+	modelMap, err = parseModelMap(getEnv("SHIM_MODEL_MAP", ""))
+	if err != nil {
+		fmt.Printf("Error: SHIM_MODEL_MAP: %v\n", err)
+		os.Exit(1)
+	}
+	if apiKey == "" && !isLoopback(listenAddr) {
+		fmt.Println("Error: set SHIM_API_KEY before listening on", listenAddr)
+		fmt.Println("Without it, anyone who can reach the shim can spend your Gloo AI credits.")
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", handleChatCompletions)
+	mux.HandleFunc("/v1/models", handleModels)
+
+	server := &http.Server{
+		Addr:              listenAddr,
+		Handler:           requireAPIKey(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Println("=== Gloo AI OpenAI-Compatible Shim ===")
+	fmt.Println()
+	fmt.Printf("Point OpenAI clients at base URL http://%s/v1\n", listenAddr)
+	if apiKey == "" {
+		fmt.Println("Any API key is accepted (set SHIM_API_KEY to require one)")
+	}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Printf("Server failed: %v\n", err)
+		os.Exit(1)
+	}
+}
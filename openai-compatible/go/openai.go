@@ -0,0 +1,366 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIRequest is an OpenAI chat completions request. Fields the shim
+// doesn't know are ignored.
+type OpenAIRequest struct {
+	Model               string          `json:"model"`
+	Messages            []OpenAIMessage `json:"messages"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       *StreamOptions  `json:"stream_options"`
+	MaxTokens           *int            `json:"max_tokens"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	Stop                json.RawMessage `json:"stop"`
+	PresencePenalty     *float64        `json:"presence_penalty"`
+	FrequencyPenalty    *float64        `json:"frequency_penalty"`
+	Seed                *int            `json:"seed"`
+	N                   *int            `json:"n"`
+	Tools               json.RawMessage `json:"tools"`
+	ToolChoice          json.RawMessage `json:"tool_choice"`
+}
+
+// StreamOptions are the options of a streamed request
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIMessage is a message in an OpenAI request. Content is a string, an
+// array of content parts, or null.
+type OpenAIMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	Name       string          `json:"name,omitempty"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// GlooRequest is the Completions V2 request an OpenAI request becomes
+type GlooRequest struct {
+	Messages         []GlooMessage   `json:"messages"`
+	AutoRouting      bool            `json:"auto_routing,omitempty"`
+	ModelFamily      string          `json:"model_family,omitempty"`
+	Model            string          `json:"model,omitempty"`
+	Stream           bool            `json:"stream,omitempty"`
+	MaxTokens        *int            `json:"max_tokens,omitempty"`
+	Temperature      *float64        `json:"temperature,omitempty"`
+	TopP             *float64        `json:"top_p,omitempty"`
+	Stop             []string        `json:"stop,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	Seed             *int            `json:"seed,omitempty"`
+	Tools            json.RawMessage `json:"tools,omitempty"`
+	ToolChoice       json.RawMessage `json:"tool_choice,omitempty"`
+}
+
+// GlooMessage is a message in a Completions V2 request
+type GlooMessage struct {
+	Role       string          `json:"role"`
+	Content    string          `json:"content"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// Usage is the token usage block of a completion
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// GlooResponse is a Completions V2 response
+type GlooResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Role      string          `json:"role"`
+			Content   string          `json:"content"`
+			ToolCalls json.RawMessage `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// ChatCompletion is an OpenAI chat completion response
+type ChatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   *Usage       `json:"usage,omitempty"`
+}
+
+// ChatChoice is a choice in an OpenAI chat completion
+type ChatChoice struct {
+	Index        int             `json:"index"`
+	Message      ResponseMessage `json:"message"`
+	FinishReason string          `json:"finish_reason"`
+	Logprobs     *struct{}       `json:"logprobs"`
+}
+
+// ResponseMessage is the assistant message of a choice. Content is null
+// when the model only called tools.
+type ResponseMessage struct {
+	Role      string          `json:"role"`
+	Content   *string         `json:"content"`
+	ToolCalls json.RawMessage `json:"tool_calls,omitempty"`
+}
+
+// RequestError is a problem with the client's request, reported as an
+// OpenAI invalid_request_error
+type RequestError struct {
+	Param   string
+	Message string
+}
+
+func (e *RequestError) Error() string { return e.Message }
+
+// routeModel sets the routing of req from an OpenAI model name: "auto" (or
+// empty) for auto-routing, "family:<name>" for a model family, and
+// "model:<name>" or any other name for that exact model. Names in
+// SHIM_MODEL_MAP are translated first, so clients with a hard-coded model
+// such as gpt-4o can be pointed at a Gloo route.
+func routeModel(req *GlooRequest, model string) {
+	if mapped, ok := modelMap[model]; ok {
+		model = mapped
+	}
+	kind, value, _ := strings.Cut(model, ":")
+	switch {
+	case model == "" || model == "auto":
+		req.AutoRouting = true
+	case kind == "family" && value != "":
+		req.ModelFamily = value
+	case kind == "model" && value != "":
+		req.Model = value
+	default:
+		req.Model = model
+	}
+}
+
+// parseModelMap parses SHIM_MODEL_MAP, a comma-separated list of
+// alias=route pairs such as "gpt-4o=family:openai,gpt-4o-mini=auto"
+func parseModelMap(spec string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		alias, route, ok := strings.Cut(pair, "=")
+		alias, route = strings.TrimSpace(alias), strings.TrimSpace(route)
+		if !ok || alias == "" || route == "" {
+			return nil, fmt.Errorf("invalid model mapping %q: expected alias=route", pair)
+		}
+		m[alias] = route
+	}
+	return m, nil
+}
+
+// toGlooRequest translates an OpenAI request into a Completions V2 request
+func toGlooRequest(in OpenAIRequest) (*GlooRequest, error) {
+	if len(in.Messages) == 0 {
+		return nil, &RequestError{Param: "messages", Message: "messages must contain at least one message"}
+	}
+	if in.N != nil && *in.N != 1 {
+		return nil, &RequestError{Param: "n", Message: "only n=1 is supported"}
+	}
+
+	out := &GlooRequest{
+		Stream:           in.Stream,
+		MaxTokens:        in.MaxTokens,
+		Temperature:      in.Temperature,
+		TopP:             in.TopP,
+		PresencePenalty:  in.PresencePenalty,
+		FrequencyPenalty: in.FrequencyPenalty,
+		Seed:             in.Seed,
+		Tools:            in.Tools,
+		ToolChoice:       in.ToolChoice,
+	}
+	// Newer clients send max_completion_tokens in place of max_tokens
+	if in.MaxCompletionTokens != nil {
+		out.MaxTokens = in.MaxCompletionTokens
+	}
+	routeModel(out, in.Model)
+
+	stop, err := stopSequences(in.Stop)
+	if err != nil {
+		return nil, err
+	}
+	out.Stop = stop
+
+	for i, m := range in.Messages {
+		content, err := messageText(m.Content)
+		if err != nil {
+			return nil, &RequestError{Param: fmt.Sprintf("messages[%d].content", i), Message: err.Error()}
+		}
+		role := m.Role
+		// OpenAI's newer models call the system role "developer"
+		if role == "developer" {
+			role = "system"
+		}
+		out.Messages = append(out.Messages, GlooMessage{
+			Role:       role,
+			Content:    content,
+			ToolCalls:  m.ToolCalls,
+			ToolCallID: m.ToolCallID,
+		})
+	}
+	return out, nil
+}
+
+// stopSequences reads stop, which OpenAI allows as a string or an array
+func stopSequences(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, &RequestError{Param: "stop", Message: "stop must be a string or an array of strings"}
+	}
+	return many, nil
+}
+
+// messageText flattens message content to text. Content may be a string,
+// null (an assistant message with only tool calls), or an array of content
+// parts, of which only text parts are supported.
+func messageText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("content must be a string or an array of content parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.Type != "text" {
+			return "", fmt.Errorf("content parts of type %q are not supported; only text is", p.Type)
+		}
+		texts = append(texts, p.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// toChatCompletion translates a Completions V2 response into an OpenAI
+// chat completion
+func toChatCompletion(in GlooResponse, requestedModel string) ChatCompletion {
+	out := ChatCompletion{
+		ID:      in.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   responseModel(in.Model, requestedModel),
+		Choices: make([]ChatChoice, 0, len(in.Choices)),
+		Usage:   in.Usage,
+	}
+	if out.ID == "" {
+		out.ID = newCompletionID()
+	}
+	for i, c := range in.Choices {
+		msg := ResponseMessage{Role: "assistant", ToolCalls: c.Message.ToolCalls}
+		if len(msg.ToolCalls) == 0 || string(msg.ToolCalls) == "null" {
+			msg.ToolCalls = nil
+		}
+		if c.Message.Content != "" || msg.ToolCalls == nil {
+			content := c.Message.Content
+			msg.Content = &content
+		}
+		out.Choices = append(out.Choices, ChatChoice{
+			Index:        i,
+			Message:      msg,
+			FinishReason: finishReason(c.FinishReason, msg.ToolCalls != nil),
+		})
+	}
+	return out
+}
+
+// responseModel is the model reported to the client: the one Gloo routed
+// to, else the one that was asked for
+func responseModel(routed, requested string) string {
+	if routed != "" {
+		return routed
+	}
+	if requested == "" {
+		return "auto"
+	}
+	return requested
+}
+
+// finishReason fills in a finish reason the API left out
+func finishReason(reason string, toolCalls bool) string {
+	switch {
+	case reason != "":
+		return reason
+	case toolCalls:
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
+// newCompletionID returns an OpenAI-style completion ID
+func newCompletionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+// openAIError is the OpenAI error response body
+type openAIError struct {
+	Error struct {
+		Message string  `json:"message"`
+		Type    string  `json:"type"`
+		Param   *string `json:"param"`
+		Code    *string `json:"code"`
+	} `json:"error"`
+}
+
+// writeError writes an error in the OpenAI format, with the error type
+// OpenAI SDKs expect for the status
+func writeError(w http.ResponseWriter, status int, param, message string) {
+	var body openAIError
+	body.Error.Message = message
+	switch {
+	case status == http.StatusUnauthorized:
+		body.Error.Type = "authentication_error"
+	case status == http.StatusForbidden:
+		body.Error.Type = "permission_error"
+	case status == http.StatusNotFound:
+		body.Error.Type = "not_found_error"
+	case status == http.StatusTooManyRequests:
+		body.Error.Type = "rate_limit_error"
+	case status >= 500:
+		body.Error.Type = "api_error"
+	default:
+		body.Error.Type = "invalid_request_error"
+	}
+	if param != "" {
+		body.Error.Param = &param
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
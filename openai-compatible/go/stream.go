@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// glooChunk is one SSE "data:" payload from the V2 API
+type glooChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content   string          `json:"content"`
+			ToolCalls json.RawMessage `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// ChatCompletionChunk is one event of an OpenAI streamed chat completion
+type ChatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// ChunkChoice is the choice in a chunk
+type ChunkChoice struct {
+	Index        int        `json:"index"`
+	Delta        ChunkDelta `json:"delta"`
+	FinishReason *string    `json:"finish_reason"`
+	Logprobs     *struct{}  `json:"logprobs"`
+}
+
+// ChunkDelta is the part of the message a chunk adds
+type ChunkDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   *string         `json:"content,omitempty"`
+	ToolCalls json.RawMessage `json:"tool_calls,omitempty"`
+}
+
+// parseSSEData extracts the payload of an SSE "data:" line.
+// It returns ok=false for blank lines, comments, and other SSE fields.
+func parseSSEData(line string) (data string, ok bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "data:")), true
+}
+
+// chunkWriter writes OpenAI stream events. Every chunk of one completion
+// carries the same ID, creation time, and model, as OpenAI's do.
+type chunkWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	chunk   ChatCompletionChunk
+}
+
+// send writes one event and flushes it to the client straight away
+func (cw *chunkWriter) send(v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(cw.w, "data: %s\n\n", data)
+	if cw.flusher != nil {
+		cw.flusher.Flush()
+	}
+}
+
+// sendChoice writes a chunk with one choice
+func (cw *chunkWriter) sendChoice(delta ChunkDelta, finish *string) {
+	chunk := cw.chunk
+	chunk.Choices = []ChunkChoice{{Delta: delta, FinishReason: finish}}
+	cw.send(chunk)
+}
+
+// relayStream translates a Completions V2 SSE stream into an OpenAI one as
+// it arrives. The first chunk carries the assistant role and the last a
+// finish reason, even if the API's stream left them out; with
+// include_usage, a final chunk with no choices carries the usage.
+func relayStream(w http.ResponseWriter, body io.Reader, requestedModel string, includeUsage bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	cw := &chunkWriter{w: w, flusher: flusher, chunk: ChatCompletionChunk{
+		ID:      newCompletionID(),
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   responseModel("", requestedModel),
+	}}
+
+	var usage *Usage
+	sentRole, finished := false, false
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, ok := parseSSEData(scanner.Text())
+		if !ok || data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk glooChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			sendStreamError(cw, fmt.Sprintf("failed to parse stream chunk from Gloo AI: %v", err))
+			return
+		}
+		if chunk.Model != "" {
+			cw.chunk.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, c := range chunk.Choices {
+			delta := ChunkDelta{}
+			if !sentRole {
+				delta.Role = "assistant"
+				sentRole = true
+			}
+			if c.Delta.Content != "" {
+				content := c.Delta.Content
+				delta.Content = &content
+			}
+			if len(c.Delta.ToolCalls) > 0 && string(c.Delta.ToolCalls) != "null" {
+				delta.ToolCalls = c.Delta.ToolCalls
+			}
+			if delta.Role == "" && delta.Content == nil && delta.ToolCalls == nil && c.FinishReason == nil {
+				continue
+			}
+			cw.sendChoice(delta, c.FinishReason)
+			if c.FinishReason != nil {
+				finished = true
+			}
+		}
+		// The usage, if any, may follow the finish reason; without
+		// include_usage there is nothing more to wait for
+		if finished && !includeUsage {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		sendStreamError(cw, fmt.Sprintf("stream from Gloo AI failed: %v", err))
+		return
+	}
+
+	if !finished {
+		stop := "stop"
+		cw.sendChoice(ChunkDelta{}, &stop)
+	}
+	if includeUsage && usage != nil {
+		chunk := cw.chunk
+		chunk.Choices = []ChunkChoice{}
+		chunk.Usage = usage
+		cw.send(chunk)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// sendStreamError ends a stream with an error event. The status line has
+// already been sent, so this is the only way to tell an OpenAI client the
+// stream failed; the SDKs raise it as an API error.
+func sendStreamError(cw *chunkWriter, message string) {
+	var body openAIError
+	body.Error.Message = message
+	body.Error.Type = "api_error"
+	cw.send(body)
+}
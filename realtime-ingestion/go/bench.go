@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// BenchOptions configure a bench ingest run
+type BenchOptions struct {
+	// Files is how many synthetic files to generate
+	Files int
+	// Sizes are the file sizes in bytes, cycled through
+	Sizes []int
+	// Uploaders are the pipeline concurrencies to compare, one run each
+	Uploaders []int
+	// Latency is how long the mock server takes to answer an upload
+	Latency time.Duration
+	// MemProfile, if set, is where to write an allocation profile
+	MemProfile string
+}
+
+// parseBenchOptions reads --files=N, --sizes=1KB,16KB, --uploaders=1,4,8,
+// --latency=20ms, and --memprofile=FILE
+func parseBenchOptions(args []string) (BenchOptions, error) {
+	opts := BenchOptions{
+		Files:     500,
+		Sizes:     []int{2 * 1024, 16 * 1024},
+		Uploaders: []int{1, 2, 4, 8},
+		Latency:   20 * time.Millisecond,
+	}
+	for _, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		var err error
+		switch name {
+		case "--files":
+			opts.Files, err = strconv.Atoi(value)
+			if err == nil && opts.Files < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "--sizes":
+			opts.Sizes, err = parseList(value, parseSize)
+		case "--uploaders":
+			opts.Uploaders, err = parseList(value, strconv.Atoi)
+		case "--latency":
+			opts.Latency, err = time.ParseDuration(value)
+		case "--memprofile":
+			opts.MemProfile = value
+		default:
+			return opts, fmt.Errorf("%w: unknown bench option %q", errConfig, arg)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("%w: invalid %s %q: %v", errConfig, name, value, err)
+		}
+	}
+	return opts, nil
+}
+
+// parseList parses a comma-separated list of positive numbers
+func parseList(value string, parse func(string) (int, error)) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		n, err := parse(item)
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("%q must be positive", item)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// parseSize parses a size in bytes, optionally with a KB or MB suffix
+func parseSize(value string) (int, error) {
+	upper := strings.ToUpper(value)
+	unit := 1
+	switch {
+	case strings.HasSuffix(upper, "MB"):
+		unit, upper = 1024*1024, strings.TrimSuffix(upper, "MB")
+	case strings.HasSuffix(upper, "KB"):
+		unit, upper = 1024, strings.TrimSuffix(upper, "KB")
+	}
+	n, err := strconv.Atoi(upper)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a size such as 4096, 16KB, or 1MB", value)
+	}
+	return n * unit, nil
+}
+
+// benchWords are the words synthetic files are made of
+var benchWords = strings.Fields(`grace faith hope love church community worship prayer
+	scripture study sermon gospel kingdom mercy peace service ministry family
+	wisdom light truth spirit life word heart mission fellowship teaching`)
+
+// benchText returns size bytes of synthetic prose, in sentences and
+// paragraphs, starting at word seed so files differ
+func benchText(seed, size int) string {
+	var b strings.Builder
+	for w := seed; b.Len() < size; w++ {
+		b.WriteString(benchWords[w%len(benchWords)])
+		switch {
+		case w%97 == 96:
+			b.WriteString(".\n\n")
+		case w%13 == 12:
+			b.WriteString(". ")
+		default:
+			b.WriteString(" ")
+		}
+	}
+	return b.String()[:size]
+}
+
+// writeBenchFiles generates opts.Files files in dir, cycling through the
+// sizes, and returns their paths and total size
+func writeBenchFiles(dir string, opts BenchOptions) ([]string, int64, error) {
+	files := make([]string, opts.Files)
+	var total int64
+	for i := range files {
+		size := opts.Sizes[i%len(opts.Sizes)]
+		files[i] = filepath.Join(dir, fmt.Sprintf("bench-%05d.txt", i))
+		if err := ioutil.WriteFile(files[i], []byte(benchText(i, size)), 0644); err != nil {
+			return nil, 0, fmt.Errorf("failed to write benchmark file: %w", err)
+		}
+		total += int64(size)
+	}
+	return files, total, nil
+}
+
+// newBenchServer starts a mock of the token and upload endpoints. Uploads
+// are answered after latency and counted in uploads.
+func newBenchServer(latency time.Duration, uploads *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/token") {
+			fmt.Fprint(w, `{"access_token":"bench","expires_in":3600,"token_type":"Bearer"}`)
+			return
+		}
+		io.Copy(ioutil.Discard, r.Body)
+		time.Sleep(latency)
+		n := atomic.AddInt64(uploads, 1)
+		fmt.Fprintf(w, `{"success":true,"message":"Accepted","task_id":"bench-%d"}`, n)
+	}))
+}
+
+// benchTransport sends every request to the mock server instead of the
+// Gloo AI API, over a transport configured like the default one
+type benchTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t *benchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return t.next.RoundTrip(req)
+}
+
+// RunBench runs a benchmark command; only ingest exists. It uploads
+// synthetic files to a mock server through the batch pipeline at each
+// concurrency and reports files/sec, MB/sec, and allocations, to guide
+// the choice of --uploaders. It needs no credentials.
+func RunBench(args []string) error {
+	if len(args) == 0 || args[0] != "ingest" {
+		return fmt.Errorf("%w: usage: bench ingest [options]", errConfig)
+	}
+	opts, err := parseBenchOptions(args[1:])
+	if err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "gloo-bench-")
+	if err != nil {
+		return fmt.Errorf("failed to create benchmark directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	files, totalBytes, err := writeBenchFiles(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	var uploads int64
+	server := newBenchServer(opts.Latency, &uploads)
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &benchTransport{target: target, next: http.DefaultTransport.(*http.Transport).Clone()},
+	}
+	tokenManager := NewTokenManager("bench", "bench", "")
+	tokenManager.httpClient = client
+	processor := NewContentProcessor(tokenManager)
+	processor.httpClient = client
+	bp := NewBatchProcessor(processor)
+
+	fmt.Printf("📈 Ingestion benchmark: %d files, %.1f MB, mock latency %s\n",
+		len(files), float64(totalBytes)/(1024*1024), opts.Latency)
+	fmt.Printf("   %9s %10s %8s %12s %14s %7s\n", "uploaders", "files/sec", "MB/sec", "allocs/file", "KB alloc/file", "failed")
+
+	// The per-file messages would swamp the results, so they are discarded
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+
+	for _, n := range opts.Uploaders {
+		runtime.GC()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		os.Stdout = devNull
+		start := time.Now()
+		processed, failed, lastErr, _ := bp.processPipelined(files, BatchOptions{Uploaders: n}, 0, NewProgress(ProgressNone, files))
+		elapsed := time.Since(start).Seconds()
+		os.Stdout = stdout
+		runtime.ReadMemStats(&after)
+
+		fmt.Printf("   %9d %10.1f %8.2f %12.0f %14.1f %7d\n", n,
+			float64(processed)/elapsed,
+			float64(totalBytes)/(1024*1024)/elapsed,
+			float64(after.Mallocs-before.Mallocs)/float64(len(files)),
+			float64(after.TotalAlloc-before.TotalAlloc)/1024/float64(len(files)),
+			failed)
+		if lastErr != nil {
+			fmt.Printf("   ⚠️  Last failure: %v\n", lastErr)
+		}
+	}
+
+	if opts.MemProfile != "" {
+		f, err := os.Create(opts.MemProfile)
+		if err != nil {
+			return fmt.Errorf("failed to create memory profile: %w", err)
+		}
+		defer f.Close()
+		if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+			return fmt.Errorf("failed to write memory profile: %w", err)
+		}
+		fmt.Printf("💾 Wrote the allocation profile to %s; inspect it with: go tool pprof %s\n", opts.MemProfile, opts.MemProfile)
+	}
+	return nil
+}
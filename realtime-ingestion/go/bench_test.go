@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchSizes are the content sizes the benchmarks run at
+var benchSizes = []struct {
+	name string
+	size int
+}{
+	{"2KB", 2 * 1024},
+	{"64KB", 64 * 1024},
+	{"1MB", 1024 * 1024},
+}
+
+func BenchmarkSplitChunks(b *testing.B) {
+	for _, s := range benchSizes {
+		text := benchText(0, s.size)
+		b.Run(s.name, func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				splitChunks(text, defaultChunkSize)
+			}
+		})
+	}
+}
+
+func BenchmarkPlanChunks(b *testing.B) {
+	chunks := splitChunks(benchText(0, 1024*1024), defaultChunkSize)
+	previous := make(map[int]string, len(chunks))
+	for i, chunk := range chunks {
+		previous[i] = contentHash(chunk)
+	}
+	// One edit in the middle
+	chunks[len(chunks)/2] += "edited"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		planChunks(chunks, previous, false)
+	}
+}
+
+func BenchmarkPartReader(b *testing.B) {
+	text := benchText(0, 8*1024*1024)
+	b.SetBytes(int64(len(text)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parts := newPartReader(strings.NewReader(text), defaultStreamPartBytes)
+		for {
+			if _, err := parts.next(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkNormalizeContent(b *testing.B) {
+	for _, s := range benchSizes {
+		data := []byte(strings.ReplaceAll(benchText(0, s.size), "\n", "\r\n"))
+		b.Run(s.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				normalizeContent(data)
+			}
+		})
+	}
+}
+
+func BenchmarkMinHash(b *testing.B) {
+	for _, s := range benchSizes {
+		text := benchText(0, s.size)
+		b.Run(s.name, func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				minHashText(text)
+			}
+		})
+	}
+}
+
+func BenchmarkFindDuplicates(b *testing.B) {
+	dir := b.TempDir()
+	files, _, err := writeBenchFiles(dir, BenchOptions{Files: 200, Sizes: []int{16 * 1024}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	limits := FileLimits{PartBytes: defaultStreamPartBytes}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		FindDuplicates(files, defaultSimilarity, limits, NewHandlerRegistry())
+	}
+}
+
+func BenchmarkEncodeContent(b *testing.B) {
+	processor := NewContentProcessor(NewTokenManager("bench", "bench", ""))
+	for _, s := range benchSizes {
+		data := processor.CreateContentData(benchText(0, s.size), "Bench")
+		b.Run(s.name, func(b *testing.B) {
+			b.SetBytes(int64(s.size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUploadContent(b *testing.B) {
+	var uploads int64
+	server := newBenchServer(0, &uploads)
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &benchTransport{target: target, next: http.DefaultTransport.(*http.Transport).Clone()},
+	}
+	tokenManager := NewTokenManager("bench", "bench", "")
+	tokenManager.httpClient = client
+	processor := NewContentProcessor(tokenManager)
+	processor.httpClient = client
+
+	data := processor.CreateContentData(benchText(0, 16*1024), "Bench")
+	b.SetBytes(int64(len(data.Content)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := processor.UploadContent(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ingestion statuses. Uploads start as submitted, and status callbacks move
+// them on to processing and then completed or failed.
+const (
+	StatusSubmitted  = "submitted"
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// statusRank orders the statuses, so a late callback can't move an item
+// back to an earlier one
+var statusRank = map[string]int{
+	StatusSubmitted:  0,
+	StatusProcessing: 1,
+	StatusCompleted:  2,
+	StatusFailed:     2,
+}
+
+// errInvalidEvent marks status callbacks the catalog can't apply
+var errInvalidEvent = errors.New("invalid ingestion event")
+
+// CatalogItem is an uploaded item and its ingestion status
+type CatalogItem struct {
+	TaskID     string    `json:"task_id"`
+	BatchID    string    `json:"batch_id,omitempty"`
+	ItemID     string    `json:"item_id,omitempty"`
+	ProducerID string    `json:"producer_id,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	File       string    `json:"file,omitempty"`
+	Status     string    `json:"status"`
+	Message    string    `json:"message,omitempty"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// ReplacedBy is the task of a later upload under the same producer ID,
+	// which replaced this one
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	// Deleted marks an item deleted from the index, such as a chunk no
+	// longer in its file
+	Deleted bool `json:"deleted,omitempty"`
+	// LastEventID is the last callback applied, so a redelivered one is
+	// ignored
+	LastEventID string `json:"last_event_id,omitempty"`
+	// ContentSHA256 and ContentBytes describe the content as uploaded
+	ContentSHA256 string `json:"content_sha256,omitempty"`
+	ContentBytes  int    `json:"content_bytes,omitempty"`
+	// RemoteSHA256 and RemoteBytes describe the processed item, when a
+	// status callback reports them
+	RemoteSHA256 string `json:"remote_sha256,omitempty"`
+	RemoteBytes  int    `json:"remote_bytes,omitempty"`
+	// Integrity is the result of the last integrity check
+	Integrity string `json:"integrity,omitempty"`
+}
+
+// Live reports whether the item is still in the index: not replaced by a
+// later upload, nor deleted
+func (item *CatalogItem) Live() bool {
+	return item.ReplacedBy == "" && !item.Deleted
+}
+
+// Finished reports whether ingestion has finished, successfully or not
+func (item *CatalogItem) Finished() bool {
+	return item.Status == StatusCompleted || item.Status == StatusFailed
+}
+
+// Catalog is the local record of uploaded items, keyed by task ID and saved
+// as a JSON file after every change. It is safe for concurrent use.
+type Catalog struct {
+	path  string
+	mu    sync.Mutex
+	items map[string]*CatalogItem
+}
+
+// OpenCatalog loads the catalog at path, or starts an empty one if the file
+// doesn't exist yet
+func OpenCatalog(path string) (*Catalog, error) {
+	c := &Catalog{path: path, items: map[string]*CatalogItem{}}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	var items []*CatalogItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog %s: %w", path, err)
+	}
+	for _, item := range items {
+		c.items[item.TaskID] = item
+	}
+	return c, nil
+}
+
+// RecordUpload adds an item the Realtime API accepted, with a hash of the
+// content so the processed item can be checked against it. An upload with
+// a producer ID replaces the earlier uploads under it. Responses without a
+// task ID can't be matched to status callbacks, so they aren't recorded.
+func (c *Catalog) RecordUpload(file string, data *ContentData, result *ApiResponse) error {
+	if result.TaskID == nil || *result.TaskID == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().UTC()
+	item := &CatalogItem{
+		TaskID:     *result.TaskID,
+		ProducerID: data.ProducerID,
+		Title:      data.ItemTitle,
+		File:       file,
+		Status:     StatusSubmitted,
+		UploadedAt: now,
+		UpdatedAt:  now,
+		// The hash is of the content as sent, in UTF-8
+		ContentSHA256: contentHash(data.Content),
+		ContentBytes:  len(data.Content),
+	}
+	if result.BatchID != nil {
+		item.BatchID = *result.BatchID
+	}
+
+	var replaced []*CatalogItem
+	if item.ProducerID != "" {
+		for _, earlier := range c.items {
+			if earlier.ProducerID == item.ProducerID && earlier.ReplacedBy == "" && earlier.TaskID != item.TaskID {
+				earlier.ReplacedBy = item.TaskID
+				replaced = append(replaced, earlier)
+			}
+		}
+	}
+	c.items[item.TaskID] = item
+	if err := c.save(); err != nil {
+		delete(c.items, item.TaskID)
+		for _, earlier := range replaced {
+			earlier.ReplacedBy = ""
+		}
+		return err
+	}
+	return nil
+}
+
+// Current returns the latest upload under a producer ID, the one that
+// hasn't been replaced
+func (c *Catalog) Current(producerID string) (CatalogItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range c.items {
+		if item.ProducerID == producerID && item.Live() {
+			return *item, true
+		}
+	}
+	return CatalogItem{}, false
+}
+
+// Chunks returns the latest upload in each slot of a file uploaded in
+// chunks under a producer ID. Slot 0 is the producer ID itself, so a file
+// uploaded whole has just that.
+func (c *Catalog) Chunks(producerID string) map[int]CatalogItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chunks := make(map[int]CatalogItem)
+	for _, item := range c.items {
+		if !item.Live() {
+			continue
+		}
+		if slot, ok := chunkSlot(producerID, item.ProducerID); ok {
+			chunks[slot] = *item
+		}
+	}
+	return chunks
+}
+
+// RecordDeleted notes that the items under a producer ID were deleted from
+// the index
+func (c *Catalog) RecordDeleted(producerID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var deleted []*CatalogItem
+	for _, item := range c.items {
+		if item.ProducerID == producerID && item.Live() {
+			item.Deleted = true
+			item.UpdatedAt = time.Now().UTC()
+			deleted = append(deleted, item)
+		}
+	}
+	if err := c.save(); err != nil {
+		for _, item := range deleted {
+			item.Deleted = false
+		}
+		return err
+	}
+	return nil
+}
+
+// RecordMetadataUpdate notes that an item's metadata was changed without
+// uploading it again
+func (c *Catalog) RecordMetadataUpdate(taskID, title string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[taskID]
+	if !ok {
+		return nil
+	}
+	previous := *item
+	item.Title = title
+	item.UpdatedAt = time.Now().UTC()
+	if err := c.save(); err != nil {
+		*item = previous
+		return err
+	}
+	return nil
+}
+
+// ApplyEvent updates an item from a status callback and returns the updated
+// item. changed is false when the callback was a redelivery or would move
+// the item back to an earlier status. Callbacks for tasks the catalog hasn't
+// seen, such as uploads from another machine, add a new item.
+func (c *Catalog) ApplyEvent(event *IngestionEvent) (item CatalogItem, changed bool, err error) {
+	if event.TaskID == "" {
+		return CatalogItem{}, false, fmt.Errorf("%w: no task_id", errInvalidEvent)
+	}
+	if _, ok := statusRank[event.Status]; !ok {
+		return CatalogItem{}, false, fmt.Errorf("%w: unknown status %q", errInvalidEvent, event.Status)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	existing, ok := c.items[event.TaskID]
+	if !ok {
+		existing = &CatalogItem{TaskID: event.TaskID, Status: StatusSubmitted}
+	}
+	if (event.ID != "" && event.ID == existing.LastEventID) || statusRank[event.Status] < statusRank[existing.Status] {
+		return *existing, false, nil
+	}
+
+	updated := *existing
+	updated.Status = event.Status
+	updated.Message = event.Message
+	updated.LastEventID = event.ID
+	updated.UpdatedAt = time.Now().UTC()
+	if event.BatchID != "" {
+		updated.BatchID = event.BatchID
+	}
+	if event.ItemID != "" {
+		updated.ItemID = event.ItemID
+	}
+	if event.Title != "" && updated.Title == "" {
+		updated.Title = event.Title
+	}
+	if event.ContentSHA256 != "" {
+		updated.RemoteSHA256 = strings.ToLower(event.ContentSHA256)
+		updated.RemoteBytes = event.ContentLength
+		updated.Integrity = compareChecksums(&updated).Status
+	}
+
+	c.items[event.TaskID] = &updated
+	if err := c.save(); err != nil {
+		// Keep memory and disk in step, so a retried callback applies again
+		if ok {
+			c.items[event.TaskID] = existing
+		} else {
+			delete(c.items, event.TaskID)
+		}
+		return CatalogItem{}, false, err
+	}
+	return updated, true, nil
+}
+
+// SetIntegrity records the result of an integrity check on an item
+func (c *Catalog) SetIntegrity(taskID, integrity string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[taskID]
+	if !ok || item.Integrity == integrity {
+		return nil
+	}
+	previous := item.Integrity
+	item.Integrity = integrity
+	if err := c.save(); err != nil {
+		item.Integrity = previous
+		return err
+	}
+	return nil
+}
+
+// Items returns the items, oldest upload first
+func (c *Catalog) Items() []CatalogItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make([]CatalogItem, 0, len(c.items))
+	for _, item := range c.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].UploadedAt.Equal(items[j].UploadedAt) {
+			return items[i].UploadedAt.Before(items[j].UploadedAt)
+		}
+		return items[i].TaskID < items[j].TaskID
+	})
+	return items
+}
+
+// save writes the catalog to a temporary file and renames it into place, so
+// a crash never leaves a half-written catalog. The caller holds c.mu.
+func (c *Catalog) save() error {
+	items := make([]*CatalogItem, 0, len(c.items))
+	for _, item := range c.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].TaskID < items[j].TaskID })
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal catalog: %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), ".catalog-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save catalog: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save catalog: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save catalog: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to save catalog: %w", err)
+	}
+	return nil
+}
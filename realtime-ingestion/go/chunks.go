@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultChunkSize is the target size of a chunk in bytes
+const defaultChunkSize = 8000
+
+// chunkSuffix separates a file's producer ID from a chunk's slot
+const chunkSuffix = "#chunk-"
+
+// chunkProducerID returns the producer ID of the chunk in slot. Slot 0 uses
+// the file's own producer ID, so the first chunked update replaces the item
+// of a whole-file upload rather than leaving it beside the chunks.
+func chunkProducerID(producerID string, slot int) string {
+	if slot == 0 {
+		return producerID
+	}
+	return producerID + chunkSuffix + strconv.Itoa(slot)
+}
+
+// chunkSlot returns the slot of a chunk's producer ID under producerID
+func chunkSlot(producerID, chunkID string) (int, bool) {
+	if chunkID == producerID {
+		return 0, true
+	}
+	if !strings.HasPrefix(chunkID, producerID+chunkSuffix) {
+		return 0, false
+	}
+	slot, err := strconv.Atoi(strings.TrimPrefix(chunkID, producerID+chunkSuffix))
+	if err != nil || slot <= 0 {
+		return 0, false
+	}
+	return slot, true
+}
+
+// splitChunks splits content into chunks of about size bytes at paragraph
+// breaks. A chunk ends at the first paragraph past size that hashes as a
+// boundary, or once it reaches twice size, so where chunks end depends on
+// their paragraphs rather than their offset: an edit changes the chunks
+// around it, and the chunks after it split as they did before.
+// Concatenated, the chunks are the content.
+func splitChunks(content string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, paragraph := range strings.SplitAfter(content, "\n\n") {
+		current.WriteString(paragraph)
+		if current.Len() >= 2*size || current.Len() >= size && chunkBoundary(paragraph) {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// chunkBoundary reports whether a chunk past its target size may end after
+// paragraph, which is true of about one paragraph in four
+func chunkBoundary(paragraph string) bool {
+	h := fnv.New32a()
+	h.Write([]byte(strings.TrimSpace(paragraph)))
+	return h.Sum32()%4 == 0
+}
+
+// chunkUpload is a chunk to upload into a slot
+type chunkUpload struct {
+	Slot    int
+	Content string
+}
+
+// chunkPlan is what a chunked update has to upload
+type chunkPlan struct {
+	Upload    []chunkUpload
+	Unchanged int
+	// Removed are slots whose chunks are no longer in the file
+	Removed []int
+}
+
+// planChunks diffs a file's chunks against the content hashes of the chunks
+// uploaded before, by slot. A chunk that was uploaded before keeps its slot
+// and isn't uploaded again unless force is set. New and changed chunks take
+// the slots of chunks that are gone, lowest first, then new slots.
+func planChunks(chunks []string, previous map[int]string, force bool) chunkPlan {
+	bySlot := make([]int, 0, len(previous))
+	for slot := range previous {
+		bySlot = append(bySlot, slot)
+	}
+	sort.Ints(bySlot)
+	slotsByHash := make(map[string][]int)
+	for _, slot := range bySlot {
+		slotsByHash[previous[slot]] = append(slotsByHash[previous[slot]], slot)
+	}
+
+	var plan chunkPlan
+	assigned := make([]int, len(chunks))
+	used := make(map[int]bool)
+	for i, chunk := range chunks {
+		assigned[i] = -1
+		hash := contentHash(chunk)
+		if slots := slotsByHash[hash]; len(slots) > 0 {
+			assigned[i], slotsByHash[hash] = slots[0], slots[1:]
+			used[slots[0]] = true
+		}
+	}
+
+	var free []int
+	next := 0
+	for _, slot := range bySlot {
+		if !used[slot] {
+			free = append(free, slot)
+		}
+		next = slot + 1
+	}
+	for i, chunk := range chunks {
+		if assigned[i] >= 0 && !force {
+			plan.Unchanged++
+			continue
+		}
+		slot := assigned[i]
+		if slot < 0 && len(free) > 0 {
+			slot, free = free[0], free[1:]
+		} else if slot < 0 {
+			slot = next
+			next++
+		}
+		plan.Upload = append(plan.Upload, chunkUpload{Slot: slot, Content: chunk})
+	}
+	plan.Removed = free
+	return plan
+}
+
+// chunkSize returns the target chunk size from GLOO_CHUNK_SIZE
+func chunkSize() (int, error) {
+	size, err := strconv.Atoi(getEnv("GLOO_CHUNK_SIZE", strconv.Itoa(defaultChunkSize)))
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("%w: GLOO_CHUNK_SIZE must be a positive number of bytes", errConfig)
+	}
+	return size, nil
+}
+
+// updateChunks brings a file uploaded in chunks up to date, uploading only
+// the chunks that changed, and fails if chunks were removed until their
+// items are deleted. Each chunk is recorded in the catalog as soon as
+// it's uploaded, so an update that fails part way resumes where it stopped.
+func (app *Application) updateChunks(file, producerID string, force, removedDeleted bool) error {
+	size, err := chunkSize()
+	if err != nil {
+		return err
+	}
+	contentData, err := app.processor.prepareFile(file)
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[int]string)
+	for slot, item := range app.catalog.Chunks(producerID) {
+		previous[slot] = item.ContentSHA256
+	}
+	chunks := splitChunks(contentData.Content, size)
+	plan := planChunks(chunks, previous, force)
+
+	for _, c := range plan.Upload {
+		chunk := *contentData
+		chunk.Content = c.Content
+		chunk.ProducerID = chunkProducerID(producerID, c.Slot)
+		result, err := app.processor.UploadContent(&chunk)
+		if err != nil {
+			return fmt.Errorf("upload of chunk %s failed: %w", chunk.ProducerID, err)
+		}
+		if err := app.catalog.RecordUpload(file, &chunk, result); err != nil {
+			fmt.Printf("⚠️  Failed to record chunk %s in the catalog: %v\n", chunk.ProducerID, err)
+		}
+		fmt.Printf("   📤 Uploaded chunk %s (%d bytes)\n", chunk.ProducerID, len(chunk.Content))
+	}
+
+	fmt.Printf("🧩 %s has %d chunks: %d uploaded, %d unchanged (producer_id %s)\n",
+		file, len(chunks), len(plan.Upload), plan.Unchanged, producerID)
+	if len(plan.Upload) > 0 {
+		fmt.Println("   Run 'go run . verify' once they have been processed to check them")
+	}
+	return app.removedChunks(producerID, plan.Removed, removedDeleted)
+}
+
+// removedChunks handles the slots whose chunks are no longer in a file.
+// The Realtime API can't delete items, so their items stay searchable
+// until they're deleted on the platform. Until then the update fails,
+// naming them; once they're deleted, update --removed-deleted records it.
+func (app *Application) removedChunks(producerID string, removed []int, deleted bool) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	ids := make([]string, len(removed))
+	for i, slot := range removed {
+		ids[i] = chunkProducerID(producerID, slot)
+	}
+	if !deleted {
+		return fmt.Errorf("%d chunks are no longer in the file, but their items are still searchable: %s. "+
+			"The Realtime API can't delete items, so delete them on the Gloo AI platform, then run update again with --removed-deleted",
+			len(ids), strings.Join(ids, ", "))
+	}
+	for _, id := range ids {
+		if err := app.catalog.RecordDeleted(id); err != nil {
+			return fmt.Errorf("failed to record %s as deleted: %w", id, err)
+		}
+	}
+	fmt.Printf("🗑️  Recorded %d removed chunks as deleted: %s\n", len(ids), strings.Join(ids, ", "))
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Exit codes, so scripts and schedulers can tell a partial failure from a
+// problem they need to fix
+const (
+	exitOK             = 0 // everything succeeded
+	exitPartialFailure = 1 // some files or jobs failed
+	exitConfigError    = 2 // bad arguments, settings, or missing credentials
+	exitAuthError      = 3 // the credentials were rejected
+)
+
+var (
+	// errConfig marks errors in the arguments or settings
+	errConfig = errors.New("invalid configuration")
+	// errAuth marks failures to authenticate with Gloo AI
+	errAuth = errors.New("authentication failed")
+	// errFilesFailed marks runs where some files or jobs failed
+	errFilesFailed = errors.New("some uploads failed")
+)
+
+// exitCode returns the exit code for a command's error
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errConfig):
+		return exitConfigError
+	case errors.Is(err, errAuth):
+		return exitAuthError
+	}
+	return exitPartialFailure
+}
+
+// BatchOptions control a run of many uploads
+type BatchOptions struct {
+	// MaxFailures stops the run after this many failures; 0 means never.
+	// --fail-fast sets it to 1.
+	MaxFailures int
+	// Progress is the progress mode; empty picks one for the terminal
+	Progress string
+	// Duplicates is how a batch handles near-duplicate files: DuplicatesWarn
+	// (the default when empty), DuplicatesSkip, or DuplicatesOff
+	Duplicates string
+	// Similarity is how alike two files must be to count as duplicates;
+	// 0 means defaultSimilarity
+	Similarity float64
+	// Uploaders, if above 0, runs a batch as a pipeline that reads and
+	// uploads this many files at once
+	Uploaders int
+}
+
+// parseBatchOptions reads --fail-fast, --max-failures N (or
+// --max-failures=N), --progress=MODE, --duplicates=MODE, --similarity=N,
+// --pipeline, and --uploaders=N from a command's arguments
+func parseBatchOptions(args []string) (BatchOptions, error) {
+	var opts BatchOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--fail-fast":
+			opts.MaxFailures = 1
+		case arg == "--max-failures" || strings.HasPrefix(arg, "--max-failures="):
+			value := strings.TrimPrefix(arg, "--max-failures=")
+			if arg == "--max-failures" {
+				if i+1 >= len(args) {
+					return opts, fmt.Errorf("%w: --max-failures needs a number", errConfig)
+				}
+				i++
+				value = args[i]
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return opts, fmt.Errorf("%w: --max-failures must be a positive number, not %q", errConfig, value)
+			}
+			opts.MaxFailures = n
+		case strings.HasPrefix(arg, "--progress="):
+			opts.Progress = strings.TrimPrefix(arg, "--progress=")
+			if !validProgressMode(opts.Progress) {
+				return opts, fmt.Errorf("%w: --progress must be line, json, or none, not %q", errConfig, opts.Progress)
+			}
+		case strings.HasPrefix(arg, "--duplicates="):
+			opts.Duplicates = strings.TrimPrefix(arg, "--duplicates=")
+			if opts.Duplicates != DuplicatesWarn && opts.Duplicates != DuplicatesSkip && opts.Duplicates != DuplicatesOff {
+				return opts, fmt.Errorf("%w: --duplicates must be warn, skip, or off, not %q", errConfig, opts.Duplicates)
+			}
+		case strings.HasPrefix(arg, "--similarity="):
+			value := strings.TrimPrefix(arg, "--similarity=")
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || n <= 0 || n > 1 {
+				return opts, fmt.Errorf("%w: --similarity must be a number above 0 and at most 1, not %q", errConfig, value)
+			}
+			opts.Similarity = n
+		case arg == "--pipeline":
+			if opts.Uploaders == 0 {
+				opts.Uploaders = defaultUploaders
+			}
+		case strings.HasPrefix(arg, "--uploaders="):
+			value := strings.TrimPrefix(arg, "--uploaders=")
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return opts, fmt.Errorf("%w: --uploaders must be a positive number, not %q", errConfig, value)
+			}
+			opts.Uploaders = n
+		}
+	}
+	return opts, nil
+}
+
+// shouldStop reports whether a run should give up after its latest failure,
+// and why. A rejected login stops it at once, since every later upload
+// would fail the same way.
+func (o BatchOptions) shouldStop(failed int, err error) (bool, string) {
+	switch {
+	case errors.Is(err, errAuth):
+		return true, "authentication failed"
+	case o.MaxFailures > 0 && failed >= o.MaxFailures:
+		return true, fmt.Sprintf("failure limit of %d reached", o.MaxFailures)
+	}
+	return false, ""
+}
+
+// runResult turns a run's failures into the error main exits with
+func runResult(lastErr error, failed, total int) error {
+	switch {
+	case errors.Is(lastErr, errAuth):
+		return lastErr
+	case failed > 0:
+		return fmt.Errorf("%w: %d of %d failed", errFilesFailed, failed, total)
+	}
+	return nil
+}
+
+// hasFlag reports whether a command-line flag was given after the
+// command's arguments
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
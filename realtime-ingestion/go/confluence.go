@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultConfluenceInterval is how often the Confluence connector checks
+// a space
+const defaultConfluenceInterval = time.Hour
+
+// confluencePage is a page from the Confluence REST API, with its body in
+// storage format
+type confluencePage struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Space struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	} `json:"space"`
+	Body struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+	Version struct {
+		Number int    `json:"number"`
+		When   string `json:"when"`
+		By     struct {
+			DisplayName string `json:"displayName"`
+		} `json:"by"`
+	} `json:"version"`
+	History struct {
+		CreatedBy struct {
+			DisplayName string `json:"displayName"`
+		} `json:"createdBy"`
+	} `json:"history"`
+	Links struct {
+		WebUI string `json:"webui"`
+	} `json:"_links"`
+}
+
+// modified returns when the page was last changed, or the zero time
+func (p *confluencePage) modified() time.Time {
+	when, _ := time.Parse(time.RFC3339, p.Version.When)
+	return when
+}
+
+// confluenceState is the sync cursor for a space: the last-modified time
+// synced up to, and the version of each page ingested
+type confluenceState struct {
+	Cursor   time.Time      `json:"cursor"`
+	Versions map[string]int `json:"versions"`
+}
+
+// confluenceSource reads pages from Confluence Cloud, with an email and
+// API token, or Confluence Data Center, with a personal access token
+type confluenceSource struct {
+	baseURL    string
+	user       string
+	token      string
+	httpClient *http.Client
+}
+
+// newConfluenceSource reads CONFLUENCE_URL, CONFLUENCE_USER, and
+// CONFLUENCE_TOKEN
+func newConfluenceSource() (*confluenceSource, error) {
+	baseURL := strings.TrimRight(getEnv("CONFLUENCE_URL", ""), "/")
+	if u, err := url.Parse(baseURL); baseURL == "" || err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%w: CONFLUENCE_URL must be set to your Confluence site, such as https://example.atlassian.net/wiki", errConfig)
+	}
+	token := getEnv("CONFLUENCE_TOKEN", "")
+	if token == "" {
+		return nil, fmt.Errorf("%w: CONFLUENCE_TOKEN must be set to an API token (Cloud) or personal access token (Data Center)", errConfig)
+	}
+	return &confluenceSource{
+		baseURL:    baseURL,
+		user:       getEnv("CONFLUENCE_USER", ""),
+		token:      token,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// get calls the REST API, decoding the reply into out
+func (s *confluenceSource) get(rawURL string, out interface{}) error {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	// Cloud takes an email and API token; Data Center a bearer token
+	if s.user != "" {
+		req.SetBasicAuth(s.user, s.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Confluence request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Confluence response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: Confluence rejected the credentials (%d); check CONFLUENCE_USER and CONFLUENCE_TOKEN", errConfig, resp.StatusCode)
+	case resp.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("%w: Confluence rejected the search: %s", errConfig, body)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("Confluence returned %d: %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid Confluence response: %w", err)
+	}
+	return nil
+}
+
+// searchURL returns the first page of a CQL search for the space's pages,
+// oldest change first. CQL compares dates in the user's time zone, so the
+// search starts a day before since, and pages already ingested are
+// skipped by their version.
+func (s *confluenceSource) searchURL(space string, since time.Time) string {
+	cql := fmt.Sprintf(`space = "%s" AND type = page`, space)
+	if !since.IsZero() {
+		cql += fmt.Sprintf(` AND lastmodified >= "%s"`, since.Add(-24*time.Hour).Format("2006-01-02"))
+	}
+	cql += " ORDER BY lastmodified ASC"
+	params := url.Values{
+		"cql":    {cql},
+		"expand": {"body.storage,version,history,space"},
+		"limit":  {"25"},
+	}
+	return s.baseURL + "/rest/api/content/search?" + params.Encode()
+}
+
+// pageContentData builds a page's payload: the page as Markdown, after a
+// header with its space, title, URL, and last change, with its creator
+// (and last editor) as authors and its last change as publication date
+func (cp *ContentProcessor) pageContentData(page *confluencePage, pageURL string) (*ContentData, error) {
+	markdown, err := storageToMarkdown(page.Body.Storage.Value)
+	if err != nil {
+		return nil, err
+	}
+	modified := page.modified()
+	var header strings.Builder
+	fmt.Fprintf(&header, "Space: %s\nPage: %s\nURL: %s\n", page.Space.Name, page.Title, pageURL)
+	if !modified.IsZero() {
+		fmt.Fprintf(&header, "Last modified: %s by %s\n", modified.Format("2006-01-02"), page.Version.By.DisplayName)
+	}
+
+	data := cp.CreateContentData(header.String()+"\n"+markdown, page.Title)
+	data.ItemTags = append(data.ItemTags, "confluence")
+	var authors []string
+	for _, name := range []string{page.History.CreatedBy.DisplayName, page.Version.By.DisplayName} {
+		if name != "" && !contains(authors, name) {
+			authors = append(authors, name)
+		}
+	}
+	if len(authors) > 0 {
+		data.Author = authors
+	}
+	if !modified.IsZero() {
+		data.PublicationDate = modified.Format("2006-01-02")
+	}
+	data.ProducerID = "confluence/" + page.ID
+	cp.enrich(data)
+	return data, nil
+}
+
+// loadConfluenceState reads the sync cursor of each space
+func loadConfluenceState(path string) (map[string]*confluenceState, error) {
+	state := make(map[string]*confluenceState)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Confluence state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse Confluence state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Confluence ingests a space's pages, then checks for changed pages every
+// interval until stopped. args is the space key, then --once,
+// --interval=DURATION, and --full, to walk the whole space again rather
+// than from the sync cursor.
+func (app *Application) Confluence(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "--") {
+		return fmt.Errorf("%w: confluence needs a space key", errConfig)
+	}
+	space := args[0]
+	source, err := newConfluenceSource()
+	if err != nil {
+		return err
+	}
+
+	once, full, interval := false, false, defaultConfluenceInterval
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "--once":
+			once = true
+		case arg == "--full":
+			full = true
+		case strings.HasPrefix(arg, "--interval="):
+			interval, err = time.ParseDuration(strings.TrimPrefix(arg, "--interval="))
+			if err != nil || interval <= 0 {
+				return fmt.Errorf("%w: --interval must be a positive duration, such as 1h", errConfig)
+			}
+		default:
+			return fmt.Errorf("%w: unknown confluence option %q", errConfig, arg)
+		}
+	}
+
+	statePath := getEnv("GLOO_CONFLUENCE_STATE", "confluence_state.json")
+	states, err := loadConfluenceState(statePath)
+	if err != nil {
+		return err
+	}
+	key := source.baseURL + " " + space
+	state := states[key]
+	if state == nil {
+		state = &confluenceState{}
+		states[key] = state
+	}
+	if state.Versions == nil {
+		state.Versions = make(map[string]int)
+	}
+	save := func() error {
+		data, err := json.MarshalIndent(states, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal Confluence state: %w", err)
+		}
+		if err := ioutil.WriteFile(statePath, data, 0644); err != nil {
+			return fmt.Errorf("failed to save Confluence state: %w", err)
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("📚 Syncing Confluence space %s from %s\n", space, source.baseURL)
+	if !state.Cursor.IsZero() && !full {
+		fmt.Printf("   Pages changed since %s\n", state.Cursor.Format(time.RFC3339))
+	}
+	if !once {
+		fmt.Printf("   Checking every %s; press Ctrl+C to stop\n", interval)
+	}
+	for {
+		ingested, failed, err := app.confluenceOnce(ctx, source, space, full, state, save)
+		full = false
+		switch {
+		case err != nil && (once || errors.Is(err, errConfig) || errors.Is(err, errAuth)):
+			return err
+		case err != nil:
+			fmt.Printf("⚠️  Space sync failed: %v; trying again in %s\n", err, interval)
+		case once && failed > 0:
+			return fmt.Errorf("%d pages failed to ingest; they're tried again on the next sync", failed)
+		case ingested > 0 || failed > 0:
+			fmt.Printf("📚 Ingested %d pages, %d failed\n", ingested, failed)
+		}
+		if once {
+			return nil
+		}
+		sleepContext(ctx, interval)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// confluenceOnce ingests the space's pages changed since the cursor, or
+// every page if full, skipping versions already ingested. The cursor then
+// moves to the latest change seen, but not past a page that failed, so
+// the next sync tries it again.
+func (app *Application) confluenceOnce(ctx context.Context, source *confluenceSource, space string, full bool, state *confluenceState, save func() error) (ingested, failed int, err error) {
+	since := state.Cursor
+	if full {
+		since = time.Time{}
+	}
+	var latest, firstFailed time.Time
+	next := source.searchURL(space, since)
+	for next != "" && ctx.Err() == nil {
+		var results struct {
+			Results []confluencePage `json:"results"`
+			Links   struct {
+				Base string `json:"base"`
+				Next string `json:"next"`
+			} `json:"_links"`
+		}
+		if err := source.get(next, &results); err != nil {
+			return ingested, failed, err
+		}
+		base := results.Links.Base
+		if base == "" {
+			base = source.baseURL
+		}
+
+		for i := range results.Results {
+			page := &results.Results[i]
+			modified := page.modified()
+			if modified.After(latest) {
+				latest = modified
+			}
+			if state.Versions[page.ID] >= page.Version.Number {
+				continue
+			}
+			contentData, err := app.processor.pageContentData(page, base+page.Links.WebUI)
+			if err == nil {
+				var result *ApiResponse
+				if result, err = app.processor.UploadContent(contentData); err == nil {
+					fmt.Printf("✅ Successfully uploaded: %s\n", contentData.ItemTitle)
+					if app.catalog != nil {
+						if err := app.catalog.RecordUpload(base+page.Links.WebUI, contentData, result); err != nil {
+							fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", contentData.ItemTitle, err)
+						}
+					}
+				} else {
+					err = fmt.Errorf("upload failed: %w", err)
+				}
+			}
+			if err != nil {
+				fmt.Printf("❌ Failed to ingest %s: %v\n", page.Title, err)
+				failed++
+				if firstFailed.IsZero() || modified.Before(firstFailed) {
+					firstFailed = modified
+				}
+				if errors.Is(err, errAuth) {
+					return ingested, failed, err
+				}
+				continue
+			}
+			ingested++
+			state.Versions[page.ID] = page.Version.Number
+			if err := save(); err != nil {
+				return ingested, failed, err
+			}
+		}
+
+		next = ""
+		if results.Links.Next != "" {
+			next = base + results.Links.Next
+		}
+	}
+	if ctx.Err() != nil {
+		return ingested, failed, nil
+	}
+
+	if !firstFailed.IsZero() && firstFailed.Before(latest) {
+		latest = firstFailed
+	}
+	if latest.After(state.Cursor) {
+		state.Cursor = latest
+		return ingested, failed, save()
+	}
+	return ingested, failed, nil
+}
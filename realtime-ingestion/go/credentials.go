@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Credentials are the client ID and secrets used to request tokens
+type Credentials struct {
+	ClientID        string
+	ClientSecret    string
+	SecondarySecret string
+}
+
+// CredentialProvider fetches client credentials from a secret store. It is
+// asked again when the token endpoint rejects the credentials it gave, so a
+// rotated secret is picked up without a restart.
+type CredentialProvider interface {
+	Name() string
+	Credentials() (*Credentials, error)
+}
+
+// credentialProviders builds the provider named by GLOO_CREDENTIALS_PROVIDER.
+// The default, env, reads GLOO_CLIENT_ID and the secrets from the
+// environment or .env file and needs no provider.
+var credentialProviders = map[string]func() (CredentialProvider, error){
+	"vault": newVaultProvider,
+	"aws":   newAWSProvider,
+}
+
+// loadCredentials sets the credentials from the configured provider, and
+// returns the provider, or nil for env
+func loadCredentials() (CredentialProvider, error) {
+	name := getEnv("GLOO_CREDENTIALS_PROVIDER", "env")
+	if name == "env" {
+		return nil, nil
+	}
+	newProvider, ok := credentialProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown credentials provider %q (expected env, vault, or aws)", name)
+	}
+	provider, err := newProvider()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := provider.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	clientID, clientSecret, secondaryClientSecret = creds.ClientID, creds.ClientSecret, creds.SecondarySecret
+	fmt.Printf("🔑 Loaded credentials from %s\n", provider.Name())
+	return provider, nil
+}
+
+// credentialsFromSecret reads credentials from a secret's key/value pairs.
+// Keys may be named like the environment variables or in lower case
+// without the GLOO_ prefix.
+func credentialsFromSecret(source string, values map[string]interface{}) (*Credentials, error) {
+	get := func(names ...string) string {
+		for _, name := range names {
+			if v, ok := values[name].(string); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	creds := &Credentials{
+		ClientID:        get("client_id", "GLOO_CLIENT_ID"),
+		ClientSecret:    get("client_secret", "GLOO_CLIENT_SECRET"),
+		SecondarySecret: get("client_secret_secondary", "GLOO_CLIENT_SECRET_SECONDARY"),
+	}
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return nil, fmt.Errorf("%s has no client_id and client_secret keys", source)
+	}
+	return creds, nil
+}
+
+// vaultProvider reads credentials from a HashiCorp Vault KV secret, version
+// 1 or 2
+type vaultProvider struct {
+	addr      string
+	token     string
+	namespace string
+	path      string
+	client    *http.Client
+}
+
+func newVaultProvider() (CredentialProvider, error) {
+	p := &vaultProvider{
+		addr:      strings.TrimSuffix(getEnv("VAULT_ADDR", ""), "/"),
+		token:     getEnv("VAULT_TOKEN", ""),
+		namespace: getEnv("VAULT_NAMESPACE", ""),
+		path:      strings.Trim(getEnv("GLOO_VAULT_PATH", ""), "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if p.addr == "" || p.token == "" || p.path == "" {
+		return nil, fmt.Errorf("the vault provider needs VAULT_ADDR, VAULT_TOKEN, and GLOO_VAULT_PATH (e.g. secret/data/gloo for a KV v2 mount)")
+	}
+	return p, nil
+}
+
+func (p *vaultProvider) Name() string {
+	return "Vault (" + p.path + ")"
+}
+
+func (p *vaultProvider) Credentials() (*Credentials, error) {
+	req, err := http.NewRequest("GET", p.addr+"/v1/"+p.path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Add("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret from Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read secret from Vault: %s - %s", resp.Status, string(body))
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Vault response: %w", err)
+	}
+	// KV version 2 nests the values (next to their metadata) one level deeper
+	values := secret.Data
+	if nested, ok := values["data"].(map[string]interface{}); ok {
+		values = nested
+	}
+	return credentialsFromSecret("Vault secret "+p.path, values)
+}
+
+// awsProvider reads credentials from an AWS Secrets Manager secret holding
+// a JSON object. It uses the AWS CLI, so every way the CLI finds AWS
+// credentials (profiles, SSO, instance and task roles) works here too.
+type awsProvider struct {
+	secretID string
+}
+
+func newAWSProvider() (CredentialProvider, error) {
+	secretID := getEnv("GLOO_AWS_SECRET_ID", "")
+	if secretID == "" {
+		return nil, fmt.Errorf("the aws provider needs GLOO_AWS_SECRET_ID, the name or ARN of the secret")
+	}
+	if _, err := exec.LookPath("aws"); err != nil {
+		return nil, fmt.Errorf("the aws provider needs the AWS CLI (https://aws.amazon.com/cli/) on the PATH")
+	}
+	return &awsProvider{secretID: secretID}, nil
+}
+
+func (p *awsProvider) Name() string {
+	return "AWS Secrets Manager (" + p.secretID + ")"
+}
+
+func (p *awsProvider) Credentials() (*Credentials, error) {
+	cmd := exec.Command("aws", "secretsmanager", "get-secret-value",
+		"--secret-id", p.secretID, "--query", "SecretString", "--output", "text")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret from AWS Secrets Manager: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(out, &values); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object: %w", p.secretID, err)
+	}
+	return credentialsFromSecret("secret "+p.secretID, values)
+}
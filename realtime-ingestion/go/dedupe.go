@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Ways a batch handles near-duplicate files
+const (
+	DuplicatesWarn = "warn" // report them and upload anyway
+	DuplicatesSkip = "skip" // upload only the first of each group
+	DuplicatesOff  = "off"  // don't look for them
+)
+
+// defaultSimilarity is how alike two files must be to count as duplicates
+const defaultSimilarity = 0.95
+
+const (
+	// shingleWords is the length of the word sequences files are compared by
+	shingleWords = 5
+	// minHashBands and minHashRows split a signature for locality-sensitive
+	// hashing. Two files become candidates if any band matches, which
+	// catches nearly every pair above 0.85 similarity while rarely pairing
+	// unrelated files.
+	minHashBands = 16
+	minHashRows  = 8
+	// minHashSize is the number of hashes in a signature
+	minHashSize = minHashBands * minHashRows
+)
+
+// Duplicate is a file that is nearly the same as one earlier in the batch
+type Duplicate struct {
+	File       string
+	Of         string  // the earlier file it duplicates
+	Similarity float64 // estimated share of word sequences in common, 0-1
+}
+
+// MinHash is a file's signature: for each of minHashSize hash functions,
+// the smallest hash of any of its shingles. The share of positions at
+// which two signatures agree estimates how many shingles the files share.
+type MinHash [minHashSize]uint64
+
+// Similarity estimates the Jaccard similarity of the files behind two
+// signatures
+func (m *MinHash) Similarity(other *MinHash) float64 {
+	same := 0
+	for i := range m {
+		if m[i] == other[i] {
+			same++
+		}
+	}
+	return float64(same) / minHashSize
+}
+
+// minHashSeeds are the seeds of the hash functions, fixed so signatures
+// are the same from run to run
+var minHashSeeds = func() [minHashSize]uint64 {
+	var seeds [minHashSize]uint64
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range seeds {
+		x = splitmix(x)
+		seeds[i] = x
+	}
+	return seeds
+}()
+
+// splitmix scrambles a 64-bit value, turning one shingle hash into many
+// independent ones
+func splitmix(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ x>>30) * 0xBF58476D1CE4E5B9
+	x = (x ^ x>>27) * 0x94D049BB133111EB
+	return x ^ x>>31
+}
+
+// minHashText returns the signature of text, or false if it has no words.
+// Case and punctuation are ignored, so a re-export with different line
+// wrapping or curly quotes still matches.
+func minHashText(text string) (*MinHash, bool) {
+	m := newMinHasher()
+	m.write(text)
+	return m.sum()
+}
+
+// minHasher builds a signature from text written to it in pieces, so a
+// large file's signature is built one part at a time
+type minHasher struct {
+	sig MinHash
+	// window is the last shingleWords words
+	window []string
+	words  int
+	// partial is a word that may continue in the next piece
+	partial string
+}
+
+func newMinHasher() *minHasher {
+	m := &minHasher{window: make([]string, 0, shingleWords)}
+	for i := range m.sig {
+		m.sig[i] = math.MaxUint64
+	}
+	return m
+}
+
+// write adds the words of the next piece of text
+func (m *minHasher) write(text string) {
+	text = m.partial + strings.ToLower(text)
+	m.partial = ""
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 0 && strings.HasSuffix(text, words[len(words)-1]) {
+		m.partial, words = words[len(words)-1], words[:len(words)-1]
+	}
+	for _, word := range words {
+		m.word(word)
+	}
+}
+
+// word adds a word, and the shingle it ends
+func (m *minHasher) word(word string) {
+	m.words++
+	if len(m.window) == shingleWords {
+		copy(m.window, m.window[1:])
+		m.window = m.window[:shingleWords-1]
+	}
+	m.window = append(m.window, word)
+	if len(m.window) == shingleWords {
+		m.shingle()
+	}
+}
+
+// shingle adds the words in the window as a shingle
+func (m *minHasher) shingle() {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(m.window, " ")))
+	shingle := h.Sum64()
+	for j, seed := range minHashSeeds {
+		if v := splitmix(shingle ^ seed); v < m.sig[j] {
+			m.sig[j] = v
+		}
+	}
+}
+
+// sum returns the signature, or false if there were no words
+func (m *minHasher) sum() (*MinHash, bool) {
+	if m.partial != "" {
+		m.word(m.partial)
+		m.partial = ""
+	}
+	if m.words == 0 {
+		return nil, false
+	}
+	if m.words < shingleWords {
+		// Shorter than one shingle, so the whole text is the shingle
+		m.shingle()
+	}
+	sig := m.sig
+	return &sig, true
+}
+
+// minHashFile returns the signature of a file, read in parts so a large
+// file is never held in memory whole
+func minHashFile(file string, partBytes int) (*MinHash, bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	m := newMinHasher()
+	parts := newPartReader(f, partBytes)
+	for {
+		text, err := parts.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		m.write(text)
+	}
+	return m.sum()
+}
+
+// FindDuplicates reads files and returns those at least threshold similar
+// to an earlier one, in order. Each is matched to the earliest file it
+// duplicates, so the first copy of a document is the one kept. Files are
+// compared on the text their handler uploads: plain text is read in parts
+// of limits.PartBytes, and other files are extracted whole by their
+// handler. Files over limits.MaxBytes aren't read at all. Files that can't
+// be read or have no words are left for the upload to deal with.
+func FindDuplicates(files []string, threshold float64, limits FileLimits, handlers *HandlerRegistry) []Duplicate {
+	sigs := make([]*MinHash, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || limits.MaxBytes > 0 && info.Size() > limits.MaxBytes {
+			continue
+		}
+		if handler, ok := handlers.Lookup(file); ok && !handler.stream {
+			if text, err := handler.text(file); err == nil {
+				sigs[i], _ = minHashText(text)
+			}
+			continue
+		}
+		// A file smaller than a part doesn't need a part's buffer
+		partBytes := limits.PartBytes
+		if info.Size() < int64(partBytes) {
+			partBytes = int(info.Size()) + 1
+		}
+		sigs[i], _ = minHashFile(file, partBytes)
+	}
+
+	// Only files that share a band are compared, so a large batch isn't
+	// compared pair by pair
+	buckets := map[string][]int{}
+	var duplicates []Duplicate
+	for i, sig := range sigs {
+		if sig == nil {
+			continue
+		}
+		best, bestSimilarity := -1, 0.0
+		var keys []string
+		for b := 0; b < minHashBands; b++ {
+			key := bandKey(b, sig[b*minHashRows:(b+1)*minHashRows])
+			keys = append(keys, key)
+			for _, j := range buckets[key] {
+				if s := sig.Similarity(sigs[j]); s >= threshold && (best < 0 || j < best) {
+					best, bestSimilarity = j, s
+				}
+			}
+		}
+		if best >= 0 {
+			// Not added to the buckets, so later copies match the original
+			duplicates = append(duplicates, Duplicate{File: files[i], Of: files[best], Similarity: bestSimilarity})
+			continue
+		}
+		for _, key := range keys {
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+	return duplicates
+}
+
+// bandKey returns the bucket for one band of a signature
+func bandKey(band int, rows []uint64) string {
+	buf := make([]byte, 8*len(rows)+1)
+	buf[0] = byte(band)
+	for i, v := range rows {
+		binary.LittleEndian.PutUint64(buf[1+8*i:], v)
+	}
+	return string(buf)
+}
+
+// reportDuplicates prints the duplicates found in a batch and returns them
+// by file
+func reportDuplicates(duplicates []Duplicate, mode string) map[string]Duplicate {
+	byFile := make(map[string]Duplicate, len(duplicates))
+	if len(duplicates) == 0 {
+		return byFile
+	}
+	action := "uploading anyway"
+	if mode == DuplicatesSkip {
+		action = "skipping"
+	}
+	fmt.Printf("🔁 Found %d near-duplicate files (%s):\n", len(duplicates), action)
+	for _, d := range duplicates {
+		fmt.Printf("   %s is %.0f%% similar to %s\n", d.File, d.Similarity*100, d.Of)
+		byFile[d.File] = d
+	}
+	return byFile
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Text encodings NormalizeContent recognizes
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// windows1252 maps bytes 0x80-0x9F to the characters Windows-1252 puts
+// there. Every other byte is the Latin-1 character of the same value, and
+// the five bytes Windows-1252 leaves undefined keep their C1 control code,
+// as browsers decode them.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// NormalizeContent turns a file's bytes into the UTF-8 text to upload, and
+// returns the encoding it was read as. A byte order mark picks UTF-8 or
+// UTF-16; otherwise valid UTF-8 is taken as UTF-8 and anything else as
+// Windows-1252, which also reads Latin-1 correctly. The BOM is dropped and
+// line endings become \n.
+func (cp *ContentProcessor) NormalizeContent(data []byte) (string, string) {
+	return normalizeContent(data)
+}
+
+// normalizeContent is NormalizeContent, for callers without a processor
+func normalizeContent(data []byte) (string, string) {
+	var text, encoding string
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		text, encoding = string(data[3:]), EncodingUTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		text, encoding = decodeUTF16(data[2:], false), EncodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		text, encoding = decodeUTF16(data[2:], true), EncodingUTF16BE
+	case utf8.Valid(data):
+		text, encoding = string(data), EncodingUTF8
+	default:
+		text, encoding = decodeWindows1252(data), EncodingWindows1252
+	}
+	return normalizeText(text), encoding
+}
+
+// normalizeText drops a leading BOM and turns \r\n and lone \r line
+// endings into \n
+func normalizeText(text string) string {
+	text = strings.TrimPrefix(text, "\uFEFF")
+	if !strings.Contains(text, "\r") {
+		return text
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+// decodeUTF16 decodes UTF-16 text. A trailing odd byte is dropped.
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeWindows1252 decodes Windows-1252 text
+func decodeWindows1252(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data))
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c < 0xA0:
+			b.WriteRune(windows1252[c-0x80])
+		default:
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+)
+
+// completionsURL is the Completions V2 endpoint used by the enrichers
+const completionsURL = "https://platform.ai.gloo.com/ai/v2/chat/completions"
+
+// builtinPrompts holds the default enrichment prompt templates
+//
+//go:embed prompts/*.txt
+var builtinPrompts embed.FS
+
+// Enricher adds metadata to an upload before it is sent, such as tags
+// generated from the content
+type Enricher interface {
+	Name() string
+	Enrich(data *ContentData) error
+}
+
+// enrich runs the processor's enrichers on an upload. Enrichment is
+// optional, so a failure is only a warning and the upload goes ahead.
+func (cp *ContentProcessor) enrich(data *ContentData) {
+	for _, e := range cp.enrichers {
+		if err := e.Enrich(data); err != nil {
+			fmt.Printf("⚠️  %s failed for %s: %v\n", e.Name(), data.ItemTitle, err)
+		}
+	}
+}
+
+// loadPrompt parses the prompt template at path, or the built-in one of the
+// given name if path is empty
+func loadPrompt(name, path string) (*template.Template, error) {
+	var text []byte
+	var err error
+	if path != "" {
+		text, err = ioutil.ReadFile(path)
+	} else {
+		text, err = builtinPrompts.ReadFile("prompts/" + name + ".txt")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s prompt: %w", name, err)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s prompt: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// renderPrompt fills in a prompt template
+func renderPrompt(tmpl *template.Template, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s prompt: %w", tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// firstTokens returns roughly the first n tokens of text, cut at a word
+// boundary. A token is taken to be about four characters, which holds for
+// English prose.
+func firstTokens(text string, n int) string {
+	limit := n * 4
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := limit
+	for cut > limit/2 && !unicode.IsSpace(runes[cut]) {
+		cut--
+	}
+	return string(runes[:cut])
+}
+
+// completionRequest is a Completions V2 request
+type completionRequest struct {
+	Messages    []completionMessage `json:"messages"`
+	AutoRouting bool                `json:"auto_routing"`
+	Temperature float64             `json:"temperature"`
+	MaxTokens   int                 `json:"max_tokens"`
+}
+
+// completionMessage is one chat message
+type completionMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// completionResponse is the part of a Completions V2 response the
+// enrichers read
+type completionResponse struct {
+	Choices []struct {
+		Message completionMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// CompleteJSON sends a prompt to Completions V2 at temperature 0 and
+// decodes the JSON reply into out
+func (cp *ContentProcessor) CompleteJSON(system, prompt string, maxTokens int, out interface{}) error {
+	token, err := cp.tokenManager.Token()
+	if errors.Is(err, errCredentialsRejected) {
+		return fmt.Errorf("%w: failed to get access token: %w", errAuth, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	payload, err := json.Marshal(completionRequest{
+		Messages: []completionMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+		AutoRouting: true,
+		MaxTokens:   maxTokens,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal completion request: %w", err)
+	}
+	req, err := http.NewRequest("POST", completionsURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := cp.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("completion failed: %s - %s", resp.Status, string(body))
+	}
+
+	var result completionResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to unmarshal completion: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return fmt.Errorf("no choices in completion")
+	}
+
+	content := strings.TrimSpace(result.Choices[0].Message.Content)
+	content = strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```")
+	content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+	if err := json.Unmarshal([]byte(content), out); err != nil {
+		return fmt.Errorf("failed to parse reply: %w", err)
+	}
+	return nil
+}
+
+// EnrichmentCache stores enrichment results in a JSON file, keyed by a hash
+// of the prompt that produced them. The prompt covers the content and the
+// template, so a file is enriched again only when either changes. It is
+// safe for concurrent use.
+type EnrichmentCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]json.RawMessage
+}
+
+// OpenEnrichmentCache loads the cache at path, or starts an empty one if
+// the file doesn't exist yet
+func OpenEnrichmentCache(path string) (*EnrichmentCache, error) {
+	c := &EnrichmentCache{path: path, entries: map[string]json.RawMessage{}}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read enrichment cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse enrichment cache %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// cacheKey returns the cache key for an enricher's prompt
+func cacheKey(kind, system, prompt string) string {
+	sum := sha256.Sum256([]byte(system + "\x00" + prompt))
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// Get decodes the entry for key into out, reporting whether there was one
+func (c *EnrichmentCache) Get(key string, out interface{}) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return ok && json.Unmarshal(entry, out) == nil
+}
+
+// Put stores value under key and saves the cache
+func (c *EnrichmentCache) Put(key string, value interface{}) error {
+	entry, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal enrichment cache: %w", err)
+	}
+	// Write a temporary file and rename it, as the catalog does
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), ".enrichment-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save enrichment cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save enrichment cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save enrichment cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to save enrichment cache: %w", err)
+	}
+	return nil
+}
+
+// loadEnrichers sets up the enrichers turned on in the environment
+func loadEnrichers(processor *ContentProcessor) ([]Enricher, error) {
+	var enrichers []Enricher
+	var cache *EnrichmentCache
+	openCache := func() (*EnrichmentCache, error) {
+		if cache != nil {
+			return cache, nil
+		}
+		var err error
+		cache, err = OpenEnrichmentCache(getEnv("GLOO_ENRICHMENT_CACHE", "enrichment_cache.json"))
+		return cache, err
+	}
+
+	if enabled(getEnv("GLOO_SCRIPTURE_TAGS", "")) {
+		scripture, err := NewScriptureTagger()
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, scripture)
+	}
+	if enabled(getEnv("GLOO_AUTO_TAG", "")) {
+		cache, err := openCache()
+		if err != nil {
+			return nil, err
+		}
+		tagger, err := NewTagger(processor, cache)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, tagger)
+	}
+	if enabled(getEnv("GLOO_AUTO_SUMMARY", "")) {
+		cache, err := openCache()
+		if err != nil {
+			return nil, err
+		}
+		summarizer, err := NewSummarizer(processor, cache)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, summarizer)
+	}
+	return enrichers, nil
+}
+
+// enabled reports whether an environment setting turns a feature on
+func enabled(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
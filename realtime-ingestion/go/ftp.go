@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ftpTimeout bounds connecting and each reply on the control connection
+const ftpTimeout = 30 * time.Second
+
+// ftpSource reads a directory over FTP. The protocol is plain text, so it
+// is spoken here with net/textproto. Transfers are binary and passive (EPSV,
+// falling back to PASV), to the control connection's host, so they work
+// through NAT. FTP sends the password in the clear: use SFTP where the
+// server offers it.
+type ftpSource struct {
+	host string
+	dir  string
+	raw  net.Conn
+	conn *textproto.Conn
+}
+
+// newFTPSource logs in to the server in u, anonymously if u has no user
+func newFTPSource(u *url.URL) (RemoteSource, error) {
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "21")
+	}
+	raw, err := net.DialTimeout("tcp", addr, ftpTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s := &ftpSource{host: u.Hostname(), dir: u.Path, raw: raw, conn: textproto.NewConn(raw)}
+	if err := s.login(u); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ftpSource) Name() string {
+	return "ftp://" + s.host + s.dir
+}
+
+// login authenticates and switches to binary transfers
+func (s *ftpSource) login(u *url.URL) error {
+	s.raw.SetDeadline(time.Now().Add(ftpTimeout))
+	if _, _, err := s.conn.ReadResponse(220); err != nil {
+		return fmt.Errorf("unexpected greeting: %w", err)
+	}
+	user, pass := "anonymous", "anonymous@"
+	if u.User != nil {
+		user, pass = u.User.Username(), remotePassword(u)
+	}
+	code, msg, err := s.cmd(0, "USER %s", user)
+	if err == nil && code == 331 {
+		code, msg, err = s.cmd(0, "PASS %s", pass)
+	}
+	if err != nil {
+		return err
+	}
+	if code/100 != 2 {
+		return fmt.Errorf("%w: %s rejected the login as %s: %d %s", errConfig, s.host, user, code, msg)
+	}
+	_, _, err = s.cmd(2, "TYPE I")
+	return err
+}
+
+// cmd sends a command and reads its reply, which must have the expected
+// code, or start with it if it's one digit; 0 accepts any reply
+func (s *ftpSource) cmd(expect int, format string, args ...interface{}) (int, string, error) {
+	s.raw.SetDeadline(time.Now().Add(ftpTimeout))
+	if _, err := s.conn.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	return s.conn.ReadResponse(expect)
+}
+
+// dataConn opens a passive data connection
+func (s *ftpSource) dataConn() (net.Conn, error) {
+	var port int
+	_, msg, err := s.cmd(229, "EPSV")
+	if err == nil {
+		// Entering Extended Passive Mode (|||6446|)
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start < 0 || end < start+4 {
+			return nil, fmt.Errorf("invalid EPSV reply %q", msg)
+		}
+		if port, err = strconv.Atoi(msg[start+4 : end]); err != nil {
+			return nil, fmt.Errorf("invalid EPSV reply %q", msg)
+		}
+	} else {
+		if _, msg, err = s.cmd(227, "PASV"); err != nil {
+			return nil, err
+		}
+		// Entering Passive Mode (h1,h2,h3,h4,p1,p2); the address is ignored
+		// for the control connection's, which NAT doesn't hide
+		start, end := strings.Index(msg, "("), strings.Index(msg, ")")
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("invalid PASV reply %q", msg)
+		}
+		fields := strings.Split(msg[start+1:end], ",")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("invalid PASV reply %q", msg)
+		}
+		high, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+		low, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid PASV reply %q", msg)
+		}
+		port = high<<8 | low
+	}
+	return net.DialTimeout("tcp", net.JoinHostPort(s.host, strconv.Itoa(port)), ftpTimeout)
+}
+
+// transfer runs a command that sends data, such as RETR, copying the data
+// to w
+func (s *ftpSource) transfer(w io.Writer, format string, args ...interface{}) error {
+	data, err := s.dataConn()
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	if _, _, err := s.cmd(1, format, args...); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, data); err != nil {
+		return err
+	}
+	data.Close()
+	s.raw.SetDeadline(time.Now().Add(ftpTimeout))
+	_, _, err = s.conn.ReadResponse(2)
+	return err
+}
+
+// path returns a file's path on the server
+func (s *ftpSource) path(name string) string {
+	if s.dir == "" {
+		return name
+	}
+	return path.Join(s.dir, name)
+}
+
+// List reads the directory with MLSD, or, from servers without it, with
+// NLST, asking SIZE and MDTM for each name
+func (s *ftpSource) List() ([]RemoteFile, error) {
+	var buf bytes.Buffer
+	err := s.transfer(&buf, "%s", strings.TrimSpace("MLSD "+s.dir))
+	if err == nil {
+		return parseMLSD(buf.String()), nil
+	}
+	var replyErr *textproto.Error
+	if !errors.As(err, &replyErr) || replyErr.Code/100 != 5 {
+		return nil, err
+	}
+
+	buf.Reset()
+	if err := s.transfer(&buf, "%s", strings.TrimSpace("NLST "+s.dir)); err != nil {
+		return nil, err
+	}
+	var files []RemoteFile
+	for _, line := range strings.Split(buf.String(), "\n") {
+		name := path.Base(strings.TrimSpace(line))
+		if name == "." || name == "/" {
+			continue
+		}
+		// Directories have no size
+		_, size, err := s.cmd(213, "SIZE %s", s.path(name))
+		if err != nil {
+			continue
+		}
+		file := RemoteFile{Name: name}
+		if file.Size, err = strconv.ParseInt(strings.TrimSpace(size), 10, 64); err != nil {
+			continue
+		}
+		if _, modified, err := s.cmd(213, "MDTM %s", s.path(name)); err == nil {
+			file.Version = strings.TrimSpace(modified)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// parseMLSD reads the files from an MLSD listing, whose lines are facts
+// then a space and the name, such as "type=file;size=12;modify=20240102150405; a.txt"
+func parseMLSD(listing string) []RemoteFile {
+	var files []RemoteFile
+	for _, line := range strings.Split(listing, "\n") {
+		line = strings.TrimRight(line, "\r")
+		space := strings.IndexByte(line, ' ')
+		if space < 0 {
+			continue
+		}
+		file := RemoteFile{Name: line[space+1:]}
+		isFile := false
+		for _, fact := range strings.Split(line[:space], ";") {
+			key, value, _ := strings.Cut(fact, "=")
+			switch strings.ToLower(key) {
+			case "type":
+				isFile = strings.EqualFold(value, "file")
+			case "size":
+				file.Size, _ = strconv.ParseInt(value, 10, 64)
+			case "modify":
+				file.Version = value
+			}
+		}
+		if isFile {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Fetch downloads a file with RETR
+func (s *ftpSource) Fetch(name, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := s.transfer(f, "RETR %s", s.path(name)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Close logs out
+func (s *ftpSource) Close() error {
+	s.cmd(0, "QUIT")
+	return s.raw.Close()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultHandlerTimeout bounds each run of a handler command
+const defaultHandlerTimeout = time.Minute
+
+// FileHandler turns files of one type into the text to upload
+type FileHandler struct {
+	// Name describes the handler in messages
+	Name string
+	// Extract reads a file's text
+	Extract func(path string) (string, error)
+	// Transform, if set, rewrites the extracted text before upload
+	Transform func(text string) (string, error)
+	// stream marks plain text read by the built-in extractor, so files over
+	// the stream threshold can be uploaded in parts
+	stream bool
+}
+
+// text extracts a file's text and transforms it
+func (h *FileHandler) text(path string) (string, error) {
+	text, err := h.Extract(path)
+	if err != nil {
+		return "", fmt.Errorf("%s handler failed to extract %s: %w", h.Name, path, err)
+	}
+	if h.Transform != nil {
+		if text, err = h.Transform(text); err != nil {
+			return "", fmt.Errorf("%s handler failed to transform %s: %w", h.Name, path, err)
+		}
+	}
+	return text, nil
+}
+
+// extractText reads a text file, converted to UTF-8 with \n line endings
+func extractText(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	content, encoding := normalizeContent(data)
+	if encoding != EncodingUTF8 {
+		fmt.Printf("🔤 Converted %s from %s to UTF-8\n", path, encoding)
+	}
+	return content, nil
+}
+
+// newTextHandler returns the built-in handler for plain text and Markdown
+func newTextHandler() *FileHandler {
+	return &FileHandler{Name: "text", Extract: extractText, stream: true}
+}
+
+// HandlerRegistry maps file extensions to the handlers that read them.
+// Only files with a registered extension are uploaded.
+type HandlerRegistry struct {
+	handlers map[string]*FileHandler
+	// order is the extensions in the order they were registered, which is
+	// the order a batch finds files in
+	order []string
+}
+
+// NewHandlerRegistry creates a registry of the built-in .txt and .md
+// handlers
+func NewHandlerRegistry() *HandlerRegistry {
+	r := &HandlerRegistry{handlers: make(map[string]*FileHandler)}
+	r.Register(".txt", newTextHandler())
+	r.Register(".md", newTextHandler())
+	return r
+}
+
+// Register sets the handler for an extension, such as ".docx", replacing
+// any earlier one
+func (r *HandlerRegistry) Register(ext string, h *FileHandler) {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if _, ok := r.handlers[ext]; !ok {
+		r.order = append(r.order, ext)
+	}
+	r.handlers[ext] = h
+}
+
+// Lookup returns the handler for a file, by its extension
+func (r *HandlerRegistry) Lookup(path string) (*FileHandler, bool) {
+	h, ok := r.handlers[strings.ToLower(filepath.Ext(path))]
+	return h, ok
+}
+
+// Extensions returns the registered extensions, in registration order
+func (r *HandlerRegistry) Extensions() []string {
+	return append([]string(nil), r.order...)
+}
+
+// HandlerConfig configures the handler for one extension in
+// GLOO_HANDLERS_FILE. Command extracts the text: it's run with {file}
+// replaced by the file's path, or the path added as the last argument, and
+// its output is the text. Without one, the file is read as text.
+// Transform, if set, is run with the text on stdin, and its output is
+// uploaded instead.
+type HandlerConfig struct {
+	Command   []string `json:"command,omitempty"`
+	Transform []string `json:"transform,omitempty"`
+	// Timeout bounds each run, such as "2m"; the default is a minute
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// LoadHandlers registers the handlers configured in a JSON file, such as
+// {".docx": {"command": ["pandoc", "--to", "plain", "{file}"]}}
+func (r *HandlerRegistry) LoadHandlers(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read handlers: %w", err)
+	}
+	var configs map[string]HandlerConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("failed to parse handlers %s: %w", path, err)
+	}
+	// Sorted, so a batch finds files in the same order every run
+	exts := make([]string, 0, len(configs))
+	for ext := range configs {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		config := configs[ext]
+		timeout := defaultHandlerTimeout
+		if config.Timeout != "" {
+			if timeout, err = time.ParseDuration(config.Timeout); err != nil || timeout <= 0 {
+				return fmt.Errorf("handlers %s: %s has an invalid timeout %q", path, ext, config.Timeout)
+			}
+		}
+		h := newTextHandler()
+		if len(config.Command) > 0 {
+			h = &FileHandler{Name: config.Command[0], Extract: commandExtractor(config.Command, timeout)}
+		}
+		if len(config.Transform) > 0 {
+			h.Transform = commandTransformer(config.Transform, timeout)
+			h.stream = false
+		}
+		r.Register(ext, h)
+	}
+	return nil
+}
+
+// commandExtractor returns an Extract that runs a command on the file
+func commandExtractor(command []string, timeout time.Duration) func(string) (string, error) {
+	return func(path string) (string, error) {
+		args := make([]string, 0, len(command))
+		found := false
+		for _, arg := range command[1:] {
+			if strings.Contains(arg, "{file}") {
+				found = true
+			}
+			args = append(args, strings.ReplaceAll(arg, "{file}", path))
+		}
+		if !found {
+			args = append(args, path)
+		}
+		return runHandlerCommand(command[0], args, nil, timeout)
+	}
+}
+
+// commandTransformer returns a Transform that pipes the text through a
+// command
+func commandTransformer(command []string, timeout time.Duration) func(string) (string, error) {
+	return func(text string) (string, error) {
+		return runHandlerCommand(command[0], command[1:], strings.NewReader(text), timeout)
+	}
+}
+
+// runHandlerCommand runs a handler command and returns its output as
+// normalized text
+func runHandlerCommand(name string, args []string, stdin *strings.Reader, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out after %s", name, timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	text, _ := normalizeContent(out)
+	return text, nil
+}
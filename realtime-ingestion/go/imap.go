@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapTimeout bounds connecting and each IMAP command, including fetching
+// a message
+const imapTimeout = 2 * time.Minute
+
+// imapClient speaks the few IMAP4rev1 commands the mail connector needs,
+// with the standard library. Mailboxes are opened read-only, and messages
+// fetched with BODY.PEEK, so reading them doesn't mark them as seen.
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// imapResponse is one response line, with the literals ({n} then n bytes)
+// it carried removed
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+// dialIMAP connects to the server in u: over TLS for imaps://, on port 993
+// by default, or for imap://, on port 143, in the clear and then upgraded
+// with STARTTLS. A server that won't upgrade is refused, so the login is
+// never sent unencrypted.
+func dialIMAP(u *url.URL) (*imapClient, error) {
+	dialer := &net.Dialer{Timeout: imapTimeout}
+	var conn net.Conn
+	var err error
+	switch u.Scheme {
+	case "imaps":
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "993")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	case "imap":
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "143")
+		}
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return nil, fmt.Errorf("%w: unsupported mail URL scheme %q (expected imaps or imap)", errConfig, u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(imapTimeout))
+	greeting, err := c.readResponse()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting.line, "* OK") && !strings.HasPrefix(greeting.line, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting from %s: %q", u.Host, greeting.line)
+	}
+	if u.Scheme == "imap" {
+		if err := c.startTLS(u.Hostname(), greeting); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startTLS upgrades a connection opened in the clear to TLS
+func (c *imapClient) startTLS(host string, greeting imapResponse) error {
+	// STARTTLS is only allowed before logging in
+	if strings.HasPrefix(greeting.line, "* PREAUTH") {
+		return fmt.Errorf("%w: %s logged in before STARTTLS, so the connection can't be encrypted; use imaps://", errConfig, host)
+	}
+	if _, err := c.command("STARTTLS"); err != nil {
+		return fmt.Errorf("%w: %s doesn't support STARTTLS (%v), and the login won't be sent unencrypted; use imaps://", errConfig, host, err)
+	}
+	// Anything sent before the handshake could have been injected by an
+	// attacker, so it must not be read as if it came over TLS
+	if c.r.Buffered() > 0 {
+		return fmt.Errorf("%s sent data before the TLS handshake", host)
+	}
+	conn := tls.Client(c.conn, &tls.Config{ServerName: host})
+	if err := conn.Handshake(); err != nil {
+		return fmt.Errorf("STARTTLS with %s failed: %w", host, err)
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	return nil
+}
+
+// readResponse reads one response. A line ending in {n} is followed by n
+// bytes of literal, then the rest of the line.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return resp, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		resp.line += line
+		open := strings.LastIndexByte(line, '{')
+		if open < 0 || !strings.HasSuffix(line, "}") {
+			return resp, nil
+		}
+		n, err := strconv.Atoi(line[open+1 : len(line)-1])
+		if err != nil {
+			return resp, nil
+		}
+		literal := make([]byte, n)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return resp, err
+		}
+		resp.literals = append(resp.literals, literal)
+	}
+}
+
+// command sends a command and returns the responses before its tagged
+// completion, or an error unless that's OK
+func (c *imapClient) command(format string, args ...interface{}) ([]imapResponse, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	c.conn.SetDeadline(time.Now().Add(imapTimeout))
+	if _, err := fmt.Fprintf(c.conn, tag+" "+format+"\r\n", args...); err != nil {
+		return nil, err
+	}
+	var responses []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if status := strings.TrimPrefix(resp.line, tag+" "); status != resp.line {
+			if !strings.HasPrefix(status, "OK") {
+				return responses, fmt.Errorf("server answered %s", status)
+			}
+			return responses, nil
+		}
+		responses = append(responses, resp)
+	}
+}
+
+// imapQuote quotes a string argument. A quoted string can't hold a line
+// break, which would end the command and start another, or a NUL.
+func imapQuote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return "", fmt.Errorf("%w: IMAP arguments can't contain line breaks or NUL characters", errConfig)
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+}
+
+// login authenticates with a password, such as an app password for Gmail
+func (c *imapClient) login(user, password string) error {
+	quotedUser, err := imapQuote(user)
+	if err != nil {
+		return err
+	}
+	quotedPassword, err := imapQuote(password)
+	if err != nil {
+		return err
+	}
+	if _, err := c.command("LOGIN %s %s", quotedUser, quotedPassword); err != nil {
+		return fmt.Errorf("%w: login as %s was rejected: %w", errConfig, user, err)
+	}
+	return nil
+}
+
+// examine opens a mailbox read-only and returns its UIDVALIDITY, which
+// changes if the server renumbers its messages
+func (c *imapClient) examine(mailbox string) (uint32, error) {
+	quoted, err := imapQuote(mailbox)
+	if err != nil {
+		return 0, err
+	}
+	responses, err := c.command("EXAMINE %s", quoted)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to open mailbox %q: %w", errConfig, mailbox, err)
+	}
+	for _, resp := range responses {
+		if i := strings.Index(resp.line, "[UIDVALIDITY "); i >= 0 {
+			rest := resp.line[i+len("[UIDVALIDITY "):]
+			if end := strings.IndexByte(rest, ']'); end > 0 {
+				validity, err := strconv.ParseUint(rest[:end], 10, 32)
+				if err == nil {
+					return uint32(validity), nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("server gave no UIDVALIDITY for mailbox %q", mailbox)
+}
+
+// searchAfter returns the UIDs of the messages after a UID, in order
+func (c *imapClient) searchAfter(uid uint32) ([]uint32, error) {
+	responses, err := c.command("UID SEARCH UID %d:*", uid+1)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, resp := range responses {
+		if !strings.HasPrefix(resp.line, "* SEARCH") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(resp.line, "* SEARCH")) {
+			// n:* always matches the newest message, even if it's not after n
+			if found, err := strconv.ParseUint(field, 10, 32); err == nil && uint32(found) > uid {
+				uids = append(uids, uint32(found))
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+// fetch returns a message's raw RFC 5322 text, or nil if it's been deleted
+func (c *imapClient) fetch(uid uint32) ([]byte, error) {
+	responses, err := c.command("UID FETCH %d (BODY.PEEK[])", uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range responses {
+		if strings.Contains(resp.line, "FETCH") && len(resp.literals) > 0 {
+			return resp.literals[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// logout ends the session and closes the connection
+func (c *imapClient) logout() {
+	c.command("LOGOUT")
+	c.conn.Close()
+}
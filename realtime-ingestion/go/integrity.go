@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Integrity check results
+const (
+	IntegrityOK         = "ok"
+	IntegrityTruncated  = "truncated"  // the processed item is a prefix of the upload
+	IntegrityEncoding   = "encoding"   // the processed item's characters were corrupted
+	IntegrityMismatch   = "mismatch"   // the processed item differs some other way
+	IntegrityUnverified = "unverified" // there was nothing to check against yet
+	IntegrityError      = "error"      // the processed item couldn't be fetched
+)
+
+// errIntegrity marks verify runs that found corrupted items
+var errIntegrity = errors.New("integrity check failed")
+
+// IntegrityResult is the outcome of checking one item
+type IntegrityResult struct {
+	TaskID       string `json:"task_id"`
+	ItemID       string `json:"item_id,omitempty"`
+	Title        string `json:"title,omitempty"`
+	File         string `json:"file,omitempty"`
+	Status       string `json:"status"`
+	Detail       string `json:"detail,omitempty"`
+	LocalSHA256  string `json:"local_sha256,omitempty"`
+	RemoteSHA256 string `json:"remote_sha256,omitempty"`
+	LocalBytes   int    `json:"local_bytes,omitempty"`
+	RemoteBytes  int    `json:"remote_bytes,omitempty"`
+}
+
+// Problem reports whether the check found the item corrupted
+func (r *IntegrityResult) Problem() bool {
+	return r.Status == IntegrityTruncated || r.Status == IntegrityEncoding || r.Status == IntegrityMismatch
+}
+
+// IntegrityReport is the result of a verify run
+type IntegrityReport struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Checked     int               `json:"checked"`
+	OK          int               `json:"ok"`
+	Problems    int               `json:"problems"`
+	Unverified  int               `json:"unverified"`
+	Errors      int               `json:"errors"`
+	Items       []IntegrityResult `json:"items"`
+}
+
+// contentHash returns the hex SHA-256 of content
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// newIntegrityResult starts a result for an item
+func newIntegrityResult(item *CatalogItem) IntegrityResult {
+	return IntegrityResult{
+		TaskID:      item.TaskID,
+		ItemID:      item.ItemID,
+		Title:       item.Title,
+		File:        item.File,
+		LocalSHA256: item.ContentSHA256,
+		LocalBytes:  item.ContentBytes,
+	}
+}
+
+// compareChecksums checks an item against the checksum a status callback
+// reported for it. Without the content, a short item is the only kind of
+// corruption that can be told apart.
+func compareChecksums(item *CatalogItem) IntegrityResult {
+	r := newIntegrityResult(item)
+	r.RemoteSHA256, r.RemoteBytes = item.RemoteSHA256, item.RemoteBytes
+	switch {
+	case item.ContentSHA256 == "":
+		r.Status, r.Detail = IntegrityUnverified, "no hash was recorded at upload"
+	case item.RemoteSHA256 == item.ContentSHA256:
+		r.Status = IntegrityOK
+	case item.RemoteBytes > 0 && item.RemoteBytes < item.ContentBytes:
+		r.Status = IntegrityTruncated
+		r.Detail = fmt.Sprintf("%d of %d bytes were processed", item.RemoteBytes, item.ContentBytes)
+	default:
+		r.Status, r.Detail = IntegrityMismatch, "checksum differs from the upload"
+	}
+	return r
+}
+
+// compareContent checks the processed content of an item against what was
+// uploaded, and says how it differs
+func compareContent(item *CatalogItem, local, remote string) IntegrityResult {
+	r := newIntegrityResult(item)
+	r.LocalSHA256, r.LocalBytes = contentHash(local), len(local)
+	r.RemoteSHA256, r.RemoteBytes = contentHash(remote), len(remote)
+	switch {
+	case remote == local:
+		r.Status = IntegrityOK
+	case strings.HasPrefix(local, remote):
+		r.Status = IntegrityTruncated
+		r.Detail = fmt.Sprintf("%d of %d bytes were processed", len(remote), len(local))
+	case !utf8.ValidString(remote):
+		r.Status, r.Detail = IntegrityEncoding, "the processed item isn't valid UTF-8"
+	case strings.Count(remote, "\uFFFD") > strings.Count(local, "\uFFFD"):
+		r.Status, r.Detail = IntegrityEncoding, "characters were replaced with U+FFFD"
+	case remote == latin1Decode(local):
+		r.Status, r.Detail = IntegrityEncoding, "the UTF-8 upload was read as Latin-1"
+	default:
+		r.Status, r.Detail = IntegrityMismatch, fmt.Sprintf("content differs from byte %d", firstDifference(local, remote))
+	}
+	return r
+}
+
+// latin1Decode returns s as it reads when its UTF-8 bytes are taken for
+// Latin-1, the usual source of mojibake such as "Ã©" for "é"
+func latin1Decode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(rune(s[i]))
+	}
+	return b.String()
+}
+
+// firstDifference returns the offset of the first byte where a and b differ
+func firstDifference(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// RemoteItem is a processed item fetched from GLOO_ITEM_URL. Either field
+// is enough to check it.
+type RemoteItem struct {
+	Content       string `json:"content"`
+	ContentSHA256 string `json:"content_sha256"`
+}
+
+// FetchItem fetches a processed item. itemURL contains {item_id}, which is
+// replaced with the item's ID.
+func (cp *ContentProcessor) FetchItem(itemURL, itemID string) (*RemoteItem, error) {
+	token, err := cp.tokenManager.Token()
+	if errors.Is(err, errCredentialsRejected) {
+		return nil, fmt.Errorf("%w: failed to get access token: %w", errAuth, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	req, err := http.NewRequest("GET", strings.ReplaceAll(itemURL, "{item_id}", url.PathEscape(itemID)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := cp.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch item: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("%w: fetching item failed: %s - %s", errAuth, resp.Status, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching item failed: %s - %s", resp.Status, string(body))
+	}
+
+	var item RemoteItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+	if item.Content == "" && item.ContentSHA256 == "" {
+		return nil, fmt.Errorf("item has neither content nor content_sha256")
+	}
+	return &item, nil
+}
+
+// Verifier checks processed items against the content that was uploaded
+type Verifier struct {
+	processor *ContentProcessor
+	// itemURL fetches items whose callbacks carried no checksum; optional
+	itemURL string
+}
+
+// Verify checks one item. It prefers a checksum from the status callbacks,
+// then fetches the item if an item URL is set.
+func (v *Verifier) Verify(item *CatalogItem) IntegrityResult {
+	unverified := func(detail string) IntegrityResult {
+		r := newIntegrityResult(item)
+		r.Status, r.Detail = IntegrityUnverified, detail
+		return r
+	}
+	switch {
+	case item.ContentSHA256 == "":
+		return unverified("no hash was recorded at upload")
+	case item.Status != StatusCompleted:
+		return unverified("ingestion is " + item.Status)
+	case item.RemoteSHA256 != "":
+		return compareChecksums(item)
+	case v.itemURL == "":
+		return unverified("no checksum in the status callbacks; set GLOO_ITEM_URL to fetch the item")
+	case item.ItemID == "":
+		return unverified("no item ID in the status callbacks")
+	}
+
+	remote, err := v.processor.FetchItem(v.itemURL, item.ItemID)
+	if err != nil {
+		r := newIntegrityResult(item)
+		r.Status, r.Detail = IntegrityError, err.Error()
+		return r
+	}
+	if remote.Content == "" {
+		checked := *item
+		checked.RemoteSHA256 = strings.ToLower(remote.ContentSHA256)
+		return compareChecksums(&checked)
+	}
+	if local, ok := localContent(item); ok {
+		return compareContent(item, local, remote.Content)
+	}
+
+	// Without the original text only the hash and length can be compared
+	checked := *item
+	checked.RemoteSHA256, checked.RemoteBytes = contentHash(remote.Content), len(remote.Content)
+	return compareChecksums(&checked)
+}
+
+// localContent reads an item's file back, normalized as it was for upload,
+// if it still holds the content that was uploaded
+func localContent(item *CatalogItem) (string, bool) {
+	if item.File == "" {
+		return "", false
+	}
+	data, err := ioutil.ReadFile(item.File)
+	if err != nil {
+		return "", false
+	}
+	content, _ := normalizeContent(data)
+	if contentHash(content) != item.ContentSHA256 {
+		return "", false
+	}
+	return content, true
+}
+
+// VerifyCatalog checks every item in the catalog that a later upload hasn't
+// replaced and that hasn't been deleted, recording each result in it
+func (v *Verifier) VerifyCatalog(catalog *Catalog) *IntegrityReport {
+	report := &IntegrityReport{GeneratedAt: time.Now().UTC()}
+	for _, item := range catalog.Items() {
+		if !item.Live() {
+			// A later upload replaced it, or it was deleted, so it no longer
+			// exists to check
+			continue
+		}
+		item := item
+		r := v.Verify(&item)
+		report.Items = append(report.Items, r)
+		report.Checked++
+		switch {
+		case r.Status == IntegrityOK:
+			report.OK++
+		case r.Problem():
+			report.Problems++
+		case r.Status == IntegrityError:
+			report.Errors++
+		default:
+			report.Unverified++
+		}
+		if r.Status != IntegrityUnverified && r.Status != IntegrityError {
+			if err := catalog.SetIntegrity(item.TaskID, r.Status); err != nil {
+				fmt.Printf("⚠️  Failed to record the check of %s: %v\n", item.TaskID, err)
+			}
+		}
+	}
+	return report
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Job statuses. A job is pending until a command claims it, uploading
+// while it's sent, and processing once the Realtime API accepts it, until
+// a status callback reports done or failed. Uploads without a task ID
+// can't be followed, so they go straight to done.
+const (
+	JobPending    = "pending"
+	JobUploading  = "uploading"
+	JobProcessing = "processing"
+	JobDone       = "done"
+	JobFailed     = "failed"
+	JobCancelled  = "cancelled"
+)
+
+// jobStaleAfter is how long a job may sit in uploading before it's taken
+// to belong to a command that crashed, and is picked up again
+const jobStaleAfter = 10 * time.Minute
+
+// jobTimeFormat is the format of the jobs table's timestamps
+const jobTimeFormat = "2006-01-02T15:04:05Z"
+
+const jobSchema = `
+CREATE TABLE IF NOT EXISTS jobs (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  source      TEXT NOT NULL,
+  file        TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  status      TEXT NOT NULL,
+  attempts    INTEGER NOT NULL DEFAULT 0,
+  task_id     TEXT,
+  error       TEXT,
+  created_at  TEXT NOT NULL,
+  updated_at  TEXT NOT NULL,
+  UNIQUE (file, fingerprint)
+);
+CREATE INDEX IF NOT EXISTS jobs_status ON jobs (status);
+CREATE INDEX IF NOT EXISTS jobs_task_id ON jobs (task_id);
+`
+
+// Job is one file to upload and how far it has got
+type Job struct {
+	ID     int64  `json:"id"`
+	Source string `json:"source"`
+	File   string `json:"file"`
+	// Fingerprint is the file's size and modification time when the job
+	// was added, so a changed file gets a new job
+	Fingerprint string `json:"fingerprint"`
+	Status      string `json:"status"`
+	Attempts    int    `json:"attempts"`
+	TaskID      string `json:"task_id"`
+	Error       string `json:"error"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// JobStore keeps jobs in a SQLite database shared by the watch and batch
+// commands, so an interrupted run picks up where it stopped. The database
+// is opened with modernc.org/sqlite, a driver in pure Go, so no cgo is
+// needed. SQLite's locking makes it safe to use from several processes at
+// once.
+type JobStore struct {
+	db *sql.DB
+}
+
+// OpenJobStore opens the database at path, creating it if needed. Writers
+// wait up to 10 seconds for another process's lock.
+func OpenJobStore(path string) (*JobStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store %s: %w", path, err)
+	}
+	if _, err := db.Exec(jobSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open job store %s: %w", path, err)
+	}
+	return &JobStore{db: db}, nil
+}
+
+// jobNow returns the current time as the jobs table stores it
+func jobNow() string {
+	return time.Now().UTC().Format(jobTimeFormat)
+}
+
+// update runs an UPDATE and reports how many rows it changed
+func (s *JobStore) update(query string, args ...interface{}) (int64, error) {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// fileFingerprint returns a file's absolute path and fingerprint
+func fileFingerprint(file string) (string, string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stat %s: %w", file, err)
+	}
+	return abs, fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// Add adds a pending job for a file and returns it. If the file already has
+// a job and hasn't changed since, that job is returned instead and added is
+// false.
+func (s *JobStore) Add(source, file string) (job *Job, added bool, err error) {
+	abs, fingerprint, err := fileFingerprint(file)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Checking first keeps ignored inserts from using up job IDs
+	now := jobNow()
+	n, err := s.update(`
+INSERT OR IGNORE INTO jobs (source, file, fingerprint, status, created_at, updated_at)
+SELECT ?, ?, ?, ?, ?, ?
+WHERE NOT EXISTS (SELECT 1 FROM jobs WHERE file = ? AND fingerprint = ?)`,
+		source, abs, fingerprint, JobPending, now, now, abs, fingerprint)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to add job for %s: %w", file, err)
+	}
+
+	jobs, err := s.list("file = ? AND fingerprint = ?", abs, fingerprint)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(jobs) == 0 {
+		return nil, false, fmt.Errorf("job for %s disappeared", file)
+	}
+	return &jobs[0], n > 0, nil
+}
+
+// Claim moves a pending job to uploading. It returns false if the job isn't
+// pending any more, such as when another command claimed it first or it
+// was cancelled.
+func (s *JobStore) Claim(id int64) (bool, error) {
+	n, err := s.update("UPDATE jobs SET status = ?, attempts = attempts + 1, error = NULL, updated_at = ? WHERE id = ? AND status = ?",
+		JobUploading, jobNow(), id, JobPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job %d: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// MarkUploaded records a successful upload
+func (s *JobStore) MarkUploaded(id int64, result *ApiResponse) error {
+	status, taskID := JobDone, sql.NullString{}
+	if result.TaskID != nil && *result.TaskID != "" {
+		status, taskID = JobProcessing, sql.NullString{String: *result.TaskID, Valid: true}
+	}
+	if _, err := s.update("UPDATE jobs SET status = ?, task_id = ?, updated_at = ? WHERE id = ?",
+		status, taskID, jobNow(), id); err != nil {
+		return fmt.Errorf("failed to update job %d: %w", id, err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed upload
+func (s *JobStore) MarkFailed(id int64, cause error) error {
+	if _, err := s.update("UPDATE jobs SET status = ?, error = ?, updated_at = ? WHERE id = ?",
+		JobFailed, cause.Error(), jobNow(), id); err != nil {
+		return fmt.Errorf("failed to update job %d: %w", id, err)
+	}
+	return nil
+}
+
+// CompleteTask finishes the processing job for a task from its status
+// callback
+func (s *JobStore) CompleteTask(item CatalogItem) error {
+	status, message := JobDone, sql.NullString{}
+	if item.Status == StatusFailed {
+		status, message = JobFailed, sql.NullString{String: item.Message, Valid: true}
+	}
+	if _, err := s.update("UPDATE jobs SET status = ?, error = ?, updated_at = ? WHERE task_id = ? AND status = ?",
+		status, message, jobNow(), item.TaskID, JobProcessing); err != nil {
+		return fmt.Errorf("failed to update job for task %s: %w", item.TaskID, err)
+	}
+	return nil
+}
+
+// Retry puts a failed or cancelled job back to pending
+func (s *JobStore) Retry(id int64) (bool, error) {
+	n, err := s.update("UPDATE jobs SET status = ?, error = NULL, updated_at = ? WHERE id = ? AND status IN (?, ?)",
+		JobPending, jobNow(), id, JobFailed, JobCancelled)
+	if err != nil {
+		return false, fmt.Errorf("failed to retry job %d: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// RetryFailed puts every failed job back to pending and returns how many
+// there were
+func (s *JobStore) RetryFailed() (int64, error) {
+	n, err := s.update("UPDATE jobs SET status = ?, error = NULL, updated_at = ? WHERE status = ?",
+		JobPending, jobNow(), JobFailed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry jobs: %w", err)
+	}
+	return n, nil
+}
+
+// Cancel cancels a pending job. Jobs already uploading can't be stopped.
+func (s *JobStore) Cancel(id int64) (bool, error) {
+	n, err := s.update("UPDATE jobs SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+		JobCancelled, jobNow(), id, JobPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel job %d: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// ResumeStale puts jobs stuck in uploading, because the command uploading
+// them stopped, back to pending and returns how many there were
+func (s *JobStore) ResumeStale() (int64, error) {
+	staleBefore := time.Now().UTC().Add(-jobStaleAfter).Format(jobTimeFormat)
+	n, err := s.update("UPDATE jobs SET status = ?, updated_at = ? WHERE status = ? AND updated_at < ?",
+		JobPending, jobNow(), JobUploading, staleBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resume jobs: %w", err)
+	}
+	return n, nil
+}
+
+// Get returns a job by ID, or nil if there is none
+func (s *JobStore) Get(id int64) (*Job, error) {
+	jobs, err := s.list("id = ?", id)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// List returns the jobs with a status, or every job if status is empty,
+// oldest first
+func (s *JobStore) List(status string) ([]Job, error) {
+	if status == "" {
+		return s.list("1 = 1")
+	}
+	return s.list("status = ?", status)
+}
+
+// list returns the jobs matching an SQL condition, with its arguments
+func (s *JobStore) list(where string, args ...interface{}) ([]Job, error) {
+	rows, err := s.db.Query(`SELECT id, source, file, fingerprint, status, attempts, task_id, error, created_at, updated_at
+FROM jobs WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var job Job
+		var taskID, message sql.NullString
+		if err := rows.Scan(&job.ID, &job.Source, &job.File, &job.Fingerprint, &job.Status, &job.Attempts,
+			&taskID, &message, &job.CreatedAt, &job.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		job.TaskID, job.Error = taskID.String, message.String
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// RunJob claims a pending job and uploads its file. It does nothing if the
+// job can't be claimed.
+func (cp *ContentProcessor) RunJob(store *JobStore, job *Job) error {
+	claimed, err := store.Claim(job.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	result, err := cp.processFile(job.File)
+	if err != nil {
+		if markErr := store.MarkFailed(job.ID, err); markErr != nil {
+			fmt.Printf("⚠️  %v\n", markErr)
+		}
+		return err
+	}
+	return store.MarkUploaded(job.ID, result)
+}
+
+// runFileJob adds a job for a file and runs it if it's pending. ran is
+// false when the file was skipped because its job already finished, failed,
+// or is running elsewhere.
+func runFileJob(store *JobStore, processor *ContentProcessor, source, file string) (ran bool, err error) {
+	job, _, err := store.Add(source, file)
+	if err != nil {
+		return true, err
+	}
+
+	switch job.Status {
+	case JobPending:
+		return true, processor.RunJob(store, job)
+	case JobFailed, JobCancelled:
+		fmt.Printf("⏭️  Skipping %s: job %d is %s (retry it with: go run . jobs retry %d)\n", file, job.ID, job.Status, job.ID)
+	default:
+		fmt.Printf("⏭️  Skipping %s: job %d is %s\n", file, job.ID, job.Status)
+	}
+	return false, nil
+}
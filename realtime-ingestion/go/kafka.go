@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Kafka REST Proxy media types
+const (
+	kafkaContentType = "application/vnd.kafka.v2+json"
+	kafkaBinaryType  = "application/vnd.kafka.binary.v2+json"
+)
+
+// kafkaSource consumes a Kafka topic through a Kafka REST Proxy (the
+// Confluent v2 API), so no Kafka client library is needed. Offsets are
+// committed after each message is handled, so delivery is at least once:
+// after a crash, the message in flight is processed again.
+type kafkaSource struct {
+	restURL    string
+	topic      string
+	group      string
+	instance   string
+	httpClient *http.Client
+	// baseURI is the consumer instance, once created
+	baseURI string
+}
+
+// kafkaRecord is a record fetched in binary format
+type kafkaRecord struct {
+	Topic     string `json:"topic"`
+	Value     string `json:"value"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// kafkaStatusError is an error response from the REST Proxy
+type kafkaStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *kafkaStatusError) Error() string {
+	return fmt.Sprintf("Kafka REST Proxy returned %d: %s", e.StatusCode, e.Body)
+}
+
+// newKafkaSource creates a source for topic through the proxy at
+// KAFKA_REST_URL, in the consumer group KAFKA_CONSUMER_GROUP
+func newKafkaSource(topic string) (QueueSource, error) {
+	restURL := getEnv("KAFKA_REST_URL", "")
+	if restURL == "" {
+		return nil, fmt.Errorf("KAFKA_REST_URL must be set to the Kafka REST Proxy URL")
+	}
+	host, _ := os.Hostname()
+	return &kafkaSource{
+		restURL:  strings.TrimRight(restURL, "/"),
+		topic:    topic,
+		group:    getEnv("KAFKA_CONSUMER_GROUP", "gloo-ingestion"),
+		instance: fmt.Sprintf("gloo-ingestion-%s-%d", host, os.Getpid()),
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}, nil
+}
+
+func (s *kafkaSource) Name() string {
+	return "Kafka topic " + s.topic
+}
+
+// Consume polls the topic until ctx is done, then removes the consumer
+// instance so the group rebalances straight away
+func (s *kafkaSource) Consume(ctx context.Context, handle func(*QueueMessage) error) error {
+	if err := s.subscribe(ctx); err != nil {
+		return err
+	}
+	defer s.close()
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		records, err := s.fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			// The proxy drops consumer instances that sit idle too long
+			var statusErr *kafkaStatusError
+			if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+				fmt.Println("⚠️  Kafka consumer instance expired; creating a new one")
+				if err = s.subscribe(ctx); err == nil {
+					continue
+				}
+			}
+			fmt.Printf("⚠️  Kafka fetch failed: %v; retrying in %s\n", err, backoff)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+
+		for _, record := range records {
+			msg := &QueueMessage{Position: fmt.Sprintf("%s[%d]@%d", record.Topic, record.Partition, record.Offset)}
+			if msg.Value, err = base64.StdEncoding.DecodeString(record.Value); err != nil {
+				err = fmt.Errorf("invalid record value: %w", err)
+			} else {
+				err = handle(msg)
+			}
+			if err != nil {
+				fmt.Printf("❌ Skipped message at %s: %v\n", msg.Position, err)
+			}
+			if err := s.commit(ctx, record); err != nil {
+				fmt.Printf("⚠️  Failed to commit offset %s: %v\n", msg.Position, err)
+			}
+		}
+	}
+	return nil
+}
+
+// subscribe creates the consumer instance and subscribes it to the topic
+func (s *kafkaSource) subscribe(ctx context.Context) error {
+	var created struct {
+		BaseURI string `json:"base_uri"`
+	}
+	err := s.call(ctx, http.MethodPost, s.restURL+"/consumers/"+s.group, map[string]string{
+		"name":               s.instance,
+		"format":             "binary",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, &created)
+	var statusErr *kafkaStatusError
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict:
+		// Left over from a previous run with the same name
+		created.BaseURI = fmt.Sprintf("%s/consumers/%s/instances/%s", s.restURL, s.group, s.instance)
+	case err != nil:
+		return fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+	s.baseURI = created.BaseURI
+
+	if err := s.call(ctx, http.MethodPost, s.baseURI+"/subscription", map[string][]string{"topics": {s.topic}}, nil); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", s.topic, err)
+	}
+	fmt.Printf("🔌 Subscribed to %s (consumer group %s)\n", s.topic, s.group)
+	return nil
+}
+
+// fetch long-polls for the next records
+func (s *kafkaSource) fetch(ctx context.Context) ([]kafkaRecord, error) {
+	var records []kafkaRecord
+	err := s.call(ctx, http.MethodGet, s.baseURI+"/records?timeout=5000", nil, &records)
+	return records, err
+}
+
+// commit commits a handled record. The proxy commits the offset after the
+// one given, so the record isn't fetched again.
+func (s *kafkaSource) commit(ctx context.Context, record kafkaRecord) error {
+	offset := map[string]interface{}{
+		"topic":     record.Topic,
+		"partition": record.Partition,
+		"offset":    record.Offset,
+	}
+	return s.call(ctx, http.MethodPost, s.baseURI+"/offsets", map[string]interface{}{
+		"offsets": []interface{}{offset},
+	}, nil)
+}
+
+// close deletes the consumer instance
+func (s *kafkaSource) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.call(ctx, http.MethodDelete, s.baseURI, nil, nil); err != nil {
+		fmt.Printf("⚠️  Failed to remove Kafka consumer: %v\n", err)
+	}
+}
+
+// call makes a REST Proxy request, decoding the response into out if set
+func (s *kafkaSource) call(ctx context.Context, method, url string, body, out interface{}) error {
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", kafkaBinaryType+", "+kafkaContentType)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return &kafkaStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
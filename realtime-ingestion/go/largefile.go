@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Defaults for streaming large files
+const (
+	defaultStreamThreshold = 16 * 1024 * 1024
+	defaultStreamPartBytes = 4 * 1024 * 1024
+)
+
+// errLargeFile marks files too large to read into one payload, which are
+// uploaded in parts instead
+var errLargeFile = errors.New("file is too large to upload in one payload")
+
+// FileLimits bound how files are read
+type FileLimits struct {
+	// MaxBytes rejects larger files outright; 0 means no limit
+	MaxBytes int64
+	// StreamThreshold is the size above which files are streamed in parts
+	StreamThreshold int64
+	// PartBytes is the target size of each part
+	PartBytes int
+}
+
+// loadFileLimits reads GLOO_MAX_FILE_BYTES, GLOO_STREAM_THRESHOLD, and
+// GLOO_STREAM_PART_BYTES
+func loadFileLimits() (FileLimits, error) {
+	var limits FileLimits
+	var err error
+	if limits.MaxBytes, err = strconv.ParseInt(getEnv("GLOO_MAX_FILE_BYTES", "0"), 10, 64); err != nil || limits.MaxBytes < 0 {
+		return limits, fmt.Errorf("%w: GLOO_MAX_FILE_BYTES must be a number of bytes, or 0 for no limit", errConfig)
+	}
+	if limits.StreamThreshold, err = strconv.ParseInt(getEnv("GLOO_STREAM_THRESHOLD", strconv.Itoa(defaultStreamThreshold)), 10, 64); err != nil || limits.StreamThreshold <= 0 {
+		return limits, fmt.Errorf("%w: GLOO_STREAM_THRESHOLD must be a positive number of bytes", errConfig)
+	}
+	if limits.PartBytes, err = strconv.Atoi(getEnv("GLOO_STREAM_PART_BYTES", strconv.Itoa(defaultStreamPartBytes))); err != nil || limits.PartBytes <= 0 {
+		return limits, fmt.Errorf("%w: GLOO_STREAM_PART_BYTES must be a positive number of bytes", errConfig)
+	}
+	return limits, nil
+}
+
+// checkFileSize returns a file's size, or an error if it's over the limit
+// or, wrapping errLargeFile, over the stream threshold
+func (cp *ContentProcessor) checkFileSize(filePath string) (int64, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	size := info.Size()
+	if cp.limits.MaxBytes > 0 && size > cp.limits.MaxBytes {
+		return size, fmt.Errorf("%s is %d bytes, over GLOO_MAX_FILE_BYTES (%d)", filePath, size, cp.limits.MaxBytes)
+	}
+	if cp.limits.StreamThreshold > 0 && size > cp.limits.StreamThreshold {
+		return size, fmt.Errorf("%w: %s is %d bytes, over GLOO_STREAM_THRESHOLD (%d)", errLargeFile, filePath, size, cp.limits.StreamThreshold)
+	}
+	return size, nil
+}
+
+// partReader reads a file in parts of about size bytes. Each part is
+// extended to the end of a line, so no character or line ending is split,
+// but never past twice size, for files without line breaks.
+type partReader struct {
+	r        *bufio.Reader
+	size     int
+	encoding string
+	buf      []byte
+	// carry is the start of a character cut off the end of the last part
+	carry []byte
+}
+
+// newPartReader starts reading r in parts, taking the encoding from its
+// byte order mark, if any. Without one, each part is decoded as UTF-8 if it
+// is valid UTF-8, and as Windows-1252 otherwise.
+func newPartReader(r io.Reader, size int) *partReader {
+	p := &partReader{r: bufio.NewReaderSize(r, 64*1024), size: size}
+	bom, _ := p.r.Peek(3)
+	switch {
+	case bytes.HasPrefix(bom, []byte{0xEF, 0xBB, 0xBF}):
+		p.encoding = EncodingUTF8
+		p.r.Discard(3)
+	case bytes.HasPrefix(bom, []byte{0xFF, 0xFE}):
+		p.encoding = EncodingUTF16LE
+		p.r.Discard(2)
+	case bytes.HasPrefix(bom, []byte{0xFE, 0xFF}):
+		p.encoding = EncodingUTF16BE
+		p.r.Discard(2)
+	}
+	return p
+}
+
+// next returns the next part as normalized text, or io.EOF after the last.
+// The part's bytes are read into a buffer that is reused, so only the
+// decoded text of one part is held at a time.
+func (p *partReader) next() (string, error) {
+	if p.buf == nil {
+		p.buf = make([]byte, 0, 2*p.size+utf8.UTFMax)
+	}
+	p.buf = append(p.buf[:0], p.carry...)
+	n, err := io.ReadFull(p.r, p.buf[len(p.buf):p.size])
+	p.buf = p.buf[:len(p.buf)+n]
+	switch {
+	case err == nil:
+		p.finishLine()
+	case err == io.EOF && len(p.buf) == 0:
+		return "", io.EOF
+	case err != io.EOF && err != io.ErrUnexpectedEOF:
+		return "", fmt.Errorf("failed to read file: %w", err)
+	default:
+		p.carry = p.carry[:0]
+	}
+	return p.decode(), nil
+}
+
+// utf16 reports whether the file is UTF-16
+func (p *partReader) utf16() bool {
+	return p.encoding == EncodingUTF16LE || p.encoding == EncodingUTF16BE
+}
+
+// unit returns the UTF-16 code unit at i
+func (p *partReader) unit(i int) uint16 {
+	if p.encoding == EncodingUTF16BE {
+		return uint16(p.buf[i])<<8 | uint16(p.buf[i+1])
+	}
+	return uint16(p.buf[i+1])<<8 | uint16(p.buf[i])
+}
+
+// finishLine reads on to the end of the current line
+func (p *partReader) finishLine() {
+	if p.utf16() && len(p.buf)%2 == 1 {
+		if c, err := p.r.ReadByte(); err == nil {
+			p.buf = append(p.buf, c)
+		}
+	}
+	for len(p.buf) < 2*p.size {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			break
+		}
+		p.buf = append(p.buf, c)
+		if p.utf16() {
+			if len(p.buf)%2 == 0 && p.unit(len(p.buf)-2) == '\n' {
+				break
+			}
+		} else if c == '\n' {
+			break
+		}
+	}
+
+	// Carry a character cut in two over to the next part
+	cut := len(p.buf)
+	if p.utf16() {
+		if cut >= 2 && cut%2 == 0 {
+			if u := p.unit(cut - 2); u >= 0xD800 && u < 0xDC00 {
+				cut -= 2
+			}
+		}
+	} else {
+		for k := 1; k < utf8.UTFMax && k <= cut; k++ {
+			c := p.buf[cut-k]
+			if c < 0x80 {
+				break
+			}
+			if c >= 0xC0 {
+				if !utf8.FullRune(p.buf[cut-k:]) {
+					cut -= k
+				}
+				break
+			}
+		}
+	}
+	p.carry = append(p.carry[:0], p.buf[cut:]...)
+	p.buf = p.buf[:cut]
+}
+
+// decode turns the part's bytes into normalized text
+func (p *partReader) decode() string {
+	switch p.encoding {
+	case EncodingUTF16LE:
+		return normalizeText(decodeUTF16(p.buf, false))
+	case EncodingUTF16BE:
+		return normalizeText(decodeUTF16(p.buf, true))
+	}
+	text, _ := normalizeContent(p.buf)
+	return text
+}
+
+// streamed reports whether a file is uploaded in parts: plain text over
+// the stream threshold
+func (cp *ContentProcessor) streamed(filePath string) bool {
+	handler, ok := cp.handlers.Lookup(filePath)
+	if !ok || !handler.stream {
+		return false
+	}
+	_, err := cp.checkFileSize(filePath)
+	return errors.Is(err, errLargeFile)
+}
+
+// partMetadata gives the parts of a streamed file their metadata. The first
+// part built is enriched, and its metadata used for every part.
+type partMetadata struct {
+	cp         *ContentProcessor
+	title      string
+	producerID string
+	first      *ContentData
+}
+
+// part returns part n, counting from 1, with content
+func (m *partMetadata) part(content string, n int) *ContentData {
+	var part *ContentData
+	if m.first == nil {
+		part = m.cp.CreateContentData(content, m.title)
+		m.cp.enrich(part)
+		copied := *part
+		copied.Content = ""
+		m.first = &copied
+	} else {
+		copied := *m.first
+		part = &copied
+		part.Content = content
+	}
+	part.ItemTitle = fmt.Sprintf("%s (part %d)", m.title, n)
+	part.ProducerID = chunkProducerID(m.producerID, n-1)
+	return part
+}
+
+// uploadPart uploads a part of a streamed file and records it
+func (cp *ContentProcessor) uploadPart(filePath string, part *ContentData, n int) (*ApiResponse, error) {
+	result, err := cp.UploadContent(part)
+	if err != nil {
+		return nil, fmt.Errorf("upload of part %d failed: %w", n, err)
+	}
+	if cp.catalog != nil {
+		if err := cp.catalog.RecordUpload(filePath, part, result); err != nil {
+			fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", part.ItemTitle, err)
+		}
+	}
+	fmt.Printf("   📤 Uploaded part %d of %s (%d bytes)\n", n, filePath, len(part.Content))
+	return result, nil
+}
+
+// processLargeFile uploads a file over the stream threshold in parts, each
+// an item under a chunk producer ID, as update --chunked would give it.
+// Only one part is held in memory at a time. The first part is enriched,
+// and its metadata used for every part. It returns the last part's
+// response.
+func (cp *ContentProcessor) processLargeFile(filePath string) (*ApiResponse, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	title := cp.ExtractTitleFromFilename(filepath.Base(filePath))
+	metadata := &partMetadata{cp: cp, title: title, producerID: producerIDFor(filePath)}
+	parts := newPartReader(f, cp.limits.PartBytes)
+	var result *ApiResponse
+	n := 0
+	for {
+		content, err := parts.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		n++
+		if result, err = cp.uploadPart(filePath, metadata.part(content, n), n); err != nil {
+			return nil, err
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("file is empty: %s", filePath)
+	}
+	fmt.Printf("✅ Successfully uploaded in parts: %s\n", title)
+	return result, nil
+}
+
+// updateParts brings a file streamed in parts up to date. The file is read
+// again a part at a time, as processLargeFile read it, and only the parts
+// whose content changed are uploaded, so it's never held in memory whole.
+// Parts are cut by size rather than by paragraph, so an edit that changes
+// the file's length uploads the parts after it again.
+func (app *Application) updateParts(file, producerID string, force, removedDeleted bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	previous := app.catalog.Chunks(producerID)
+	title := app.processor.ExtractTitleFromFilename(filepath.Base(file))
+	metadata := &partMetadata{cp: app.processor, title: title, producerID: producerID}
+	parts := newPartReader(f, app.processor.limits.PartBytes)
+	n, uploaded := 0, 0
+	for {
+		content, err := parts.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		n++
+		if item, ok := previous[n-1]; ok && !force && item.ContentSHA256 == contentHash(content) {
+			continue
+		}
+		if _, err := app.processor.uploadPart(file, metadata.part(content, n), n); err != nil {
+			return err
+		}
+		uploaded++
+	}
+	if n == 0 {
+		return fmt.Errorf("file is empty: %s", file)
+	}
+
+	fmt.Printf("🧩 %s has %d parts: %d uploaded, %d unchanged (producer_id %s)\n",
+		file, n, uploaded, n-uploaded, producerID)
+	var removed []int
+	for slot := range previous {
+		if slot >= n {
+			removed = append(removed, slot)
+		}
+	}
+	sort.Ints(removed)
+	if uploaded > 0 {
+		fmt.Println("   Run 'go run . verify' once they have been processed to check them")
+	}
+	return app.removedChunks(producerID, removed, removedDeleted)
+}
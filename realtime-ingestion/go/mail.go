@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultMailInterval is how often the mail connector checks the mailbox
+const defaultMailInterval = 5 * time.Minute
+
+// mailboxState is how far the mail connector has read a mailbox
+type mailboxState struct {
+	UIDValidity uint32 `json:"uid_validity"`
+	LastUID     uint32 `json:"last_uid"`
+}
+
+// emailMessage is an email parsed for upload
+type emailMessage struct {
+	MessageID   string
+	Subject     string
+	Author      string
+	Date        time.Time
+	Body        string
+	Attachments []emailAttachment
+}
+
+// emailAttachment is an attached file whose type a handler can read
+type emailAttachment struct {
+	Name string
+	Data []byte
+}
+
+// Matches for turning HTML mail into text
+var (
+	htmlHiddenPattern = regexp.MustCompile(`(?is)<(head|style|script)\b.*?</(head|style|script)>`)
+	htmlBreakPattern  = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])\b[^>]*>`)
+	htmlTagPattern    = regexp.MustCompile(`<[^>]*>`)
+	blankLinesPattern = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+)
+
+// htmlToText reduces an HTML body to its text, a line per block
+func htmlToText(s string) string {
+	s = htmlHiddenPattern.ReplaceAllString(s, "")
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(s, "\n\n"))
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping the raw value if
+// they're in a charset Go doesn't know
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// parseEmail reads a raw message's headers, text body, and the attachments
+// of types handlers can read. The body is the first text/plain part, or
+// the first text/html part as text if there's none.
+func parseEmail(raw []byte, handlers *HandlerRegistry) (*emailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	email := &emailMessage{
+		MessageID: strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>"),
+		Subject:   strings.TrimSpace(decodeHeader(msg.Header.Get("Subject"))),
+	}
+	if from, err := (&mail.AddressParser{WordDecoder: new(mime.WordDecoder)}).Parse(msg.Header.Get("From")); err == nil {
+		email.Author = from.Name
+		if email.Author == "" {
+			email.Author = from.Address
+		}
+	}
+	if email.Date, err = msg.Header.Date(); err != nil {
+		email.Date = time.Now()
+	}
+
+	var plain, htmlBody string
+	var walk func(header textproto.MIMEHeader, body io.Reader) error
+	walk = func(header textproto.MIMEHeader, body io.Reader) error {
+		mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil {
+			mediaType, params = "text/plain", nil
+		}
+		if strings.HasPrefix(mediaType, "multipart/") {
+			parts := multipart.NewReader(body, params["boundary"])
+			for {
+				part, err := parts.NextRawPart()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("invalid %s part: %w", mediaType, err)
+				}
+				if err := walk(part.Header, part); err != nil {
+					return err
+				}
+			}
+		}
+
+		switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+		case "base64":
+			body = base64.NewDecoder(base64.StdEncoding, body)
+		case "quoted-printable":
+			body = quotedprintable.NewReader(body)
+		}
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s part: %w", mediaType, err)
+		}
+
+		disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+		name := dispositionParams["filename"]
+		if name == "" {
+			name = params["name"]
+		}
+		name = filepath.Base(decodeHeader(name))
+		if name != "." && name != string(filepath.Separator) && (disposition == "attachment" || !strings.HasPrefix(mediaType, "text/")) {
+			if _, ok := handlers.Lookup(name); ok {
+				email.Attachments = append(email.Attachments, emailAttachment{Name: name, Data: data})
+			}
+			return nil
+		}
+		// Text parts are converted from their charset the way text files are
+		text, _ := normalizeContent(data)
+		switch {
+		case mediaType == "text/plain" && plain == "":
+			plain = text
+		case mediaType == "text/html" && htmlBody == "":
+			htmlBody = text
+		}
+		return nil
+	}
+	if err := walk(textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return nil, err
+	}
+
+	email.Body = strings.TrimSpace(plain)
+	if email.Body == "" {
+		email.Body = htmlToText(htmlBody)
+	}
+	return email, nil
+}
+
+// emailContentData builds the upload payload for an email or one of its
+// attachments: the subject is the title, the sender the author, and the
+// date the publication date
+func (cp *ContentProcessor) emailContentData(email *emailMessage, content, title, producerID string) *ContentData {
+	data := cp.CreateContentData(content, title)
+	if email.Author != "" {
+		data.Author = []string{email.Author}
+	}
+	data.PublicationDate = email.Date.Format("2006-01-02")
+	data.ProducerID = producerID
+	cp.enrich(data)
+	return data
+}
+
+// ProcessEmail uploads an email's body and each attachment a handler can
+// read as items. Items are identified by the Message-ID, or by
+// fallbackID for messages without one, so uploading an email again
+// replaces them.
+func (cp *ContentProcessor) ProcessEmail(email *emailMessage, source, fallbackID string) error {
+	producerID := "email/" + email.MessageID
+	if email.MessageID == "" {
+		producerID = fallbackID
+	}
+	subject := email.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+
+	var items []*ContentData
+	if email.Body != "" {
+		items = append(items, cp.emailContentData(email, email.Body, subject, producerID))
+	}
+	if len(email.Attachments) > 0 {
+		dir, err := ioutil.TempDir("", "gloo-mail-")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		for _, attachment := range email.Attachments {
+			// Handlers read files, so the attachment is saved under its name
+			path := filepath.Join(dir, attachment.Name)
+			if err := ioutil.WriteFile(path, attachment.Data, 0600); err != nil {
+				return fmt.Errorf("failed to save attachment %s: %w", attachment.Name, err)
+			}
+			handler, _ := cp.handlers.Lookup(attachment.Name)
+			text, err := handler.text(path)
+			if err != nil {
+				fmt.Printf("⚠️  Skipped attachment %s of %q: %v\n", attachment.Name, subject, err)
+				continue
+			}
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			title := fmt.Sprintf("%s (%s)", subject, attachment.Name)
+			items = append(items, cp.emailContentData(email, text, title, producerID+"/"+attachment.Name))
+		}
+	}
+	if len(items) == 0 {
+		fmt.Printf("⏭️  Skipped %q: no text body or readable attachments\n", subject)
+		return nil
+	}
+
+	for _, item := range items {
+		result, err := cp.UploadContent(item)
+		if err != nil {
+			return fmt.Errorf("upload of %s failed: %w", item.ItemTitle, err)
+		}
+		fmt.Printf("✅ Successfully uploaded: %s\n", item.ItemTitle)
+		if cp.catalog != nil {
+			if err := cp.catalog.RecordUpload(source, item, result); err != nil {
+				fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", item.ItemTitle, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadMailState reads how far each mailbox has been read
+func loadMailState(path string) (map[string]mailboxState, error) {
+	state := make(map[string]mailboxState)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mail state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse mail state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Mail ingests the messages in a mailbox, then checks it for new ones
+// every interval until stopped. args is optionally the mailbox URL, such
+// as imaps://me@example.com@imap.gmail.com/Newsletters, which defaults to
+// IMAP_URL, then --once and --interval=DURATION.
+func (app *Application) Mail(args []string) error {
+	raw := getEnv("IMAP_URL", "")
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		raw, args = args[0], args[1:]
+	}
+	u, err := url.Parse(raw)
+	if raw == "" || err != nil || u.Hostname() == "" || u.User == nil {
+		return fmt.Errorf("%w: mail needs a mailbox URL, such as imaps://user@imap.example.com/INBOX, as an argument or in IMAP_URL", errConfig)
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		password = getEnv("IMAP_PASSWORD", "")
+	}
+	mailbox := strings.TrimPrefix(u.Path, "/")
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+
+	once, interval := false, defaultMailInterval
+	for _, arg := range args {
+		switch {
+		case arg == "--once":
+			once = true
+		case strings.HasPrefix(arg, "--interval="):
+			interval, err = time.ParseDuration(strings.TrimPrefix(arg, "--interval="))
+			if err != nil || interval <= 0 {
+				return fmt.Errorf("%w: --interval must be a positive duration, such as 5m", errConfig)
+			}
+		default:
+			return fmt.Errorf("%w: unknown mail option %q", errConfig, arg)
+		}
+	}
+
+	statePath := getEnv("GLOO_MAIL_STATE", "mail_state.json")
+	state, err := loadMailState(statePath)
+	if err != nil {
+		return err
+	}
+	key := u.User.Username() + "@" + u.Host + "/" + mailbox
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("📬 Reading mailbox %s as %s on %s\n", mailbox, u.User.Username(), u.Host)
+	if !once {
+		fmt.Printf("   Checking every %s; press Ctrl+C to stop\n", interval)
+	}
+	for {
+		ingested, err := app.mailOnce(u, password, mailbox, key, state, statePath)
+		switch {
+		case err != nil && (once || errors.Is(err, errConfig) || errors.Is(err, errAuth)):
+			return err
+		case err != nil:
+			fmt.Printf("⚠️  Mail check failed: %v; trying again in %s\n", err, interval)
+		case ingested > 0:
+			fmt.Printf("📬 Ingested %d messages\n", ingested)
+		}
+		if once {
+			return nil
+		}
+		sleepContext(ctx, interval)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// mailOnce ingests the messages after the last one read. Progress is
+// saved after each message, and a message whose upload fails ends the
+// check, so it's tried again next time. Messages that can't be parsed
+// are skipped.
+func (app *Application) mailOnce(u *url.URL, password, mailbox, key string, state map[string]mailboxState, statePath string) (int, error) {
+	client, err := dialIMAP(u)
+	if err != nil {
+		return 0, err
+	}
+	defer client.logout()
+	if err := client.login(u.User.Username(), password); err != nil {
+		return 0, err
+	}
+	validity, err := client.examine(mailbox)
+	if err != nil {
+		return 0, err
+	}
+
+	current := state[key]
+	if current.UIDValidity != validity {
+		if current.UIDValidity != 0 {
+			fmt.Printf("⚠️  The server renumbered mailbox %s; reading it again from the start, replacing the items uploaded before\n", mailbox)
+		}
+		current = mailboxState{UIDValidity: validity}
+	}
+	uids, err := client.searchAfter(current.LastUID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search mailbox %s: %w", mailbox, err)
+	}
+
+	ingested := 0
+	for _, uid := range uids {
+		raw, err := client.fetch(uid)
+		if err != nil {
+			return ingested, fmt.Errorf("failed to fetch message %d: %w", uid, err)
+		}
+		source := fmt.Sprintf("imap %s UID %d", key, uid)
+		if raw != nil {
+			email, err := parseEmail(raw, app.processor.handlers)
+			if err != nil {
+				fmt.Printf("⏭️  Skipped message %d: %v\n", uid, err)
+			} else {
+				fallbackID := fmt.Sprintf("email/%s/%d/%d", key, validity, uid)
+				if err := app.processor.ProcessEmail(email, source, fallbackID); err != nil {
+					return ingested, err
+				}
+				ingested++
+			}
+		}
+
+		current.LastUID = uid
+		state[key] = current
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return ingested, fmt.Errorf("failed to marshal mail state: %w", err)
+		}
+		if err := ioutil.WriteFile(statePath, data, 0644); err != nil {
+			return ingested, fmt.Errorf("failed to save mail state: %w", err)
+		}
+	}
+	return ingested, nil
+}
@@ -2,13 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -23,9 +30,10 @@ const (
 )
 
 var (
-	clientID     string
-	clientSecret string
-	tokenInfo    *TokenInfo
+	clientID              string
+	clientSecret          string
+	secondaryClientSecret string
+	credentialProvider    CredentialProvider
 )
 
 // TokenInfo represents OAuth2 token information
@@ -48,6 +56,12 @@ type ContentData struct {
 	ItemTags        []string `json:"item_tags"`
 	Evergreen       bool     `json:"evergreen"`
 	DRM             []string `json:"drm"`
+	// ItemSummary is a short summary for search results, if one was
+	// generated
+	ItemSummary string `json:"item_summary,omitempty"`
+	// ProducerID is the item's ID in the publisher's own system. Uploading
+	// again under the same ID replaces the item rather than adding another.
+	ProducerID string `json:"producer_id,omitempty"`
 }
 
 // ApiResponse represents the API response structure
@@ -59,43 +73,159 @@ type ApiResponse struct {
 	ProcessingDetails interface{} `json:"processing_details"`
 }
 
-// TokenManager handles OAuth2 token lifecycle
+// TokenManager handles OAuth2 token lifecycle. During a secret rotation it
+// holds both the primary and the secondary client secret, and switches to
+// whichever one the token endpoint accepts. With a credential provider, it
+// fetches the credentials again once both are rejected. It is safe for
+// concurrent use, so one manager can serve a pool of uploaders.
 type TokenManager struct {
-	clientID     string
-	clientSecret string
-	httpClient   *http.Client
+	// mu guards the cached token and the credentials, which a refresh may
+	// switch or reload
+	mu         sync.Mutex
+	token      *TokenInfo
+	clientID   string
+	secrets    []string // primary first; secrets[active] is tried first
+	active     int
+	provider   CredentialProvider
+	scope      string // space-separated scopes to request
+	audience   string // sent only if set
+	httpClient *http.Client
 }
 
-// NewTokenManager creates a new token manager instance
-func NewTokenManager(clientID, clientSecret string) *TokenManager {
-	return &TokenManager{
-		clientID:     clientID,
-		clientSecret: clientSecret,
+// NewTokenManager creates a new token manager instance. secondarySecret is
+// optional. The scope is GLOO_INGESTION_SCOPE, for ingestion-only
+// credentials, else GLOO_SCOPE, else api/access; the audience is
+// GLOO_AUDIENCE.
+func NewTokenManager(clientID, clientSecret, secondarySecret string) *TokenManager {
+	tm := &TokenManager{
+		scope:    getEnv("GLOO_INGESTION_SCOPE", getEnv("GLOO_SCOPE", "api/access")),
+		audience: getEnv("GLOO_AUDIENCE", ""),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
+	tm.setCredentials(clientID, clientSecret, secondarySecret)
+	return tm
+}
+
+// setCredentials replaces the client ID and secrets
+func (tm *TokenManager) setCredentials(clientID, clientSecret, secondarySecret string) {
+	tm.clientID = clientID
+	tm.secrets = []string{clientSecret}
+	if secondarySecret != "" {
+		tm.secrets = append(tm.secrets, secondarySecret)
+	}
+	tm.active = 0
 }
 
-// GetAccessToken retrieves a new access token from the OAuth2 endpoint
+// secretNames are the environment variables the secrets come from, for
+// warnings
+var secretNames = []string{"GLOO_CLIENT_SECRET", "GLOO_CLIENT_SECRET_SECONDARY"}
+
+// GetAccessToken retrieves a new access token from the OAuth2 endpoint. If
+// every configured secret is rejected and the credentials came from a
+// provider, they are fetched again in case they were rotated there.
 func (tm *TokenManager) GetAccessToken() (*TokenInfo, error) {
-	data := strings.NewReader("grant_type=client_credentials&scope=api/access")
-	req, err := http.NewRequest("POST", tokenURL, data)
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.getAccessToken()
+}
+
+// Token returns the cached access token, fetching a new one first if it
+// is missing or about to expire. Concurrent callers share one refresh.
+func (tm *TokenManager) Token() (*TokenInfo, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if !tm.IsTokenExpired(tm.token) {
+		return tm.token, nil
+	}
+	fmt.Println("Token is expired or missing. Fetching a new one...")
+	token, err := tm.getAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	tm.token = token
+	return token, nil
+}
+
+// getAccessToken is GetAccessToken. The caller holds tm.mu.
+func (tm *TokenManager) getAccessToken() (*TokenInfo, error) {
+	token, err := tm.tryCredentials()
+	if err == nil || tm.provider == nil || !errors.Is(err, errCredentialsRejected) {
+		return token, err
+	}
+
+	creds, reloadErr := tm.provider.Credentials()
+	if reloadErr != nil {
+		return nil, fmt.Errorf("%w; reloading credentials from %s: %v", err, tm.provider.Name(), reloadErr)
+	}
+	if creds.ClientID == tm.clientID && creds.ClientSecret == tm.secrets[0] &&
+		creds.SecondarySecret == strings.Join(tm.secrets[1:], "") {
+		return nil, fmt.Errorf("%w (the credentials in %s haven't changed)", err, tm.provider.Name())
+	}
+	fmt.Printf("🔄 Credentials were rejected; reloaded them from %s\n", tm.provider.Name())
+	tm.setCredentials(creds.ClientID, creds.ClientSecret, creds.SecondarySecret)
+	return tm.tryCredentials()
+}
+
+// tryCredentials requests a token with the current secret. If the endpoint
+// rejects it and a secondary secret is configured, it retries with the
+// other secret, warns that a rotation is under way, and keeps using the
+// secret that worked.
+func (tm *TokenManager) tryCredentials() (*TokenInfo, error) {
+	token, err := tm.requestToken(tm.secrets[tm.active])
+	if err == nil || len(tm.secrets) < 2 || !errors.Is(err, errCredentialsRejected) {
+		return token, err
+	}
+
+	other := 1 - tm.active
+	token, otherErr := tm.requestToken(tm.secrets[other])
+	if otherErr != nil {
+		return nil, fmt.Errorf("%w; with %s: %v", err, secretNames[other], otherErr)
+	}
+	fmt.Printf("⚠️  %s was rejected; authenticated with %s instead.\n", secretNames[tm.active], secretNames[other])
+	if other == 1 {
+		fmt.Println("   Finish the rotation by moving the new secret to GLOO_CLIENT_SECRET and unsetting GLOO_CLIENT_SECRET_SECONDARY.")
+	}
+	tm.active = other
+	return token, nil
+}
+
+// errCredentialsRejected marks token request failures caused by the client
+// credentials, as opposed to network or server errors
+var errCredentialsRejected = errors.New("client credentials rejected")
+
+// requestToken requests a token with one client secret
+func (tm *TokenManager) requestToken(secret string) (*TokenInfo, error) {
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {tm.scope}}
+	if tm.audience != "" {
+		form.Set("audience", tm.audience)
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.SetBasicAuth(tm.clientID, tm.clientSecret)
+	req.SetBasicAuth(tm.clientID, secret)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := tm.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, fmt.Errorf("%w: failed to execute request: %w", errUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		// RFC 6749 reports a bad client secret as invalid_client, with 401
+		// or, from some servers, 400
+		if resp.StatusCode == http.StatusUnauthorized || strings.Contains(string(bodyBytes), "invalid_client") {
+			return nil, fmt.Errorf("failed to get token: %w: %s - %s", errCredentialsRejected, resp.Status, string(bodyBytes))
+		}
+		if unavailableStatus(resp.StatusCode) {
+			return nil, fmt.Errorf("failed to get token: %w: %s - %s", errUnavailable, resp.Status, string(bodyBytes))
+		}
 		return nil, fmt.Errorf("failed to get token: %s - %s", resp.Status, string(bodyBytes))
 	}
 
@@ -123,9 +253,12 @@ func (tm *TokenManager) IsTokenExpired(token *TokenInfo) bool {
 
 // ContentProcessor handles content processing and uploads
 type ContentProcessor struct {
-	tokenManager  *TokenManager
-	httpClient    *http.Client
-	supportedExts map[string]bool
+	tokenManager *TokenManager
+	httpClient   *http.Client
+	handlers     *HandlerRegistry // read each supported file type
+	catalog      *Catalog         // records uploads for status callbacks; optional
+	limits       FileLimits       // bound file sizes, and stream large files in parts
+	enrichers    []Enricher       // add metadata before each upload; optional
 }
 
 // NewContentProcessor creates a new content processor instance
@@ -135,17 +268,14 @@ func NewContentProcessor(tokenManager *TokenManager) *ContentProcessor {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		supportedExts: map[string]bool{
-			".txt": true,
-			".md":  true,
-		},
+		handlers: NewHandlerRegistry(),
 	}
 }
 
-// IsSupportedFile checks if the file extension is supported
+// IsSupportedFile checks if the file extension has a handler
 func (cp *ContentProcessor) IsSupportedFile(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	return cp.supportedExts[ext]
+	_, ok := cp.handlers.Lookup(filePath)
+	return ok
 }
 
 // ExtractTitleFromFilename extracts and formats title from filename
@@ -184,13 +314,12 @@ func (cp *ContentProcessor) CreateContentData(content, title string) *ContentDat
 // UploadContent uploads content to the Realtime API
 func (cp *ContentProcessor) UploadContent(contentData *ContentData) (*ApiResponse, error) {
 	// Check and refresh token if needed
-	if cp.tokenManager.IsTokenExpired(tokenInfo) {
-		fmt.Println("Token is expired or missing. Fetching a new one...")
-		var err error
-		tokenInfo, err = cp.tokenManager.GetAccessToken()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get access token: %w", err)
-		}
+	token, err := cp.tokenManager.Token()
+	if errors.Is(err, errCredentialsRejected) {
+		return nil, fmt.Errorf("%w: failed to get access token: %w", errAuth, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
 	}
 
 	jsonPayload, err := json.Marshal(contentData)
@@ -203,12 +332,12 @@ func (cp *ContentProcessor) UploadContent(contentData *ContentData) (*ApiRespons
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Add("Authorization", "Bearer "+tokenInfo.AccessToken)
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
 	req.Header.Add("Content-Type", "application/json")
 
 	resp, err := cp.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, fmt.Errorf("%w: failed to execute request: %w", errUnavailable, err)
 	}
 	defer resp.Body.Close()
 
@@ -217,6 +346,12 @@ func (cp *ContentProcessor) UploadContent(contentData *ContentData) (*ApiRespons
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("%w: API call failed: %s - %s", errAuth, resp.Status, string(body))
+	}
+	if unavailableStatus(resp.StatusCode) {
+		return nil, fmt.Errorf("%w: API call failed: %s - %s", errUnavailable, resp.Status, string(body))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API call failed: %s - %s", resp.Status, string(body))
 	}
@@ -231,55 +366,112 @@ func (cp *ContentProcessor) UploadContent(contentData *ContentData) (*ApiRespons
 
 // ProcessFile processes a single file and uploads its content
 func (cp *ContentProcessor) ProcessFile(filePath string) error {
-	// Validate file
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("file does not exist: %s", filePath)
-	}
+	_, err := cp.processFile(filePath)
+	return err
+}
 
-	if !cp.IsSupportedFile(filePath) {
-		return fmt.Errorf("unsupported file type: %s", filePath)
+// processFile is ProcessFile, returning the API's response
+func (cp *ContentProcessor) processFile(filePath string) (*ApiResponse, error) {
+	contentData, err := cp.prepareFile(filePath)
+	if errors.Is(err, errLargeFile) {
+		return cp.processLargeFile(filePath)
 	}
-
-	// Read file content
-	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	if len(strings.TrimSpace(string(content))) == 0 {
-		return fmt.Errorf("file is empty: %s", filePath)
+		return nil, err
 	}
+	return cp.uploadFile(filePath, contentData)
+}
 
-	// Extract metadata
-	filename := filepath.Base(filePath)
-	title := cp.ExtractTitleFromFilename(filename)
-	contentData := cp.CreateContentData(string(content), title)
+// uploadFile uploads a file's prepared payload and records it in the
+// catalog
+func (cp *ContentProcessor) uploadFile(filePath string, contentData *ContentData) (*ApiResponse, error) {
+	title := contentData.ItemTitle
 
 	// Upload content
 	result, err := cp.UploadContent(contentData)
 	if err != nil {
-		return fmt.Errorf("upload failed: %w", err)
+		return nil, fmt.Errorf("upload failed: %w", err)
 	}
 
 	fmt.Printf("✅ Successfully uploaded: %s\n", title)
 	fmt.Printf("   Response: %s\n", result.Message)
-	return nil
+
+	// The upload succeeded even if it can't be recorded, so only warn
+	if cp.catalog != nil {
+		if err := cp.catalog.RecordUpload(filePath, contentData, result); err != nil {
+			fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", title, err)
+		}
+	}
+	return result, nil
+}
+
+// prepareFile reads a file into an enriched upload payload
+func (cp *ContentProcessor) prepareFile(filePath string) (*ContentData, error) {
+	// Validate file
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("file does not exist: %s", filePath)
+	}
+
+	handler, ok := cp.handlers.Lookup(filePath)
+	if !ok {
+		return nil, fmt.Errorf("unsupported file type: %s", filePath)
+	}
+
+	// Refuse oversized files before reading them in. Only plain text can be
+	// streamed in parts; other handlers are given the whole file.
+	if _, err := cp.checkFileSize(filePath); err != nil && (handler.stream || !errors.Is(err, errLargeFile)) {
+		return nil, err
+	}
+
+	// Read the text, converted to UTF-8 with \n line endings
+	content, err := handler.text(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("file is empty: %s", filePath)
+	}
+
+	// Extract metadata
+	filename := filepath.Base(filePath)
+	title := cp.ExtractTitleFromFilename(filename)
+	contentData := cp.CreateContentData(content, title)
+	contentData.ProducerID = producerIDFor(filePath)
+	cp.enrich(contentData)
+	return contentData, nil
 }
 
 // DirectoryWatcher handles file system monitoring
 type DirectoryWatcher struct {
 	processor *ContentProcessor
+	// handle is called with each new file; it uploads the file unless
+	// replaced, such as to enqueue it for a worker fleet
+	handle func(filePath string) error
+	// spool holds uploads while the API is unreachable; optional
+	spool *Spool
+	// forcePoll lists the directory every pollInterval instead of watching
+	// for file events, which polling also falls back to if the events run
+	// out
+	forcePoll    bool
+	pollInterval time.Duration
 }
 
 // NewDirectoryWatcher creates a new directory watcher instance
 func NewDirectoryWatcher(processor *ContentProcessor) *DirectoryWatcher {
 	return &DirectoryWatcher{
-		processor: processor,
+		processor:    processor,
+		handle:       processor.ProcessFile,
+		pollInterval: defaultPollInterval,
 	}
 }
 
 // Watch starts monitoring a directory for new files
 func (dw *DirectoryWatcher) Watch(directory string) error {
+	return dw.WatchContext(context.Background(), directory)
+}
+
+// WatchContext is Watch, returning when ctx is done
+func (dw *DirectoryWatcher) WatchContext(ctx context.Context, directory string) error {
 	// Create directory if it doesn't exist
 	if _, err := os.Stat(directory); os.IsNotExist(err) {
 		if err := os.MkdirAll(directory, 0755); err != nil {
@@ -288,25 +480,44 @@ func (dw *DirectoryWatcher) Watch(directory string) error {
 		fmt.Printf("Created watch directory: %s\n", directory)
 	}
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("failed to create watcher: %w", err)
-	}
-	defer watcher.Close()
-
 	fmt.Printf("🔍 Monitoring directory: %s\n", directory)
-	fmt.Println("   Supported file types: .txt, .md")
+	fmt.Printf("   Supported file types: %s\n", strings.Join(dw.processor.handlers.Extensions(), ", "))
+	if dw.spool != nil {
+		fmt.Printf("   Spooling uploads to %s during outages (%d waiting)\n", dw.spool.dir, dw.spool.Len())
+	}
 	fmt.Println("   Press Ctrl+C to stop")
 
-	// Add directory to watcher
-	err = watcher.Add(directory)
+	if dw.forcePoll {
+		fmt.Printf("   Polling every %s (GLOO_WATCH_MODE=poll)\n", dw.pollInterval)
+		return dw.poll(ctx, directory)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		defer watcher.Close()
+		// Add directory to watcher
+		if err = watcher.Add(longPath(directory)); err != nil {
+			err = fmt.Errorf("failed to add directory to watcher: %w", err)
+		}
+	} else {
+		err = fmt.Errorf("failed to create watcher: %w", err)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to add directory to watcher: %w", err)
+		warning := watchLimitWarning(err)
+		if warning == "" {
+			return err
+		}
+		fmt.Printf("⚠️  Can't watch for file events: %s\n", warning)
+		fmt.Printf("   Polling every %s instead (GLOO_POLL_INTERVAL); new files are noticed more slowly\n", dw.pollInterval)
+		return dw.poll(ctx, directory)
 	}
 
 	// Handle events
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
+
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return fmt.Errorf("watcher events channel closed")
@@ -318,7 +529,7 @@ func (dw *DirectoryWatcher) Watch(directory string) error {
 					// Small delay to ensure file write is complete
 					time.Sleep(1 * time.Second)
 
-					if err := dw.processor.ProcessFile(event.Name); err != nil {
+					if err := dw.handleFile(event.Name); err != nil {
 						fmt.Printf("❌ Failed to process %s: %v\n", event.Name, err)
 					}
 				}
@@ -336,6 +547,9 @@ func (dw *DirectoryWatcher) Watch(directory string) error {
 // BatchProcessor handles batch processing of directories
 type BatchProcessor struct {
 	processor *ContentProcessor
+	// jobs, if set, tracks each file as a job, so a rerun skips the files
+	// already uploaded and resumes the rest
+	jobs *JobStore
 }
 
 // NewBatchProcessor creates a new batch processor instance
@@ -345,26 +559,18 @@ func NewBatchProcessor(processor *ContentProcessor) *BatchProcessor {
 	}
 }
 
-// ProcessDirectory processes all supported files in a directory
-func (bp *BatchProcessor) ProcessDirectory(dirPath string) error {
+// ProcessDirectory processes all supported files in a directory. It
+// returns an error wrapping errFilesFailed if any file failed, and stops
+// early when opts says so.
+func (bp *BatchProcessor) ProcessDirectory(dirPath string, opts BatchOptions) error {
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		return fmt.Errorf("directory does not exist: %s", dirPath)
+		return fmt.Errorf("%w: directory does not exist: %s", errConfig, dirPath)
 	}
 
-	// Find all supported files
-	var supportedFiles []string
-
-	txtFiles, err := filepath.Glob(filepath.Join(dirPath, "*.txt"))
+	supportedFiles, err := findSupportedFiles(dirPath, bp.processor.handlers.Extensions())
 	if err != nil {
-		return fmt.Errorf("failed to glob txt files: %w", err)
+		return err
 	}
-	supportedFiles = append(supportedFiles, txtFiles...)
-
-	mdFiles, err := filepath.Glob(filepath.Join(dirPath, "*.md"))
-	if err != nil {
-		return fmt.Errorf("failed to glob md files: %w", err)
-	}
-	supportedFiles = append(supportedFiles, mdFiles...)
 
 	if len(supportedFiles) == 0 {
 		fmt.Printf("No supported files found in: %s\n", dirPath)
@@ -373,34 +579,122 @@ func (bp *BatchProcessor) ProcessDirectory(dirPath string) error {
 
 	fmt.Printf("Found %d files to process\n", len(supportedFiles))
 
+	var interval time.Duration
+	if opts.Uploaders > 0 && bp.jobs != nil {
+		fmt.Println("⚠️  --pipeline is ignored while GLOO_JOBS_DB is set; uploading one file at a time")
+		opts.Uploaders = 0
+	} else if opts.Uploaders > 0 {
+		if interval, err = pipelineInterval(); err != nil {
+			return err
+		}
+	}
+
+	var duplicates map[string]Duplicate
+	if opts.Duplicates != DuplicatesOff {
+		similarity := opts.Similarity
+		if similarity == 0 {
+			similarity = defaultSimilarity
+		}
+		duplicates = reportDuplicates(FindDuplicates(supportedFiles, similarity, bp.processor.limits, bp.processor.handlers), opts.Duplicates)
+	}
+
 	processed := 0
 	failed := 0
-
-	for _, file := range supportedFiles {
-		if err := bp.processor.ProcessFile(file); err != nil {
-			fmt.Printf("❌ Failed to process %s: %v\n", file, err)
-			failed++
-		} else {
-			processed++
+	skipped := 0
+	var lastErr error
+	stopReason := ""
+	progress := NewProgress(opts.Progress, supportedFiles)
+	progress.Start()
+
+	if opts.Uploaders > 0 {
+		var queued []string
+		for _, file := range supportedFiles {
+			if _, ok := duplicates[file]; ok && opts.Duplicates == DuplicatesSkip {
+				skipped++
+				progress.Finish(file, progressSkipped)
+				continue
+			}
+			queued = append(queued, file)
 		}
+		processed, failed, lastErr, stopReason = bp.processPipelined(queued, opts, interval, progress)
+	} else {
+		for i, file := range supportedFiles {
+			progress.Begin()
+			if _, ok := duplicates[file]; ok && opts.Duplicates == DuplicatesSkip {
+				skipped++
+				progress.Finish(file, progressSkipped)
+				continue
+			}
+			var err error
+			if bp.jobs != nil {
+				var ran bool
+				if ran, err = runFileJob(bp.jobs, bp.processor, "batch", file); !ran {
+					skipped++
+					progress.Finish(file, progressSkipped)
+					continue
+				}
+			} else {
+				err = bp.processor.ProcessFile(file)
+			}
 
-		// Rate limiting - avoid overwhelming the API
-		time.Sleep(1 * time.Second)
+			if err != nil {
+				fmt.Printf("❌ Failed to process %s: %v\n", file, err)
+				failed++
+				lastErr = err
+				progress.Finish(file, progressFailed)
+				if stop, reason := opts.shouldStop(failed, err); stop {
+					stopReason = fmt.Sprintf("%s; %d files not attempted", reason, len(supportedFiles)-i-1)
+					break
+				}
+			} else {
+				processed++
+				progress.Finish(file, progressUploaded)
+			}
+
+			// Rate limiting - avoid overwhelming the API
+			time.Sleep(1 * time.Second)
+		}
 	}
+	progress.End(stopReason)
 
 	fmt.Printf("\n📊 Batch processing complete:\n")
 	fmt.Printf("   ✅ Processed: %d files\n", processed)
 	fmt.Printf("   ❌ Failed: %d files\n", failed)
+	if skipped > 0 {
+		fmt.Printf("   ⏭️  Skipped: %d files\n", skipped)
+	}
+	if stopReason != "" {
+		fmt.Printf("   🛑 Stopped early: %s\n", stopReason)
+	}
 
-	return nil
+	return runResult(lastErr, failed, len(supportedFiles))
+}
+
+// findSupportedFiles returns the files in a directory with the given
+// extensions, grouped by extension in the order given
+func findSupportedFiles(dirPath string, extensions []string) ([]string, error) {
+	var supportedFiles []string
+
+	for _, ext := range extensions {
+		files, err := filepath.Glob(filepath.Join(dirPath, "*"+ext))
+		if err != nil {
+			return nil, fmt.Errorf("failed to glob %s files: %w", ext, err)
+		}
+		supportedFiles = append(supportedFiles, files...)
+	}
+
+	return supportedFiles, nil
 }
 
 // Application represents the main application
 type Application struct {
 	tokenManager   *TokenManager
+	catalog        *Catalog
+	jobs           *JobStore
 	processor      *ContentProcessor
 	watcher        *DirectoryWatcher
 	batchProcessor *BatchProcessor
+	spoolInterval  time.Duration
 }
 
 // NewApplication creates a new application instance
@@ -410,30 +704,128 @@ func NewApplication() (*Application, error) {
 		return nil, fmt.Errorf("GLOO_CLIENT_ID and GLOO_CLIENT_SECRET must be set")
 	}
 
-	tokenManager := NewTokenManager(clientID, clientSecret)
+	catalog, err := OpenCatalog(getEnv("GLOO_CATALOG_FILE", "ingestion_catalog.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenManager := NewTokenManager(clientID, clientSecret, secondaryClientSecret)
+	tokenManager.provider = credentialProvider
 	processor := NewContentProcessor(tokenManager)
+	processor.catalog = catalog
+	if path := getEnv("GLOO_HANDLERS_FILE", ""); path != "" {
+		if err := processor.handlers.LoadHandlers(path); err != nil {
+			return nil, fmt.Errorf("%w: %w", errConfig, err)
+		}
+	}
+	if processor.limits, err = loadFileLimits(); err != nil {
+		return nil, err
+	}
+	if processor.enrichers, err = loadEnrichers(processor); err != nil {
+		return nil, err
+	}
 	watcher := NewDirectoryWatcher(processor)
+	if watcher.forcePoll, watcher.pollInterval, err = loadPollSettings(); err != nil {
+		return nil, err
+	}
 	batchProcessor := NewBatchProcessor(processor)
 
+	// The job store is optional
+	var jobs *JobStore
+	if path := getEnv("GLOO_JOBS_DB", ""); path != "" {
+		if jobs, err = OpenJobStore(path); err != nil {
+			return nil, err
+		}
+		if resumed, err := jobs.ResumeStale(); err != nil {
+			return nil, err
+		} else if resumed > 0 {
+			fmt.Printf("🔁 Resuming %d interrupted jobs\n", resumed)
+		}
+		batchProcessor.jobs = jobs
+		watcher.handle = func(filePath string) error {
+			_, err := runFileJob(jobs, processor, "watch", filePath)
+			return err
+		}
+	}
+
+	// Jobs already keep failed uploads for retrying, so the spool is only
+	// used without them
+	spool, spoolInterval, err := openSpool()
+	if err != nil {
+		return nil, err
+	}
+	if spool != nil && jobs != nil {
+		fmt.Println("⚠️  GLOO_SPOOL_DIR is ignored while GLOO_JOBS_DB is set; retry failed watch jobs with: go run . jobs retry failed")
+		spool = nil
+	}
+	watcher.spool = spool
+
 	return &Application{
 		tokenManager:   tokenManager,
+		catalog:        catalog,
+		jobs:           jobs,
 		processor:      processor,
 		watcher:        watcher,
 		batchProcessor: batchProcessor,
+		spoolInterval:  spoolInterval,
 	}, nil
 }
 
-// PrintUsage prints application usage information
-func (app *Application) PrintUsage() {
+// printUsage prints application usage information. It needs no
+// credentials, so --help works before they are set up.
+func printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("  go run main.go watch <directory>     # Monitor directory for new files")
-	fmt.Println("  go run main.go batch <directory>     # Process all files in directory")
-	fmt.Println("  go run main.go single <file_path>    # Process single file")
+	fmt.Println("  go run . watch <directory>          # Monitor directory for new files")
+	fmt.Println("  go run . batch <directory> [options] # Process all files in directory")
+	fmt.Println("  go run . single <file_path>         # Process single file")
+	fmt.Println("  go run . update <file_path> [--producer-id ID] [--force] [--chunked] [--removed-deleted] # Update an uploaded file's item")
+	fmt.Println("  go run . webhook [addr]             # Receive ingestion status callbacks (default :8080)")
+	fmt.Println("  go run . catalog                    # List uploaded items and their status")
+	fmt.Println("  go run . verify [--report file]     # Check processed items against their uploads")
+	fmt.Println("  go run . consume <kafka|nats> <topic> # Upload content messages from a queue")
+	fmt.Println("  go run . mirror <url> [--once] [--interval=15m] # Ingest new and changed files from SFTP or FTP")
+	fmt.Println("  go run . mail [url] [--once] [--interval=5m] # Ingest emails and attachments from an IMAP mailbox")
+	fmt.Println("  go run . podcast <feed> [--once] [--interval=1h] [--limit=N] # Ingest podcast episode transcripts")
+	fmt.Println("  go run . youtube <channel> [--once] [--interval=6h] [--limit=N] [--lang=en] # Ingest video captions")
+	fmt.Println("  go run . confluence <space-key> [--once] [--interval=1h] [--full]  # Sync a Confluence space as Markdown")
+	fmt.Println("  go run . enqueue <path> [--inline]  # Add a file or directory to the Redis work queue")
+	fmt.Println("  go run . watch <dir> --enqueue      # Add new files to the Redis work queue")
+	fmt.Println("  go run . worker                     # Upload jobs from the Redis work queue")
+	fmt.Println("  go run . queue-status               # Show Redis work queue counts")
+	fmt.Println("  go run . jobs [status]              # List jobs, optionally only those with a status")
+	fmt.Println("  go run . jobs show <id>             # Show one job")
+	fmt.Println("  go run . jobs retry <id|failed>     # Retry a failed or cancelled job, or every failed job")
+	fmt.Println("  go run . jobs cancel <id>           # Cancel a pending job")
+	fmt.Println("  go run . jobs run [options]         # Upload every pending job")
+	fmt.Println("  go run . bench ingest [options]     # Measure upload throughput against a mock server")
+	fmt.Println("  gloo-ingestion service install <dir> # Run watch mode as a Windows service")
+	fmt.Println("  gloo-ingestion service uninstall    # Remove the Windows service")
+	fmt.Println("  go run . help                       # Show this help")
+	fmt.Println()
+	fmt.Println("Options for batch and jobs run:")
+	fmt.Println("  --fail-fast                         # Stop at the first failure")
+	fmt.Println("  --max-failures N                    # Stop after N failures")
+	fmt.Println("  --progress=line|json|none           # Progress on stderr (default: line on a terminal)")
+	fmt.Println("  --duplicates=warn|skip|off          # Near-duplicate files in a batch (default: warn)")
+	fmt.Println("  --similarity=0.95                   # How alike files must be to count as duplicates")
+	fmt.Println("  --pipeline                          # Read and upload several files at once (batch only)")
+	fmt.Println("  --uploaders=N                       # How many at once in a pipeline (default: 4)")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("  0  Success")
+	fmt.Println("  1  Some files or jobs failed, or the command failed")
+	fmt.Println("  2  Configuration error: bad arguments or settings, or missing credentials")
+	fmt.Println("  3  Authentication error: the credentials were rejected")
 	fmt.Println()
 	fmt.Println("Examples:")
-	fmt.Println("  go run main.go watch ./sample_content")
-	fmt.Println("  go run main.go batch ./sample_content")
-	fmt.Println("  go run main.go single ./sample_content/article.txt")
+	fmt.Println("  go run . watch ./sample_content")
+	fmt.Println("  go run . batch ./sample_content --max-failures 5")
+	fmt.Println("  go run . single ./sample_content/article.txt")
+	fmt.Println("  go run . update ./sample_content/article.txt")
+	fmt.Println("  go run . webhook :8080")
+	fmt.Println("  go run . consume nats content.published")
+	fmt.Println("  go run . mirror sftp://publisher@files.example.com/outgoing")
+	fmt.Println("  go run . enqueue ./sample_content")
 }
 
 // ProcessSingleFile processes a single file
@@ -441,14 +833,463 @@ func (app *Application) ProcessSingleFile(filePath string) error {
 	return app.processor.ProcessFile(filePath)
 }
 
-// StartWatching starts directory monitoring
+// StartWatching starts directory monitoring, draining the spool in the
+// background if there is one
 func (app *Application) StartWatching(directory string) error {
-	return app.watcher.Watch(directory)
+	return app.watch(context.Background(), directory)
+}
+
+// watch is StartWatching, returning when ctx is done
+func (app *Application) watch(ctx context.Context, directory string) error {
+	if spool := app.watcher.spool; spool != nil {
+		go app.processor.drainSpoolEvery(ctx, spool, app.spoolInterval)
+	}
+	return app.watcher.WatchContext(ctx, directory)
 }
 
 // BatchProcess processes all files in a directory
-func (app *Application) BatchProcess(directory string) error {
-	return app.batchProcessor.ProcessDirectory(directory)
+func (app *Application) BatchProcess(directory string, opts BatchOptions) error {
+	return app.batchProcessor.ProcessDirectory(directory, opts)
+}
+
+// ReceiveWebhooks serves ingestion status callbacks on addr, updating the
+// catalog and notifying the console and, if GLOO_NOTIFY_WEBHOOK_URL is set,
+// a chat channel when items finish
+func (app *Application) ReceiveWebhooks(addr string) error {
+	var secrets []string
+	for _, key := range []string{"GLOO_WEBHOOK_SECRET", "GLOO_WEBHOOK_SECRET_SECONDARY"} {
+		if secret := getEnv(key, ""); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) == 0 {
+		return fmt.Errorf("%w: GLOO_WEBHOOK_SECRET must be set to verify callbacks", errConfig)
+	}
+
+	notifiers := []Notifier{consoleNotifier{}}
+	if url := getEnv("GLOO_NOTIFY_WEBHOOK_URL", ""); url != "" {
+		notifiers = append(notifiers, NewChatNotifier(url))
+	}
+	handler := NewWebhookHandler(secrets, app.catalog, notifiers...)
+	handler.jobs = app.jobs
+	return ServeWebhooks(addr, handler)
+}
+
+// ConsumeQueue uploads the content messages on a Kafka topic or NATS
+// subject until SIGINT or SIGTERM. QUEUE_MAPPING_FILE maps message fields
+// to ContentData.
+func (app *Application) ConsumeQueue(kind, topic string) error {
+	source, err := newQueueSource(kind, topic)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errConfig, err)
+	}
+	var mapping FieldMapping
+	if path := getEnv("QUEUE_MAPPING_FILE", ""); path != "" {
+		if mapping, err = LoadFieldMapping(path); err != nil {
+			return fmt.Errorf("%w: %w", errConfig, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("📥 Consuming %s\n", source.Name())
+	fmt.Printf("   Mapping: %s\n", mappingSummary(mapping))
+	fmt.Println("   Press Ctrl+C to stop")
+	return source.Consume(ctx, func(msg *QueueMessage) error {
+		return app.processor.ProcessMessage(msg, mapping, kind)
+	})
+}
+
+// openWorkQueue connects to the Redis work queue at REDIS_URL
+func openWorkQueue() (*WorkQueue, error) {
+	redisURL := getEnv("REDIS_URL", "")
+	if redisURL == "" {
+		return nil, fmt.Errorf("%w: REDIS_URL must be set to use the work queue", errConfig)
+	}
+	visibility, err := time.ParseDuration(getEnv("GLOO_QUEUE_VISIBILITY_TIMEOUT", "5m"))
+	if err != nil || visibility <= 0 {
+		return nil, fmt.Errorf("%w: GLOO_QUEUE_VISIBILITY_TIMEOUT must be a positive duration, such as 5m", errConfig)
+	}
+	maxAttempts, err := strconv.Atoi(getEnv("GLOO_QUEUE_MAX_ATTEMPTS", "3"))
+	if err != nil || maxAttempts < 1 {
+		return nil, fmt.Errorf("%w: GLOO_QUEUE_MAX_ATTEMPTS must be a positive number", errConfig)
+	}
+	queue, err := NewWorkQueue(redisURL, getEnv("GLOO_QUEUE_PREFIX", "gloo:ingest"), visibility, maxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfig, err)
+	}
+	return queue, nil
+}
+
+// enqueueFile adds one file to the work queue, by path or, if inline, as
+// its upload payload
+func (app *Application) enqueueFile(queue *WorkQueue, filePath string, inline bool) error {
+	var job *WorkJob
+	var err error
+	if inline {
+		job, err = app.processor.NewPayloadJob(filePath)
+	} else {
+		job, err = NewFileJob(filePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	added, err := queue.Enqueue(job)
+	if err != nil {
+		return err
+	}
+	if added {
+		fmt.Printf("📥 Queued %s\n", filePath)
+	} else {
+		fmt.Printf("⏭️  Already queued: %s\n", filePath)
+	}
+	return nil
+}
+
+// Enqueue adds a file, or every supported file in a directory, to the
+// work queue
+func (app *Application) Enqueue(path string, inline bool) error {
+	queue, err := openWorkQueue()
+	if err != nil {
+		return err
+	}
+	defer queue.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%w: failed to stat %s: %w", errConfig, path, err)
+	}
+	if !info.IsDir() {
+		if !app.processor.IsSupportedFile(path) {
+			return fmt.Errorf("%w: unsupported file type: %s", errConfig, path)
+		}
+		return app.enqueueFile(queue, path, inline)
+	}
+
+	files, err := findSupportedFiles(path, app.processor.handlers.Extensions())
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, file := range files {
+		if err := app.enqueueFile(queue, file, inline); err != nil {
+			fmt.Printf("❌ Failed to queue %s: %v\n", file, err)
+			failed++
+		}
+	}
+	fmt.Printf("📊 Queued %d of %d files\n", len(files)-failed, len(files))
+	return runResult(nil, failed, len(files))
+}
+
+// WatchAndEnqueue watches a directory like StartWatching, but adds new
+// files to the work queue for the workers to upload
+func (app *Application) WatchAndEnqueue(directory string, inline bool) error {
+	queue, err := openWorkQueue()
+	if err != nil {
+		return err
+	}
+	defer queue.Close()
+
+	// Files go to Redis, not the API, so the spool doesn't apply
+	app.watcher.spool = nil
+	app.watcher.handle = func(filePath string) error {
+		return app.enqueueFile(queue, filePath, inline)
+	}
+	return app.watcher.Watch(directory)
+}
+
+// RunWorker uploads jobs from the work queue until SIGINT or SIGTERM
+func (app *Application) RunWorker() error {
+	queue, err := openWorkQueue()
+	if err != nil {
+		return err
+	}
+	defer queue.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("👷 Worker started on %s (visibility timeout %s, %d attempts)\n", queue.key("pending"), queue.visibility, queue.maxAttempts)
+	fmt.Println("   Press Ctrl+C to stop after the current job")
+	return queue.RunWorker(ctx, app.processor)
+}
+
+// PrintQueueStatus prints the work queue's counts
+func (app *Application) PrintQueueStatus() error {
+	queue, err := openWorkQueue()
+	if err != nil {
+		return err
+	}
+	defer queue.Close()
+
+	pending, inflight, dead, err := queue.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("⏳ Pending:   %d\n", pending)
+	fmt.Printf("⚙️  In flight: %d\n", inflight)
+	fmt.Printf("☠️  Dead:      %d (%s)\n", dead, queue.key("dead"))
+	return nil
+}
+
+// Jobs runs a jobs subcommand: list, show, retry, cancel, or run
+func (app *Application) Jobs(args []string) error {
+	if app.jobs == nil {
+		return fmt.Errorf("%w: GLOO_JOBS_DB must be set to the job database, such as ingestion_jobs.db", errConfig)
+	}
+
+	// jobID parses the subcommand's job ID argument
+	jobID := func() (int64, error) {
+		if len(args) < 2 {
+			return 0, fmt.Errorf("%w: please specify a job ID", errConfig)
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid job ID %q", errConfig, args[1])
+		}
+		return id, nil
+	}
+
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand = strings.ToLower(args[0])
+	}
+	switch subcommand {
+	case "show":
+		id, err := jobID()
+		if err != nil {
+			return err
+		}
+		job, err := app.jobs.Get(id)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return fmt.Errorf("no job %d", id)
+		}
+		fmt.Printf("Job %d (%s)\n", job.ID, job.Source)
+		fmt.Printf("   File:     %s\n", job.File)
+		fmt.Printf("   Status:   %s\n", job.Status)
+		fmt.Printf("   Attempts: %d\n", job.Attempts)
+		if job.TaskID != "" {
+			fmt.Printf("   Task:     %s\n", job.TaskID)
+		}
+		if job.Error != "" {
+			fmt.Printf("   Error:    %s\n", job.Error)
+		}
+		fmt.Printf("   Created:  %s\n", job.CreatedAt)
+		fmt.Printf("   Updated:  %s\n", job.UpdatedAt)
+
+	case "retry":
+		if len(args) > 1 && args[1] == "failed" {
+			n, err := app.jobs.RetryFailed()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("🔁 %d failed jobs are pending again\n", n)
+			return nil
+		}
+		id, err := jobID()
+		if err != nil {
+			return err
+		}
+		ok, err := app.jobs.Retry(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("job %d isn't failed or cancelled", id)
+		}
+		fmt.Printf("🔁 Job %d is pending again\n", id)
+
+	case "cancel":
+		id, err := jobID()
+		if err != nil {
+			return err
+		}
+		ok, err := app.jobs.Cancel(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("job %d isn't pending", id)
+		}
+		fmt.Printf("🚫 Job %d cancelled\n", id)
+
+	case "run":
+		jobs, err := app.jobs.List(JobPending)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No pending jobs")
+			return nil
+		}
+		opts, err := parseBatchOptions(args[1:])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Found %d pending jobs\n", len(jobs))
+		files := make([]string, len(jobs))
+		for i, job := range jobs {
+			files[i] = job.File
+		}
+		progress := NewProgress(opts.Progress, files)
+		progress.Start()
+		ran, failed := 0, 0
+		var lastErr error
+		stopReason := ""
+		for i := range jobs {
+			ran++
+			progress.Begin()
+			if err := app.processor.RunJob(app.jobs, &jobs[i]); err != nil {
+				fmt.Printf("❌ Failed to process %s: %v\n", jobs[i].File, err)
+				failed++
+				lastErr = err
+				progress.Finish(jobs[i].File, progressFailed)
+				if stop, reason := opts.shouldStop(failed, err); stop {
+					stopReason = fmt.Sprintf("%s; %d jobs left pending", reason, len(jobs)-ran)
+					break
+				}
+			} else {
+				progress.Finish(jobs[i].File, progressUploaded)
+			}
+
+			// Rate limiting - avoid overwhelming the API
+			time.Sleep(1 * time.Second)
+		}
+		progress.End(stopReason)
+		if stopReason != "" {
+			fmt.Printf("🛑 Stopped early: %s\n", stopReason)
+		}
+		fmt.Printf("\n📊 Ran %d jobs, %d failed\n", ran, failed)
+		return runResult(lastErr, failed, ran)
+
+	default:
+		// Anything else is a status to list, such as "jobs failed"
+		status := ""
+		if subcommand != "list" {
+			status = subcommand
+		} else if len(args) > 1 {
+			status = strings.ToLower(args[1])
+		}
+		jobs, err := app.jobs.List(status)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No jobs")
+			return nil
+		}
+		icons := map[string]string{
+			JobPending:    "⏳",
+			JobUploading:  "📤",
+			JobProcessing: "⚙️ ",
+			JobDone:       "✅",
+			JobFailed:     "❌",
+			JobCancelled:  "🚫",
+		}
+		for _, job := range jobs {
+			fmt.Printf("%s %5d  %-10s %s\n", icons[job.Status], job.ID, job.Status, job.File)
+			if job.Status == JobFailed && job.Error != "" {
+				fmt.Printf("          %s\n", job.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// Verify checks completed items against the content that was uploaded,
+// prints an integrity report, and writes it as JSON if args has --report
+func (app *Application) Verify(args []string) error {
+	reportPath := ""
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--report="):
+			reportPath = strings.TrimPrefix(arg, "--report=")
+		case arg == "--report" && i+1 < len(args):
+			reportPath = args[i+1]
+		case arg == "--report":
+			return fmt.Errorf("%w: --report needs a file name", errConfig)
+		}
+	}
+
+	verifier := &Verifier{processor: app.processor, itemURL: getEnv("GLOO_ITEM_URL", "")}
+	if verifier.itemURL != "" && !strings.Contains(verifier.itemURL, "{item_id}") {
+		return fmt.Errorf("%w: GLOO_ITEM_URL must contain {item_id}", errConfig)
+	}
+	report := verifier.VerifyCatalog(app.catalog)
+	if report.Checked == 0 {
+		fmt.Println("The catalog is empty. Upload files to add them.")
+		return nil
+	}
+
+	icons := map[string]string{
+		IntegrityOK:         "✅",
+		IntegrityTruncated:  "✂️ ",
+		IntegrityEncoding:   "🔣",
+		IntegrityMismatch:   "❌",
+		IntegrityUnverified: "❔",
+		IntegrityError:      "⚠️ ",
+	}
+	for _, r := range report.Items {
+		name := r.Title
+		if name == "" {
+			name = "task " + r.TaskID
+		}
+		fmt.Printf("%s %-10s %s\n", icons[r.Status], r.Status, name)
+		if r.Detail != "" {
+			fmt.Printf("   %s\n", r.Detail)
+		}
+	}
+	fmt.Printf("\n📊 Checked %d items: %d ok, %d corrupted, %d unverified, %d errors\n",
+		report.Checked, report.OK, report.Problems, report.Unverified, report.Errors)
+
+	if reportPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		if err := ioutil.WriteFile(reportPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		fmt.Printf("📝 Wrote integrity report to %s\n", reportPath)
+	}
+
+	switch {
+	case report.Problems > 0:
+		return fmt.Errorf("%w: %d of %d items don't match their upload", errIntegrity, report.Problems, report.Checked)
+	case report.Errors > 0:
+		return fmt.Errorf("%d items couldn't be fetched", report.Errors)
+	}
+	return nil
+}
+
+// PrintCatalog lists the uploaded items and their ingestion status
+func (app *Application) PrintCatalog() {
+	items := app.catalog.Items()
+	if len(items) == 0 {
+		fmt.Println("The catalog is empty. Upload files to add them.")
+		return
+	}
+
+	icons := map[string]string{
+		StatusSubmitted:  "⏳",
+		StatusProcessing: "⚙️ ",
+		StatusCompleted:  "✅",
+		StatusFailed:     "❌",
+	}
+	for _, item := range items {
+		if !item.Live() {
+			continue
+		}
+		fmt.Printf("%s %-10s %s  (task %s)\n", icons[item.Status], item.Status, item.Title, item.TaskID)
+		if item.Message != "" && item.Status == StatusFailed {
+			fmt.Printf("   %s\n", item.Message)
+		}
+	}
 }
 
 // getEnv returns environment variable value or fallback
@@ -478,6 +1319,8 @@ func validateCredentials() error {
 
 // Initialize loads environment variables and validates configuration
 func init() {
+	prepareService()
+
 	// Load environment variables from .env file if it exists
 	if err := godotenv.Load(); err != nil {
 		// .env file is optional, so we don't fail here
@@ -486,69 +1329,204 @@ func init() {
 	// Get credentials from environment
 	clientID = getEnv("GLOO_CLIENT_ID", "")
 	clientSecret = getEnv("GLOO_CLIENT_SECRET", "")
+	secondaryClientSecret = getEnv("GLOO_CLIENT_SECRET_SECONDARY", "")
 }
 
 func main() {
+	if len(os.Args) > 1 && hasFlag([]string{"help", "--help", "-h"}, strings.ToLower(os.Args[1])) {
+		printUsage()
+		os.Exit(exitOK)
+	}
+
+	// Installing a service needs no credentials until it runs
+	if len(os.Args) > 1 && strings.ToLower(os.Args[1]) == "service" {
+		var err error
+		switch {
+		case len(os.Args) > 3 && os.Args[2] == "install":
+			err = installService(os.Args[3])
+		case len(os.Args) > 2 && os.Args[2] == "uninstall":
+			err = uninstallService()
+		default:
+			err = fmt.Errorf("%w: usage: service install <directory> | service uninstall", errConfig)
+		}
+		if err != nil {
+			fmt.Printf("Error managing service: %v\n", err)
+		}
+		os.Exit(exitCode(err))
+	}
+
+	// The benchmark uploads to a mock server, so it needs no credentials
+	if len(os.Args) > 1 && strings.ToLower(os.Args[1]) == "bench" {
+		err := RunBench(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error running benchmark: %v\n", err)
+		}
+		os.Exit(exitCode(err))
+	}
+
+	// Fetch credentials from a secret store, if one is configured
+	var err error
+	if credentialProvider, err = loadCredentials(); err != nil {
+		fmt.Printf("Failed to load credentials: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
 	// Validate credentials
 	if err := validateCredentials(); err != nil {
-		os.Exit(1)
+		os.Exit(exitConfigError)
 	}
 
 	// Create application
 	app, err := NewApplication()
 	if err != nil {
 		fmt.Printf("Failed to create application: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitConfigError)
 	}
 
 	// Parse command line arguments
 	if len(os.Args) < 2 {
-		app.PrintUsage()
-		os.Exit(1)
+		printUsage()
+		os.Exit(exitConfigError)
+	}
+
+	// usageError reports a missing argument
+	usageError := func(message string) {
+		fmt.Println("Error: " + message)
+		printUsage()
+		os.Exit(exitConfigError)
 	}
 
 	command := strings.ToLower(os.Args[1])
+	var action string
 
 	switch command {
 	case "watch":
 		if len(os.Args) < 3 {
-			fmt.Println("Error: Please specify a directory to watch")
-			app.PrintUsage()
-			os.Exit(1)
+			usageError("Please specify a directory to watch")
 		}
 
-		if err := app.StartWatching(os.Args[2]); err != nil {
-			fmt.Printf("Error watching directory: %v\n", err)
-			os.Exit(1)
+		action = "watching directory"
+		if hasFlag(os.Args[3:], "--enqueue") {
+			err = app.WatchAndEnqueue(os.Args[2], hasFlag(os.Args[3:], "--inline"))
+		} else if isWindowsService() {
+			err = runService(app, os.Args[2])
+		} else {
+			err = app.StartWatching(os.Args[2])
 		}
 
 	case "batch":
 		if len(os.Args) < 3 {
-			fmt.Println("Error: Please specify a directory to process")
-			app.PrintUsage()
-			os.Exit(1)
+			usageError("Please specify a directory to process")
 		}
 
-		if err := app.BatchProcess(os.Args[2]); err != nil {
-			fmt.Printf("Error processing directory: %v\n", err)
-			os.Exit(1)
+		action = "processing directory"
+		var opts BatchOptions
+		if opts, err = parseBatchOptions(os.Args[3:]); err == nil {
+			err = app.BatchProcess(os.Args[2], opts)
 		}
 
 	case "single":
 		if len(os.Args) < 3 {
-			fmt.Println("Error: Please specify a file to process")
-			app.PrintUsage()
-			os.Exit(1)
+			usageError("Please specify a file to process")
+		}
+
+		action = "processing file"
+		err = app.ProcessSingleFile(os.Args[2])
+
+	case "update":
+		if len(os.Args) < 3 {
+			usageError("Please specify a file to update")
+		}
+
+		action = "updating file"
+		err = app.Update(os.Args[2:])
+
+	case "webhook":
+		addr := ":8080"
+		if len(os.Args) > 2 {
+			addr = os.Args[2]
 		}
 
-		if err := app.ProcessSingleFile(os.Args[2]); err != nil {
-			fmt.Printf("Error processing file: %v\n", err)
-			os.Exit(1)
+		action = "receiving webhooks"
+		err = app.ReceiveWebhooks(addr)
+
+	case "catalog":
+		app.PrintCatalog()
+
+	case "verify":
+		action = "verifying items"
+		err = app.Verify(os.Args[2:])
+
+	case "consume":
+		if len(os.Args) < 4 {
+			usageError("Please specify a queue (kafka or nats) and a topic")
 		}
 
+		action = "consuming queue"
+		err = app.ConsumeQueue(strings.ToLower(os.Args[2]), os.Args[3])
+
+	case "mirror":
+		if len(os.Args) < 3 {
+			usageError("Please specify a remote URL to mirror")
+		}
+
+		action = "mirroring remote directory"
+		err = app.Mirror(os.Args[2:])
+
+	case "mail":
+		action = "reading mailbox"
+		err = app.Mail(os.Args[2:])
+
+	case "podcast":
+		if len(os.Args) < 3 {
+			usageError("Please specify a podcast feed URL")
+		}
+
+		action = "reading podcast feed"
+		err = app.Podcast(os.Args[2:])
+
+	case "youtube":
+		if len(os.Args) < 3 {
+			usageError("Please specify a YouTube channel")
+		}
+
+		action = "reading YouTube channel"
+		err = app.YouTube(os.Args[2:])
+
+	case "confluence":
+		if len(os.Args) < 3 {
+			usageError("Please specify a Confluence space key")
+		}
+
+		action = "syncing Confluence space"
+		err = app.Confluence(os.Args[2:])
+
+	case "enqueue":
+		if len(os.Args) < 3 {
+			usageError("Please specify a file or directory to queue")
+		}
+
+		action = "queueing files"
+		err = app.Enqueue(os.Args[2], hasFlag(os.Args[3:], "--inline"))
+
+	case "worker":
+		action = "running worker"
+		err = app.RunWorker()
+
+	case "jobs":
+		action = "managing jobs"
+		err = app.Jobs(os.Args[2:])
+
+	case "queue-status":
+		action = "reading queue"
+		err = app.PrintQueueStatus()
+
 	default:
-		fmt.Printf("Error: Invalid command '%s'\n", command)
-		app.PrintUsage()
-		os.Exit(1)
+		usageError(fmt.Sprintf("Invalid command '%s'", command))
+	}
+
+	if err != nil {
+		fmt.Printf("Error %s: %v\n", action, err)
 	}
+	os.Exit(exitCode(err))
 }
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// xhtmlNode is an element or text in a parsed XHTML document
+type xhtmlNode struct {
+	// name is the element's local name, prefixed with ac: or ri: for
+	// Confluence's own elements; "" for text
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*xhtmlNode
+}
+
+// attr returns an attribute by its local name
+func (n *xhtmlNode) attr(name string) string {
+	return n.attrs[name]
+}
+
+// child returns the first child element with a name, or nil
+func (n *xhtmlNode) child(name string) *xhtmlNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// parameter returns a Confluence macro's ac:parameter
+func (n *xhtmlNode) parameter(name string) string {
+	for _, c := range n.children {
+		if c.name == "ac:parameter" && c.attr("name") == name {
+			return c.plainText()
+		}
+	}
+	return ""
+}
+
+// plainText returns the text inside a node, as it's written
+func (n *xhtmlNode) plainText() string {
+	if n.name == "" {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.plainText())
+	}
+	return b.String()
+}
+
+// parseXHTML reads a fragment of XHTML, such as a Confluence page in
+// storage format, leniently: HTML entities are known, and unclosed void
+// elements such as <br> are closed
+func parseXHTML(fragment string) (*xhtmlNode, error) {
+	// The ac: and ri: prefixes are declared so they read as namespaces
+	decoder := xml.NewDecoder(strings.NewReader(`<root xmlns:ac="ac" xmlns:ri="ri">` + fragment + `</root>`))
+	decoder.Strict = false
+	// Not xml.HTMLAutoClose, which would also close <ac:link>
+	decoder.AutoClose = []string{"br", "hr", "img"}
+	decoder.Entity = xml.HTMLEntity
+
+	root := &xhtmlNode{}
+	var stack []*xhtmlNode
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XHTML: %w", err)
+		}
+		parent := root
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if t.Name.Space == "ac" || t.Name.Space == "ri" {
+				name = t.Name.Space + ":" + name
+			}
+			node := &xhtmlNode{name: name, attrs: make(map[string]string)}
+			for _, a := range t.Attr {
+				node.attrs[strings.ToLower(a.Name.Local)] = a.Value
+			}
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			parent.children = append(parent.children, &xhtmlNode{text: string(t)})
+		}
+	}
+	if len(root.children) != 1 {
+		return nil, fmt.Errorf("invalid XHTML: unbalanced elements")
+	}
+	return root.children[0], nil
+}
+
+// markdownSpacePattern matches the whitespace HTML collapses to a space
+var markdownSpacePattern = regexp.MustCompile(`[ \t\n]+`)
+
+// markdownWriter converts XHTML to Markdown
+type markdownWriter struct {
+	b strings.Builder
+	// prefix starts each line, such as "> " in a quote
+	prefix string
+	// lists holds, for each open list, the next item number, or 0 if it's
+	// a bulleted list
+	lists []int
+}
+
+// storageToMarkdown converts a Confluence page in storage format to
+// Markdown: headings, paragraphs, emphasis, links, lists, tables, quotes,
+// and code blocks are kept; images and most macros' chrome are dropped,
+// though the text inside macros, such as info panels, is kept
+func storageToMarkdown(storage string) (string, error) {
+	root, err := parseXHTML(storage)
+	if err != nil {
+		return "", err
+	}
+	w := &markdownWriter{}
+	w.blocks(root)
+	return tidyMarkdown(w.b.String()), nil
+}
+
+// tidyMarkdown trims trailing spaces and reduces each run of blank lines
+// to one: an empty line, or ">" if the run is all inside a quote
+func tidyMarkdown(markdown string) string {
+	var lines []string
+	blank := ""
+	inBlank := false
+	for _, line := range strings.Split(markdown, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if strings.Trim(line, "> ") == "" {
+			if !inBlank || line == "" {
+				blank = line
+			}
+			inBlank = true
+			continue
+		}
+		if inBlank && len(lines) > 0 {
+			lines = append(lines, strings.TrimRight(blank, " "))
+		}
+		inBlank = false
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// newBlock ends the current block with a blank line
+func (w *markdownWriter) newBlock() {
+	w.b.WriteString("\n" + strings.TrimRight(w.prefix, " ") + "\n" + w.prefix)
+}
+
+// blocks writes a node's children
+func (w *markdownWriter) blocks(n *xhtmlNode) {
+	for _, c := range n.children {
+		w.node(c)
+	}
+}
+
+// inline returns the Markdown of a node's children on one line
+func (w *markdownWriter) inline(n *xhtmlNode) string {
+	inner := &markdownWriter{}
+	inner.blocks(n)
+	return strings.TrimSpace(markdownSpacePattern.ReplaceAllString(inner.b.String(), " "))
+}
+
+// node writes one node
+func (w *markdownWriter) node(n *xhtmlNode) {
+	switch n.name {
+	case "":
+		w.b.WriteString(markdownSpacePattern.ReplaceAllString(n.text, " "))
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.newBlock()
+		w.b.WriteString(strings.Repeat("#", int(n.name[1]-'0')) + " " + w.inline(n))
+		w.newBlock()
+	case "p", "div", "ac:rich-text-body", "ac:layout", "ac:layout-section", "ac:layout-cell":
+		w.newBlock()
+		w.blocks(n)
+		w.newBlock()
+	case "br":
+		w.b.WriteString("\n" + w.prefix)
+	case "hr":
+		w.newBlock()
+		w.b.WriteString("---")
+		w.newBlock()
+	case "strong", "b":
+		if text := w.inline(n); text != "" {
+			w.b.WriteString("**" + text + "**")
+		}
+	case "em", "i":
+		if text := w.inline(n); text != "" {
+			w.b.WriteString("*" + text + "*")
+		}
+	case "code":
+		w.b.WriteString("`" + n.plainText() + "`")
+	case "a":
+		text := w.inline(n)
+		if href := n.attr("href"); href != "" {
+			w.b.WriteString("[" + text + "](" + href + ")")
+		} else {
+			w.b.WriteString(text)
+		}
+	case "ac:link":
+		// A link to another page shows its own text, or the page's title
+		text := ""
+		if body := n.child("ac:link-body"); body != nil {
+			text = w.inline(body)
+		} else if body := n.child("ac:plain-text-link-body"); body != nil {
+			text = body.plainText()
+		} else if page := n.child("ri:page"); page != nil {
+			text = page.attr("content-title")
+		}
+		w.b.WriteString(text)
+	case "ul", "ol":
+		start := 0
+		if n.name == "ol" {
+			start = 1
+		}
+		if len(w.lists) == 0 {
+			w.b.WriteString("\n" + w.prefix)
+		}
+		w.lists = append(w.lists, start)
+		w.blocks(n)
+		w.lists = w.lists[:len(w.lists)-1]
+		if len(w.lists) == 0 {
+			w.newBlock()
+		}
+	case "li":
+		depth := len(w.lists)
+		marker := "- "
+		if depth > 0 && w.lists[depth-1] > 0 {
+			marker = fmt.Sprintf("%d. ", w.lists[depth-1])
+			w.lists[depth-1]++
+		}
+		indent := ""
+		if depth > 1 {
+			indent = strings.Repeat("  ", depth-1)
+		}
+		w.b.WriteString("\n" + w.prefix + indent + marker)
+		// Nested lists start their own lines; the rest of the item is one line
+		for _, c := range n.children {
+			if c.name == "p" {
+				w.b.WriteString(w.inline(c) + " ")
+			} else {
+				w.node(c)
+			}
+		}
+	case "blockquote":
+		w.newBlock()
+		outer := w.prefix
+		w.prefix += "> "
+		w.b.WriteString("> ")
+		w.blocks(n)
+		w.prefix = outer
+		w.newBlock()
+	case "pre":
+		w.codeBlock("", n.plainText())
+	case "table":
+		w.table(n)
+	case "ac:structured-macro":
+		switch n.attr("name") {
+		case "code", "noformat":
+			if body := n.child("ac:plain-text-body"); body != nil {
+				w.codeBlock(n.parameter("language"), body.plainText())
+			}
+		case "toc", "children", "recently-updated", "attachments", "jira":
+			// Generated from other content, or from other systems
+		default:
+			if body := n.child("ac:rich-text-body"); body != nil {
+				w.node(body)
+			}
+		}
+	case "ac:parameter", "ac:image", "ri:attachment", "ri:page", "ri:user", "ac:placeholder", "style", "script":
+		// Macro settings, images, and hidden content have no text to keep
+	default:
+		w.blocks(n)
+	}
+}
+
+// codeBlock writes a fenced code block
+func (w *markdownWriter) codeBlock(language, code string) {
+	w.newBlock()
+	w.b.WriteString("```" + language + "\n" + strings.Trim(code, "\n") + "\n```")
+	w.newBlock()
+}
+
+// table writes a table as a Markdown table, its first row the header
+func (w *markdownWriter) table(n *xhtmlNode) {
+	var rows [][]string
+	var collect func(n *xhtmlNode)
+	collect = func(n *xhtmlNode) {
+		for _, c := range n.children {
+			switch c.name {
+			case "tr":
+				var cells []string
+				for _, cell := range c.children {
+					if cell.name == "td" || cell.name == "th" {
+						cells = append(cells, strings.ReplaceAll(w.inline(cell), "|", `\|`))
+					}
+				}
+				rows = append(rows, cells)
+			case "thead", "tbody", "tfoot":
+				collect(c)
+			}
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return
+	}
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	w.newBlock()
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		w.b.WriteString("| " + strings.Join(row, " | ") + " |\n" + w.prefix)
+		if i == 0 {
+			w.b.WriteString(strings.Repeat("| --- ", columns) + "|\n" + w.prefix)
+		}
+	}
+	w.newBlock()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultUploaders is how many files --pipeline reads and uploads at once
+const defaultUploaders = 4
+
+// preparedFile is a file read into its upload payload, or the error
+// reading it
+type preparedFile struct {
+	file string
+	data *ContentData
+	err  error
+}
+
+// uploadedFile is the outcome of a file's upload
+type uploadedFile struct {
+	file string
+	err  error
+}
+
+// pipelineInterval returns the time between pipelined uploads from
+// GLOO_PIPELINE_RATE, in uploads per second, or 0 for no limit
+func pipelineInterval() (time.Duration, error) {
+	rate, err := strconv.ParseFloat(getEnv("GLOO_PIPELINE_RATE", "5"), 64)
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("%w: GLOO_PIPELINE_RATE must be a number of uploads per second, or 0 for no limit", errConfig)
+	}
+	if rate == 0 {
+		return 0, nil
+	}
+	return time.Duration(float64(time.Second) / rate), nil
+}
+
+// keepFresh fetches an access token, then fetches the next one just before
+// each expires, until ctx is done, so uploads don't wait on a refresh
+func (tm *TokenManager) keepFresh(ctx context.Context) {
+	for ctx.Err() == nil {
+		wait := 30 * time.Second
+		if token, err := tm.Token(); err == nil {
+			// Token refreshes once a token is within a minute of expiring
+			wait = time.Until(time.Unix(token.ExpiresAt-60, 0)) + time.Second
+		}
+		if wait < time.Second {
+			wait = time.Second
+		}
+		sleepContext(ctx, wait)
+	}
+}
+
+// processPipelined uploads files in three overlapping stages: the access
+// token is fetched, and kept fresh, in the background; readers turn files
+// into payloads; and uploaders send them, at most one per interval. The
+// stages are joined by bounded channels, so reading stays only a little
+// ahead of uploading. Outcomes are reported to progress from this goroutine
+// alone. Once opts says to stop, files not yet uploaded are dropped.
+func (bp *BatchProcessor) processPipelined(files []string, opts BatchOptions, interval time.Duration, progress *Progress) (processed, failed int, lastErr error, stopReason string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go bp.processor.tokenManager.keepFresh(ctx)
+
+	queue := make(chan string)
+	go func() {
+		defer close(queue)
+		for _, file := range files {
+			select {
+			case queue <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	prepared := make(chan preparedFile, 2*opts.Uploaders)
+	var readers sync.WaitGroup
+	for i := 0; i < opts.Uploaders; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for file := range queue {
+				data, err := bp.processor.prepareFile(file)
+				select {
+				case prepared <- preparedFile{file: file, data: data, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		readers.Wait()
+		close(prepared)
+	}()
+
+	var limit <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+	results := make(chan uploadedFile)
+	var uploaders sync.WaitGroup
+	for i := 0; i < opts.Uploaders; i++ {
+		uploaders.Add(1)
+		go func() {
+			defer uploaders.Done()
+			for p := range prepared {
+				if large := errors.Is(p.err, errLargeFile); p.err == nil || large {
+					if limit != nil {
+						select {
+						case <-limit:
+						case <-ctx.Done():
+						}
+					}
+					if ctx.Err() != nil {
+						continue
+					}
+					if large {
+						_, p.err = bp.processor.processLargeFile(p.file)
+					} else {
+						_, p.err = bp.processor.uploadFile(p.file, p.data)
+					}
+				}
+				results <- uploadedFile{file: p.file, err: p.err}
+			}
+		}()
+	}
+	go func() {
+		uploaders.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		progress.Begin()
+		if r.err == nil {
+			processed++
+			progress.Finish(r.file, progressUploaded)
+			continue
+		}
+		fmt.Printf("❌ Failed to process %s: %v\n", r.file, r.err)
+		failed++
+		lastErr = r.err
+		progress.Finish(r.file, progressFailed)
+		if stopReason == "" {
+			if stop, reason := opts.shouldStop(failed, r.err); stop {
+				stopReason = reason
+				cancel()
+			}
+		}
+	}
+	if stopReason != "" {
+		stopReason = fmt.Sprintf("%s; %d files not attempted", stopReason, len(files)-processed-failed)
+	}
+	return processed, failed, lastErr, stopReason
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultPodcastInterval is how often the podcast connector checks a feed
+const defaultPodcastInterval = time.Hour
+
+// podcastFeed is the part of a podcast's RSS feed the connector reads,
+// including the iTunes and Podcasting 2.0 (podcast:) extensions
+type podcastFeed struct {
+	Channel struct {
+		Title  string           `xml:"title"`
+		Author string           `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		Items  []podcastEpisode `xml:"item"`
+	} `xml:"channel"`
+}
+
+// podcastEpisode is one episode in a feed
+type podcastEpisode struct {
+	Title       string `xml:"title"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+	Author      string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	Duration    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Enclosure   struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Transcripts []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"https://podcastindex.org/namespace/1.0 transcript"`
+}
+
+// id identifies the episode across runs: its GUID, or its audio URL
+func (e *podcastEpisode) id() string {
+	if id := strings.TrimSpace(e.GUID); id != "" {
+		return id
+	}
+	return strings.TrimSpace(e.Enclosure.URL)
+}
+
+// published returns the episode's publication time, or the zero time if
+// the feed's date can't be read
+func (e *podcastEpisode) published() time.Time {
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(e.PubDate)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// formatDuration normalizes an itunes:duration, which is seconds or
+// [H:]MM:SS, to H:MM:SS, or returns "" if it can't be read
+func formatDuration(raw string) string {
+	seconds := 0
+	for _, field := range strings.Split(strings.TrimSpace(raw), ":") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return ""
+		}
+		seconds = seconds*60 + n
+	}
+	if seconds == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// Matches for reading caption transcripts
+var (
+	captionTimingPattern = regexp.MustCompile(`^(\d+:)?\d+:\d+[.,]\d+\s+-->`)
+	captionTagPattern    = regexp.MustCompile(`<[^>]*>`)
+)
+
+// captionsToText reduces WebVTT or SubRip captions to their text, dropping
+// cue numbers, timings, and styling, and lines repeated from the cue before
+func captionsToText(captions string) string {
+	var lines []string
+	skipBlock := false
+	for _, line := range strings.Split(normalizeText(captions), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			skipBlock = false
+			continue
+		case skipBlock:
+			continue
+		case strings.HasPrefix(line, "WEBVTT"), strings.HasPrefix(line, "NOTE"), line == "STYLE", line == "REGION":
+			skipBlock = true
+			continue
+		case captionTimingPattern.MatchString(line):
+			continue
+		}
+		if _, err := strconv.Atoi(line); err == nil {
+			continue
+		}
+		line = strings.TrimSpace(captionTagPattern.ReplaceAllString(line, ""))
+		if line != "" && (len(lines) == 0 || lines[len(lines)-1] != line) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// jsonTranscriptToText reads the Podcasting 2.0 JSON transcript format,
+// putting each speaker's run of segments in a paragraph
+func jsonTranscriptToText(data []byte) (string, error) {
+	var transcript struct {
+		Segments []struct {
+			Speaker string `json:"speaker"`
+			Body    string `json:"body"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return "", fmt.Errorf("invalid JSON transcript: %w", err)
+	}
+	var b strings.Builder
+	speaker := "\x00"
+	for _, segment := range transcript.Segments {
+		if segment.Speaker != speaker {
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			if segment.Speaker != "" {
+				b.WriteString(segment.Speaker + ": ")
+			}
+			speaker = segment.Speaker
+		} else {
+			b.WriteString(" ")
+		}
+		b.WriteString(strings.TrimSpace(segment.Body))
+	}
+	return b.String(), nil
+}
+
+// transcriptPreference ranks transcript types, best first: plain text
+// reads best, captions repeat less than nothing
+var transcriptPreference = []string{"text/plain", "application/json", "text/html", "text/vtt", "application/x-subrip", "application/srt"}
+
+// podcastSource reads one feed
+type podcastSource struct {
+	feedURL    string
+	httpClient *http.Client
+}
+
+// get fetches a URL's body
+func (s *podcastSource) get(rawURL string) ([]byte, error) {
+	resp, err := s.httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// fetchTranscript downloads the episode's best published transcript, or
+// returns "" if it has none
+func (s *podcastSource) fetchTranscript(episode *podcastEpisode) (string, error) {
+	best, rank := "", len(transcriptPreference)
+	bestType := ""
+	for _, t := range episode.Transcripts {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(t.Type, ";")[0]))
+		for i, preferred := range transcriptPreference {
+			if mediaType == preferred && i < rank {
+				best, rank, bestType = t.URL, i, mediaType
+			}
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	data, err := s.get(best)
+	if err != nil {
+		return "", fmt.Errorf("failed to download transcript: %w", err)
+	}
+	text, _ := normalizeContent(data)
+	switch bestType {
+	case "application/json":
+		return jsonTranscriptToText([]byte(text))
+	case "text/html":
+		return htmlToText(text), nil
+	case "text/vtt", "application/x-subrip", "application/srt":
+		return captionsToText(text), nil
+	}
+	return text, nil
+}
+
+// audioExtension returns the extension of an episode's audio file, from
+// its URL or, failing that, its type
+func (e *podcastEpisode) audioExtension() string {
+	if u, err := url.Parse(e.Enclosure.URL); err == nil && path.Ext(u.Path) != "" {
+		return strings.ToLower(path.Ext(u.Path))
+	}
+	switch strings.ToLower(e.Enclosure.Type) {
+	case "audio/mp4", "audio/x-m4a", "audio/m4a":
+		return ".m4a"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	}
+	return ".mp3"
+}
+
+// transcribeAudio downloads the episode's audio to a temp file and reads it
+// with the handler for its type
+func (s *podcastSource) transcribeAudio(episode *podcastEpisode, handler *FileHandler) (string, error) {
+	resp, err := (&http.Client{}).Get(episode.Enclosure.URL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download audio: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download audio: %s returned %d", episode.Enclosure.URL, resp.StatusCode)
+	}
+
+	audio, err := ioutil.TempFile("", "gloo-episode-*"+episode.audioExtension())
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(audio.Name())
+	if _, err := io.Copy(audio, resp.Body); err != nil {
+		audio.Close()
+		return "", fmt.Errorf("failed to download audio: %w", err)
+	}
+	if err := audio.Close(); err != nil {
+		return "", fmt.Errorf("failed to download audio: %w", err)
+	}
+	fmt.Printf("🎙️  Transcribing %s\n", episode.Title)
+	return handler.text(audio.Name())
+}
+
+// episodeContentData builds an episode's payload: the transcript, after a
+// header with the show, episode, and duration, titled by the episode, with
+// the episode's or show's author and the publication date
+func (cp *ContentProcessor) episodeContentData(feed *podcastFeed, episode *podcastEpisode, transcript string) *ContentData {
+	var header strings.Builder
+	fmt.Fprintf(&header, "Podcast: %s\nEpisode: %s\n", strings.TrimSpace(feed.Channel.Title), strings.TrimSpace(episode.Title))
+	if duration := formatDuration(episode.Duration); duration != "" {
+		fmt.Fprintf(&header, "Duration: %s\n", duration)
+	}
+	data := cp.CreateContentData(header.String()+"\n"+transcript, strings.TrimSpace(episode.Title))
+	data.Type = "Transcript"
+	data.ItemTags = append(data.ItemTags, "podcast")
+	author := strings.TrimSpace(episode.Author)
+	if author == "" {
+		author = strings.TrimSpace(feed.Channel.Author)
+	}
+	if author == "" {
+		author = strings.TrimSpace(feed.Channel.Title)
+	}
+	if author != "" {
+		data.Author = []string{author}
+	}
+	if published := episode.published(); !published.IsZero() {
+		data.PublicationDate = published.Format("2006-01-02")
+	}
+	data.ProducerID = "podcast/" + episode.id()
+	cp.enrich(data)
+	return data
+}
+
+// loadPodcastState reads the IDs of the episodes already ingested from
+// each feed
+func loadPodcastState(path string) (map[string]map[string]bool, error) {
+	state := make(map[string]map[string]bool)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read podcast state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse podcast state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Podcast ingests the transcripts of a podcast feed's episodes, then checks
+// the feed for new ones every interval until stopped. args is the feed URL,
+// then --once, --interval=DURATION, and --limit=N, the most episodes to
+// ingest in one check.
+func (app *Application) Podcast(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "--") {
+		return fmt.Errorf("%w: podcast needs a feed URL", errConfig)
+	}
+	if u, err := url.Parse(args[0]); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%w: invalid feed URL %q", errConfig, args[0])
+	}
+	source := &podcastSource{feedURL: args[0], httpClient: &http.Client{Timeout: time.Minute}}
+
+	once, interval, limit := false, defaultPodcastInterval, 0
+	for _, arg := range args[1:] {
+		var err error
+		switch {
+		case arg == "--once":
+			once = true
+		case strings.HasPrefix(arg, "--interval="):
+			interval, err = time.ParseDuration(strings.TrimPrefix(arg, "--interval="))
+			if err != nil || interval <= 0 {
+				return fmt.Errorf("%w: --interval must be a positive duration, such as 1h", errConfig)
+			}
+		case strings.HasPrefix(arg, "--limit="):
+			limit, err = strconv.Atoi(strings.TrimPrefix(arg, "--limit="))
+			if err != nil || limit <= 0 {
+				return fmt.Errorf("%w: --limit must be a positive number of episodes", errConfig)
+			}
+		default:
+			return fmt.Errorf("%w: unknown podcast option %q", errConfig, arg)
+		}
+	}
+
+	statePath := getEnv("GLOO_PODCAST_STATE", "podcast_state.json")
+	state, err := loadPodcastState(statePath)
+	if err != nil {
+		return err
+	}
+	if state[source.feedURL] == nil {
+		state[source.feedURL] = make(map[string]bool)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("🎧 Reading podcast feed %s\n", source.feedURL)
+	if !once {
+		fmt.Printf("   Checking every %s; press Ctrl+C to stop\n", interval)
+	}
+	for {
+		ingested, failed, err := app.podcastOnce(ctx, source, state, statePath, limit)
+		switch {
+		case err != nil && (once || errors.Is(err, errConfig) || errors.Is(err, errAuth)):
+			return err
+		case err != nil:
+			fmt.Printf("⚠️  Feed check failed: %v; trying again in %s\n", err, interval)
+		case once && failed > 0:
+			return fmt.Errorf("%d episodes failed to ingest; they're tried again on the next check", failed)
+		case ingested > 0 || failed > 0:
+			fmt.Printf("🎧 Ingested %d episodes, %d failed\n", ingested, failed)
+		}
+		if once {
+			return nil
+		}
+		sleepContext(ctx, interval)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// podcastOnce ingests the feed's episodes not ingested yet, oldest first.
+// An episode's transcript is the one the feed publishes or, if there's
+// none, its audio read by the file handler for the audio's type, such as
+// a speech-to-text command registered for .mp3. An episode is recorded as
+// ingested once uploaded, so one that fails, or has no transcript yet, is
+// tried again next time.
+func (app *Application) podcastOnce(ctx context.Context, source *podcastSource, state map[string]map[string]bool, statePath string, limit int) (ingested, failed int, err error) {
+	untranscribed := 0
+	defer func() {
+		if untranscribed > 0 {
+			fmt.Printf("⏭️  Skipped %d episodes with no published transcript; register a file handler for their audio type to transcribe them\n", untranscribed)
+		}
+	}()
+
+	data, err := source.get(source.feedURL)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to download feed: %w", err)
+	}
+	var feed podcastFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return 0, 0, fmt.Errorf("invalid feed: %w", err)
+	}
+	episodes := feed.Channel.Items
+	sort.SliceStable(episodes, func(i, j int) bool { return episodes[i].published().Before(episodes[j].published()) })
+
+	seen := state[source.feedURL]
+	save := func() error {
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal podcast state: %w", err)
+		}
+		if err := ioutil.WriteFile(statePath, data, 0644); err != nil {
+			return fmt.Errorf("failed to save podcast state: %w", err)
+		}
+		return nil
+	}
+	for i := range episodes {
+		episode := &episodes[i]
+		id := episode.id()
+		if id == "" || seen[id] {
+			continue
+		}
+		if ctx.Err() != nil || (limit > 0 && ingested+failed >= limit) {
+			break
+		}
+
+		transcript, err := source.fetchTranscript(episode)
+		if err == nil && transcript == "" && episode.Enclosure.URL != "" {
+			if handler, ok := app.processor.handlers.Lookup("episode" + episode.audioExtension()); ok && !handler.stream {
+				transcript, err = source.transcribeAudio(episode, handler)
+			}
+		}
+		if err == nil && strings.TrimSpace(transcript) == "" {
+			untranscribed++
+			continue
+		}
+		if err == nil {
+			contentData := app.processor.episodeContentData(&feed, episode, transcript)
+			var result *ApiResponse
+			if result, err = app.processor.UploadContent(contentData); err == nil {
+				fmt.Printf("✅ Successfully uploaded: %s\n", contentData.ItemTitle)
+				if app.catalog != nil {
+					if err := app.catalog.RecordUpload("podcast "+id, contentData, result); err != nil {
+						fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", contentData.ItemTitle, err)
+					}
+				}
+			} else {
+				err = fmt.Errorf("upload failed: %w", err)
+			}
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to ingest %s: %v\n", episode.Title, err)
+			failed++
+			if errors.Is(err, errAuth) {
+				return ingested, failed, err
+			}
+			continue
+		}
+		ingested++
+		seen[id] = true
+		if err := save(); err != nil {
+			return ingested, failed, err
+		}
+	}
+	return ingested, failed, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// defaultPollInterval is how often a polled directory is listed
+const defaultPollInterval = 10 * time.Second
+
+// loadPollSettings reads GLOO_WATCH_MODE, which is "events" (the default) or
+// "poll" to always poll, and GLOO_POLL_INTERVAL
+func loadPollSettings() (forcePoll bool, interval time.Duration, err error) {
+	switch mode := getEnv("GLOO_WATCH_MODE", "events"); mode {
+	case "events":
+	case "poll":
+		forcePoll = true
+	default:
+		return false, 0, fmt.Errorf("%w: GLOO_WATCH_MODE must be events or poll, not %q", errConfig, mode)
+	}
+	interval, err = time.ParseDuration(getEnv("GLOO_POLL_INTERVAL", defaultPollInterval.String()))
+	if err != nil || interval <= 0 {
+		return false, 0, fmt.Errorf("%w: GLOO_POLL_INTERVAL must be a positive duration, such as 10s", errConfig)
+	}
+	return forcePoll, interval, nil
+}
+
+// watchLimitWarning explains an error creating the file watcher if it's
+// Linux running out of inotify watches or instances, or returns "" if it's
+// not
+func watchLimitWarning(err error) string {
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		return "the inotify watch limit is reached (fs.inotify.max_user_watches); raise it with: sudo sysctl fs.inotify.max_user_watches=524288"
+	case errors.Is(err, syscall.EMFILE):
+		return "the inotify instance limit is reached (fs.inotify.max_user_instances); raise it with: sudo sysctl fs.inotify.max_user_instances=512"
+	}
+	return ""
+}
+
+// poll watches a directory by listing it every dw.pollInterval, for when
+// file system events aren't available. Like the event watcher, it leaves
+// files already there when it starts alone. A new file is handled once its
+// size and modification time are unchanged between two listings, so it
+// isn't read half-written.
+func (dw *DirectoryWatcher) poll(ctx context.Context, directory string) error {
+	seen := make(map[string]bool)
+	pending := make(map[string]os.FileInfo)
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return fmt.Errorf("failed to list directory: %w", err)
+	}
+	for _, f := range files {
+		seen[filepath.Join(directory, f.Name())] = true
+	}
+
+	for {
+		sleepContext(ctx, dw.pollInterval)
+		if ctx.Err() != nil {
+			return nil
+		}
+		files, err := ioutil.ReadDir(directory)
+		if err != nil {
+			fmt.Printf("Watcher error: %v\n", err)
+			continue
+		}
+
+		present := make(map[string]bool, len(files))
+		for _, f := range files {
+			path := filepath.Join(directory, f.Name())
+			present[path] = true
+			if f.IsDir() || seen[path] || !dw.processor.IsSupportedFile(path) {
+				continue
+			}
+			if prev, ok := pending[path]; !ok || prev.Size() != f.Size() || !prev.ModTime().Equal(f.ModTime()) {
+				pending[path] = f
+				continue
+			}
+			delete(pending, path)
+			seen[path] = true
+			fmt.Printf("📄 New file detected: %s\n", path)
+			if err := dw.handleFile(path); err != nil {
+				fmt.Printf("❌ Failed to process %s: %v\n", path, err)
+			}
+		}
+		// Forget removed files, so one created again under the same name is
+		// handled, as the event watcher would
+		for path := range seen {
+			if !present[path] {
+				delete(seen, path)
+			}
+		}
+		for path := range pending {
+			if !present[path] {
+				delete(pending, path)
+			}
+		}
+	}
+}
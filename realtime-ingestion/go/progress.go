@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Progress modes for --progress
+const (
+	ProgressNone = "none"
+	ProgressLine = "line" // a single line, redrawn after each file
+	ProgressJSON = "json" // one JSON event per line, for wrapping automation
+)
+
+// File outcomes in progress events
+const (
+	progressUploaded = "uploaded"
+	progressFailed   = "failed"
+	progressSkipped  = "skipped"
+)
+
+// ProgressEvent is a snapshot of a run. In JSON mode one is written when
+// the run starts ("start"), after each file ("file"), and at the end
+// ("end").
+type ProgressEvent struct {
+	Event   string `json:"event"`
+	File    string `json:"file,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+	// Done counts finished files, including failed and skipped ones
+	Done       int     `json:"done"`
+	Total      int     `json:"total"`
+	Failed     int     `json:"failed"`
+	Skipped    int     `json:"skipped"`
+	Bytes      int64   `json:"bytes"`
+	TotalBytes int64   `json:"total_bytes"`
+	Elapsed    float64 `json:"elapsed_seconds"`
+	// BytesPerSecond and FilesPerMinute are averages over the run so far
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	FilesPerMinute float64 `json:"files_per_minute"`
+	// ETA is the estimated seconds left, once there's a rate to go on
+	ETA *float64 `json:"eta_seconds,omitempty"`
+	// Stopped says why the run ended early, on the end event
+	Stopped string `json:"stopped,omitempty"`
+}
+
+// Progress reports how far a batch run has got: files and bytes done, the
+// rate, and an ETA. It writes to stderr, so it doesn't mix with the
+// per-file messages on stdout.
+type Progress struct {
+	mode  string
+	w     io.Writer
+	sizes map[string]int64
+	start time.Time
+
+	total      int
+	totalBytes int64
+	done       int
+	failed     int
+	skipped    int
+	bytes      int64
+	// attempted counts uploaded and failed files. The ETA is based on them
+	// alone, since skipped files take no time and would skew it.
+	attempted int
+	drawn     bool
+}
+
+// NewProgress creates a progress report over files in the given mode. An
+// empty mode draws the line when stderr is a terminal, and nothing
+// otherwise.
+func NewProgress(mode string, files []string) *Progress {
+	if mode == "" {
+		mode = ProgressNone
+		if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			mode = ProgressLine
+		}
+	}
+	p := &Progress{mode: mode, w: os.Stderr, sizes: map[string]int64{}, total: len(files)}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			p.sizes[file] = info.Size()
+			p.totalBytes += info.Size()
+		}
+	}
+	return p
+}
+
+// validProgressMode reports whether mode is a --progress value
+func validProgressMode(mode string) bool {
+	return mode == ProgressNone || mode == ProgressLine || mode == ProgressJSON
+}
+
+// Start begins the run
+func (p *Progress) Start() {
+	p.start = time.Now()
+	p.emit(p.event("start", "", ""))
+}
+
+// Begin marks the start of a file. It clears the progress line, so the
+// file's own messages don't land on the end of it.
+func (p *Progress) Begin() {
+	p.clear()
+}
+
+// Finish records a file's outcome and reports the new totals
+func (p *Progress) Finish(file, outcome string) {
+	p.done++
+	switch outcome {
+	case progressFailed:
+		p.failed++
+	case progressSkipped:
+		p.skipped++
+	}
+	if outcome != progressSkipped {
+		p.attempted++
+	}
+	p.bytes += p.sizes[file]
+	p.emit(p.event("file", file, outcome))
+}
+
+// End reports the final totals. stopped says why the run ended early, if
+// it did.
+func (p *Progress) End(stopped string) {
+	event := p.event("end", "", "")
+	event.Stopped = stopped
+	p.emit(event)
+	if p.drawn {
+		fmt.Fprintln(p.w)
+		p.drawn = false
+	}
+}
+
+// event snapshots the run
+func (p *Progress) event(kind, file, outcome string) ProgressEvent {
+	elapsed := time.Since(p.start)
+	event := ProgressEvent{
+		Event:      kind,
+		File:       file,
+		Outcome:    outcome,
+		Done:       p.done,
+		Total:      p.total,
+		Failed:     p.failed,
+		Skipped:    p.skipped,
+		Bytes:      p.bytes,
+		TotalBytes: p.totalBytes,
+		Elapsed:    elapsed.Seconds(),
+	}
+	if elapsed > 0 {
+		event.BytesPerSecond = float64(p.bytes) / elapsed.Seconds()
+		event.FilesPerMinute = float64(p.done) / elapsed.Minutes()
+	}
+	if p.attempted > 0 && kind != "end" {
+		eta := elapsed.Seconds() / float64(p.attempted) * float64(p.total-p.done)
+		event.ETA = &eta
+	}
+	return event
+}
+
+// emit writes an event in the current mode
+func (p *Progress) emit(event ProgressEvent) {
+	switch p.mode {
+	case ProgressJSON:
+		data, err := json.Marshal(event)
+		if err == nil {
+			fmt.Fprintln(p.w, string(data))
+		}
+	case ProgressLine:
+		if event.Event == "start" {
+			return
+		}
+		p.clear()
+		percent := 100
+		if event.Total > 0 {
+			percent = event.Done * 100 / event.Total
+		}
+		line := fmt.Sprintf("⏱️  %d/%d files (%d%%) · %s of %s · %.1f files/min",
+			event.Done, event.Total, percent, formatBytes(event.Bytes), formatBytes(event.TotalBytes), event.FilesPerMinute)
+		if event.Failed > 0 {
+			line += fmt.Sprintf(" · %d failed", event.Failed)
+		}
+		if event.ETA != nil {
+			line += " · ETA " + (time.Duration(*event.ETA) * time.Second).Round(time.Second).String()
+		}
+		fmt.Fprint(p.w, line)
+		p.drawn = true
+	}
+}
+
+// clear erases the progress line, if one is drawn
+func (p *Progress) clear() {
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// formatBytes formats a byte count for people
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KMGTPE"
+	i := 0
+	for value >= unit && i < len(suffix)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, suffix[i])
+}
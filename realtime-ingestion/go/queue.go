@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// QueueMessage is one content message from a message broker
+type QueueMessage struct {
+	Value []byte
+	// Position identifies the message in logs, such as its partition and
+	// offset
+	Position string
+}
+
+// QueueSource delivers content messages from a message broker
+type QueueSource interface {
+	Name() string
+	// Consume passes each message to handle until ctx is done. A message
+	// handle fails on is logged and skipped, so one bad message can't stall
+	// the queue.
+	Consume(ctx context.Context, handle func(*QueueMessage) error) error
+}
+
+// queueSources builds the source for each broker, for a topic (Kafka) or
+// subject (NATS)
+var queueSources = map[string]func(topic string) (QueueSource, error){
+	"kafka": newKafkaSource,
+	"nats":  newNATSSource,
+}
+
+// errSourceRejected marks broker errors that retrying won't fix, such as
+// bad credentials
+var errSourceRejected = errors.New("rejected by the broker")
+
+// newQueueSource builds the named source
+func newQueueSource(kind, topic string) (QueueSource, error) {
+	newSource, ok := queueSources[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown queue %q (expected kafka or nats)", kind)
+	}
+	return newSource(topic)
+}
+
+// FieldMapping maps ContentData fields, by their JSON names, to the fields
+// of a queue message that hold them. Paths are dot-separated, such as
+// "article.body". Fields that aren't mapped are read from the message
+// field of the same name.
+type FieldMapping map[string]string
+
+// mappableFields are the ContentData fields a message can set. publisherId
+// always comes from the configuration.
+var mappableFields = []string{
+	"content", "item_title", "author", "publication_date", "type",
+	"pub_type", "item_tags", "evergreen", "drm",
+}
+
+// LoadFieldMapping reads a mapping from a JSON file, such as
+// {"content": "body", "item_title": "headline", "author": "byline.names"}
+func LoadFieldMapping(path string) (FieldMapping, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field mapping: %w", err)
+	}
+	var mapping FieldMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse field mapping %s: %w", path, err)
+	}
+	for field := range mapping {
+		if !contains(mappableFields, field) {
+			return nil, fmt.Errorf("field mapping %s: unknown field %q (expected one of %s)", path, field, strings.Join(mappableFields, ", "))
+		}
+	}
+	return mapping, nil
+}
+
+// path returns the message path for a ContentData field
+func (m FieldMapping) path(field string) string {
+	if p, ok := m[field]; ok {
+		return p
+	}
+	return field
+}
+
+// lookup returns the value at a dot-separated path in a decoded message
+func lookup(message map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = message
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok || value == nil {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// BuildContentData maps a queue message to the upload payload. content and
+// item_title are required; other fields missing from the message get the
+// same defaults as file uploads.
+func (cp *ContentProcessor) BuildContentData(data []byte, mapping FieldMapping) (*ContentData, error) {
+	var message map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&message); err != nil {
+		return nil, fmt.Errorf("message is not a JSON object: %w", err)
+	}
+
+	str := func(field string) (string, bool, error) {
+		value, ok := lookup(message, mapping.path(field))
+		if !ok {
+			return "", false, nil
+		}
+		switch v := value.(type) {
+		case string:
+			return v, true, nil
+		case json.Number:
+			return v.String(), true, nil
+		}
+		return "", false, fmt.Errorf("%s (%s) must be a string", field, mapping.path(field))
+	}
+	list := func(field string) ([]string, bool, error) {
+		value, ok := lookup(message, mapping.path(field))
+		if !ok {
+			return nil, false, nil
+		}
+		switch v := value.(type) {
+		case string:
+			return []string{v}, true, nil
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, false, fmt.Errorf("%s (%s) must be a list of strings", field, mapping.path(field))
+				}
+				items = append(items, s)
+			}
+			return items, true, nil
+		}
+		return nil, false, fmt.Errorf("%s (%s) must be a string or a list of strings", field, mapping.path(field))
+	}
+
+	content, ok, err := str("content")
+	if err != nil {
+		return nil, err
+	}
+	// JSON strings are already UTF-8, but may carry a BOM or \r\n
+	content = normalizeText(content)
+	if !ok || strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("message has no content (%s)", mapping.path("content"))
+	}
+	title, ok, err := str("item_title")
+	if err != nil {
+		return nil, err
+	}
+	if !ok || strings.TrimSpace(title) == "" {
+		return nil, fmt.Errorf("message has no title (%s)", mapping.path("item_title"))
+	}
+	contentData := cp.CreateContentData(content, title)
+
+	for field, target := range map[string]*string{
+		"publication_date": &contentData.PublicationDate,
+		"type":             &contentData.Type,
+		"pub_type":         &contentData.PubType,
+	} {
+		value, ok, err := str(field)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			*target = value
+		}
+	}
+	for field, target := range map[string]*[]string{
+		"author":    &contentData.Author,
+		"item_tags": &contentData.ItemTags,
+		"drm":       &contentData.DRM,
+	} {
+		value, ok, err := list(field)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			*target = value
+		}
+	}
+	if value, ok := lookup(message, mapping.path("evergreen")); ok {
+		evergreen, isBool := value.(bool)
+		if !isBool {
+			return nil, fmt.Errorf("evergreen (%s) must be true or false", mapping.path("evergreen"))
+		}
+		contentData.Evergreen = evergreen
+	}
+	return contentData, nil
+}
+
+// ProcessMessage uploads the content in a queue message
+func (cp *ContentProcessor) ProcessMessage(msg *QueueMessage, mapping FieldMapping, source string) error {
+	contentData, err := cp.BuildContentData(msg.Value, mapping)
+	if err != nil {
+		return err
+	}
+	cp.enrich(contentData)
+
+	result, err := cp.UploadContent(contentData)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+
+	fmt.Printf("✅ Successfully uploaded: %s\n", contentData.ItemTitle)
+	fmt.Printf("   Response: %s\n", result.Message)
+
+	if cp.catalog != nil {
+		if err := cp.catalog.RecordUpload(source+" "+msg.Position, contentData, result); err != nil {
+			fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", contentData.ItemTitle, err)
+		}
+	}
+	return nil
+}
+
+// mappingSummary describes a mapping for the startup banner
+func mappingSummary(mapping FieldMapping) string {
+	if len(mapping) == 0 {
+		return "message fields match ContentData"
+	}
+	pairs := make([]string, 0, len(mapping))
+	for field, path := range mapping {
+		pairs = append(pairs, field+" ← "+path)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultMirrorInterval is how often mirror checks the remote directory
+const defaultMirrorInterval = 15 * time.Minute
+
+// mirrorStateFile records, in the mirror directory, which version of each
+// remote file was ingested
+const mirrorStateFile = ".mirror_state.json"
+
+// RemoteFile is a file in a remote directory
+type RemoteFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Version changes when the file does: its modification time as the
+	// server reports it
+	Version string `json:"version"`
+}
+
+// RemoteSource reads one directory on a file server
+type RemoteSource interface {
+	Name() string
+	// List returns the directory's files, leaving out subdirectories
+	List() ([]RemoteFile, error)
+	// Fetch downloads a file to dest
+	Fetch(name, dest string) error
+	Close() error
+}
+
+// remoteSources opens the source for each URL scheme
+var remoteSources = map[string]func(u *url.URL) (RemoteSource, error){
+	"ftp":  newFTPSource,
+	"sftp": newSFTPSource,
+}
+
+// parseRemoteURL checks a mirror URL, such as sftp://user@host/outgoing
+func parseRemoteURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: invalid remote URL %q (expected sftp://user@host/path or ftp://user@host/path)", errConfig, raw)
+	}
+	if _, ok := remoteSources[u.Scheme]; !ok {
+		return nil, fmt.Errorf("%w: unsupported remote URL scheme %q (expected sftp or ftp)", errConfig, u.Scheme)
+	}
+	return u, nil
+}
+
+// remotePassword returns the password in a remote URL, or
+// GLOO_REMOTE_PASSWORD, so it needn't be in the command line
+func remotePassword(u *url.URL) string {
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			return pass
+		}
+	}
+	return getEnv("GLOO_REMOTE_PASSWORD", "")
+}
+
+// MirrorOptions configure the mirror command
+type MirrorOptions struct {
+	// Dir holds the mirrored files, under a folder per server
+	Dir      string
+	Interval time.Duration
+	// Once stops after a single pass instead of checking on a schedule
+	Once bool
+}
+
+// parseMirrorOptions reads --dir=PATH, --interval=DURATION, and --once
+func parseMirrorOptions(args []string) (MirrorOptions, error) {
+	opts := MirrorOptions{Dir: getEnv("GLOO_MIRROR_DIR", "remote_mirror"), Interval: defaultMirrorInterval}
+	for _, arg := range args {
+		switch {
+		case arg == "--once":
+			opts.Once = true
+		case strings.HasPrefix(arg, "--dir="):
+			opts.Dir = strings.TrimPrefix(arg, "--dir=")
+		case strings.HasPrefix(arg, "--interval="):
+			interval, err := time.ParseDuration(strings.TrimPrefix(arg, "--interval="))
+			if err != nil || interval <= 0 {
+				return opts, fmt.Errorf("%w: --interval must be a positive duration, such as 15m", errConfig)
+			}
+			opts.Interval = interval
+		default:
+			return opts, fmt.Errorf("%w: unknown mirror option %q", errConfig, arg)
+		}
+	}
+	return opts, nil
+}
+
+// loadMirrorState reads the versions ingested from a mirror directory
+func loadMirrorState(dir string) (map[string]RemoteFile, error) {
+	state := make(map[string]RemoteFile)
+	data, err := ioutil.ReadFile(filepath.Join(dir, mirrorStateFile))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mirror state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse mirror state %s: %w", filepath.Join(dir, mirrorStateFile), err)
+	}
+	return state, nil
+}
+
+// saveMirrorState writes the ingested versions, replacing the file
+// atomically
+func saveMirrorState(dir string, state map[string]RemoteFile) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror state: %w", err)
+	}
+	tmp, err := ioutil.TempFile(dir, ".mirror_state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save mirror state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save mirror state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save mirror state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, mirrorStateFile)); err != nil {
+		return fmt.Errorf("failed to save mirror state: %w", err)
+	}
+	return nil
+}
+
+// Mirror copies the supported files in a remote directory to a local
+// mirror and ingests the new and changed ones, then checks again every
+// interval until stopped. args is the remote URL and MirrorOptions flags.
+func (app *Application) Mirror(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: mirror needs a remote URL, such as sftp://user@host/outgoing", errConfig)
+	}
+	u, err := parseRemoteURL(args[0])
+	if err != nil {
+		return err
+	}
+	opts, err := parseMirrorOptions(args[1:])
+	if err != nil {
+		return err
+	}
+	local := filepath.Join(opts.Dir, u.Hostname(), filepath.FromSlash(strings.Trim(u.Path, "/")))
+	if err := os.MkdirAll(local, 0755); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	state, err := loadMirrorState(local)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	display := *u
+	display.User = nil
+	if u.User != nil {
+		display.User = url.User(u.User.Username())
+	}
+	fmt.Printf("🌐 Mirroring %s to %s\n", display.String(), local)
+	if !opts.Once {
+		fmt.Printf("   Checking every %s; press Ctrl+C to stop\n", opts.Interval)
+	}
+	for {
+		ingested, failed, err := app.mirrorOnce(u, local, state)
+		switch {
+		case err != nil && (opts.Once || errors.Is(err, errConfig) || errors.Is(err, errAuth)):
+			return err
+		case err != nil:
+			fmt.Printf("⚠️  Mirror pass failed: %v; trying again in %s\n", err, opts.Interval)
+		case opts.Once && failed > 0:
+			return fmt.Errorf("%d files failed to ingest; they're tried again on the next pass", failed)
+		default:
+			fmt.Printf("🌐 Mirror pass done: %d ingested, %d failed\n", ingested, failed)
+		}
+		if opts.Once {
+			return nil
+		}
+		sleepContext(ctx, opts.Interval)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// mirrorOnce downloads and ingests each supported remote file whose size or
+// version differs from the one last ingested. A file's new version is
+// recorded only once it's ingested, so one that fails is tried again on
+// the next pass.
+func (app *Application) mirrorOnce(u *url.URL, local string, state map[string]RemoteFile) (ingested, failed int, err error) {
+	source, err := remoteSources[u.Scheme](u)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer source.Close()
+
+	files, err := source.List()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list %s: %w", source.Name(), err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	for _, file := range files {
+		// Names come from the server, so they mustn't lead out of the mirror
+		if file.Name != filepath.Base(file.Name) || strings.HasPrefix(file.Name, ".") {
+			continue
+		}
+		if !app.processor.IsSupportedFile(file.Name) || state[file.Name] == file {
+			continue
+		}
+
+		dest := filepath.Join(local, file.Name)
+		if err := app.fetchAndIngest(source, file, dest); err != nil {
+			fmt.Printf("❌ Failed to mirror %s: %v\n", file.Name, err)
+			failed++
+			if errors.Is(err, errAuth) {
+				return ingested, failed, err
+			}
+			continue
+		}
+		ingested++
+		state[file.Name] = file
+		if err := saveMirrorState(local, state); err != nil {
+			return ingested, failed, err
+		}
+	}
+	return ingested, failed, nil
+}
+
+// fetchAndIngest downloads a remote file into the mirror, then uploads it,
+// or updates its item if it was uploaded before
+func (app *Application) fetchAndIngest(source RemoteSource, file RemoteFile, dest string) error {
+	// Downloaded beside the mirror copy, so a failed transfer leaves the
+	// last good copy in place
+	partial := filepath.Join(filepath.Dir(dest), "."+file.Name+".part")
+	if err := source.Fetch(file.Name, partial); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if err := os.Rename(partial, dest); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to save download: %w", err)
+	}
+	fmt.Printf("📥 Downloaded %s (%d bytes)\n", dest, file.Size)
+
+	if _, found := app.catalog.Current(producerIDFor(dest)); found {
+		return app.Update([]string{dest})
+	}
+	return app.processor.ProcessFile(dest)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bibleBook is a book of the Bible and the names it is cited by
+type bibleBook struct {
+	name     string // canonical name, with its number if it has one
+	chapters int
+	// names are the full names, cited with or without a verse when
+	// wholeChapters is set; abbrevs are only recognized with a verse
+	names         []string
+	abbrevs       []string
+	wholeChapters bool
+}
+
+// bibleBooks lists the books of the Protestant canon. Numbered books share
+// their names, and the number in front tells them apart.
+var bibleBooks = []bibleBook{
+	{"Genesis", 50, []string{"Genesis"}, []string{"Gen", "Gn"}, true},
+	{"Exodus", 40, []string{"Exodus"}, []string{"Exod", "Exo", "Ex"}, true},
+	{"Leviticus", 27, []string{"Leviticus"}, []string{"Lev", "Lv"}, true},
+	{"Numbers", 36, []string{"Numbers"}, []string{"Num", "Nm"}, false},
+	{"Deuteronomy", 34, []string{"Deuteronomy"}, []string{"Deut", "Dt"}, true},
+	{"Joshua", 24, []string{"Joshua"}, []string{"Josh", "Jos"}, false},
+	{"Judges", 21, []string{"Judges"}, []string{"Judg", "Jdg"}, false},
+	{"Ruth", 4, []string{"Ruth"}, []string{"Rth"}, false},
+	{"1 Samuel", 31, []string{"Samuel"}, []string{"Sam", "Sm"}, true},
+	{"2 Samuel", 24, []string{"Samuel"}, []string{"Sam", "Sm"}, true},
+	{"1 Kings", 22, []string{"Kings"}, []string{"Kgs", "Kin"}, true},
+	{"2 Kings", 25, []string{"Kings"}, []string{"Kgs", "Kin"}, true},
+	{"1 Chronicles", 29, []string{"Chronicles"}, []string{"Chron", "Chr"}, true},
+	{"2 Chronicles", 36, []string{"Chronicles"}, []string{"Chron", "Chr"}, true},
+	{"Ezra", 10, []string{"Ezra"}, []string{"Ezr"}, false},
+	{"Nehemiah", 13, []string{"Nehemiah"}, []string{"Neh"}, true},
+	{"Esther", 10, []string{"Esther"}, []string{"Esth", "Est"}, false},
+	{"Job", 42, []string{"Job"}, []string{"Jb"}, false},
+	{"Psalms", 150, []string{"Psalms", "Psalm"}, []string{"Pss", "Psa", "Ps"}, true},
+	{"Proverbs", 31, []string{"Proverbs"}, []string{"Prov", "Prv"}, true},
+	{"Ecclesiastes", 12, []string{"Ecclesiastes"}, []string{"Eccles", "Eccl", "Ecc", "Qoh"}, true},
+	{"Song of Solomon", 8, []string{"Song of Solomon", "Song of Songs"}, []string{"Song"}, true},
+	{"Isaiah", 66, []string{"Isaiah"}, []string{"Isa"}, true},
+	{"Jeremiah", 52, []string{"Jeremiah"}, []string{"Jer"}, true},
+	{"Lamentations", 5, []string{"Lamentations"}, []string{"Lam"}, true},
+	{"Ezekiel", 48, []string{"Ezekiel"}, []string{"Ezek", "Ezk"}, true},
+	{"Daniel", 12, []string{"Daniel"}, []string{"Dan", "Dn"}, false},
+	{"Hosea", 14, []string{"Hosea"}, []string{"Hos"}, true},
+	{"Joel", 3, []string{"Joel"}, []string{"Jl"}, false},
+	{"Amos", 9, []string{"Amos"}, []string{"Am"}, false},
+	{"Obadiah", 1, []string{"Obadiah"}, []string{"Obad", "Ob"}, true},
+	{"Jonah", 4, []string{"Jonah"}, []string{"Jon", "Jnh"}, false},
+	{"Micah", 7, []string{"Micah"}, []string{"Mic"}, false},
+	{"Nahum", 3, []string{"Nahum"}, []string{"Nah"}, false},
+	{"Habakkuk", 3, []string{"Habakkuk"}, []string{"Hab"}, true},
+	{"Zephaniah", 3, []string{"Zephaniah"}, []string{"Zeph", "Zep"}, true},
+	{"Haggai", 2, []string{"Haggai"}, []string{"Hag"}, true},
+	{"Zechariah", 14, []string{"Zechariah"}, []string{"Zech", "Zec"}, false},
+	{"Malachi", 4, []string{"Malachi"}, []string{"Mal"}, false},
+	{"Matthew", 28, []string{"Matthew"}, []string{"Matt", "Mt"}, false},
+	{"Mark", 16, []string{"Mark"}, []string{"Mrk", "Mk"}, false},
+	{"Luke", 24, []string{"Luke"}, []string{"Lk"}, false},
+	{"John", 21, []string{"John"}, []string{"Jhn", "Jn"}, false},
+	{"Acts", 28, []string{"Acts"}, []string{"Act"}, false},
+	{"Romans", 16, []string{"Romans"}, []string{"Rom", "Rm"}, true},
+	{"1 Corinthians", 16, []string{"Corinthians"}, []string{"Cor"}, true},
+	{"2 Corinthians", 13, []string{"Corinthians"}, []string{"Cor"}, true},
+	{"Galatians", 6, []string{"Galatians"}, []string{"Gal"}, true},
+	{"Ephesians", 6, []string{"Ephesians"}, []string{"Ephes", "Eph"}, true},
+	{"Philippians", 4, []string{"Philippians"}, []string{"Phil", "Php"}, true},
+	{"Colossians", 4, []string{"Colossians"}, []string{"Col"}, true},
+	{"1 Thessalonians", 5, []string{"Thessalonians"}, []string{"Thess", "Thes"}, true},
+	{"2 Thessalonians", 3, []string{"Thessalonians"}, []string{"Thess", "Thes"}, true},
+	{"1 Timothy", 6, []string{"Timothy"}, []string{"Tim", "Tm"}, true},
+	{"2 Timothy", 4, []string{"Timothy"}, []string{"Tim", "Tm"}, true},
+	{"Titus", 3, []string{"Titus"}, []string{"Tit"}, false},
+	{"Philemon", 1, []string{"Philemon"}, []string{"Philem", "Phm"}, true},
+	{"Hebrews", 13, []string{"Hebrews"}, []string{"Heb"}, true},
+	{"James", 5, []string{"James"}, []string{"Jas"}, false},
+	{"1 Peter", 5, []string{"Peter"}, []string{"Pet", "Pt"}, true},
+	{"2 Peter", 3, []string{"Peter"}, []string{"Pet", "Pt"}, true},
+	{"1 John", 5, []string{"John"}, []string{"Jhn", "Jn"}, true},
+	{"2 John", 1, []string{"John"}, []string{"Jhn", "Jn"}, true},
+	{"3 John", 1, []string{"John"}, []string{"Jhn", "Jn"}, true},
+	{"Jude", 1, []string{"Jude"}, []string{"Jud"}, false},
+	{"Revelation", 22, []string{"Revelation", "Revelations"}, []string{"Rev"}, true},
+}
+
+// bookName is how a book was cited: its number, if any, and name
+type bookName struct {
+	book *bibleBook
+	full bool // the full name rather than an abbreviation
+}
+
+var (
+	// bookNames maps "<number> <name>", or just "<name>" for unnumbered
+	// books, to the book
+	bookNames = map[string]bookName{}
+	// scripturePattern matches a citation: an optional book number, a
+	// name, a chapter, and an optional verse or verse range
+	scripturePattern *regexp.Regexp
+)
+
+// bookNumbers maps the ways a book's number is written to the digit
+var bookNumbers = map[string]string{
+	"1": "1", "I": "1", "1st": "1", "First": "1",
+	"2": "2", "II": "2", "2nd": "2", "Second": "2",
+	"3": "3", "III": "3", "3rd": "3", "Third": "3",
+}
+
+func init() {
+	var names []string
+	for i := range bibleBooks {
+		book := &bibleBooks[i]
+		number := ""
+		if n, _, ok := strings.Cut(book.name, " "); ok && bookNumbers[n] != "" {
+			number = n + " "
+		}
+		for _, name := range book.names {
+			bookNames[number+name] = bookName{book: book, full: true}
+			names = append(names, name)
+		}
+		for _, abbrev := range book.abbrevs {
+			bookNames[number+abbrev] = bookName{book: book}
+			names = append(names, abbrev)
+		}
+	}
+
+	// Longer names first, so "Song of Solomon" wins over "Song"
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	alternatives := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			alternatives = append(alternatives, strings.ReplaceAll(regexp.QuoteMeta(name), " ", `\s+`))
+		}
+	}
+	scripturePattern = regexp.MustCompile(`\b(?:(1st|2nd|3rd|First|Second|Third|III|II|I|1|2|3)\s*)?(` +
+		strings.Join(alternatives, "|") +
+		`)(?:\.\s*|\s+)(\d{1,3})(?::(\d{1,3})(?:\s*[-–]\s*(\d{1,3})(?::(\d{1,3}))?)?)?\b`)
+}
+
+// ScriptureRef is a Bible reference: a whole chapter, a verse, or a range
+type ScriptureRef struct {
+	Book    string
+	Chapter int
+	Verse   int // 0 for a whole chapter
+	// EndChapter and EndVerse end a range; 0 if it isn't one
+	EndChapter int
+	EndVerse   int
+}
+
+// String formats the reference as "Romans 8:28", "Romans 8:28-30",
+// "John 3:16-4:2", or "Psalms 23"
+func (r ScriptureRef) String() string {
+	s := fmt.Sprintf("%s %d", r.Book, r.Chapter)
+	if r.Verse == 0 {
+		return s
+	}
+	s += fmt.Sprintf(":%d", r.Verse)
+	switch {
+	case r.EndChapter != 0 && r.EndChapter != r.Chapter:
+		s += fmt.Sprintf("-%d:%d", r.EndChapter, r.EndVerse)
+	case r.EndVerse != 0:
+		s += fmt.Sprintf("-%d", r.EndVerse)
+	}
+	return s
+}
+
+// ParseScriptureReferences finds the Bible references in text, in order.
+// Abbreviated books are only recognized with a verse, and so are books
+// whose names are also common words or names ("Job 3", "Mark 4"), which
+// keeps ordinary prose from being read as references. Chapters past the end
+// of a book are ignored.
+func ParseScriptureReferences(text string) []ScriptureRef {
+	var refs []ScriptureRef
+	for _, m := range scripturePattern.FindAllStringSubmatch(text, -1) {
+		name := strings.Join(strings.Fields(m[2]), " ")
+		// A number in front may not belong to the citation, as in
+		// "chapter 2 John 3:16", so fall back to the name alone
+		keys := []string{name}
+		if m[1] != "" {
+			keys = []string{bookNumbers[m[1]] + " " + name, name}
+		}
+		ref, ok := ScriptureRef{}, false
+		for _, key := range keys {
+			if ref, ok = citedRef(key, m[3], m[4]); ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if m[6] != "" {
+			ref.EndChapter, _ = strconv.Atoi(m[5])
+			ref.EndVerse, _ = strconv.Atoi(m[6])
+		} else if m[5] != "" {
+			ref.EndVerse, _ = strconv.Atoi(m[5])
+		}
+		if ref.EndChapter > bookNames[ref.Book].book.chapters || ref.EndChapter != 0 && ref.EndChapter < ref.Chapter ||
+			ref.EndChapter == 0 && ref.EndVerse != 0 && ref.EndVerse <= ref.Verse {
+			// Not a range that makes sense, so keep just the start
+			ref.EndChapter, ref.EndVerse = 0, 0
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// citedRef resolves a book name, with its number if any, and a chapter and
+// verse, reporting whether they make a reference
+func citedRef(key, chapterText, verseText string) (ScriptureRef, bool) {
+	cited, ok := bookNames[key]
+	if !ok {
+		return ScriptureRef{}, false
+	}
+	book := cited.book
+	chapter, _ := strconv.Atoi(chapterText)
+	verse, _ := strconv.Atoi(verseText)
+	if verse == 0 && !(cited.full && book.wholeChapters) {
+		return ScriptureRef{}, false
+	}
+	if book.chapters == 1 && verse == 0 {
+		// "Philemon 10" is verse 10 of the only chapter
+		chapter, verse = 1, chapter
+	}
+	if chapter < 1 || chapter > book.chapters {
+		return ScriptureRef{}, false
+	}
+	return ScriptureRef{Book: book.name, Chapter: chapter, Verse: verse}, true
+}
+
+// ScriptureTagger is an Enricher that adds the Bible references in a
+// document as tags, so search can filter on them. Each reference gets a tag
+// such as "scripture:Romans 8:28", and each book one such as
+// "scripture:Romans".
+type ScriptureTagger struct {
+	prefix  string
+	maxRefs int // the most-cited references are kept
+}
+
+// NewScriptureTagger creates a scripture tagger configured from the
+// environment: GLOO_SCRIPTURE_TAG_PREFIX and GLOO_SCRIPTURE_MAX_REFS
+func NewScriptureTagger() (*ScriptureTagger, error) {
+	maxRefs, err := strconv.Atoi(getEnv("GLOO_SCRIPTURE_MAX_REFS", "20"))
+	if err != nil || maxRefs < 1 {
+		return nil, fmt.Errorf("%w: GLOO_SCRIPTURE_MAX_REFS must be a positive number", errConfig)
+	}
+	return &ScriptureTagger{
+		prefix:  getEnv("GLOO_SCRIPTURE_TAG_PREFIX", "scripture:"),
+		maxRefs: maxRefs,
+	}, nil
+}
+
+// Name identifies the enricher in warnings
+func (s *ScriptureTagger) Name() string {
+	return "scripture tagging"
+}
+
+// Enrich adds tags for the references in the upload's title and content,
+// most-cited first
+func (s *ScriptureTagger) Enrich(data *ContentData) error {
+	counts := map[string]int{}
+	var order []ScriptureRef
+	for _, ref := range ParseScriptureReferences(data.ItemTitle + "\n" + data.Content) {
+		key := ref.String()
+		if counts[key] == 0 {
+			order = append(order, ref)
+		}
+		counts[key]++
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	// Stable, so equally cited references stay in order of appearance
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i].String()] > counts[order[j].String()]
+	})
+	if len(order) > s.maxRefs {
+		order = order[:s.maxRefs]
+	}
+
+	var refTags, bookTags []string
+	for _, ref := range order {
+		refTags = append(refTags, s.prefix+ref.String())
+		if tag := s.prefix + ref.Book; !contains(bookTags, tag) {
+			bookTags = append(bookTags, tag)
+		}
+	}
+	data.ItemTags = mergeTags(mergeTags(data.ItemTags, bookTags), refTags)
+
+	names := make([]string, len(order))
+	for i, ref := range order {
+		names[i] = ref.String()
+	}
+	fmt.Printf("📖 Found %d scripture references in %s: %s\n", len(order), data.ItemTitle, strings.Join(names, ", "))
+	return nil
+}
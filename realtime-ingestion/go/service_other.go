@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// errNotWindows is returned by the service commands on other systems
+var errNotWindows = fmt.Errorf("%w: Windows services can only be installed on Windows; see Systemd Service in the README", errConfig)
+
+// isWindowsService reports whether the process is a Windows service, which
+// it never is here
+func isWindowsService() bool {
+	return false
+}
+
+// prepareService does nothing outside Windows
+func prepareService() {}
+
+// runService is only reached on Windows
+func runService(app *Application, directory string) error {
+	return errNotWindows
+}
+
+// installService is only supported on Windows
+func installService(directory string) error {
+	return errNotWindows
+}
+
+// uninstallService is only supported on Windows
+func uninstallService() error {
+	return errNotWindows
+}
+
+// longPath returns path unchanged, as only Windows limits path length
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,176 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceName is the Windows service's name, GLOO_SERVICE_NAME or
+// GlooIngestion
+func serviceName() string {
+	return getEnv("GLOO_SERVICE_NAME", "GlooIngestion")
+}
+
+// isWindowsService reports whether the service control manager started
+// the process
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// prepareService moves a service into its executable's directory, since
+// services start in System32, where .env and the catalog aren't
+func prepareService() {
+	if !isWindowsService() {
+		return
+	}
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+}
+
+// watchService runs watch mode for the service control manager
+type watchService struct {
+	app       *Application
+	directory string
+}
+
+// Execute watches until the service is stopped or the system shuts down
+func (s *watchService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.app.watch(ctx, s.directory)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				fmt.Printf("❌ Watching stopped: %v\n", err)
+				return true, 1
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				fmt.Println("🛑 Service stopping")
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				select {
+				case <-done:
+				case <-time.After(20 * time.Second):
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// runService runs watch mode as a Windows service. With no console, output
+// goes to GLOO_SERVICE_LOG, by default ingestion-service.log beside the
+// executable.
+func runService(app *Application, directory string) error {
+	logFile, err := os.OpenFile(getEnv("GLOO_SERVICE_LOG", "ingestion-service.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open service log: %w", err)
+	}
+	defer logFile.Close()
+	os.Stdout = logFile
+	fmt.Printf("▶️  Service %s started %s\n", serviceName(), time.Now().Format(time.RFC3339))
+	return svc.Run(serviceName(), &watchService{app: app, directory: directory})
+}
+
+// installService registers a service that runs watch mode on directory
+// from this executable, starts at boot, and restarts after a crash
+func installService(directory string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the executable: %w", err)
+	}
+	if strings.Contains(exe, "go-build") {
+		return fmt.Errorf("%w: build the program with go build first; 'go run' executables are temporary", errConfig)
+	}
+	dir, err := filepath.Abs(directory)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errConfig, err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	name := serviceName()
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("%w: service %s already exists; uninstall it first", errConfig, name)
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "Gloo AI Realtime Ingestion",
+		Description: "Uploads new files in " + dir + " to Gloo AI",
+		StartType:   mgr.StartAutomatic,
+	}, "watch", dir)
+	if err != nil {
+		return fmt.Errorf("failed to create service %s: %w", name, err)
+	}
+	defer s.Close()
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 30 * time.Second}
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, uint32((24 * time.Hour).Seconds())); err != nil {
+		fmt.Printf("⚠️  Failed to set the service to restart after failures: %v\n", err)
+	}
+
+	fmt.Printf("✅ Installed service %s watching %s\n", name, dir)
+	fmt.Printf("   Start it with: sc start %s\n", name)
+	return nil
+}
+
+// uninstallService removes the service
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	name := serviceName()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("%w: service %s is not installed", errConfig, name)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to remove service %s: %w", name, err)
+	}
+	fmt.Printf("✅ Removed service %s; it is deleted once stopped\n", name)
+	return nil
+}
+
+// longPath returns a directory's path with the \\?\ prefix if it's near the
+// 260-character MAX_PATH limit, which the file watcher's Windows calls
+// otherwise fail on
+func longPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < 248 || strings.HasPrefix(abs, `\\?\`) {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
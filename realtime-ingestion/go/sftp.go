@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// sftpSource reads a directory over SFTP by running OpenSSH's sftp client
+// in batch mode, so no SSH library is needed. Each listing and download is its own connection. Logins use a
+// key or the SSH agent, never a password, and the server's host key must
+// already be in known_hosts.
+type sftpSource struct {
+	target string
+	args   []string
+	dir    string
+}
+
+// newSFTPSource connects to the server in u with the key in GLOO_SFTP_KEY,
+// or whatever ~/.ssh/config and the agent offer
+func newSFTPSource(u *url.URL) (RemoteSource, error) {
+	if _, err := exec.LookPath("sftp"); err != nil {
+		return nil, fmt.Errorf("%w: SFTP needs OpenSSH's sftp client on the PATH", errConfig)
+	}
+	if remotePassword(u) != "" {
+		return nil, fmt.Errorf("%w: SFTP logins use a key, not a password; set GLOO_SFTP_KEY", errConfig)
+	}
+	args := []string{"-b", "-", "-o", "BatchMode=yes", "-o", "ConnectTimeout=30"}
+	if port := u.Port(); port != "" {
+		args = append(args, "-P", port)
+	}
+	if key := getEnv("GLOO_SFTP_KEY", ""); key != "" {
+		args = append(args, "-i", key)
+	}
+	target := u.Hostname()
+	if u.User != nil {
+		target = u.User.Username() + "@" + target
+	}
+	return &sftpSource{target: target, args: args, dir: u.Path}, nil
+}
+
+func (s *sftpSource) Name() string {
+	return "sftp://" + s.target + s.dir
+}
+
+// run runs batch commands, returning their output
+func (s *sftpSource) run(commands string) (string, error) {
+	cmd := exec.Command("sftp", append(s.args, s.target)...)
+	cmd.Stdin = strings.NewReader(commands)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("sftp failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// sftpQuote quotes an argument to a batch command
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// List reads the directory with ls -ln. The listing's date only has
+// minutes, or the year for older files, so together with the size it
+// serves as the version.
+func (s *sftpSource) List() ([]RemoteFile, error) {
+	dir := s.dir
+	if dir == "" {
+		dir = "."
+	}
+	out, err := s.run("ls -ln " + sftpQuote(dir) + "\n")
+	if err != nil {
+		return nil, err
+	}
+	return parseSFTPListing(out), nil
+}
+
+// parseSFTPListing reads the regular files from ls -ln output, such as
+// "-rw-r--r--    1 1000     1000         1234 Jan  2 15:04 /outgoing/a.txt"
+func parseSFTPListing(out string) []RemoteFile {
+	var files []RemoteFile
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, "\r")
+		fields := strings.Fields(line)
+		// Batch mode echoes each command after an sftp> prompt
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		// The name is the rest of the line after eight fields, so it may
+		// hold spaces
+		rest := line
+		for i := 0; i < 8; i++ {
+			rest = strings.TrimLeft(rest, " ")
+			rest = rest[strings.IndexByte(rest, ' '):]
+		}
+		files = append(files, RemoteFile{
+			Name:    path.Base(strings.TrimLeft(rest, " ")),
+			Size:    size,
+			Version: strings.Join(fields[5:8], " "),
+		})
+	}
+	return files
+}
+
+// Fetch downloads a file with get
+func (s *sftpSource) Fetch(name, dest string) error {
+	remote := name
+	if s.dir != "" {
+		remote = path.Join(s.dir, name)
+	}
+	_, err := s.run("get " + sftpQuote(remote) + " " + sftpQuote(dest) + "\n")
+	return err
+}
+
+// Close does nothing, as no connection stays open
+func (s *sftpSource) Close() error {
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errUnavailable marks upload failures where the API couldn't be reached or
+// failed on its side, which are worth retrying later
+var errUnavailable = errors.New("Gloo AI API unavailable")
+
+// unavailableStatus reports whether an HTTP status means the API is down or
+// overloaded, rather than that the request was wrong
+func unavailableStatus(code int) bool {
+	return code >= 500 || code == 429
+}
+
+// SpoolEntry is an upload waiting in the spool
+type SpoolEntry struct {
+	File      string       `json:"file"`
+	Payload   *ContentData `json:"payload"`
+	SpooledAt time.Time    `json:"spooled_at"`
+}
+
+// Spool is a directory of uploads held back while the API is unreachable,
+// one JSON file each, uploaded oldest first once it's back. Uploads the API
+// rejects are moved to its rejected subdirectory.
+type Spool struct {
+	dir string
+}
+
+// OpenSpool opens the spool in dir, creating it if needed
+func OpenSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "rejected"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return &Spool{dir: dir}, nil
+}
+
+// Add spools a file's payload. It's written to a temporary file and renamed
+// into place, so a crash never leaves a half-written entry.
+func (s *Spool) Add(file string, payload *ContentData) error {
+	data, err := json.MarshalIndent(SpoolEntry{File: file, Payload: payload, SpooledAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool entry: %w", err)
+	}
+	tmp, err := ioutil.TempFile(s.dir, ".spool-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to spool %s: %w", file, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to spool %s: %w", file, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to spool %s: %w", file, err)
+	}
+	// Names sort by spool time; the temporary name's random part keeps
+	// entries spooled in the same nanosecond apart
+	name := fmt.Sprintf("%020d-%s", time.Now().UnixNano(), strings.TrimPrefix(filepath.Base(tmp.Name()), ".spool-"))
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("failed to spool %s: %w", file, err)
+	}
+	return nil
+}
+
+// Pending returns the names of the waiting entries, oldest first
+func (s *Spool) Pending() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && !strings.HasPrefix(f.Name(), ".") && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Len returns how many entries are waiting, or 0 if the spool can't be read
+func (s *Spool) Len() int {
+	names, _ := s.Pending()
+	return len(names)
+}
+
+// load reads a waiting entry
+func (s *Spool) load(name string) (*SpoolEntry, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool entry %s: %w", name, err)
+	}
+	var entry SpoolEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Payload == nil {
+		return nil, fmt.Errorf("spool entry %s is corrupt", name)
+	}
+	return &entry, nil
+}
+
+// reject moves an entry the API won't accept out of the way
+func (s *Spool) reject(name string) error {
+	return os.Rename(filepath.Join(s.dir, name), filepath.Join(s.dir, "rejected", name))
+}
+
+// DrainSpool uploads the spooled entries, oldest first. It stops at the
+// first failure that means the API is still unavailable, or that the
+// credentials were rejected, leaving that entry and the rest spooled.
+// Entries the API rejects are moved aside.
+func (cp *ContentProcessor) DrainSpool(s *Spool) (uploaded int, err error) {
+	names, err := s.Pending()
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range names {
+		entry, err := s.load(name)
+		if err != nil {
+			fmt.Printf("❌ %v; moving it to %s\n", err, filepath.Join(s.dir, "rejected"))
+			if err := s.reject(name); err != nil {
+				return uploaded, err
+			}
+			continue
+		}
+
+		result, err := cp.UploadContent(entry.Payload)
+		if errors.Is(err, errUnavailable) || errors.Is(err, errAuth) {
+			return uploaded, err
+		}
+		if err != nil {
+			fmt.Printf("❌ Spooled upload of %s was rejected: %v\n", entry.File, err)
+			if err := s.reject(name); err != nil {
+				return uploaded, err
+			}
+			continue
+		}
+
+		fmt.Printf("✅ Uploaded spooled file: %s\n", entry.File)
+		if cp.catalog != nil {
+			if err := cp.catalog.RecordUpload(entry.File, entry.Payload, result); err != nil {
+				fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", entry.File, err)
+			}
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return uploaded, fmt.Errorf("failed to remove spool entry %s: %w", name, err)
+		}
+		uploaded++
+	}
+	return uploaded, nil
+}
+
+// drainSpoolEvery drains the spool every interval until ctx is done
+func (cp *ContentProcessor) drainSpoolEvery(ctx context.Context, s *Spool, interval time.Duration) {
+	for ctx.Err() == nil {
+		if waiting := s.Len(); waiting > 0 {
+			uploaded, err := cp.DrainSpool(s)
+			if uploaded > 0 {
+				fmt.Printf("📦 Drained %d spooled uploads\n", uploaded)
+			}
+			if err != nil {
+				fmt.Printf("⏳ %d uploads still spooled; retrying in %s: %v\n", s.Len(), interval, err)
+			}
+		}
+		sleepContext(ctx, interval)
+	}
+}
+
+// handleFile hands a new file to dw.handle. With a spool, files are spooled
+// instead when the API is unreachable, and while earlier files are still
+// spooled, so they're uploaded in order without each waiting on a timeout.
+func (dw *DirectoryWatcher) handleFile(filePath string) error {
+	if dw.spool == nil {
+		return dw.handle(filePath)
+	}
+	if dw.spool.Len() == 0 {
+		err := dw.handle(filePath)
+		if !errors.Is(err, errUnavailable) {
+			return err
+		}
+		fmt.Printf("📡 Upload of %s failed: %v\n", filePath, err)
+	}
+
+	contentData, err := dw.processor.prepareFile(filePath)
+	if err != nil {
+		return err
+	}
+	if err := dw.spool.Add(filePath, contentData); err != nil {
+		return err
+	}
+	fmt.Printf("📦 Spooled %s until the API is reachable (%d waiting)\n", filePath, dw.spool.Len())
+	return nil
+}
+
+// openSpool opens the spool in GLOO_SPOOL_DIR, or returns nil if it isn't
+// set, along with how often to retry it
+func openSpool() (*Spool, time.Duration, error) {
+	dir := getEnv("GLOO_SPOOL_DIR", "")
+	if dir == "" {
+		return nil, 0, nil
+	}
+	interval, err := time.ParseDuration(getEnv("GLOO_SPOOL_RETRY_INTERVAL", "30s"))
+	if err != nil || interval <= 0 {
+		return nil, 0, fmt.Errorf("%w: GLOO_SPOOL_RETRY_INTERVAL must be a positive duration such as 30s", errConfig)
+	}
+	spool, err := OpenSpool(dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", errConfig, err)
+	}
+	return spool, interval, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// summarySystem is the system message for summary requests
+const summarySystem = "You write short, accurate summaries of documents for search results. Reply with only JSON."
+
+// summaryMaxWords caps the length of a summary
+const summaryMaxWords = 75
+
+// Where a Summarizer puts the summary
+const (
+	SummaryField   = "field"   // the item_summary field
+	SummaryTag     = "tag"     // a "summary: ..." tag
+	SummaryContent = "content" // a paragraph at the top of the content
+)
+
+// SummaryPromptData is what a summary prompt template can use
+type SummaryPromptData struct {
+	Title    string
+	Content  string // the first MaxTokens or so of the document
+	MaxWords int
+}
+
+// Summary is a summary reply, as cached
+type Summary struct {
+	Summary string `json:"summary"`
+}
+
+// Summarizer is an Enricher that asks Completions V2 for a 2-3 sentence
+// summary of each long document, for better search snippets
+type Summarizer struct {
+	processor *ContentProcessor
+	cache     *EnrichmentCache
+	prompt    *template.Template
+	maxTokens int    // how much of the document to send
+	minWords  int    // documents shorter than this aren't summarized
+	target    string // SummaryField, SummaryTag, or SummaryContent
+}
+
+// NewSummarizer creates a summarizer configured from the environment:
+// GLOO_SUMMARY_PROMPT_FILE, GLOO_SUMMARY_MAX_TOKENS, GLOO_SUMMARY_MIN_WORDS,
+// and GLOO_SUMMARY_TARGET
+func NewSummarizer(processor *ContentProcessor, cache *EnrichmentCache) (*Summarizer, error) {
+	prompt, err := loadPrompt("summary", getEnv("GLOO_SUMMARY_PROMPT_FILE", ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfig, err)
+	}
+	maxTokens, err := strconv.Atoi(getEnv("GLOO_SUMMARY_MAX_TOKENS", "3000"))
+	if err != nil || maxTokens < 1 {
+		return nil, fmt.Errorf("%w: GLOO_SUMMARY_MAX_TOKENS must be a positive number", errConfig)
+	}
+	minWords, err := strconv.Atoi(getEnv("GLOO_SUMMARY_MIN_WORDS", "300"))
+	if err != nil || minWords < 0 {
+		return nil, fmt.Errorf("%w: GLOO_SUMMARY_MIN_WORDS must be a number", errConfig)
+	}
+	target := strings.ToLower(getEnv("GLOO_SUMMARY_TARGET", SummaryField))
+	if target != SummaryField && target != SummaryTag && target != SummaryContent {
+		return nil, fmt.Errorf("%w: GLOO_SUMMARY_TARGET must be field, tag, or content, not %q", errConfig, target)
+	}
+
+	return &Summarizer{
+		processor: processor,
+		cache:     cache,
+		prompt:    prompt,
+		maxTokens: maxTokens,
+		minWords:  minWords,
+		target:    target,
+	}, nil
+}
+
+// Name identifies the enricher in warnings
+func (s *Summarizer) Name() string {
+	return "summary"
+}
+
+// Enrich summarizes a long document and stores the summary in the target.
+// Replies are cached by prompt, so an unchanged file isn't summarized twice.
+func (s *Summarizer) Enrich(data *ContentData) error {
+	if len(strings.Fields(data.Content)) < s.minWords {
+		return nil
+	}
+
+	prompt, err := renderPrompt(s.prompt, SummaryPromptData{
+		Title:    data.ItemTitle,
+		Content:  firstTokens(data.Content, s.maxTokens),
+		MaxWords: summaryMaxWords,
+	})
+	if err != nil {
+		return err
+	}
+
+	var summary Summary
+	key := cacheKey("summary", summarySystem, prompt)
+	cached := s.cache.Get(key, &summary) && summary.Summary != ""
+	if !cached {
+		if err := s.processor.CompleteJSON(summarySystem, prompt, 300, &summary); err != nil {
+			return err
+		}
+		summary.Summary = strings.Join(strings.Fields(summary.Summary), " ")
+		if summary.Summary == "" {
+			return fmt.Errorf("the reply had no summary")
+		}
+		if err := s.cache.Put(key, summary); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	}
+
+	switch s.target {
+	case SummaryTag:
+		data.ItemTags = mergeTags(data.ItemTags, []string{"summary: " + summary.Summary})
+	case SummaryContent:
+		data.Content = "Summary: " + summary.Summary + "\n\n" + data.Content
+	default:
+		data.ItemSummary = summary.Summary
+	}
+
+	source := "generated"
+	if cached {
+		source = "cached"
+	}
+	fmt.Printf("📝 Summarized %s (%s): %s\n", data.ItemTitle, source, summary.Summary)
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// tagSystem is the system message for tagging requests
+const tagSystem = "You catalog documents for a content library. Reply with only JSON."
+
+// defaultTagTypes are the content types the tagger may choose from
+const defaultTagTypes = "Article,Blog Post,Sermon,Devotional,Study Guide,Book Chapter,Transcript"
+
+// TagPromptData is what a tagging prompt template can use
+type TagPromptData struct {
+	Title   string
+	Content string // the first MaxTokens or so of the document
+	MaxTags int
+	Types   string // the allowed types, comma-separated
+}
+
+// Tagging is a tagging reply, as cached
+type Tagging struct {
+	Tags []string `json:"tags"`
+	Type string   `json:"type"`
+}
+
+// Tagger is an Enricher that asks Completions V2 for topic tags and a
+// content type, from a prompt template and the start of the document
+type Tagger struct {
+	processor *ContentProcessor
+	cache     *EnrichmentCache
+	prompt    *template.Template
+	maxTokens int      // how much of the document to send
+	maxTags   int      // how many tags to add
+	types     []string // the types the model may choose
+}
+
+// NewTagger creates a tagger configured from the environment:
+// GLOO_TAG_PROMPT_FILE, GLOO_TAG_MAX_TOKENS, GLOO_TAG_MAX_TAGS, and
+// GLOO_TAG_TYPES
+func NewTagger(processor *ContentProcessor, cache *EnrichmentCache) (*Tagger, error) {
+	prompt, err := loadPrompt("tagging", getEnv("GLOO_TAG_PROMPT_FILE", ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfig, err)
+	}
+	maxTokens, err := strconv.Atoi(getEnv("GLOO_TAG_MAX_TOKENS", "1000"))
+	if err != nil || maxTokens < 1 {
+		return nil, fmt.Errorf("%w: GLOO_TAG_MAX_TOKENS must be a positive number", errConfig)
+	}
+	maxTags, err := strconv.Atoi(getEnv("GLOO_TAG_MAX_TAGS", "6"))
+	if err != nil || maxTags < 1 {
+		return nil, fmt.Errorf("%w: GLOO_TAG_MAX_TAGS must be a positive number", errConfig)
+	}
+	var types []string
+	for _, t := range strings.Split(getEnv("GLOO_TAG_TYPES", defaultTagTypes), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("%w: GLOO_TAG_TYPES must list at least one type", errConfig)
+	}
+
+	return &Tagger{
+		processor: processor,
+		cache:     cache,
+		prompt:    prompt,
+		maxTokens: maxTokens,
+		maxTags:   maxTags,
+		types:     types,
+	}, nil
+}
+
+// Name identifies the enricher in warnings
+func (t *Tagger) Name() string {
+	return "tagging"
+}
+
+// Enrich adds generated tags to the upload's tags and sets its type, if
+// the model chose one of the allowed types. Replies are cached by prompt,
+// so an unchanged file isn't tagged twice.
+func (t *Tagger) Enrich(data *ContentData) error {
+	prompt, err := renderPrompt(t.prompt, TagPromptData{
+		Title:   data.ItemTitle,
+		Content: firstTokens(data.Content, t.maxTokens),
+		MaxTags: t.maxTags,
+		Types:   strings.Join(t.types, ", "),
+	})
+	if err != nil {
+		return err
+	}
+
+	var tagging Tagging
+	key := cacheKey("tags", tagSystem, prompt)
+	cached := t.cache.Get(key, &tagging)
+	if !cached {
+		if err := t.processor.CompleteJSON(tagSystem, prompt, 200, &tagging); err != nil {
+			return err
+		}
+		if err := t.cache.Put(key, tagging); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	}
+
+	tags := normalizeTags(tagging.Tags)
+	if len(tags) > t.maxTags {
+		tags = tags[:t.maxTags]
+	}
+	data.ItemTags = mergeTags(data.ItemTags, tags)
+	for _, allowed := range t.types {
+		if strings.EqualFold(strings.TrimSpace(tagging.Type), allowed) {
+			data.Type = allowed
+			break
+		}
+	}
+
+	source := "generated"
+	if cached {
+		source = "cached"
+	}
+	fmt.Printf("🏷️  Tagged %s (%s): %s; type %s\n", data.ItemTitle, source, strings.Join(tags, ", "), data.Type)
+	return nil
+}
+
+// normalizeTags lowercases tags, collapses their spaces, and drops empty
+// and repeated ones
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag != "" && !contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// mergeTags appends the tags in extra that aren't already in tags
+func mergeTags(tags, extra []string) []string {
+	merged := append([]string(nil), tags...)
+	for _, tag := range extra {
+		if !contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// metadataURL is the endpoint that changes an item's metadata in place
+const metadataURL = "https://platform.ai.gloo.com/engine/v2/item"
+
+// ItemMetadata is a metadata update for an item, found by its producer ID
+type ItemMetadata struct {
+	PublisherID string   `json:"publisher_id"`
+	ProducerID  string   `json:"producer_id"`
+	ItemTitle   string   `json:"item_title,omitempty"`
+	Author      []string `json:"author,omitempty"`
+	ItemTags    []string `json:"item_tags,omitempty"`
+	ItemSummary string   `json:"item_summary,omitempty"`
+}
+
+// producerIDFor returns the producer ID a file is uploaded under: its path
+// relative to GLOO_PRODUCER_ROOT (by default the current directory), with
+// forward slashes, or its absolute path if it's outside the root. The same
+// file gets the same ID from every command, so uploading it again
+// replaces the item. On Windows, a \\?\ long path gets the same ID as its
+// short form, and a drive letter is always upper case.
+func producerIDFor(path string) string {
+	abs, err := filepath.Abs(trimLongPathPrefix(path))
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	root := getEnv("GLOO_PRODUCER_ROOT", "")
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	if root, err = filepath.Abs(trimLongPathPrefix(root)); err == nil {
+		if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	if volume := filepath.VolumeName(abs); len(volume) == 2 && volume[1] == ':' {
+		abs = strings.ToUpper(volume) + abs[2:]
+	}
+	return filepath.ToSlash(abs)
+}
+
+// trimLongPathPrefix removes the \\?\ prefix Windows long paths carry
+func trimLongPathPrefix(path string) string {
+	if strings.HasPrefix(path, `\\?\UNC\`) {
+		return `\\` + path[len(`\\?\UNC\`):]
+	}
+	return strings.TrimPrefix(path, `\\?\`)
+}
+
+// UpdateMetadata changes the metadata of an uploaded item without
+// uploading its content again
+func (cp *ContentProcessor) UpdateMetadata(metadata *ItemMetadata) error {
+	token, err := cp.tokenManager.Token()
+	if errors.Is(err, errCredentialsRejected) {
+		return fmt.Errorf("%w: failed to get access token: %w", errAuth, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	payload, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	req, err := http.NewRequest("POST", metadataURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := cp.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: metadata update failed: %s - %s", errAuth, resp.Status, string(body))
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("metadata update failed: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// Update brings an uploaded file's item up to date. Changed content is
+// uploaded again under the same producer ID, which replaces the item;
+// unchanged content only has its metadata refreshed. args is the file,
+// then optionally --producer-id ID for an item the catalog doesn't know,
+// --force to upload even unchanged content, and --chunked to upload it in
+// chunks, only the changed ones of which are uploaded by later updates,
+// and --removed-deleted once the items of chunks no longer in the file
+// have been deleted. A file over the stream threshold is updated part by
+// part.
+func (app *Application) Update(args []string) error {
+	file, producerID, force, chunked, removedDeleted := "", "", false, false, false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--force":
+			force = true
+		case arg == "--chunked":
+			chunked = true
+		case arg == "--removed-deleted":
+			removedDeleted = true
+		case strings.HasPrefix(arg, "--producer-id="):
+			producerID = strings.TrimPrefix(arg, "--producer-id=")
+		case arg == "--producer-id":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%w: --producer-id needs an ID", errConfig)
+			}
+			i++
+			producerID = args[i]
+		case file == "":
+			file = arg
+		default:
+			return fmt.Errorf("%w: unexpected argument %q", errConfig, arg)
+		}
+	}
+	if file == "" {
+		return fmt.Errorf("%w: update needs a file", errConfig)
+	}
+
+	explicit := producerID != ""
+	if !explicit {
+		producerID = producerIDFor(file)
+	}
+	// A file over the stream threshold is updated in parts, as it was
+	// uploaded
+	if app.processor.streamed(file) {
+		return app.updateParts(file, producerID, force, removedDeleted)
+	}
+	// A file uploaded in chunks stays chunked
+	for slot := range app.catalog.Chunks(producerID) {
+		chunked = chunked || slot > 0
+	}
+	if chunked {
+		return app.updateChunks(file, producerID, force, removedDeleted)
+	}
+	current, found := app.catalog.Current(producerID)
+	if !found && !explicit {
+		return fmt.Errorf("%w: the catalog has no upload of %s under producer_id %q; upload it first with single or batch, or pass --producer-id", errConfig, file, producerID)
+	}
+
+	contentData, err := app.processor.prepareFile(file)
+	if err != nil {
+		return err
+	}
+	contentData.ProducerID = producerID
+
+	if found && !force && current.ContentSHA256 == contentHash(contentData.Content) {
+		err := app.processor.UpdateMetadata(&ItemMetadata{
+			PublisherID: contentData.PublisherID,
+			ProducerID:  producerID,
+			ItemTitle:   contentData.ItemTitle,
+			Author:      contentData.Author,
+			ItemTags:    contentData.ItemTags,
+			ItemSummary: contentData.ItemSummary,
+		})
+		if err != nil {
+			return err
+		}
+		if err := app.catalog.RecordMetadataUpdate(current.TaskID, contentData.ItemTitle); err != nil {
+			fmt.Printf("⚠️  Failed to record the update in the catalog: %v\n", err)
+		}
+		fmt.Printf("🔄 Content of %s is unchanged; refreshed its metadata (producer_id %s)\n", file, producerID)
+		return nil
+	}
+
+	result, err := app.processor.UploadContent(contentData)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	if err := app.catalog.RecordUpload(file, contentData, result); err != nil {
+		fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", contentData.ItemTitle, err)
+	}
+
+	fmt.Printf("🔄 Uploaded new content for %s (producer_id %s)\n", file, producerID)
+	fmt.Printf("   Response: %s\n", result.Message)
+	if found {
+		fmt.Printf("   Replaces task %s\n", current.TaskID)
+	}
+	fmt.Println("   Run 'go run . verify' once it has been processed to check it")
+	return nil
+}
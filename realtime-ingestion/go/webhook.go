@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// IngestionEvent is an ingestion status callback. Processing continues
+// after the Realtime API accepts an upload; the callbacks report its
+// progress by task ID. The Gloo AI API documentation doesn't define status
+// callbacks, so this format, and the signature checked with it, are this
+// receiver's own: a relay or signing proxy in front of it sends them.
+// Adapt both to whatever sends your callbacks.
+type IngestionEvent struct {
+	ID      string `json:"event_id"`
+	TaskID  string `json:"task_id"`
+	BatchID string `json:"batch_id"`
+	ItemID  string `json:"item_id"`
+	Title   string `json:"item_title"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// ContentSHA256 and ContentLength describe the processed content, if
+	// the sender includes them; they are checked against the upload
+	ContentSHA256 string `json:"content_sha256"`
+	ContentLength int    `json:"content_length"`
+}
+
+// Webhook signatures, in the receiver's own scheme rather than one the
+// Gloo AI platform defines
+const (
+	// signatureHeader carries "t=<unix time>,v1=<signature>", where the
+	// signature is the hex HMAC-SHA256 of "<unix time>.<body>" keyed with
+	// the webhook secret the sender and receiver share
+	signatureHeader = "X-Webhook-Signature"
+	// signatureTolerance is how old a signed callback may be, which stops
+	// a captured one from being replayed later
+	signatureTolerance = 5 * time.Minute
+	// maxWebhookBody caps callback bodies
+	maxWebhookBody = 1 << 20
+)
+
+// errInvalidSignature marks callbacks that fail signature verification
+var errInvalidSignature = errors.New("invalid webhook signature")
+
+// VerifySignature checks a callback's signature header against the body.
+// Any of secrets may have signed it, so the secret can be rotated without
+// rejecting callbacks during the switch.
+func VerifySignature(header string, body []byte, secrets []string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("%w: missing or malformed %s header", errInvalidSignature, signatureHeader)
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp %q", errInvalidSignature, timestamp)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return fmt.Errorf("%w: timestamp is %s from now, more than the %s allowed", errInvalidSignature, age.Round(time.Second), signatureTolerance)
+	}
+
+	for _, secret := range secrets {
+		expected := signPayload(secret, timestamp, body)
+		for _, signature := range signatures {
+			if hmac.Equal([]byte(signature), []byte(expected)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: no signature matches", errInvalidSignature)
+}
+
+// signPayload returns the hex HMAC-SHA256 signature of a callback
+func signPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notifier is told when an item finishes ingestion
+type Notifier interface {
+	Notify(item CatalogItem) error
+}
+
+// consoleNotifier prints finished items
+type consoleNotifier struct{}
+
+func (consoleNotifier) Notify(item CatalogItem) error {
+	fmt.Println(notificationText(item))
+	return nil
+}
+
+// chatNotifier posts finished items to an incoming webhook that takes
+// {"text": ...}, as Slack, Mattermost, and Microsoft Teams do
+type chatNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewChatNotifier creates a notifier that posts to url
+func NewChatNotifier(url string) Notifier {
+	return &chatNotifier{
+		url: url,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (n *chatNotifier) Notify(item CatalogItem) error {
+	payload, err := json.Marshal(map[string]string{"text": notificationText(item)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	resp, err := n.httpClient.Post(n.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("notification failed: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// notificationText describes a finished item
+func notificationText(item CatalogItem) string {
+	name := item.Title
+	if name == "" {
+		name = "task " + item.TaskID
+	}
+	if item.Status == StatusFailed {
+		text := fmt.Sprintf("❌ Ingestion failed: %s", name)
+		if item.Message != "" {
+			text += ": " + item.Message
+		}
+		return text
+	}
+	text := fmt.Sprintf("✅ Ingestion completed: %s", name)
+	if item.ItemID != "" {
+		text += fmt.Sprintf(" (item %s)", item.ItemID)
+	}
+	if item.Integrity != "" && item.Integrity != IntegrityOK {
+		text += fmt.Sprintf(" ⚠️ integrity check: %s", item.Integrity)
+	}
+	return text
+}
+
+// WebhookHandler receives ingestion status callbacks: it verifies their
+// signatures, updates the catalog, and notifies when an item finishes. It
+// can be mounted on any http.ServeMux.
+type WebhookHandler struct {
+	secrets   []string
+	catalog   *Catalog
+	notifiers []Notifier
+	// jobs, if set, finishes the job for each finished item
+	jobs *JobStore
+	// pending tracks notifications still being sent
+	pending sync.WaitGroup
+}
+
+// NewWebhookHandler creates a handler that accepts callbacks signed with
+// any of secrets
+func NewWebhookHandler(secrets []string, catalog *Catalog, notifiers ...Notifier) *WebhookHandler {
+	return &WebhookHandler{
+		secrets:   secrets,
+		catalog:   catalog,
+		notifiers: notifiers,
+	}
+}
+
+// ServeHTTP handles one callback. It answers 2xx only once the catalog is
+// saved, so the sender retries anything that wasn't recorded.
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := VerifySignature(r.Header.Get(signatureHeader), body, h.secrets, time.Now()); err != nil {
+		fmt.Printf("⚠️  Rejected webhook from %s: %v\n", r.RemoteAddr, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event IngestionEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	item, changed, err := h.catalog.ApplyEvent(&event)
+	if errors.Is(err, errInvalidEvent) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to record webhook for task %s: %v\n", event.TaskID, err)
+		http.Error(w, "failed to record event", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	if !changed {
+		return
+	}
+	fmt.Printf("📬 Task %s is %s\n", item.TaskID, item.Status)
+	if item.Finished() {
+		if h.jobs != nil {
+			if err := h.jobs.CompleteTask(item); err != nil {
+				fmt.Printf("⚠️  %v\n", err)
+			}
+		}
+		h.notify(item)
+	}
+}
+
+// notify sends the notifications in the background, so a slow chat service
+// doesn't hold up the callback's response
+func (h *WebhookHandler) notify(item CatalogItem) {
+	for _, n := range h.notifiers {
+		h.pending.Add(1)
+		go func(n Notifier) {
+			defer h.pending.Done()
+			if err := n.Notify(item); err != nil {
+				fmt.Printf("⚠️  Notification for task %s failed: %v\n", item.TaskID, err)
+			}
+		}(n)
+	}
+}
+
+// Wait blocks until every notification has been sent
+func (h *WebhookHandler) Wait() {
+	h.pending.Wait()
+}
+
+// ServeWebhooks receives callbacks at /webhooks/ingestion on addr until
+// SIGINT or SIGTERM, then finishes in-flight callbacks and notifications
+func ServeWebhooks(addr string, handler *WebhookHandler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/webhooks/ingestion", handler)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	fmt.Printf("📡 Receiving ingestion callbacks on %s at /webhooks/ingestion\n", addr)
+	fmt.Println("   Press Ctrl+C to stop")
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown failed: %w", err)
+	}
+	handler.Wait()
+	return nil
+}
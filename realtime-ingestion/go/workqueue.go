@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WorkJob is one unit of work on the shared queue: a file path, for workers
+// that share storage with the producer, or the upload payload itself
+type WorkJob struct {
+	ID      string       `json:"id"`
+	File    string       `json:"file,omitempty"`
+	Payload *ContentData `json:"payload,omitempty"`
+	// Source is where an inline payload came from, for logs and the catalog
+	Source string `json:"source,omitempty"`
+	// Attempts counts claims, including this one
+	Attempts int `json:"-"`
+}
+
+// WorkQueue is a work queue in Redis shared by a fleet of ingestion
+// workers. A claimed job is hidden from other workers for the visibility
+// timeout; if its worker dies before acknowledging it, the next claim
+// after the timeout puts it back on the queue. Jobs that fail maxAttempts
+// times are moved to a dead-letter list.
+//
+// The keys share a hash tag, so the Lua scripts work on Redis Cluster:
+//
+//	{prefix}:pending   list of job IDs waiting to be claimed
+//	{prefix}:inflight  sorted set of claimed job IDs, by claim deadline
+//	{prefix}:jobs      hash of job ID to job JSON
+//	{prefix}:attempts  hash of job ID to claim count
+//	{prefix}:dead      list of job IDs that failed too many times
+type WorkQueue struct {
+	client      *redis.Client
+	prefix      string
+	visibility  time.Duration
+	maxAttempts int
+}
+
+// NewWorkQueue creates a queue on the Redis server at redisURL, a
+// redis://[user:password@]host:port/db URL, or rediss:// for TLS. The
+// client keeps a pool of connections, replacing broken ones.
+func NewWorkQueue(redisURL, prefix string, visibility time.Duration, maxAttempts int) (*WorkQueue, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL %q (expected redis://host:port/db or rediss://host:port/db): %w", redisURL, err)
+	}
+	return &WorkQueue{
+		client:      redis.NewClient(options),
+		prefix:      prefix,
+		visibility:  visibility,
+		maxAttempts: maxAttempts,
+	}, nil
+}
+
+// key returns the Redis key for one of the queue's structures
+func (q *WorkQueue) key(name string) string {
+	return fmt.Sprintf("{%s}:%s", q.prefix, name)
+}
+
+// enqueueScript adds a job unless one with the same ID is already queued
+var enqueueScript = redis.NewScript(`
+if redis.call('HSETNX', KEYS[1], ARGV[1], ARGV[2]) == 1 then
+  redis.call('RPUSH', KEYS[2], ARGV[1])
+  return 1
+end
+return 0`)
+
+// claimScript first puts jobs whose claims expired back at the front of
+// the queue, then claims the next job. It uses the server's clock, so
+// workers' clocks don't need to agree.
+var claimScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now)) do
+  redis.call('ZREM', KEYS[2], id)
+  redis.call('LPUSH', KEYS[1], id)
+end
+while true do
+  local id = redis.call('LPOP', KEYS[1])
+  if not id then
+    return false
+  end
+  local job = redis.call('HGET', KEYS[3], id)
+  if job then
+    local attempts = redis.call('HINCRBY', KEYS[4], id, 1)
+    if attempts > tonumber(ARGV[2]) then
+      redis.call('RPUSH', KEYS[5], id)
+    else
+      redis.call('ZADD', KEYS[2], now + tonumber(ARGV[1]), id)
+      return {job, attempts}
+    end
+  end
+end`)
+
+// ackScript removes a finished job
+var ackScript = redis.NewScript(`
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return 1`)
+
+// failScript releases a failed job for another attempt, or moves it to the
+// dead-letter list once it has none left. It does nothing if the claim
+// already expired, since the job is back on the queue.
+var failScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+  return 0
+end
+if tonumber(redis.call('HGET', KEYS[3], ARGV[1]) or '0') >= tonumber(ARGV[2]) then
+  redis.call('RPUSH', KEYS[4], ARGV[1])
+  return 2
+end
+redis.call('RPUSH', KEYS[2], ARGV[1])
+return 1`)
+
+// eval runs a Lua script, sending it only if the server hasn't cached it
+func (q *WorkQueue) eval(script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	reply, err := script.Run(context.Background(), q.client, keys, args...).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return reply, err
+}
+
+// Enqueue adds a job, returning false if a job with the same ID is already
+// queued or in flight
+func (q *WorkQueue) Enqueue(job *WorkJob) (bool, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal job: %w", err)
+	}
+	reply, err := q.eval(enqueueScript, []string{q.key("jobs"), q.key("pending")}, job.ID, string(data))
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue %s: %w", job.ID, err)
+	}
+	return reply == int64(1), nil
+}
+
+// Claim takes the next job, or returns nil if the queue is empty
+func (q *WorkQueue) Claim() (*WorkJob, error) {
+	reply, err := q.eval(claimScript,
+		[]string{q.key("pending"), q.key("inflight"), q.key("jobs"), q.key("attempts"), q.key("dead")},
+		q.visibility.Milliseconds(), q.maxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim a job: %w", err)
+	}
+	fields, ok := reply.([]interface{})
+	if !ok || len(fields) != 2 {
+		return nil, nil
+	}
+	data, _ := fields[0].(string)
+	var job WorkJob
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job: %w", err)
+	}
+	attempts, _ := fields[1].(int64)
+	job.Attempts = int(attempts)
+	return &job, nil
+}
+
+// Ack removes a finished job
+func (q *WorkQueue) Ack(job *WorkJob) error {
+	if _, err := q.eval(ackScript, []string{q.key("inflight"), q.key("jobs"), q.key("attempts")}, job.ID); err != nil {
+		return fmt.Errorf("failed to acknowledge %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Fail releases a failed job for another attempt. dead reports whether it
+// had none left and was moved to the dead-letter list.
+func (q *WorkQueue) Fail(job *WorkJob) (dead bool, err error) {
+	reply, err := q.eval(failScript,
+		[]string{q.key("inflight"), q.key("pending"), q.key("attempts"), q.key("dead")},
+		job.ID, q.maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("failed to release %s: %w", job.ID, err)
+	}
+	return reply == int64(2), nil
+}
+
+// Stats returns the number of pending, in-flight, and dead jobs
+func (q *WorkQueue) Stats() (pending, inflight, dead int64, err error) {
+	ctx := context.Background()
+	pipe := q.client.Pipeline()
+	pendingCmd := pipe.LLen(ctx, q.key("pending"))
+	inflightCmd := pipe.ZCard(ctx, q.key("inflight"))
+	deadCmd := pipe.LLen(ctx, q.key("dead"))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read queue stats: %w", err)
+	}
+	return pendingCmd.Val(), inflightCmd.Val(), deadCmd.Val(), nil
+}
+
+// Close closes the Redis connections
+func (q *WorkQueue) Close() error {
+	return q.client.Close()
+}
+
+// NewFileJob creates a job for a file path. The ID covers the path, size,
+// and modification time, so a file is queued once until it changes.
+func NewFileJob(path string) (*WorkJob, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", abs, info.Size(), info.ModTime().UnixNano())))
+	return &WorkJob{ID: "file-" + hex.EncodeToString(sum[:8]), File: abs}, nil
+}
+
+// NewPayloadJob reads a file into an inline upload payload, for workers
+// that don't share the producer's storage or its file handlers. The ID
+// covers the content, so the same content is queued once.
+func (cp *ContentProcessor) NewPayloadJob(path string) (*WorkJob, error) {
+	handler, ok := cp.handlers.Lookup(path)
+	if !ok {
+		return nil, fmt.Errorf("unsupported file type: %s", path)
+	}
+	content, err := handler.text(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("file is empty: %s", path)
+	}
+	sum := sha256.Sum256([]byte(content))
+	payload := cp.CreateContentData(content, cp.ExtractTitleFromFilename(filepath.Base(path)))
+	payload.ProducerID = producerIDFor(path)
+	return &WorkJob{
+		ID:      "payload-" + hex.EncodeToString(sum[:8]),
+		Payload: payload,
+		Source:  path,
+	}, nil
+}
+
+// ProcessJob uploads a job's file or payload
+func (cp *ContentProcessor) ProcessJob(job *WorkJob) error {
+	if job.Payload == nil {
+		return cp.ProcessFile(job.File)
+	}
+	cp.enrich(job.Payload)
+
+	result, err := cp.UploadContent(job.Payload)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+
+	fmt.Printf("✅ Successfully uploaded: %s\n", job.Payload.ItemTitle)
+	fmt.Printf("   Response: %s\n", result.Message)
+
+	if cp.catalog != nil {
+		if err := cp.catalog.RecordUpload(job.Source, job.Payload, result); err != nil {
+			fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", job.Payload.ItemTitle, err)
+		}
+	}
+	return nil
+}
+
+// RunWorker claims and processes jobs until ctx is done. The job in hand
+// when ctx is cancelled is finished first.
+func (q *WorkQueue) RunWorker(ctx context.Context, processor *ContentProcessor) error {
+	idle := false
+	for ctx.Err() == nil {
+		job, err := q.Claim()
+		if err != nil {
+			fmt.Printf("⚠️  %v; retrying in 5s\n", err)
+			sleepContext(ctx, 5*time.Second)
+			continue
+		}
+		if job == nil {
+			if !idle {
+				fmt.Println("💤 Queue is empty; waiting for jobs")
+				idle = true
+			}
+			sleepContext(ctx, 2*time.Second)
+			continue
+		}
+		idle = false
+
+		name := job.File
+		if job.Payload != nil {
+			name = job.Source
+		}
+		fmt.Printf("📦 Claimed %s (attempt %d of %d)\n", name, job.Attempts, q.maxAttempts)
+
+		if err := processor.ProcessJob(job); err != nil {
+			fmt.Printf("❌ Failed to process %s: %v\n", name, err)
+			dead, err := q.Fail(job)
+			if err != nil {
+				// The claim expires and the job is retried anyway
+				fmt.Printf("⚠️  %v\n", err)
+			} else if dead {
+				fmt.Printf("☠️  Gave up on %s after %d attempts; moved to %s\n", name, job.Attempts, q.key("dead"))
+			}
+			continue
+		}
+		if err := q.Ack(job); err != nil {
+			// The job will be uploaded again once its claim expires
+			fmt.Printf("⚠️  %v\n", err)
+		}
+	}
+	return nil
+}
+
+// sleepContext sleeps for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
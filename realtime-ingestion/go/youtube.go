@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Defaults for the YouTube connector
+const (
+	defaultYouTubeInterval = 6 * time.Hour
+	youtubeAPIURL          = "https://www.googleapis.com/youtube/v3"
+	youtubeFeedURL         = "https://www.youtube.com/feeds/videos.xml"
+)
+
+// youtubeChannelPattern finds a channel ID or @handle in a channel URL
+var youtubeChannelPattern = regexp.MustCompile(`(UC[\w-]{22})|(@[\w.-]+)`)
+
+// youtubeVideo is a video in a channel's uploads
+type youtubeVideo struct {
+	ID        string
+	Title     string
+	Channel   string
+	Published time.Time
+}
+
+// URL returns the video's watch page
+func (v *youtubeVideo) URL() string {
+	return "https://www.youtube.com/watch?v=" + v.ID
+}
+
+// youtubeSource lists a channel's videos and downloads their captions.
+// With an API key, the YouTube Data API lists every upload; without one,
+// the channel's public feed lists its latest 15. Captions are downloaded
+// with yt-dlp, as the Data API only gives them to the channel's owner.
+type youtubeSource struct {
+	channel    string
+	apiKey     string
+	language   string
+	ytdlp      string
+	httpClient *http.Client
+}
+
+// apiGet calls a YouTube Data API method, decoding the reply into out
+func (s *youtubeSource) apiGet(method string, params url.Values, out interface{}) error {
+	params.Set("key", s.apiKey)
+	resp, err := s.httpClient.Get(youtubeAPIURL + "/" + method + "?" + params.Encode())
+	if err != nil {
+		return fmt.Errorf("YouTube API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read YouTube API response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusForbidden:
+		// A bad key, or the key's quota used up
+		return fmt.Errorf("%w: YouTube API returned %d: %s", errConfig, resp.StatusCode, body)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("YouTube API returned %d: %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid YouTube API response: %w", err)
+	}
+	return nil
+}
+
+// listVideos returns the channel's videos
+func (s *youtubeSource) listVideos() ([]youtubeVideo, error) {
+	if s.apiKey == "" {
+		return s.listFeed()
+	}
+
+	var channels struct {
+		Items []struct {
+			Snippet struct {
+				Title string `json:"title"`
+			} `json:"snippet"`
+			ContentDetails struct {
+				RelatedPlaylists struct {
+					Uploads string `json:"uploads"`
+				} `json:"relatedPlaylists"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+	params := url.Values{"part": {"snippet,contentDetails"}}
+	if strings.HasPrefix(s.channel, "@") {
+		params.Set("forHandle", s.channel)
+	} else {
+		params.Set("id", s.channel)
+	}
+	if err := s.apiGet("channels", params, &channels); err != nil {
+		return nil, err
+	}
+	if len(channels.Items) == 0 {
+		return nil, fmt.Errorf("%w: YouTube has no channel %s", errConfig, s.channel)
+	}
+	channelTitle := channels.Items[0].Snippet.Title
+
+	var videos []youtubeVideo
+	pageToken := ""
+	for {
+		var page struct {
+			NextPageToken string `json:"nextPageToken"`
+			Items         []struct {
+				Snippet struct {
+					Title string `json:"title"`
+				} `json:"snippet"`
+				ContentDetails struct {
+					VideoID          string `json:"videoId"`
+					VideoPublishedAt string `json:"videoPublishedAt"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+		}
+		params := url.Values{
+			"part":       {"snippet,contentDetails"},
+			"playlistId": {channels.Items[0].ContentDetails.RelatedPlaylists.Uploads},
+			"maxResults": {"50"},
+		}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+		if err := s.apiGet("playlistItems", params, &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			// Private and deleted videos are listed without a publish time
+			published, err := time.Parse(time.RFC3339, item.ContentDetails.VideoPublishedAt)
+			if err != nil {
+				continue
+			}
+			videos = append(videos, youtubeVideo{
+				ID:        item.ContentDetails.VideoID,
+				Title:     item.Snippet.Title,
+				Channel:   channelTitle,
+				Published: published,
+			})
+		}
+		if pageToken = page.NextPageToken; pageToken == "" {
+			return videos, nil
+		}
+	}
+}
+
+// listFeed returns the latest videos from the channel's Atom feed
+func (s *youtubeSource) listFeed() ([]youtubeVideo, error) {
+	resp, err := s.httpClient.Get(youtubeFeedURL + "?channel_id=" + url.QueryEscape(s.channel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download channel feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: YouTube has no channel %s", errConfig, s.channel)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("channel feed returned %d", resp.StatusCode)
+	}
+	var feed struct {
+		Title   string `xml:"title"`
+		Entries []struct {
+			VideoID   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+			Title     string `xml:"title"`
+			Published string `xml:"published"`
+		} `xml:"entry"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("invalid channel feed: %w", err)
+	}
+	var videos []youtubeVideo
+	for _, entry := range feed.Entries {
+		published, _ := time.Parse(time.RFC3339, entry.Published)
+		videos = append(videos, youtubeVideo{ID: entry.VideoID, Title: entry.Title, Channel: feed.Title, Published: published})
+	}
+	return videos, nil
+}
+
+// fetchCaptions downloads a video's captions in the source's language,
+// preferring ones the uploader wrote to automatic ones, and returns their
+// text, or "" if the video has none
+func (s *youtubeSource) fetchCaptions(video *youtubeVideo) (string, error) {
+	dir, err := ioutil.TempDir("", "gloo-youtube-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command(s.ytdlp, "--skip-download", "--no-warnings", "--quiet",
+		"--write-subs", "--write-auto-subs", "--sub-langs", s.language+","+s.language+"-.*",
+		"--sub-format", "vtt", "--output", filepath.Join(dir, "captions.%(ext)s"), video.URL())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s failed: %w: %s", s.ytdlp, err, strings.TrimSpace(string(out)))
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "captions.*.vtt"))
+	if err != nil || len(files) == 0 {
+		return "", nil
+	}
+	// captions.en.vtt sorts before captions.en-GB.vtt
+	sort.Strings(files)
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to read captions: %w", err)
+	}
+	text, _ := normalizeContent(data)
+	return captionsToText(text), nil
+}
+
+// videoContentData builds a video's payload: the transcript, after a header
+// with the video's title, channel, and URL, titled by the video, with the
+// channel as author and the publish date
+func (cp *ContentProcessor) videoContentData(video *youtubeVideo, transcript string) *ContentData {
+	header := fmt.Sprintf("Video: %s\nChannel: %s\nURL: %s\n\n", video.Title, video.Channel, video.URL())
+	data := cp.CreateContentData(header+transcript, video.Title)
+	data.Type = "Transcript"
+	data.ItemTags = append(data.ItemTags, "youtube")
+	if video.Channel != "" {
+		data.Author = []string{video.Channel}
+	}
+	if !video.Published.IsZero() {
+		data.PublicationDate = video.Published.Format("2006-01-02")
+	}
+	data.ProducerID = "youtube/" + video.ID
+	cp.enrich(data)
+	return data
+}
+
+// loadYouTubeState reads the IDs of the videos already ingested from each
+// channel
+func loadYouTubeState(path string) (map[string]map[string]bool, error) {
+	state := make(map[string]map[string]bool)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read YouTube state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse YouTube state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// YouTube ingests the captions of a channel's videos, then checks for new
+// videos every interval until stopped. args is the channel, as an ID,
+// @handle, or URL, then --once, --interval=DURATION, --limit=N, the most
+// videos to ingest in one check, and --lang=CODE, the caption language.
+func (app *Application) YouTube(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "--") {
+		return fmt.Errorf("%w: youtube needs a channel ID, @handle, or URL", errConfig)
+	}
+	channel := youtubeChannelPattern.FindString(args[0])
+	if channel == "" {
+		return fmt.Errorf("%w: no channel ID (UC...) or @handle in %q", errConfig, args[0])
+	}
+	source := &youtubeSource{
+		channel:    channel,
+		apiKey:     getEnv("YOUTUBE_API_KEY", ""),
+		language:   "en",
+		ytdlp:      getEnv("GLOO_YTDLP", "yt-dlp"),
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+	if strings.HasPrefix(channel, "@") && source.apiKey == "" {
+		return fmt.Errorf("%w: finding a channel by @handle needs YOUTUBE_API_KEY; pass the channel ID (UC...) instead", errConfig)
+	}
+	if _, err := exec.LookPath(source.ytdlp); err != nil {
+		return fmt.Errorf("%w: downloading captions needs yt-dlp (https://github.com/yt-dlp/yt-dlp) on the PATH, or its path in GLOO_YTDLP", errConfig)
+	}
+
+	once, interval, limit := false, defaultYouTubeInterval, 0
+	for _, arg := range args[1:] {
+		var err error
+		switch {
+		case arg == "--once":
+			once = true
+		case strings.HasPrefix(arg, "--interval="):
+			interval, err = time.ParseDuration(strings.TrimPrefix(arg, "--interval="))
+			if err != nil || interval <= 0 {
+				return fmt.Errorf("%w: --interval must be a positive duration, such as 6h", errConfig)
+			}
+		case strings.HasPrefix(arg, "--limit="):
+			limit, err = strconv.Atoi(strings.TrimPrefix(arg, "--limit="))
+			if err != nil || limit <= 0 {
+				return fmt.Errorf("%w: --limit must be a positive number of videos", errConfig)
+			}
+		case strings.HasPrefix(arg, "--lang="):
+			source.language = strings.TrimPrefix(arg, "--lang=")
+		default:
+			return fmt.Errorf("%w: unknown youtube option %q", errConfig, arg)
+		}
+	}
+
+	statePath := getEnv("GLOO_YOUTUBE_STATE", "youtube_state.json")
+	state, err := loadYouTubeState(statePath)
+	if err != nil {
+		return err
+	}
+	if state[channel] == nil {
+		state[channel] = make(map[string]bool)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("📺 Reading YouTube channel %s\n", channel)
+	if source.apiKey == "" {
+		fmt.Println("   Without YOUTUBE_API_KEY only the latest 15 videos are listed")
+	}
+	if !once {
+		fmt.Printf("   Checking every %s; press Ctrl+C to stop\n", interval)
+	}
+	for {
+		ingested, failed, err := app.youtubeOnce(ctx, source, state, statePath, limit)
+		switch {
+		case err != nil && (once || errors.Is(err, errConfig) || errors.Is(err, errAuth)):
+			return err
+		case err != nil:
+			fmt.Printf("⚠️  Channel check failed: %v; trying again in %s\n", err, interval)
+		case once && failed > 0:
+			return fmt.Errorf("%d videos failed to ingest; they're tried again on the next check", failed)
+		case ingested > 0 || failed > 0:
+			fmt.Printf("📺 Ingested %d videos, %d failed\n", ingested, failed)
+		}
+		if once {
+			return nil
+		}
+		sleepContext(ctx, interval)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// youtubeOnce ingests the channel's videos not ingested yet, oldest first.
+// A video is recorded as ingested once uploaded, so one that fails, or has
+// no captions yet, is tried again next time.
+func (app *Application) youtubeOnce(ctx context.Context, source *youtubeSource, state map[string]map[string]bool, statePath string, limit int) (ingested, failed int, err error) {
+	videos, err := source.listVideos()
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.SliceStable(videos, func(i, j int) bool { return videos[i].Published.Before(videos[j].Published) })
+
+	seen := state[source.channel]
+	uncaptioned := 0
+	defer func() {
+		if uncaptioned > 0 {
+			fmt.Printf("⏭️  Skipped %d videos with no %s captions yet\n", uncaptioned, source.language)
+		}
+	}()
+	for i := range videos {
+		video := &videos[i]
+		if seen[video.ID] {
+			continue
+		}
+		if ctx.Err() != nil || (limit > 0 && ingested+failed >= limit) {
+			break
+		}
+
+		transcript, err := source.fetchCaptions(video)
+		if err == nil && strings.TrimSpace(transcript) == "" {
+			uncaptioned++
+			continue
+		}
+		if err == nil {
+			contentData := app.processor.videoContentData(video, transcript)
+			var result *ApiResponse
+			if result, err = app.processor.UploadContent(contentData); err == nil {
+				fmt.Printf("✅ Successfully uploaded: %s\n", contentData.ItemTitle)
+				if app.catalog != nil {
+					if err := app.catalog.RecordUpload(video.URL(), contentData, result); err != nil {
+						fmt.Printf("⚠️  Failed to record %s in the catalog: %v\n", contentData.ItemTitle, err)
+					}
+				}
+			} else {
+				err = fmt.Errorf("upload failed: %w", err)
+			}
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to ingest %s: %v\n", video.Title, err)
+			failed++
+			if errors.Is(err, errAuth) {
+				return ingested, failed, err
+			}
+			continue
+		}
+		ingested++
+		seen[video.ID] = true
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return ingested, failed, fmt.Errorf("failed to marshal YouTube state: %w", err)
+		}
+		if err := ioutil.WriteFile(statePath, data, 0644); err != nil {
+			return ingested, failed, fmt.Errorf("failed to save YouTube state: %w", err)
+		}
+	}
+	return ingested, failed, nil
+}
@@ -0,0 +1,399 @@
+// Gloo AI Search API - Saved Searches and Alerts
+//
+// Saves queries by name and re-runs them on an interval, reporting items
+// that newly match above a certainty threshold. After a big ingestion batch,
+// this shows which new content reaches the queries that matter.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// alertDefaultCertainty is the threshold for saved searches saved without
+// --min-certainty.
+const alertDefaultCertainty = 0.7
+
+// alertWebhookTimeout is how long a webhook notification may take.
+const alertWebhookTimeout = 10 * time.Second
+
+// SavedSearch is a query saved by name, with the items it has already
+// reported.
+type SavedSearch struct {
+	Name         string    `json:"name"`
+	Query        string    `json:"query"`
+	Limit        int       `json:"limit"`
+	MinCertainty float64   `json:"min_certainty"`
+	Seen         []string  `json:"seen"` // UUIDs of items already reported
+	CreatedAt    time.Time `json:"created_at"`
+	LastRunAt    time.Time `json:"last_run_at,omitempty"`
+}
+
+// SavedSearchStore keeps saved searches in a JSON file.
+type SavedSearchStore struct {
+	path     string
+	searches map[string]*SavedSearch
+}
+
+// OpenSavedSearches loads the saved searches at path, or starts with none if
+// the file doesn't exist yet.
+func OpenSavedSearches(path string) (*SavedSearchStore, error) {
+	store := &SavedSearchStore{path: path, searches: map[string]*SavedSearch{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved searches: %w", err)
+	}
+
+	var searches []*SavedSearch
+	if err := json.Unmarshal(data, &searches); err != nil {
+		return nil, fmt.Errorf("failed to parse saved searches %s: %w", path, err)
+	}
+	for _, s := range searches {
+		store.searches[s.Name] = s
+	}
+	return store, nil
+}
+
+// List returns the saved searches by name.
+func (st *SavedSearchStore) List() []*SavedSearch {
+	list := make([]*SavedSearch, 0, len(st.searches))
+	for _, s := range st.searches {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Save writes the saved searches to a temporary file and renames it into
+// place, so an interrupted write never loses them.
+func (st *SavedSearchStore) Save() error {
+	data, err := json.MarshalIndent(st.List(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved searches: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(st.path), ".saved-searches-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save searches: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save searches: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save searches: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), st.path); err != nil {
+		return fmt.Errorf("failed to save searches: %w", err)
+	}
+	return nil
+}
+
+// AlertItem is a newly matching item in an alert.
+type AlertItem struct {
+	UUID      string  `json:"uuid"`
+	Title     string  `json:"title"`
+	Type      string  `json:"type"`
+	Certainty float64 `json:"certainty"`
+}
+
+// Alert reports the new items for one saved search.
+type Alert struct {
+	Search    string      `json:"search"`
+	Query     string      `json:"query"`
+	NewItems  []AlertItem `json:"new_items"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// checkSavedSearch runs a saved search and returns the items above its
+// threshold that it hasn't reported before.
+func checkSavedSearch(ctx context.Context, sc *SearchClient, s *SavedSearch) ([]SearchResult, error) {
+	results, err := sc.Search(ctx, s.Query, s.Limit)
+	if err != nil {
+		return nil, err
+	}
+	results = sc.FilterByCertainty(results, s.MinCertainty)
+
+	seen := make(map[string]bool, len(s.Seen))
+	for _, uuid := range s.Seen {
+		seen[uuid] = true
+	}
+	var fresh []SearchResult
+	for _, r := range results.Data {
+		if r.UUID != "" && !seen[r.UUID] {
+			seen[r.UUID] = true
+			fresh = append(fresh, r)
+		}
+	}
+	return fresh, nil
+}
+
+// markSeen records results as reported.
+func (s *SavedSearch) markSeen(results []SearchResult) {
+	for _, r := range results {
+		s.Seen = append(s.Seen, r.UUID)
+	}
+}
+
+// newAlert builds the alert for a saved search's new items.
+func newAlert(s *SavedSearch, fresh []SearchResult) Alert {
+	alert := Alert{Search: s.Name, Query: s.Query, CheckedAt: time.Now().UTC()}
+	for _, r := range fresh {
+		alert.NewItems = append(alert.NewItems, AlertItem{
+			UUID:      r.UUID,
+			Title:     r.Properties.ItemTitle,
+			Type:      r.Properties.Type,
+			Certainty: r.Metadata.Certainty,
+		})
+	}
+	return alert
+}
+
+// printAlert prints an alert to stdout.
+func printAlert(alert Alert) {
+	fmt.Printf("[%s] %d new results for '%s' (%s):\n",
+		alert.CheckedAt.Local().Format("2006-01-02 15:04:05"), len(alert.NewItems), alert.Search, alert.Query)
+	for _, item := range alert.NewItems {
+		fmt.Printf("  - %s (%s) certainty %.4f\n", item.Title, item.Type, item.Certainty)
+	}
+}
+
+// sendAlertWebhook posts an alert as JSON to url.
+func sendAlertWebhook(ctx context.Context, url string, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, alertWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// runAlerts checks every saved search and notifies about new items. An item
+// is only marked as reported once its notifications went out, so a failed
+// webhook is retried on the next run.
+func runAlerts(ctx context.Context, sc *SearchClient, store *SavedSearchStore, webhookURL string) {
+	for _, s := range store.List() {
+		fresh, err := checkSavedSearch(ctx, sc, s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Saved search '%s' failed: %v\n", s.Name, err)
+			continue
+		}
+		s.LastRunAt = time.Now().UTC()
+
+		if len(fresh) > 0 {
+			alert := newAlert(s, fresh)
+			printAlert(alert)
+			if webhookURL != "" {
+				if err := sendAlertWebhook(ctx, webhookURL, alert); err != nil {
+					fmt.Fprintf(os.Stderr, "Alert webhook for '%s' failed, will retry: %v\n", s.Name, err)
+					continue
+				}
+			}
+			s.markSeen(fresh)
+		}
+	}
+
+	if err := store.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
+// isSavedSearchCommand reports whether the search command's first argument
+// is one of the saved search subcommands.
+func isSavedSearchCommand(arg string) bool {
+	switch arg {
+	case "save", "saved", "delete", "alerts":
+		return true
+	}
+	return false
+}
+
+// handleSavedSearchCommand runs "search save", "search saved",
+// "search delete", or "search alerts".
+func handleSavedSearchCommand(args []string) {
+	store, err := OpenSavedSearches(getEnv("SAVED_SEARCHES_FILE", "saved_searches.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sc := &SearchClient{TokenManager: NewTokenManager(clientID, clientSecret, tokenURL)}
+
+	switch args[0] {
+	case "save":
+		saveSearch(sc, store, args[1:])
+	case "saved":
+		listSavedSearches(store)
+	case "delete":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Error: Name of the saved search required")
+			printUsage()
+			os.Exit(1)
+		}
+		if _, ok := store.searches[args[1]]; !ok {
+			fmt.Fprintf(os.Stderr, "Error: No saved search named '%s'\n", args[1])
+			os.Exit(1)
+		}
+		delete(store.searches, args[1])
+		if err := store.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted saved search '%s'\n", args[1])
+	case "alerts":
+		watchSavedSearches(sc, store, args[1:])
+	}
+}
+
+// saveSearch saves a query by name. The items it matches now are marked as
+// seen, so alerts only report items that appear later.
+func saveSearch(sc *SearchClient, store *SavedSearchStore, args []string) {
+	positional, opts, err := parseSearchArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printUsage()
+		os.Exit(1)
+	}
+	if len(positional) < 2 {
+		fmt.Fprintln(os.Stderr, "Error: A name and a query are required to save a search")
+		printUsage()
+		os.Exit(1)
+	}
+
+	limit := 10
+	if len(positional) > 2 {
+		limit = parseLimitArg(positional[2], 10)
+	}
+	s := &SavedSearch{
+		Name:         positional[0],
+		Query:        positional[1],
+		Limit:        normalizeLimit(limit, 10, 1, 100),
+		MinCertainty: opts.MinCertainty,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if s.MinCertainty == 0 {
+		s.MinCertainty = alertDefaultCertainty
+	}
+
+	current, err := checkSavedSearch(context.Background(), sc, s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
+		os.Exit(1)
+	}
+	s.markSeen(current)
+	s.LastRunAt = time.Now().UTC()
+
+	_, replaced := store.searches[s.Name]
+	store.searches[s.Name] = s
+	if err := store.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	verb := "Saved"
+	if replaced {
+		verb = "Replaced"
+	}
+	fmt.Printf("%s search '%s': '%s' (limit %d, min certainty %.2f)\n", verb, s.Name, s.Query, s.Limit, s.MinCertainty)
+	fmt.Printf("%d current results marked as seen; alerts will report new ones.\n", len(current))
+}
+
+// listSavedSearches prints the saved searches.
+func listSavedSearches(store *SavedSearchStore) {
+	searches := store.List()
+	if len(searches) == 0 {
+		fmt.Println("No saved searches. Save one with: go run . search save <name> <query>")
+		return
+	}
+	for _, s := range searches {
+		lastRun := "never"
+		if !s.LastRunAt.IsZero() {
+			lastRun = s.LastRunAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s: '%s' (limit %d, min certainty %.2f, %d seen, last run %s)\n",
+			s.Name, s.Query, s.Limit, s.MinCertainty, len(s.Seen), lastRun)
+	}
+}
+
+// watchSavedSearches re-runs the saved searches every interval until
+// SIGINT or SIGTERM, or once with --once.
+func watchSavedSearches(sc *SearchClient, store *SavedSearchStore, args []string) {
+	intervalText := getEnv("ALERT_INTERVAL", "5m")
+	webhookURL := getEnv("ALERT_WEBHOOK_URL", "")
+	once := false
+
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--interval="):
+			intervalText = strings.TrimPrefix(arg, "--interval=")
+		case strings.HasPrefix(arg, "--webhook="):
+			webhookURL = strings.TrimPrefix(arg, "--webhook=")
+		case arg == "--once":
+			once = true
+		default:
+			fmt.Fprintf(os.Stderr, "Error: Unknown alerts option '%s'\n", arg)
+			printUsage()
+			os.Exit(1)
+		}
+	}
+	interval, err := time.ParseDuration(intervalText)
+	if err != nil || interval < time.Second {
+		fmt.Fprintf(os.Stderr, "Error: the alert interval must be a duration of at least 1s, such as 10m, got '%s'\n", intervalText)
+		os.Exit(1)
+	}
+
+	if len(store.searches) == 0 {
+		fmt.Println("No saved searches. Save one with: go run . search save <name> <query>")
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !once {
+		fmt.Printf("Checking %d saved searches every %s (Ctrl+C to stop)\n", len(store.searches), interval)
+	}
+	for {
+		runAlerts(ctx, sc, store, webhookURL)
+		if once {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopped checking saved searches")
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -4,12 +4,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,37 +24,58 @@ type TokenInfo struct {
 	TokenType   string `json:"token_type"`
 }
 
-// TokenManager manages OAuth2 token lifecycle.
+// TokenManager manages OAuth2 token lifecycle. It is safe for concurrent
+// use, so parallel searches share one token fetch.
 type TokenManager struct {
 	ClientID     string
 	ClientSecret string
 	TokenURL     string
-	tokenInfo    *TokenInfo
+	Scope        string // space-separated scopes to request
+	Audience     string // sent only if set
+
+	mu        sync.Mutex
+	tokenInfo *TokenInfo
 }
 
-// NewTokenManager creates a new TokenManager.
+// NewTokenManager creates a new TokenManager. The scope is
+// GLOO_SEARCH_SCOPE, for search-only credentials, else GLOO_SCOPE, else
+// api/access; the audience is GLOO_AUDIENCE.
 func NewTokenManager(clientID, clientSecret, tokenURL string) *TokenManager {
 	return &TokenManager{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		TokenURL:     tokenURL,
+		Scope:        getEnv("GLOO_SEARCH_SCOPE", getEnv("GLOO_SCOPE", "api/access")),
+		Audience:     getEnv("GLOO_AUDIENCE", ""),
 	}
 }
 
 // GetAccessToken retrieves a new access token from the OAuth2 endpoint.
 func (tm *TokenManager) GetAccessToken() (*TokenInfo, error) {
-	body := strings.NewReader("grant_type=client_credentials&scope=api/access")
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.refresh()
+}
+
+// refresh fetches a new token and stores it. The caller holds tm.mu.
+func (tm *TokenManager) refresh() (*TokenInfo, error) {
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {tm.Scope}}
+	if tm.Audience != "" {
+		form.Set("audience", tm.Audience)
+	}
 
-	req, err := http.NewRequest("POST", tm.TokenURL, body)
+	// The token is shared by every request, so its fetch isn't tied to
+	// the one that happened to need it
+	req, cancel, err := apiRequest(context.Background(), OpAuth, "POST", tm.TokenURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token request: %w", err)
 	}
+	defer cancel()
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.SetBasicAuth(tm.ClientID, tm.ClientSecret)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to obtain access token: %w", err)
 	}
@@ -74,6 +98,14 @@ func (tm *TokenManager) GetAccessToken() (*TokenInfo, error) {
 
 // IsTokenExpired checks if the token is expired or close to expiring.
 func (tm *TokenManager) IsTokenExpired() bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.expired()
+}
+
+// expired reports whether the token needs refreshing. The caller holds
+// tm.mu.
+func (tm *TokenManager) expired() bool {
 	if tm.tokenInfo == nil {
 		return true
 	}
@@ -82,8 +114,10 @@ func (tm *TokenManager) IsTokenExpired() bool {
 
 // EnsureValidToken ensures we have a valid access token and returns it.
 func (tm *TokenManager) EnsureValidToken() (string, error) {
-	if tm.IsTokenExpired() {
-		if _, err := tm.GetAccessToken(); err != nil {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.expired() {
+		if _, err := tm.refresh(); err != nil {
 			return "", err
 		}
 	}
@@ -0,0 +1,73 @@
+// Gloo AI Search API - Batch Search
+//
+// Runs several searches for a single proxy request with bounded concurrency
+// upstream, so a dashboard can populate multiple widgets in one round trip.
+package main
+
+import "sync"
+
+// BatchSearchQuery is a single query in a batch search request.
+type BatchSearchQuery struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+// BatchSearchRequest is the JSON body for the batch search endpoint.
+type BatchSearchRequest struct {
+	Queries []BatchSearchQuery `json:"queries"`
+}
+
+// BatchSearchResult is the outcome of one query in a batch. Exactly one of
+// Results or Error is set.
+type BatchSearchResult struct {
+	Query   string          `json:"query"`
+	Limit   int             `json:"limit"`
+	Results *SearchResponse `json:"results,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// BatchSearchResponse is the JSON response from the batch search endpoint.
+// Results are in the same order as the request's queries.
+type BatchSearchResponse struct {
+	Results []BatchSearchResult `json:"results"`
+}
+
+// runBatchSearch executes queries with at most concurrency searches in
+// flight. A failing query is reported in its own result and does not affect
+// the others.
+func runBatchSearch(queries []BatchSearchQuery, concurrency int, search func(query string, limit int) (*SearchResponse, error)) []BatchSearchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]BatchSearchResult, len(queries))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, q := range queries {
+		limit := normalizeLimit(q.Limit, 10, 1, maxSearchLimit)
+		results[i] = BatchSearchResult{Query: q.Query, Limit: limit}
+
+		if q.Query == "" {
+			results[i].Error = "Field 'query' is required"
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, query string, limit int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			resp, err := search(query, limit)
+			if err != nil {
+				results[i].Error = "Search request failed"
+				return
+			}
+			results[i].Results = resp
+		}(i, q.Query, limit)
+	}
+
+	wg.Wait()
+	return results
+}
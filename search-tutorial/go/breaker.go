@@ -0,0 +1,211 @@
+// Gloo AI Search API - Circuit Breaker
+//
+// Stops the proxy server calling a Gloo API that keeps failing. After a run
+// of consecutive failures of one kind of call, its circuit opens and those
+// calls fail at once, so users get a quick "try again shortly" instead of
+// each waiting out the timeout. After a cooldown, one probe call is let
+// through: if it succeeds the circuit closes, otherwise it stays open for
+// another cooldown.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call until the cooldown has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets one probe call through to test the upstream.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitOpenError is returned for a call refused by an open circuit.
+// RetryAfter is how long until the next probe.
+type CircuitOpenError struct {
+	Op         Operation
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s circuit is open after repeated failures", e.Op)
+}
+
+// circuitOpenMessage is shown to proxy clients while a circuit is open.
+const circuitOpenMessage = "The Gloo AI service is temporarily unavailable. Please try again in a moment."
+
+// CircuitBreaker tracks the failures of one kind of call.
+type CircuitBreaker struct {
+	// Threshold is how many consecutive failures open the circuit.
+	Threshold int
+	// Cooldown is how long the circuit stays open before a probe.
+	Cooldown time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// Allow reports whether a call may go ahead, and if not, how long until the
+// next probe. A call that was allowed must be followed by Record or Cancel.
+func (b *CircuitBreaker) Allow() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen {
+		wait := b.Cooldown - time.Since(b.openedAt)
+		if wait > 0 {
+			return wait, false
+		}
+		b.state = CircuitHalfOpen
+	}
+	if b.state == CircuitHalfOpen {
+		if b.probing {
+			return b.Cooldown, false
+		}
+		b.probing = true
+	}
+	return 0, true
+}
+
+// Record reports the outcome of an allowed call.
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+
+	if !failed {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.Threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Cancel reports that an allowed call ended without telling whether the
+// upstream is healthy, for example because the proxy's client disconnected.
+func (b *CircuitBreaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State returns the breaker's current state.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.Cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// CircuitBreakers holds a breaker for each kind of Gloo API call, so an
+// outage of completions doesn't stop searches. A nil CircuitBreakers breaks
+// nothing.
+type CircuitBreakers struct {
+	breakers map[Operation]*CircuitBreaker
+}
+
+// circuitOperations are the calls that get a breaker, in metrics order.
+var circuitOperations = []Operation{OpAuth, OpSearch, OpCompletion, OpChat}
+
+// NewCircuitBreakers creates a breaker for each kind of call.
+func NewCircuitBreakers(threshold int, cooldown time.Duration) *CircuitBreakers {
+	c := &CircuitBreakers{breakers: make(map[Operation]*CircuitBreaker)}
+	for _, op := range circuitOperations {
+		c.breakers[op] = &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+	}
+	return c
+}
+
+// Transport wraps next, which may be nil for http.DefaultTransport, so that
+// calls made with apiRequest go through their operation's breaker.
+func (c *CircuitBreakers) Transport(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &breakerTransport{breakers: c, next: next}
+}
+
+// WriteMetrics writes each breaker's state in the Prometheus text format.
+func (c *CircuitBreakers) WriteMetrics(w io.Writer) {
+	if c == nil {
+		return
+	}
+	fmt.Fprintln(w, "# HELP gloo_proxy_circuit_state Circuit breaker state by operation (0 closed, 1 open, 2 half-open).")
+	fmt.Fprintln(w, "# TYPE gloo_proxy_circuit_state gauge")
+	for _, op := range circuitOperations {
+		fmt.Fprintf(w, "gloo_proxy_circuit_state{op=%q} %d\n", op, c.breakers[op].State())
+	}
+}
+
+// breakerTransport is an http.RoundTripper that counts 5xx responses and
+// transport errors, including timeouts, as failures.
+type breakerTransport struct {
+	breakers *CircuitBreakers
+	next     http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	op, _ := req.Context().Value(operationKey{}).(Operation)
+	b := t.breakers.breakers[op]
+	if b == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	if wait, ok := b.Allow(); !ok {
+		return nil, &CircuitOpenError{Op: op, RetryAfter: wait}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		b.Cancel()
+	case err != nil:
+		b.Record(true)
+	default:
+		b.Record(resp.StatusCode >= 500)
+	}
+	return resp, err
+}
+
+// newCircuitBreakersFromEnv returns the circuit breakers configured by the
+// environment, or nil when they are disabled.
+func newCircuitBreakersFromEnv() *CircuitBreakers {
+	threshold := getEnvInt("CIRCUIT_BREAKER_FAILURES", 5)
+	if threshold <= 0 {
+		return nil
+	}
+	return NewCircuitBreakers(threshold, time.Duration(getEnvInt("CIRCUIT_BREAKER_COOLDOWN_SECONDS", 30))*time.Second)
+}
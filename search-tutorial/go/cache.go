@@ -0,0 +1,151 @@
+// Gloo AI Search API - Response Cache
+//
+// An in-memory LRU cache with TTL for proxy search responses, so popular
+// queries from the frontend don't hit the Search API on every request.
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache status values reported in the X-Cache response header.
+const (
+	CacheHit   = "HIT"
+	CacheMiss  = "MISS"
+	CacheStale = "STALE"
+)
+
+// searchCacheEntry is a single cached search response.
+type searchCacheEntry struct {
+	key      string
+	value    *SearchResponse
+	storedAt time.Time
+}
+
+// SearchCache is an LRU cache of search responses keyed on query and limit.
+//
+// Entries are fresh for TTL. When StaleTTL is greater than zero, entries
+// older than TTL but younger than TTL+StaleTTL are still served while a
+// background refresh replaces them (stale-while-revalidate).
+type SearchCache struct {
+	Capacity int
+	TTL      time.Duration
+	StaleTTL time.Duration
+
+	mu         sync.Mutex
+	ll         *list.List
+	items      map[string]*list.Element
+	refreshing map[string]bool
+}
+
+// NewSearchCache creates a new SearchCache.
+func NewSearchCache(capacity int, ttl, staleTTL time.Duration) *SearchCache {
+	return &SearchCache{
+		Capacity:   capacity,
+		TTL:        ttl,
+		StaleTTL:   staleTTL,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		refreshing: make(map[string]bool),
+	}
+}
+
+// searchCacheKey normalizes a tenant, query, and limit into a cache key.
+func searchCacheKey(tenant, query string, limit int) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	return fmt.Sprintf("%s|%d|%s", tenant, limit, normalized)
+}
+
+// Fetch returns the cached response for a tenant's query and limit, calling
+// fetch on a miss. The returned status is one of CacheHit, CacheMiss, or
+// CacheStale.
+func (c *SearchCache) Fetch(tenant, query string, limit int, fetch func() (*SearchResponse, error)) (*SearchResponse, string, error) {
+	key := searchCacheKey(tenant, query, limit)
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*searchCacheEntry)
+		age := time.Since(entry.storedAt)
+
+		if age <= c.TTL {
+			c.ll.MoveToFront(el)
+			c.mu.Unlock()
+			return entry.value, CacheHit, nil
+		}
+
+		if c.StaleTTL > 0 && age <= c.TTL+c.StaleTTL {
+			c.ll.MoveToFront(el)
+			if !c.refreshing[key] {
+				c.refreshing[key] = true
+				go c.refresh(tenant, key, fetch)
+			}
+			c.mu.Unlock()
+			return entry.value, CacheStale, nil
+		}
+
+		c.removeElement(el)
+	}
+	c.mu.Unlock()
+
+	value, err := fetch()
+	if err != nil {
+		return nil, CacheMiss, err
+	}
+
+	c.add(key, value)
+	return value, CacheMiss, nil
+}
+
+// refresh re-fetches a stale entry in the background. Failures are logged
+// and the stale entry is kept until it expires.
+func (c *SearchCache) refresh(tenant, key string, fetch func() (*SearchResponse, error)) {
+	value, err := fetch()
+
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	c.mu.Unlock()
+
+	if err != nil {
+		logEvent(EventLogEntry{Event: "search_cache_refresh", Tenant: tenant, CacheKey: key, Error: err.Error()})
+		return
+	}
+	c.add(key, value)
+}
+
+// add stores a response, evicting the least recently used entry when full.
+func (c *SearchCache) add(key string, value *SearchResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*searchCacheEntry)
+		entry.value = value
+		entry.storedAt = time.Now()
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&searchCacheEntry{key: key, value: value, storedAt: time.Now()})
+	c.items[key] = el
+
+	for c.Capacity > 0 && c.ll.Len() > c.Capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// removeElement removes an entry. The caller must hold c.mu.
+func (c *SearchCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*searchCacheEntry).key)
+}
+
+// Len returns the number of cached entries.
+func (c *SearchCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
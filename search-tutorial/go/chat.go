@@ -0,0 +1,170 @@
+// Gloo AI Search API - Chat Client
+//
+// Client for the Gloo AI Chat (message and history) APIs, following the
+// chat-tutorial's sendMessage/getChatHistory flow, so the proxy server can
+// offer a full chat experience without exposing credentials to the browser.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+var (
+	messageURL = "https://platform.ai.gloo.com/ai/v1/message"
+	chatURL    = "https://platform.ai.gloo.com/ai/v1/chat"
+)
+
+// MessageRequest is the request payload for the Message API.
+type MessageRequest struct {
+	Query             string   `json:"query"`
+	CharacterLimit    int      `json:"character_limit,omitempty"`
+	SourcesLimit      int      `json:"sources_limit,omitempty"`
+	Stream            bool     `json:"stream,omitempty"`
+	Publishers        []string `json:"publishers,omitempty"`
+	ChatID            string   `json:"chat_id,omitempty"`
+	EnableSuggestions int      `json:"enable_suggestions,omitempty"`
+}
+
+// MessageResponse is the response from the Message API.
+type MessageResponse struct {
+	ChatID      string   `json:"chat_id"`
+	QueryID     string   `json:"query_id"`
+	MessageID   string   `json:"message_id"`
+	Message     string   `json:"message"`
+	Timestamp   string   `json:"timestamp"`
+	Success     bool     `json:"success"`
+	Suggestions []string `json:"suggestions"`
+	Sources     []any    `json:"sources"`
+	// Safety is set by the proxy, not the Message API, when the safety
+	// policy flagged the message and answered with helpline resources.
+	Safety bool `json:"safety,omitempty"`
+}
+
+// ChatMessage is a single message in a chat's history.
+type ChatMessage struct {
+	QueryID        string `json:"query_id"`
+	MessageID      string `json:"message_id"`
+	Timestamp      string `json:"timestamp"`
+	Role           string `json:"role"`
+	Message        string `json:"message"`
+	CharacterLimit *int   `json:"character_limit,omitempty"`
+}
+
+// ChatHistory is the response from the Chat API.
+type ChatHistory struct {
+	ChatID    string        `json:"chat_id"`
+	CreatedAt string        `json:"created_at"`
+	Messages  []ChatMessage `json:"messages"`
+}
+
+// UpstreamError is a non-2xx response from a Gloo API.
+type UpstreamError struct {
+	Op         string
+	StatusCode int
+	Body       string
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// ChatMessageRequest is the JSON body for the proxy's chat message endpoint.
+type ChatMessageRequest struct {
+	Message string `json:"message"`
+}
+
+// ChatClient handles chat requests.
+type ChatClient struct {
+	TokenManager *TokenManager
+}
+
+// SendMessage sends a message, starting a new chat when chatID is empty.
+func (cc *ChatClient) SendMessage(ctx context.Context, message, chatID string) (*MessageResponse, error) {
+	token, err := cc.TokenManager.EnsureValidToken()
+	if err != nil {
+		return nil, err
+	}
+
+	payload := MessageRequest{
+		Query:             message,
+		CharacterLimit:    1000,
+		SourcesLimit:      5,
+		ChatID:            chatID,
+		EnableSuggestions: 1,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message request: %w", err)
+	}
+
+	req, cancel, err := apiRequest(ctx, OpChat, "POST", messageURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message request: %w", err)
+	}
+	defer cancel()
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("message request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &UpstreamError{Op: "message", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result MessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode message response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// GetChatHistory retrieves all messages in a chat.
+func (cc *ChatClient) GetChatHistory(ctx context.Context, chatID string) (*ChatHistory, error) {
+	token, err := cc.TokenManager.EnsureValidToken()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("chat_id", chatID)
+
+	req, cancel, err := apiRequest(ctx, OpChat, "GET", chatURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat history request: %w", err)
+	}
+	defer cancel()
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("chat history request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &UpstreamError{Op: "chat history", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result ChatHistory
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode chat history: %w", err)
+	}
+
+	return &result, nil
+}
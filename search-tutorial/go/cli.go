@@ -0,0 +1,119 @@
+// Gloo AI Search API - Command-Line Options
+//
+// Parses the options the search, filter, and rag commands take after their
+// positional arguments.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchOptions are the options for the search commands.
+type SearchOptions struct {
+	// MinCertainty drops results below this certainty before they are
+	// shown or used as RAG context; 0 keeps every result the API returns.
+	MinCertainty float64
+	// Explain prints each result's distance, certainty, and score, and how
+	// the ranking was computed.
+	Explain bool
+	// Facets names the facets to count across the results, from type,
+	// author, and tags.
+	Facets []string
+	// HalfLife, if set, reranks results by a blend of certainty and
+	// recency, in which freshness halves every HalfLife.
+	HalfLife time.Duration
+	// RecencyWeight is the share of the blended score that comes from
+	// recency, from 0 to 1.
+	RecencyWeight float64
+}
+
+// parseSearchArgs splits a command's arguments into its positional
+// arguments and its options. Options may come before, between, or after
+// the positional arguments, and take their value as --name=value or
+// --name value.
+func parseSearchArgs(args []string) ([]string, SearchOptions, error) {
+	var positional []string
+	var opts SearchOptions
+	weightSet := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		// takeValue returns the option's value, taking the next argument
+		// if it wasn't given with "="
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "--min-certainty":
+			v, err := takeValue()
+			if err != nil {
+				return nil, opts, err
+			}
+			certainty, err := strconv.ParseFloat(v, 64)
+			if err != nil || certainty < 0 || certainty > 1 {
+				return nil, opts, fmt.Errorf("--min-certainty must be a number from 0 to 1, got %q", v)
+			}
+			opts.MinCertainty = certainty
+		case "--explain":
+			if hasValue {
+				return nil, opts, fmt.Errorf("--explain doesn't take a value")
+			}
+			opts.Explain = true
+		case "--facets":
+			v, err := takeValue()
+			if err != nil {
+				return nil, opts, err
+			}
+			if opts.Facets, err = parseFacets(v); err != nil {
+				return nil, opts, err
+			}
+		case "--half-life":
+			v, err := takeValue()
+			if err != nil {
+				return nil, opts, err
+			}
+			if opts.HalfLife, err = parseHalfLife(v); err != nil {
+				return nil, opts, err
+			}
+		case "--recency-weight":
+			v, err := takeValue()
+			if err != nil {
+				return nil, opts, err
+			}
+			weight, err := strconv.ParseFloat(v, 64)
+			if err != nil || weight < 0 || weight > 1 {
+				return nil, opts, fmt.Errorf("--recency-weight must be a number from 0 to 1, got %q", v)
+			}
+			opts.RecencyWeight = weight
+			weightSet = true
+		default:
+			return nil, opts, fmt.Errorf("unknown option '%s'", name)
+		}
+	}
+
+	if weightSet && opts.HalfLife == 0 {
+		return nil, opts, fmt.Errorf("--recency-weight needs --half-life")
+	}
+	if opts.HalfLife > 0 && !weightSet {
+		opts.RecencyWeight = defaultRecencyWeight
+	}
+
+	return positional, opts, nil
+}
@@ -0,0 +1,167 @@
+// Gloo AI Search API - Response Compression
+//
+// Middleware that compresses JSON responses with brotli or gzip, negotiated
+// from the request's Accept-Encoding header. Search results and RAG payloads
+// can be tens of KB, and compress well.
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Supported content encodings, in order of preference.
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// compressWriter compresses the response body once the handler has set a
+// compressible Content-Type.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	decided bool
+	enc     io.WriteCloser
+	gz      *gzip.Writer
+}
+
+// decide chooses whether to compress, based on the headers written so far.
+func (cw *compressWriter) decide(status int) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+
+	h := cw.Header()
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		return
+	}
+	if h.Get("Content-Encoding") != "" || !isCompressibleType(h.Get("Content-Type")) {
+		return
+	}
+
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+
+	switch cw.encoding {
+	case encodingBrotli:
+		cw.enc = brotli.NewWriterLevel(cw.ResponseWriter, brotli.DefaultCompression)
+	case encodingGzip:
+		cw.gz = gzipWriterPool.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+		cw.enc = cw.gz
+	}
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	cw.decide(code)
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush flushes buffered compressed data to the client.
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the compressed stream.
+func (cw *compressWriter) Close() error {
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	if cw.gz != nil {
+		cw.gz.Reset(io.Discard)
+		gzipWriterPool.Put(cw.gz)
+		cw.gz = nil
+	}
+	cw.enc = nil
+	return err
+}
+
+// withCompression wraps a handler with Accept-Encoding negotiated compression
+// of JSON responses.
+func withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the preferred supported encoding acceptable to the
+// client, or "" for an uncompressed response.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					weight = v
+				}
+			}
+		}
+		q[name] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range []string{encodingBrotli, encodingGzip} {
+		weight, ok := q[enc]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+	return best
+}
+
+// isCompressibleType reports whether responses of the given Content-Type
+// should be compressed.
+func isCompressibleType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return mediaType == "application/json"
+}
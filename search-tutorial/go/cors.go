@@ -0,0 +1,170 @@
+// Gloo AI Search API - CORS
+//
+// A configurable CORS policy for the proxy's /api/ endpoints, loaded from
+// environment variables and validated at startup.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CORSPolicy controls which browser origins may call the proxy API.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long, in seconds, browsers may cache preflight results.
+	MaxAge int
+}
+
+// LoadCORSPolicy reads the CORS policy from the environment.
+func LoadCORSPolicy() CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", "*")),
+		AllowedMethods:   splitList(strings.ToUpper(getEnv("CORS_ALLOWED_METHODS", "GET, POST, DELETE, OPTIONS"))),
+		AllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type, "+RequestIDHeader+", "+TenantHeader)),
+		ExposedHeaders:   splitList(getEnv("CORS_EXPOSED_HEADERS", "X-Cache, "+RequestIDHeader)),
+		AllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "false") == "true",
+		MaxAge:           getEnvInt("CORS_MAX_AGE_SECONDS", 600),
+	}
+}
+
+// Validate checks the policy for mistakes that browsers would reject or that
+// would open the API wider than intended.
+func (p CORSPolicy) Validate() error {
+	if len(p.AllowedOrigins) == 0 {
+		return fmt.Errorf("CORS_ALLOWED_ORIGINS must list at least one origin (or *)")
+	}
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			if len(p.AllowedOrigins) > 1 {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS: * cannot be combined with other origins")
+			}
+			if p.AllowCredentials {
+				return fmt.Errorf("CORS_ALLOW_CREDENTIALS=true requires explicit origins, not *")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: invalid origin %q (expected scheme://host[:port])", origin)
+		}
+	}
+	if len(p.AllowedMethods) == 0 {
+		return fmt.Errorf("CORS_ALLOWED_METHODS must list at least one method")
+	}
+	for _, method := range p.AllowedMethods {
+		switch method {
+		case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS":
+		default:
+			return fmt.Errorf("CORS_ALLOWED_METHODS: unsupported method %q", method)
+		}
+	}
+	for _, h := range append(append([]string{}, p.AllowedHeaders...), p.ExposedHeaders...) {
+		if !isHeaderToken(h) {
+			return fmt.Errorf("CORS header list: invalid header name %q", h)
+		}
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("CORS_MAX_AGE_SECONDS must not be negative")
+	}
+	return nil
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or ""
+// when the origin is not allowed.
+func (p CORSPolicy) allowOrigin(origin string) string {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// Handler applies the policy to /api/ requests and answers preflight
+// requests. Other paths (the static frontend) are passed through untouched.
+func (p CORSPolicy) Handler(next http.Handler) http.Handler {
+	methods := strings.Join(p.AllowedMethods, ", ")
+	headers := strings.Join(p.AllowedHeaders, ", ")
+	exposed := strings.Join(p.ExposedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		allowed := ""
+		if origin != "" {
+			allowed = p.allowOrigin(origin)
+		}
+
+		if allowed != "" {
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if p.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if exposed != "" {
+				h.Set("Access-Control-Expose-Headers", exposed)
+			}
+		}
+
+		if r.Method == "OPTIONS" {
+			if allowed != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", methods)
+				if headers != "" {
+					h.Set("Access-Control-Allow-Headers", headers)
+				}
+				if p.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(p.MaxAge))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isHeaderToken reports whether name is a valid HTTP header field name.
+func isHeaderToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return false
+		}
+	}
+	return true
+}
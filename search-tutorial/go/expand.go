@@ -0,0 +1,120 @@
+// Gloo AI Search API - Snippet Window Expansion
+//
+// Optionally widens each matched snippet with the chunks before and after it
+// in the same item, so RAG answers that need the surrounding text have it.
+// Neighbors come from the results already fetched, or else from a search
+// for the matched chunk's own text, which ranks its neighbors highly.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// neighborSearchLimit is how many results a search for a chunk's neighbors
+// looks through.
+const neighborSearchLimit = 20
+
+// chunkKey identifies one chunk of an item.
+type chunkKey struct {
+	item  string
+	index int
+}
+
+// chunkKeyOf returns a result's chunk, if the collection returned its item
+// ID and chunk index.
+func chunkKeyOf(r SearchResult) (chunkKey, bool) {
+	if r.Properties.ItemID == "" || r.Properties.ChunkIndex == nil {
+		return chunkKey{}, false
+	}
+	return chunkKey{item: r.Properties.ItemID, index: *r.Properties.ChunkIndex}, true
+}
+
+// ExpandWindows widens the snippets of the first maxResults results with up
+// to window chunks on each side from the same item. A result already merged
+// into an earlier one's window is dropped. Results without an item ID and
+// chunk index are left as they are. The returned error lists neighbor
+// searches that failed; the results are usable either way.
+func (rh *RAGHelper) ExpandWindows(ctx context.Context, results *SearchResponse, maxResults, window int) (*SearchResponse, error) {
+	if results == nil || window <= 0 {
+		return results, nil
+	}
+
+	chunks := map[chunkKey]string{}
+	addChunks := func(data []SearchResult) {
+		for _, r := range data {
+			if key, ok := chunkKeyOf(r); ok {
+				if _, seen := chunks[key]; !seen {
+					chunks[key] = r.Properties.Snippet
+				}
+			}
+		}
+	}
+	addChunks(results.Data)
+
+	sc := &SearchClient{TokenManager: rh.TokenManager, Tenant: rh.Tenant}
+	covered := map[chunkKey]bool{}
+	var errs []error
+	var expanded []SearchResult
+
+	for _, r := range results.Data {
+		if len(expanded) == maxResults {
+			break
+		}
+		key, ok := chunkKeyOf(r)
+		if !ok {
+			expanded = append(expanded, r)
+			continue
+		}
+		if covered[key] {
+			continue
+		}
+
+		if missingNeighbors(chunks, key, window) {
+			found, err := sc.Search(ctx, r.Properties.Snippet, neighborSearchLimit)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("fetching neighbors of %q: %w", r.Properties.ItemTitle, err))
+			} else {
+				addChunks(found.Data)
+			}
+		}
+
+		var indices []int
+		for i := key.index - window; i <= key.index+window; i++ {
+			neighbor := chunkKey{item: key.item, index: i}
+			if _, ok := chunks[neighbor]; ok && !covered[neighbor] {
+				indices = append(indices, i)
+			}
+		}
+		sort.Ints(indices)
+
+		parts := make([]string, len(indices))
+		for i, index := range indices {
+			neighbor := chunkKey{item: key.item, index: index}
+			parts[i] = chunks[neighbor]
+			covered[neighbor] = true
+		}
+		r.Properties.Snippet = strings.Join(parts, "\n")
+		expanded = append(expanded, r)
+	}
+
+	return &SearchResponse{Data: expanded, Intent: results.Intent}, errors.Join(errs...)
+}
+
+// missingNeighbors reports whether any chunk within window of key, other
+// than key itself, hasn't been seen. Chunks before the first are never
+// missing.
+func missingNeighbors(chunks map[chunkKey]string, key chunkKey, window int) bool {
+	for i := key.index - window; i <= key.index+window; i++ {
+		if i < 0 || i == key.index {
+			continue
+		}
+		if _, ok := chunks[chunkKey{item: key.item, index: i}]; !ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Gloo AI Search API - Prompt Experiments
+//
+// A/B tests of RAG system prompts in the proxy server. Named prompt variants
+// are loaded from a JSON file, each session is assigned one
+// deterministically, and responses and access log lines are tagged with the
+// variant, so teams can compare answer quality between prompts.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// PromptVariant is one system prompt in an experiment. An empty Prompt is
+// the default RAG prompt, for a control group. Weight is the variant's
+// share of sessions relative to the others, 1 if unset.
+type PromptVariant struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// PromptExperiment assigns sessions to prompt variants. Changing Name
+// reshuffles every session.
+type PromptExperiment struct {
+	Name     string          `json:"name"`
+	Variants []PromptVariant `json:"variants"`
+}
+
+// LoadPromptExperiment reads an experiment from a JSON file.
+func LoadPromptExperiment(path string) (*PromptExperiment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt experiment: %w", err)
+	}
+	var exp PromptExperiment
+	if err := json.Unmarshal(data, &exp); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt experiment %s: %w", path, err)
+	}
+	if err := exp.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid prompt experiment %s: %w", path, err)
+	}
+	return &exp, nil
+}
+
+// Validate checks that the experiment has a name and at least two uniquely
+// named variants with non-negative weights.
+func (e *PromptExperiment) Validate() error {
+	if e.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(e.Variants) < 2 {
+		return errors.New("at least two variants are required")
+	}
+	seen := map[string]bool{}
+	for i, v := range e.Variants {
+		if v.Name == "" {
+			return fmt.Errorf("variant %d has no name", i+1)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("variant name '%s' is used twice", v.Name)
+		}
+		seen[v.Name] = true
+		if v.Weight < 0 {
+			return fmt.Errorf("variant '%s' has a negative weight", v.Name)
+		}
+	}
+	return nil
+}
+
+func (v PromptVariant) weight() int {
+	if v.Weight == 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// Assign returns the variant for a session. The same experiment and
+// session always get the same variant.
+func (e *PromptExperiment) Assign(session string) PromptVariant {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.weight()
+	}
+
+	// SHA-256 rather than a faster hash, whose low bits would depend
+	// mostly on the session ID's last character
+	sum := sha256.Sum256([]byte(e.Name + "|" + session))
+	n := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+
+	for _, v := range e.Variants {
+		if n < v.weight() {
+			return v
+		}
+		n -= v.weight()
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// recordVariant adds the prompt variant to the request's access log entry.
+func recordVariant(r *http.Request, variant string) {
+	if rl := requestLogFrom(r); rl != nil {
+		rl.mu.Lock()
+		rl.variant = variant
+		rl.mu.Unlock()
+	}
+}
+
+// newPromptExperimentFromEnv loads the experiment in PROMPT_EXPERIMENT_FILE,
+// or returns nil if none is set.
+func newPromptExperimentFromEnv() (*PromptExperiment, error) {
+	path := os.Getenv("PROMPT_EXPERIMENT_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadPromptExperiment(path)
+}
@@ -0,0 +1,39 @@
+// Gloo AI Search API - Score Explanations
+//
+// Describes how the Search API scored and ranked each result, for the
+// --explain option of the search and filter commands.
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// printRankingExplanation prints how the results were ranked. dropped is
+// how many results --min-certainty removed, out of returned.
+func printRankingExplanation(opts SearchOptions, dropped, returned int) {
+	fmt.Println("How these results were ranked:")
+	fmt.Println("  - The query and each item are embedded as vectors. Distance is the cosine")
+	fmt.Println("    distance between them: 0 means the same meaning, 2 the opposite.")
+	fmt.Println("  - Certainty rescales distance to 0-1 as 1 - distance/2, so higher is closer.")
+	fmt.Printf("    The API returns results with certainty of at least %.2f, closest first.\n", apiMinCertainty)
+	fmt.Println("  - Score is the keyword relevance score when the search also matched on")
+	fmt.Println("    keywords, and 0 for a purely semantic match.")
+	if opts.HalfLife > 0 {
+		fmt.Printf("  - Then reranked here for recency: each result's freshness halves every %s\n", formatHalfLife(opts.HalfLife))
+		fmt.Printf("    since publication_date, and the blended score is %.2f x certainty + %.2f x freshness.\n", 1-opts.RecencyWeight, opts.RecencyWeight)
+	}
+	if opts.MinCertainty > 0 {
+		fmt.Printf("  - --min-certainty %.2f dropped %d of the %d results returned.\n", opts.MinCertainty, dropped, returned)
+	}
+	fmt.Println()
+}
+
+// printScoreDetails prints the relevance data of one result.
+func printScoreDetails(r SearchResult) {
+	m := r.Metadata
+	fmt.Printf("Distance: %.4f  Certainty: %.4f  Score: %.4f\n", m.Distance, m.Certainty, m.Score)
+	if math.Abs(m.Certainty-(1-m.Distance/2)) > 0.01 {
+		fmt.Println("  (certainty doesn't match 1 - distance/2; this result may use another distance metric)")
+	}
+}
@@ -0,0 +1,124 @@
+// Gloo AI Search API - Facets
+//
+// Counts the types, authors, and tags of search results, so the CLI can
+// show what a corpus is made of alongside the hits.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// facetSampleSize is how many results facets are counted over. The Search
+// API has no offset to page with, so facets cover the top results a single
+// request can return.
+const facetSampleSize = 100
+
+// facetTopValues is how many values of each facet are printed.
+const facetTopValues = 10
+
+// facetFields returns the values of each facet for a result.
+var facetFields = map[string]func(SearchResult) []string{
+	"type": func(r SearchResult) []string {
+		if r.Properties.Type == "" {
+			return nil
+		}
+		return []string{r.Properties.Type}
+	},
+	"author": func(r SearchResult) []string { return r.Properties.Author },
+	"tags":   func(r SearchResult) []string { return r.Properties.ItemTags },
+}
+
+// FacetCount is how many results have one value of a facet.
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// Facet is the counts of one facet's values, most common first. Missing is
+// how many results had no value for it.
+type Facet struct {
+	Name    string
+	Counts  []FacetCount
+	Missing int
+}
+
+// parseFacets reads a comma-separated list of facet names.
+func parseFacets(spec string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := facetFields[name]; !ok {
+			return nil, fmt.Errorf("unknown facet '%s' (choose from type, author, tags)", name)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("--facets needs at least one of type, author, tags")
+	}
+	return names, nil
+}
+
+// ComputeFacets counts the values of each named facet across results.
+// Values are matched ignoring case and surrounding space, and each is shown
+// as it was first seen.
+func ComputeFacets(results []SearchResult, names []string) []Facet {
+	facets := make([]Facet, 0, len(names))
+	for _, name := range names {
+		values := facetFields[name]
+		facet := Facet{Name: name}
+		index := map[string]int{}
+		for _, r := range results {
+			seen := map[string]bool{}
+			for _, value := range values(r) {
+				value = strings.TrimSpace(value)
+				key := strings.ToLower(value)
+				if value == "" || seen[key] {
+					continue
+				}
+				seen[key] = true
+				if i, ok := index[key]; ok {
+					facet.Counts[i].Count++
+				} else {
+					index[key] = len(facet.Counts)
+					facet.Counts = append(facet.Counts, FacetCount{Value: value, Count: 1})
+				}
+			}
+			if len(seen) == 0 {
+				facet.Missing++
+			}
+		}
+		sort.SliceStable(facet.Counts, func(i, j int) bool {
+			return facet.Counts[i].Count > facet.Counts[j].Count
+		})
+		facets = append(facets, facet)
+	}
+	return facets
+}
+
+// printFacets prints each facet's most common values.
+func printFacets(facets []Facet, sampled int) {
+	fmt.Printf("=== Facets (across %d results) ===\n", sampled)
+	for _, f := range facets {
+		fmt.Printf("%s:\n", strings.ToUpper(f.Name[:1])+f.Name[1:])
+		if len(f.Counts) == 0 {
+			fmt.Println("  (no values)")
+			continue
+		}
+		for i, c := range f.Counts {
+			if i == facetTopValues {
+				fmt.Printf("  ... and %d more\n", len(f.Counts)-facetTopValues)
+				break
+			}
+			fmt.Printf("  %-30s %d\n", c.Value, c.Count)
+		}
+		if f.Missing > 0 {
+			fmt.Printf("  %-30s %d\n", "(none)", f.Missing)
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,60 @@
+// Gloo AI Search API - Embedded Frontend
+//
+// The browser UI from ../frontend-example/simple-html is embedded into the
+// binary so the server works no matter which directory it is started from.
+// A custom directory can be layered on top with --frontend-dir; any asset
+// missing there falls back to the embedded copy.
+package main
+
+//go:generate sh -c "rm -rf frontend && mkdir frontend && cp ../frontend-example/simple-html/* frontend/"
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+//go:embed frontend
+var embeddedFrontend embed.FS
+
+// fallbackFS serves files from Primary, falling back to Fallback when a file
+// does not exist in Primary.
+type fallbackFS struct {
+	Primary  http.FileSystem
+	Fallback http.FileSystem
+}
+
+// Open implements http.FileSystem.
+func (f fallbackFS) Open(name string) (http.File, error) {
+	file, err := f.Primary.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+	return f.Fallback.Open(name)
+}
+
+// frontendFileSystem returns the file system the server serves static assets
+// from. With an empty overrideDir only the embedded frontend is used.
+func frontendFileSystem(overrideDir string) (http.FileSystem, error) {
+	sub, err := fs.Sub(embeddedFrontend, "frontend")
+	if err != nil {
+		return nil, err
+	}
+	embedded := http.FS(sub)
+
+	if overrideDir == "" {
+		return embedded, nil
+	}
+
+	info, err := os.Stat(overrideDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(overrideDir + " is not a directory")
+	}
+
+	return fallbackFS{Primary: http.Dir(overrideDir), Fallback: embedded}, nil
+}
@@ -0,0 +1,232 @@
+// Gloo AI Search API - gRPC service
+//
+// The gRPC variant of the search proxy server (`go run . grpc`). The
+// server's Go code in gen/searchv1 is generated from this file with:
+//
+//   protoc --go_out=. --go_opt=module=github.com/gloo/search-tutorial \
+//     --go-grpc_out=. --go-grpc_opt=module=github.com/gloo/search-tutorial \
+//     proto/search.proto
+//
+// Generate a client for your language with protoc the same way.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: proto/search.proto
+
+package searchv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SearchService_Search_FullMethodName    = "/gloo.search.v1.SearchService/Search"
+	SearchService_Rag_FullMethodName       = "/gloo.search.v1.SearchService/Rag"
+	SearchService_StreamRag_FullMethodName = "/gloo.search.v1.SearchService/StreamRag"
+)
+
+// SearchServiceClient is the client API for SearchService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SearchServiceClient interface {
+	// Search runs a semantic search, like GET /api/search.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// Rag searches, then answers the query from the results with
+	// Completions V2, like POST /api/search/rag.
+	Rag(ctx context.Context, in *RagRequest, opts ...grpc.CallOption) (*RagResponse, error)
+	// StreamRag is Rag with the answer sent as it is generated. The first
+	// chunk carries the sources; the rest carry the answer, piece by piece.
+	StreamRag(ctx context.Context, in *RagRequest, opts ...grpc.CallOption) (SearchService_StreamRagClient, error)
+}
+
+type searchServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSearchServiceClient(cc grpc.ClientConnInterface) SearchServiceClient {
+	return &searchServiceClient{cc}
+}
+
+func (c *searchServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, SearchService_Search_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *searchServiceClient) Rag(ctx context.Context, in *RagRequest, opts ...grpc.CallOption) (*RagResponse, error) {
+	out := new(RagResponse)
+	err := c.cc.Invoke(ctx, SearchService_Rag_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *searchServiceClient) StreamRag(ctx context.Context, in *RagRequest, opts ...grpc.CallOption) (SearchService_StreamRagClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SearchService_ServiceDesc.Streams[0], SearchService_StreamRag_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &searchServiceStreamRagClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SearchService_StreamRagClient interface {
+	Recv() (*RagChunk, error)
+	grpc.ClientStream
+}
+
+type searchServiceStreamRagClient struct {
+	grpc.ClientStream
+}
+
+func (x *searchServiceStreamRagClient) Recv() (*RagChunk, error) {
+	m := new(RagChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SearchServiceServer is the server API for SearchService service.
+// All implementations must embed UnimplementedSearchServiceServer
+// for forward compatibility
+type SearchServiceServer interface {
+	// Search runs a semantic search, like GET /api/search.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// Rag searches, then answers the query from the results with
+	// Completions V2, like POST /api/search/rag.
+	Rag(context.Context, *RagRequest) (*RagResponse, error)
+	// StreamRag is Rag with the answer sent as it is generated. The first
+	// chunk carries the sources; the rest carry the answer, piece by piece.
+	StreamRag(*RagRequest, SearchService_StreamRagServer) error
+	mustEmbedUnimplementedSearchServiceServer()
+}
+
+// UnimplementedSearchServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSearchServiceServer struct {
+}
+
+func (UnimplementedSearchServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedSearchServiceServer) Rag(context.Context, *RagRequest) (*RagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rag not implemented")
+}
+func (UnimplementedSearchServiceServer) StreamRag(*RagRequest, SearchService_StreamRagServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamRag not implemented")
+}
+func (UnimplementedSearchServiceServer) mustEmbedUnimplementedSearchServiceServer() {}
+
+// UnsafeSearchServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SearchServiceServer will
+// result in compilation errors.
+type UnsafeSearchServiceServer interface {
+	mustEmbedUnimplementedSearchServiceServer()
+}
+
+func RegisterSearchServiceServer(s grpc.ServiceRegistrar, srv SearchServiceServer) {
+	s.RegisterService(&SearchService_ServiceDesc, srv)
+}
+
+func _SearchService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SearchService_Rag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchServiceServer).Rag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SearchService_Rag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchServiceServer).Rag(ctx, req.(*RagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SearchService_StreamRag_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RagRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SearchServiceServer).StreamRag(m, &searchServiceStreamRagServer{stream})
+}
+
+type SearchService_StreamRagServer interface {
+	Send(*RagChunk) error
+	grpc.ServerStream
+}
+
+type searchServiceStreamRagServer struct {
+	grpc.ServerStream
+}
+
+func (x *searchServiceStreamRagServer) Send(m *RagChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SearchService_ServiceDesc is the grpc.ServiceDesc for SearchService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SearchService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gloo.search.v1.SearchService",
+	HandlerType: (*SearchServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _SearchService_Search_Handler,
+		},
+		{
+			MethodName: "Rag",
+			Handler:    _SearchService_Rag_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamRag",
+			Handler:       _SearchService_StreamRag_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/search.proto",
+}
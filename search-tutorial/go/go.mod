@@ -2,4 +2,17 @@ module github.com/gloo/search-tutorial
 
 go 1.20
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.21.0
+	google.golang.org/grpc v1.63.2
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240227224415-6ceb2ff114de // indirect
+)
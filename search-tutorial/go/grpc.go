@@ -0,0 +1,391 @@
+// Gloo AI Search API - gRPC Server
+//
+// A gRPC variant of the proxy server, for internal services that prefer gRPC
+// to the JSON endpoints. It serves the SearchService defined in
+// proto/search.proto over HTTP/2: in cleartext (h2c) by default, or with TLS.
+//
+// Start with:
+//
+//	go run . grpc
+//	go run . grpc 50051 --tls-cert=cert.pem --tls-key=key.pem
+//
+// The messages and service interface are generated from proto/search.proto
+// into gen/searchv1. Calls are served by grpc-go on net/http, so they share
+// the proxy server's access log, TLS setup, and graceful shutdown.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	searchv1 "github.com/gloo/search-tutorial/gen/searchv1"
+)
+
+// Full method names of the SearchService RPCs, which are also their paths.
+const (
+	grpcSearchPath    = searchv1.SearchService_Search_FullMethodName
+	grpcRagPath       = searchv1.SearchService_Rag_FullMethodName
+	grpcStreamRagPath = searchv1.SearchService_StreamRag_FullMethodName
+)
+
+// grpcTenantKey is the metadata key that names the tenant to search, the
+// gRPC form of the X-Gloo-Tenant header.
+const grpcTenantKey = "x-gloo-tenant"
+
+// noContentResponse answers a RAG query that found nothing to answer from.
+const noContentResponse = "No relevant content found."
+
+// GRPCOptions configures the gRPC server.
+type GRPCOptions struct {
+	Port string
+	TLS  TLSOptions
+}
+
+// grpcService implements the SearchService RPCs with the same clients, and
+// the same search cache settings and tenant allowlist, as the JSON
+// endpoints.
+type grpcService struct {
+	searchv1.UnimplementedSearchServiceServer
+
+	tm      *TokenManager
+	cache   *SearchCache
+	safety  *SafetyPolicy
+	tenants *TenantAllowlist
+
+	server *grpc.Server
+
+	// inflight counts running calls, so shutdown can wait for them.
+	inflight sync.WaitGroup
+}
+
+// newGRPCService creates the service and the gRPC server that runs it.
+func newGRPCService(tm *TokenManager, cache *SearchCache, tenants *TenantAllowlist) *grpcService {
+	s := &grpcService{
+		tm:      tm,
+		cache:   cache,
+		safety:  newSafetyPolicy(&RAGHelper{TokenManager: tm, Options: ragOptions}),
+		tenants: tenants,
+	}
+	s.server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logGRPCUnaryError),
+		grpc.ChainStreamInterceptor(logGRPCStreamError),
+	)
+	searchv1.RegisterSearchServiceServer(s.server, s)
+	return s
+}
+
+type grpcRequestKey struct{}
+
+// ServeHTTP hands one gRPC call to the gRPC server. The HTTP request is
+// kept in the call's context for the access log and search cache helpers
+// the JSON endpoints share; see callRequest.
+func (s *grpcService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.inflight.Add(1)
+	defer s.inflight.Done()
+	s.server.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), grpcRequestKey{}, r)))
+}
+
+// callRequest returns the HTTP request that carried a call, with the call's
+// context, which ends when the call's deadline passes or it is canceled.
+func callRequest(ctx context.Context) *http.Request {
+	r, ok := ctx.Value(grpcRequestKey{}).(*http.Request)
+	if !ok {
+		r = &http.Request{}
+	}
+	return r.WithContext(ctx)
+}
+
+// tenant returns the tenant a call names in its x-gloo-tenant metadata, or
+// else in field, the request's tenant. Tenants that aren't on the
+// allowlist are refused, as in the JSON endpoints.
+func (s *grpcService) tenant(ctx context.Context, field string) (string, error) {
+	requested := field
+	if values := metadata.ValueFromIncomingContext(ctx, grpcTenantKey); len(values) > 0 && values[0] != "" {
+		requested = values[0]
+	}
+	t, err := s.tenants.Resolve(requested)
+	if err != nil {
+		return "", status.Error(codes.PermissionDenied, "tenant is not allowed")
+	}
+	return t, nil
+}
+
+// Search runs a semantic search, through the search cache when it is
+// enabled.
+func (s *grpcService) Search(ctx context.Context, req *searchv1.SearchRequest) (*searchv1.SearchResponse, error) {
+	if req.GetQuery() == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+	tenant, err := s.tenant(ctx, req.GetTenant())
+	if err != nil {
+		return nil, err
+	}
+	limit := normalizeLimit(int(req.GetLimit()), 10, 1, maxSearchLimit)
+
+	r := callRequest(ctx)
+	sc := &SearchClient{TokenManager: s.tm, Tenant: tenant}
+	results, _, err := cachedSearch(sc, s.cache, r, req.GetQuery(), limit)
+	if err != nil {
+		return nil, upstreamStatus(r, "search", err)
+	}
+	if len(req.GetContentTypes()) > 0 {
+		results = sc.FilterByContentType(results, req.GetContentTypes())
+	}
+
+	resp := &searchv1.SearchResponse{}
+	for _, result := range results.Data {
+		resp.Results = append(resp.Results, &searchv1.SearchResult{
+			Uuid:      result.UUID,
+			Title:     result.Properties.ItemTitle,
+			Type:      result.Properties.Type,
+			Authors:   result.Properties.Author,
+			Snippet:   result.Properties.Snippet,
+			Certainty: result.Metadata.Certainty,
+		})
+	}
+	return resp, nil
+}
+
+// Rag searches, then answers the query from the results.
+func (s *grpcService) Rag(ctx context.Context, req *searchv1.RagRequest) (*searchv1.RagResponse, error) {
+	r := callRequest(ctx)
+	rh, snippets, flagged, err := s.retrieve(r, req)
+	if err != nil {
+		return nil, err
+	}
+	if flagged {
+		return &searchv1.RagResponse{Response: s.safety.Response()}, nil
+	}
+	if len(snippets) == 0 {
+		return &searchv1.RagResponse{Response: noContentResponse}, nil
+	}
+
+	start := time.Now()
+	response, err := rh.GenerateWithContext(r.Context(), req.GetQuery(), rh.FormatContextForLLM(snippets), req.GetSystemPrompt())
+	recordUpstream(r, "completions", time.Since(start), err)
+	if err != nil {
+		return nil, upstreamStatus(r, "rag generation", err)
+	}
+
+	return &searchv1.RagResponse{Response: response, Sources: protoSources(snippets)}, nil
+}
+
+// StreamRag is Rag with the answer sent as it is generated: first a chunk
+// with the sources, then one per piece of the answer.
+func (s *grpcService) StreamRag(req *searchv1.RagRequest, stream searchv1.SearchService_StreamRagServer) error {
+	r := callRequest(stream.Context())
+	rh, snippets, flagged, err := s.retrieve(r, req)
+	if err != nil {
+		return err
+	}
+	if flagged {
+		return stream.Send(&searchv1.RagChunk{Delta: s.safety.Response()})
+	}
+	if len(snippets) == 0 {
+		return stream.Send(&searchv1.RagChunk{Delta: noContentResponse})
+	}
+	if err := stream.Send(&searchv1.RagChunk{Sources: protoSources(snippets)}); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = rh.StreamWithContext(r.Context(), req.GetQuery(), rh.FormatContextForLLM(snippets), req.GetSystemPrompt(), func(delta string) error {
+		return stream.Send(&searchv1.RagChunk{Delta: delta})
+	})
+	recordUpstream(r, "completions", time.Since(start), err)
+	if err != nil {
+		return upstreamStatus(r, "rag generation", err)
+	}
+	return nil
+}
+
+// retrieve runs the search step of RAG for the tenant the call names, and
+// returns the RAG helper for that tenant and the snippets to answer from,
+// which are empty when nothing relevant was found. flagged is true when the
+// safety policy flagged the query, which should then be answered with
+// helpline resources.
+func (s *grpcService) retrieve(r *http.Request, req *searchv1.RagRequest) (rh *RAGHelper, snippets []Snippet, flagged bool, err error) {
+	if req.GetQuery() == "" {
+		return nil, nil, false, status.Error(codes.InvalidArgument, "query is required")
+	}
+	tenant, err := s.tenant(r.Context(), req.GetTenant())
+	if err != nil {
+		return nil, nil, false, err
+	}
+	limit := normalizeLimit(int(req.GetLimit()), 5, 1, maxSearchLimit)
+	sc := &SearchClient{TokenManager: s.tm, Tenant: tenant}
+	rh = &RAGHelper{TokenManager: s.tm, Options: ragOptions, Tenant: tenant}
+
+	start := time.Now()
+	results, err := sc.Search(r.Context(), req.GetQuery(), limit)
+	recordUpstream(r, "search", time.Since(start), err)
+	if err != nil {
+		return nil, nil, false, upstreamStatus(r, "rag search", err)
+	}
+
+	onSafetyError := func(err error) { logRequestError(r, "safety check", err) }
+	if checkSafety(r.Context(), s.safety, req.GetQuery(), results.Intent, onSafetyError) {
+		return rh, nil, true, nil
+	}
+
+	snippetLimit := limit
+	if snippetLimit > ragMaxSnips {
+		snippetLimit = ragMaxSnips
+	}
+	snippets, err = rh.PrepareSnippets(r.Context(), req.GetQuery(), results, snippetLimit, ragMaxChars)
+	if err != nil {
+		logRequestError(r, "rag summarize", err)
+	}
+	return rh, snippets, false, nil
+}
+
+// drain waits for running calls to finish, or for ctx to end.
+func (s *grpcService) drain(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// protoSources lists the snippets' titles and types as RAG sources.
+func protoSources(snippets []Snippet) []*searchv1.Source {
+	sources := make([]*searchv1.Source, len(snippets))
+	for i, s := range snippets {
+		sources[i] = &searchv1.Source{Title: s.Title, Type: s.Type}
+	}
+	return sources
+}
+
+// upstreamStatus records a failed Gloo API call in the access log and
+// returns the status to report, without the upstream details.
+func upstreamStatus(r *http.Request, stage string, err error) error {
+	logRequestError(r, stage, err)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, "%s timed out", stage)
+	case errors.Is(err, context.Canceled):
+		return status.Errorf(codes.Canceled, "%s was canceled", stage)
+	default:
+		return status.Errorf(codes.Unavailable, "%s failed", stage)
+	}
+}
+
+// grpcError records a failed call's status in the access log. Errors
+// without a gRPC status are reported as internal errors, without detail.
+func grpcError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	logRequestError(callRequest(ctx), "grpc", err)
+	if _, ok := status.FromError(err); !ok {
+		return status.Error(codes.Internal, "internal error")
+	}
+	return err
+}
+
+func logGRPCUnaryError(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, grpcError(ctx, err)
+}
+
+func logGRPCStreamError(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return grpcError(ss.Context(), handler(srv, ss))
+}
+
+func startGRPCServer(opts GRPCOptions) {
+	tm := NewTokenManager(clientID, clientSecret, tokenURL)
+	svc := newGRPCService(tm, newSearchCacheFromEnv(), newTenantAllowlistFromEnv())
+
+	srv := &http.Server{
+		Addr:              ":" + opts.Port,
+		Handler:           withAccessLog(svc),
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout(),
+		IdleTimeout:       serverIdleTimeout,
+	}
+
+	serve := srv.ListenAndServe
+	transport := "cleartext HTTP/2 (h2c)"
+	if opts.TLS.Enabled() {
+		serve, _ = configureTLS(srv, opts.TLS)
+		transport = "TLS"
+	} else {
+		// gRPC clients without TLS speak HTTP/2 from the first byte, which
+		// net/http only accepts through the h2c handler
+		h2s := &http2.Server{}
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to configure HTTP/2: %v\n", err)
+			os.Exit(1)
+		}
+		srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	}
+
+	fmt.Printf("gRPC SearchService listening on port %s (%s)\n", opts.Port, transport)
+	fmt.Printf("\nRPCs:\n")
+	for _, path := range []string{grpcSearchPath, grpcRagPath, grpcStreamRagPath} {
+		fmt.Printf("  %s\n", strings.TrimPrefix(path, "/"))
+	}
+	fmt.Printf("\nService definition: proto/search.proto\n")
+	if allowed := svc.tenants.Tenants(); len(allowed) > 1 {
+		fmt.Printf("Tenants: %s (default %s)\n", strings.Join(allowed, ", "), svc.tenants.Default)
+	}
+	if svc.cache != nil {
+		fmt.Printf("\nSearch cache: %d entries, TTL %ds, stale %ds\n", searchCacheSize, searchCacheTTL, searchCacheStaleTTL)
+	}
+
+	// net/http hands h2c connections over to the HTTP/2 server, so Shutdown
+	// doesn't wait for their calls; drain does.
+	if err := runServer(srv, serve, svc.drain); err != nil {
+		fmt.Fprintf(os.Stderr, "Server failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleGRPCCommand parses the grpc command's arguments:
+//
+//	grpc [port] [--port=N] [--tls-cert=FILE --tls-key=FILE]
+func handleGRPCCommand() {
+	opts := GRPCOptions{Port: "50051"}
+
+	for _, arg := range os.Args[2:] {
+		switch {
+		case strings.HasPrefix(arg, "--port="):
+			opts.Port = strings.TrimPrefix(arg, "--port=")
+		case strings.HasPrefix(arg, "--tls-cert="):
+			opts.TLS.CertFile = strings.TrimPrefix(arg, "--tls-cert=")
+		case strings.HasPrefix(arg, "--tls-key="):
+			opts.TLS.KeyFile = strings.TrimPrefix(arg, "--tls-key=")
+		case strings.HasPrefix(arg, "--"):
+			fmt.Fprintf(os.Stderr, "Error: Unknown grpc option '%s'\n", arg)
+			printUsage()
+			os.Exit(1)
+		default:
+			opts.Port = arg
+		}
+	}
+
+	if err := opts.TLS.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	startGRPCServer(opts)
+}
@@ -0,0 +1,111 @@
+// Gloo AI Search API - HTTP Client
+//
+// One HTTP client shared by every call this example makes to the Gloo AI
+// APIs, with a timeout for each kind of call loaded from the environment (or
+// .env file) instead of hard-coded at each call site. The servers derive
+// their write timeout from the same policy.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Operation is a kind of Gloo AI API call with its own timeout.
+type Operation string
+
+const (
+	OpAuth       Operation = "auth"
+	OpSearch     Operation = "search"
+	OpCompletion Operation = "completion"
+	OpChat       Operation = "chat"
+)
+
+// TimeoutPolicy is how long each kind of call may take, including reading
+// the response body.
+type TimeoutPolicy struct {
+	Auth       time.Duration
+	Search     time.Duration
+	Completion time.Duration
+	Chat       time.Duration
+}
+
+// timeouts is the policy used by apiRequest, set from the environment in
+// main.
+var timeouts = defaultTimeoutPolicy()
+
+// httpClient is shared by every Gloo AI API call, so connections are reused
+// across them. It has no overall timeout; each request carries a context
+// deadline from the timeout policy instead.
+var httpClient = &http.Client{}
+
+// defaultTimeoutPolicy returns the timeouts used when none are configured.
+// Completions and chat messages generate text, so they get longer than the
+// lookups.
+func defaultTimeoutPolicy() TimeoutPolicy {
+	return TimeoutPolicy{
+		Auth:       30 * time.Second,
+		Search:     60 * time.Second,
+		Completion: 60 * time.Second,
+		Chat:       60 * time.Second,
+	}
+}
+
+// LoadTimeoutPolicy reads the timeouts, in seconds, from the environment.
+func LoadTimeoutPolicy() TimeoutPolicy {
+	defaults := defaultTimeoutPolicy()
+	seconds := func(key string, fallback time.Duration) time.Duration {
+		return time.Duration(getEnvInt(key, int(fallback/time.Second))) * time.Second
+	}
+	return TimeoutPolicy{
+		Auth:       seconds("HTTP_TIMEOUT_AUTH_SECONDS", defaults.Auth),
+		Search:     seconds("HTTP_TIMEOUT_SEARCH_SECONDS", defaults.Search),
+		Completion: seconds("HTTP_TIMEOUT_COMPLETION_SECONDS", defaults.Completion),
+		Chat:       seconds("HTTP_TIMEOUT_CHAT_SECONDS", defaults.Chat),
+	}
+}
+
+// Validate checks that every timeout is positive.
+func (p TimeoutPolicy) Validate() error {
+	for op, d := range map[Operation]time.Duration{
+		OpAuth: p.Auth, OpSearch: p.Search, OpCompletion: p.Completion, OpChat: p.Chat,
+	} {
+		if d <= 0 {
+			return fmt.Errorf("the %s timeout must be at least 1 second, got %s", op, d)
+		}
+	}
+	return nil
+}
+
+// For returns the timeout for op.
+func (p TimeoutPolicy) For(op Operation) time.Duration {
+	switch op {
+	case OpAuth:
+		return p.Auth
+	case OpSearch:
+		return p.Search
+	case OpCompletion:
+		return p.Completion
+	default:
+		return p.Chat
+	}
+}
+
+// operationKey carries a request's Operation in its context.
+type operationKey struct{}
+
+// apiRequest creates a request for op whose context expires after op's
+// timeout, or earlier if ctx ends first (for example when the proxy's client
+// disconnects). Call cancel once the response body has been read.
+func apiRequest(ctx context.Context, op Operation, method, url string, body io.Reader) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, operationKey{}, op), timeouts.For(op))
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return req, cancel, nil
+}
@@ -0,0 +1,173 @@
+// Gloo AI Search API - Intent Routing
+//
+// The Search API classifies each query and returns the class as an intent
+// code. An IntentRouter maps those codes to handlers, so the CLI can, for
+// example, show support resources for a crisis query or answer a
+// conversational one with grounded chat instead of a list of results.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IntentHandler acts on searches whose response carried an intent it is
+// registered for.
+type IntentHandler interface {
+	// HandleIntent returns true if it answered the query itself, so the
+	// results should not be shown.
+	HandleIntent(ctx context.Context, query string, results *SearchResponse) (bool, error)
+}
+
+// IntentHandlerFunc adapts a function to an IntentHandler.
+type IntentHandlerFunc func(ctx context.Context, query string, results *SearchResponse) (bool, error)
+
+// HandleIntent calls f.
+func (f IntentHandlerFunc) HandleIntent(ctx context.Context, query string, results *SearchResponse) (bool, error) {
+	return f(ctx, query, results)
+}
+
+// IntentRouter maps intent codes to handlers. Intents without a handler
+// leave the results as they are.
+type IntentRouter struct {
+	handlers map[int]IntentHandler
+}
+
+// NewIntentRouter returns a router with no handlers.
+func NewIntentRouter() *IntentRouter {
+	return &IntentRouter{handlers: map[int]IntentHandler{}}
+}
+
+// Register routes an intent code to a handler, replacing any before it.
+func (ir *IntentRouter) Register(intent int, h IntentHandler) {
+	ir.handlers[intent] = h
+}
+
+// Route runs the handler for the response's intent, if there is one. It
+// returns true if the handler answered the query itself.
+func (ir *IntentRouter) Route(ctx context.Context, query string, results *SearchResponse) (bool, error) {
+	h, ok := ir.handlers[results.Intent]
+	if !ok {
+		return false, nil
+	}
+	return h.HandleIntent(ctx, query, results)
+}
+
+// CrisisResources shows a list of support resources ahead of the results.
+type CrisisResources struct {
+	Resources []string
+}
+
+// HandleIntent prints the resources. The results are still shown after
+// them.
+func (c CrisisResources) HandleIntent(ctx context.Context, query string, results *SearchResponse) (bool, error) {
+	fmt.Println("=== If you need support right now ===")
+	for _, r := range c.Resources {
+		fmt.Printf("- %s\n", r)
+	}
+	fmt.Println()
+	return false, nil
+}
+
+// GroundedChat answers the query with the Message API, which grounds its
+// reply in your content, in place of the results.
+type GroundedChat struct {
+	Chat *ChatClient
+}
+
+// HandleIntent prints the chat reply and its suggested follow-ups.
+func (g GroundedChat) HandleIntent(ctx context.Context, query string, results *SearchResponse) (bool, error) {
+	resp, err := g.Chat.SendMessage(ctx, query, "")
+	if err != nil {
+		return false, err
+	}
+	fmt.Println("=== Answer ===")
+	fmt.Println(resp.Message)
+	if len(resp.Suggestions) > 0 {
+		fmt.Println("\nYou might also ask:")
+		for _, s := range resp.Suggestions {
+			fmt.Printf("- %s\n", s)
+		}
+	}
+	return true, nil
+}
+
+// intentActions are the handlers SEARCH_INTENT_ROUTES can name.
+var intentActions = []string{"crisis", "chat"}
+
+// parseIntentRoutes reads SEARCH_INTENT_ROUTES, a comma-separated list of
+// intent=action pairs such as "2=chat,4=crisis".
+func parseIntentRoutes(spec string) (map[int]string, error) {
+	routes := map[int]string{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, action, ok := strings.Cut(pair, "=")
+		intent, err := strconv.Atoi(strings.TrimSpace(code))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("intent route '%s' should look like 2=chat", pair)
+		}
+		action = strings.ToLower(strings.TrimSpace(action))
+		switch action {
+		case "crisis", "chat":
+		default:
+			return nil, fmt.Errorf("unknown intent action '%s' (choose from %s)", action, strings.Join(intentActions, ", "))
+		}
+		routes[intent] = action
+	}
+	return routes, nil
+}
+
+// parseCrisisResources reads CRISIS_RESOURCES, a semicolon-separated list of
+// resources to show for crisis intents.
+func parseCrisisResources(spec string) []string {
+	var resources []string
+	for _, r := range strings.Split(spec, ";") {
+		if r = strings.TrimSpace(r); r != "" {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// validateIntentRoutes checks that every action routed to has what it
+// needs.
+func validateIntentRoutes(routes map[int]string, resources []string) error {
+	for intent, action := range routes {
+		if action == "crisis" && len(resources) == 0 {
+			return fmt.Errorf("intent %d is routed to crisis but CRISIS_RESOURCES is empty", intent)
+		}
+	}
+	return nil
+}
+
+// newIntentRouter builds the router configured by SEARCH_INTENT_ROUTES.
+func newIntentRouter(tm *TokenManager) *IntentRouter {
+	ir := NewIntentRouter()
+	for intent, action := range intentRoutes {
+		switch action {
+		case "crisis":
+			ir.Register(intent, CrisisResources{Resources: crisisResources})
+		case "chat":
+			ir.Register(intent, GroundedChat{Chat: &ChatClient{TokenManager: tm}})
+		}
+	}
+	return ir
+}
+
+// routeIntent routes a search's intent, reporting whether its handler
+// answered the query. A handler that fails is reported and the results are
+// shown as usual.
+func routeIntent(ir *IntentRouter, query string, results *SearchResponse) bool {
+	handled, err := ir.Route(context.Background(), query, results)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: handling intent %d failed, showing results instead: %v\n", results.Intent, err)
+		return false
+	}
+	return handled
+}
@@ -0,0 +1,214 @@
+// Gloo AI Search API - Access Logging
+//
+// Middleware that assigns each request an ID and writes one structured
+// (JSON) access log line per request, including time spent waiting on
+// upstream Gloo API calls and any error recorded by the handler.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+type requestLogKey struct{}
+
+// requestLog collects per-request data that handlers contribute to the
+// access log line.
+type requestLog struct {
+	ID string
+
+	mu       sync.Mutex
+	upstream time.Duration
+	errors   []string
+	variant  string
+}
+
+// AccessLogEntry is a single structured access log line.
+type AccessLogEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"request_id"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	LatencyMS  float64 `json:"latency_ms"`
+	UpstreamMS float64 `json:"upstream_ms"`
+	RemoteAddr string  `json:"remote_addr"`
+	Variant    string  `json:"variant,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// EventLogEntry is a structured log line for work done outside a request,
+// such as a background cache refresh.
+type EventLogEntry struct {
+	Time     string `json:"time"`
+	Event    string `json:"event"`
+	Tenant   string `json:"tenant,omitempty"`
+	CacheKey string `json:"cache_key,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// statusRecorder captures the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.status == 0 {
+		sr.status = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can flush through the access log.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// Flush flushes the underlying writer, for handlers such as grpc-go's that
+// need an http.Flusher rather than using http.ResponseController.
+func (sr *statusRecorder) Flush() {
+	http.NewResponseController(sr.ResponseWriter).Flush()
+}
+
+// withAccessLog wraps a handler with request ID assignment and access logging.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rl := &requestLog{ID: requestIDFromHeader(r.Header.Get(RequestIDHeader))}
+		w.Header().Set(RequestIDHeader, rl.ID)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, rl)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		rl.mu.Lock()
+		entry := AccessLogEntry{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			RequestID:  rl.ID,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rec.status,
+			Bytes:      rec.bytes,
+			LatencyMS:  durationMS(time.Since(start)),
+			UpstreamMS: durationMS(rl.upstream),
+			RemoteAddr: r.RemoteAddr,
+			Variant:    rl.variant,
+		}
+		if len(rl.errors) > 0 {
+			entry.Error = rl.errors[0]
+			for _, e := range rl.errors[1:] {
+				entry.Error += "; " + e
+			}
+		}
+		rl.mu.Unlock()
+
+		serverMetrics.ObserveRequest(routeLabel(r.URL.Path), r.Method, entry.Status, time.Since(start))
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Access log error: %v\n", err)
+			return
+		}
+		fmt.Println(string(line))
+	})
+}
+
+// logEvent writes an event log line alongside the access log.
+func logEvent(entry EventLogEntry) {
+	entry.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Event log error: %v\n", err)
+		return
+	}
+	fmt.Println(string(line))
+}
+
+// requestLogFrom returns the request log attached by withAccessLog, if any.
+func requestLogFrom(r *http.Request) *requestLog {
+	rl, _ := r.Context().Value(requestLogKey{}).(*requestLog)
+	return rl
+}
+
+// recordUpstream adds time spent waiting on a Gloo API call to the request's
+// access log entry and records the call in the upstream metrics.
+func recordUpstream(r *http.Request, op string, d time.Duration, err error) {
+	serverMetrics.ObserveUpstream(op, d, err)
+	if rl := requestLogFrom(r); rl != nil {
+		rl.mu.Lock()
+		rl.upstream += d
+		rl.mu.Unlock()
+	}
+}
+
+// logRequestError records an error against the request's access log entry.
+// Requests served outside the middleware fall back to stderr.
+func logRequestError(r *http.Request, stage string, err error) {
+	msg := fmt.Sprintf("%s: %v", stage, err)
+	rl := requestLogFrom(r)
+	if rl == nil {
+		fmt.Fprintln(os.Stderr, msg)
+		return
+	}
+	rl.mu.Lock()
+	rl.errors = append(rl.errors, msg)
+	rl.mu.Unlock()
+}
+
+// requestIDFromHeader reuses a caller-supplied request ID when it is safe to
+// echo back, and otherwise generates a new one.
+func requestIDFromHeader(value string) string {
+	if value != "" && len(value) <= 128 {
+		valid := true
+		for _, c := range value {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return value
+		}
+	}
+	return newRequestID()
+}
+
+// newRequestID generates a random 16-byte hex request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -6,7 +6,9 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,7 +17,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -29,11 +30,28 @@ var (
 	ragMaxSnips  int
 	ragMaxChars  int
 
+	searchCacheSize     int
+	searchCacheTTL      int
+	searchCacheStaleTTL int
+
+	searchBatchMaxQueries  int
+	searchBatchConcurrency int
+
+	intentRoutes    map[int]string
+	crisisResources []string
+
+	safetyIntents    map[int]bool
+	safetyClassifier bool
+
 	tokenURL       = "https://platform.ai.gloo.com/oauth2/token"
 	searchURL      = "https://platform.ai.gloo.com/ai/data/v1/search"
 	completionsURL = "https://platform.ai.gloo.com/ai/v2/chat/completions"
 )
 
+// apiMinCertainty is the certainty threshold sent with every search, so the
+// API never returns results below it.
+const apiMinCertainty = 0.5
+
 // --- Types ---
 
 // SearchRequest is the request payload for the Search API.
@@ -57,7 +75,15 @@ type SearchProperties struct {
 	ItemTitle string   `json:"item_title"`
 	Type      string   `json:"type"`
 	Author    []string `json:"author"`
+	ItemTags  []string `json:"item_tags,omitempty"`
 	Snippet   string   `json:"snippet"`
+	// PublicationDate is the date the item was published, such as
+	// 2025-05-01, if it has one.
+	PublicationDate string `json:"publication_date,omitempty"`
+	// ItemID and ChunkIndex place the snippet within its item, for
+	// collections that return them.
+	ItemID     string `json:"item_id,omitempty"`
+	ChunkIndex *int   `json:"chunk_index,omitempty"`
 }
 
 // SearchResult is a single search result.
@@ -85,6 +111,7 @@ type CompletionRequest struct {
 	Messages    []CompletionMessage `json:"messages"`
 	AutoRouting bool                `json:"auto_routing"`
 	MaxTokens   int                 `json:"max_tokens"`
+	Stream      bool                `json:"stream,omitempty"`
 }
 
 // CompletionChoice is a single completion choice.
@@ -110,10 +137,20 @@ type Snippet struct {
 // SearchClient handles search requests.
 type SearchClient struct {
 	TokenManager *TokenManager
+	// Tenant, if set, is searched instead of GLOO_TENANT.
+	Tenant string
+}
+
+// tenant returns the tenant the client searches.
+func (sc *SearchClient) tenant() string {
+	if sc.Tenant != "" {
+		return sc.Tenant
+	}
+	return tenant
 }
 
 // Search performs a semantic search query.
-func (sc *SearchClient) Search(query string, limit int) (*SearchResponse, error) {
+func (sc *SearchClient) Search(ctx context.Context, query string, limit int) (*SearchResponse, error) {
 	token, err := sc.TokenManager.EnsureValidToken()
 	if err != nil {
 		return nil, err
@@ -122,9 +159,9 @@ func (sc *SearchClient) Search(query string, limit int) (*SearchResponse, error)
 	payload := SearchRequest{
 		Query:      query,
 		Collection: "GlooProd",
-		Tenant:     tenant,
+		Tenant:     sc.tenant(),
 		Limit:      limit,
-		Certainty:  0.5,
+		Certainty:  apiMinCertainty,
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -132,16 +169,16 @@ func (sc *SearchClient) Search(query string, limit int) (*SearchResponse, error)
 		return nil, fmt.Errorf("failed to marshal search request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", searchURL, bytes.NewBuffer(jsonData))
+	req, cancel, err := apiRequest(ctx, OpSearch, "POST", searchURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create search request: %w", err)
 	}
+	defer cancel()
 
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("search request failed: %w", err)
 	}
@@ -181,6 +218,22 @@ func (sc *SearchClient) FilterByContentType(results *SearchResponse, contentType
 	return &SearchResponse{Data: filtered, Intent: results.Intent}
 }
 
+// FilterByCertainty keeps the results with at least minCertainty.
+func (sc *SearchClient) FilterByCertainty(results *SearchResponse, minCertainty float64) *SearchResponse {
+	if results == nil || minCertainty <= 0 {
+		return results
+	}
+
+	var filtered []SearchResult
+	for _, r := range results.Data {
+		if r.Metadata.Certainty >= minCertainty {
+			filtered = append(filtered, r)
+		}
+	}
+
+	return &SearchResponse{Data: filtered, Intent: results.Intent}
+}
+
 // SortByCertainty sorts results by certainty score descending.
 func (sc *SearchClient) SortByCertainty(results *SearchResponse) {
 	if results == nil || len(results.Data) == 0 {
@@ -196,6 +249,10 @@ func (sc *SearchClient) SortByCertainty(results *SearchResponse) {
 // RAGHelper provides RAG workflow utilities.
 type RAGHelper struct {
 	TokenManager *TokenManager
+	Options      RAGOptions
+	// Tenant, if set, is searched for neighboring chunks instead of
+	// GLOO_TENANT.
+	Tenant string
 }
 
 // ExtractSnippets extracts and formats snippets from search results.
@@ -233,43 +290,56 @@ func (rh *RAGHelper) FormatContextForLLM(snippets []Snippet) string {
 	return strings.Join(parts, "\n---\n")
 }
 
-// GenerateWithContext calls Completions V2 API with custom context.
-func (rh *RAGHelper) GenerateWithContext(query, context, systemPrompt string) (string, error) {
-	token, err := rh.TokenManager.EnsureValidToken()
-	if err != nil {
-		return "", err
-	}
+// defaultRAGSystemPrompt is the system prompt for RAG answers when the
+// caller doesn't give one.
+const defaultRAGSystemPrompt = "You are a helpful assistant. Answer the user's question based on the " +
+	"provided context. If the context doesn't contain relevant information, " +
+	"say so honestly."
 
+// completionRequest builds the Completions V2 request that answers query
+// from llmContext.
+func (rh *RAGHelper) completionRequest(query, llmContext, systemPrompt string) CompletionRequest {
 	if systemPrompt == "" {
-		systemPrompt = "You are a helpful assistant. Answer the user's question based on the " +
-			"provided context. If the context doesn't contain relevant information, " +
-			"say so honestly."
+		systemPrompt = defaultRAGSystemPrompt
 	}
 
-	payload := CompletionRequest{
+	return CompletionRequest{
 		Messages: []CompletionMessage{
 			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: fmt.Sprintf("Context:\n%s\n\nQuestion: %s", context, query)},
+			{Role: "user", Content: fmt.Sprintf("Context:\n%s\n\nQuestion: %s", llmContext, query)},
 		},
 		AutoRouting: true,
 		MaxTokens:   ragMaxTokens,
 	}
+}
+
+// GenerateWithContext calls Completions V2 API with custom context.
+func (rh *RAGHelper) GenerateWithContext(ctx context.Context, query, llmContext, systemPrompt string) (string, error) {
+	return rh.complete(ctx, rh.completionRequest(query, llmContext, systemPrompt))
+}
+
+// complete sends a request to Completions V2 and returns the first choice.
+func (rh *RAGHelper) complete(ctx context.Context, payload CompletionRequest) (string, error) {
+	token, err := rh.TokenManager.EnsureValidToken()
+	if err != nil {
+		return "", err
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal completions request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", completionsURL, bytes.NewBuffer(jsonData))
+	req, cancel, err := apiRequest(ctx, OpCompletion, "POST", completionsURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create completions request: %w", err)
 	}
+	defer cancel()
 
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("completions request failed: %w", err)
 	}
@@ -292,26 +362,127 @@ func (rh *RAGHelper) GenerateWithContext(query, context, systemPrompt string) (s
 	return result.Choices[0].Message.Content, nil
 }
 
+// StreamWithContext is GenerateWithContext with the response streamed:
+// onDelta is called with each piece of text as Completions V2 generates it.
+// An error from onDelta stops the stream and is returned.
+func (rh *RAGHelper) StreamWithContext(ctx context.Context, query, llmContext, systemPrompt string, onDelta func(string) error) error {
+	token, err := rh.TokenManager.EnsureValidToken()
+	if err != nil {
+		return err
+	}
+
+	payload := rh.completionRequest(query, llmContext, systemPrompt)
+	payload.Stream = true
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal completions request: %w", err)
+	}
+
+	req, cancel, err := apiRequest(ctx, OpCompletion, "POST", completionsURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create completions request: %w", err)
+	}
+	defer cancel()
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("completions request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("completions API failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	// The stream is server-sent events, one JSON chunk per "data:" line,
+	// ending with "data: [DONE]"
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta CompletionMessage `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode completions stream: %w", err)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read completions stream: %w", err)
+	}
+	return nil
+}
+
 // --- Commands ---
 
-func basicSearch(query string, limit int) {
+func basicSearch(query string, limit int, opts SearchOptions) {
 	tm := NewTokenManager(clientID, clientSecret, tokenURL)
 	sc := &SearchClient{TokenManager: tm}
 
 	fmt.Printf("Searching for: '%s'\n", query)
-	fmt.Printf("Limit: %d results\n\n", limit)
+	fmt.Printf("Limit: %d results\n", limit)
+	if opts.MinCertainty > 0 {
+		fmt.Printf("Minimum certainty: %.2f\n", opts.MinCertainty)
+	}
+	if opts.HalfLife > 0 {
+		fmt.Printf("Ranking: recency-weighted (half-life %s, weight %.2f)\n", formatHalfLife(opts.HalfLife), opts.RecencyWeight)
+	}
+	fmt.Println()
 
-	results, err := sc.Search(query, limit)
+	results, err := sc.Search(context.Background(), query, fetchLimit(limit, opts))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
 		os.Exit(1)
 	}
+	if routeIntent(newIntentRouter(tm), query, results) {
+		return
+	}
+	returned := len(results.Data)
+	results = sc.FilterByCertainty(results, opts.MinCertainty)
+	ranking := recencyRanking(opts)
+	if ranking != nil {
+		ranking.Rerank(results)
+	}
+	sample := results.Data
+	if len(results.Data) > limit {
+		results = &SearchResponse{Data: results.Data[:limit], Intent: results.Intent}
+	}
 
 	if len(results.Data) == 0 {
-		fmt.Println("No results found.")
+		if returned > 0 {
+			fmt.Printf("No results met the minimum certainty (%d below %.2f).\n", returned, opts.MinCertainty)
+		} else {
+			fmt.Println("No results found.")
+		}
 		return
 	}
 
+	if opts.Explain {
+		printRankingExplanation(opts, returned-len(sample), returned)
+	}
 	fmt.Printf("Found %d results:\n\n", len(results.Data))
 
 	for i, r := range results.Data {
@@ -319,7 +490,14 @@ func basicSearch(query string, limit int) {
 		fmt.Printf("Title: %s\n", r.Properties.ItemTitle)
 		fmt.Printf("Type: %s\n", r.Properties.Type)
 		fmt.Printf("Author: %s\n", strings.Join(r.Properties.Author, ", "))
-		fmt.Printf("Relevance Score: %.4f\n", r.Metadata.Certainty)
+		if opts.Explain {
+			printScoreDetails(r)
+		} else {
+			fmt.Printf("Relevance Score: %.4f\n", r.Metadata.Certainty)
+		}
+		if ranking != nil {
+			ranking.printRecencyDetails(r)
+		}
 
 		snippet := r.Properties.Snippet
 		if len(snippet) > 200 {
@@ -330,9 +508,23 @@ func basicSearch(query string, limit int) {
 		}
 		fmt.Println()
 	}
+
+	if len(opts.Facets) > 0 {
+		printFacets(ComputeFacets(sample, opts.Facets), len(sample))
+	}
 }
 
-func filteredSearch(query string, contentTypes []string, limit int) {
+// fetchLimit returns how many results to request: enough to count facets
+// over, or for recency to bring fresh items up from below the limit, when
+// either is asked for, else the limit shown.
+func fetchLimit(limit int, opts SearchOptions) int {
+	if (len(opts.Facets) > 0 || opts.HalfLife > 0) && limit < facetSampleSize {
+		return facetSampleSize
+	}
+	return limit
+}
+
+func filteredSearch(query string, contentTypes []string, limit int, opts SearchOptions) {
 	tm := NewTokenManager(clientID, clientSecret, tokenURL)
 	sc := &SearchClient{TokenManager: tm}
 
@@ -340,83 +532,128 @@ func filteredSearch(query string, contentTypes []string, limit int) {
 	fmt.Printf("Content types: %s\n", strings.Join(contentTypes, ", "))
 	fmt.Printf("Limit: %d\n\n", limit)
 
-	results, err := sc.Search(query, limit)
+	results, err := sc.Search(context.Background(), query, fetchLimit(limit, opts))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
 		os.Exit(1)
 	}
 
+	if routeIntent(newIntentRouter(tm), query, results) {
+		return
+	}
+
 	filtered := sc.FilterByContentType(results, contentTypes)
+	matched := len(filtered.Data)
+	filtered = sc.FilterByCertainty(filtered, opts.MinCertainty)
+	ranking := recencyRanking(opts)
+	if ranking != nil {
+		ranking.Rerank(filtered)
+	}
+	sample := filtered.Data
+	if len(filtered.Data) > limit {
+		filtered = &SearchResponse{Data: filtered.Data[:limit], Intent: filtered.Intent}
+	}
 
 	if len(filtered.Data) == 0 {
 		fmt.Println("No results found matching filters.")
 		return
 	}
 
+	if opts.Explain {
+		printRankingExplanation(opts, matched-len(sample), matched)
+	}
 	fmt.Printf("Found %d results:\n\n", len(filtered.Data))
 
 	for i, r := range filtered.Data {
 		fmt.Printf("%d. %s (%s)\n", i+1, r.Properties.ItemTitle, r.Properties.Type)
+		if opts.Explain {
+			fmt.Print("   ")
+			printScoreDetails(r)
+		}
+		if ranking != nil {
+			fmt.Print("   ")
+			ranking.printRecencyDetails(r)
+		}
+	}
+
+	if len(opts.Facets) > 0 {
+		fmt.Println()
+		printFacets(ComputeFacets(sample, opts.Facets), len(sample))
 	}
 }
 
-func ragSearch(query string, limit int) {
+func ragSearch(query string, limit int, opts SearchOptions) {
 	tm := NewTokenManager(clientID, clientSecret, tokenURL)
-	sc := &SearchClient{TokenManager: tm}
-	rh := &RAGHelper{TokenManager: tm}
+	warn := func(err error) { fmt.Fprintf(os.Stderr, "Warning: %v\n", err) }
+	p := newRAGPipeline(tm, limit, opts, warn)
+	p.OnStage = func(stage string) {
+		switch stage {
+		case "retrieve":
+			fmt.Println("Step 1: Searching for relevant content...")
+		case "compress":
+			if ragOptions.Summarize {
+				fmt.Println("Step 2: Extracting and summarizing snippets...")
+			} else {
+				fmt.Println("Step 2: Extracting snippets...")
+			}
+		case "generate":
+			fmt.Println("Step 3: Generating response with context...")
+			fmt.Println()
+		}
+	}
 
 	fmt.Printf("RAG Search for: '%s'\n\n", query)
 
-	fmt.Println("Step 1: Searching for relevant content...")
-	results, err := sc.Search(query, limit)
+	result, err := p.Run(context.Background(), query, limit)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	if len(results.Data) == 0 {
-		fmt.Println("No results found.")
+	if result.Stopped {
+		if result.Answer != "" {
+			fmt.Println()
+			fmt.Println(result.Answer)
+		}
 		return
 	}
-
-	fmt.Printf("Found %d results\n\n", len(results.Data))
-
-	fmt.Println("Step 2: Extracting snippets...")
-	snippetLimit := limit
-	if snippetLimit > ragMaxSnips {
-		snippetLimit = ragMaxSnips
-	}
-	snippets := rh.ExtractSnippets(results, snippetLimit, ragMaxChars)
-	context := rh.FormatContextForLLM(snippets)
-	fmt.Printf("Extracted %d snippets\n\n", len(snippets))
-
-	fmt.Println("Step 3: Generating response with context...\n")
-	response, err := rh.GenerateWithContext(query, context, "")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "RAG generation failed: %v\n", err)
-		os.Exit(1)
+	if len(result.Results.Data) == 0 {
+		fmt.Println("No results found.")
+		return
 	}
 
+	fmt.Printf("Answered from %d snippets of %d results\n\n", len(result.Snippets), len(result.Results.Data))
 	fmt.Println("=== Generated Response ===")
-	fmt.Println(response)
+	fmt.Println(result.Answer)
 	fmt.Println("\n=== Sources Used ===")
-	for _, s := range snippets {
+	for _, s := range result.Snippets {
 		fmt.Printf("- %s (%s)\n", s.Title, s.Type)
 	}
 }
 
 func printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("  go run . search <query> [limit]")
-	fmt.Println("  go run . filter <query> <types> [limit]")
-	fmt.Println("  go run . rag <query> [limit]")
-	fmt.Println("  go run . server [port]")
+	fmt.Println("  go run . search <query> [limit] [--min-certainty=N] [--explain] [--facets=type,author,tags] [--half-life=30d [--recency-weight=0.3]]")
+	fmt.Println("  go run . filter <query> <types> [limit] [--min-certainty=N] [--explain] [--facets=type,author,tags] [--half-life=30d [--recency-weight=0.3]]")
+	fmt.Println("  go run . rag <query> [limit] [--min-certainty=N] [--half-life=30d [--recency-weight=0.3]]")
+	fmt.Println("  go run . search save <name> <query> [limit] [--min-certainty=N]")
+	fmt.Println("  go run . search saved")
+	fmt.Println("  go run . search delete <name>")
+	fmt.Println("  go run . search alerts [--interval=5m] [--webhook=URL] [--once]")
+	fmt.Println("  go run . server [port] [--frontend-dir=DIR] [--admin-addr=ADDR] [--tls-cert=FILE --tls-key=FILE | --autocert=DOMAINS]")
+	fmt.Println("  go run . grpc [port] [--tls-cert=FILE --tls-key=FILE]")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  go run . search \"How can I know my purpose?\" 5")
+	fmt.Println("  go run . search \"purpose\" --min-certainty=0.7 --explain")
+	fmt.Println("  go run . search \"purpose\" 5 --facets=type,author")
+	fmt.Println("  go run . search \"election news\" --half-life=14d --recency-weight=0.5")
+	fmt.Println("  go run . search save purpose \"How can I know my purpose?\" --min-certainty=0.75")
+	fmt.Println("  go run . search alerts --interval=10m")
 	fmt.Println("  go run . filter \"purpose\" \"Article,Video\" 10")
 	fmt.Println("  go run . rag \"How can I know my purpose?\" 3")
 	fmt.Println("  go run . server 3000")
+	fmt.Println("  go run . server 443 --autocert=search.example.com")
+	fmt.Println("  go run . grpc 50051")
 }
 
 func getEnv(key, fallback string) string {
@@ -469,6 +706,41 @@ func main() {
 	ragMaxTokens = getEnvInt("RAG_MAX_TOKENS", 3000)
 	ragMaxSnips = getEnvInt("RAG_CONTEXT_MAX_SNIPPETS", 5)
 	ragMaxChars = getEnvInt("RAG_CONTEXT_MAX_CHARS_PER_SNIPPET", 350)
+	searchCacheSize = getEnvInt("SEARCH_CACHE_SIZE", 256)
+	searchCacheTTL = getEnvInt("SEARCH_CACHE_TTL_SECONDS", 300)
+	searchCacheStaleTTL = getEnvInt("SEARCH_CACHE_STALE_SECONDS", 0)
+	searchBatchMaxQueries = getEnvInt("SEARCH_BATCH_MAX_QUERIES", 20)
+	searchBatchConcurrency = getEnvInt("SEARCH_BATCH_CONCURRENCY", 4)
+	crisisResources = parseCrisisResources(os.Getenv("CRISIS_RESOURCES"))
+	routes, err := parseIntentRoutes(os.Getenv("SEARCH_INTENT_ROUTES"))
+	if err == nil {
+		err = validateIntentRoutes(routes, crisisResources)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid SEARCH_INTENT_ROUTES: %v\n", err)
+		os.Exit(1)
+	}
+	intentRoutes = routes
+	safetyIntents, err = parseIntentList(os.Getenv("SAFETY_CRISIS_INTENTS"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid SAFETY_CRISIS_INTENTS: %v\n", err)
+		os.Exit(1)
+	}
+	safetyClassifier = strings.EqualFold(os.Getenv("SAFETY_CLASSIFIER"), "true")
+	if (len(safetyIntents) > 0 || safetyClassifier) && len(crisisResources) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: the safety policy is enabled but CRISIS_RESOURCES is empty")
+		os.Exit(1)
+	}
+	ragOptions = LoadRAGOptions()
+	if err := ragOptions.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid RAG options: %v\n", err)
+		os.Exit(1)
+	}
+	timeouts = LoadTimeoutPolicy()
+	if err := timeouts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid HTTP timeouts: %v\n", err)
+		os.Exit(1)
+	}
 
 	ValidateCredentials(clientID, clientSecret)
 
@@ -479,53 +751,64 @@ func main() {
 
 	command := strings.ToLower(os.Args[1])
 
-	// Server command doesn't need a query argument
-	if command == "server" {
-		port := "3000"
-		if len(os.Args) > 2 {
-			port = os.Args[2]
-		}
-		startServer(port)
+	// Server commands don't need a query argument
+	switch command {
+	case "server":
+		handleServerCommand()
+		return
+	case "grpc":
+		handleGRPCCommand()
+		return
+	}
+
+	if command == "search" && len(os.Args) > 2 && isSavedSearchCommand(os.Args[2]) {
+		handleSavedSearchCommand(os.Args[2:])
 		return
 	}
 
-	if len(os.Args) < 3 {
+	args, opts, err := parseSearchArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printUsage()
+		os.Exit(1)
+	}
+	if len(args) < 1 {
 		printUsage()
 		os.Exit(1)
 	}
 
-	query := os.Args[2]
+	query := args[0]
 
 	switch command {
 	case "search":
 		limit := 10
-		if len(os.Args) > 3 {
-			limit = parseLimitArg(os.Args[3], 10)
+		if len(args) > 1 {
+			limit = parseLimitArg(args[1], 10)
 		}
 		limit = normalizeLimit(limit, 10, 1, 100)
-		basicSearch(query, limit)
+		basicSearch(query, limit, opts)
 
 	case "filter":
-		if len(os.Args) < 4 {
+		if len(args) < 2 {
 			fmt.Fprintln(os.Stderr, "Error: Content types required for filter command")
 			printUsage()
 			os.Exit(1)
 		}
-		types := strings.Split(os.Args[3], ",")
+		types := strings.Split(args[1], ",")
 		limit := 10
-		if len(os.Args) > 4 {
-			limit = parseLimitArg(os.Args[4], 10)
+		if len(args) > 2 {
+			limit = parseLimitArg(args[2], 10)
 		}
 		limit = normalizeLimit(limit, 10, 1, 100)
-		filteredSearch(query, types, limit)
+		filteredSearch(query, types, limit, opts)
 
 	case "rag":
 		limit := 5
-		if len(os.Args) > 3 {
-			limit = parseLimitArg(os.Args[3], 5)
+		if len(args) > 1 {
+			limit = parseLimitArg(args[1], 5)
 		}
 		limit = normalizeLimit(limit, 5, 1, 100)
-		ragSearch(query, limit)
+		ragSearch(query, limit, opts)
 
 	default:
 		fmt.Fprintf(os.Stderr, "Error: Unknown command '%s'\n", command)
@@ -0,0 +1,233 @@
+// Gloo AI Search API - Metrics
+//
+// Request, upstream, and cache metrics for the proxy server, exposed at
+// /metrics in the Prometheus text exposition format. Profiling handlers
+// (net/http/pprof) are served on a separate admin listener when enabled.
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are histogram upper bounds in seconds. They extend past the
+// usual Prometheus defaults because RAG requests can take a minute or more.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// histogram is a cumulative Prometheus-style histogram.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+type requestKey struct {
+	route  string
+	method string
+	status int
+}
+
+// Metrics collects proxy server metrics.
+type Metrics struct {
+	mu              sync.Mutex
+	requests        map[requestKey]uint64
+	requestLatency  map[string]*histogram
+	upstreamLatency map[string]*histogram
+	upstreamErrors  map[string]uint64
+	cacheLookups    map[string]uint64
+}
+
+// NewMetrics creates an empty Metrics collector.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:        make(map[requestKey]uint64),
+		requestLatency:  make(map[string]*histogram),
+		upstreamLatency: make(map[string]*histogram),
+		upstreamErrors:  make(map[string]uint64),
+		cacheLookups:    make(map[string]uint64),
+	}
+}
+
+// serverMetrics is the collector used by the proxy server.
+var serverMetrics = NewMetrics()
+
+// ObserveRequest records a completed proxy request.
+func (m *Metrics) ObserveRequest(route, method string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{route: route, method: method, status: status}]++
+	observeInto(m.requestLatency, route, d)
+}
+
+// ObserveUpstream records a call to a Gloo API.
+func (m *Metrics) ObserveUpstream(op string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observeInto(m.upstreamLatency, op, d)
+	if err != nil {
+		m.upstreamErrors[op]++
+	}
+}
+
+// ObserveCache records a search cache lookup result (CacheHit, CacheMiss, or
+// CacheStale).
+func (m *Metrics) ObserveCache(status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cacheLookups[status]++
+}
+
+func observeInto(hs map[string]*histogram, key string, d time.Duration) {
+	h, ok := hs[key]
+	if !ok {
+		h = &histogram{}
+		hs[key] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+// cacheEntries is the current search cache size, or -1 when caching is off.
+func (m *Metrics) WriteTo(w io.Writer, cacheEntries int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP gloo_proxy_requests_total Proxy requests by route, method, and status code.")
+	fmt.Fprintln(w, "# TYPE gloo_proxy_requests_total counter")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "gloo_proxy_requests_total{route=%q,method=%q,status=\"%d\"} %d\n", k.route, k.method, k.status, m.requests[k])
+	}
+
+	writeHistograms(w, "gloo_proxy_request_duration_seconds", "Proxy request latency by route.", "route", m.requestLatency)
+	writeHistograms(w, "gloo_proxy_upstream_duration_seconds", "Gloo API call latency by operation.", "op", m.upstreamLatency)
+
+	fmt.Fprintln(w, "# HELP gloo_proxy_upstream_errors_total Failed Gloo API calls by operation.")
+	fmt.Fprintln(w, "# TYPE gloo_proxy_upstream_errors_total counter")
+	for _, op := range sortedKeys(m.upstreamLatency) {
+		fmt.Fprintf(w, "gloo_proxy_upstream_errors_total{op=%q} %d\n", op, m.upstreamErrors[op])
+	}
+
+	if cacheEntries >= 0 {
+		fmt.Fprintln(w, "# HELP gloo_proxy_search_cache_lookups_total Search cache lookups by result (HIT, MISS, STALE).")
+		fmt.Fprintln(w, "# TYPE gloo_proxy_search_cache_lookups_total counter")
+		for _, status := range []string{CacheHit, CacheMiss, CacheStale} {
+			fmt.Fprintf(w, "gloo_proxy_search_cache_lookups_total{result=%q} %d\n", status, m.cacheLookups[status])
+		}
+		fmt.Fprintln(w, "# HELP gloo_proxy_search_cache_entries Entries currently in the search cache.")
+		fmt.Fprintln(w, "# TYPE gloo_proxy_search_cache_entries gauge")
+		fmt.Fprintf(w, "gloo_proxy_search_cache_entries %d\n", cacheEntries)
+	}
+}
+
+func writeHistograms(w io.Writer, name, help, label string, hs map[string]*histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, key := range sortedKeys(hs) {
+		h := hs[key]
+		for i, le := range latencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s=%q,le=%q} %d\n", name, label, key, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", name, label, key, h.count)
+		fmt.Fprintf(w, "%s_sum{%s=%q} %s\n", name, label, key, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s=%q} %d\n", name, label, key, h.count)
+	}
+}
+
+func sortedKeys(hs map[string]*histogram) []string {
+	keys := make([]string, 0, len(hs))
+	for k := range hs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// routeLabel maps a request path to a bounded set of route names, so chat
+// IDs and static asset paths don't create a metric series each.
+func routeLabel(path string) string {
+	switch path {
+	case "/api/search", "/api/search/batch", "/api/search/rag", "/api/chat/message",
+		"/api/openapi.json", "/docs", "/metrics", grpcSearchPath, grpcRagPath, grpcStreamRagPath:
+		return path
+	}
+	if strings.HasPrefix(path, "/api/search/rag/sessions/") {
+		return "/api/search/rag/sessions/{id}"
+	}
+	if strings.HasPrefix(path, "/api/chat/") {
+		if strings.HasSuffix(path, "/message") {
+			return "/api/chat/{id}/message"
+		}
+		return "/api/chat/{id}"
+	}
+	if strings.HasPrefix(path, "/api/") {
+		return "other"
+	}
+	return "static"
+}
+
+// handleMetrics serves the metrics in the Prometheus text format.
+func handleMetrics(m *Metrics, cache *SearchCache, breakers *CircuitBreakers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries := -1
+		if cache != nil {
+			entries = cache.Len()
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(w, entries)
+		breakers.WriteMetrics(w)
+	}
+}
+
+// newAdminServer returns a server exposing net/http/pprof profiling handlers
+// and metrics on addr. It should be bound to a private interface.
+func newAdminServer(addr string, metricsHandler http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/metrics", metricsHandler)
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		// Profiles and traces stream for up to their requested duration.
+		WriteTimeout: serverWriteTimeout(),
+		IdleTimeout:  serverIdleTimeout,
+	}
+}
@@ -0,0 +1,478 @@
+// Gloo AI Search API - OpenAPI Specification
+//
+// An OpenAPI 3 document describing the proxy server's endpoints, served at
+// /api/openapi.json, plus a Swagger UI page at /docs for browsing it.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// openAPIVersion is the version of the proxy API described by the spec.
+const openAPIVersion = "1.0.0"
+
+// swaggerUIVersion is the Swagger UI release loaded by the /docs page.
+const swaggerUIVersion = "5.17.14"
+
+type jsonObject = map[string]any
+
+// schemaRef returns a reference to a named component schema.
+func schemaRef(name string) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonContent wraps a schema as an application/json media type.
+func jsonContent(schema jsonObject) jsonObject {
+	return jsonObject{"application/json": jsonObject{"schema": schema}}
+}
+
+// errorResponse describes an ErrorResponse body.
+func errorResponse(description string) jsonObject {
+	return jsonObject{
+		"description": description,
+		"content":     jsonContent(schemaRef("ErrorResponse")),
+	}
+}
+
+// openAPISpec builds the OpenAPI document for the proxy API.
+func openAPISpec() jsonObject {
+	requestIDHeader := jsonObject{
+		RequestIDHeader: jsonObject{
+			"description": "Request ID, echoed from the request when supplied.",
+			"schema":      jsonObject{"type": "string"},
+		},
+	}
+
+	searchHeaders := jsonObject{
+		RequestIDHeader: requestIDHeader[RequestIDHeader],
+		"X-Cache": jsonObject{
+			"description": "Response cache status (present when caching is enabled).",
+			"schema":      jsonObject{"type": "string", "enum": []string{CacheHit, CacheMiss, CacheStale}},
+		},
+	}
+
+	ragHeaders := jsonObject{
+		RequestIDHeader: requestIDHeader[RequestIDHeader],
+		"X-Cache": jsonObject{
+			"description": "Whether the session's cached context was used (present when the request has a sessionId and the context cache is enabled).",
+			"schema":      jsonObject{"type": "string", "enum": []string{CacheHit, CacheMiss}},
+		},
+	}
+
+	tenantHeaderParam := jsonObject{
+		"name":        TenantHeader,
+		"in":          "header",
+		"description": "Tenant to search instead of the server's default. Must be on the server's tenant allowlist.",
+		"schema":      jsonObject{"type": "string"},
+	}
+
+	circuitOpenResponse := errorResponse("A Gloo API is failing and its circuit breaker is open. Retry after the number of seconds in the Retry-After header.")
+
+	chatIDParam := jsonObject{
+		"name":     "chatId",
+		"in":       "path",
+		"required": true,
+		"schema":   jsonObject{"type": "string"},
+	}
+
+	paths := jsonObject{
+		"/api/search": jsonObject{
+			"get": jsonObject{
+				"operationId": "search",
+				"summary":     "Semantic search",
+				"description": "Runs a semantic search against the content of the server's default tenant, or of an allowed tenant named in the request.",
+				"parameters": []jsonObject{
+					{
+						"name":        "q",
+						"in":          "query",
+						"required":    true,
+						"description": "Search query.",
+						"schema":      jsonObject{"type": "string"},
+					},
+					{
+						"name":        "limit",
+						"in":          "query",
+						"description": "Maximum number of results.",
+						"schema":      jsonObject{"type": "integer", "minimum": 1, "maximum": 100, "default": 10},
+					},
+					{
+						"name":        "certainty",
+						"in":          "query",
+						"description": "Minimum certainty of the results returned.",
+						"schema":      jsonObject{"type": "number", "minimum": 0, "maximum": 1},
+					},
+					{
+						"name":        "tenant",
+						"in":          "query",
+						"description": "Tenant to search, if the header isn't set.",
+						"schema":      jsonObject{"type": "string"},
+					},
+					tenantHeaderParam,
+				},
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "Search results.",
+						"headers":     searchHeaders,
+						"content":     jsonContent(schemaRef("SearchResponse")),
+					},
+					"400": errorResponse("Missing query parameter, or a parameter out of bounds."),
+					"403": errorResponse("The tenant is not allowed."),
+					"413": errorResponse("The request body is too large."),
+					"500": errorResponse("Upstream search failed."),
+					"503": circuitOpenResponse,
+				},
+			},
+		},
+		"/api/search/batch": jsonObject{
+			"post": jsonObject{
+				"operationId": "batchSearch",
+				"summary":     "Run several searches at once",
+				"description": "Runs each query with bounded upstream concurrency. A failing query reports an error in its own result; the others still succeed.",
+				"parameters":  []jsonObject{tenantHeaderParam},
+				"requestBody": jsonObject{
+					"required": true,
+					"content":  jsonContent(schemaRef("BatchSearchRequest")),
+				},
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "Per-query results, in request order.",
+						"headers":     requestIDHeader,
+						"content":     jsonContent(schemaRef("BatchSearchResponse")),
+					},
+					"400": errorResponse("Missing or too many queries, or a query or limit out of bounds."),
+					"403": errorResponse("The tenant is not allowed."),
+					"413": errorResponse("The request body is too large."),
+				},
+			},
+		},
+		"/api/search/rag": jsonObject{
+			"post": jsonObject{
+				"operationId": "ragSearch",
+				"summary":     "Search and generate an answer (RAG)",
+				"description": "Searches for relevant content and generates an answer with Completions V2 using the results as context.",
+				"parameters":  []jsonObject{tenantHeaderParam},
+				"requestBody": jsonObject{
+					"required": true,
+					"content":  jsonContent(schemaRef("RAGRequest")),
+				},
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "Generated answer and the sources used.",
+						"headers":     ragHeaders,
+						"content":     jsonContent(schemaRef("RAGResponse")),
+					},
+					"400": errorResponse("Missing query field, a field out of bounds, or invalid format or sessionId."),
+					"403": errorResponse("The tenant is not allowed."),
+					"413": errorResponse("The request body is too large."),
+					"500": errorResponse("Upstream search or generation failed."),
+					"503": circuitOpenResponse,
+					"502": errorResponse("The model did not return a valid structured answer."),
+				},
+			},
+		},
+		"/api/search/rag/sessions/{sessionId}": jsonObject{
+			"delete": jsonObject{
+				"operationId": "invalidateRAGSession",
+				"summary":     "Drop a session's cached RAG contexts",
+				"description": "Removes the snippets cached for the session, so its next RAG requests search again.",
+				"parameters": []jsonObject{{
+					"name":     "sessionId",
+					"in":       "path",
+					"required": true,
+					"schema":   jsonObject{"type": "string"},
+				}},
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "How many cached contexts were removed.",
+						"headers":     requestIDHeader,
+						"content":     jsonContent(schemaRef("RAGSessionInvalidation")),
+					},
+					"404": errorResponse("Invalid session ID."),
+				},
+			},
+		},
+		"/api/chat/message": jsonObject{
+			"post": jsonObject{
+				"operationId": "startChat",
+				"summary":     "Start a new chat",
+				"description": "Sends the first message of a new chat. The response's chat_id identifies the chat for follow-up messages.",
+				"requestBody": jsonObject{
+					"required": true,
+					"content":  jsonContent(schemaRef("ChatMessageRequest")),
+				},
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "The assistant's reply.",
+						"headers":     requestIDHeader,
+						"content":     jsonContent(schemaRef("MessageResponse")),
+					},
+					"400": errorResponse("Invalid JSON or missing message field."),
+					"500": errorResponse("Upstream chat request failed."),
+					"503": circuitOpenResponse,
+				},
+			},
+		},
+		"/api/chat/{chatId}": jsonObject{
+			"parameters": []jsonObject{chatIDParam},
+			"get": jsonObject{
+				"operationId": "getChatHistory",
+				"summary":     "Chat history",
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "All messages in the chat.",
+						"headers":     requestIDHeader,
+						"content":     jsonContent(schemaRef("ChatHistory")),
+					},
+					"404": errorResponse("Chat not found."),
+					"500": errorResponse("Upstream chat request failed."),
+					"503": circuitOpenResponse,
+				},
+			},
+		},
+		"/api/chat/{chatId}/message": jsonObject{
+			"parameters": []jsonObject{chatIDParam},
+			"post": jsonObject{
+				"operationId": "sendChatMessage",
+				"summary":     "Send a message to an existing chat",
+				"requestBody": jsonObject{
+					"required": true,
+					"content":  jsonContent(schemaRef("ChatMessageRequest")),
+				},
+				"responses": jsonObject{
+					"200": jsonObject{
+						"description": "The assistant's reply.",
+						"headers":     requestIDHeader,
+						"content":     jsonContent(schemaRef("MessageResponse")),
+					},
+					"400": errorResponse("Invalid JSON or missing message field."),
+					"404": errorResponse("Chat not found."),
+					"500": errorResponse("Upstream chat request failed."),
+					"503": circuitOpenResponse,
+				},
+			},
+		},
+	}
+
+	schemas := jsonObject{
+		"SearchMetadata": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"distance":  jsonObject{"type": "number"},
+				"certainty": jsonObject{"type": "number"},
+				"score":     jsonObject{"type": "number"},
+			},
+		},
+		"SearchProperties": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"item_title":       jsonObject{"type": "string"},
+				"type":             jsonObject{"type": "string"},
+				"author":           jsonObject{"type": "array", "items": jsonObject{"type": "string"}},
+				"item_tags":        jsonObject{"type": "array", "items": jsonObject{"type": "string"}},
+				"snippet":          jsonObject{"type": "string"},
+				"publication_date": jsonObject{"type": "string"},
+				"item_id":          jsonObject{"type": "string"},
+				"chunk_index":      jsonObject{"type": "integer"},
+			},
+		},
+		"SearchResult": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"uuid":       jsonObject{"type": "string"},
+				"metadata":   schemaRef("SearchMetadata"),
+				"properties": schemaRef("SearchProperties"),
+				"collection": jsonObject{"type": "string"},
+			},
+		},
+		"SearchResponse": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"data":   jsonObject{"type": "array", "items": schemaRef("SearchResult")},
+				"intent": jsonObject{"type": "integer"},
+			},
+		},
+		"BatchSearchQuery": jsonObject{
+			"type":     "object",
+			"required": []string{"query"},
+			"properties": jsonObject{
+				"query": jsonObject{"type": "string"},
+				"limit": jsonObject{"type": "integer", "minimum": 1, "maximum": 100, "default": 10},
+			},
+		},
+		"BatchSearchRequest": jsonObject{
+			"type":     "object",
+			"required": []string{"queries"},
+			"properties": jsonObject{
+				"queries": jsonObject{"type": "array", "minItems": 1, "items": schemaRef("BatchSearchQuery")},
+			},
+		},
+		"BatchSearchResult": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"query":   jsonObject{"type": "string"},
+				"limit":   jsonObject{"type": "integer"},
+				"results": schemaRef("SearchResponse"),
+				"error":   jsonObject{"type": "string"},
+			},
+		},
+		"BatchSearchResponse": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"results": jsonObject{"type": "array", "items": schemaRef("BatchSearchResult")},
+			},
+		},
+		"RAGRequest": jsonObject{
+			"type":     "object",
+			"required": []string{"query"},
+			"properties": jsonObject{
+				"query":        jsonObject{"type": "string"},
+				"limit":        jsonObject{"type": "integer", "minimum": 1, "maximum": maxSearchLimit, "default": 5},
+				"systemPrompt": jsonObject{"type": "string", "description": "Overrides the default system prompt."},
+				"format":       jsonObject{"type": "string", "enum": []string{RAGFormatText, RAGFormatStructured}, "default": RAGFormatText, "description": "structured returns a validated JSON answer with key points and citations."},
+				"sessionId":    jsonObject{"type": "string", "maxLength": 128, "description": "Caches the retrieved snippets for this session, so repeating the query only generates."},
+				"tenant":       jsonObject{"type": "string", "description": "Tenant to search, if the X-Gloo-Tenant header isn't set."},
+				"certainty":    jsonObject{"type": "number", "minimum": 0, "maximum": 1, "description": "Minimum certainty of the results answered from."},
+			},
+		},
+		"RAGSessionInvalidation": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"sessionId": jsonObject{"type": "string"},
+				"removed":   jsonObject{"type": "integer"},
+			},
+		},
+		"SourceInfo": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"title": jsonObject{"type": "string"},
+				"type":  jsonObject{"type": "string"},
+			},
+		},
+		"RAGResponse": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"response":   jsonObject{"type": "string"},
+				"sources":    jsonObject{"type": "array", "items": schemaRef("SourceInfo")},
+				"safety":     jsonObject{"type": "boolean", "description": "Set when the query was flagged by the safety policy and the response is helpline resources."},
+				"variant":    jsonObject{"type": "string", "description": "Prompt experiment variant that generated the response, if the session is in an experiment."},
+				"structured": schemaRef("StructuredAnswer"),
+			},
+		},
+		"StructuredAnswer": jsonObject{
+			"type":     "object",
+			"required": []string{"answer", "key_points", "citations"},
+			"properties": jsonObject{
+				"answer":     jsonObject{"type": "string"},
+				"key_points": jsonObject{"type": "array", "items": jsonObject{"type": "string"}},
+				"citations":  jsonObject{"type": "array", "items": schemaRef("Citation")},
+			},
+		},
+		"Citation": jsonObject{
+			"type":     "object",
+			"required": []string{"source", "title"},
+			"properties": jsonObject{
+				"source": jsonObject{"type": "integer", "description": "1-based position of the cited source in sources."},
+				"title":  jsonObject{"type": "string"},
+				"quote":  jsonObject{"type": "string"},
+			},
+		},
+		"ChatMessageRequest": jsonObject{
+			"type":     "object",
+			"required": []string{"message"},
+			"properties": jsonObject{
+				"message": jsonObject{"type": "string"},
+			},
+		},
+		"MessageResponse": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"chat_id":     jsonObject{"type": "string"},
+				"query_id":    jsonObject{"type": "string"},
+				"message_id":  jsonObject{"type": "string"},
+				"message":     jsonObject{"type": "string"},
+				"timestamp":   jsonObject{"type": "string"},
+				"success":     jsonObject{"type": "boolean"},
+				"suggestions": jsonObject{"type": "array", "items": jsonObject{"type": "string"}},
+				"sources":     jsonObject{"type": "array", "items": jsonObject{"type": "object"}},
+				"safety":      jsonObject{"type": "boolean", "description": "Set when the message was flagged by the safety policy and answered with helpline resources."},
+			},
+		},
+		"ChatMessage": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"query_id":        jsonObject{"type": "string"},
+				"message_id":      jsonObject{"type": "string"},
+				"timestamp":       jsonObject{"type": "string"},
+				"role":            jsonObject{"type": "string"},
+				"message":         jsonObject{"type": "string"},
+				"character_limit": jsonObject{"type": "integer"},
+			},
+		},
+		"ChatHistory": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"chat_id":    jsonObject{"type": "string"},
+				"created_at": jsonObject{"type": "string"},
+				"messages":   jsonObject{"type": "array", "items": schemaRef("ChatMessage")},
+			},
+		},
+		"ErrorResponse": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"error": jsonObject{"type": "string"},
+				"field": jsonObject{"type": "string", "description": "The invalid query parameter or body field, for validation errors."},
+			},
+		},
+	}
+
+	return jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":       "Gloo AI Search Proxy API",
+			"version":     openAPIVersion,
+			"description": "Proxy endpoints that call the Gloo AI Search and Completions V2 APIs with server-side credentials.",
+		},
+		"servers":    []jsonObject{{"url": "/"}},
+		"paths":      paths,
+		"components": jsonObject{"schemas": schemas},
+	}
+}
+
+// handleOpenAPISpec serves the OpenAPI document.
+func handleOpenAPISpec(spec []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// handleSwaggerUI serves a Swagger UI page for the OpenAPI document.
+func handleSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Gloo AI Search Proxy API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`))
+}
+
+// mustMarshalOpenAPISpec renders the OpenAPI document once at startup.
+func mustMarshalOpenAPISpec() []byte {
+	spec, err := json.MarshalIndent(openAPISpec(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
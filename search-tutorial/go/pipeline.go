@@ -0,0 +1,260 @@
+// Gloo AI Search API - RAG Pipeline
+//
+// Runs RAG as a series of stages: Retrieve, Filter, Rerank, Compress,
+// Generate, and Verify. Each stage is an interface, so one can be swapped,
+// for example for a custom reranker, without changing the rag command.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Retriever fetches candidate results for a query.
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, limit int) (*SearchResponse, error)
+}
+
+// ResultFilter narrows the retrieved results. It may return a
+// StopPipeline error to answer the query itself.
+type ResultFilter interface {
+	Filter(ctx context.Context, query string, results *SearchResponse) (*SearchResponse, error)
+}
+
+// Reranker reorders the results in place.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, results *SearchResponse) error
+}
+
+// Compressor turns the results into the snippets the answer is generated
+// from.
+type Compressor interface {
+	Compress(ctx context.Context, query string, results *SearchResponse) ([]Snippet, error)
+}
+
+// Generator answers the query from the snippets.
+type Generator interface {
+	Generate(ctx context.Context, query string, snippets []Snippet) (string, error)
+}
+
+// Verifier checks a generated answer against its snippets, and returns the
+// answer to give, which may be revised.
+type Verifier interface {
+	Verify(ctx context.Context, query, answer string, snippets []Snippet) (string, error)
+}
+
+// StopPipeline is returned by a stage that answered the query itself, such
+// as the safety check. Answer is empty if the stage already showed its
+// answer.
+type StopPipeline struct {
+	Answer string
+}
+
+func (s *StopPipeline) Error() string {
+	return "pipeline stopped early"
+}
+
+// Pipeline is a configured RAG flow. Filters and Rerankers run in order;
+// Verifier is optional.
+type Pipeline struct {
+	Retriever  Retriever
+	Filters    []ResultFilter
+	Rerankers  []Reranker
+	Compressor Compressor
+	Generator  Generator
+	Verifier   Verifier
+
+	// FetchLimit is how many results to retrieve, so filters and rerankers
+	// have more than the limit to choose from. It defaults to the limit.
+	FetchLimit int
+	// OnStage, if set, is called as each stage starts.
+	OnStage func(stage string)
+}
+
+// RAGResult is the outcome of a pipeline run.
+type RAGResult struct {
+	Results  *SearchResponse
+	Snippets []Snippet
+	Answer   string
+	// Stopped is set when a stage answered the query itself, in which case
+	// Answer is that stage's answer.
+	Stopped bool
+}
+
+// Run answers query from at most limit results. If nothing is left after
+// filtering, it returns without generating, with Results empty.
+func (p *Pipeline) Run(ctx context.Context, query string, limit int) (*RAGResult, error) {
+	fetch := p.FetchLimit
+	if fetch < limit {
+		fetch = limit
+	}
+
+	p.stage("retrieve")
+	results, err := p.Retriever.Retrieve(ctx, query, fetch)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	p.stage("filter")
+	for _, f := range p.Filters {
+		results, err = f.Filter(ctx, query, results)
+		var stop *StopPipeline
+		if errors.As(err, &stop) {
+			return &RAGResult{Results: results, Answer: stop.Answer, Stopped: true}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("filtering failed: %w", err)
+		}
+	}
+
+	p.stage("rerank")
+	for _, r := range p.Rerankers {
+		if err := r.Rerank(ctx, query, results); err != nil {
+			return nil, fmt.Errorf("reranking failed: %w", err)
+		}
+	}
+	if len(results.Data) > limit {
+		results = &SearchResponse{Data: results.Data[:limit], Intent: results.Intent}
+	}
+
+	out := &RAGResult{Results: results}
+	if len(results.Data) == 0 {
+		return out, nil
+	}
+
+	p.stage("compress")
+	if out.Snippets, err = p.Compressor.Compress(ctx, query, results); err != nil {
+		return nil, fmt.Errorf("building the context failed: %w", err)
+	}
+
+	p.stage("generate")
+	if out.Answer, err = p.Generator.Generate(ctx, query, out.Snippets); err != nil {
+		return nil, fmt.Errorf("RAG generation failed: %w", err)
+	}
+
+	if p.Verifier != nil {
+		p.stage("verify")
+		if out.Answer, err = p.Verifier.Verify(ctx, query, out.Answer, out.Snippets); err != nil {
+			return nil, fmt.Errorf("verification failed: %w", err)
+		}
+	}
+
+	return out, nil
+}
+
+func (p *Pipeline) stage(name string) {
+	if p.OnStage != nil {
+		p.OnStage(name)
+	}
+}
+
+// --- Stages ---
+
+// searchRetriever retrieves with the Search API.
+type searchRetriever struct {
+	sc *SearchClient
+}
+
+func (s searchRetriever) Retrieve(ctx context.Context, query string, limit int) (*SearchResponse, error) {
+	return s.sc.Search(ctx, query, limit)
+}
+
+// safetyFilter stops the pipeline with the safety policy's response for
+// flagged queries.
+type safetyFilter struct {
+	policy  *SafetyPolicy
+	onError func(error)
+}
+
+func (f safetyFilter) Filter(ctx context.Context, query string, results *SearchResponse) (*SearchResponse, error) {
+	if checkSafety(ctx, f.policy, query, results.Intent, f.onError) {
+		return results, &StopPipeline{Answer: f.policy.Response()}
+	}
+	return results, nil
+}
+
+// intentFilter runs the intent router, stopping the pipeline when a handler
+// answered the query.
+type intentFilter struct {
+	router *IntentRouter
+}
+
+func (f intentFilter) Filter(ctx context.Context, query string, results *SearchResponse) (*SearchResponse, error) {
+	if routeIntent(f.router, query, results) {
+		return results, &StopPipeline{}
+	}
+	return results, nil
+}
+
+// certaintyFilter drops results below a certainty.
+type certaintyFilter struct {
+	min float64
+}
+
+func (f certaintyFilter) Filter(ctx context.Context, query string, results *SearchResponse) (*SearchResponse, error) {
+	return (&SearchClient{}).FilterByCertainty(results, f.min), nil
+}
+
+// recencyReranker adapts RecencyRanking to the Reranker interface.
+type recencyReranker struct {
+	ranking *RecencyRanking
+}
+
+func (r recencyReranker) Rerank(ctx context.Context, query string, results *SearchResponse) error {
+	r.ranking.Rerank(results)
+	return nil
+}
+
+// snippetCompressor extracts snippets, summarizing long ones when RAG
+// summarization is on. Snippets that couldn't be summarized are reported
+// through onError and cut off.
+type snippetCompressor struct {
+	rh          *RAGHelper
+	maxSnippets int
+	maxChars    int
+	onError     func(error)
+}
+
+func (c snippetCompressor) Compress(ctx context.Context, query string, results *SearchResponse) ([]Snippet, error) {
+	snippets, err := c.rh.PrepareSnippets(ctx, query, results, c.maxSnippets, c.maxChars)
+	if err != nil {
+		c.onError(err)
+	}
+	return snippets, nil
+}
+
+// completionGenerator answers with Completions V2.
+type completionGenerator struct {
+	rh           *RAGHelper
+	systemPrompt string
+}
+
+func (g completionGenerator) Generate(ctx context.Context, query string, snippets []Snippet) (string, error) {
+	return g.rh.GenerateWithContext(ctx, query, g.rh.FormatContextForLLM(snippets), g.systemPrompt)
+}
+
+// newRAGPipeline builds the pipeline the rag command runs.
+func newRAGPipeline(tm *TokenManager, limit int, opts SearchOptions, warn func(error)) *Pipeline {
+	rh := &RAGHelper{TokenManager: tm, Options: ragOptions}
+	snippetLimit := limit
+	if snippetLimit > ragMaxSnips {
+		snippetLimit = ragMaxSnips
+	}
+
+	p := &Pipeline{
+		Retriever: searchRetriever{sc: &SearchClient{TokenManager: tm}},
+		Filters: []ResultFilter{
+			safetyFilter{policy: newSafetyPolicy(rh), onError: warn},
+			intentFilter{router: newIntentRouter(tm)},
+			certaintyFilter{min: opts.MinCertainty},
+		},
+		Compressor: snippetCompressor{rh: rh, maxSnippets: snippetLimit, maxChars: ragMaxChars, onError: warn},
+		Generator:  completionGenerator{rh: rh},
+		FetchLimit: fetchLimit(limit, opts),
+	}
+	if ranking := recencyRanking(opts); ranking != nil {
+		p.Rerankers = append(p.Rerankers, recencyReranker{ranking: ranking})
+	}
+	return p
+}
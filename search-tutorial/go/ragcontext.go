@@ -0,0 +1,152 @@
+// Gloo AI Search API - RAG Context Cache
+//
+// An in-memory LRU cache of the snippets retrieved for RAG, per session and
+// query, so a session that asks the same question again (for example with a
+// different system prompt) pays only for generation.
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ragContextEntry is the context retrieved for one session and query.
+type ragContextEntry struct {
+	session  string
+	key      string
+	snippets []Snippet
+	storedAt time.Time
+}
+
+// RAGContextCache is an LRU cache of RAG snippets keyed on session and
+// retrieval. Entries expire after TTL. A nil cache caches nothing.
+type RAGContextCache struct {
+	Capacity int
+	TTL      time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewRAGContextCache creates a new RAGContextCache.
+func NewRAGContextCache(capacity int, ttl time.Duration) *RAGContextCache {
+	return &RAGContextCache{
+		Capacity: capacity,
+		TTL:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// ragRetrieval is the search a RAG context was retrieved with.
+type ragRetrieval struct {
+	Tenant    string
+	Query     string
+	Limit     int
+	Certainty float64
+}
+
+// ragContextKey combines a session with the search cache's normalized
+// tenant, query, and limit, and the certainty.
+func ragContextKey(session string, q ragRetrieval) string {
+	return fmt.Sprintf("%s|%s|%g", session, searchCacheKey(q.Tenant, q.Query, q.Limit), q.Certainty)
+}
+
+// Get returns the snippets cached for a session's retrieval. Requests
+// without a session are never cached.
+func (c *RAGContextCache) Get(session string, q ragRetrieval) ([]Snippet, bool) {
+	if c == nil || session == "" {
+		return nil, false
+	}
+	key := ragContextKey(session, q)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*ragContextEntry)
+	if time.Since(entry.storedAt) > c.TTL {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.snippets, true
+}
+
+// Put stores the snippets of a session's retrieval, evicting the least
+// recently used entry when full.
+func (c *RAGContextCache) Put(session string, q ragRetrieval, snippets []Snippet) {
+	if c == nil || session == "" {
+		return
+	}
+	key := ragContextKey(session, q)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*ragContextEntry)
+		entry.snippets = snippets
+		entry.storedAt = time.Now()
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&ragContextEntry{session: session, key: key, snippets: snippets, storedAt: time.Now()})
+	c.items[key] = el
+
+	for c.Capacity > 0 && c.ll.Len() > c.Capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Invalidate removes every entry for a session, across tenants, and
+// returns how many there were.
+func (c *RAGContextCache) Invalidate(session string) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*ragContextEntry).session == session {
+			c.removeElement(el)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+// removeElement removes an entry. The caller must hold c.mu.
+func (c *RAGContextCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*ragContextEntry).key)
+}
+
+// validSessionID reports whether a session ID is short and printable enough
+// to key the cache and appear in URLs.
+func validSessionID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return !strings.ContainsAny(id, "/|?# \t\r\n")
+}
+
+// newRAGContextCacheFromEnv returns the RAG context cache configured by the
+// environment, or nil when it is disabled.
+func newRAGContextCacheFromEnv() *RAGContextCache {
+	size := getEnvInt("RAG_CONTEXT_CACHE_SIZE", 512)
+	if size <= 0 {
+		return nil
+	}
+	return NewRAGContextCache(size, time.Duration(getEnvInt("RAG_CONTEXT_CACHE_TTL_SECONDS", 900))*time.Second)
+}
@@ -0,0 +1,134 @@
+// Gloo AI Search API - Recency Ranking
+//
+// Reranks search results on the client, blending each result's certainty
+// with how recently it was published, so publishers of news-like content
+// can prefer fresh items.
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRecencyWeight is how much recency counts when --half-life is set
+// without --recency-weight.
+const defaultRecencyWeight = 0.3
+
+// publicationDateLayouts are the publication_date formats understood.
+var publicationDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// RecencyRanking blends certainty with recency. An item's freshness halves
+// every HalfLife since it was published, and its score is
+// (1 - Weight) * certainty + Weight * freshness.
+type RecencyRanking struct {
+	HalfLife time.Duration
+	Weight   float64
+	Now      time.Time
+}
+
+// parseHalfLife reads a half-life such as 30d, 2w, or 36h.
+func parseHalfLife(value string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(value, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit != 0 {
+		n, err := strconv.ParseFloat(strings.TrimRight(value, "dw"), 64)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("--half-life must be a positive duration such as 30d, got %q", value)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("--half-life must be a positive duration such as 30d, got %q", value)
+	}
+	return d, nil
+}
+
+// publicationTime returns when a result was published, if it has a
+// publication_date.
+func publicationTime(r SearchResult) (time.Time, bool) {
+	date := strings.TrimSpace(r.Properties.PublicationDate)
+	for _, layout := range publicationDateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Freshness returns 1 for an item published now, 0.5 for one a half-life
+// old, and so on. Items without a publication date get 0, and items dated
+// in the future get 1.
+func (rr RecencyRanking) Freshness(r SearchResult) float64 {
+	published, ok := publicationTime(r)
+	if !ok {
+		return 0
+	}
+	age := rr.Now.Sub(published)
+	if age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(rr.HalfLife))
+}
+
+// Score returns a result's blended score.
+func (rr RecencyRanking) Score(r SearchResult) float64 {
+	return (1-rr.Weight)*r.Metadata.Certainty + rr.Weight*rr.Freshness(r)
+}
+
+// Rerank sorts results by blended score, highest first. Results with equal
+// scores keep the API's order.
+func (rr RecencyRanking) Rerank(results *SearchResponse) {
+	if results == nil || len(results.Data) == 0 {
+		return
+	}
+	order := make([]int, len(results.Data))
+	scores := make([]float64, len(results.Data))
+	for i, r := range results.Data {
+		order[i] = i
+		scores[i] = rr.Score(r)
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	sorted := make([]SearchResult, len(results.Data))
+	for i, idx := range order {
+		sorted[i] = results.Data[idx]
+	}
+	results.Data = sorted
+}
+
+// recencyRanking returns the ranking opts asks for, or nil for the API's
+// own order.
+func recencyRanking(opts SearchOptions) *RecencyRanking {
+	if opts.HalfLife <= 0 {
+		return nil
+	}
+	return &RecencyRanking{HalfLife: opts.HalfLife, Weight: opts.RecencyWeight, Now: time.Now()}
+}
+
+// formatHalfLife formats a half-life in days when it is a whole number of
+// them.
+func formatHalfLife(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// printRecencyDetails prints how recency changed a result's score.
+func (rr RecencyRanking) printRecencyDetails(r SearchResult) {
+	published := "unknown"
+	if t, ok := publicationTime(r); ok {
+		published = fmt.Sprintf("%s (%.0f days ago)", t.Format("2006-01-02"), rr.Now.Sub(t).Hours()/24)
+	}
+	fmt.Printf("Published: %s  Freshness: %.4f  Blended Score: %.4f\n", published, rr.Freshness(r), rr.Score(r))
+}
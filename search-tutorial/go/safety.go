@@ -0,0 +1,128 @@
+// Gloo AI Search API - Safety Policy
+//
+// Checks RAG and chat queries for signs of self-harm or crisis before an
+// answer is generated, and answers those with helpline resources instead.
+// A query is flagged when the Search API returns one of the configured
+// crisis intents, or, optionally, when a classifier prompt says so.
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultSafetyMessage introduces the resources in a safety response.
+const defaultSafetyMessage = "It sounds like you may be going through something really hard. " +
+	"You don't have to face it alone. Please reach out to one of these resources:"
+
+// safetyClassifierPrompt asks Completions V2 whether a message needs a
+// safety response.
+const safetyClassifierPrompt = "You are a safety classifier. Reply with exactly YES if the user's " +
+	"message expresses thoughts of suicide or self-harm, or describes a crisis in which " +
+	"they or someone else may be in danger. Otherwise reply with exactly NO."
+
+// noSearchIntent is the intent checked for queries that weren't searched,
+// such as chat messages.
+const noSearchIntent = -1
+
+// SafetyPolicy decides which queries get helpline resources in place of a
+// generated answer. A nil policy flags nothing.
+type SafetyPolicy struct {
+	// CrisisIntents are the Search API intent codes that are always flagged.
+	CrisisIntents map[int]bool
+	// Classifier, if set, is asked about queries whose intent wasn't
+	// flagged, and about chat messages, which have no intent.
+	Classifier func(ctx context.Context, query string) (bool, error)
+	Resources  []string
+	Message    string
+}
+
+// Check reports whether a query needs a safety response. intent is the
+// Search API's intent for the query, or noSearchIntent.
+func (p *SafetyPolicy) Check(ctx context.Context, query string, intent int) (bool, error) {
+	if p == nil {
+		return false, nil
+	}
+	if p.CrisisIntents[intent] {
+		return true, nil
+	}
+	if p.Classifier == nil {
+		return false, nil
+	}
+	return p.Classifier(ctx, query)
+}
+
+// Response returns the answer given to flagged queries.
+func (p *SafetyPolicy) Response() string {
+	var b strings.Builder
+	b.WriteString(p.Message)
+	for _, r := range p.Resources {
+		fmt.Fprintf(&b, "\n- %s", r)
+	}
+	return b.String()
+}
+
+// classifySafety asks Completions V2 whether a query needs a safety
+// response.
+func (rh *RAGHelper) classifySafety(ctx context.Context, query string) (bool, error) {
+	answer, err := rh.complete(ctx, CompletionRequest{
+		Messages: []CompletionMessage{
+			{Role: "system", Content: safetyClassifierPrompt},
+			{Role: "user", Content: query},
+		},
+		AutoRouting: true,
+		MaxTokens:   5,
+	})
+	if err != nil {
+		return false, fmt.Errorf("safety classifier failed: %w", err)
+	}
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(answer)), "YES"), nil
+}
+
+// parseIntentList reads a comma-separated list of intent codes.
+func parseIntentList(spec string) (map[int]bool, error) {
+	intents := map[int]bool{}
+	for _, code := range strings.Split(spec, ",") {
+		if code = strings.TrimSpace(code); code == "" {
+			continue
+		}
+		intent, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, fmt.Errorf("intent code '%s' is not a number", code)
+		}
+		intents[intent] = true
+	}
+	return intents, nil
+}
+
+// newSafetyPolicy builds the policy configured by SAFETY_CRISIS_INTENTS and
+// SAFETY_CLASSIFIER, or returns nil if neither is set. The classifier, if
+// enabled, calls Completions V2 through rh.
+func newSafetyPolicy(rh *RAGHelper) *SafetyPolicy {
+	if len(safetyIntents) == 0 && !safetyClassifier {
+		return nil
+	}
+	p := &SafetyPolicy{
+		CrisisIntents: safetyIntents,
+		Resources:     crisisResources,
+		Message:       getEnv("SAFETY_MESSAGE", defaultSafetyMessage),
+	}
+	if safetyClassifier {
+		p.Classifier = rh.classifySafety
+	}
+	return p
+}
+
+// checkSafety runs the policy. A check that fails is reported through
+// onError and treated as not flagged, so a classifier outage doesn't block
+// every answer.
+func checkSafety(ctx context.Context, p *SafetyPolicy, query string, intent int, onError func(error)) bool {
+	flagged, err := p.Check(ctx, query, intent)
+	if err != nil {
+		onError(err)
+		return false
+	}
+	return flagged
+}
@@ -7,20 +7,47 @@
 // Start with:
 //
 //	go run . server
+//	go run . server 443 --tls-cert=cert.pem --tls-key=key.pem
+//	go run . server 443 --autocert=search.example.com
 //
 // Endpoints:
 //
 //	GET  /api/search?q=<query>&limit=<limit>  - Basic search
+//	POST /api/search/batch                     - Multiple searches in one request
 //	POST /api/search/rag                       - Search + RAG with Completions V2
+//	DELETE /api/search/rag/sessions/<id>       - Drop a session's cached RAG contexts
+//	GET  /api/chat/<id>                        - Chat history
+//	POST /api/chat/<id>/message                - Send a chat message
+//	POST /api/chat/message                     - Start a new chat
+//	GET  /api/openapi.json                     - OpenAPI 3 specification
+//	GET  /docs                                 - Swagger UI
+//	GET  /metrics                              - Prometheus metrics
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+)
+
+// Server timeouts. The write timeout is derived from the HTTP timeout
+// policy by serverWriteTimeout.
+const (
+	serverReadHeaderTimeout = 10 * time.Second
+	serverReadTimeout       = 30 * time.Second
+	serverIdleTimeout       = 120 * time.Second
+	// serverWriteMargin is the time left to send a response after the
+	// longest chain of Gloo API calls a request can wait for.
+	serverWriteMargin = 30 * time.Second
 )
 
 // RAGRequest is the JSON body for the RAG endpoint.
@@ -28,12 +55,38 @@ type RAGRequest struct {
 	Query        string `json:"query"`
 	Limit        int    `json:"limit"`
 	SystemPrompt string `json:"systemPrompt"`
+	// Format is RAGFormatText (the default) or RAGFormatStructured.
+	Format string `json:"format"`
+	// SessionID, if set, caches the retrieved snippets for the session, so
+	// asking the same query again only generates.
+	SessionID string `json:"sessionId"`
+	// Tenant, if set, is searched instead of GLOO_TENANT. It must be on the
+	// server's tenant allowlist; the X-Gloo-Tenant header takes precedence.
+	Tenant string `json:"tenant"`
+	// Certainty, if set, drops results below it before answering.
+	Certainty float64 `json:"certainty"`
 }
 
 // RAGResponse is the JSON response from the RAG endpoint.
 type RAGResponsePayload struct {
 	Response string       `json:"response"`
 	Sources  []SourceInfo `json:"sources"`
+	// Variant is the prompt experiment variant that generated the
+	// response, if the session is in an experiment.
+	Variant string `json:"variant,omitempty"`
+	// Structured is the full answer when the request asked for the
+	// structured format; Response is then its answer text.
+	Structured *StructuredAnswer `json:"structured,omitempty"`
+	// Safety is set when the safety policy flagged the query and Response
+	// is the helpline resources instead of an answer.
+	Safety bool `json:"safety,omitempty"`
+}
+
+// RAGSessionInvalidation is the JSON response from dropping a session's
+// cached RAG contexts.
+type RAGSessionInvalidation struct {
+	SessionID string `json:"sessionId"`
+	Removed   int    `json:"removed"`
 }
 
 // SourceInfo is a source reference in the RAG response.
@@ -45,109 +98,249 @@ type SourceInfo struct {
 // ErrorResponse is a JSON error response.
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Field names the invalid query parameter or body field, for input
+	// validation errors.
+	Field string `json:"field,omitempty"`
+}
+
+// ServerOptions configures the proxy server.
+type ServerOptions struct {
+	Port string
+	// FrontendDir overrides the embedded frontend; missing assets fall back
+	// to the embedded copy.
+	FrontendDir string
+	TLS         TLSOptions
+	CORS        CORSPolicy
+	Limits      RequestLimits
+	// AdminAddr, when set, serves pprof profiling handlers on a separate
+	// listener. Bind it to a private interface such as localhost.
+	AdminAddr string
 }
 
-func startServer(port string) {
+func startServer(opts ServerOptions) {
+
+	breakers := newCircuitBreakersFromEnv()
+	httpClient.Transport = breakers.Transport(httpClient.Transport)
+
 	tm := NewTokenManager(clientID, clientSecret, tokenURL)
-	sc := &SearchClient{TokenManager: tm}
-	rh := &RAGHelper{TokenManager: tm}
+	rh := &RAGHelper{TokenManager: tm, Options: ragOptions}
+	cc := &ChatClient{TokenManager: tm}
+	safety := newSafetyPolicy(rh)
+	ragContexts := newRAGContextCacheFromEnv()
+	tenants := newTenantAllowlistFromEnv()
+	experiment, err := newPromptExperimentFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	frontendDir, _ := filepath.Abs(filepath.Join(".", "..", "frontend-example", "simple-html"))
+	cache := newSearchCacheFromEnv()
+
+	frontend, err := frontendFileSystem(opts.FrontendDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid frontend directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	search := func(r *http.Request, tenant, q string, limit int) (*SearchResponse, string, error) {
+		return cachedSearch(&SearchClient{TokenManager: tm, Tenant: tenant}, cache, r, q, limit)
+	}
 
 	mux := http.NewServeMux()
 
 	// API: Basic search
 	mux.HandleFunc("/api/search", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
 		w.Header().Set("Content-Type", "application/json")
 
 		q := r.URL.Query().Get("q")
 		if q == "" {
 			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "Query parameter 'q' is required"})
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Query parameter 'q' is required", Field: "q"})
 			return
 		}
 
-		limitStr := r.URL.Query().Get("limit")
-		limit := 10
-		if limitStr != "" {
-			limit = parseLimitArg(limitStr, 10)
+		// Parameters were validated by opts.Limits
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		limit = normalizeLimit(limit, 10, 1, maxSearchLimit)
+		certainty, _ := strconv.ParseFloat(r.URL.Query().Get("certainty"), 64)
+
+		tenant, ok := resolveTenant(w, r, tenants, r.URL.Query().Get("tenant"))
+		if !ok {
+			return
 		}
-		limit = normalizeLimit(limit, 10, 1, 100)
 
-		results, err := sc.Search(q, limit)
+		results, status, err := search(r, tenant, q, limit)
+		if status != "" {
+			w.Header().Set("X-Cache", status)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Search error: %v\n", err)
+			logRequestError(r, "search", err)
+			if writeCircuitOpen(w, err) {
+				return
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(ErrorResponse{Error: "Search request failed"})
 			return
 		}
+		results = (&SearchClient{}).FilterByCertainty(results, certainty)
 
 		json.NewEncoder(w).Encode(results)
 	})
 
-	// API: RAG search
-	mux.HandleFunc("/api/search/rag", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	// API: Batch search
+	mux.HandleFunc("/api/search/batch", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var body BatchSearchRequest
+		if !decodeJSONBody(w, r, &body) {
+			return
+		}
+		if len(body.Queries) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Field 'queries' must be a non-empty array", Field: "queries"})
+			return
+		}
+		if len(body.Queries) > searchBatchMaxQueries {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Error: fmt.Sprintf("At most %d queries are allowed per batch", searchBatchMaxQueries),
+				Field: "queries",
+			})
+			return
+		}
+		for i, q := range body.Queries {
+			err := opts.Limits.CheckQuery(fmt.Sprintf("queries[%d].query", i), q.Query)
+			if err == nil {
+				err = checkLimit(fmt.Sprintf("queries[%d].limit", i), q.Limit)
+			}
+			if err != nil {
+				writeValidationError(w, err)
+				return
+			}
+		}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
+		tenant, ok := resolveTenant(w, r, tenants, "")
+		if !ok {
 			return
 		}
 
+		results := runBatchSearch(body.Queries, searchBatchConcurrency, func(q string, limit int) (*SearchResponse, error) {
+			results, _, err := search(r, tenant, q, limit)
+			if err != nil {
+				logRequestError(r, "batch search", err)
+			}
+			return results, err
+		})
+
+		json.NewEncoder(w).Encode(BatchSearchResponse{Results: results})
+	})
+
+	// API: RAG search
+	mux.HandleFunc("/api/search/rag", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		var body RAGRequest
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Query == "" {
+		if !decodeJSONBody(w, r, &body) {
+			return
+		}
+		if body.Query == "" {
 			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "Field 'query' is required"})
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Field 'query' is required", Field: "query"})
+			return
+		}
+		verr := opts.Limits.CheckQuery("query", body.Query)
+		if verr == nil {
+			verr = checkLimit("limit", body.Limit)
+		}
+		if verr == nil {
+			verr = checkCertainty("certainty", body.Certainty)
+		}
+		if verr != nil {
+			writeValidationError(w, verr)
 			return
 		}
 
-		body.Limit = normalizeLimit(body.Limit, 5, 1, 100)
+		body.Limit = normalizeLimit(body.Limit, 5, 1, maxSearchLimit)
 
-		// Step 1: Search
-		results, err := sc.Search(body.Query, body.Limit)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "RAG search error: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "RAG request failed"})
+		switch body.Format {
+		case "", RAGFormatText, RAGFormatStructured:
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Field 'format' must be 'text' or 'structured'", Field: "format"})
 			return
 		}
 
-		if len(results.Data) == 0 {
-			json.NewEncoder(w).Encode(RAGResponsePayload{
-				Response: "No relevant content found.",
-				Sources:  []SourceInfo{},
-			})
+		if body.SessionID != "" && !validSessionID(body.SessionID) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Field 'sessionId' must be 1-128 characters without spaces or /|?#", Field: "sessionId"})
 			return
 		}
 
-		// Step 2: Extract snippets and format context
-		snippetLimit := body.Limit
-		if snippetLimit > ragMaxSnips {
-			snippetLimit = ragMaxSnips
+		tenant, ok := resolveTenant(w, r, tenants, body.Tenant)
+		if !ok {
+			return
 		}
-		snippets := rh.ExtractSnippets(results, snippetLimit, ragMaxChars)
-		context := rh.FormatContextForLLM(snippets)
+		sc := &SearchClient{TokenManager: tm, Tenant: tenant}
+		rh := &RAGHelper{TokenManager: tm, Options: ragOptions, Tenant: tenant}
 
-		// Step 3: Generate response
-		generatedResponse, err := rh.GenerateWithContext(body.Query, context, body.SystemPrompt)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "RAG generation error: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(ErrorResponse{Error: "RAG request failed"})
-			return
+		// A session asking the same question again reuses its snippets. Only
+		// queries that passed the safety check and found content are cached,
+		// and only if every snippet was prepared in full.
+		retrieval := ragRetrieval{Tenant: tenant, Query: body.Query, Limit: body.Limit, Certainty: body.Certainty}
+		snippets, hit := ragContexts.Get(body.SessionID, retrieval)
+		if ragContexts != nil && body.SessionID != "" {
+			if hit {
+				w.Header().Set("X-Cache", CacheHit)
+			} else {
+				w.Header().Set("X-Cache", CacheMiss)
+			}
+		}
+		if !hit {
+			// Step 1: Search
+			start := time.Now()
+			results, err := sc.Search(r.Context(), body.Query, body.Limit)
+			recordUpstream(r, "search", time.Since(start), err)
+			if err != nil {
+				logRequestError(r, "rag search", err)
+				if writeCircuitOpen(w, err) {
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(ErrorResponse{Error: "RAG request failed"})
+				return
+			}
+			results = sc.FilterByCertainty(results, body.Certainty)
+
+			// Crisis queries get helpline resources instead of an answer
+			onSafetyError := func(err error) { logRequestError(r, "safety check", err) }
+			if checkSafety(r.Context(), safety, body.Query, results.Intent, onSafetyError) {
+				json.NewEncoder(w).Encode(RAGResponsePayload{
+					Response: safety.Response(),
+					Sources:  []SourceInfo{},
+					Safety:   true,
+				})
+				return
+			}
+
+			if len(results.Data) == 0 {
+				json.NewEncoder(w).Encode(RAGResponsePayload{
+					Response: "No relevant content found.",
+					Sources:  []SourceInfo{},
+				})
+				return
+			}
+
+			// Step 2: Extract snippets
+			snippetLimit := body.Limit
+			if snippetLimit > ragMaxSnips {
+				snippetLimit = ragMaxSnips
+			}
+			snippets, err = rh.PrepareSnippets(r.Context(), body.Query, results, snippetLimit, ragMaxChars)
+			if err != nil {
+				logRequestError(r, "rag summarize", err)
+			} else {
+				ragContexts.Put(body.SessionID, retrieval, snippets)
+			}
 		}
 
 		sources := make([]SourceInfo, len(snippets))
@@ -155,41 +348,437 @@ func startServer(port string) {
 			sources[i] = SourceInfo{Title: s.Title, Type: s.Type}
 		}
 
+		// Sessions in a prompt experiment get their variant's prompt, unless
+		// the request brings its own
+		systemPrompt, variant := body.SystemPrompt, ""
+		if experiment != nil && body.SessionID != "" && body.SystemPrompt == "" {
+			v := experiment.Assign(body.SessionID)
+			systemPrompt, variant = v.Prompt, v.Name
+			recordVariant(r, variant)
+		}
+
+		// Step 3: Generate response
+		if body.Format == RAGFormatStructured {
+			start := time.Now()
+			answer, err := rh.GenerateStructured(r.Context(), body.Query, snippets, systemPrompt)
+			recordUpstream(r, "completions", time.Since(start), err)
+			if errors.Is(err, errInvalidStructuredAnswer) {
+				logRequestError(r, "rag generation", err)
+				w.WriteHeader(http.StatusBadGateway)
+				json.NewEncoder(w).Encode(ErrorResponse{Error: "The model did not return a valid structured answer"})
+				return
+			}
+			if err != nil {
+				logRequestError(r, "rag generation", err)
+				if writeCircuitOpen(w, err) {
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(ErrorResponse{Error: "RAG request failed"})
+				return
+			}
+			json.NewEncoder(w).Encode(RAGResponsePayload{
+				Response:   answer.Answer,
+				Sources:    sources,
+				Variant:    variant,
+				Structured: answer,
+			})
+			return
+		}
+
+		llmContext := rh.FormatContextForLLM(snippets)
+		start := time.Now()
+		generatedResponse, err := rh.GenerateWithContext(r.Context(), body.Query, llmContext, systemPrompt)
+		recordUpstream(r, "completions", time.Since(start), err)
+		if err != nil {
+			logRequestError(r, "rag generation", err)
+			if writeCircuitOpen(w, err) {
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "RAG request failed"})
+			return
+		}
+
 		json.NewEncoder(w).Encode(RAGResponsePayload{
 			Response: generatedResponse,
 			Sources:  sources,
+			Variant:  variant,
 		})
 	})
 
+	// API: Drop a session's cached RAG contexts
+	mux.HandleFunc("/api/search/rag/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		session := strings.TrimPrefix(r.URL.Path, "/api/search/rag/sessions/")
+		if !validSessionID(session) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Not found"})
+			return
+		}
+		if r.Method != "DELETE" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Method not allowed"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(RAGSessionInvalidation{
+			SessionID: session,
+			Removed:   ragContexts.Invalidate(session),
+		})
+	})
+
+	// API: Chat history and messages
+	//
+	//	GET  /api/chat/{id}          - Chat history
+	//	POST /api/chat/{id}/message  - Send a message to an existing chat
+	//	POST /api/chat/message       - Start a new chat
+	mux.HandleFunc("/api/chat/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/chat/"), "/")
+
+		switch {
+		case r.Method == "GET" && len(parts) == 1 && parts[0] != "":
+			start := time.Now()
+			history, err := cc.GetChatHistory(r.Context(), parts[0])
+			recordUpstream(r, "chat_history", time.Since(start), err)
+			if err != nil {
+				logRequestError(r, "chat history", err)
+				writeChatError(w, err)
+				return
+			}
+			json.NewEncoder(w).Encode(history)
+
+		case r.Method == "POST" && (len(parts) == 1 && parts[0] == "message" ||
+			len(parts) == 2 && parts[0] != "" && parts[1] == "message"):
+			chatID := ""
+			if len(parts) == 2 {
+				chatID = parts[0]
+			}
+
+			var body ChatMessageRequest
+			if !decodeJSONBody(w, r, &body) {
+				return
+			}
+			if strings.TrimSpace(body.Message) == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(ErrorResponse{Error: "Field 'message' is required", Field: "message"})
+				return
+			}
+
+			onSafetyError := func(err error) { logRequestError(r, "safety check", err) }
+			if checkSafety(r.Context(), safety, body.Message, noSearchIntent, onSafetyError) {
+				json.NewEncoder(w).Encode(MessageResponse{
+					ChatID:  chatID,
+					Message: safety.Response(),
+					Success: true,
+					Safety:  true,
+				})
+				return
+			}
+
+			start := time.Now()
+			resp, err := cc.SendMessage(r.Context(), body.Message, chatID)
+			recordUpstream(r, "chat_message", time.Since(start), err)
+			if err != nil {
+				logRequestError(r, "chat message", err)
+				writeChatError(w, err)
+				return
+			}
+			json.NewEncoder(w).Encode(resp)
+
+		case len(parts) <= 2 && parts[0] != "":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Method not allowed"})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "Not found"})
+		}
+	})
+
+	// Metrics
+	metricsHandler := handleMetrics(serverMetrics, cache, breakers)
+	mux.Handle("/metrics", metricsHandler)
+
+	// API documentation
+	mux.HandleFunc("/api/openapi.json", handleOpenAPISpec(mustMarshalOpenAPISpec()))
+	mux.HandleFunc("/docs", handleSwaggerUI)
+
 	// Serve frontend static files
-	fileServer := http.FileServer(http.Dir(frontendDir))
-	mux.Handle("/", fileServer)
+	mux.Handle("/", http.FileServer(frontend))
 
-	fmt.Printf("Search API proxy server running at http://localhost:%s\n", port)
-	fmt.Printf("Frontend available at http://localhost:%s\n", port)
+	scheme, host := "http", "localhost"
+	if opts.TLS.Enabled() {
+		scheme = "https"
+	}
+	if opts.TLS.Autocert() {
+		host = opts.TLS.AutocertDomains[0]
+	}
+	baseURL := fmt.Sprintf("%s://%s:%s", scheme, host, opts.Port)
+
+	fmt.Printf("Search API proxy server running at %s\n", baseURL)
+	fmt.Printf("Frontend available at %s\n", baseURL)
 	fmt.Printf("\nAPI endpoints:\n")
-	fmt.Printf("  GET  http://localhost:%s/api/search?q=your+query&limit=10\n", port)
-	fmt.Printf("  POST http://localhost:%s/api/search/rag\n", port)
+	fmt.Printf("  GET  %s/api/search?q=your+query&limit=10\n", baseURL)
+	fmt.Printf("  POST %s/api/search/batch\n", baseURL)
+	fmt.Printf("  POST %s/api/search/rag\n", baseURL)
+	fmt.Printf("  DELETE %s/api/search/rag/sessions/<session_id>\n", baseURL)
+	fmt.Printf("  GET  %s/api/chat/<chat_id>\n", baseURL)
+	fmt.Printf("  POST %s/api/chat/<chat_id>/message\n", baseURL)
+	fmt.Printf("  POST %s/api/chat/message\n", baseURL)
+	fmt.Printf("  GET  %s/api/openapi.json\n", baseURL)
+	fmt.Printf("\nAPI docs available at %s/docs\n", baseURL)
+	fmt.Printf("Metrics available at %s/metrics\n", baseURL)
+	fmt.Printf("\nCORS allowed origins: %s\n", strings.Join(opts.CORS.AllowedOrigins, ", "))
+	if opts.FrontendDir != "" {
+		fmt.Printf("\nFrontend directory: %s (embedded assets as fallback)\n", opts.FrontendDir)
+	}
+	if cache != nil {
+		fmt.Printf("\nSearch cache: %d entries, TTL %ds, stale %ds\n", searchCacheSize, searchCacheTTL, searchCacheStaleTTL)
+	}
+	if ragContexts != nil {
+		fmt.Printf("RAG context cache: %d entries, TTL %s\n", ragContexts.Capacity, ragContexts.TTL)
+	}
+	if allowed := tenants.Tenants(); len(allowed) > 1 {
+		fmt.Printf("Tenants: %s (default %s)\n", strings.Join(allowed, ", "), tenants.Default)
+	}
+	if experiment != nil {
+		fmt.Printf("Prompt experiment: %s (%d variants)\n", experiment.Name, len(experiment.Variants))
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + opts.Port,
+		Handler:           withAccessLog(opts.CORS.Handler(opts.Limits.Handler(withCompression(mux)))),
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout(),
+		IdleTimeout:       serverIdleTimeout,
+	}
 
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
+	serve := srv.ListenAndServe
+	var companions []*http.Server
+	if opts.TLS.Enabled() {
+		var challenge *http.Server
+		serve, challenge = configureTLS(srv, opts.TLS)
+		if challenge != nil {
+			companions = append(companions, challenge)
+			fmt.Printf("ACME challenge listener on %s\n", challenge.Addr)
+		}
+	}
+
+	if opts.AdminAddr != "" {
+		companions = append(companions, newAdminServer(opts.AdminAddr, metricsHandler))
+		fmt.Printf("Admin (pprof) listener on %s\n", opts.AdminAddr)
+	}
+
+	if err := runServer(srv, serve, nil, companions...); err != nil {
 		fmt.Fprintf(os.Stderr, "Server failed: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func init() {
-	// Register "server" as a valid command by patching main's switch
-	// This is handled in main() below
+// newSearchCacheFromEnv returns the search cache configured by the
+// environment, or nil when caching is disabled.
+func newSearchCacheFromEnv() *SearchCache {
+	if searchCacheSize <= 0 {
+		return nil
+	}
+	return NewSearchCache(
+		searchCacheSize,
+		time.Duration(searchCacheTTL)*time.Second,
+		time.Duration(searchCacheStaleTTL)*time.Second,
+	)
+}
+
+// cachedSearch runs a search through cache when it is enabled. The returned
+// cache status is empty when caching is disabled. Cached searches aren't
+// cancelled when the client disconnects: their results serve later
+// requests, and stale entries are refreshed after the response is sent.
+func cachedSearch(sc *SearchClient, cache *SearchCache, r *http.Request, q string, limit int) (*SearchResponse, string, error) {
+	if cache == nil {
+		start := time.Now()
+		results, err := sc.Search(r.Context(), q, limit)
+		recordUpstream(r, "search", time.Since(start), err)
+		return results, "", err
+	}
+	results, status, err := cache.Fetch(sc.tenant(), q, limit, func() (results *SearchResponse, err error) {
+		start := time.Now()
+		defer func() { recordUpstream(r, "search", time.Since(start), err) }()
+		return sc.Search(context.Background(), q, limit)
+	})
+	serverMetrics.ObserveCache(status)
+	return results, status, err
 }
 
-// addServerCommand patches into the main command dispatch.
-// Called from main.go's main() function if command is "server".
+// writeCircuitOpen answers a request whose Gloo API call was refused by an
+// open circuit breaker with a 503, and reports whether it did.
+func writeCircuitOpen(w http.ResponseWriter, err error) bool {
+	var open *CircuitOpenError
+	if !errors.As(err, &open) {
+		return false
+	}
+	seconds := int(math.Ceil(open.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: circuitOpenMessage})
+	return true
+}
+
+// writeChatError reports a failed chat request, passing through "not found"
+// from the Chat API and hiding other upstream details from the client.
+func writeChatError(w http.ResponseWriter, err error) {
+	if writeCircuitOpen(w, err) {
+		return
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.StatusCode == http.StatusNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Chat not found"})
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: "Chat request failed"})
+}
+
+// runServer starts srv with serve (and any companion servers, such as the
+// ACME challenge listener) and runs until SIGINT or SIGTERM is received. It
+// then stops accepting new connections and waits for in-flight requests (such
+// as long-running RAG generations) to finish before returning. drain, when
+// set, also waits for requests Shutdown can't see.
+func runServer(srv *http.Server, serve func() error, drain func(context.Context), companions ...*http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1+len(companions))
+	go func() {
+		errCh <- serve()
+	}()
+	for _, c := range companions {
+		go func(c *http.Server) {
+			errCh <- c.ListenAndServe()
+		}(c)
+	}
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	stop()
+	fmt.Println("\nShutting down, waiting for in-flight requests to finish...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverWriteTimeout())
+	defer cancel()
+
+	for _, c := range companions {
+		c.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if drain != nil {
+		drain(shutdownCtx)
+	}
+
+	fmt.Println("Server stopped")
+	return nil
+}
+
+// handleServerCommand parses the server command's arguments:
+//
+//	server [port] [--port=N] [--frontend-dir=DIR] [--admin-addr=ADDR]
+//	       [--tls-cert=FILE --tls-key=FILE]
+//	       [--autocert=DOMAINS] [--autocert-cache=DIR] [--autocert-email=EMAIL]
+//	       [--autocert-http=ADDR]
 func handleServerCommand() {
-	port := "3000"
+	opts := ServerOptions{
+		Port:   "3000",
+		CORS:   LoadCORSPolicy(),
+		Limits: LoadRequestLimits(),
+		TLS: TLSOptions{
+			AutocertCache:    "autocert-cache",
+			AutocertHTTPAddr: ":80",
+		},
+	}
+
 	for _, arg := range os.Args[2:] {
-		if strings.HasPrefix(arg, "--port=") {
-			port = strings.TrimPrefix(arg, "--port=")
+		switch {
+		case strings.HasPrefix(arg, "--port="):
+			opts.Port = strings.TrimPrefix(arg, "--port=")
+		case strings.HasPrefix(arg, "--admin-addr="):
+			opts.AdminAddr = strings.TrimPrefix(arg, "--admin-addr=")
+		case strings.HasPrefix(arg, "--frontend-dir="):
+			opts.FrontendDir = strings.TrimPrefix(arg, "--frontend-dir=")
+		case strings.HasPrefix(arg, "--tls-cert="):
+			opts.TLS.CertFile = strings.TrimPrefix(arg, "--tls-cert=")
+		case strings.HasPrefix(arg, "--tls-key="):
+			opts.TLS.KeyFile = strings.TrimPrefix(arg, "--tls-key=")
+		case strings.HasPrefix(arg, "--autocert="):
+			opts.TLS.AutocertDomains = splitList(strings.TrimPrefix(arg, "--autocert="))
+		case strings.HasPrefix(arg, "--autocert-cache="):
+			opts.TLS.AutocertCache = strings.TrimPrefix(arg, "--autocert-cache=")
+		case strings.HasPrefix(arg, "--autocert-email="):
+			opts.TLS.AutocertEmail = strings.TrimPrefix(arg, "--autocert-email=")
+		case strings.HasPrefix(arg, "--autocert-http="):
+			opts.TLS.AutocertHTTPAddr = strings.TrimPrefix(arg, "--autocert-http=")
+		case strings.HasPrefix(arg, "--"):
+			fmt.Fprintf(os.Stderr, "Error: Unknown server option '%s'\n", arg)
+			printUsage()
+			os.Exit(1)
+		default:
+			opts.Port = arg
 		}
 	}
-	startServer(port)
+
+	if err := opts.TLS.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := opts.CORS.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid CORS configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if err := opts.Limits.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	startServer(opts)
+}
+
+// ragWait returns the longest a RAG request can wait on the Gloo APIs: a
+// search and a completion, plus a safety classification, neighboring chunks,
+// and snippet summaries when those are enabled.
+func ragWait() time.Duration {
+	wait := timeouts.Search + timeouts.Completion
+	if safetyClassifier {
+		wait += timeouts.Completion
+	}
+	if ragOptions.Window > 0 {
+		wait += timeouts.Search
+	}
+	if ragOptions.Summarize {
+		wait += timeouts.Completion
+	}
+	return wait
+}
+
+// serverWriteTimeout returns the write timeout for the servers: the longer
+// of a RAG request and a chat message, plus serverWriteMargin, so raising
+// an HTTP timeout never cuts off a response the policy allows.
+func serverWriteTimeout() time.Duration {
+	wait := ragWait()
+	if timeouts.Chat > wait {
+		wait = timeouts.Chat
+	}
+	return wait + serverWriteMargin
 }
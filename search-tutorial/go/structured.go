@@ -0,0 +1,134 @@
+// Gloo AI Search API - Structured Answers
+//
+// A RAG answer mode for programmatic clients: the model is asked for a JSON
+// object with the answer, its key points, and citations of the sources, and
+// the object is checked against the sources before it is returned.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RAG answer formats.
+const (
+	RAGFormatText       = "text"
+	RAGFormatStructured = "structured"
+)
+
+// structuredFormatPrompt is added to the system prompt for structured
+// answers.
+const structuredFormatPrompt = `Respond with only a JSON object, with no other text, of the form:
+{"answer": "...", "key_points": ["..."], "citations": [{"source": 1, "quote": "..."}]}
+"answer" is your answer. "key_points" are its main points, one short sentence each.
+Each citation's "source" is the number N of a [Source N] in the context that supports
+the answer, and "quote" is a short exact quotation from it. Cite only sources you used.`
+
+// errInvalidStructuredAnswer is returned when the model's reply fails
+// validation even after a correction.
+var errInvalidStructuredAnswer = errors.New("invalid structured answer")
+
+// structuredRetryPrompt asks the model to correct an answer that failed
+// validation.
+const structuredRetryPrompt = "Your reply was not valid: %v. Reply again with only the JSON object."
+
+// StructuredAnswer is a RAG answer in the structured format.
+type StructuredAnswer struct {
+	Answer    string     `json:"answer"`
+	KeyPoints []string   `json:"key_points"`
+	Citations []Citation `json:"citations"`
+}
+
+// Citation is a source that supports a structured answer. Source is the
+// source's 1-based position in the response's sources.
+type Citation struct {
+	Source int    `json:"source"`
+	Title  string `json:"title"`
+	Quote  string `json:"quote,omitempty"`
+}
+
+// parseStructuredAnswer decodes and validates a structured answer to a
+// query answered from snippets, filling in each citation's title.
+func parseStructuredAnswer(raw string, snippets []Snippet) (*StructuredAnswer, error) {
+	raw = strings.TrimSpace(raw)
+	// Models often fence JSON in a Markdown code block despite being asked
+	// not to
+	if strings.HasPrefix(raw, "```") {
+		raw = strings.TrimPrefix(raw, "```json")
+		raw = strings.TrimPrefix(raw, "```")
+		raw = strings.TrimSuffix(strings.TrimSpace(raw), "```")
+	}
+
+	var answer StructuredAnswer
+	if err := json.Unmarshal([]byte(raw), &answer); err != nil {
+		return nil, fmt.Errorf("not a JSON object of the requested form: %w", err)
+	}
+
+	answer.Answer = strings.TrimSpace(answer.Answer)
+	if answer.Answer == "" {
+		return nil, errors.New(`"answer" is empty`)
+	}
+
+	points := answer.KeyPoints[:0]
+	for _, p := range answer.KeyPoints {
+		if p = strings.TrimSpace(p); p != "" {
+			points = append(points, p)
+		}
+	}
+	if len(points) == 0 {
+		return nil, errors.New(`"key_points" is empty`)
+	}
+	answer.KeyPoints = points
+
+	for i, c := range answer.Citations {
+		if c.Source < 1 || c.Source > len(snippets) {
+			return nil, fmt.Errorf("citation %d names source %d, but there are %d sources", i+1, c.Source, len(snippets))
+		}
+		answer.Citations[i].Title = snippets[c.Source-1].Title
+	}
+	if answer.Citations == nil {
+		answer.Citations = []Citation{}
+	}
+
+	return &answer, nil
+}
+
+// GenerateStructured answers query from snippets in the structured format.
+// A reply that fails validation is sent back to the model once to correct;
+// both attempts share one completion timeout, so a RAG request waits no
+// longer than for a text answer.
+func (rh *RAGHelper) GenerateStructured(ctx context.Context, query string, snippets []Snippet, systemPrompt string) (*StructuredAnswer, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeouts.Completion)
+	defer cancel()
+
+	if systemPrompt == "" {
+		systemPrompt = defaultRAGSystemPrompt
+	}
+	payload := rh.completionRequest(query, rh.FormatContextForLLM(snippets), systemPrompt+"\n\n"+structuredFormatPrompt)
+
+	raw, err := rh.complete(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := parseStructuredAnswer(raw, snippets)
+	if err == nil {
+		return answer, nil
+	}
+
+	payload.Messages = append(payload.Messages,
+		CompletionMessage{Role: "assistant", Content: raw},
+		CompletionMessage{Role: "user", Content: fmt.Sprintf(structuredRetryPrompt, err)},
+	)
+	raw, err = rh.complete(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	answer, err = parseStructuredAnswer(raw, snippets)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidStructuredAnswer, err)
+	}
+	return answer, nil
+}
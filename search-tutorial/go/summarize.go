@@ -0,0 +1,157 @@
+// Gloo AI Search API - Snippet Summarization
+//
+// Optionally condenses long snippets through Completions V2 before they go
+// into the RAG context. Each snippet is summarized with the question in
+// view, concurrently, so the final prompt stays small while every source
+// still contributes.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// summarizePrompt asks Completions V2 to condense one snippet.
+const summarizePrompt = "Summarize the following source text in at most %d characters, keeping " +
+	"only what helps answer the user's question. Keep names, numbers, and quotations exact. " +
+	"Reply with the summary only."
+
+// RAGOptions configures how RAGHelper builds the context for an answer.
+type RAGOptions struct {
+	// Summarize condenses snippets longer than the per-snippet limit
+	// instead of cutting them off.
+	Summarize bool
+	// SourceChars is how much of each snippet is read when summarizing.
+	SourceChars int
+	// Concurrency is how many snippets are summarized at once.
+	Concurrency int
+	// Window is how many neighboring chunks on each side are merged into a
+	// matched snippet; 0 leaves snippets as they matched.
+	Window int
+}
+
+// ragOptions is the configuration of every RAGHelper, set from the
+// environment in main.
+var ragOptions RAGOptions
+
+// LoadRAGOptions reads the RAG options from the environment.
+func LoadRAGOptions() RAGOptions {
+	return RAGOptions{
+		Summarize:   strings.EqualFold(getEnv("RAG_SUMMARIZE", "false"), "true"),
+		SourceChars: getEnvInt("RAG_SUMMARIZE_SOURCE_CHARS", 2000),
+		Concurrency: getEnvInt("RAG_SUMMARIZE_CONCURRENCY", 4),
+		Window:      getEnvInt("RAG_SNIPPET_WINDOW", 0),
+	}
+}
+
+// Validate checks that the options are usable.
+func (o RAGOptions) Validate() error {
+	if o.Window < 0 {
+		return fmt.Errorf("RAG_SNIPPET_WINDOW must not be negative, got %d", o.Window)
+	}
+	if !o.Summarize {
+		return nil
+	}
+	if o.SourceChars <= 0 {
+		return fmt.Errorf("RAG_SUMMARIZE_SOURCE_CHARS must be positive, got %d", o.SourceChars)
+	}
+	if o.Concurrency < 1 {
+		return fmt.Errorf("RAG_SUMMARIZE_CONCURRENCY must be at least 1, got %d", o.Concurrency)
+	}
+	return nil
+}
+
+// PrepareSnippets extracts the snippets to answer query from. With a
+// snippet window, each is first widened with its neighboring chunks, and
+// may be that many times longer. With summarization on, snippets longer
+// than maxCharsPerSnippet are summarized down to it; otherwise they are cut
+// off. The returned error lists the neighbors that couldn't be fetched and
+// the snippets that couldn't be summarized, which are cut off instead, so
+// the snippets are always usable.
+//
+// Fetching neighbors takes at most one search timeout in all, and
+// summarizing at most one completion timeout, however many snippets wait
+// for a turn.
+func (rh *RAGHelper) PrepareSnippets(ctx context.Context, query string, results *SearchResponse, maxSnippets, maxCharsPerSnippet int) ([]Snippet, error) {
+	var expandErr error
+	if rh.Options.Window > 0 {
+		expandCtx, cancel := context.WithTimeout(ctx, timeouts.Search)
+		results, expandErr = rh.ExpandWindows(expandCtx, results, maxSnippets, rh.Options.Window)
+		cancel()
+		maxCharsPerSnippet *= 2*rh.Options.Window + 1
+	}
+
+	if !rh.Options.Summarize {
+		return rh.ExtractSnippets(results, maxSnippets, maxCharsPerSnippet), expandErr
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeouts.Completion)
+	defer cancel()
+	snippets := rh.ExtractSnippets(results, maxSnippets, rh.Options.SourceChars)
+	snippets, err := rh.SummarizeSnippets(ctx, query, snippets, maxCharsPerSnippet)
+	return snippets, errors.Join(expandErr, err)
+}
+
+// SummarizeSnippets summarizes, concurrently, each snippet longer than
+// maxChars. A snippet whose summary fails is cut off at maxChars.
+func (rh *RAGHelper) SummarizeSnippets(ctx context.Context, query string, snippets []Snippet, maxChars int) ([]Snippet, error) {
+	concurrency := rh.Options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	out := make([]Snippet, len(snippets))
+	errs := make([]error, len(snippets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, s := range snippets {
+		out[i] = s
+		if len(s.Text) <= maxChars {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, s Snippet) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			summary, err := rh.summarize(ctx, query, s.Text, maxChars)
+			if err != nil {
+				errs[i] = fmt.Errorf("summarizing %q: %w", s.Title, err)
+				summary = s.Text
+			}
+			if len(summary) > maxChars {
+				summary = summary[:maxChars]
+			}
+			out[i].Text = summary
+		}(i, s)
+	}
+
+	wg.Wait()
+	return out, errors.Join(errs...)
+}
+
+// summarize condenses one snippet's text with query in view.
+func (rh *RAGHelper) summarize(ctx context.Context, query, text string, maxChars int) (string, error) {
+	summary, err := rh.complete(ctx, CompletionRequest{
+		Messages: []CompletionMessage{
+			{Role: "system", Content: fmt.Sprintf(summarizePrompt, maxChars)},
+			{Role: "user", Content: fmt.Sprintf("Question: %s\n\nSource text:\n%s", query, text)},
+		},
+		AutoRouting: true,
+		// About four characters per token, with room to spare
+		MaxTokens: maxChars/3 + 16,
+	})
+	if err != nil {
+		return "", err
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return "", errors.New("empty summary")
+	}
+	return summary, nil
+}
@@ -0,0 +1,86 @@
+// Gloo AI Search API - Tenant Routing
+//
+// Lets one proxy server search for several tenants (publishers). A request
+// names its tenant in the X-Gloo-Tenant header or a tenant parameter, which
+// must be on the server's allowlist; requests that name none search
+// GLOO_TENANT.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// TenantHeader is the request header that names the tenant to search.
+const TenantHeader = "X-Gloo-Tenant"
+
+// errTenantNotAllowed is returned for a tenant that isn't on the allowlist.
+var errTenantNotAllowed = errors.New("tenant is not allowed")
+
+// TenantAllowlist is the set of tenants requests may name. The default
+// tenant is always allowed.
+type TenantAllowlist struct {
+	Default string
+	allowed map[string]bool
+}
+
+// NewTenantAllowlist creates an allowlist of the default tenant and others.
+func NewTenantAllowlist(defaultTenant string, others []string) *TenantAllowlist {
+	a := &TenantAllowlist{Default: defaultTenant, allowed: map[string]bool{defaultTenant: true}}
+	for _, t := range others {
+		a.allowed[t] = true
+	}
+	return a
+}
+
+// Resolve returns the tenant to search for a requested one, which is the
+// default when none was requested.
+func (a *TenantAllowlist) Resolve(requested string) (string, error) {
+	if requested == "" {
+		return a.Default, nil
+	}
+	if !a.allowed[requested] {
+		return "", errTenantNotAllowed
+	}
+	return requested, nil
+}
+
+// Tenants returns the allowed tenants, sorted.
+func (a *TenantAllowlist) Tenants() []string {
+	tenants := make([]string, 0, len(a.allowed))
+	for t := range a.allowed {
+		tenants = append(tenants, t)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// requestTenant returns the tenant a request names: the X-Gloo-Tenant
+// header, or else field, the tenant parameter from the query string or body.
+func requestTenant(r *http.Request, field string) string {
+	if t := r.Header.Get(TenantHeader); t != "" {
+		return t
+	}
+	return field
+}
+
+// newTenantAllowlistFromEnv returns the allowlist of GLOO_TENANT and the
+// tenants in PROXY_ALLOWED_TENANTS.
+func newTenantAllowlistFromEnv() *TenantAllowlist {
+	return NewTenantAllowlist(tenant, splitList(os.Getenv("PROXY_ALLOWED_TENANTS")))
+}
+
+// resolveTenant returns the tenant a request names, or writes a 403 and
+// returns false if it isn't allowed.
+func resolveTenant(w http.ResponseWriter, r *http.Request, tenants *TenantAllowlist, field string) (string, bool) {
+	t, err := tenants.Resolve(requestTenant(r, field))
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "Tenant is not allowed"})
+		return "", false
+	}
+	return t, true
+}
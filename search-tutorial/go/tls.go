@@ -0,0 +1,97 @@
+// Gloo AI Search API - TLS
+//
+// HTTPS support for the proxy server, either from a certificate/key pair on
+// disk or from Let's Encrypt certificates obtained automatically (autocert).
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSOptions configures HTTPS for the proxy server.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+
+	// AutocertDomains enables Let's Encrypt mode for the listed host names.
+	AutocertDomains []string
+	AutocertCache   string
+	AutocertEmail   string
+	// AutocertHTTPAddr serves ACME HTTP-01 challenges and redirects plain
+	// HTTP traffic to HTTPS. Empty disables the listener.
+	AutocertHTTPAddr string
+}
+
+// Enabled reports whether the server should serve HTTPS.
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || len(o.AutocertDomains) > 0
+}
+
+// Autocert reports whether certificates come from Let's Encrypt.
+func (o TLSOptions) Autocert() bool {
+	return len(o.AutocertDomains) > 0
+}
+
+// Validate checks that the TLS options are consistent.
+func (o TLSOptions) Validate() error {
+	if o.Autocert() {
+		if o.CertFile != "" || o.KeyFile != "" {
+			return errors.New("--autocert cannot be combined with --tls-cert/--tls-key")
+		}
+		if o.AutocertCache == "" {
+			return errors.New("--autocert-cache must not be empty")
+		}
+		return nil
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("--tls-cert and --tls-key must be set together")
+	}
+	for _, path := range []string{o.CertFile, o.KeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("cannot read %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// configureTLS prepares srv for HTTPS and returns the function that starts
+// serving it. In autocert mode it also returns a companion HTTP server for
+// ACME challenges (nil when AutocertHTTPAddr is empty).
+func configureTLS(srv *http.Server, opts TLSOptions) (func() error, *http.Server) {
+	if !opts.Autocert() {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		return func() error { return srv.ListenAndServeTLS(opts.CertFile, opts.KeyFile) }, nil
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(opts.AutocertDomains...),
+		Cache:      autocert.DirCache(opts.AutocertCache),
+		Email:      opts.AutocertEmail,
+	}
+	srv.TLSConfig = m.TLSConfig()
+	srv.TLSConfig.MinVersion = tls.VersionTLS12
+
+	var challenge *http.Server
+	if opts.AutocertHTTPAddr != "" {
+		challenge = &http.Server{
+			Addr:              opts.AutocertHTTPAddr,
+			Handler:           m.HTTPHandler(nil),
+			ReadHeaderTimeout: serverReadHeaderTimeout,
+			ReadTimeout:       serverReadTimeout,
+			WriteTimeout:      serverReadTimeout,
+			IdleTimeout:       serverIdleTimeout,
+		}
+	}
+
+	return func() error { return srv.ListenAndServeTLS("", "") }, challenge
+}
@@ -0,0 +1,167 @@
+// Gloo AI Search API - Request Validation
+//
+// Bounds what proxy server clients can send: the size of request bodies, the
+// length of queries, and the range of limits and certainty thresholds.
+// Invalid input is rejected with a 400 naming the field at fault, before
+// anything is sent upstream.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSearchLimit is the largest result limit a proxy request may ask for.
+const maxSearchLimit = 100
+
+// RequestLimits bounds the input of proxy server requests.
+type RequestLimits struct {
+	// MaxBodyBytes is the largest request body accepted.
+	MaxBodyBytes int64
+	// MaxQueryChars is the longest search query accepted, in characters.
+	MaxQueryChars int
+}
+
+// LoadRequestLimits reads the request limits from the environment.
+func LoadRequestLimits() RequestLimits {
+	return RequestLimits{
+		MaxBodyBytes:  int64(getEnvInt("PROXY_MAX_BODY_BYTES", 64*1024)),
+		MaxQueryChars: getEnvInt("PROXY_MAX_QUERY_CHARS", 1000),
+	}
+}
+
+// Validate checks that the limits are positive.
+func (l RequestLimits) Validate() error {
+	if l.MaxBodyBytes <= 0 {
+		return errors.New("PROXY_MAX_BODY_BYTES must be positive")
+	}
+	if l.MaxQueryChars <= 0 {
+		return errors.New("PROXY_MAX_QUERY_CHARS must be positive")
+	}
+	return nil
+}
+
+// ValidationError is invalid input in one field of a request. Field is the
+// query parameter or JSON field, such as "q" or "queries[2].limit".
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// CheckQuery checks a search query's length. Whether a query is required is
+// left to the caller.
+func (l RequestLimits) CheckQuery(field, query string) *ValidationError {
+	if utf8.RuneCountInString(query) > l.MaxQueryChars {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("'%s' must be at most %d characters", field, l.MaxQueryChars)}
+	}
+	return nil
+}
+
+// checkLimit checks a result limit. Zero means the endpoint's default.
+func checkLimit(field string, limit int) *ValidationError {
+	if limit < 0 || limit > maxSearchLimit {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("'%s' must be between 1 and %d", field, maxSearchLimit)}
+	}
+	return nil
+}
+
+// checkCertainty checks a minimum certainty. Zero means no filtering.
+func checkCertainty(field string, certainty float64) *ValidationError {
+	if !(certainty >= 0 && certainty <= 1) {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("'%s' must be between 0 and 1", field)}
+	}
+	return nil
+}
+
+// checkParams checks the query, limit, and certainty parameters in a
+// request's query string, if present.
+func (l RequestLimits) checkParams(r *http.Request) *ValidationError {
+	params := r.URL.Query()
+	if err := l.CheckQuery("q", params.Get("q")); err != nil {
+		return err
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return &ValidationError{Field: "limit", Message: "'limit' must be an integer"}
+		}
+		if err := checkLimit("limit", limit); err != nil {
+			return err
+		}
+	}
+	if value := params.Get("certainty"); value != "" {
+		certainty, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return &ValidationError{Field: "certainty", Message: "'certainty' must be a number"}
+		}
+		if err := checkCertainty("certainty", certainty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler rejects API requests with oversized bodies or invalid query
+// parameters, and caps the body that later handlers can read.
+func (l RequestLimits) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > l.MaxBodyBytes {
+			writeBodyTooLarge(w, l.MaxBodyBytes)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, l.MaxBodyBytes)
+
+		if err := l.checkParams(r); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSONBody decodes a request body into v, writing an error response
+// and returning false if it is too large or isn't valid JSON.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &tooLarge):
+		writeBodyTooLarge(w, tooLarge.Limit)
+	default:
+		writeValidationError(w, &ValidationError{Field: "body", Message: "Request body must be a JSON object"})
+	}
+	return false
+}
+
+// writeValidationError writes a 400 naming the invalid field.
+func writeValidationError(w http.ResponseWriter, err *ValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: err.Message, Field: err.Field})
+}
+
+// writeBodyTooLarge writes a 413 for a body over limit bytes.
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error: fmt.Sprintf("Request body must be at most %d bytes", limit),
+		Field: "body",
+	})
+}
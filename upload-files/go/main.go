@@ -100,7 +100,8 @@ func getEnv(key, fallback string) string {
 
 // getAccessToken retrieves a new access token from the OAuth2 endpoint.
 func getAccessToken() (*TokenInfo, error) {
-	data := strings.NewReader("grant_type=client_credentials&scope=api/access")
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {getEnv("GLOO_SCOPE", "api/access")}}
+	data := strings.NewReader(form.Encode())
 
 	req, err := http.NewRequest("POST", tokenURL, data)
 	if err != nil {
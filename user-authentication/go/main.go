@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Configuration
+var (
+	clientID string
+	// The authorize and revoke endpoints aren't in the Gloo AI API
+	// documentation; these defaults assume the usual OAuth2 layout
+	authorizeURL = "https://platform.ai.gloo.com/oauth2/authorize"
+	tokenURL     = "https://platform.ai.gloo.com/oauth2/token"
+	revokeURL    = "https://platform.ai.gloo.com/oauth2/revoke"
+	apiURL       = "https://platform.ai.gloo.com/ai/v2/chat/completions"
+	scope        = "openid email profile api/access"
+	redirectPort = 8765
+)
+
+// ChatMessage represents a chat message
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatCompletionRequest represents the request payload
+type ChatCompletionRequest struct {
+	AutoRouting bool          `json:"auto_routing"`
+	Messages    []ChatMessage `json:"messages"`
+}
+
+// ChatCompletionResponse represents the API response
+type ChatCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// Session is a signed-in user. The access and ID tokens live only in
+// memory; the refresh token is kept in the token store so the next run
+// doesn't need another sign-in.
+type Session struct {
+	store     TokenStore
+	tokens    *tokenResponse
+	expiresAt time.Time
+}
+
+// getEnv returns environment variable or default value
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// newSession starts a session from a token response, storing its refresh
+// token. Identity providers that rotate refresh tokens send a new one with
+// every refresh, replacing the old one.
+func newSession(store TokenStore, tokens *tokenResponse) (*Session, error) {
+	if tokens.RefreshToken != "" {
+		if err := store.Save(clientID, tokens.RefreshToken); err != nil {
+			return nil, fmt.Errorf("%w (set GLOO_TOKEN_STORE=file if this machine has no secret storage)", err)
+		}
+	}
+	expiresIn := tokens.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = 3600
+	}
+	return &Session{
+		store:     store,
+		tokens:    tokens,
+		expiresAt: time.Now().Add(time.Duration(expiresIn) * time.Second),
+	}, nil
+}
+
+// resumeSession signs the user in with their stored refresh token
+func resumeSession(store TokenStore) (*Session, error) {
+	refreshToken, err := store.Load(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken == "" {
+		return nil, errLoginRequired
+	}
+	tokens, err := refreshTokens(refreshToken)
+	if err == errLoginRequired {
+		// The stored token is no good any more, so don't try it again
+		store.Delete(clientID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Providers that don't rotate refresh tokens leave the stored one valid
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = refreshToken
+	}
+	return newSession(store, tokens)
+}
+
+// AccessToken returns a valid access token for the user, refreshing it a
+// minute before it expires
+func (s *Session) AccessToken() (string, error) {
+	if time.Until(s.expiresAt) > time.Minute {
+		return s.tokens.AccessToken, nil
+	}
+	refreshed, err := resumeSession(s.store)
+	if err != nil {
+		return "", err
+	}
+	*s = *refreshed
+	return s.tokens.AccessToken, nil
+}
+
+// ask sends a message to the chat completions API on the user's behalf
+func (s *Session) ask(message string) (string, error) {
+	token, err := s.AccessToken()
+	if err != nil {
+		return "", err
+	}
+	reqBody, err := json.Marshal(ChatCompletionRequest{
+		AutoRouting: true,
+		Messages:    []ChatMessage{{Role: "user", Content: message}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API call failed: %s - %s", resp.Status, string(body))
+	}
+
+	var response ChatCompletionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("API returned no choices")
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
+// printUser shows who the session belongs to, from the ID token
+func (s *Session) printUser() {
+	if s.tokens.IDToken == "" {
+		fmt.Println("✓ Signed in (no ID token; add openid to GLOO_USER_SCOPE to see who)")
+		return
+	}
+	claims, err := parseIDToken(s.tokens.IDToken)
+	if err != nil {
+		fmt.Printf("✓ Signed in (%v)\n", err)
+		return
+	}
+	who := claims.Name
+	if claims.Email != "" {
+		who = strings.TrimSpace(who + " <" + claims.Email + ">")
+	}
+	if who == "" {
+		who = claims.Subject
+	}
+	fmt.Printf("✓ Signed in as %s\n", who)
+}
+
+func printUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  go run . login            # sign in with your browser")
+	fmt.Println("  go run . whoami           # show who is signed in")
+	fmt.Println("  go run . ask <message>    # call the API as the signed-in user")
+	fmt.Println("  go run . logout           # revoke and forget the stored sign-in")
+}
+
+// run carries out a command
+func run(store TokenStore, command string, args []string) error {
+	switch command {
+	case "login":
+		tokens, err := login()
+		if err != nil {
+			return err
+		}
+		session, err := newSession(store, tokens)
+		if err != nil {
+			return err
+		}
+		session.printUser()
+		if tokens.RefreshToken == "" {
+			fmt.Println("  No refresh token was issued, so you'll need to sign in again when this session expires")
+		} else {
+			fmt.Println("  Refresh token stored in", store.Name())
+		}
+
+	case "whoami":
+		session, err := resumeSession(store)
+		if err != nil {
+			return err
+		}
+		session.printUser()
+		fmt.Printf("  Access token expires at %s\n", session.expiresAt.Format(time.RFC3339))
+
+	case "ask":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: ask <message>")
+		}
+		session, err := resumeSession(store)
+		if err != nil {
+			return err
+		}
+		answer, err := session.ask(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		fmt.Println(answer)
+
+	case "logout":
+		refreshToken, err := store.Load(clientID)
+		if err != nil {
+			return err
+		}
+		if refreshToken == "" {
+			fmt.Println("Not signed in")
+			return nil
+		}
+		if err := revokeRefreshToken(refreshToken); err != nil {
+			fmt.Printf("Warning: %v; forgetting it anyway\n", err)
+		}
+		if err := store.Delete(clientID); err != nil {
+			return err
+		}
+		fmt.Println("✓ Signed out")
+
+	default:
+		printUsage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+	return nil
+}
+
+// main is the entry point
+func main() {
+	if err := godotenv.Load(); err != nil {
+		fmt.Println("No .env file found, using environment variables")
+	}
+
+	clientID = getEnv("GLOO_USER_CLIENT_ID", "")
+	authorizeURL = getEnv("GLOO_AUTHORIZE_URL", authorizeURL)
+	tokenURL = getEnv("GLOO_TOKEN_URL", tokenURL)
+	revokeURL = getEnv("GLOO_REVOKE_URL", revokeURL)
+	scope = getEnv("GLOO_USER_SCOPE", scope)
+	if port, err := strconv.Atoi(getEnv("GLOO_REDIRECT_PORT", "")); err == nil {
+		redirectPort = port
+	}
+
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+	if clientID == "" {
+		fmt.Println("Please set GLOO_USER_CLIENT_ID to the client ID of a public app client")
+		fmt.Println("that allows the authorization code grant with PKCE and has")
+		fmt.Printf("%s as a callback URL.\n", redirectURI())
+		os.Exit(1)
+	}
+
+	store, err := openTokenStore()
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+	if err := run(store, os.Args[1], os.Args[2:]); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// loginTimeout is how long login waits for the browser to come back
+const loginTimeout = 5 * time.Minute
+
+// errLoginRequired means the user has to sign in again: there is no stored
+// refresh token, or the identity provider no longer accepts it
+var errLoginRequired = errors.New("not signed in; run `go run . login` first")
+
+// tokenResponse is a token endpoint response for the authorization code
+// and refresh token grants
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+}
+
+// randomString returns n random bytes, base64url-encoded
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallenge derives the S256 PKCE challenge for a code verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// redirectURI is where the identity provider sends the browser back to. It
+// must be registered for the client exactly as written here.
+func redirectURI() string {
+	return fmt.Sprintf("http://localhost:%d/callback", redirectPort)
+}
+
+// authorizationURL builds the sign-in page URL for a PKCE login
+func authorizationURL(state, verifier string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI()},
+		"scope":                 {scope},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	return authorizeURL + "?" + q.Encode()
+}
+
+// login signs the user in with the authorization code flow and PKCE: it
+// opens the sign-in page in a browser, receives the authorization code on a
+// local callback, and exchanges it for tokens. The code verifier never
+// leaves this process, so an intercepted code is useless on its own.
+func login() (*tokenResponse, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", redirectPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the sign-in callback on port %d (set GLOO_REDIRECT_PORT to use another registered port): %w", redirectPort, err)
+	}
+	codes := make(chan string, 1)
+	failures := make(chan error, 1)
+	server := &http.Server{Handler: callbackHandler(state, codes, failures)}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	signInURL := authorizationURL(state, verifier)
+	fmt.Println("Opening your browser to sign in. If it doesn't open, visit:")
+	fmt.Println()
+	fmt.Println("  " + signInURL)
+	fmt.Println()
+	openBrowser(signInURL)
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-failures:
+		return nil, err
+	case <-time.After(loginTimeout):
+		return nil, fmt.Errorf("timed out after %s waiting for sign-in", loginTimeout)
+	}
+
+	return requestTokens(url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"redirect_uri":  {redirectURI()},
+		"code_verifier": {verifier},
+	})
+}
+
+// callbackHandler receives the browser's redirect back from the sign-in
+// page and passes on the authorization code. The state must match, so
+// another site can't complete a login it started.
+func callbackHandler(state string, codes chan<- string, failures chan<- error) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var err error
+		switch {
+		case q.Get("state") != state:
+			err = fmt.Errorf("sign-in callback had the wrong state; try again")
+		case q.Get("error") != "":
+			err = fmt.Errorf("sign-in failed: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("code") == "":
+			err = fmt.Errorf("sign-in callback had no authorization code")
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<p>Sign-in failed. Check the terminal for details.</p>")
+			select {
+			case failures <- err:
+			default:
+			}
+			return
+		}
+		fmt.Fprint(w, "<p>Signed in to Gloo AI. You can close this tab.</p>")
+		select {
+		case codes <- q.Get("code"):
+		default:
+		}
+	})
+	return mux
+}
+
+// openBrowser tries to open url in the default browser. Failure is fine:
+// the URL is also printed.
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+}
+
+// refreshTokens exchanges a refresh token for new tokens
+func refreshTokens(refreshToken string) (*tokenResponse, error) {
+	return requestTokens(url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// requestTokens posts a grant to the token endpoint. This is a public
+// client, so it sends its client ID but no secret.
+func requestTokens(form url.Values) (*tokenResponse, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.PostForm(tokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// An expired or revoked refresh token means signing in again
+		if form.Get("grant_type") == "refresh_token" && strings.Contains(string(body), "invalid_grant") {
+			return nil, errLoginRequired
+		}
+		return nil, fmt.Errorf("failed to get tokens: %s - %s", resp.Status, string(body))
+	}
+
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if tokens.AccessToken == "" {
+		return nil, fmt.Errorf("failed to parse response: no access_token in %s", string(body))
+	}
+	return &tokens, nil
+}
+
+// revokeRefreshToken revokes a refresh token, and with it the tokens it
+// issued, with an RFC 7009 revocation request
+func revokeRefreshToken(refreshToken string) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.PostForm(revokeURL, url.Values{
+		"token":           {refreshToken},
+		"token_type_hint": {"refresh_token"},
+		"client_id":       {clientID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke refresh token: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// userClaims are the ID token claims shown for the signed-in user
+type userClaims struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+}
+
+// parseIDToken reads the claims of an ID token. It came straight from the
+// token endpoint over TLS, so its signature isn't checked here; verify it
+// before trusting the claims anywhere else.
+func parseIDToken(idToken string) (*userClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("ID token is not a JWT")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ID token: %w", err)
+	}
+	var claims userClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token: %w", err)
+	}
+	return &claims, nil
+}
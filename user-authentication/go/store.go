@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// TokenStore keeps a user's refresh token between runs. Refresh tokens are
+// long-lived credentials, so the stores prefer the operating system's
+// secret storage over files.
+type TokenStore interface {
+	Name() string
+	Load(account string) (string, error) // "" if nothing is stored
+	Save(account, token string) error
+	Delete(account string) error
+}
+
+// keychainService names the stored tokens in the OS secret storage
+const keychainService = "gloo-ai-user-auth"
+
+// tokenStores builds the store named by GLOO_TOKEN_STORE
+var tokenStores = map[string]func() (TokenStore, error){
+	"keychain":       newKeychainStore,
+	"secret-service": newSecretServiceStore,
+	"file":           newFileStore,
+}
+
+// openTokenStore returns the store named by GLOO_TOKEN_STORE, or the most
+// secure one available here: the macOS keychain, the Linux Secret Service
+// (GNOME Keyring, KWallet), or else a file only this user can read
+func openTokenStore() (TokenStore, error) {
+	if name := getEnv("GLOO_TOKEN_STORE", ""); name != "" {
+		newStore, ok := tokenStores[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown token store %q (expected keychain, secret-service, or file)", name)
+		}
+		return newStore()
+	}
+	for _, newStore := range []func() (TokenStore, error){newKeychainStore, newSecretServiceStore} {
+		if store, err := newStore(); err == nil {
+			return store, nil
+		}
+	}
+	fmt.Println("Warning: no OS secret storage found; keeping the refresh token in a file only you can read")
+	return newFileStore()
+}
+
+// keychainStore keeps tokens in the macOS keychain with the security tool
+type keychainStore struct{}
+
+func newKeychainStore() (TokenStore, error) {
+	if runtime.GOOS != "darwin" {
+		return nil, fmt.Errorf("the keychain store needs macOS")
+	}
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil, fmt.Errorf("the keychain store needs the security tool")
+	}
+	return keychainStore{}, nil
+}
+
+func (keychainStore) Name() string { return "macOS keychain" }
+
+func (keychainStore) Load(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	if err != nil {
+		// security exits with 44 when there is no such item
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read the keychain: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (keychainStore) Save(account, token string) error {
+	// Each value is its own argument, so nothing in the account or token
+	// can be read as another option or command. The token shows in the
+	// process list only while security runs.
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-w", token)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write the keychain: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (keychainStore) Delete(account string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", account).Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+		return nil
+	}
+	return err
+}
+
+// secretServiceStore keeps tokens in the Linux Secret Service with
+// secret-tool, from libsecret
+type secretServiceStore struct{}
+
+func newSecretServiceStore() (TokenStore, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("the secret-service store needs Linux")
+	}
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil, fmt.Errorf("the secret-service store needs secret-tool (libsecret-tools)")
+	}
+	return secretServiceStore{}, nil
+}
+
+func (secretServiceStore) Name() string { return "Secret Service" }
+
+func (secretServiceStore) Load(account string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// secret-tool exits with 1 and says nothing when there is no match
+		if stderr.Len() == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read the Secret Service: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (secretServiceStore) Save(account, token string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=Gloo AI refresh token", "service", keychainService, "account", account)
+	cmd.Stdin = strings.NewReader(token)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write the Secret Service: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (secretServiceStore) Delete(account string) error {
+	return exec.Command("secret-tool", "clear", "service", keychainService, "account", account).Run()
+}
+
+// fileStore keeps tokens in a file readable only by this user, under the
+// user's config directory. It is the fallback where there is no OS secret
+// storage, such as on servers and in containers.
+type fileStore struct {
+	dir string
+}
+
+func newFileStore() (TokenStore, error) {
+	dir := getEnv("GLOO_TOKEN_DIR", "")
+	if dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find a directory for tokens; set GLOO_TOKEN_DIR: %w", err)
+		}
+		dir = filepath.Join(config, keychainService)
+	}
+	return fileStore{dir: dir}, nil
+}
+
+func (s fileStore) Name() string { return "file in " + s.dir }
+
+func (s fileStore) path(account string) string {
+	return filepath.Join(s.dir, safeFileName(account)+".token")
+}
+
+func (s fileStore) Load(account string) (string, error) {
+	data, err := ioutil.ReadFile(s.path(account))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read stored token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s fileStore) Save(account, token string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+	if err := ioutil.WriteFile(s.path(account), []byte(token), 0600); err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+	// WriteFile only sets the mode of a new file, so tighten one left
+	// readable by an earlier version or another tool
+	if err := os.Chmod(s.path(account), 0600); err != nil {
+		return fmt.Errorf("failed to restrict stored token: %w", err)
+	}
+	return nil
+}
+
+func (s fileStore) Delete(account string) error {
+	if err := os.Remove(s.path(account)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete stored token: %w", err)
+	}
+	return nil
+}
+
+// safeFileName makes an account name safe to use as a file name
+func safeFileName(s string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_", "..", "_").Replace(s)
+}